@@ -22,12 +22,16 @@ const (
 )
 
 type CreateSessionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	Pdrs          []*PDR                 `protobuf:"bytes,2,rep,name=pdrs,proto3" json:"pdrs,omitempty"`
-	Fars          []*FAR                 `protobuf:"bytes,3,rep,name=fars,proto3" json:"fars,omitempty"`
-	Qers          []*QER                 `protobuf:"bytes,4,rep,name=qers,proto3" json:"qers,omitempty"`
-	Urrs          []*URR                 `protobuf:"bytes,5,rep,name=urrs,proto3" json:"urrs,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	NodeId string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Pdrs   []*PDR                 `protobuf:"bytes,2,rep,name=pdrs,proto3" json:"pdrs,omitempty"`
+	Fars   []*FAR                 `protobuf:"bytes,3,rep,name=fars,proto3" json:"fars,omitempty"`
+	Qers   []*QER                 `protobuf:"bytes,4,rep,name=qers,proto3" json:"qers,omitempty"`
+	Urrs   []*URR                 `protobuf:"bytes,5,rep,name=urrs,proto3" json:"urrs,omitempty"`
+	Bar    *BAR                   `protobuf:"bytes,6,opt,name=bar,proto3" json:"bar,omitempty"`
+	// Key under which the session's addresses from IP pools are bound, so
+	// that the subscriber gets the same ones back.
+	SubscriberId  string `protobuf:"bytes,7,opt,name=subscriber_id,json=subscriberId,proto3" json:"subscriber_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -97,9 +101,26 @@ func (x *CreateSessionRequest) GetUrrs() []*URR {
 	return nil
 }
 
+func (x *CreateSessionRequest) GetBar() *BAR {
+	if x != nil {
+		return x.Bar
+	}
+	return nil
+}
+
+func (x *CreateSessionRequest) GetSubscriberId() string {
+	if x != nil {
+		return x.SubscriberId
+	}
+	return ""
+}
+
 type CreateSessionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Seid          uint64                 `protobuf:"varint,1,opt,name=seid,proto3" json:"seid,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Seid  uint64                 `protobuf:"varint,1,opt,name=seid,proto3" json:"seid,omitempty"`
+	// Local F-TEIDs and UE IP addresses of the request's PDRs, including
+	// those the UP chose.
+	CreatedPdrs   []*CreatedPDR `protobuf:"bytes,2,rep,name=created_pdrs,json=createdPdrs,proto3" json:"created_pdrs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -141,13 +162,27 @@ func (x *CreateSessionResponse) GetSeid() uint64 {
 	return 0
 }
 
+func (x *CreateSessionResponse) GetCreatedPdrs() []*CreatedPDR {
+	if x != nil {
+		return x.CreatedPdrs
+	}
+	return nil
+}
+
 type ModifySessionRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Seid          uint64                 `protobuf:"varint,1,opt,name=seid,proto3" json:"seid,omitempty"`
-	Pdrs          []*PDR                 `protobuf:"bytes,2,rep,name=pdrs,proto3" json:"pdrs,omitempty"`
-	Fars          []*FAR                 `protobuf:"bytes,3,rep,name=fars,proto3" json:"fars,omitempty"`
-	Qers          []*QER                 `protobuf:"bytes,4,rep,name=qers,proto3" json:"qers,omitempty"`
-	Urrs          []*URR                 `protobuf:"bytes,5,rep,name=urrs,proto3" json:"urrs,omitempty"`
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Seid         uint64                 `protobuf:"varint,1,opt,name=seid,proto3" json:"seid,omitempty"`
+	Pdrs         []*PDR                 `protobuf:"bytes,2,rep,name=pdrs,proto3" json:"pdrs,omitempty"`
+	Fars         []*FAR                 `protobuf:"bytes,3,rep,name=fars,proto3" json:"fars,omitempty"`
+	Qers         []*QER                 `protobuf:"bytes,4,rep,name=qers,proto3" json:"qers,omitempty"`
+	Urrs         []*URR                 `protobuf:"bytes,5,rep,name=urrs,proto3" json:"urrs,omitempty"`
+	RemovePdrIds []uint32               `protobuf:"varint,6,rep,packed,name=remove_pdr_ids,json=removePdrIds,proto3" json:"remove_pdr_ids,omitempty"`
+	RemoveFarIds []uint32               `protobuf:"varint,7,rep,packed,name=remove_far_ids,json=removeFarIds,proto3" json:"remove_far_ids,omitempty"`
+	RemoveQerIds []uint32               `protobuf:"varint,8,rep,packed,name=remove_qer_ids,json=removeQerIds,proto3" json:"remove_qer_ids,omitempty"`
+	RemoveUrrIds []uint32               `protobuf:"varint,9,rep,packed,name=remove_urr_ids,json=removeUrrIds,proto3" json:"remove_urr_ids,omitempty"`
+	// Creates the session's BAR, or updates it if it has the same ID.
+	Bar           *BAR `protobuf:"bytes,10,opt,name=bar,proto3" json:"bar,omitempty"`
+	RemoveBar     bool `protobuf:"varint,11,opt,name=remove_bar,json=removeBar,proto3" json:"remove_bar,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -217,9 +252,54 @@ func (x *ModifySessionRequest) GetUrrs() []*URR {
 	return nil
 }
 
+func (x *ModifySessionRequest) GetRemovePdrIds() []uint32 {
+	if x != nil {
+		return x.RemovePdrIds
+	}
+	return nil
+}
+
+func (x *ModifySessionRequest) GetRemoveFarIds() []uint32 {
+	if x != nil {
+		return x.RemoveFarIds
+	}
+	return nil
+}
+
+func (x *ModifySessionRequest) GetRemoveQerIds() []uint32 {
+	if x != nil {
+		return x.RemoveQerIds
+	}
+	return nil
+}
+
+func (x *ModifySessionRequest) GetRemoveUrrIds() []uint32 {
+	if x != nil {
+		return x.RemoveUrrIds
+	}
+	return nil
+}
+
+func (x *ModifySessionRequest) GetBar() *BAR {
+	if x != nil {
+		return x.Bar
+	}
+	return nil
+}
+
+func (x *ModifySessionRequest) GetRemoveBar() bool {
+	if x != nil {
+		return x.RemoveBar
+	}
+	return false
+}
+
 type ModifySessionResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Success bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	// Local F-TEIDs and UE IP addresses of the request's PDRs, including
+	// those the UP chose.
+	CreatedPdrs   []*CreatedPDR `protobuf:"bytes,2,rep,name=created_pdrs,json=createdPdrs,proto3" json:"created_pdrs,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -261,6 +341,74 @@ func (x *ModifySessionResponse) GetSuccess() bool {
 	return false
 }
 
+func (x *ModifySessionResponse) GetCreatedPdrs() []*CreatedPDR {
+	if x != nil {
+		return x.CreatedPdrs
+	}
+	return nil
+}
+
+type CreatedPDR struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	PdrId      uint32                 `protobuf:"varint,1,opt,name=pdr_id,json=pdrId,proto3" json:"pdr_id,omitempty"`
+	LocalFteid *FTEID                 `protobuf:"bytes,2,opt,name=local_fteid,json=localFteid,proto3" json:"local_fteid,omitempty"`
+	// UE IP address, or IPv6 prefix as address/length, the PDR has.
+	UeIpAddress   string `protobuf:"bytes,3,opt,name=ue_ip_address,json=ueIpAddress,proto3" json:"ue_ip_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatedPDR) Reset() {
+	*x = CreatedPDR{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatedPDR) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatedPDR) ProtoMessage() {}
+
+func (x *CreatedPDR) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatedPDR.ProtoReflect.Descriptor instead.
+func (*CreatedPDR) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CreatedPDR) GetPdrId() uint32 {
+	if x != nil {
+		return x.PdrId
+	}
+	return 0
+}
+
+func (x *CreatedPDR) GetLocalFteid() *FTEID {
+	if x != nil {
+		return x.LocalFteid
+	}
+	return nil
+}
+
+func (x *CreatedPDR) GetUeIpAddress() string {
+	if x != nil {
+		return x.UeIpAddress
+	}
+	return ""
+}
+
 type DeleteSessionRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Seid          uint64                 `protobuf:"varint,1,opt,name=seid,proto3" json:"seid,omitempty"`
@@ -270,7 +418,7 @@ type DeleteSessionRequest struct {
 
 func (x *DeleteSessionRequest) Reset() {
 	*x = DeleteSessionRequest{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[4]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -282,7 +430,7 @@ func (x *DeleteSessionRequest) String() string {
 func (*DeleteSessionRequest) ProtoMessage() {}
 
 func (x *DeleteSessionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[4]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -295,7 +443,7 @@ func (x *DeleteSessionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteSessionRequest.ProtoReflect.Descriptor instead.
 func (*DeleteSessionRequest) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{4}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *DeleteSessionRequest) GetSeid() uint64 {
@@ -314,7 +462,7 @@ type DeleteSessionResponse struct {
 
 func (x *DeleteSessionResponse) Reset() {
 	*x = DeleteSessionResponse{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[5]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -326,7 +474,7 @@ func (x *DeleteSessionResponse) String() string {
 func (*DeleteSessionResponse) ProtoMessage() {}
 
 func (x *DeleteSessionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[5]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -339,7 +487,7 @@ func (x *DeleteSessionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeleteSessionResponse.ProtoReflect.Descriptor instead.
 func (*DeleteSessionResponse) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{5}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *DeleteSessionResponse) GetSuccess() bool {
@@ -357,7 +505,7 @@ type ListAssociationsRequest struct {
 
 func (x *ListAssociationsRequest) Reset() {
 	*x = ListAssociationsRequest{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[6]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -369,7 +517,7 @@ func (x *ListAssociationsRequest) String() string {
 func (*ListAssociationsRequest) ProtoMessage() {}
 
 func (x *ListAssociationsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[6]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -382,7 +530,7 @@ func (x *ListAssociationsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListAssociationsRequest.ProtoReflect.Descriptor instead.
 func (*ListAssociationsRequest) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{6}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{7}
 }
 
 type ListAssociationsResponse struct {
@@ -394,7 +542,7 @@ type ListAssociationsResponse struct {
 
 func (x *ListAssociationsResponse) Reset() {
 	*x = ListAssociationsResponse{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[7]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -406,7 +554,7 @@ func (x *ListAssociationsResponse) String() string {
 func (*ListAssociationsResponse) ProtoMessage() {}
 
 func (x *ListAssociationsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[7]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -419,7 +567,7 @@ func (x *ListAssociationsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListAssociationsResponse.ProtoReflect.Descriptor instead.
 func (*ListAssociationsResponse) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{7}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *ListAssociationsResponse) GetAssociations() []*Association {
@@ -440,7 +588,7 @@ type Association struct {
 
 func (x *Association) Reset() {
 	*x = Association{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[8]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -452,7 +600,7 @@ func (x *Association) String() string {
 func (*Association) ProtoMessage() {}
 
 func (x *Association) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[8]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -465,7 +613,7 @@ func (x *Association) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Association.ProtoReflect.Descriptor instead.
 func (*Association) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{8}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Association) GetNodeId() string {
@@ -489,31 +637,29 @@ func (x *Association) GetEstablishedAt() int64 {
 	return 0
 }
 
-type PDR struct {
+type AuditSessionsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	Precedence    uint32                 `protobuf:"varint,2,opt,name=precedence,proto3" json:"precedence,omitempty"`
-	Pdi           *PacketDetectionInfo   `protobuf:"bytes,3,opt,name=pdi,proto3" json:"pdi,omitempty"`
-	FarId         uint32                 `protobuf:"varint,4,opt,name=far_id,json=farId,proto3" json:"far_id,omitempty"`
+	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Repair        bool                   `protobuf:"varint,2,opt,name=repair,proto3" json:"repair,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PDR) Reset() {
-	*x = PDR{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[9]
+func (x *AuditSessionsRequest) Reset() {
+	*x = AuditSessionsRequest{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PDR) String() string {
+func (x *AuditSessionsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PDR) ProtoMessage() {}
+func (*AuditSessionsRequest) ProtoMessage() {}
 
-func (x *PDR) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[9]
+func (x *AuditSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -524,65 +670,97 @@ func (x *PDR) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PDR.ProtoReflect.Descriptor instead.
-func (*PDR) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{9}
+// Deprecated: Use AuditSessionsRequest.ProtoReflect.Descriptor instead.
+func (*AuditSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{10}
 }
 
-func (x *PDR) GetId() uint32 {
+func (x *AuditSessionsRequest) GetNodeId() string {
 	if x != nil {
-		return x.Id
+		return x.NodeId
 	}
-	return 0
+	return ""
 }
 
-func (x *PDR) GetPrecedence() uint32 {
+func (x *AuditSessionsRequest) GetRepair() bool {
 	if x != nil {
-		return x.Precedence
+		return x.Repair
 	}
-	return 0
+	return false
 }
 
-func (x *PDR) GetPdi() *PacketDetectionInfo {
+type AuditSessionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reports       []*AuditReport         `protobuf:"bytes,1,rep,name=reports,proto3" json:"reports,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AuditSessionsResponse) Reset() {
+	*x = AuditSessionsResponse{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AuditSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AuditSessionsResponse) ProtoMessage() {}
+
+func (x *AuditSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[11]
 	if x != nil {
-		return x.Pdi
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *PDR) GetFarId() uint32 {
+// Deprecated: Use AuditSessionsResponse.ProtoReflect.Descriptor instead.
+func (*AuditSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AuditSessionsResponse) GetReports() []*AuditReport {
 	if x != nil {
-		return x.FarId
+		return x.Reports
 	}
-	return 0
+	return nil
 }
 
-type PacketDetectionInfo struct {
-	state           protoimpl.MessageState `protogen:"open.v1"`
-	SourceInterface uint32                 `protobuf:"varint,1,opt,name=source_interface,json=sourceInterface,proto3" json:"source_interface,omitempty"`
-	SdfFilter       string                 `protobuf:"bytes,2,opt,name=sdf_filter,json=sdfFilter,proto3" json:"sdf_filter,omitempty"`
-	UeIpAddress     string                 `protobuf:"bytes,3,opt,name=ue_ip_address,json=ueIpAddress,proto3" json:"ue_ip_address,omitempty"`
-	NetworkInstance string                 `protobuf:"bytes,4,opt,name=network_instance,json=networkInstance,proto3" json:"network_instance,omitempty"`
-	ApplicationId   string                 `protobuf:"bytes,5,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
-	unknownFields   protoimpl.UnknownFields
-	sizeCache       protoimpl.SizeCache
+type AuditReport struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	NodeId              string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	MissingSeids        []uint64               `protobuf:"varint,2,rep,packed,name=missing_seids,json=missingSeids,proto3" json:"missing_seids,omitempty"`
+	OrphanedRemoteSeids []uint64               `protobuf:"varint,3,rep,packed,name=orphaned_remote_seids,json=orphanedRemoteSeids,proto3" json:"orphaned_remote_seids,omitempty"`
+	MismatchedSeids     []uint64               `protobuf:"varint,4,rep,packed,name=mismatched_seids,json=mismatchedSeids,proto3" json:"mismatched_seids,omitempty"`
+	ReestablishedSeids  []uint64               `protobuf:"varint,5,rep,packed,name=reestablished_seids,json=reestablishedSeids,proto3" json:"reestablished_seids,omitempty"`
+	DeletedRemoteSeids  []uint64               `protobuf:"varint,6,rep,packed,name=deleted_remote_seids,json=deletedRemoteSeids,proto3" json:"deleted_remote_seids,omitempty"`
+	Errors              []string               `protobuf:"bytes,7,rep,name=errors,proto3" json:"errors,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
-func (x *PacketDetectionInfo) Reset() {
-	*x = PacketDetectionInfo{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[10]
+func (x *AuditReport) Reset() {
+	*x = AuditReport{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PacketDetectionInfo) String() string {
+func (x *AuditReport) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PacketDetectionInfo) ProtoMessage() {}
+func (*AuditReport) ProtoMessage() {}
 
-func (x *PacketDetectionInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[10]
+func (x *AuditReport) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -593,70 +771,93 @@ func (x *PacketDetectionInfo) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PacketDetectionInfo.ProtoReflect.Descriptor instead.
-func (*PacketDetectionInfo) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{10}
+// Deprecated: Use AuditReport.ProtoReflect.Descriptor instead.
+func (*AuditReport) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{12}
 }
 
-func (x *PacketDetectionInfo) GetSourceInterface() uint32 {
+func (x *AuditReport) GetNodeId() string {
 	if x != nil {
-		return x.SourceInterface
+		return x.NodeId
 	}
-	return 0
+	return ""
 }
 
-func (x *PacketDetectionInfo) GetSdfFilter() string {
+func (x *AuditReport) GetMissingSeids() []uint64 {
 	if x != nil {
-		return x.SdfFilter
+		return x.MissingSeids
 	}
-	return ""
+	return nil
 }
 
-func (x *PacketDetectionInfo) GetUeIpAddress() string {
+func (x *AuditReport) GetOrphanedRemoteSeids() []uint64 {
 	if x != nil {
-		return x.UeIpAddress
+		return x.OrphanedRemoteSeids
 	}
-	return ""
+	return nil
 }
 
-func (x *PacketDetectionInfo) GetNetworkInstance() string {
+func (x *AuditReport) GetMismatchedSeids() []uint64 {
 	if x != nil {
-		return x.NetworkInstance
+		return x.MismatchedSeids
 	}
-	return ""
+	return nil
 }
 
-func (x *PacketDetectionInfo) GetApplicationId() string {
+func (x *AuditReport) GetReestablishedSeids() []uint64 {
 	if x != nil {
-		return x.ApplicationId
+		return x.ReestablishedSeids
 	}
-	return ""
+	return nil
 }
 
-type FAR struct {
-	state            protoimpl.MessageState `protogen:"open.v1"`
-	Id               uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	ApplyAction      uint32                 `protobuf:"varint,2,opt,name=apply_action,json=applyAction,proto3" json:"apply_action,omitempty"`
-	ForwardingParams *ForwardingParameters  `protobuf:"bytes,3,opt,name=forwarding_params,json=forwardingParams,proto3" json:"forwarding_params,omitempty"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+func (x *AuditReport) GetDeletedRemoteSeids() []uint64 {
+	if x != nil {
+		return x.DeletedRemoteSeids
+	}
+	return nil
 }
 
-func (x *FAR) Reset() {
-	*x = FAR{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[11]
+func (x *AuditReport) GetErrors() []string {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+type IPPool struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// IPv4 or IPv6 prefix, e.g. 100.64.0.0/16.
+	Prefix string `protobuf:"bytes,2,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	// Length of the prefixes an IPv6 pool hands out, 64 by default.
+	PrefixLength uint32 `protobuf:"varint,3,opt,name=prefix_length,json=prefixLength,proto3" json:"prefix_length,omitempty"`
+	// Only serve sessions on this UP.
+	NodeId string `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	// Only serve PDRs in this Network Instance.
+	NetworkInstance string `protobuf:"bytes,5,opt,name=network_instance,json=networkInstance,proto3" json:"network_instance,omitempty"`
+	// Number of addresses, and how many sessions hold; ignored on creation.
+	Size          uint64 `protobuf:"varint,6,opt,name=size,proto3" json:"size,omitempty"`
+	Used          uint64 `protobuf:"varint,7,opt,name=used,proto3" json:"used,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IPPool) Reset() {
+	*x = IPPool{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *FAR) String() string {
+func (x *IPPool) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*FAR) ProtoMessage() {}
+func (*IPPool) ProtoMessage() {}
 
-func (x *FAR) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[11]
+func (x *IPPool) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -667,55 +868,1388 @@ func (x *FAR) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use FAR.ProtoReflect.Descriptor instead.
-func (*FAR) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{11}
+// Deprecated: Use IPPool.ProtoReflect.Descriptor instead.
+func (*IPPool) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *IPPool) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *IPPool) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+func (x *IPPool) GetPrefixLength() uint32 {
+	if x != nil {
+		return x.PrefixLength
+	}
+	return 0
+}
+
+func (x *IPPool) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *IPPool) GetNetworkInstance() string {
+	if x != nil {
+		return x.NetworkInstance
+	}
+	return ""
+}
+
+func (x *IPPool) GetSize() uint64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *IPPool) GetUsed() uint64 {
+	if x != nil {
+		return x.Used
+	}
+	return 0
+}
+
+type CreateIPPoolRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pool          *IPPool                `protobuf:"bytes,1,opt,name=pool,proto3" json:"pool,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateIPPoolRequest) Reset() {
+	*x = CreateIPPoolRequest{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateIPPoolRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateIPPoolRequest) ProtoMessage() {}
+
+func (x *CreateIPPoolRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateIPPoolRequest.ProtoReflect.Descriptor instead.
+func (*CreateIPPoolRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *CreateIPPoolRequest) GetPool() *IPPool {
+	if x != nil {
+		return x.Pool
+	}
+	return nil
+}
+
+type CreateIPPoolResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pool          *IPPool                `protobuf:"bytes,1,opt,name=pool,proto3" json:"pool,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateIPPoolResponse) Reset() {
+	*x = CreateIPPoolResponse{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateIPPoolResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateIPPoolResponse) ProtoMessage() {}
+
+func (x *CreateIPPoolResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateIPPoolResponse.ProtoReflect.Descriptor instead.
+func (*CreateIPPoolResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CreateIPPoolResponse) GetPool() *IPPool {
+	if x != nil {
+		return x.Pool
+	}
+	return nil
+}
+
+type DeleteIPPoolRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteIPPoolRequest) Reset() {
+	*x = DeleteIPPoolRequest{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteIPPoolRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteIPPoolRequest) ProtoMessage() {}
+
+func (x *DeleteIPPoolRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteIPPoolRequest.ProtoReflect.Descriptor instead.
+func (*DeleteIPPoolRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeleteIPPoolRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteIPPoolResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteIPPoolResponse) Reset() {
+	*x = DeleteIPPoolResponse{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteIPPoolResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteIPPoolResponse) ProtoMessage() {}
+
+func (x *DeleteIPPoolResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteIPPoolResponse.ProtoReflect.Descriptor instead.
+func (*DeleteIPPoolResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeleteIPPoolResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+type ListIPPoolsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIPPoolsRequest) Reset() {
+	*x = ListIPPoolsRequest{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIPPoolsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIPPoolsRequest) ProtoMessage() {}
+
+func (x *ListIPPoolsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIPPoolsRequest.ProtoReflect.Descriptor instead.
+func (*ListIPPoolsRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{18}
+}
+
+type ListIPPoolsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pools         []*IPPool              `protobuf:"bytes,1,rep,name=pools,proto3" json:"pools,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIPPoolsResponse) Reset() {
+	*x = ListIPPoolsResponse{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIPPoolsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIPPoolsResponse) ProtoMessage() {}
+
+func (x *ListIPPoolsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIPPoolsResponse.ProtoReflect.Descriptor instead.
+func (*ListIPPoolsResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ListIPPoolsResponse) GetPools() []*IPPool {
+	if x != nil {
+		return x.Pools
+	}
+	return nil
+}
+
+type PDR struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Precedence         uint32                 `protobuf:"varint,2,opt,name=precedence,proto3" json:"precedence,omitempty"`
+	Pdi                *PacketDetectionInfo   `protobuf:"bytes,3,opt,name=pdi,proto3" json:"pdi,omitempty"`
+	FarId              uint32                 `protobuf:"varint,4,opt,name=far_id,json=farId,proto3" json:"far_id,omitempty"`
+	QerIds             []uint32               `protobuf:"varint,5,rep,packed,name=qer_ids,json=qerIds,proto3" json:"qer_ids,omitempty"`
+	UrrIds             []uint32               `protobuf:"varint,6,rep,packed,name=urr_ids,json=urrIds,proto3" json:"urr_ids,omitempty"`
+	OuterHeaderRemoval *OuterHeaderRemoval    `protobuf:"bytes,7,opt,name=outer_header_removal,json=outerHeaderRemoval,proto3" json:"outer_header_removal,omitempty"`
+	// BBF (TR-459) Outer Header Removal, e.g. to decapsulate a PPPoE session.
+	BbfOuterHeaderRemoval *BBFOuterHeaderRemoval `protobuf:"bytes,8,opt,name=bbf_outer_header_removal,json=bbfOuterHeaderRemoval,proto3" json:"bbf_outer_header_removal,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *PDR) Reset() {
+	*x = PDR{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PDR) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PDR) ProtoMessage() {}
+
+func (x *PDR) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PDR.ProtoReflect.Descriptor instead.
+func (*PDR) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *PDR) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *PDR) GetPrecedence() uint32 {
+	if x != nil {
+		return x.Precedence
+	}
+	return 0
+}
+
+func (x *PDR) GetPdi() *PacketDetectionInfo {
+	if x != nil {
+		return x.Pdi
+	}
+	return nil
+}
+
+func (x *PDR) GetFarId() uint32 {
+	if x != nil {
+		return x.FarId
+	}
+	return 0
+}
+
+func (x *PDR) GetQerIds() []uint32 {
+	if x != nil {
+		return x.QerIds
+	}
+	return nil
+}
+
+func (x *PDR) GetUrrIds() []uint32 {
+	if x != nil {
+		return x.UrrIds
+	}
+	return nil
+}
+
+func (x *PDR) GetOuterHeaderRemoval() *OuterHeaderRemoval {
+	if x != nil {
+		return x.OuterHeaderRemoval
+	}
+	return nil
+}
+
+func (x *PDR) GetBbfOuterHeaderRemoval() *BBFOuterHeaderRemoval {
+	if x != nil {
+		return x.BbfOuterHeaderRemoval
+	}
+	return nil
+}
+
+type OuterHeaderRemoval struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 0 = GTP-U/UDP/IPv4, 1 = GTP-U/UDP/IPv6, 6 = GTP-U/UDP/IP.
+	Description   uint32 `protobuf:"varint,1,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OuterHeaderRemoval) Reset() {
+	*x = OuterHeaderRemoval{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OuterHeaderRemoval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OuterHeaderRemoval) ProtoMessage() {}
+
+func (x *OuterHeaderRemoval) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OuterHeaderRemoval.ProtoReflect.Descriptor instead.
+func (*OuterHeaderRemoval) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *OuterHeaderRemoval) GetDescription() uint32 {
+	if x != nil {
+		return x.Description
+	}
+	return 0
+}
+
+type BBFOuterHeaderRemoval struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// 1 = Ethernet, 2 = PPPoE/Ethernet, 3 = PPP/PPPoE/Ethernet, 4 = L2TP,
+	// 5 = PPP/L2TP.
+	Description   uint32 `protobuf:"varint,1,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BBFOuterHeaderRemoval) Reset() {
+	*x = BBFOuterHeaderRemoval{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BBFOuterHeaderRemoval) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BBFOuterHeaderRemoval) ProtoMessage() {}
+
+func (x *BBFOuterHeaderRemoval) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BBFOuterHeaderRemoval.ProtoReflect.Descriptor instead.
+func (*BBFOuterHeaderRemoval) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *BBFOuterHeaderRemoval) GetDescription() uint32 {
+	if x != nil {
+		return x.Description
+	}
+	return 0
+}
+
+type PacketDetectionInfo struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SourceInterface uint32                 `protobuf:"varint,1,opt,name=source_interface,json=sourceInterface,proto3" json:"source_interface,omitempty"`
+	SdfFilter       string                 `protobuf:"bytes,2,opt,name=sdf_filter,json=sdfFilter,proto3" json:"sdf_filter,omitempty"`
+	// An address, or an IPv6 prefix as address/length.
+	UeIpAddress     string `protobuf:"bytes,3,opt,name=ue_ip_address,json=ueIpAddress,proto3" json:"ue_ip_address,omitempty"`
+	NetworkInstance string `protobuf:"bytes,4,opt,name=network_instance,json=networkInstance,proto3" json:"network_instance,omitempty"`
+	ApplicationId   string `protobuf:"bytes,5,opt,name=application_id,json=applicationId,proto3" json:"application_id,omitempty"`
+	LocalFteid      *FTEID `protobuf:"bytes,6,opt,name=local_fteid,json=localFteid,proto3" json:"local_fteid,omitempty"`
+	// A frame matching any of the filters matches.
+	EthernetPacketFilters []*EthernetPacketFilter `protobuf:"bytes,7,rep,name=ethernet_packet_filters,json=ethernetPacketFilters,proto3" json:"ethernet_packet_filters,omitempty"`
+	// Match a PPPoE session, with the BBF (TR-459) PPPoE Session ID and PPP
+	// Protocol IEs.
+	Pppoe *PPPoEMatch `protobuf:"bytes,8,opt,name=pppoe,proto3" json:"pppoe,omitempty"`
+	// Let the UP allocate the UE IP address from its pools. ue_ip_address may
+	// be set to the unspecified address to ask for a family; IPv4 is the
+	// default.
+	ChooseUeIp bool `protobuf:"varint,9,opt,name=choose_ue_ip,json=chooseUeIp,proto3" json:"choose_ue_ip,omitempty"`
+	// Allocate the UE IP address from this CP IP pool instead; exclusive with
+	// ue_ip_address and choose_ue_ip.
+	UeIpPool string `protobuf:"bytes,10,opt,name=ue_ip_pool,json=ueIpPool,proto3" json:"ue_ip_pool,omitempty"`
+	// Subnets routed behind the UE, as RADIUS Framed-Route and
+	// Framed-IPv6-Route attributes: "prefix [gateway [metric...]]", e.g.
+	// "192.0.2.0/24 0.0.0.0 1". The UP matches them like the UE address and
+	// routes them into the session.
+	FramedRoutes     []string `protobuf:"bytes,11,rep,name=framed_routes,json=framedRoutes,proto3" json:"framed_routes,omitempty"`
+	FramedIpv6Routes []string `protobuf:"bytes,12,rep,name=framed_ipv6_routes,json=framedIpv6Routes,proto3" json:"framed_ipv6_routes,omitempty"`
+	// RADIUS Framed-Routing: 0 = None, 1 = Send, 2 = Listen, 3 = Send and
+	// Listen.
+	FramedRouting uint32 `protobuf:"varint,13,opt,name=framed_routing,json=framedRouting,proto3" json:"framed_routing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PacketDetectionInfo) Reset() {
+	*x = PacketDetectionInfo{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PacketDetectionInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PacketDetectionInfo) ProtoMessage() {}
+
+func (x *PacketDetectionInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PacketDetectionInfo.ProtoReflect.Descriptor instead.
+func (*PacketDetectionInfo) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *PacketDetectionInfo) GetSourceInterface() uint32 {
+	if x != nil {
+		return x.SourceInterface
+	}
+	return 0
+}
+
+func (x *PacketDetectionInfo) GetSdfFilter() string {
+	if x != nil {
+		return x.SdfFilter
+	}
+	return ""
+}
+
+func (x *PacketDetectionInfo) GetUeIpAddress() string {
+	if x != nil {
+		return x.UeIpAddress
+	}
+	return ""
+}
+
+func (x *PacketDetectionInfo) GetNetworkInstance() string {
+	if x != nil {
+		return x.NetworkInstance
+	}
+	return ""
+}
+
+func (x *PacketDetectionInfo) GetApplicationId() string {
+	if x != nil {
+		return x.ApplicationId
+	}
+	return ""
+}
+
+func (x *PacketDetectionInfo) GetLocalFteid() *FTEID {
+	if x != nil {
+		return x.LocalFteid
+	}
+	return nil
+}
+
+func (x *PacketDetectionInfo) GetEthernetPacketFilters() []*EthernetPacketFilter {
+	if x != nil {
+		return x.EthernetPacketFilters
+	}
+	return nil
+}
+
+func (x *PacketDetectionInfo) GetPppoe() *PPPoEMatch {
+	if x != nil {
+		return x.Pppoe
+	}
+	return nil
+}
+
+func (x *PacketDetectionInfo) GetChooseUeIp() bool {
+	if x != nil {
+		return x.ChooseUeIp
+	}
+	return false
+}
+
+func (x *PacketDetectionInfo) GetUeIpPool() string {
+	if x != nil {
+		return x.UeIpPool
+	}
+	return ""
+}
+
+func (x *PacketDetectionInfo) GetFramedRoutes() []string {
+	if x != nil {
+		return x.FramedRoutes
+	}
+	return nil
+}
+
+func (x *PacketDetectionInfo) GetFramedIpv6Routes() []string {
+	if x != nil {
+		return x.FramedIpv6Routes
+	}
+	return nil
+}
+
+func (x *PacketDetectionInfo) GetFramedRouting() uint32 {
+	if x != nil {
+		return x.FramedRouting
+	}
+	return 0
+}
+
+type PPPoEMatch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionId     *uint32                `protobuf:"varint,1,opt,name=session_id,json=sessionId,proto3,oneof" json:"session_id,omitempty"`
+	PppProtocol   *PPPProtocol           `protobuf:"bytes,2,opt,name=ppp_protocol,json=pppProtocol,proto3" json:"ppp_protocol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PPPoEMatch) Reset() {
+	*x = PPPoEMatch{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PPPoEMatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PPPoEMatch) ProtoMessage() {}
+
+func (x *PPPoEMatch) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PPPoEMatch.ProtoReflect.Descriptor instead.
+func (*PPPoEMatch) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *PPPoEMatch) GetSessionId() uint32 {
+	if x != nil && x.SessionId != nil {
+		return *x.SessionId
+	}
+	return 0
+}
+
+func (x *PPPoEMatch) GetPppProtocol() *PPPProtocol {
+	if x != nil {
+		return x.PppProtocol
+	}
+	return nil
+}
+
+// PPP control (LCP, NCPs, authentication) or data frames, or one protocol.
+type PPPProtocol struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Control       bool                   `protobuf:"varint,1,opt,name=control,proto3" json:"control,omitempty"`
+	Data          bool                   `protobuf:"varint,2,opt,name=data,proto3" json:"data,omitempty"`
+	Protocol      *uint32                `protobuf:"varint,3,opt,name=protocol,proto3,oneof" json:"protocol,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PPPProtocol) Reset() {
+	*x = PPPProtocol{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PPPProtocol) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PPPProtocol) ProtoMessage() {}
+
+func (x *PPPProtocol) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PPPProtocol.ProtoReflect.Descriptor instead.
+func (*PPPProtocol) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *PPPProtocol) GetControl() bool {
+	if x != nil {
+		return x.Control
+	}
+	return false
+}
+
+func (x *PPPProtocol) GetData() bool {
+	if x != nil {
+		return x.Data
+	}
+	return false
+}
+
+func (x *PPPProtocol) GetProtocol() uint32 {
+	if x != nil && x.Protocol != nil {
+		return *x.Protocol
+	}
+	return 0
+}
+
+type EthernetPacketFilter struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	FilterId *uint32                `protobuf:"varint,1,opt,name=filter_id,json=filterId,proto3,oneof" json:"filter_id,omitempty"`
+	// Also match frames with the source and destination MAC swapped.
+	Bidirectional bool `protobuf:"varint,2,opt,name=bidirectional,proto3" json:"bidirectional,omitempty"`
+	// A frame matches if it matches any of the MAC addresses.
+	MacAddresses []*MACAddress `protobuf:"bytes,3,rep,name=mac_addresses,json=macAddresses,proto3" json:"mac_addresses,omitempty"`
+	// EtherType after the VLAN tags, 0 for any.
+	Ethertype     uint32   `protobuf:"varint,4,opt,name=ethertype,proto3" json:"ethertype,omitempty"`
+	CTag          *VLANTag `protobuf:"bytes,5,opt,name=c_tag,json=cTag,proto3" json:"c_tag,omitempty"`
+	STag          *VLANTag `protobuf:"bytes,6,opt,name=s_tag,json=sTag,proto3" json:"s_tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EthernetPacketFilter) Reset() {
+	*x = EthernetPacketFilter{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EthernetPacketFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EthernetPacketFilter) ProtoMessage() {}
+
+func (x *EthernetPacketFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EthernetPacketFilter.ProtoReflect.Descriptor instead.
+func (*EthernetPacketFilter) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *EthernetPacketFilter) GetFilterId() uint32 {
+	if x != nil && x.FilterId != nil {
+		return *x.FilterId
+	}
+	return 0
+}
+
+func (x *EthernetPacketFilter) GetBidirectional() bool {
+	if x != nil {
+		return x.Bidirectional
+	}
+	return false
+}
+
+func (x *EthernetPacketFilter) GetMacAddresses() []*MACAddress {
+	if x != nil {
+		return x.MacAddresses
+	}
+	return nil
+}
+
+func (x *EthernetPacketFilter) GetEthertype() uint32 {
+	if x != nil {
+		return x.Ethertype
+	}
+	return 0
+}
+
+func (x *EthernetPacketFilter) GetCTag() *VLANTag {
+	if x != nil {
+		return x.CTag
+	}
+	return nil
+}
+
+func (x *EthernetPacketFilter) GetSTag() *VLANTag {
+	if x != nil {
+		return x.STag
+	}
+	return nil
+}
+
+// MAC addresses as aa:bb:cc:dd:ee:ff; the upper ones make ranges.
+type MACAddress struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Source           string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	Destination      string                 `protobuf:"bytes,2,opt,name=destination,proto3" json:"destination,omitempty"`
+	UpperSource      string                 `protobuf:"bytes,3,opt,name=upper_source,json=upperSource,proto3" json:"upper_source,omitempty"`
+	UpperDestination string                 `protobuf:"bytes,4,opt,name=upper_destination,json=upperDestination,proto3" json:"upper_destination,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *MACAddress) Reset() {
+	*x = MACAddress{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MACAddress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MACAddress) ProtoMessage() {}
+
+func (x *MACAddress) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MACAddress.ProtoReflect.Descriptor instead.
+func (*MACAddress) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *MACAddress) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *MACAddress) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *MACAddress) GetUpperSource() string {
+	if x != nil {
+		return x.UpperSource
+	}
+	return ""
+}
+
+func (x *MACAddress) GetUpperDestination() string {
+	if x != nil {
+		return x.UpperDestination
+	}
+	return ""
+}
+
+// Only the fields that are set are matched.
+type VLANTag struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Vid           *uint32                `protobuf:"varint,1,opt,name=vid,proto3,oneof" json:"vid,omitempty"`
+	Pcp           *uint32                `protobuf:"varint,2,opt,name=pcp,proto3,oneof" json:"pcp,omitempty"`
+	Dei           *bool                  `protobuf:"varint,3,opt,name=dei,proto3,oneof" json:"dei,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *VLANTag) Reset() {
+	*x = VLANTag{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *VLANTag) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*VLANTag) ProtoMessage() {}
+
+func (x *VLANTag) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use VLANTag.ProtoReflect.Descriptor instead.
+func (*VLANTag) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *VLANTag) GetVid() uint32 {
+	if x != nil && x.Vid != nil {
+		return *x.Vid
+	}
+	return 0
+}
+
+func (x *VLANTag) GetPcp() uint32 {
+	if x != nil && x.Pcp != nil {
+		return *x.Pcp
+	}
+	return 0
+}
+
+func (x *VLANTag) GetDei() bool {
+	if x != nil && x.Dei != nil {
+		return *x.Dei
+	}
+	return false
+}
+
+type FTEID struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Teid  uint32                 `protobuf:"varint,1,opt,name=teid,proto3" json:"teid,omitempty"`
+	Ipv4  string                 `protobuf:"bytes,2,opt,name=ipv4,proto3" json:"ipv4,omitempty"`
+	Ipv6  string                 `protobuf:"bytes,3,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	// Let the UP allocate the TEID and address. ipv4 or ipv6 may be set to
+	// the unspecified address to ask for a family; IPv4 is the default.
+	Choose bool `protobuf:"varint,4,opt,name=choose,proto3" json:"choose,omitempty"`
+	// PDRs of a session with the same non-zero choose_id share one F-TEID.
+	ChooseId      uint32 `protobuf:"varint,5,opt,name=choose_id,json=chooseId,proto3" json:"choose_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FTEID) Reset() {
+	*x = FTEID{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FTEID) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FTEID) ProtoMessage() {}
+
+func (x *FTEID) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FTEID.ProtoReflect.Descriptor instead.
+func (*FTEID) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *FTEID) GetTeid() uint32 {
+	if x != nil {
+		return x.Teid
+	}
+	return 0
+}
+
+func (x *FTEID) GetIpv4() string {
+	if x != nil {
+		return x.Ipv4
+	}
+	return ""
+}
+
+func (x *FTEID) GetIpv6() string {
+	if x != nil {
+		return x.Ipv6
+	}
+	return ""
+}
+
+func (x *FTEID) GetChoose() bool {
+	if x != nil {
+		return x.Choose
+	}
+	return false
+}
+
+func (x *FTEID) GetChooseId() uint32 {
+	if x != nil {
+		return x.ChooseId
+	}
+	return 0
+}
+
+type FAR struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Id               uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ApplyAction      uint32                 `protobuf:"varint,2,opt,name=apply_action,json=applyAction,proto3" json:"apply_action,omitempty"`
+	ForwardingParams *ForwardingParameters  `protobuf:"bytes,3,opt,name=forwarding_params,json=forwardingParams,proto3" json:"forwarding_params,omitempty"`
+	// BAR the FAR buffers with when apply_action has BUFF (0x04).
+	BarId *uint32 `protobuf:"varint,4,opt,name=bar_id,json=barId,proto3,oneof" json:"bar_id,omitempty"`
+	// Where copies of the FAR's packets go when apply_action has DUPL (0x10).
+	DuplicatingParams []*DuplicatingParameters `protobuf:"bytes,5,rep,name=duplicating_params,json=duplicatingParams,proto3" json:"duplicating_params,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *FAR) Reset() {
+	*x = FAR{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FAR) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FAR) ProtoMessage() {}
+
+func (x *FAR) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FAR.ProtoReflect.Descriptor instead.
+func (*FAR) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{30}
 }
 
 func (x *FAR) GetId() uint32 {
 	if x != nil {
-		return x.Id
+		return x.Id
+	}
+	return 0
+}
+
+func (x *FAR) GetApplyAction() uint32 {
+	if x != nil {
+		return x.ApplyAction
+	}
+	return 0
+}
+
+func (x *FAR) GetForwardingParams() *ForwardingParameters {
+	if x != nil {
+		return x.ForwardingParams
+	}
+	return nil
+}
+
+func (x *FAR) GetBarId() uint32 {
+	if x != nil && x.BarId != nil {
+		return *x.BarId
+	}
+	return 0
+}
+
+func (x *FAR) GetDuplicatingParams() []*DuplicatingParameters {
+	if x != nil {
+		return x.DuplicatingParams
+	}
+	return nil
+}
+
+type ForwardingParameters struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	DestinationInterface uint32                 `protobuf:"varint,1,opt,name=destination_interface,json=destinationInterface,proto3" json:"destination_interface,omitempty"`
+	NetworkInstance      string                 `protobuf:"bytes,2,opt,name=network_instance,json=networkInstance,proto3" json:"network_instance,omitempty"`
+	OuterHeaderCreation  *OuterHeaderCreation   `protobuf:"bytes,3,opt,name=outer_header_creation,json=outerHeaderCreation,proto3" json:"outer_header_creation,omitempty"`
+	// Encapsulate into a PPPoE session, sent as a BBF (TR-459) Outer Header
+	// Creation; exclusive with outer_header_creation.
+	Pppoe *PPPoESession `protobuf:"bytes,4,opt,name=pppoe,proto3" json:"pppoe,omitempty"`
+	// Forward PPP frames into an L2TP session towards an LNS, as a LAC, sent
+	// as a BBF (TR-459) Outer Header Creation; exclusive with the others.
+	L2Tp          *L2TPSession `protobuf:"bytes,5,opt,name=l2tp,proto3" json:"l2tp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForwardingParameters) Reset() {
+	*x = ForwardingParameters{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForwardingParameters) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForwardingParameters) ProtoMessage() {}
+
+func (x *ForwardingParameters) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForwardingParameters.ProtoReflect.Descriptor instead.
+func (*ForwardingParameters) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *ForwardingParameters) GetDestinationInterface() uint32 {
+	if x != nil {
+		return x.DestinationInterface
 	}
 	return 0
 }
 
-func (x *FAR) GetApplyAction() uint32 {
+func (x *ForwardingParameters) GetNetworkInstance() string {
 	if x != nil {
-		return x.ApplyAction
+		return x.NetworkInstance
+	}
+	return ""
+}
+
+func (x *ForwardingParameters) GetOuterHeaderCreation() *OuterHeaderCreation {
+	if x != nil {
+		return x.OuterHeaderCreation
+	}
+	return nil
+}
+
+func (x *ForwardingParameters) GetPppoe() *PPPoESession {
+	if x != nil {
+		return x.Pppoe
+	}
+	return nil
+}
+
+func (x *ForwardingParameters) GetL2Tp() *L2TPSession {
+	if x != nil {
+		return x.L2Tp
+	}
+	return nil
+}
+
+type PPPoESession struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	SessionId uint32                 `protobuf:"varint,1,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// The subscriber's MAC address, as aa:bb:cc:dd:ee:ff.
+	PeerMac string `protobuf:"bytes,2,opt,name=peer_mac,json=peerMac,proto3" json:"peer_mac,omitempty"`
+	// Subscriber VLAN tags to push; each needs a vid.
+	CTag          *VLANTag `protobuf:"bytes,3,opt,name=c_tag,json=cTag,proto3" json:"c_tag,omitempty"`
+	STag          *VLANTag `protobuf:"bytes,4,opt,name=s_tag,json=sTag,proto3" json:"s_tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PPPoESession) Reset() {
+	*x = PPPoESession{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PPPoESession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PPPoESession) ProtoMessage() {}
+
+func (x *PPPoESession) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PPPoESession.ProtoReflect.Descriptor instead.
+func (*PPPoESession) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *PPPoESession) GetSessionId() uint32 {
+	if x != nil {
+		return x.SessionId
 	}
 	return 0
 }
 
-func (x *FAR) GetForwardingParams() *ForwardingParameters {
+func (x *PPPoESession) GetPeerMac() string {
 	if x != nil {
-		return x.ForwardingParams
+		return x.PeerMac
+	}
+	return ""
+}
+
+func (x *PPPoESession) GetCTag() *VLANTag {
+	if x != nil {
+		return x.CTag
 	}
 	return nil
 }
 
-type ForwardingParameters struct {
+func (x *PPPoESession) GetSTag() *VLANTag {
+	if x != nil {
+		return x.STag
+	}
+	return nil
+}
+
+// The L2TP session, with the tunnel and session ID the LNS assigned.
+type L2TPSession struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	TunnelId  uint32                 `protobuf:"varint,1,opt,name=tunnel_id,json=tunnelId,proto3" json:"tunnel_id,omitempty"`
+	SessionId uint32                 `protobuf:"varint,2,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// IPv4 or IPv6 address of the LNS.
+	LnsAddress    string `protobuf:"bytes,3,opt,name=lns_address,json=lnsAddress,proto3" json:"lns_address,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *L2TPSession) Reset() {
+	*x = L2TPSession{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *L2TPSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*L2TPSession) ProtoMessage() {}
+
+func (x *L2TPSession) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use L2TPSession.ProtoReflect.Descriptor instead.
+func (*L2TPSession) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{33}
+}
+
+func (x *L2TPSession) GetTunnelId() uint32 {
+	if x != nil {
+		return x.TunnelId
+	}
+	return 0
+}
+
+func (x *L2TPSession) GetSessionId() uint32 {
+	if x != nil {
+		return x.SessionId
+	}
+	return 0
+}
+
+func (x *L2TPSession) GetLnsAddress() string {
+	if x != nil {
+		return x.LnsAddress
+	}
+	return ""
+}
+
+type DuplicatingParameters struct {
 	state                protoimpl.MessageState `protogen:"open.v1"`
 	DestinationInterface uint32                 `protobuf:"varint,1,opt,name=destination_interface,json=destinationInterface,proto3" json:"destination_interface,omitempty"`
-	NetworkInstance      string                 `protobuf:"bytes,2,opt,name=network_instance,json=networkInstance,proto3" json:"network_instance,omitempty"`
-	unknownFields        protoimpl.UnknownFields
-	sizeCache            protoimpl.SizeCache
+	// Encapsulation towards the collector; unset sends the copies as they are.
+	OuterHeaderCreation *OuterHeaderCreation `protobuf:"bytes,2,opt,name=outer_header_creation,json=outerHeaderCreation,proto3" json:"outer_header_creation,omitempty"`
+	// Policy pre-configured on the UP, e.g. a VPP interface to mirror to.
+	ForwardingPolicy string `protobuf:"bytes,3,opt,name=forwarding_policy,json=forwardingPolicy,proto3" json:"forwarding_policy,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
-func (x *ForwardingParameters) Reset() {
-	*x = ForwardingParameters{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[12]
+func (x *DuplicatingParameters) Reset() {
+	*x = DuplicatingParameters{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ForwardingParameters) String() string {
+func (x *DuplicatingParameters) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ForwardingParameters) ProtoMessage() {}
+func (*DuplicatingParameters) ProtoMessage() {}
 
-func (x *ForwardingParameters) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[12]
+func (x *DuplicatingParameters) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -726,38 +2260,128 @@ func (x *ForwardingParameters) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ForwardingParameters.ProtoReflect.Descriptor instead.
-func (*ForwardingParameters) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{12}
+// Deprecated: Use DuplicatingParameters.ProtoReflect.Descriptor instead.
+func (*DuplicatingParameters) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{34}
 }
 
-func (x *ForwardingParameters) GetDestinationInterface() uint32 {
+func (x *DuplicatingParameters) GetDestinationInterface() uint32 {
 	if x != nil {
 		return x.DestinationInterface
 	}
 	return 0
 }
 
-func (x *ForwardingParameters) GetNetworkInstance() string {
+func (x *DuplicatingParameters) GetOuterHeaderCreation() *OuterHeaderCreation {
 	if x != nil {
-		return x.NetworkInstance
+		return x.OuterHeaderCreation
+	}
+	return nil
+}
+
+func (x *DuplicatingParameters) GetForwardingPolicy() string {
+	if x != nil {
+		return x.ForwardingPolicy
+	}
+	return ""
+}
+
+type OuterHeaderCreation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Outer Header Creation description, octet 5 in the low byte:
+	// 1 = GTP-U/UDP/IPv4, 2 = GTP-U/UDP/IPv6.
+	Description   uint32 `protobuf:"varint,1,opt,name=description,proto3" json:"description,omitempty"`
+	Teid          uint32 `protobuf:"varint,2,opt,name=teid,proto3" json:"teid,omitempty"`
+	Ipv4          string `protobuf:"bytes,3,opt,name=ipv4,proto3" json:"ipv4,omitempty"`
+	Ipv6          string `protobuf:"bytes,4,opt,name=ipv6,proto3" json:"ipv6,omitempty"`
+	Port          uint32 `protobuf:"varint,5,opt,name=port,proto3" json:"port,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OuterHeaderCreation) Reset() {
+	*x = OuterHeaderCreation{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OuterHeaderCreation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OuterHeaderCreation) ProtoMessage() {}
+
+func (x *OuterHeaderCreation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OuterHeaderCreation.ProtoReflect.Descriptor instead.
+func (*OuterHeaderCreation) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *OuterHeaderCreation) GetDescription() uint32 {
+	if x != nil {
+		return x.Description
+	}
+	return 0
+}
+
+func (x *OuterHeaderCreation) GetTeid() uint32 {
+	if x != nil {
+		return x.Teid
+	}
+	return 0
+}
+
+func (x *OuterHeaderCreation) GetIpv4() string {
+	if x != nil {
+		return x.Ipv4
+	}
+	return ""
+}
+
+func (x *OuterHeaderCreation) GetIpv6() string {
+	if x != nil {
+		return x.Ipv6
 	}
 	return ""
 }
 
+func (x *OuterHeaderCreation) GetPort() uint32 {
+	if x != nil {
+		return x.Port
+	}
+	return 0
+}
+
 type QER struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	GateStatus    uint32                 `protobuf:"varint,2,opt,name=gate_status,json=gateStatus,proto3" json:"gate_status,omitempty"`
-	MbrUplink     uint64                 `protobuf:"varint,3,opt,name=mbr_uplink,json=mbrUplink,proto3" json:"mbr_uplink,omitempty"`
-	MbrDownlink   uint64                 `protobuf:"varint,4,opt,name=mbr_downlink,json=mbrDownlink,proto3" json:"mbr_downlink,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Gate Status IE octet: UL gate in bits 3-4, DL gate in bits 1-2
+	// (0 = open, 1 = closed).
+	GateStatus uint32 `protobuf:"varint,2,opt,name=gate_status,json=gateStatus,proto3" json:"gate_status,omitempty"`
+	// Bit rates in kbps.
+	MbrUplink     uint64 `protobuf:"varint,3,opt,name=mbr_uplink,json=mbrUplink,proto3" json:"mbr_uplink,omitempty"`
+	MbrDownlink   uint64 `protobuf:"varint,4,opt,name=mbr_downlink,json=mbrDownlink,proto3" json:"mbr_downlink,omitempty"`
+	GbrUplink     uint64 `protobuf:"varint,5,opt,name=gbr_uplink,json=gbrUplink,proto3" json:"gbr_uplink,omitempty"`
+	GbrDownlink   uint64 `protobuf:"varint,6,opt,name=gbr_downlink,json=gbrDownlink,proto3" json:"gbr_downlink,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *QER) Reset() {
 	*x = QER{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[13]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -769,7 +2393,7 @@ func (x *QER) String() string {
 func (*QER) ProtoMessage() {}
 
 func (x *QER) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[13]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -782,7 +2406,7 @@ func (x *QER) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use QER.ProtoReflect.Descriptor instead.
 func (*QER) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{13}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{36}
 }
 
 func (x *QER) GetId() uint32 {
@@ -813,17 +2437,37 @@ func (x *QER) GetMbrDownlink() uint64 {
 	return 0
 }
 
+func (x *QER) GetGbrUplink() uint64 {
+	if x != nil {
+		return x.GbrUplink
+	}
+	return 0
+}
+
+func (x *QER) GetGbrDownlink() uint64 {
+	if x != nil {
+		return x.GbrDownlink
+	}
+	return 0
+}
+
 type URR struct {
 	state             protoimpl.MessageState `protogen:"open.v1"`
 	Id                uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
 	MeasurementMethod uint32                 `protobuf:"varint,2,opt,name=measurement_method,json=measurementMethod,proto3" json:"measurement_method,omitempty"`
-	unknownFields     protoimpl.UnknownFields
-	sizeCache         protoimpl.SizeCache
+	// Reporting Triggers flags, octet 5 in the low byte.
+	ReportingTriggers uint32 `protobuf:"varint,3,opt,name=reporting_triggers,json=reportingTriggers,proto3" json:"reporting_triggers,omitempty"`
+	// Total volume threshold in bytes.
+	VolumeThreshold uint64 `protobuf:"varint,4,opt,name=volume_threshold,json=volumeThreshold,proto3" json:"volume_threshold,omitempty"`
+	// Time threshold in seconds.
+	TimeThreshold uint32 `protobuf:"varint,5,opt,name=time_threshold,json=timeThreshold,proto3" json:"time_threshold,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *URR) Reset() {
 	*x = URR{}
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[14]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -835,7 +2479,7 @@ func (x *URR) String() string {
 func (*URR) ProtoMessage() {}
 
 func (x *URR) ProtoReflect() protoreflect.Message {
-	mi := &file_api_pfcp_v1_control_proto_msgTypes[14]
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -848,7 +2492,7 @@ func (x *URR) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use URR.ProtoReflect.Descriptor instead.
 func (*URR) Descriptor() ([]byte, []int) {
-	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{14}
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *URR) GetId() uint32 {
@@ -865,27 +2509,130 @@ func (x *URR) GetMeasurementMethod() uint32 {
 	return 0
 }
 
+func (x *URR) GetReportingTriggers() uint32 {
+	if x != nil {
+		return x.ReportingTriggers
+	}
+	return 0
+}
+
+func (x *URR) GetVolumeThreshold() uint64 {
+	if x != nil {
+		return x.VolumeThreshold
+	}
+	return 0
+}
+
+func (x *URR) GetTimeThreshold() uint32 {
+	if x != nil {
+		return x.TimeThreshold
+	}
+	return 0
+}
+
+// Buffering Action Rule, applied by the FARs that buffer.
+type BAR struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Delay before the UP reports the first buffered packet to the CP, in
+	// milliseconds, rounded down to 50ms.
+	DownlinkDataNotificationDelayMs uint32 `protobuf:"varint,2,opt,name=downlink_data_notification_delay_ms,json=downlinkDataNotificationDelayMs,proto3" json:"downlink_data_notification_delay_ms,omitempty"`
+	// Packets to buffer per FAR; 0 leaves it to the UP.
+	SuggestedBufferingPacketsCount uint32 `protobuf:"varint,3,opt,name=suggested_buffering_packets_count,json=suggestedBufferingPacketsCount,proto3" json:"suggested_buffering_packets_count,omitempty"`
+	unknownFields                  protoimpl.UnknownFields
+	sizeCache                      protoimpl.SizeCache
+}
+
+func (x *BAR) Reset() {
+	*x = BAR{}
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BAR) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BAR) ProtoMessage() {}
+
+func (x *BAR) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_control_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BAR.ProtoReflect.Descriptor instead.
+func (*BAR) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_control_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *BAR) GetId() uint32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *BAR) GetDownlinkDataNotificationDelayMs() uint32 {
+	if x != nil {
+		return x.DownlinkDataNotificationDelayMs
+	}
+	return 0
+}
+
+func (x *BAR) GetSuggestedBufferingPacketsCount() uint32 {
+	if x != nil {
+		return x.SuggestedBufferingPacketsCount
+	}
+	return 0
+}
+
 var File_api_pfcp_v1_control_proto protoreflect.FileDescriptor
 
 const file_api_pfcp_v1_control_proto_rawDesc = "" +
 	"\n" +
-	"\x19api/pfcp/v1/control.proto\x12\apfcp.v1\"\xb7\x01\n" +
+	"\x19api/pfcp/v1/control.proto\x12\apfcp.v1\"\xfc\x01\n" +
 	"\x14CreateSessionRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12 \n" +
 	"\x04pdrs\x18\x02 \x03(\v2\f.pfcp.v1.PDRR\x04pdrs\x12 \n" +
 	"\x04fars\x18\x03 \x03(\v2\f.pfcp.v1.FARR\x04fars\x12 \n" +
 	"\x04qers\x18\x04 \x03(\v2\f.pfcp.v1.QERR\x04qers\x12 \n" +
-	"\x04urrs\x18\x05 \x03(\v2\f.pfcp.v1.URRR\x04urrs\"+\n" +
+	"\x04urrs\x18\x05 \x03(\v2\f.pfcp.v1.URRR\x04urrs\x12\x1e\n" +
+	"\x03bar\x18\x06 \x01(\v2\f.pfcp.v1.BARR\x03bar\x12#\n" +
+	"\rsubscriber_id\x18\a \x01(\tR\fsubscriberId\"c\n" +
 	"\x15CreateSessionResponse\x12\x12\n" +
-	"\x04seid\x18\x01 \x01(\x04R\x04seid\"\xb2\x01\n" +
+	"\x04seid\x18\x01 \x01(\x04R\x04seid\x126\n" +
+	"\fcreated_pdrs\x18\x02 \x03(\v2\x13.pfcp.v1.CreatedPDRR\vcreatedPdrs\"\x89\x03\n" +
 	"\x14ModifySessionRequest\x12\x12\n" +
 	"\x04seid\x18\x01 \x01(\x04R\x04seid\x12 \n" +
 	"\x04pdrs\x18\x02 \x03(\v2\f.pfcp.v1.PDRR\x04pdrs\x12 \n" +
 	"\x04fars\x18\x03 \x03(\v2\f.pfcp.v1.FARR\x04fars\x12 \n" +
 	"\x04qers\x18\x04 \x03(\v2\f.pfcp.v1.QERR\x04qers\x12 \n" +
-	"\x04urrs\x18\x05 \x03(\v2\f.pfcp.v1.URRR\x04urrs\"1\n" +
+	"\x04urrs\x18\x05 \x03(\v2\f.pfcp.v1.URRR\x04urrs\x12$\n" +
+	"\x0eremove_pdr_ids\x18\x06 \x03(\rR\fremovePdrIds\x12$\n" +
+	"\x0eremove_far_ids\x18\a \x03(\rR\fremoveFarIds\x12$\n" +
+	"\x0eremove_qer_ids\x18\b \x03(\rR\fremoveQerIds\x12$\n" +
+	"\x0eremove_urr_ids\x18\t \x03(\rR\fremoveUrrIds\x12\x1e\n" +
+	"\x03bar\x18\n" +
+	" \x01(\v2\f.pfcp.v1.BARR\x03bar\x12\x1d\n" +
+	"\n" +
+	"remove_bar\x18\v \x01(\bR\tremoveBar\"i\n" +
 	"\x15ModifySessionResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"*\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x126\n" +
+	"\fcreated_pdrs\x18\x02 \x03(\v2\x13.pfcp.v1.CreatedPDRR\vcreatedPdrs\"x\n" +
+	"\n" +
+	"CreatedPDR\x12\x15\n" +
+	"\x06pdr_id\x18\x01 \x01(\rR\x05pdrId\x12/\n" +
+	"\vlocal_fteid\x18\x02 \x01(\v2\x0e.pfcp.v1.FTEIDR\n" +
+	"localFteid\x12\"\n" +
+	"\rue_ip_address\x18\x03 \x01(\tR\vueIpAddress\"*\n" +
 	"\x14DeleteSessionRequest\x12\x12\n" +
 	"\x04seid\x18\x01 \x01(\x04R\x04seid\"1\n" +
 	"\x15DeleteSessionResponse\x12\x18\n" +
@@ -897,43 +2644,176 @@ const file_api_pfcp_v1_control_proto_rawDesc = "" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x1f\n" +
 	"\vremote_addr\x18\x02 \x01(\tR\n" +
 	"remoteAddr\x12%\n" +
-	"\x0eestablished_at\x18\x03 \x01(\x03R\restablishedAt\"|\n" +
+	"\x0eestablished_at\x18\x03 \x01(\x03R\restablishedAt\"G\n" +
+	"\x14AuditSessionsRequest\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x16\n" +
+	"\x06repair\x18\x02 \x01(\bR\x06repair\"G\n" +
+	"\x15AuditSessionsResponse\x12.\n" +
+	"\areports\x18\x01 \x03(\v2\x14.pfcp.v1.AuditReportR\areports\"\xa5\x02\n" +
+	"\vAuditReport\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12#\n" +
+	"\rmissing_seids\x18\x02 \x03(\x04R\fmissingSeids\x122\n" +
+	"\x15orphaned_remote_seids\x18\x03 \x03(\x04R\x13orphanedRemoteSeids\x12)\n" +
+	"\x10mismatched_seids\x18\x04 \x03(\x04R\x0fmismatchedSeids\x12/\n" +
+	"\x13reestablished_seids\x18\x05 \x03(\x04R\x12reestablishedSeids\x120\n" +
+	"\x14deleted_remote_seids\x18\x06 \x03(\x04R\x12deletedRemoteSeids\x12\x16\n" +
+	"\x06errors\x18\a \x03(\tR\x06errors\"\xc5\x01\n" +
+	"\x06IPPool\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12#\n" +
+	"\rprefix_length\x18\x03 \x01(\rR\fprefixLength\x12\x17\n" +
+	"\anode_id\x18\x04 \x01(\tR\x06nodeId\x12)\n" +
+	"\x10network_instance\x18\x05 \x01(\tR\x0fnetworkInstance\x12\x12\n" +
+	"\x04size\x18\x06 \x01(\x04R\x04size\x12\x12\n" +
+	"\x04used\x18\a \x01(\x04R\x04used\":\n" +
+	"\x13CreateIPPoolRequest\x12#\n" +
+	"\x04pool\x18\x01 \x01(\v2\x0f.pfcp.v1.IPPoolR\x04pool\";\n" +
+	"\x14CreateIPPoolResponse\x12#\n" +
+	"\x04pool\x18\x01 \x01(\v2\x0f.pfcp.v1.IPPoolR\x04pool\")\n" +
+	"\x13DeleteIPPoolRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"0\n" +
+	"\x14DeleteIPPoolResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x14\n" +
+	"\x12ListIPPoolsRequest\"<\n" +
+	"\x13ListIPPoolsResponse\x12%\n" +
+	"\x05pools\x18\x01 \x03(\v2\x0f.pfcp.v1.IPPoolR\x05pools\"\xd6\x02\n" +
 	"\x03PDR\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\rR\x02id\x12\x1e\n" +
 	"\n" +
 	"precedence\x18\x02 \x01(\rR\n" +
 	"precedence\x12.\n" +
 	"\x03pdi\x18\x03 \x01(\v2\x1c.pfcp.v1.PacketDetectionInfoR\x03pdi\x12\x15\n" +
-	"\x06far_id\x18\x04 \x01(\rR\x05farId\"\xd5\x01\n" +
+	"\x06far_id\x18\x04 \x01(\rR\x05farId\x12\x17\n" +
+	"\aqer_ids\x18\x05 \x03(\rR\x06qerIds\x12\x17\n" +
+	"\aurr_ids\x18\x06 \x03(\rR\x06urrIds\x12M\n" +
+	"\x14outer_header_removal\x18\a \x01(\v2\x1b.pfcp.v1.OuterHeaderRemovalR\x12outerHeaderRemoval\x12W\n" +
+	"\x18bbf_outer_header_removal\x18\b \x01(\v2\x1e.pfcp.v1.BBFOuterHeaderRemovalR\x15bbfOuterHeaderRemoval\"6\n" +
+	"\x12OuterHeaderRemoval\x12 \n" +
+	"\vdescription\x18\x01 \x01(\rR\vdescription\"9\n" +
+	"\x15BBFOuterHeaderRemoval\x12 \n" +
+	"\vdescription\x18\x01 \x01(\rR\vdescription\"\xc2\x04\n" +
 	"\x13PacketDetectionInfo\x12)\n" +
 	"\x10source_interface\x18\x01 \x01(\rR\x0fsourceInterface\x12\x1d\n" +
 	"\n" +
 	"sdf_filter\x18\x02 \x01(\tR\tsdfFilter\x12\"\n" +
 	"\rue_ip_address\x18\x03 \x01(\tR\vueIpAddress\x12)\n" +
 	"\x10network_instance\x18\x04 \x01(\tR\x0fnetworkInstance\x12%\n" +
-	"\x0eapplication_id\x18\x05 \x01(\tR\rapplicationId\"\x84\x01\n" +
+	"\x0eapplication_id\x18\x05 \x01(\tR\rapplicationId\x12/\n" +
+	"\vlocal_fteid\x18\x06 \x01(\v2\x0e.pfcp.v1.FTEIDR\n" +
+	"localFteid\x12U\n" +
+	"\x17ethernet_packet_filters\x18\a \x03(\v2\x1d.pfcp.v1.EthernetPacketFilterR\x15ethernetPacketFilters\x12)\n" +
+	"\x05pppoe\x18\b \x01(\v2\x13.pfcp.v1.PPPoEMatchR\x05pppoe\x12 \n" +
+	"\fchoose_ue_ip\x18\t \x01(\bR\n" +
+	"chooseUeIp\x12\x1c\n" +
+	"\n" +
+	"ue_ip_pool\x18\n" +
+	" \x01(\tR\bueIpPool\x12#\n" +
+	"\rframed_routes\x18\v \x03(\tR\fframedRoutes\x12,\n" +
+	"\x12framed_ipv6_routes\x18\f \x03(\tR\x10framedIpv6Routes\x12%\n" +
+	"\x0eframed_routing\x18\r \x01(\rR\rframedRouting\"x\n" +
+	"\n" +
+	"PPPoEMatch\x12\"\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\rH\x00R\tsessionId\x88\x01\x01\x127\n" +
+	"\fppp_protocol\x18\x02 \x01(\v2\x14.pfcp.v1.PPPProtocolR\vpppProtocolB\r\n" +
+	"\v_session_id\"i\n" +
+	"\vPPPProtocol\x12\x18\n" +
+	"\acontrol\x18\x01 \x01(\bR\acontrol\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\bR\x04data\x12\x1f\n" +
+	"\bprotocol\x18\x03 \x01(\rH\x00R\bprotocol\x88\x01\x01B\v\n" +
+	"\t_protocol\"\x92\x02\n" +
+	"\x14EthernetPacketFilter\x12 \n" +
+	"\tfilter_id\x18\x01 \x01(\rH\x00R\bfilterId\x88\x01\x01\x12$\n" +
+	"\rbidirectional\x18\x02 \x01(\bR\rbidirectional\x128\n" +
+	"\rmac_addresses\x18\x03 \x03(\v2\x13.pfcp.v1.MACAddressR\fmacAddresses\x12\x1c\n" +
+	"\tethertype\x18\x04 \x01(\rR\tethertype\x12%\n" +
+	"\x05c_tag\x18\x05 \x01(\v2\x10.pfcp.v1.VLANTagR\x04cTag\x12%\n" +
+	"\x05s_tag\x18\x06 \x01(\v2\x10.pfcp.v1.VLANTagR\x04sTagB\f\n" +
+	"\n" +
+	"_filter_id\"\x96\x01\n" +
+	"\n" +
+	"MACAddress\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12 \n" +
+	"\vdestination\x18\x02 \x01(\tR\vdestination\x12!\n" +
+	"\fupper_source\x18\x03 \x01(\tR\vupperSource\x12+\n" +
+	"\x11upper_destination\x18\x04 \x01(\tR\x10upperDestination\"f\n" +
+	"\aVLANTag\x12\x15\n" +
+	"\x03vid\x18\x01 \x01(\rH\x00R\x03vid\x88\x01\x01\x12\x15\n" +
+	"\x03pcp\x18\x02 \x01(\rH\x01R\x03pcp\x88\x01\x01\x12\x15\n" +
+	"\x03dei\x18\x03 \x01(\bH\x02R\x03dei\x88\x01\x01B\x06\n" +
+	"\x04_vidB\x06\n" +
+	"\x04_pcpB\x06\n" +
+	"\x04_dei\"x\n" +
+	"\x05FTEID\x12\x12\n" +
+	"\x04teid\x18\x01 \x01(\rR\x04teid\x12\x12\n" +
+	"\x04ipv4\x18\x02 \x01(\tR\x04ipv4\x12\x12\n" +
+	"\x04ipv6\x18\x03 \x01(\tR\x04ipv6\x12\x16\n" +
+	"\x06choose\x18\x04 \x01(\bR\x06choose\x12\x1b\n" +
+	"\tchoose_id\x18\x05 \x01(\rR\bchooseId\"\xfa\x01\n" +
 	"\x03FAR\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\rR\x02id\x12!\n" +
 	"\fapply_action\x18\x02 \x01(\rR\vapplyAction\x12J\n" +
-	"\x11forwarding_params\x18\x03 \x01(\v2\x1d.pfcp.v1.ForwardingParametersR\x10forwardingParams\"v\n" +
+	"\x11forwarding_params\x18\x03 \x01(\v2\x1d.pfcp.v1.ForwardingParametersR\x10forwardingParams\x12\x1a\n" +
+	"\x06bar_id\x18\x04 \x01(\rH\x00R\x05barId\x88\x01\x01\x12M\n" +
+	"\x12duplicating_params\x18\x05 \x03(\v2\x1e.pfcp.v1.DuplicatingParametersR\x11duplicatingParamsB\t\n" +
+	"\a_bar_id\"\x9f\x02\n" +
 	"\x14ForwardingParameters\x123\n" +
 	"\x15destination_interface\x18\x01 \x01(\rR\x14destinationInterface\x12)\n" +
-	"\x10network_instance\x18\x02 \x01(\tR\x0fnetworkInstance\"x\n" +
+	"\x10network_instance\x18\x02 \x01(\tR\x0fnetworkInstance\x12P\n" +
+	"\x15outer_header_creation\x18\x03 \x01(\v2\x1c.pfcp.v1.OuterHeaderCreationR\x13outerHeaderCreation\x12+\n" +
+	"\x05pppoe\x18\x04 \x01(\v2\x15.pfcp.v1.PPPoESessionR\x05pppoe\x12(\n" +
+	"\x04l2tp\x18\x05 \x01(\v2\x14.pfcp.v1.L2TPSessionR\x04l2tp\"\x96\x01\n" +
+	"\fPPPoESession\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x01 \x01(\rR\tsessionId\x12\x19\n" +
+	"\bpeer_mac\x18\x02 \x01(\tR\apeerMac\x12%\n" +
+	"\x05c_tag\x18\x03 \x01(\v2\x10.pfcp.v1.VLANTagR\x04cTag\x12%\n" +
+	"\x05s_tag\x18\x04 \x01(\v2\x10.pfcp.v1.VLANTagR\x04sTag\"j\n" +
+	"\vL2TPSession\x12\x1b\n" +
+	"\ttunnel_id\x18\x01 \x01(\rR\btunnelId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x02 \x01(\rR\tsessionId\x12\x1f\n" +
+	"\vlns_address\x18\x03 \x01(\tR\n" +
+	"lnsAddress\"\xcb\x01\n" +
+	"\x15DuplicatingParameters\x123\n" +
+	"\x15destination_interface\x18\x01 \x01(\rR\x14destinationInterface\x12P\n" +
+	"\x15outer_header_creation\x18\x02 \x01(\v2\x1c.pfcp.v1.OuterHeaderCreationR\x13outerHeaderCreation\x12+\n" +
+	"\x11forwarding_policy\x18\x03 \x01(\tR\x10forwardingPolicy\"\x87\x01\n" +
+	"\x13OuterHeaderCreation\x12 \n" +
+	"\vdescription\x18\x01 \x01(\rR\vdescription\x12\x12\n" +
+	"\x04teid\x18\x02 \x01(\rR\x04teid\x12\x12\n" +
+	"\x04ipv4\x18\x03 \x01(\tR\x04ipv4\x12\x12\n" +
+	"\x04ipv6\x18\x04 \x01(\tR\x04ipv6\x12\x12\n" +
+	"\x04port\x18\x05 \x01(\rR\x04port\"\xba\x01\n" +
 	"\x03QER\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\rR\x02id\x12\x1f\n" +
 	"\vgate_status\x18\x02 \x01(\rR\n" +
 	"gateStatus\x12\x1d\n" +
 	"\n" +
 	"mbr_uplink\x18\x03 \x01(\x04R\tmbrUplink\x12!\n" +
-	"\fmbr_downlink\x18\x04 \x01(\x04R\vmbrDownlink\"D\n" +
+	"\fmbr_downlink\x18\x04 \x01(\x04R\vmbrDownlink\x12\x1d\n" +
+	"\n" +
+	"gbr_uplink\x18\x05 \x01(\x04R\tgbrUplink\x12!\n" +
+	"\fgbr_downlink\x18\x06 \x01(\x04R\vgbrDownlink\"\xc5\x01\n" +
 	"\x03URR\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\rR\x02id\x12-\n" +
-	"\x12measurement_method\x18\x02 \x01(\rR\x11measurementMethod2\xd7\x02\n" +
+	"\x12measurement_method\x18\x02 \x01(\rR\x11measurementMethod\x12-\n" +
+	"\x12reporting_triggers\x18\x03 \x01(\rR\x11reportingTriggers\x12)\n" +
+	"\x10volume_threshold\x18\x04 \x01(\x04R\x0fvolumeThreshold\x12%\n" +
+	"\x0etime_threshold\x18\x05 \x01(\rR\rtimeThreshold\"\xae\x01\n" +
+	"\x03BAR\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12L\n" +
+	"#downlink_data_notification_delay_ms\x18\x02 \x01(\rR\x1fdownlinkDataNotificationDelayMs\x12I\n" +
+	"!suggested_buffering_packets_count\x18\x03 \x01(\rR\x1esuggestedBufferingPacketsCount2\x8b\x05\n" +
 	"\fControlPlane\x12N\n" +
 	"\rCreateSession\x12\x1d.pfcp.v1.CreateSessionRequest\x1a\x1e.pfcp.v1.CreateSessionResponse\x12N\n" +
 	"\rModifySession\x12\x1d.pfcp.v1.ModifySessionRequest\x1a\x1e.pfcp.v1.ModifySessionResponse\x12N\n" +
 	"\rDeleteSession\x12\x1d.pfcp.v1.DeleteSessionRequest\x1a\x1e.pfcp.v1.DeleteSessionResponse\x12W\n" +
-	"\x10ListAssociations\x12 .pfcp.v1.ListAssociationsRequest\x1a!.pfcp.v1.ListAssociationsResponseB7Z5github.com/veesix-networks/pfcp-go/api/pfcp/v1;pfcpv1b\x06proto3"
+	"\x10ListAssociations\x12 .pfcp.v1.ListAssociationsRequest\x1a!.pfcp.v1.ListAssociationsResponse\x12N\n" +
+	"\rAuditSessions\x12\x1d.pfcp.v1.AuditSessionsRequest\x1a\x1e.pfcp.v1.AuditSessionsResponse\x12K\n" +
+	"\fCreateIPPool\x12\x1c.pfcp.v1.CreateIPPoolRequest\x1a\x1d.pfcp.v1.CreateIPPoolResponse\x12K\n" +
+	"\fDeleteIPPool\x12\x1c.pfcp.v1.DeleteIPPoolRequest\x1a\x1d.pfcp.v1.DeleteIPPoolResponse\x12H\n" +
+	"\vListIPPools\x12\x1b.pfcp.v1.ListIPPoolsRequest\x1a\x1c.pfcp.v1.ListIPPoolsResponseB7Z5github.com/veesix-networks/pfcp-go/api/pfcp/v1;pfcpv1b\x06proto3"
 
 var (
 	file_api_pfcp_v1_control_proto_rawDescOnce sync.Once
@@ -947,49 +2827,106 @@ func file_api_pfcp_v1_control_proto_rawDescGZIP() []byte {
 	return file_api_pfcp_v1_control_proto_rawDescData
 }
 
-var file_api_pfcp_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_api_pfcp_v1_control_proto_msgTypes = make([]protoimpl.MessageInfo, 39)
 var file_api_pfcp_v1_control_proto_goTypes = []any{
 	(*CreateSessionRequest)(nil),     // 0: pfcp.v1.CreateSessionRequest
 	(*CreateSessionResponse)(nil),    // 1: pfcp.v1.CreateSessionResponse
 	(*ModifySessionRequest)(nil),     // 2: pfcp.v1.ModifySessionRequest
 	(*ModifySessionResponse)(nil),    // 3: pfcp.v1.ModifySessionResponse
-	(*DeleteSessionRequest)(nil),     // 4: pfcp.v1.DeleteSessionRequest
-	(*DeleteSessionResponse)(nil),    // 5: pfcp.v1.DeleteSessionResponse
-	(*ListAssociationsRequest)(nil),  // 6: pfcp.v1.ListAssociationsRequest
-	(*ListAssociationsResponse)(nil), // 7: pfcp.v1.ListAssociationsResponse
-	(*Association)(nil),              // 8: pfcp.v1.Association
-	(*PDR)(nil),                      // 9: pfcp.v1.PDR
-	(*PacketDetectionInfo)(nil),      // 10: pfcp.v1.PacketDetectionInfo
-	(*FAR)(nil),                      // 11: pfcp.v1.FAR
-	(*ForwardingParameters)(nil),     // 12: pfcp.v1.ForwardingParameters
-	(*QER)(nil),                      // 13: pfcp.v1.QER
-	(*URR)(nil),                      // 14: pfcp.v1.URR
+	(*CreatedPDR)(nil),               // 4: pfcp.v1.CreatedPDR
+	(*DeleteSessionRequest)(nil),     // 5: pfcp.v1.DeleteSessionRequest
+	(*DeleteSessionResponse)(nil),    // 6: pfcp.v1.DeleteSessionResponse
+	(*ListAssociationsRequest)(nil),  // 7: pfcp.v1.ListAssociationsRequest
+	(*ListAssociationsResponse)(nil), // 8: pfcp.v1.ListAssociationsResponse
+	(*Association)(nil),              // 9: pfcp.v1.Association
+	(*AuditSessionsRequest)(nil),     // 10: pfcp.v1.AuditSessionsRequest
+	(*AuditSessionsResponse)(nil),    // 11: pfcp.v1.AuditSessionsResponse
+	(*AuditReport)(nil),              // 12: pfcp.v1.AuditReport
+	(*IPPool)(nil),                   // 13: pfcp.v1.IPPool
+	(*CreateIPPoolRequest)(nil),      // 14: pfcp.v1.CreateIPPoolRequest
+	(*CreateIPPoolResponse)(nil),     // 15: pfcp.v1.CreateIPPoolResponse
+	(*DeleteIPPoolRequest)(nil),      // 16: pfcp.v1.DeleteIPPoolRequest
+	(*DeleteIPPoolResponse)(nil),     // 17: pfcp.v1.DeleteIPPoolResponse
+	(*ListIPPoolsRequest)(nil),       // 18: pfcp.v1.ListIPPoolsRequest
+	(*ListIPPoolsResponse)(nil),      // 19: pfcp.v1.ListIPPoolsResponse
+	(*PDR)(nil),                      // 20: pfcp.v1.PDR
+	(*OuterHeaderRemoval)(nil),       // 21: pfcp.v1.OuterHeaderRemoval
+	(*BBFOuterHeaderRemoval)(nil),    // 22: pfcp.v1.BBFOuterHeaderRemoval
+	(*PacketDetectionInfo)(nil),      // 23: pfcp.v1.PacketDetectionInfo
+	(*PPPoEMatch)(nil),               // 24: pfcp.v1.PPPoEMatch
+	(*PPPProtocol)(nil),              // 25: pfcp.v1.PPPProtocol
+	(*EthernetPacketFilter)(nil),     // 26: pfcp.v1.EthernetPacketFilter
+	(*MACAddress)(nil),               // 27: pfcp.v1.MACAddress
+	(*VLANTag)(nil),                  // 28: pfcp.v1.VLANTag
+	(*FTEID)(nil),                    // 29: pfcp.v1.FTEID
+	(*FAR)(nil),                      // 30: pfcp.v1.FAR
+	(*ForwardingParameters)(nil),     // 31: pfcp.v1.ForwardingParameters
+	(*PPPoESession)(nil),             // 32: pfcp.v1.PPPoESession
+	(*L2TPSession)(nil),              // 33: pfcp.v1.L2TPSession
+	(*DuplicatingParameters)(nil),    // 34: pfcp.v1.DuplicatingParameters
+	(*OuterHeaderCreation)(nil),      // 35: pfcp.v1.OuterHeaderCreation
+	(*QER)(nil),                      // 36: pfcp.v1.QER
+	(*URR)(nil),                      // 37: pfcp.v1.URR
+	(*BAR)(nil),                      // 38: pfcp.v1.BAR
 }
 var file_api_pfcp_v1_control_proto_depIdxs = []int32{
-	9,  // 0: pfcp.v1.CreateSessionRequest.pdrs:type_name -> pfcp.v1.PDR
-	11, // 1: pfcp.v1.CreateSessionRequest.fars:type_name -> pfcp.v1.FAR
-	13, // 2: pfcp.v1.CreateSessionRequest.qers:type_name -> pfcp.v1.QER
-	14, // 3: pfcp.v1.CreateSessionRequest.urrs:type_name -> pfcp.v1.URR
-	9,  // 4: pfcp.v1.ModifySessionRequest.pdrs:type_name -> pfcp.v1.PDR
-	11, // 5: pfcp.v1.ModifySessionRequest.fars:type_name -> pfcp.v1.FAR
-	13, // 6: pfcp.v1.ModifySessionRequest.qers:type_name -> pfcp.v1.QER
-	14, // 7: pfcp.v1.ModifySessionRequest.urrs:type_name -> pfcp.v1.URR
-	8,  // 8: pfcp.v1.ListAssociationsResponse.associations:type_name -> pfcp.v1.Association
-	10, // 9: pfcp.v1.PDR.pdi:type_name -> pfcp.v1.PacketDetectionInfo
-	12, // 10: pfcp.v1.FAR.forwarding_params:type_name -> pfcp.v1.ForwardingParameters
-	0,  // 11: pfcp.v1.ControlPlane.CreateSession:input_type -> pfcp.v1.CreateSessionRequest
-	2,  // 12: pfcp.v1.ControlPlane.ModifySession:input_type -> pfcp.v1.ModifySessionRequest
-	4,  // 13: pfcp.v1.ControlPlane.DeleteSession:input_type -> pfcp.v1.DeleteSessionRequest
-	6,  // 14: pfcp.v1.ControlPlane.ListAssociations:input_type -> pfcp.v1.ListAssociationsRequest
-	1,  // 15: pfcp.v1.ControlPlane.CreateSession:output_type -> pfcp.v1.CreateSessionResponse
-	3,  // 16: pfcp.v1.ControlPlane.ModifySession:output_type -> pfcp.v1.ModifySessionResponse
-	5,  // 17: pfcp.v1.ControlPlane.DeleteSession:output_type -> pfcp.v1.DeleteSessionResponse
-	7,  // 18: pfcp.v1.ControlPlane.ListAssociations:output_type -> pfcp.v1.ListAssociationsResponse
-	15, // [15:19] is the sub-list for method output_type
-	11, // [11:15] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	20, // 0: pfcp.v1.CreateSessionRequest.pdrs:type_name -> pfcp.v1.PDR
+	30, // 1: pfcp.v1.CreateSessionRequest.fars:type_name -> pfcp.v1.FAR
+	36, // 2: pfcp.v1.CreateSessionRequest.qers:type_name -> pfcp.v1.QER
+	37, // 3: pfcp.v1.CreateSessionRequest.urrs:type_name -> pfcp.v1.URR
+	38, // 4: pfcp.v1.CreateSessionRequest.bar:type_name -> pfcp.v1.BAR
+	4,  // 5: pfcp.v1.CreateSessionResponse.created_pdrs:type_name -> pfcp.v1.CreatedPDR
+	20, // 6: pfcp.v1.ModifySessionRequest.pdrs:type_name -> pfcp.v1.PDR
+	30, // 7: pfcp.v1.ModifySessionRequest.fars:type_name -> pfcp.v1.FAR
+	36, // 8: pfcp.v1.ModifySessionRequest.qers:type_name -> pfcp.v1.QER
+	37, // 9: pfcp.v1.ModifySessionRequest.urrs:type_name -> pfcp.v1.URR
+	38, // 10: pfcp.v1.ModifySessionRequest.bar:type_name -> pfcp.v1.BAR
+	4,  // 11: pfcp.v1.ModifySessionResponse.created_pdrs:type_name -> pfcp.v1.CreatedPDR
+	29, // 12: pfcp.v1.CreatedPDR.local_fteid:type_name -> pfcp.v1.FTEID
+	9,  // 13: pfcp.v1.ListAssociationsResponse.associations:type_name -> pfcp.v1.Association
+	12, // 14: pfcp.v1.AuditSessionsResponse.reports:type_name -> pfcp.v1.AuditReport
+	13, // 15: pfcp.v1.CreateIPPoolRequest.pool:type_name -> pfcp.v1.IPPool
+	13, // 16: pfcp.v1.CreateIPPoolResponse.pool:type_name -> pfcp.v1.IPPool
+	13, // 17: pfcp.v1.ListIPPoolsResponse.pools:type_name -> pfcp.v1.IPPool
+	23, // 18: pfcp.v1.PDR.pdi:type_name -> pfcp.v1.PacketDetectionInfo
+	21, // 19: pfcp.v1.PDR.outer_header_removal:type_name -> pfcp.v1.OuterHeaderRemoval
+	22, // 20: pfcp.v1.PDR.bbf_outer_header_removal:type_name -> pfcp.v1.BBFOuterHeaderRemoval
+	29, // 21: pfcp.v1.PacketDetectionInfo.local_fteid:type_name -> pfcp.v1.FTEID
+	26, // 22: pfcp.v1.PacketDetectionInfo.ethernet_packet_filters:type_name -> pfcp.v1.EthernetPacketFilter
+	24, // 23: pfcp.v1.PacketDetectionInfo.pppoe:type_name -> pfcp.v1.PPPoEMatch
+	25, // 24: pfcp.v1.PPPoEMatch.ppp_protocol:type_name -> pfcp.v1.PPPProtocol
+	27, // 25: pfcp.v1.EthernetPacketFilter.mac_addresses:type_name -> pfcp.v1.MACAddress
+	28, // 26: pfcp.v1.EthernetPacketFilter.c_tag:type_name -> pfcp.v1.VLANTag
+	28, // 27: pfcp.v1.EthernetPacketFilter.s_tag:type_name -> pfcp.v1.VLANTag
+	31, // 28: pfcp.v1.FAR.forwarding_params:type_name -> pfcp.v1.ForwardingParameters
+	34, // 29: pfcp.v1.FAR.duplicating_params:type_name -> pfcp.v1.DuplicatingParameters
+	35, // 30: pfcp.v1.ForwardingParameters.outer_header_creation:type_name -> pfcp.v1.OuterHeaderCreation
+	32, // 31: pfcp.v1.ForwardingParameters.pppoe:type_name -> pfcp.v1.PPPoESession
+	33, // 32: pfcp.v1.ForwardingParameters.l2tp:type_name -> pfcp.v1.L2TPSession
+	28, // 33: pfcp.v1.PPPoESession.c_tag:type_name -> pfcp.v1.VLANTag
+	28, // 34: pfcp.v1.PPPoESession.s_tag:type_name -> pfcp.v1.VLANTag
+	35, // 35: pfcp.v1.DuplicatingParameters.outer_header_creation:type_name -> pfcp.v1.OuterHeaderCreation
+	0,  // 36: pfcp.v1.ControlPlane.CreateSession:input_type -> pfcp.v1.CreateSessionRequest
+	2,  // 37: pfcp.v1.ControlPlane.ModifySession:input_type -> pfcp.v1.ModifySessionRequest
+	5,  // 38: pfcp.v1.ControlPlane.DeleteSession:input_type -> pfcp.v1.DeleteSessionRequest
+	7,  // 39: pfcp.v1.ControlPlane.ListAssociations:input_type -> pfcp.v1.ListAssociationsRequest
+	10, // 40: pfcp.v1.ControlPlane.AuditSessions:input_type -> pfcp.v1.AuditSessionsRequest
+	14, // 41: pfcp.v1.ControlPlane.CreateIPPool:input_type -> pfcp.v1.CreateIPPoolRequest
+	16, // 42: pfcp.v1.ControlPlane.DeleteIPPool:input_type -> pfcp.v1.DeleteIPPoolRequest
+	18, // 43: pfcp.v1.ControlPlane.ListIPPools:input_type -> pfcp.v1.ListIPPoolsRequest
+	1,  // 44: pfcp.v1.ControlPlane.CreateSession:output_type -> pfcp.v1.CreateSessionResponse
+	3,  // 45: pfcp.v1.ControlPlane.ModifySession:output_type -> pfcp.v1.ModifySessionResponse
+	6,  // 46: pfcp.v1.ControlPlane.DeleteSession:output_type -> pfcp.v1.DeleteSessionResponse
+	8,  // 47: pfcp.v1.ControlPlane.ListAssociations:output_type -> pfcp.v1.ListAssociationsResponse
+	11, // 48: pfcp.v1.ControlPlane.AuditSessions:output_type -> pfcp.v1.AuditSessionsResponse
+	15, // 49: pfcp.v1.ControlPlane.CreateIPPool:output_type -> pfcp.v1.CreateIPPoolResponse
+	17, // 50: pfcp.v1.ControlPlane.DeleteIPPool:output_type -> pfcp.v1.DeleteIPPoolResponse
+	19, // 51: pfcp.v1.ControlPlane.ListIPPools:output_type -> pfcp.v1.ListIPPoolsResponse
+	44, // [44:52] is the sub-list for method output_type
+	36, // [36:44] is the sub-list for method input_type
+	36, // [36:36] is the sub-list for extension type_name
+	36, // [36:36] is the sub-list for extension extendee
+	0,  // [0:36] is the sub-list for field type_name
 }
 
 func init() { file_api_pfcp_v1_control_proto_init() }
@@ -997,13 +2934,18 @@ func file_api_pfcp_v1_control_proto_init() {
 	if File_api_pfcp_v1_control_proto != nil {
 		return
 	}
+	file_api_pfcp_v1_control_proto_msgTypes[24].OneofWrappers = []any{}
+	file_api_pfcp_v1_control_proto_msgTypes[25].OneofWrappers = []any{}
+	file_api_pfcp_v1_control_proto_msgTypes[26].OneofWrappers = []any{}
+	file_api_pfcp_v1_control_proto_msgTypes[28].OneofWrappers = []any{}
+	file_api_pfcp_v1_control_proto_msgTypes[30].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_pfcp_v1_control_proto_rawDesc), len(file_api_pfcp_v1_control_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   15,
+			NumMessages:   39,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -23,6 +23,10 @@ const (
 	ControlPlane_ModifySession_FullMethodName    = "/pfcp.v1.ControlPlane/ModifySession"
 	ControlPlane_DeleteSession_FullMethodName    = "/pfcp.v1.ControlPlane/DeleteSession"
 	ControlPlane_ListAssociations_FullMethodName = "/pfcp.v1.ControlPlane/ListAssociations"
+	ControlPlane_AuditSessions_FullMethodName    = "/pfcp.v1.ControlPlane/AuditSessions"
+	ControlPlane_CreateIPPool_FullMethodName     = "/pfcp.v1.ControlPlane/CreateIPPool"
+	ControlPlane_DeleteIPPool_FullMethodName     = "/pfcp.v1.ControlPlane/DeleteIPPool"
+	ControlPlane_ListIPPools_FullMethodName      = "/pfcp.v1.ControlPlane/ListIPPools"
 )
 
 // ControlPlaneClient is the client API for ControlPlane service.
@@ -33,6 +37,10 @@ type ControlPlaneClient interface {
 	ModifySession(ctx context.Context, in *ModifySessionRequest, opts ...grpc.CallOption) (*ModifySessionResponse, error)
 	DeleteSession(ctx context.Context, in *DeleteSessionRequest, opts ...grpc.CallOption) (*DeleteSessionResponse, error)
 	ListAssociations(ctx context.Context, in *ListAssociationsRequest, opts ...grpc.CallOption) (*ListAssociationsResponse, error)
+	AuditSessions(ctx context.Context, in *AuditSessionsRequest, opts ...grpc.CallOption) (*AuditSessionsResponse, error)
+	CreateIPPool(ctx context.Context, in *CreateIPPoolRequest, opts ...grpc.CallOption) (*CreateIPPoolResponse, error)
+	DeleteIPPool(ctx context.Context, in *DeleteIPPoolRequest, opts ...grpc.CallOption) (*DeleteIPPoolResponse, error)
+	ListIPPools(ctx context.Context, in *ListIPPoolsRequest, opts ...grpc.CallOption) (*ListIPPoolsResponse, error)
 }
 
 type controlPlaneClient struct {
@@ -83,6 +91,46 @@ func (c *controlPlaneClient) ListAssociations(ctx context.Context, in *ListAssoc
 	return out, nil
 }
 
+func (c *controlPlaneClient) AuditSessions(ctx context.Context, in *AuditSessionsRequest, opts ...grpc.CallOption) (*AuditSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuditSessionsResponse)
+	err := c.cc.Invoke(ctx, ControlPlane_AuditSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) CreateIPPool(ctx context.Context, in *CreateIPPoolRequest, opts ...grpc.CallOption) (*CreateIPPoolResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateIPPoolResponse)
+	err := c.cc.Invoke(ctx, ControlPlane_CreateIPPool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) DeleteIPPool(ctx context.Context, in *DeleteIPPoolRequest, opts ...grpc.CallOption) (*DeleteIPPoolResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteIPPoolResponse)
+	err := c.cc.Invoke(ctx, ControlPlane_DeleteIPPool_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlPlaneClient) ListIPPools(ctx context.Context, in *ListIPPoolsRequest, opts ...grpc.CallOption) (*ListIPPoolsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListIPPoolsResponse)
+	err := c.cc.Invoke(ctx, ControlPlane_ListIPPools_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ControlPlaneServer is the server API for ControlPlane service.
 // All implementations must embed UnimplementedControlPlaneServer
 // for forward compatibility.
@@ -91,6 +139,10 @@ type ControlPlaneServer interface {
 	ModifySession(context.Context, *ModifySessionRequest) (*ModifySessionResponse, error)
 	DeleteSession(context.Context, *DeleteSessionRequest) (*DeleteSessionResponse, error)
 	ListAssociations(context.Context, *ListAssociationsRequest) (*ListAssociationsResponse, error)
+	AuditSessions(context.Context, *AuditSessionsRequest) (*AuditSessionsResponse, error)
+	CreateIPPool(context.Context, *CreateIPPoolRequest) (*CreateIPPoolResponse, error)
+	DeleteIPPool(context.Context, *DeleteIPPoolRequest) (*DeleteIPPoolResponse, error)
+	ListIPPools(context.Context, *ListIPPoolsRequest) (*ListIPPoolsResponse, error)
 	mustEmbedUnimplementedControlPlaneServer()
 }
 
@@ -113,6 +165,18 @@ func (UnimplementedControlPlaneServer) DeleteSession(context.Context, *DeleteSes
 func (UnimplementedControlPlaneServer) ListAssociations(context.Context, *ListAssociationsRequest) (*ListAssociationsResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method ListAssociations not implemented")
 }
+func (UnimplementedControlPlaneServer) AuditSessions(context.Context, *AuditSessionsRequest) (*AuditSessionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method AuditSessions not implemented")
+}
+func (UnimplementedControlPlaneServer) CreateIPPool(context.Context, *CreateIPPoolRequest) (*CreateIPPoolResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method CreateIPPool not implemented")
+}
+func (UnimplementedControlPlaneServer) DeleteIPPool(context.Context, *DeleteIPPoolRequest) (*DeleteIPPoolResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method DeleteIPPool not implemented")
+}
+func (UnimplementedControlPlaneServer) ListIPPools(context.Context, *ListIPPoolsRequest) (*ListIPPoolsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListIPPools not implemented")
+}
 func (UnimplementedControlPlaneServer) mustEmbedUnimplementedControlPlaneServer() {}
 func (UnimplementedControlPlaneServer) testEmbeddedByValue()                      {}
 
@@ -206,6 +270,78 @@ func _ControlPlane_ListAssociations_Handler(srv interface{}, ctx context.Context
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ControlPlane_AuditSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).AuditSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlPlane_AuditSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).AuditSessions(ctx, req.(*AuditSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_CreateIPPool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateIPPoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).CreateIPPool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlPlane_CreateIPPool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).CreateIPPool(ctx, req.(*CreateIPPoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_DeleteIPPool_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteIPPoolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).DeleteIPPool(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlPlane_DeleteIPPool_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).DeleteIPPool(ctx, req.(*DeleteIPPoolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlPlane_ListIPPools_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIPPoolsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlPlaneServer).ListIPPools(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlPlane_ListIPPools_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlPlaneServer).ListIPPools(ctx, req.(*ListIPPoolsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ControlPlane_ServiceDesc is the grpc.ServiceDesc for ControlPlane service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -229,6 +365,22 @@ var ControlPlane_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListAssociations",
 			Handler:    _ControlPlane_ListAssociations_Handler,
 		},
+		{
+			MethodName: "AuditSessions",
+			Handler:    _ControlPlane_AuditSessions_Handler,
+		},
+		{
+			MethodName: "CreateIPPool",
+			Handler:    _ControlPlane_CreateIPPool_Handler,
+		},
+		{
+			MethodName: "DeleteIPPool",
+			Handler:    _ControlPlane_DeleteIPPool_Handler,
+		},
+		{
+			MethodName: "ListIPPools",
+			Handler:    _ControlPlane_ListIPPools_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/pfcp/v1/control.proto",
@@ -0,0 +1,569 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v3.21.12
+// source: api/pfcp/v1/userplane.proto
+
+package pfcpv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListSessionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsRequest) Reset() {
+	*x = ListSessionsRequest{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsRequest) ProtoMessage() {}
+
+func (x *ListSessionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsRequest.ProtoReflect.Descriptor instead.
+func (*ListSessionsRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{0}
+}
+
+type ListSessionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sessions      []*UserPlaneSession    `protobuf:"bytes,1,rep,name=sessions,proto3" json:"sessions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListSessionsResponse) Reset() {
+	*x = ListSessionsResponse{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListSessionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSessionsResponse) ProtoMessage() {}
+
+func (x *ListSessionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSessionsResponse.ProtoReflect.Descriptor instead.
+func (*ListSessionsResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListSessionsResponse) GetSessions() []*UserPlaneSession {
+	if x != nil {
+		return x.Sessions
+	}
+	return nil
+}
+
+type UserPlaneSession struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LocalSeid     uint64                 `protobuf:"varint,1,opt,name=local_seid,json=localSeid,proto3" json:"local_seid,omitempty"`
+	RemoteSeid    uint64                 `protobuf:"varint,2,opt,name=remote_seid,json=remoteSeid,proto3" json:"remote_seid,omitempty"`
+	RuleHash      string                 `protobuf:"bytes,3,opt,name=rule_hash,json=ruleHash,proto3" json:"rule_hash,omitempty"`
+	PdrCount      uint32                 `protobuf:"varint,4,opt,name=pdr_count,json=pdrCount,proto3" json:"pdr_count,omitempty"`
+	FarCount      uint32                 `protobuf:"varint,5,opt,name=far_count,json=farCount,proto3" json:"far_count,omitempty"`
+	QerCount      uint32                 `protobuf:"varint,6,opt,name=qer_count,json=qerCount,proto3" json:"qer_count,omitempty"`
+	UrrCount      uint32                 `protobuf:"varint,7,opt,name=urr_count,json=urrCount,proto3" json:"urr_count,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UserPlaneSession) Reset() {
+	*x = UserPlaneSession{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UserPlaneSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UserPlaneSession) ProtoMessage() {}
+
+func (x *UserPlaneSession) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UserPlaneSession.ProtoReflect.Descriptor instead.
+func (*UserPlaneSession) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *UserPlaneSession) GetLocalSeid() uint64 {
+	if x != nil {
+		return x.LocalSeid
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetRemoteSeid() uint64 {
+	if x != nil {
+		return x.RemoteSeid
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetRuleHash() string {
+	if x != nil {
+		return x.RuleHash
+	}
+	return ""
+}
+
+func (x *UserPlaneSession) GetPdrCount() uint32 {
+	if x != nil {
+		return x.PdrCount
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetFarCount() uint32 {
+	if x != nil {
+		return x.FarCount
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetQerCount() uint32 {
+	if x != nil {
+		return x.QerCount
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetUrrCount() uint32 {
+	if x != nil {
+		return x.UrrCount
+	}
+	return 0
+}
+
+func (x *UserPlaneSession) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type StreamPuntsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamPuntsRequest) Reset() {
+	*x = StreamPuntsRequest{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamPuntsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamPuntsRequest) ProtoMessage() {}
+
+func (x *StreamPuntsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamPuntsRequest.ProtoReflect.Descriptor instead.
+func (*StreamPuntsRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{3}
+}
+
+// PuntedPacket is tagged with the session and PDR that punted it. The SEIDs
+// and PDR ID are zero if no PDR matched.
+type PuntedPacket struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	LocalSeid  uint64                 `protobuf:"varint,1,opt,name=local_seid,json=localSeid,proto3" json:"local_seid,omitempty"`
+	RemoteSeid uint64                 `protobuf:"varint,2,opt,name=remote_seid,json=remoteSeid,proto3" json:"remote_seid,omitempty"`
+	PdrId      uint32                 `protobuf:"varint,3,opt,name=pdr_id,json=pdrId,proto3" json:"pdr_id,omitempty"`
+	// source_interface is the PFCP Source Interface the packet arrived from.
+	SourceInterface uint32 `protobuf:"varint,4,opt,name=source_interface,json=sourceInterface,proto3" json:"source_interface,omitempty"`
+	// interface is the dataplane interface the packet arrived on.
+	Interface string `protobuf:"bytes,5,opt,name=interface,proto3" json:"interface,omitempty"`
+	// packet starts at the Ethernet header, or at the IP header if ip is set.
+	Packet []byte `protobuf:"bytes,6,opt,name=packet,proto3" json:"packet,omitempty"`
+	Ip     bool   `protobuf:"varint,7,opt,name=ip,proto3" json:"ip,omitempty"`
+	// received_at is in Unix nanoseconds.
+	ReceivedAt    int64 `protobuf:"varint,8,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PuntedPacket) Reset() {
+	*x = PuntedPacket{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PuntedPacket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PuntedPacket) ProtoMessage() {}
+
+func (x *PuntedPacket) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PuntedPacket.ProtoReflect.Descriptor instead.
+func (*PuntedPacket) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PuntedPacket) GetLocalSeid() uint64 {
+	if x != nil {
+		return x.LocalSeid
+	}
+	return 0
+}
+
+func (x *PuntedPacket) GetRemoteSeid() uint64 {
+	if x != nil {
+		return x.RemoteSeid
+	}
+	return 0
+}
+
+func (x *PuntedPacket) GetPdrId() uint32 {
+	if x != nil {
+		return x.PdrId
+	}
+	return 0
+}
+
+func (x *PuntedPacket) GetSourceInterface() uint32 {
+	if x != nil {
+		return x.SourceInterface
+	}
+	return 0
+}
+
+func (x *PuntedPacket) GetInterface() string {
+	if x != nil {
+		return x.Interface
+	}
+	return ""
+}
+
+func (x *PuntedPacket) GetPacket() []byte {
+	if x != nil {
+		return x.Packet
+	}
+	return nil
+}
+
+func (x *PuntedPacket) GetIp() bool {
+	if x != nil {
+		return x.Ip
+	}
+	return false
+}
+
+func (x *PuntedPacket) GetReceivedAt() int64 {
+	if x != nil {
+		return x.ReceivedAt
+	}
+	return 0
+}
+
+type InjectPacketRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// interface is the dataplane interface to send the packet out of, or for
+	// IP packets, whose routing table to send it through.
+	Interface string `protobuf:"bytes,1,opt,name=interface,proto3" json:"interface,omitempty"`
+	Packet    []byte `protobuf:"bytes,2,opt,name=packet,proto3" json:"packet,omitempty"`
+	Ip        bool   `protobuf:"varint,3,opt,name=ip,proto3" json:"ip,omitempty"`
+	// vlans are pushed onto an Ethernet frame, outermost first. All but the
+	// innermost of a stack are 802.1ad service tags.
+	Vlans []uint32 `protobuf:"varint,4,rep,packed,name=vlans,proto3" json:"vlans,omitempty"`
+	// local_seid is the UP's SEID of the session the packet belongs to, if
+	// any. The injection is refused if the session does not exist.
+	LocalSeid     uint64 `protobuf:"varint,5,opt,name=local_seid,json=localSeid,proto3" json:"local_seid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InjectPacketRequest) Reset() {
+	*x = InjectPacketRequest{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InjectPacketRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InjectPacketRequest) ProtoMessage() {}
+
+func (x *InjectPacketRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InjectPacketRequest.ProtoReflect.Descriptor instead.
+func (*InjectPacketRequest) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *InjectPacketRequest) GetInterface() string {
+	if x != nil {
+		return x.Interface
+	}
+	return ""
+}
+
+func (x *InjectPacketRequest) GetPacket() []byte {
+	if x != nil {
+		return x.Packet
+	}
+	return nil
+}
+
+func (x *InjectPacketRequest) GetIp() bool {
+	if x != nil {
+		return x.Ip
+	}
+	return false
+}
+
+func (x *InjectPacketRequest) GetVlans() []uint32 {
+	if x != nil {
+		return x.Vlans
+	}
+	return nil
+}
+
+func (x *InjectPacketRequest) GetLocalSeid() uint64 {
+	if x != nil {
+		return x.LocalSeid
+	}
+	return 0
+}
+
+type InjectPacketResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InjectPacketResponse) Reset() {
+	*x = InjectPacketResponse{}
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InjectPacketResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InjectPacketResponse) ProtoMessage() {}
+
+func (x *InjectPacketResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_pfcp_v1_userplane_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InjectPacketResponse.ProtoReflect.Descriptor instead.
+func (*InjectPacketResponse) Descriptor() ([]byte, []int) {
+	return file_api_pfcp_v1_userplane_proto_rawDescGZIP(), []int{6}
+}
+
+var File_api_pfcp_v1_userplane_proto protoreflect.FileDescriptor
+
+const file_api_pfcp_v1_userplane_proto_rawDesc = "" +
+	"\n" +
+	"\x1bapi/pfcp/v1/userplane.proto\x12\apfcp.v1\"\x15\n" +
+	"\x13ListSessionsRequest\"M\n" +
+	"\x14ListSessionsResponse\x125\n" +
+	"\bsessions\x18\x01 \x03(\v2\x19.pfcp.v1.UserPlaneSessionR\bsessions\"\x82\x02\n" +
+	"\x10UserPlaneSession\x12\x1d\n" +
+	"\n" +
+	"local_seid\x18\x01 \x01(\x04R\tlocalSeid\x12\x1f\n" +
+	"\vremote_seid\x18\x02 \x01(\x04R\n" +
+	"remoteSeid\x12\x1b\n" +
+	"\trule_hash\x18\x03 \x01(\tR\bruleHash\x12\x1b\n" +
+	"\tpdr_count\x18\x04 \x01(\rR\bpdrCount\x12\x1b\n" +
+	"\tfar_count\x18\x05 \x01(\rR\bfarCount\x12\x1b\n" +
+	"\tqer_count\x18\x06 \x01(\rR\bqerCount\x12\x1b\n" +
+	"\turr_count\x18\a \x01(\rR\burrCount\x12\x1d\n" +
+	"\n" +
+	"created_at\x18\b \x01(\x03R\tcreatedAt\"\x14\n" +
+	"\x12StreamPuntsRequest\"\xf7\x01\n" +
+	"\fPuntedPacket\x12\x1d\n" +
+	"\n" +
+	"local_seid\x18\x01 \x01(\x04R\tlocalSeid\x12\x1f\n" +
+	"\vremote_seid\x18\x02 \x01(\x04R\n" +
+	"remoteSeid\x12\x15\n" +
+	"\x06pdr_id\x18\x03 \x01(\rR\x05pdrId\x12)\n" +
+	"\x10source_interface\x18\x04 \x01(\rR\x0fsourceInterface\x12\x1c\n" +
+	"\tinterface\x18\x05 \x01(\tR\tinterface\x12\x16\n" +
+	"\x06packet\x18\x06 \x01(\fR\x06packet\x12\x0e\n" +
+	"\x02ip\x18\a \x01(\bR\x02ip\x12\x1f\n" +
+	"\vreceived_at\x18\b \x01(\x03R\n" +
+	"receivedAt\"\x90\x01\n" +
+	"\x13InjectPacketRequest\x12\x1c\n" +
+	"\tinterface\x18\x01 \x01(\tR\tinterface\x12\x16\n" +
+	"\x06packet\x18\x02 \x01(\fR\x06packet\x12\x0e\n" +
+	"\x02ip\x18\x03 \x01(\bR\x02ip\x12\x14\n" +
+	"\x05vlans\x18\x04 \x03(\rR\x05vlans\x12\x1d\n" +
+	"\n" +
+	"local_seid\x18\x05 \x01(\x04R\tlocalSeid\"\x16\n" +
+	"\x14InjectPacketResponse2\xea\x01\n" +
+	"\tUserPlane\x12K\n" +
+	"\fListSessions\x12\x1c.pfcp.v1.ListSessionsRequest\x1a\x1d.pfcp.v1.ListSessionsResponse\x12C\n" +
+	"\vStreamPunts\x12\x1b.pfcp.v1.StreamPuntsRequest\x1a\x15.pfcp.v1.PuntedPacket0\x01\x12K\n" +
+	"\fInjectPacket\x12\x1c.pfcp.v1.InjectPacketRequest\x1a\x1d.pfcp.v1.InjectPacketResponseB7Z5github.com/veesix-networks/pfcp-go/api/pfcp/v1;pfcpv1b\x06proto3"
+
+var (
+	file_api_pfcp_v1_userplane_proto_rawDescOnce sync.Once
+	file_api_pfcp_v1_userplane_proto_rawDescData []byte
+)
+
+func file_api_pfcp_v1_userplane_proto_rawDescGZIP() []byte {
+	file_api_pfcp_v1_userplane_proto_rawDescOnce.Do(func() {
+		file_api_pfcp_v1_userplane_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_api_pfcp_v1_userplane_proto_rawDesc), len(file_api_pfcp_v1_userplane_proto_rawDesc)))
+	})
+	return file_api_pfcp_v1_userplane_proto_rawDescData
+}
+
+var file_api_pfcp_v1_userplane_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_api_pfcp_v1_userplane_proto_goTypes = []any{
+	(*ListSessionsRequest)(nil),  // 0: pfcp.v1.ListSessionsRequest
+	(*ListSessionsResponse)(nil), // 1: pfcp.v1.ListSessionsResponse
+	(*UserPlaneSession)(nil),     // 2: pfcp.v1.UserPlaneSession
+	(*StreamPuntsRequest)(nil),   // 3: pfcp.v1.StreamPuntsRequest
+	(*PuntedPacket)(nil),         // 4: pfcp.v1.PuntedPacket
+	(*InjectPacketRequest)(nil),  // 5: pfcp.v1.InjectPacketRequest
+	(*InjectPacketResponse)(nil), // 6: pfcp.v1.InjectPacketResponse
+}
+var file_api_pfcp_v1_userplane_proto_depIdxs = []int32{
+	2, // 0: pfcp.v1.ListSessionsResponse.sessions:type_name -> pfcp.v1.UserPlaneSession
+	0, // 1: pfcp.v1.UserPlane.ListSessions:input_type -> pfcp.v1.ListSessionsRequest
+	3, // 2: pfcp.v1.UserPlane.StreamPunts:input_type -> pfcp.v1.StreamPuntsRequest
+	5, // 3: pfcp.v1.UserPlane.InjectPacket:input_type -> pfcp.v1.InjectPacketRequest
+	1, // 4: pfcp.v1.UserPlane.ListSessions:output_type -> pfcp.v1.ListSessionsResponse
+	4, // 5: pfcp.v1.UserPlane.StreamPunts:output_type -> pfcp.v1.PuntedPacket
+	6, // 6: pfcp.v1.UserPlane.InjectPacket:output_type -> pfcp.v1.InjectPacketResponse
+	4, // [4:7] is the sub-list for method output_type
+	1, // [1:4] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_api_pfcp_v1_userplane_proto_init() }
+func file_api_pfcp_v1_userplane_proto_init() {
+	if File_api_pfcp_v1_userplane_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_api_pfcp_v1_userplane_proto_rawDesc), len(file_api_pfcp_v1_userplane_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_pfcp_v1_userplane_proto_goTypes,
+		DependencyIndexes: file_api_pfcp_v1_userplane_proto_depIdxs,
+		MessageInfos:      file_api_pfcp_v1_userplane_proto_msgTypes,
+	}.Build()
+	File_api_pfcp_v1_userplane_proto = out.File
+	file_api_pfcp_v1_userplane_proto_goTypes = nil
+	file_api_pfcp_v1_userplane_proto_depIdxs = nil
+}
@@ -0,0 +1,209 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             v3.21.12
+// source: api/pfcp/v1/userplane.proto
+
+package pfcpv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	UserPlane_ListSessions_FullMethodName = "/pfcp.v1.UserPlane/ListSessions"
+	UserPlane_StreamPunts_FullMethodName  = "/pfcp.v1.UserPlane/StreamPunts"
+	UserPlane_InjectPacket_FullMethodName = "/pfcp.v1.UserPlane/InjectPacket"
+)
+
+// UserPlaneClient is the client API for UserPlane service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UserPlaneClient interface {
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	// StreamPunts delivers the packets the dataplane punts until the client
+	// goes away. Packets are dropped, not queued, while a client falls behind.
+	StreamPunts(ctx context.Context, in *StreamPuntsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PuntedPacket], error)
+	// InjectPacket sends a packet, such as a DHCP or PPPoE reply, out through
+	// the dataplane.
+	InjectPacket(ctx context.Context, in *InjectPacketRequest, opts ...grpc.CallOption) (*InjectPacketResponse, error)
+}
+
+type userPlaneClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUserPlaneClient(cc grpc.ClientConnInterface) UserPlaneClient {
+	return &userPlaneClient{cc}
+}
+
+func (c *userPlaneClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, UserPlane_ListSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *userPlaneClient) StreamPunts(ctx context.Context, in *StreamPuntsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PuntedPacket], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &UserPlane_ServiceDesc.Streams[0], UserPlane_StreamPunts_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamPuntsRequest, PuntedPacket]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserPlane_StreamPuntsClient = grpc.ServerStreamingClient[PuntedPacket]
+
+func (c *userPlaneClient) InjectPacket(ctx context.Context, in *InjectPacketRequest, opts ...grpc.CallOption) (*InjectPacketResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InjectPacketResponse)
+	err := c.cc.Invoke(ctx, UserPlane_InjectPacket_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UserPlaneServer is the server API for UserPlane service.
+// All implementations must embed UnimplementedUserPlaneServer
+// for forward compatibility.
+type UserPlaneServer interface {
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	// StreamPunts delivers the packets the dataplane punts until the client
+	// goes away. Packets are dropped, not queued, while a client falls behind.
+	StreamPunts(*StreamPuntsRequest, grpc.ServerStreamingServer[PuntedPacket]) error
+	// InjectPacket sends a packet, such as a DHCP or PPPoE reply, out through
+	// the dataplane.
+	InjectPacket(context.Context, *InjectPacketRequest) (*InjectPacketResponse, error)
+	mustEmbedUnimplementedUserPlaneServer()
+}
+
+// UnimplementedUserPlaneServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUserPlaneServer struct{}
+
+func (UnimplementedUserPlaneServer) ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedUserPlaneServer) StreamPunts(*StreamPuntsRequest, grpc.ServerStreamingServer[PuntedPacket]) error {
+	return status.Error(codes.Unimplemented, "method StreamPunts not implemented")
+}
+func (UnimplementedUserPlaneServer) InjectPacket(context.Context, *InjectPacketRequest) (*InjectPacketResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method InjectPacket not implemented")
+}
+func (UnimplementedUserPlaneServer) mustEmbedUnimplementedUserPlaneServer() {}
+func (UnimplementedUserPlaneServer) testEmbeddedByValue()                   {}
+
+// UnsafeUserPlaneServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UserPlaneServer will
+// result in compilation errors.
+type UnsafeUserPlaneServer interface {
+	mustEmbedUnimplementedUserPlaneServer()
+}
+
+func RegisterUserPlaneServer(s grpc.ServiceRegistrar, srv UserPlaneServer) {
+	// If the following call panics, it indicates UnimplementedUserPlaneServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&UserPlane_ServiceDesc, srv)
+}
+
+func _UserPlane_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserPlaneServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserPlane_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserPlaneServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _UserPlane_StreamPunts_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamPuntsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UserPlaneServer).StreamPunts(m, &grpc.GenericServerStream[StreamPuntsRequest, PuntedPacket]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type UserPlane_StreamPuntsServer = grpc.ServerStreamingServer[PuntedPacket]
+
+func _UserPlane_InjectPacket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InjectPacketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UserPlaneServer).InjectPacket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: UserPlane_InjectPacket_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UserPlaneServer).InjectPacket(ctx, req.(*InjectPacketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// UserPlane_ServiceDesc is the grpc.ServiceDesc for UserPlane service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var UserPlane_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pfcp.v1.UserPlane",
+	HandlerType: (*UserPlaneServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler:    _UserPlane_ListSessions_Handler,
+		},
+		{
+			MethodName: "InjectPacket",
+			Handler:    _UserPlane_InjectPacket_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamPunts",
+			Handler:       _UserPlane_StreamPunts_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/pfcp/v1/userplane.proto",
+}
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -23,6 +25,16 @@ func main() {
 	heartbeatInterval := flag.Duration("heartbeat-interval", 60*time.Second, "Heartbeat interval")
 	retransmitN1 := flag.Int("retransmit-n1", 3, "Max retransmission attempts")
 	retransmitT1 := flag.Duration("retransmit-t1", 3*time.Second, "Retransmission timeout")
+	storeType := flag.String("store", "memory", "Session store type (memory, file or kv)")
+	storeDir := flag.String("store-dir", "/var/lib/pfcp-cp", "Session store directory for the file store")
+	kvEndpoints := flag.String("kv-endpoints", "127.0.0.1:2379", "Comma-separated etcd endpoints for the kv store")
+	kvPrefix := flag.String("kv-prefix", "/pfcp-cp", "Key prefix of the kv store and leader lease")
+	electionID := flag.String("election-id", "", "Identity of this CP in leader election (default: node ID and hostname)")
+	electionTTL := flag.Duration("election-ttl", 10*time.Second, "Leader lease TTL; a standby takes over this long after the active CP stops renewing")
+	auditInterval := flag.Duration("audit-interval", 0, "Session audit interval (0 disables scheduled audits)")
+	upAdminAddrs := flag.String("up-admin-addrs", "", "Comma-separated node-id=host:port list of UP admin gRPC addresses")
+	puntLog := flag.Bool("punt-log", false, "Stream the packets the UPs punt and log them (requires -up-admin-addrs)")
+	ipBindingLifetime := flag.Duration("ip-binding-lifetime", 24*time.Hour, "How long an IP pool address stays bound to its subscriber after it is released (0 keeps bindings forever)")
 
 	flag.Parse()
 
@@ -33,8 +45,42 @@ func main() {
 	log.Printf("  Heartbeat Interval: %s", *heartbeatInterval)
 	log.Printf("  Retransmit N1: %d", *retransmitN1)
 	log.Printf("  Retransmit T1: %s", *retransmitT1)
+	log.Printf("  Store: %s", *storeType)
+
+	var store cp.NorthboundStore
+	var elector *cp.KVElector
+
+	switch *storeType {
+	case "memory":
+		store = cp.NewMemoryStore()
+	case "file":
+		log.Printf("  Store Directory: %s", *storeDir)
+		fileStore, err := cp.NewFileStore(*storeDir)
+		if err != nil {
+			log.Fatalf("Failed to open file store: %v", err)
+		}
+		defer fileStore.Close()
+		store = fileStore
+	case "kv":
+		endpoints := strings.Split(*kvEndpoints, ",")
+		log.Printf("  KV Endpoints: %s (prefix %s)", strings.Join(endpoints, ", "), *kvPrefix)
+		backend, err := cp.NewEtcdKV(endpoints, 5*time.Second, *electionTTL/3)
+		if err != nil {
+			log.Fatalf("Failed to open kv store: %v", err)
+		}
+		defer backend.Close()
+		store = cp.NewKVStore(backend, *kvPrefix)
 
-	store := cp.NewMemoryStore()
+		id := *electionID
+		if id == "" {
+			hostname, _ := os.Hostname()
+			id = fmt.Sprintf("%s@%s", *nodeID, hostname)
+		}
+		log.Printf("  Leader Election: %s (TTL %s)", id, *electionTTL)
+		elector = cp.NewKVElector(backend, strings.TrimSuffix(*kvPrefix, "/")+"/leader", id, *electionTTL)
+	default:
+		log.Fatalf("Unknown store type: %s", *storeType)
+	}
 
 	cpCfg := &cp.Config{
 		NodeID:            *nodeID,
@@ -42,6 +88,8 @@ func main() {
 		HeartbeatInterval: *heartbeatInterval,
 		RetransmitN1:      *retransmitN1,
 		RetransmitT1:      *retransmitT1,
+		AuditInterval:     *auditInterval,
+		IPBindingLifetime: *ipBindingLifetime,
 	}
 
 	cpFunc, err := cp.NewCPFunction(cpCfg, store)
@@ -49,6 +97,31 @@ func main() {
 		log.Fatalf("Failed to create CP function: %v", err)
 	}
 
+	if elector != nil {
+		cpFunc.SetElector(elector)
+	}
+
+	if *upAdminAddrs != "" {
+		addrs, err := parseNodeAddrs(*upAdminAddrs)
+		if err != nil {
+			log.Fatalf("Invalid -up-admin-addrs: %v", err)
+		}
+		auditClient := cp.NewGRPCAuditClient(addrs)
+		defer auditClient.Close()
+		cpFunc.SetAuditClient(auditClient)
+		log.Printf("  Session audit enabled for %d UP nodes (interval: %s)", len(addrs), *auditInterval)
+
+		if *puntLog {
+			cpFunc.SetPuntHandler(auditClient, func(pkt *cp.PuntedPacket) {
+				log.Printf("Punted %d bytes from node %s interface %s (session %d, PDR %d)",
+					len(pkt.Data), pkt.NodeID, pkt.Interface, pkt.SEID, pkt.PDRID)
+			})
+			log.Printf("  Punt logging enabled")
+		}
+	} else if *puntLog {
+		log.Fatalf("-punt-log requires -up-admin-addrs")
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -85,3 +158,15 @@ func main() {
 	cancel()
 	time.Sleep(1 * time.Second)
 }
+
+func parseNodeAddrs(value string) (map[string]string, error) {
+	addrs := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		nodeID, addr, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || nodeID == "" || addr == "" {
+			return nil, fmt.Errorf("expected node-id=host:port, got %q", entry)
+		}
+		addrs[nodeID] = addr
+	}
+	return addrs, nil
+}
@@ -3,15 +3,26 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	pb "github.com/veesix-networks/pfcp-go/api/pfcp/v1"
+	"github.com/veesix-networks/pfcp-go/pkg/afpacket"
+	"github.com/veesix-networks/pfcp-go/pkg/dataplane/linux"
 	"github.com/veesix-networks/pfcp-go/pkg/dataplane/mock"
+	"github.com/veesix-networks/pfcp-go/pkg/dataplane/userspace"
 	"github.com/veesix-networks/pfcp-go/pkg/dataplane/vpp"
 	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 )
 
 func main() {
@@ -19,8 +30,26 @@ func main() {
 	cpAddress := flag.String("cp-address", "127.0.0.1:8805", "Control Plane address")
 	localAddr := flag.String("local-addr", ":8805", "Local listen address")
 	heartbeatInterval := flag.Duration("heartbeat-interval", 60*time.Second, "Heartbeat interval")
-	dataplaneType := flag.String("dataplane", "vpp", "Dataplane type (mock or vpp)")
+	dataplaneType := flag.String("dataplane", "vpp", "Dataplane type (mock, vpp or linux)")
 	vppSocket := flag.String("vpp-socket", "/run/vpp/api.sock", "VPP API socket path")
+	vppStateFile := flag.String("vpp-state-file", "/var/lib/pfcp-up/vpp-state.json", "File recording what was programmed into VPP, used to clean up after a restart")
+	reconcileDelay := flag.Duration("reconcile-delay", 60*time.Second, "Time the CP has to restore sessions before stale dataplane state is removed")
+	grpcAddr := flag.String("grpc-addr", ":50061", "gRPC admin API address")
+	accessInterfaces := flag.String("access-interfaces", "", "Comma-separated access interfaces, VPP or Linux, for L2 punt and uplink QER enforcement")
+	l2PuntNode := flag.String("l2-punt-node", "error-punt", "VPP graph node receiving L2 punted frames")
+	l2PuntTap := flag.String("l2-punt-tap", "", "VPP tap interface L2 punted frames are sent out of instead of -l2-punt-node, captured on its host side with -punt-capture")
+	coreInterfaces := flag.String("core-interfaces", "", "Comma-separated core interfaces, VPP or Linux, for downlink QER enforcement")
+	vppStatsSocket := flag.String("vpp-stats-socket", "/run/vpp/stats.sock", "VPP stats socket path, used to read URR usage counters")
+	gtpuAddr := flag.String("gtpu-addr", "", "Local GTP-U address on which F-TEIDs are allocated when the CP asks the UP to CHOOSE one")
+	l2tpAddr := flag.String("l2tp-addr", "", "Local address the UP sends L2TP packets to an LNS from when it forwards L2TP sessions for VPP (default: -gtpu-addr)")
+	nfqueue := flag.Int("nfqueue", -1, "NFQUEUE number the linux dataplane punts packets to and delivers them from; without it, PDRs and FARs that punt are rejected")
+	puntSocket := flag.String("punt-socket", "", "Socket VPP delivers L4 and IP protocol punts to, streamed to the CP over the gRPC admin API")
+	puntCapture := flag.Bool("punt-capture", false, "Capture the access and core interfaces, or with VPP the host side of -l2-punt-tap, with AF_PACKET and stream the packets PDRs punt to the CP")
+	pppoeCPInterface := flag.String("pppoe-cp-interface", "", "VPP interface the pppoe plugin hands PPPoE discovery and PPP control frames to, e.g. a tap towards the BNG control plane")
+	networkInstances := flag.String("network-instances", "", "Comma-separated name=table pairs mapping Network Instances to VPP FIB table IDs or Linux VRF devices, e.g. internet=1,ims=2")
+	ueIPPools := flag.String("ue-ip-pools", "", "Comma-separated [network-instance=]prefix pools UE IP addresses are allocated from when the CP asks the UP to CHOOSE one, IPv6 with the prefix length handed out, e.g. 100.64.0.0/16,internet=2001:db8::/48/64")
+	ueIPStateFile := flag.String("ue-ip-state-file", "/var/lib/pfcp-up/ue-ip-state.jsonl", "File recording UE IP allocations so that they survive a restart")
+	usageInterval := flag.Duration("usage-interval", 10*time.Second, "Interval at which URR volume and time thresholds are evaluated (0 disables)")
 
 	flag.Parse()
 
@@ -30,19 +59,78 @@ func main() {
 	log.Printf("  Local Address: %s", *localAddr)
 	log.Printf("  Heartbeat Interval: %s", *heartbeatInterval)
 	log.Printf("  Dataplane: %s", *dataplaneType)
+	log.Printf("  gRPC Address: %s", *grpcAddr)
+	log.Printf("  GTP-U Address: %s", *gtpuAddr)
+	log.Printf("  UE IP Pools: %s", *ueIPPools)
 
 	var dp up.Dataplane
+	var puntSource up.PuntSource
 	var err error
 
+	instances, err := splitPairs(*networkInstances)
+	if err != nil {
+		log.Fatalf("Invalid network instances: %v", err)
+	}
+
 	switch *dataplaneType {
 	case "vpp":
 		log.Printf("  VPP Socket: %s", *vppSocket)
-		dp, err = vpp.NewVPPDataplane(*vppSocket)
+		log.Printf("  VPP State File: %s", *vppStateFile)
+		log.Printf("  Access Interfaces: %s", *accessInterfaces)
+		log.Printf("  Core Interfaces: %s", *coreInterfaces)
+		log.Printf("  Network Instances: %s", *networkInstances)
+		tables := make(map[string]uint32, len(instances))
+		for name, table := range instances {
+			id, err := strconv.ParseUint(table, 10, 32)
+			if err != nil {
+				log.Fatalf("Invalid FIB table of network instance %s: %q", name, table)
+			}
+			tables[name] = uint32(id)
+		}
+		dp, err = vpp.NewVPPDataplane(&vpp.Config{
+			SocketPath:            *vppSocket,
+			StateFile:             *vppStateFile,
+			AccessInterfaces:      splitList(*accessInterfaces),
+			L2PuntNode:            *l2PuntNode,
+			L2PuntTap:             *l2PuntTap,
+			CoreInterfaces:        splitList(*coreInterfaces),
+			StatsSocketPath:       *vppStatsSocket,
+			PuntSocketPath:        *puntSocket,
+			PPPoEControlInterface: *pppoeCPInterface,
+			NetworkInstances:      tables,
+		})
 		if err != nil {
 			log.Fatalf("Failed to create VPP dataplane: %v", err)
 		}
+		puntSource = dp.(*vpp.VPPDataplane).PuntSource()
 		log.Println("VPP dataplane initialized")
+	case "linux":
+		log.Printf("  Access Interfaces: %s", *accessInterfaces)
+		log.Printf("  Core Interfaces: %s", *coreInterfaces)
+		log.Printf("  Network Instances: %s", *networkInstances)
+		log.Printf("  NFQUEUE: %d", *nfqueue)
+		if *nfqueue > math.MaxUint16 {
+			log.Fatalf("Invalid NFQUEUE number: %d", *nfqueue)
+		}
+		if *puntCapture {
+			log.Fatalf("-punt-capture is not used with the linux dataplane, which delivers punts from -nfqueue")
+		}
+		dp, err = linux.NewLinuxDataplane(&linux.Config{
+			AccessInterfaces: splitList(*accessInterfaces),
+			CoreInterfaces:   splitList(*coreInterfaces),
+			PuntQueue:        uint16(max(*nfqueue, 0)),
+			PuntDelivery:     *nfqueue >= 0,
+			NetworkInstances: instances,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create Linux dataplane: %v", err)
+		}
+		puntSource = dp.(*linux.LinuxDataplane).PuntSource()
+		log.Println("Linux dataplane initialized")
 	case "mock":
+		if len(instances) > 0 {
+			log.Printf("Network instances are ignored by the mock dataplane")
+		}
 		dp = mock.NewMockDataplane()
 		log.Println("Mock dataplane initialized")
 	default:
@@ -54,6 +142,44 @@ func main() {
 		CPAddress:         *cpAddress,
 		LocalAddr:         *localAddr,
 		HeartbeatInterval: *heartbeatInterval,
+		ReconcileDelay:    *reconcileDelay,
+		UsageInterval:     *usageInterval,
+		GTPUAddress:       *gtpuAddr,
+		UEIPStateFile:     *ueIPStateFile,
+	}
+
+	for _, s := range splitList(*ueIPPools) {
+		pool, err := up.ParseUEIPPool(s)
+		if err != nil {
+			log.Fatalf("Invalid UE IP pool: %v", err)
+		}
+		upCfg.UEIPPools = append(upCfg.UEIPPools, pool)
+	}
+
+	if *puntCapture {
+		captureCfg := &afpacket.Config{
+			AccessInterfaces: splitList(*accessInterfaces),
+			CoreInterfaces:   splitList(*coreInterfaces),
+		}
+		// VPP owns its interfaces, so only the L2 punt tap can be
+		// captured. The punt socket, if any, carries the other punts.
+		if v, ok := dp.(*vpp.VPPDataplane); ok {
+			host, iface, ok := v.L2PuntCapture()
+			if !ok {
+				log.Fatalf("-punt-capture with VPP captures the L2 punt tap, set -l2-punt-tap")
+			}
+			captureCfg = &afpacket.Config{PuntInterfaces: map[string]string{host: iface}}
+		}
+
+		capture, err := afpacket.NewSource(captureCfg)
+		if err != nil {
+			log.Fatalf("Failed to create AF_PACKET punt source: %v", err)
+		}
+		if puntSource != nil {
+			puntSource = up.MergePuntSources(puntSource, capture)
+		} else {
+			puntSource = capture
+		}
 	}
 
 	upFunc, err := up.NewUPFunction(upCfg, dp)
@@ -61,6 +187,21 @@ func main() {
 		log.Fatalf("Failed to create UP function: %v", err)
 	}
 
+	if puntSource != nil {
+		// The userspace dataplane tags punted packets with the session
+		// and PDR that punted them, and forwards those diverted to the UP.
+		classifier, err := userspace.NewUserspaceDataplane(&userspace.Config{
+			GTPUAddress: *gtpuAddr,
+			L2TPAddress: *l2tpAddr,
+			Quiet:       true,
+		})
+		if err != nil {
+			log.Fatalf("Failed to create punt classifier: %v", err)
+		}
+		upFunc.SetPuntSource(puntSource, classifier)
+		log.Println("Punt delivery enabled")
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -70,6 +211,22 @@ func main() {
 		}
 	}()
 
+	lis, err := net.Listen("tcp", *grpcAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen on gRPC address: %v", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	pb.RegisterUserPlaneServer(grpcServer, up.NewGRPCServer(upFunc))
+	reflection.Register(grpcServer)
+
+	go func() {
+		log.Printf("gRPC server listening on %s", *grpcAddr)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 
@@ -77,6 +234,33 @@ func main() {
 
 	<-sigCh
 	log.Println("Shutting down...")
+	// Stopping the UP function ends the punt streams, which a graceful
+	// stop would otherwise wait for.
 	cancel()
+	grpcServer.GracefulStop()
 	time.Sleep(1 * time.Second)
 }
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// splitPairs parses a comma-separated list of name=value pairs.
+func splitPairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for _, item := range splitList(s) {
+		name, value, ok := strings.Cut(item, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || value == "" {
+			return nil, fmt.Errorf("%q is not name=value", item)
+		}
+		pairs[name] = value
+	}
+	return pairs, nil
+}
@@ -0,0 +1,224 @@
+// Package afpacket is a punt source for dataplanes without a punt channel of
+// their own. It captures the traffic of the access and core interfaces with
+// AF_PACKET sockets, leaving the UP to deliver the packets whose PDR punts
+// them, and sends injected frames out of the same interfaces. It also reads
+// host interfaces a dataplane sends nothing but punted frames out of, such
+// as the host side of VPP's L2 punt tap.
+package afpacket
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"golang.org/x/sys/unix"
+)
+
+// maxFrameLen covers jumbo frames. Longer ones are truncated.
+const maxFrameLen = 9216
+
+type Config struct {
+	AccessInterfaces []string
+	CoreInterfaces   []string
+	// PuntInterfaces maps host interfaces carrying access side frames the
+	// dataplane punted to the interface the frames are reported to have
+	// arrived on. Nothing is injected through them.
+	PuntInterfaces map[string]string
+}
+
+type Source struct {
+	links   map[string]*link
+	packets chan *up.PuntedPacket
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// link is a packet socket bound to one interface.
+type link struct {
+	name            string
+	sourceInterface uint8
+	// punted is set for punt interfaces, whose frames are reported on
+	// iface.
+	punted bool
+	iface  string
+	file   *os.File
+	conn   syscall.RawConn
+}
+
+func NewSource(cfg *Config) (*Source, error) {
+	s := &Source{
+		links:   make(map[string]*link),
+		packets: make(chan *up.PuntedPacket, 256),
+		done:    make(chan struct{}),
+	}
+
+	for _, ifaces := range []struct {
+		names           []string
+		sourceInterface uint8
+	}{
+		{cfg.AccessInterfaces, protocol.SourceInterfaceAccess},
+		{cfg.CoreInterfaces, protocol.SourceInterfaceCore},
+	} {
+		for _, name := range ifaces.names {
+			if _, ok := s.links[name]; ok {
+				s.Close()
+				return nil, fmt.Errorf("interface %s listed more than once", name)
+			}
+			l, err := openLink(name, ifaces.sourceInterface)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+			s.links[name] = l
+		}
+	}
+
+	for name, iface := range cfg.PuntInterfaces {
+		if _, ok := s.links[name]; ok {
+			s.Close()
+			return nil, fmt.Errorf("interface %s listed more than once", name)
+		}
+		l, err := openLink(name, protocol.SourceInterfaceAccess)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		l.punted = true
+		l.iface = iface
+		s.links[name] = l
+	}
+
+	if len(s.links) == 0 {
+		return nil, fmt.Errorf("no access, core or punt interfaces to capture")
+	}
+
+	for _, l := range s.links {
+		s.wg.Add(1)
+		go s.capture(l)
+	}
+
+	return s, nil
+}
+
+func openLink(name string, sourceInterface uint8) (*link, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", name, err)
+	}
+
+	proto := htons(unix.ETH_P_ALL)
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, int(proto))
+	if err != nil {
+		return nil, fmt.Errorf("open packet socket on %s: %w", name, err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: proto, Ifindex: iface.Index}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("bind packet socket to %s: %w", name, err)
+	}
+
+	// A non-blocking file goes through the runtime poller, so closing it
+	// wakes up the reader.
+	file := os.NewFile(uintptr(fd), name)
+	conn, err := file.SyscallConn()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("packet socket on %s: %w", name, err)
+	}
+
+	return &link{name: name, sourceInterface: sourceInterface, iface: name, file: file, conn: conn}, nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// capture queues every frame the interface receives. Frames it sends,
+// including injected ones, are skipped.
+func (s *Source) capture(l *link) {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxFrameLen)
+	for {
+		var n int
+		var from unix.Sockaddr
+		var recvErr error
+		err := l.conn.Read(func(fd uintptr) bool {
+			n, from, recvErr = unix.Recvfrom(int(fd), buf, 0)
+			return recvErr != unix.EAGAIN
+		})
+		if err == nil {
+			err = recvErr
+		}
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				log.Printf("[AF_PACKET] Capture on %s stopped: %v", l.name, err)
+			}
+			return
+		}
+
+		if sll, ok := from.(*unix.SockaddrLinklayer); ok && sll.Pkttype == unix.PACKET_OUTGOING {
+			continue
+		}
+
+		pkt := &up.PuntedPacket{
+			Interface:       l.iface,
+			SourceInterface: l.sourceInterface,
+			Data:            append([]byte(nil), buf[:n]...),
+			ReceivedAt:      time.Now(),
+			Captured:        !l.punted,
+		}
+
+		select {
+		case s.packets <- pkt:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Source) ReadPunt() (*up.PuntedPacket, error) {
+	select {
+	case pkt := <-s.packets:
+		return pkt, nil
+	case <-s.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// InjectPacket sends an Ethernet frame out of an access or core interface.
+// IP packets are not routed.
+func (s *Source) InjectPacket(pkt *up.InjectedPacket) error {
+	if pkt.IP {
+		return fmt.Errorf("AF_PACKET punt source injects Ethernet frames only")
+	}
+
+	l, ok := s.links[pkt.Interface]
+	if !ok || l.punted {
+		return fmt.Errorf("unknown interface %q", pkt.Interface)
+	}
+
+	if _, err := l.file.Write(pkt.Data); err != nil {
+		return fmt.Errorf("send on %s: %w", l.name, err)
+	}
+	return nil
+}
+
+func (s *Source) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		for _, l := range s.links {
+			l.file.Close()
+		}
+		s.wg.Wait()
+	})
+	return nil
+}
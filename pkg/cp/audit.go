@@ -0,0 +1,302 @@
+package cp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/veesix-networks/pfcp-go/api/pfcp/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// UPSessionInfo is the UP's view of a session as reported by its admin API.
+type UPSessionInfo struct {
+	LocalSEID  uint64
+	RemoteSEID uint64
+	RuleHash   string
+	CreatedAt  time.Time
+}
+
+type UPAuditClient interface {
+	ListUPSessions(ctx context.Context, nodeID string) ([]*UPSessionInfo, error)
+}
+
+// GRPCAuditClient reads UP sessions, and streams the packets UPs punt, over
+// the UserPlane gRPC admin API.
+type GRPCAuditClient struct {
+	addrs map[string]string
+	conns map[string]*grpc.ClientConn
+	mu    sync.Mutex
+}
+
+func NewGRPCAuditClient(addrs map[string]string) *GRPCAuditClient {
+	return &GRPCAuditClient{
+		addrs: addrs,
+		conns: make(map[string]*grpc.ClientConn),
+	}
+}
+
+func (c *GRPCAuditClient) conn(nodeID string) (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conn, ok := c.conns[nodeID]; ok {
+		return conn, nil
+	}
+
+	addr, ok := c.addrs[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("no admin address for node %s", nodeID)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", addr, err)
+	}
+
+	c.conns[nodeID] = conn
+	return conn, nil
+}
+
+func (c *GRPCAuditClient) ListUPSessions(ctx context.Context, nodeID string) ([]*UPSessionInfo, error) {
+	conn, err := c.conn(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := pb.NewUserPlaneClient(conn).ListSessions(ctx, &pb.ListSessionsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]*UPSessionInfo, 0, len(resp.Sessions))
+	for _, session := range resp.Sessions {
+		sessions = append(sessions, &UPSessionInfo{
+			LocalSEID:  session.LocalSeid,
+			RemoteSEID: session.RemoteSeid,
+			RuleHash:   session.RuleHash,
+			CreatedAt:  time.Unix(session.CreatedAt, 0),
+		})
+	}
+
+	return sessions, nil
+}
+
+func (c *GRPCAuditClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for nodeID, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, nodeID)
+	}
+	return nil
+}
+
+// AuditReport describes the drift found between the CP and one UP. SEIDs in
+// Missing, Mismatched and Reestablished are CP SEIDs; Orphaned and Deleted
+// are UP SEIDs, since the CP has no session for them.
+type AuditReport struct {
+	NodeID        string
+	Missing       []uint64
+	Orphaned      []uint64
+	Mismatched    []uint64
+	Reestablished []uint64
+	Deleted       []uint64
+	Errors        []string
+}
+
+// SetAuditClient enables session audits against the UPs. It must be called
+// before Start for scheduled audits to run.
+func (cp *CPFunction) SetAuditClient(client UPAuditClient) {
+	cp.auditClient = client
+}
+
+// AuditSessions compares the CP's sessions with those reported by the UP, or
+// by every associated UP when nodeID is empty. With repair set, sessions
+// missing on the UP or with different rules are re-established and sessions
+// unknown to the CP are deleted.
+func (cp *CPFunction) AuditSessions(ctx context.Context, nodeID string, repair bool) ([]*AuditReport, error) {
+	if cp.auditClient == nil {
+		return nil, fmt.Errorf("session audit not configured")
+	}
+
+	if repair && !cp.IsActive() {
+		return nil, fmt.Errorf("control plane is standby")
+	}
+
+	cp.mu.RLock()
+	var nodeIDs []string
+	for id := range cp.associations {
+		if nodeID == "" || id == nodeID {
+			nodeIDs = append(nodeIDs, id)
+		}
+	}
+	cp.mu.RUnlock()
+
+	if nodeID != "" && len(nodeIDs) == 0 {
+		return nil, fmt.Errorf("no association with node %s", nodeID)
+	}
+
+	sort.Strings(nodeIDs)
+
+	reports := make([]*AuditReport, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		reports = append(reports, cp.auditNode(ctx, id, repair))
+	}
+
+	return reports, nil
+}
+
+func (cp *CPFunction) auditNode(ctx context.Context, nodeID string, repair bool) *AuditReport {
+	report := &AuditReport{NodeID: nodeID}
+
+	upSessions, err := cp.auditClient.ListUPSessions(ctx, nodeID)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("list UP sessions: %v", err))
+		return report
+	}
+
+	// Hash the rules under the lock, which ModifySession changes them under.
+	cp.mu.RLock()
+	assoc, ok := cp.associations[nodeID]
+	var sessions []*Session
+	ruleHashes := make(map[uint64]string)
+	for _, session := range cp.sessions {
+		if session.NodeID == nodeID {
+			sessions = append(sessions, session)
+			ruleHashes[session.LocalSEID] = session.RuleHash()
+		}
+	}
+	cp.mu.RUnlock()
+
+	if !ok {
+		report.Errors = append(report.Errors, "association released during audit")
+		return report
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LocalSEID < sessions[j].LocalSEID })
+
+	upByCPSEID := make(map[uint64]*UPSessionInfo, len(upSessions))
+	for _, upSession := range upSessions {
+		upByCPSEID[upSession.RemoteSEID] = upSession
+	}
+
+	known := make(map[uint64]bool, len(sessions))
+
+	for _, session := range sessions {
+		known[session.LocalSEID] = true
+
+		upSession, found := upByCPSEID[session.LocalSEID]
+		switch {
+		case !found:
+			report.Missing = append(report.Missing, session.LocalSEID)
+		case upSession.RuleHash != ruleHashes[session.LocalSEID]:
+			report.Mismatched = append(report.Mismatched, session.LocalSEID)
+		default:
+			continue
+		}
+
+		if !repair {
+			continue
+		}
+
+		if found {
+			usage, err := cp.deleteRemoteSession(assoc, upSession.LocalSEID)
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("delete mismatched session %d: %v", session.LocalSEID, err))
+				continue
+			}
+			cp.reportUsage(session.LocalSEID, usage)
+		}
+
+		if err := cp.reestablishSession(assoc, session); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("re-establish session %d: %v", session.LocalSEID, err))
+			continue
+		}
+		report.Reestablished = append(report.Reestablished, session.LocalSEID)
+	}
+
+	// Sessions the CP is still establishing are not yet in cp.sessions, so
+	// leave recently created UP sessions alone.
+	grace := cp.config.RetransmitT1 * time.Duration(cp.config.RetransmitN1+1)
+
+	for _, upSession := range upSessions {
+		if known[upSession.RemoteSEID] || time.Since(upSession.CreatedAt) < grace {
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, upSession.LocalSEID)
+
+		if !repair {
+			continue
+		}
+
+		if _, err := cp.deleteRemoteSession(assoc, upSession.LocalSEID); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("delete orphaned session %d: %v", upSession.LocalSEID, err))
+			continue
+		}
+		report.Deleted = append(report.Deleted, upSession.LocalSEID)
+	}
+
+	return report
+}
+
+// reestablishSession establishes a snapshot of the session's rules, taken
+// under the lock, so that the request is not built from rules a concurrent
+// ModifySession is changing.
+func (cp *CPFunction) reestablishSession(assoc *Association, session *Session) error {
+	cp.mu.RLock()
+	snapshot, err := session.clone()
+	cp.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	remoteSEID, resp, err := cp.establishSession(assoc, snapshot)
+	if err != nil {
+		return err
+	}
+
+	cp.mu.Lock()
+	applyCreatedPDRs(session, resp)
+	session.RemoteSEID = remoteSEID
+	cp.mu.Unlock()
+
+	return cp.persistSession(session.LocalSEID, session)
+}
+
+func (cp *CPFunction) auditLoop() {
+	defer cp.wg.Done()
+
+	ticker := time.NewTicker(cp.config.AuditInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cp.ctx.Done():
+			return
+		case <-ticker.C:
+			if !cp.IsActive() {
+				continue
+			}
+
+			reports, err := cp.AuditSessions(cp.ctx, "", true)
+			if err != nil {
+				fmt.Printf("Audit: %v\n", err)
+				continue
+			}
+
+			for _, report := range reports {
+				if len(report.Missing)+len(report.Orphaned)+len(report.Mismatched)+len(report.Errors) == 0 {
+					continue
+				}
+				fmt.Printf("Audit: node %s missing=%v orphaned=%v mismatched=%v reestablished=%v deleted=%v errors=%v\n",
+					report.NodeID, report.Missing, report.Orphaned, report.Mismatched, report.Reestablished, report.Deleted, report.Errors)
+			}
+		}
+	}
+}
@@ -2,27 +2,40 @@ package cp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
 	"github.com/veesix-networks/pfcp-go/pkg/protocol"
 )
 
 type CPFunction struct {
-	config       *Config
-	nodeID       []byte
-	recoveryTS   uint32
-	transport    *protocol.Transport
-	associations map[string]*Association
-	sessions     map[uint64]*Session
-	nextSEID     uint64
-	store        NorthboundStore
-	mu           sync.RWMutex
-	ctx          context.Context
-	cancel       context.CancelFunc
-	wg           sync.WaitGroup
+	config        *Config
+	nodeID        []byte
+	recoveryTS    uint32
+	transport     *protocol.Transport
+	associations  map[string]*Association
+	sessions      map[uint64]*Session
+	nextSEID      uint64
+	store         NorthboundStore
+	elector       Elector
+	auditClient   UPAuditClient
+	usageHandler  UsageHandler
+	dlDataHandler DownlinkDataHandler
+	reports       chan func()
+	reportDrops   uint64
+	reportMu      sync.Mutex
+	puntClient    UPPuntClient
+	puntHandler   PuntHandler
+	ipam          *ipam
+	mu            sync.RWMutex
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
 }
 
 type Config struct {
@@ -31,8 +44,24 @@ type Config struct {
 	HeartbeatInterval time.Duration
 	RetransmitN1      int
 	RetransmitT1      time.Duration
+	AuditInterval     time.Duration
+	// IPBindingLifetime is how long an IP pool address stays bound to its
+	// subscriber after it is released, or forever if zero.
+	IPBindingLifetime time.Duration
 }
 
+// UsageHandler receives the Usage Reports the UP sends for a session, in
+// Session Report Requests and in the Session Deletion Response. Handlers run
+// one at a time, in the order the reports arrived, off the PFCP receive path,
+// so they may call ModifySession and DeleteSession.
+type UsageHandler func(seid uint64, reports []*protocol.UsageReport)
+
+// DownlinkDataHandler receives the Downlink Data Reports the UP sends when it
+// starts buffering downlink packets for a session, with the PDRs that matched
+// them. Updating their FARs to forward releases the packets. It runs like a
+// UsageHandler.
+type DownlinkDataHandler func(seid uint64, pdrIDs []uint16)
+
 type Association struct {
 	NodeID        []byte
 	RemoteAddr    *net.UDPAddr
@@ -50,7 +79,12 @@ type Session struct {
 	FARs       map[uint32]*FAR
 	QERs       map[uint32]*QER
 	URRs       map[uint32]*URR
-	CreatedAt  time.Time
+	// BAR is the session's Buffering Action Rule, or nil.
+	BAR *BAR
+	// SubscriberID keys the session's addresses from the CP's IP pools, so
+	// that the subscriber gets the same ones back.
+	SubscriberID string
+	CreatedAt    time.Time
 }
 
 type PDR struct {
@@ -60,6 +94,11 @@ type PDR struct {
 	FAR_ID     uint32
 	QER_IDs    []uint32
 	URR_IDs    []uint32
+	// OuterHeaderRemoval is the Outer Header Removal description, or nil.
+	OuterHeaderRemoval *uint8
+	// BBFOuterHeaderRemoval is the BBF Outer Header Removal description,
+	// or nil.
+	BBFOuterHeaderRemoval *uint8
 }
 
 type PacketDetectionInfo struct {
@@ -68,17 +107,61 @@ type PacketDetectionInfo struct {
 	UE_IPAddress    net.IP
 	SDFFilter       string
 	ApplicationID   string
+	// UE_IPv6PrefixLength makes UE_IPAddress the UE's IPv6 prefix of that
+	// length rather than a single address.
+	UE_IPv6PrefixLength uint8
+	// ChooseUE_IP asks the UP to allocate the UE IP address, of the family
+	// of UE_IPAddress, which is then the unspecified address. Both are
+	// replaced by what the UP allocated once it answers, so re-establishing
+	// the session keeps the address.
+	ChooseUE_IP bool
+	// UE_IPPool names the CP pool UE_IPAddress is allocated from. The
+	// address is allocated before the request is sent, and the pool is kept
+	// so that the address is released with the PDR.
+	UE_IPPool string
+	// LocalFTEID is replaced by the F-TEID the UP allocated once it
+	// answers a CHOOSE request, so re-establishing the session keeps it.
+	LocalFTEID *protocol.FTEID
+	// EthernetPacketFilters match frames on their MAC addresses, VLAN tags
+	// and EtherType.
+	EthernetPacketFilters []*protocol.EthernetPacketFilter
+	// PPPoE matches one PPPoE session, sent as the BBF PPPoE Session ID
+	// and PPP Protocol IEs.
+	PPPoE *protocol.PPPoEMatch
+	// FramedRoutes are the subnets routed behind the UE, sent as
+	// Framed-Route or Framed-IPv6-Route IEs, and FramedRouting the
+	// Framed-Routing IE, sent when it is not None.
+	FramedRoutes  []*protocol.FramedRoute
+	FramedRouting uint32
 }
 
 type FAR struct {
 	ID                   uint32
 	ApplyAction          uint8
 	ForwardingParameters *ForwardingParams
+	// BAR_ID is the BAR the FAR buffers with, or nil.
+	BAR_ID *uint8
+	// DuplicatingParameters are where the FAR copies packets to when its
+	// apply action has DUPL.
+	DuplicatingParameters []*DuplicatingParams
 }
 
 type ForwardingParams struct {
 	DestinationInterface uint8
 	NetworkInstance      string
+	OuterHeaderCreation  *protocol.OuterHeaderCreation
+	// PPPoE is the session to encapsulate packets into, sent as a BBF
+	// Outer Header Creation, or nil.
+	PPPoE *protocol.PPPoESession
+	// L2TP is the session to forward PPP frames into towards the LNS, sent
+	// as a BBF Outer Header Creation and L2TP Tunnel Endpoint, or nil.
+	L2TP *protocol.L2TPSession
+}
+
+type DuplicatingParams struct {
+	DestinationInterface uint8
+	OuterHeaderCreation  *protocol.OuterHeaderCreation
+	ForwardingPolicy     string
 }
 
 type QER struct {
@@ -90,6 +173,12 @@ type QER struct {
 	GBR_DL     uint64
 }
 
+type BAR struct {
+	ID                             uint8
+	DownlinkDataNotificationDelay  time.Duration
+	SuggestedBufferingPacketsCount uint8
+}
+
 type URR struct {
 	ID                uint32
 	MeasurementMethod uint8
@@ -98,6 +187,108 @@ type URR struct {
 	TimeThreshold     uint32
 }
 
+func (s *Session) pdrList() []*PDR {
+	pdrs := make([]*PDR, 0, len(s.PDRs))
+	for _, pdr := range s.PDRs {
+		pdrs = append(pdrs, pdr)
+	}
+	sort.Slice(pdrs, func(i, j int) bool { return pdrs[i].ID < pdrs[j].ID })
+	return pdrs
+}
+
+func (s *Session) farList() []*FAR {
+	fars := make([]*FAR, 0, len(s.FARs))
+	for _, far := range s.FARs {
+		fars = append(fars, far)
+	}
+	sort.Slice(fars, func(i, j int) bool { return fars[i].ID < fars[j].ID })
+	return fars
+}
+
+func (s *Session) qerList() []*QER {
+	qers := make([]*QER, 0, len(s.QERs))
+	for _, qer := range s.QERs {
+		qers = append(qers, qer)
+	}
+	sort.Slice(qers, func(i, j int) bool { return qers[i].ID < qers[j].ID })
+	return qers
+}
+
+func (s *Session) urrList() []*URR {
+	urrs := make([]*URR, 0, len(s.URRs))
+	for _, urr := range s.URRs {
+		urrs = append(urrs, urr)
+	}
+	sort.Slice(urrs, func(i, j int) bool { return urrs[i].ID < urrs[j].ID })
+	return urrs
+}
+
+// clone deep copies the session through its JSON encoding, which is also how
+// the stores keep it.
+func (s *Session) clone() (*Session, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("marshal session: %w", err)
+	}
+	var c Session
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("unmarshal session: %w", err)
+	}
+	return &c, nil
+}
+
+// RuleHash must stay in sync with the UP's Session.RuleHash so that audits
+// compare like with like.
+func (s *Session) RuleHash() string {
+	var d protocol.RuleDigest
+	for _, pdr := range s.PDRs {
+		d.AddPDR(pdr.ID, pdr.Precedence, pdr.FAR_ID, pdr.QER_IDs, pdr.URR_IDs)
+		d.AddPDI(pdr.ID, pdr.PDI.digest())
+	}
+	for _, far := range s.FARs {
+		d.AddFAR(far.ID, far.ApplyAction)
+		if fp := far.ForwardingParameters; fp != nil {
+			d.AddForwarding(far.ID, fp.DestinationInterface, fp.NetworkInstance, fp.OuterHeaderCreation, fp.PPPoE, fp.L2TP)
+		}
+		for _, dp := range far.DuplicatingParameters {
+			d.AddDuplicating(far.ID, dp.DestinationInterface, dp.OuterHeaderCreation, dp.ForwardingPolicy)
+		}
+	}
+	for _, qer := range s.QERs {
+		d.AddQER(qer.ID, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
+	}
+	for _, urr := range s.URRs {
+		d.AddURR(urr.ID, urr.MeasurementMethod, urr.ReportingTriggers, urr.VolumeThreshold, urr.TimeThreshold)
+	}
+	return d.Sum()
+}
+
+// digest returns the PDI as it is sent to the UP, for RuleHash. A nil PDI
+// sends no IEs, which the UP reads as an empty PDI.
+func (pdi *PacketDetectionInfo) digest() protocol.DigestPDI {
+	if pdi == nil {
+		return protocol.DigestPDI{}
+	}
+
+	d := protocol.DigestPDI{
+		SourceInterface:       pdi.SourceInterface,
+		NetworkInstance:       pdi.NetworkInstance,
+		ApplicationID:         pdi.ApplicationID,
+		LocalFTEID:            pdi.LocalFTEID,
+		EthernetPacketFilters: pdi.EthernetPacketFilters,
+		PPPoE:                 pdi.PPPoE,
+		FramedRoutes:          pdi.FramedRoutes,
+		FramedRouting:         pdi.FramedRouting,
+	}
+	if pdi.UE_IPAddress != nil {
+		d.UEIPAddress = ueIPAddress(pdi)
+	}
+	if pdi.SDFFilter != "" {
+		d.SDFFilter = protocol.NewSDFFilterIE(pdi.SDFFilter).Value
+	}
+	return d
+}
+
 func NewCPFunction(cfg *Config, store NorthboundStore) (*CPFunction, error) {
 	transportCfg := &protocol.TransportConfig{
 		LocalAddr: cfg.ListenAddr,
@@ -121,19 +312,196 @@ func NewCPFunction(cfg *Config, store NorthboundStore) (*CPFunction, error) {
 		sessions:     make(map[uint64]*Session),
 		nextSEID:     1,
 		store:        store,
+		ipam:         newIPAM(cfg.IPBindingLifetime),
+		reports:      make(chan func(), reportQueueLen),
 		ctx:          ctx,
 		cancel:       cancel,
 	}
 
+	if err := cp.restoreState(); err != nil {
+		transport.Close()
+		cancel()
+		return nil, fmt.Errorf("restore state: %w", err)
+	}
+
 	cp.registerHandlers()
 
 	return cp, nil
 }
 
+func (cp *CPFunction) restoreState() error {
+	if cp.store == nil {
+		return nil
+	}
+
+	nextSEID, err := cp.store.GetNextSEID()
+	if err != nil {
+		return fmt.Errorf("get next SEID: %w", err)
+	}
+
+	seids, err := cp.store.ListSessions()
+	if err != nil {
+		return fmt.Errorf("list sessions: %w", err)
+	}
+
+	sessions := make(map[uint64]*Session, len(seids))
+	for _, seid := range seids {
+		session, err := cp.store.GetSession(seid)
+		if err != nil {
+			return fmt.Errorf("get session %d: %w", seid, err)
+		}
+		sessions[seid] = session
+
+		if seid >= nextSEID {
+			nextSEID = seid + 1
+		}
+	}
+
+	storedAssociations, err := cp.store.ListAssociations()
+	if err != nil {
+		return fmt.Errorf("list associations: %w", err)
+	}
+
+	associations := make(map[string]*Association, len(storedAssociations))
+	for _, assoc := range storedAssociations {
+		associations[string(assoc.NodeID)] = assoc
+	}
+
+	pools, err := cp.store.ListIPPools()
+	if err != nil {
+		return fmt.Errorf("list IP pools: %w", err)
+	}
+	bindings, err := cp.store.ListIPBindings()
+	if err != nil {
+		return fmt.Errorf("list IP bindings: %w", err)
+	}
+	cp.ipam.restore(pools, bindings, sessions)
+
+	cp.mu.Lock()
+	cp.sessions = sessions
+	cp.associations = associations
+	if nextSEID > cp.nextSEID {
+		cp.nextSEID = nextSEID
+	}
+	cp.mu.Unlock()
+
+	if len(sessions) > 0 || len(associations) > 0 {
+		fmt.Printf("Restored %d sessions and %d associations from store (next SEID: %d)\n",
+			len(sessions), len(associations), nextSEID)
+	}
+
+	return nil
+}
+
+// SetElector makes the CP take part in leader election. Only the leader sends
+// PFCP requests; a standby takes over the stored associations and sessions
+// when it is elected. It must be called before Start.
+func (cp *CPFunction) SetElector(elector Elector) {
+	cp.elector = elector
+}
+
+// SetUsageHandler replaces the default handler, which logs Usage Reports.
+func (cp *CPFunction) SetUsageHandler(handler UsageHandler) {
+	cp.usageHandler = handler
+}
+
+func (cp *CPFunction) reportUsage(seid uint64, reports []*protocol.UsageReport) {
+	if len(reports) == 0 {
+		return
+	}
+	cp.queueReport(func() { cp.deliverUsage(seid, reports) })
+}
+
+func (cp *CPFunction) deliverUsage(seid uint64, reports []*protocol.UsageReport) {
+	if cp.usageHandler != nil {
+		cp.usageHandler(seid, reports)
+		return
+	}
+
+	for _, r := range reports {
+		fmt.Printf("Usage report for session %d URR %d (trigger=0x%04x): UL %d bytes/%d packets, DL %d bytes/%d packets over %ds\n",
+			seid, r.URRID, r.Trigger, r.UplinkVolume, r.UplinkPackets, r.DownlinkVolume, r.DownlinkPackets, r.Duration)
+	}
+}
+
+// SetDownlinkDataHandler replaces the default handler, which logs Downlink
+// Data Reports.
+func (cp *CPFunction) SetDownlinkDataHandler(handler DownlinkDataHandler) {
+	cp.dlDataHandler = handler
+}
+
+func (cp *CPFunction) reportDownlinkData(seid uint64, pdrIDs []uint16) {
+	cp.queueReport(func() {
+		if cp.dlDataHandler != nil {
+			cp.dlDataHandler(seid, pdrIDs)
+			return
+		}
+
+		fmt.Printf("Downlink data buffered for session %d (PDRs %v)\n", seid, pdrIDs)
+	})
+}
+
+// reportQueueLen is the number of reports that wait for the handlers before
+// further ones are dropped.
+const reportQueueLen = 1024
+
+// queueReport hands a report to reportLoop, so that handlers making PFCP
+// requests do not hold up the receive loop that reads their responses.
+func (cp *CPFunction) queueReport(deliver func()) {
+	select {
+	case cp.reports <- deliver:
+		return
+	default:
+	}
+
+	cp.reportMu.Lock()
+	cp.reportDrops++
+	drops := cp.reportDrops
+	cp.reportMu.Unlock()
+	if drops&(drops-1) == 0 {
+		fmt.Printf("Report handlers are behind, %d reports dropped\n", drops)
+	}
+}
+
+func (cp *CPFunction) reportLoop() {
+	defer cp.wg.Done()
+
+	for {
+		select {
+		case <-cp.ctx.Done():
+			return
+		case deliver := <-cp.reports:
+			deliver()
+		}
+	}
+}
+
+func (cp *CPFunction) IsActive() bool {
+	return cp.elector == nil || cp.elector.IsLeader()
+}
+
 func (cp *CPFunction) Start(ctx context.Context) error {
+	if cp.elector != nil {
+		cp.wg.Add(1)
+		go cp.leadershipLoop(cp.elector.Run(cp.ctx))
+	}
+
 	cp.wg.Add(1)
 	go cp.heartbeatLoop()
 
+	cp.wg.Add(1)
+	go cp.reportLoop()
+
+	if cp.auditClient != nil && cp.config.AuditInterval > 0 {
+		cp.wg.Add(1)
+		go cp.auditLoop()
+	}
+
+	if cp.puntClient != nil {
+		cp.wg.Add(1)
+		go cp.puntLoop()
+	}
+
 	<-ctx.Done()
 	return cp.Stop()
 }
@@ -152,6 +520,26 @@ func (cp *CPFunction) registerHandlers() {
 	cp.transport.RegisterHandler(protocol.MsgTypeSessionReportRequest, cp.handleSessionReportRequest)
 }
 
+func (cp *CPFunction) leadershipLoop(changes <-chan bool) {
+	defer cp.wg.Done()
+
+	for leader := range changes {
+		if !leader {
+			fmt.Println("Control plane is standby")
+			continue
+		}
+
+		fmt.Println("Control plane elected active, taking over associations and sessions")
+
+		if err := cp.restoreState(); err != nil {
+			fmt.Printf("Failed to take over state: %v\n", err)
+			continue
+		}
+
+		cp.sendHeartbeats()
+	}
+}
+
 func (cp *CPFunction) heartbeatLoop() {
 	defer cp.wg.Done()
 
@@ -169,6 +557,10 @@ func (cp *CPFunction) heartbeatLoop() {
 }
 
 func (cp *CPFunction) sendHeartbeats() {
+	if !cp.IsActive() {
+		return
+	}
+
 	cp.mu.RLock()
 	associations := make([]*Association, 0, len(cp.associations))
 	for _, assoc := range cp.associations {
@@ -189,7 +581,17 @@ func (cp *CPFunction) sendHeartbeats() {
 	}
 }
 
-func (cp *CPFunction) CreateSession(nodeID string, pdrs []*PDR, fars []*FAR, qers []*QER, urrs []*URR) (uint64, error) {
+// CreateSession establishes a session on the UP. PDRs naming an IP pool are
+// given an address from it first, bound to subscriberID if it is not empty.
+func (cp *CPFunction) CreateSession(nodeID string, pdrs []*PDR, fars []*FAR, qers []*QER, urrs []*URR, bar *BAR, subscriberID string) (uint64, error) {
+	if !cp.IsActive() {
+		return 0, fmt.Errorf("control plane is standby")
+	}
+
+	if err := validatePDRs(pdrs); err != nil {
+		return 0, err
+	}
+
 	cp.mu.RLock()
 	assoc, ok := cp.associations[nodeID]
 	cp.mu.RUnlock()
@@ -198,80 +600,169 @@ func (cp *CPFunction) CreateSession(nodeID string, pdrs []*PDR, fars []*FAR, qer
 		return 0, fmt.Errorf("no association with node %s", nodeID)
 	}
 
-	seid := cp.allocSEID()
+	seid, err := cp.allocSEID()
+	if err != nil {
+		return 0, fmt.Errorf("allocate SEID: %w", err)
+	}
+
+	session := &Session{
+		LocalSEID:    seid,
+		NodeID:       nodeID,
+		PDRs:         make(map[uint16]*PDR),
+		FARs:         make(map[uint32]*FAR),
+		QERs:         make(map[uint32]*QER),
+		URRs:         make(map[uint32]*URR),
+		BAR:          bar,
+		SubscriberID: subscriberID,
+		CreatedAt:    time.Now(),
+	}
+
+	allocated, err := cp.allocateUEIPs(session, pdrs)
+	if err != nil {
+		return 0, fmt.Errorf("allocate UE IP address: %w", err)
+	}
+
+	for _, pdr := range pdrs {
+		session.PDRs[pdr.ID] = pdr
+	}
+	for _, far := range fars {
+		session.FARs[far.ID] = far
+	}
+	for _, qer := range qers {
+		session.QERs[qer.ID] = qer
+	}
+	for _, urr := range urrs {
+		session.URRs[urr.ID] = urr
+	}
+
+	remoteSEID, resp, err := cp.establishSession(assoc, session)
+	if err != nil {
+		cp.storeIPBindings(cp.releaseUEIPs(allocated))
+		return 0, err
+	}
+	applyCreatedPDRs(session, resp)
+	session.RemoteSEID = remoteSEID
+
+	cp.mu.Lock()
+	cp.sessions[seid] = session
+	cp.mu.Unlock()
+
+	// A session the store does not know would be lost on failover, so it
+	// is torn down again.
+	if err := cp.persistSession(seid, session); err != nil {
+		if delErr := cp.DeleteSession(seid); delErr != nil {
+			fmt.Printf("Failed to delete unpersisted session %d: %v\n", seid, delErr)
+		}
+		return 0, err
+	}
+
+	return seid, nil
+}
+
+// establishSession sends a Session Establishment Request for the rules in
+// session and returns the SEID allocated by the UP, with the response for
+// applyCreatedPDRs. The session must not change while it runs, so it is
+// either not yet in cp.sessions or a snapshot.
+func (cp *CPFunction) establishSession(assoc *Association, session *Session) (uint64, *protocol.Message, error) {
+	createPDRs, err := cp.marshalPDRs(protocol.IETypeCreatePDR, session.pdrList())
+	if err != nil {
+		return 0, nil, fmt.Errorf("marshal PDRs: %w", err)
+	}
 
-	createPDRs, err := cp.marshalPDRs(pdrs)
+	createFARs, err := cp.marshalFARs(protocol.IETypeCreateFAR, session.farList())
 	if err != nil {
-		return 0, fmt.Errorf("marshal PDRs: %w", err)
+		return 0, nil, fmt.Errorf("marshal FARs: %w", err)
 	}
 
-	createFARs, err := cp.marshalFARs(fars)
+	createQERs, err := cp.marshalQERs(protocol.IETypeCreateQER, session.qerList())
 	if err != nil {
-		return 0, fmt.Errorf("marshal FARs: %w", err)
+		return 0, nil, fmt.Errorf("marshal QERs: %w", err)
 	}
 
-	createQERs, err := cp.marshalQERs(qers)
+	createURRs, err := cp.marshalURRs(protocol.IETypeCreateURR, session.urrList())
 	if err != nil {
-		return 0, fmt.Errorf("marshal QERs: %w", err)
+		return 0, nil, fmt.Errorf("marshal URRs: %w", err)
 	}
 
-	createURRs, err := cp.marshalURRs(urrs)
+	createBAR, err := cp.marshalBAR(protocol.IETypeCreateBAR, session.BAR)
 	if err != nil {
-		return 0, fmt.Errorf("marshal URRs: %w", err)
+		return 0, nil, fmt.Errorf("marshal BAR: %w", err)
 	}
 
-	req := protocol.NewSessionEstablishmentRequest(0, 0, createPDRs, createFARs, createQERs, createURRs)
+	req := protocol.NewSessionEstablishmentRequest(0, 0, cp.nodeID, session.LocalSEID, createPDRs, createFARs, createQERs, createURRs, createBAR)
 	resp, err := cp.transport.SendRequest(req, assoc.RemoteAddr, cp.config.RetransmitT1, cp.config.RetransmitN1)
 	if err != nil {
-		return 0, fmt.Errorf("send request: %w", err)
+		return 0, nil, fmt.Errorf("send request: %w", err)
 	}
 
 	causeIE := resp.FindIE(protocol.IETypeCause)
 	if causeIE == nil {
-		return 0, fmt.Errorf("no cause IE in response")
+		return 0, nil, fmt.Errorf("no cause IE in response")
 	}
 
 	cause, err := causeIE.GetCause()
 	if err != nil || cause != protocol.CauseRequestAccepted {
-		return 0, fmt.Errorf("session establishment rejected: cause=%d", cause)
+		return 0, nil, fmt.Errorf("session establishment rejected: %s", rejection(cause, resp))
 	}
 
-	session := &Session{
-		LocalSEID:  seid,
-		RemoteSEID: resp.Header.SEID,
-		NodeID:     nodeID,
-		PDRs:       make(map[uint16]*PDR),
-		FARs:       make(map[uint32]*FAR),
-		QERs:       make(map[uint32]*QER),
-		URRs:       make(map[uint32]*URR),
-		CreatedAt:  time.Now(),
+	fseidIE := resp.FindIE(protocol.IETypeFSEID)
+	if fseidIE == nil {
+		return 0, nil, fmt.Errorf("no F-SEID IE in response")
 	}
 
-	for _, pdr := range pdrs {
-		session.PDRs[pdr.ID] = pdr
-	}
-	for _, far := range fars {
-		session.FARs[far.ID] = far
-	}
-	for _, qer := range qers {
-		session.QERs[qer.ID] = qer
-	}
-	for _, urr := range urrs {
-		session.URRs[urr.ID] = urr
+	remoteSEID, _, err := fseidIE.GetFSEID()
+	if err != nil {
+		return 0, nil, err
 	}
 
-	cp.mu.Lock()
-	cp.sessions[seid] = session
-	cp.mu.Unlock()
+	return remoteSEID, resp, nil
+}
 
-	if cp.store != nil {
-		cp.store.StoreSession(seid, session)
+// applyCreatedPDRs records the F-TEIDs and UE IP addresses the UP allocated
+// for the session's PDRs.
+func applyCreatedPDRs(session *Session, resp *protocol.Message) {
+	for _, ie := range resp.FindAllIEs(protocol.IETypeCreatedPDR) {
+		created, err := protocol.ParseCreatedPDR(ie)
+		if err != nil {
+			fmt.Printf("Ignoring invalid Created PDR: %v\n", err)
+			continue
+		}
+		pdr, ok := session.PDRs[created.PDRID]
+		if !ok || pdr.PDI == nil {
+			continue
+		}
+		if created.LocalFTEID != nil {
+			pdr.PDI.LocalFTEID = created.LocalFTEID
+		}
+		if ue := created.UEIPAddress; ue != nil && (ue.IPv4 != nil || ue.IPv6 != nil) {
+			pdr.PDI.ChooseUE_IP = false
+			if ue.IPv4 != nil {
+				pdr.PDI.UE_IPAddress, pdr.PDI.UE_IPv6PrefixLength = ue.IPv4, 0
+			} else {
+				pdr.PDI.UE_IPAddress, pdr.PDI.UE_IPv6PrefixLength = ue.IPv6, ue.IPv6PrefixLength
+			}
+		}
 	}
+}
 
-	return seid, nil
+// ueIPAddress is the UE IP Address IE of a PDI with a UE IP address.
+func ueIPAddress(pdi *PacketDetectionInfo) *protocol.UEIPAddress {
+	isV6 := pdi.UE_IPAddress.To4() == nil
+	switch {
+	case pdi.ChooseUE_IP:
+		return &protocol.UEIPAddress{ChooseV4: !isV6, ChooseV6: isV6}
+	case isV6:
+		return &protocol.UEIPAddress{IPv6: pdi.UE_IPAddress, IPv6PrefixLength: pdi.UE_IPv6PrefixLength}
+	default:
+		return &protocol.UEIPAddress{IPv4: pdi.UE_IPAddress}
+	}
 }
 
 func (cp *CPFunction) DeleteSession(seid uint64) error {
+	if !cp.IsActive() {
+		return fmt.Errorf("control plane is standby")
+	}
+
 	cp.mu.RLock()
 	session, ok := cp.sessions[seid]
 	cp.mu.RUnlock()
@@ -288,7 +779,189 @@ func (cp *CPFunction) DeleteSession(seid uint64) error {
 		return fmt.Errorf("no association with node %s", session.NodeID)
 	}
 
-	req := protocol.NewSessionDeletionRequest(0, session.RemoteSEID)
+	reports, err := cp.deleteRemoteSession(assoc, session.RemoteSEID)
+	if err != nil {
+		return err
+	}
+	cp.reportUsage(seid, reports)
+
+	cp.mu.Lock()
+	delete(cp.sessions, seid)
+	released := cp.releaseUEIPs(session.poolAddresses())
+	cp.mu.Unlock()
+
+	cp.storeIPBindings(released)
+
+	if cp.store != nil {
+		if err := cp.store.DeleteSession(seid); err != nil {
+			fmt.Printf("Failed to remove session %d from store: %v\n", seid, err)
+		}
+	}
+
+	return nil
+}
+
+// SessionModification lists the rule changes for ModifySession. Rules whose
+// ID already exists in the session are updated, others are created.
+type SessionModification struct {
+	PDRs       []*PDR
+	FARs       []*FAR
+	QERs       []*QER
+	URRs       []*URR
+	RemovePDRs []uint16
+	RemoveFARs []uint32
+	RemoveQERs []uint32
+	RemoveURRs []uint32
+	// BAR creates the session's BAR, or updates it if it has the same ID.
+	BAR       *BAR
+	RemoveBAR bool
+}
+
+// validatePDRs rejects PDRs whose SDF filter is not a valid IPFilterRule, or
+// that both name an IP pool and ask the UP to CHOOSE, before anything is sent
+// to the UP.
+func validatePDRs(pdrs []*PDR) error {
+	for _, pdr := range pdrs {
+		if pdr.PDI == nil {
+			continue
+		}
+		if pdr.PDI.UE_IPPool != "" && pdr.PDI.ChooseUE_IP {
+			return fmt.Errorf("PDR %d: an IP pool and CHOOSE are exclusive", pdr.ID)
+		}
+		if pdr.PDI.SDFFilter == "" {
+			continue
+		}
+		if _, err := ipfilter.Parse(pdr.PDI.SDFFilter); err != nil {
+			return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+		}
+	}
+	return nil
+}
+
+func (cp *CPFunction) ModifySession(seid uint64, mod *SessionModification) error {
+	if !cp.IsActive() {
+		return fmt.Errorf("control plane is standby")
+	}
+
+	if err := validatePDRs(mod.PDRs); err != nil {
+		return err
+	}
+
+	cp.mu.RLock()
+	session, ok := cp.sessions[seid]
+	if !ok {
+		cp.mu.RUnlock()
+		return fmt.Errorf("session %d not found", seid)
+	}
+
+	assoc, ok := cp.associations[session.NodeID]
+	if !ok {
+		cp.mu.RUnlock()
+		return fmt.Errorf("no association with node %s", session.NodeID)
+	}
+
+	var createPDRs, updatePDRs []*PDR
+	for _, pdr := range mod.PDRs {
+		if _, exists := session.PDRs[pdr.ID]; exists {
+			updatePDRs = append(updatePDRs, pdr)
+		} else {
+			createPDRs = append(createPDRs, pdr)
+		}
+	}
+
+	var createFARs, updateFARs []*FAR
+	for _, far := range mod.FARs {
+		if _, exists := session.FARs[far.ID]; exists {
+			updateFARs = append(updateFARs, far)
+		} else {
+			createFARs = append(createFARs, far)
+		}
+	}
+
+	var createQERs, updateQERs []*QER
+	for _, qer := range mod.QERs {
+		if _, exists := session.QERs[qer.ID]; exists {
+			updateQERs = append(updateQERs, qer)
+		} else {
+			createQERs = append(createQERs, qer)
+		}
+	}
+
+	var createURRs, updateURRs []*URR
+	for _, urr := range mod.URRs {
+		if _, exists := session.URRs[urr.ID]; exists {
+			updateURRs = append(updateURRs, urr)
+		} else {
+			createURRs = append(createURRs, urr)
+		}
+	}
+
+	// A session has one BAR, so one with a new ID replaces it.
+	var removeBAR *uint8
+	barType := protocol.IETypeCreateBAR
+	if current := session.BAR; current != nil {
+		switch {
+		case mod.RemoveBAR || (mod.BAR != nil && mod.BAR.ID != current.ID):
+			removeBAR = &current.ID
+		case mod.BAR != nil:
+			barType = protocol.IETypeUpdateBAR
+		}
+	}
+	held := session.poolAddresses()
+	cp.mu.RUnlock()
+
+	allocated, err := cp.allocateUEIPs(session, mod.PDRs)
+	if err != nil {
+		return fmt.Errorf("allocate UE IP address: %w", err)
+	}
+	modified := false
+	defer func() {
+		if !modified {
+			cp.storeIPBindings(cp.releaseUEIPs(allocated))
+		}
+	}()
+
+	ies, err := cp.marshalRemovals(mod)
+	if err != nil {
+		return fmt.Errorf("marshal removals: %w", err)
+	}
+
+	if removeBAR != nil {
+		ie, err := protocol.NewGroupedIE(protocol.IETypeRemoveBAR, []*protocol.IE{protocol.NewBAR_ID_IE(*removeBAR)})
+		if err != nil {
+			return fmt.Errorf("marshal removals: %w", err)
+		}
+		ies = append(ies, ie)
+	}
+
+	marshalled := []struct {
+		ies []*protocol.IE
+		err error
+	}{}
+	add := func(ruleIEs []*protocol.IE, err error) {
+		marshalled = append(marshalled, struct {
+			ies []*protocol.IE
+			err error
+		}{ruleIEs, err})
+	}
+	add(cp.marshalPDRs(protocol.IETypeCreatePDR, createPDRs))
+	add(cp.marshalFARs(protocol.IETypeCreateFAR, createFARs))
+	add(cp.marshalQERs(protocol.IETypeCreateQER, createQERs))
+	add(cp.marshalURRs(protocol.IETypeCreateURR, createURRs))
+	add(cp.marshalPDRs(protocol.IETypeUpdatePDR, updatePDRs))
+	add(cp.marshalFARs(protocol.IETypeUpdateFAR, updateFARs))
+	add(cp.marshalQERs(protocol.IETypeUpdateQER, updateQERs))
+	add(cp.marshalURRs(protocol.IETypeUpdateURR, updateURRs))
+	add(cp.marshalBAR(barType, mod.BAR))
+
+	for _, m := range marshalled {
+		if m.err != nil {
+			return fmt.Errorf("marshal rules: %w", m.err)
+		}
+		ies = append(ies, m.ies...)
+	}
+
+	req := protocol.NewSessionModificationRequest(0, session.RemoteSEID, ies)
 	resp, err := cp.transport.SendRequest(req, assoc.RemoteAddr, cp.config.RetransmitT1, cp.config.RetransmitN1)
 	if err != nil {
 		return fmt.Errorf("send request: %w", err)
@@ -301,29 +974,178 @@ func (cp *CPFunction) DeleteSession(seid uint64) error {
 
 	cause, _ := causeIE.GetCause()
 	if cause != protocol.CauseRequestAccepted {
-		return fmt.Errorf("session deletion rejected: cause=%d", cause)
+		return fmt.Errorf("session modification rejected: %s", rejection(cause, resp))
 	}
 
 	cp.mu.Lock()
-	delete(cp.sessions, seid)
+	for _, id := range mod.RemovePDRs {
+		delete(session.PDRs, id)
+	}
+	for _, id := range mod.RemoveFARs {
+		delete(session.FARs, id)
+	}
+	for _, id := range mod.RemoveQERs {
+		delete(session.QERs, id)
+	}
+	for _, id := range mod.RemoveURRs {
+		delete(session.URRs, id)
+	}
+	for _, pdr := range mod.PDRs {
+		session.PDRs[pdr.ID] = pdr
+	}
+	for _, far := range mod.FARs {
+		session.FARs[far.ID] = far
+	}
+	for _, qer := range mod.QERs {
+		session.QERs[qer.ID] = qer
+	}
+	for _, urr := range mod.URRs {
+		session.URRs[urr.ID] = urr
+	}
+	if mod.RemoveBAR {
+		session.BAR = nil
+	}
+	if mod.BAR != nil {
+		session.BAR = mod.BAR
+	}
+	applyCreatedPDRs(session, resp)
+	released := cp.releaseUnusedUEIPs(session, held)
+	modified = true
 	cp.mu.Unlock()
 
-	if cp.store != nil {
-		cp.store.DeleteSession(seid)
+	cp.storeIPBindings(released)
+
+	// The UP already applies the modification, so it is kept, and a later
+	// modification or audit stores it again.
+	if err := cp.persistSession(seid, session); err != nil {
+		return fmt.Errorf("session %d modified on the UP: %w", seid, err)
 	}
 
 	return nil
 }
 
-func (cp *CPFunction) allocSEID() uint64 {
+// deleteRemoteSession returns the final Usage Reports the UP sent with the
+// deletion response.
+func (cp *CPFunction) deleteRemoteSession(assoc *Association, remoteSEID uint64) ([]*protocol.UsageReport, error) {
+	req := protocol.NewSessionDeletionRequest(0, remoteSEID)
+	resp, err := cp.transport.SendRequest(req, assoc.RemoteAddr, cp.config.RetransmitT1, cp.config.RetransmitN1)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	causeIE := resp.FindIE(protocol.IETypeCause)
+	if causeIE == nil {
+		return nil, fmt.Errorf("no cause IE in response")
+	}
+
+	cause, _ := causeIE.GetCause()
+	if cause != protocol.CauseRequestAccepted {
+		return nil, fmt.Errorf("session deletion rejected: cause=%d", cause)
+	}
+
+	return parseUsageReports(resp.FindAllIEs(protocol.IETypeUsageReportDeletion)), nil
+}
+
+func parseUsageReports(ies []*protocol.IE) []*protocol.UsageReport {
+	var reports []*protocol.UsageReport
+	for _, ie := range ies {
+		r, err := protocol.ParseUsageReport(ie)
+		if err != nil {
+			fmt.Printf("Ignoring invalid usage report: %v\n", err)
+			continue
+		}
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// persistSession stores a copy of the session taken under cp.mu, so that the
+// store never reads rules a concurrent modification is changing.
+func (cp *CPFunction) persistSession(seid uint64, session *Session) error {
+	if cp.store == nil {
+		return nil
+	}
+
+	cp.mu.RLock()
+	snapshot, err := session.clone()
+	cp.mu.RUnlock()
+
+	if err == nil {
+		err = cp.store.StoreSession(seid, snapshot)
+	}
+	if err != nil {
+		return fmt.Errorf("persist session %d: %w", seid, err)
+	}
+	return nil
+}
+
+func (cp *CPFunction) allocSEID() (uint64, error) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 	seid := cp.nextSEID
+
+	// Persist before handing the SEID out so a restart never reuses it.
+	if cp.store != nil {
+		if err := cp.store.StoreNextSEID(seid + 1); err != nil {
+			return 0, err
+		}
+	}
+
 	cp.nextSEID++
-	return seid
+	return seid, nil
 }
 
-func (cp *CPFunction) marshalPDRs(pdrs []*PDR) ([]*protocol.IE, error) {
+func (cp *CPFunction) marshalRemovals(mod *SessionModification) ([]*protocol.IE, error) {
+	var ies []*protocol.IE
+
+	for _, id := range mod.RemovePDRs {
+		ie, err := protocol.NewGroupedIE(protocol.IETypeRemovePDR, []*protocol.IE{protocol.NewPDR_ID_IE(id)})
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+
+	for _, id := range mod.RemoveFARs {
+		ie, err := protocol.NewGroupedIE(protocol.IETypeRemoveFAR, []*protocol.IE{protocol.NewFAR_ID_IE(id)})
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+
+	for _, id := range mod.RemoveQERs {
+		ie, err := protocol.NewGroupedIE(protocol.IETypeRemoveQER, []*protocol.IE{protocol.NewQER_ID_IE(id)})
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+
+	for _, id := range mod.RemoveURRs {
+		ie, err := protocol.NewGroupedIE(protocol.IETypeRemoveURR, []*protocol.IE{protocol.NewURR_ID_IE(id)})
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+
+	return ies, nil
+}
+
+// rejection describes the cause of a rejected session request, with the IE
+// the UP found at fault if it named one.
+func rejection(cause uint8, resp *protocol.Message) string {
+	desc := fmt.Sprintf("cause=%d", cause)
+	if ie := resp.FindIE(protocol.IETypeOffendingIE); ie != nil {
+		if ieType, err := ie.GetOffendingIE(); err == nil {
+			desc += fmt.Sprintf(" offending IE=%d", ieType)
+		}
+	}
+	return desc
+}
+
+func (cp *CPFunction) marshalPDRs(ieType uint16, pdrs []*PDR) ([]*protocol.IE, error) {
 	var ies []*protocol.IE
 	for _, pdr := range pdrs {
 		pdrIEs := []*protocol.IE{
@@ -336,9 +1158,16 @@ func (cp *CPFunction) marshalPDRs(pdrs []*PDR) ([]*protocol.IE, error) {
 				protocol.NewSourceInterfaceIE(pdr.PDI.SourceInterface),
 			}
 
+			if pdr.PDI.NetworkInstance != "" {
+				pdiIEs = append(pdiIEs, protocol.NewNetworkInstanceIE(pdr.PDI.NetworkInstance))
+			}
+
 			if pdr.PDI.UE_IPAddress != nil {
-				isV6 := pdr.PDI.UE_IPAddress.To4() == nil
-				pdiIEs = append(pdiIEs, protocol.NewUE_IPAddressIE(pdr.PDI.UE_IPAddress, isV6))
+				ueIE, err := protocol.NewUEIPAddressIE(ueIPAddress(pdr.PDI))
+				if err != nil {
+					return nil, fmt.Errorf("PDR %d: %w", pdr.ID, err)
+				}
+				pdiIEs = append(pdiIEs, ueIE)
 			}
 
 			if pdr.PDI.SDFFilter != "" {
@@ -349,6 +1178,34 @@ func (cp *CPFunction) marshalPDRs(pdrs []*PDR) ([]*protocol.IE, error) {
 				pdiIEs = append(pdiIEs, protocol.NewApplicationIDIE(pdr.PDI.ApplicationID))
 			}
 
+			for _, filter := range pdr.PDI.EthernetPacketFilters {
+				filterIE, err := protocol.NewEthernetPacketFilterIE(filter)
+				if err != nil {
+					return nil, fmt.Errorf("PDR %d: %w", pdr.ID, err)
+				}
+				pdiIEs = append(pdiIEs, filterIE)
+			}
+
+			if pppoe := pdr.PDI.PPPoE; pppoe != nil {
+				if pppoe.HasSessionID {
+					pdiIEs = append(pdiIEs, protocol.NewBBFPPPoESessionIDIE(pppoe.SessionID))
+				}
+				if pppoe.Protocol != nil {
+					pdiIEs = append(pdiIEs, protocol.NewPPPProtocolIE(pppoe.Protocol))
+				}
+			}
+
+			if pdr.PDI.LocalFTEID != nil {
+				pdiIEs = append(pdiIEs, protocol.NewFTEIDIE(pdr.PDI.LocalFTEID))
+			}
+
+			for _, route := range pdr.PDI.FramedRoutes {
+				pdiIEs = append(pdiIEs, protocol.NewFramedRouteIE(route))
+			}
+			if pdr.PDI.FramedRouting != protocol.FramedRoutingNone {
+				pdiIEs = append(pdiIEs, protocol.NewFramedRoutingIE(pdr.PDI.FramedRouting))
+			}
+
 			pdiIE, err := protocol.NewGroupedIE(protocol.IETypePDI, pdiIEs)
 			if err != nil {
 				return nil, err
@@ -356,6 +1213,14 @@ func (cp *CPFunction) marshalPDRs(pdrs []*PDR) ([]*protocol.IE, error) {
 			pdrIEs = append(pdrIEs, pdiIE)
 		}
 
+		if pdr.OuterHeaderRemoval != nil {
+			pdrIEs = append(pdrIEs, protocol.NewOuterHeaderRemovalIE(*pdr.OuterHeaderRemoval))
+		}
+
+		if pdr.BBFOuterHeaderRemoval != nil {
+			pdrIEs = append(pdrIEs, protocol.NewBBFOuterHeaderRemovalIE(*pdr.BBFOuterHeaderRemoval))
+		}
+
 		pdrIEs = append(pdrIEs, protocol.NewFAR_ID_IE(pdr.FAR_ID))
 
 		for _, qerID := range pdr.QER_IDs {
@@ -366,16 +1231,16 @@ func (cp *CPFunction) marshalPDRs(pdrs []*PDR) ([]*protocol.IE, error) {
 			pdrIEs = append(pdrIEs, protocol.NewURR_ID_IE(urrID))
 		}
 
-		createPDR, err := protocol.NewGroupedIE(protocol.IETypeCreatePDR, pdrIEs)
+		pdrIE, err := protocol.NewGroupedIE(ieType, pdrIEs)
 		if err != nil {
 			return nil, err
 		}
-		ies = append(ies, createPDR)
+		ies = append(ies, pdrIE)
 	}
 	return ies, nil
 }
 
-func (cp *CPFunction) marshalFARs(fars []*FAR) ([]*protocol.IE, error) {
+func (cp *CPFunction) marshalFARs(ieType uint16, fars []*FAR) ([]*protocol.IE, error) {
 	var ies []*protocol.IE
 	for _, far := range fars {
 		farIEs := []*protocol.IE{
@@ -388,50 +1253,163 @@ func (cp *CPFunction) marshalFARs(fars []*FAR) ([]*protocol.IE, error) {
 				protocol.NewDestinationInterfaceIE(far.ForwardingParameters.DestinationInterface),
 			}
 
-			fpIE, err := protocol.NewGroupedIE(protocol.IETypeForwardingParameters, fpIEs)
+			if ni := far.ForwardingParameters.NetworkInstance; ni != "" {
+				fpIEs = append(fpIEs, protocol.NewNetworkInstanceIE(ni))
+			}
+
+			if ohc := far.ForwardingParameters.OuterHeaderCreation; ohc != nil {
+				ohcIE, err := protocol.NewOuterHeaderCreationIE(ohc)
+				if err != nil {
+					return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+				}
+				fpIEs = append(fpIEs, ohcIE)
+			}
+
+			if session := far.ForwardingParameters.PPPoE; session != nil {
+				sessionIEs, err := protocol.NewPPPoESessionIEs(session)
+				if err != nil {
+					return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+				}
+				fpIEs = append(fpIEs, sessionIEs...)
+			}
+
+			if session := far.ForwardingParameters.L2TP; session != nil {
+				sessionIEs, err := protocol.NewL2TPSessionIEs(session)
+				if err != nil {
+					return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+				}
+				fpIEs = append(fpIEs, sessionIEs...)
+			}
+
+			fpType := protocol.IETypeForwardingParameters
+			if ieType == protocol.IETypeUpdateFAR {
+				fpType = protocol.IETypeUpdateForwardingParameters
+			}
+
+			fpIE, err := protocol.NewGroupedIE(fpType, fpIEs)
 			if err != nil {
 				return nil, err
 			}
 			farIEs = append(farIEs, fpIE)
 		}
 
-		createFAR, err := protocol.NewGroupedIE(protocol.IETypeCreateFAR, farIEs)
+		for _, dp := range far.DuplicatingParameters {
+			dpIE, err := marshalDuplicatingParams(ieType, dp)
+			if err != nil {
+				return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+			}
+			farIEs = append(farIEs, dpIE)
+		}
+
+		if far.BAR_ID != nil {
+			farIEs = append(farIEs, protocol.NewBAR_ID_IE(*far.BAR_ID))
+		}
+
+		farIE, err := protocol.NewGroupedIE(ieType, farIEs)
 		if err != nil {
 			return nil, err
 		}
-		ies = append(ies, createFAR)
+		ies = append(ies, farIE)
 	}
 	return ies, nil
 }
 
-func (cp *CPFunction) marshalQERs(qers []*QER) ([]*protocol.IE, error) {
+func marshalDuplicatingParams(farType uint16, dp *DuplicatingParams) (*protocol.IE, error) {
+	dpIEs := []*protocol.IE{
+		protocol.NewDestinationInterfaceIE(dp.DestinationInterface),
+	}
+
+	if dp.OuterHeaderCreation != nil {
+		ohcIE, err := protocol.NewOuterHeaderCreationIE(dp.OuterHeaderCreation)
+		if err != nil {
+			return nil, err
+		}
+		dpIEs = append(dpIEs, ohcIE)
+	}
+
+	if dp.ForwardingPolicy != "" {
+		policyIE, err := protocol.NewForwardingPolicyIE(dp.ForwardingPolicy)
+		if err != nil {
+			return nil, err
+		}
+		dpIEs = append(dpIEs, policyIE)
+	}
+
+	dpType := protocol.IETypeDuplicatingParameters
+	if farType == protocol.IETypeUpdateFAR {
+		dpType = protocol.IETypeUpdateDuplicatingParameters
+	}
+	return protocol.NewGroupedIE(dpType, dpIEs)
+}
+
+func (cp *CPFunction) marshalQERs(ieType uint16, qers []*QER) ([]*protocol.IE, error) {
 	var ies []*protocol.IE
 	for _, qer := range qers {
 		qerIEs := []*protocol.IE{
 			protocol.NewQER_ID_IE(qer.ID),
+			protocol.NewGateStatusIE(qer.GateStatus),
+		}
+
+		if qer.MBR_UL != 0 || qer.MBR_DL != 0 {
+			qerIEs = append(qerIEs, protocol.NewMBRIE(qer.MBR_UL, qer.MBR_DL))
+		}
+
+		if qer.GBR_UL != 0 || qer.GBR_DL != 0 {
+			qerIEs = append(qerIEs, protocol.NewGBRIE(qer.GBR_UL, qer.GBR_DL))
 		}
 
-		createQER, err := protocol.NewGroupedIE(protocol.IETypeCreateQER, qerIEs)
+		qerIE, err := protocol.NewGroupedIE(ieType, qerIEs)
 		if err != nil {
 			return nil, err
 		}
-		ies = append(ies, createQER)
+		ies = append(ies, qerIE)
 	}
 	return ies, nil
 }
 
-func (cp *CPFunction) marshalURRs(urrs []*URR) ([]*protocol.IE, error) {
+func (cp *CPFunction) marshalURRs(ieType uint16, urrs []*URR) ([]*protocol.IE, error) {
 	var ies []*protocol.IE
 	for _, urr := range urrs {
 		urrIEs := []*protocol.IE{
 			protocol.NewURR_ID_IE(urr.ID),
+			protocol.NewMeasurementMethodIE(urr.MeasurementMethod),
+			protocol.NewReportingTriggersIE(urr.ReportingTriggers),
+		}
+		if urr.VolumeThreshold > 0 {
+			urrIEs = append(urrIEs, protocol.NewVolumeThresholdIE(urr.VolumeThreshold))
+		}
+		if urr.TimeThreshold > 0 {
+			urrIEs = append(urrIEs, protocol.NewTimeThresholdIE(urr.TimeThreshold))
 		}
 
-		createURR, err := protocol.NewGroupedIE(protocol.IETypeCreateURR, urrIEs)
+		urrIE, err := protocol.NewGroupedIE(ieType, urrIEs)
 		if err != nil {
 			return nil, err
 		}
-		ies = append(ies, createURR)
+		ies = append(ies, urrIE)
 	}
 	return ies, nil
 }
+
+// marshalBAR returns no IE for a nil BAR.
+func (cp *CPFunction) marshalBAR(ieType uint16, bar *BAR) ([]*protocol.IE, error) {
+	if bar == nil {
+		return nil, nil
+	}
+
+	barIEs := []*protocol.IE{
+		protocol.NewBAR_ID_IE(bar.ID),
+	}
+	if bar.DownlinkDataNotificationDelay > 0 {
+		barIEs = append(barIEs, protocol.NewDownlinkDataNotificationDelayIE(bar.DownlinkDataNotificationDelay))
+	}
+	if bar.SuggestedBufferingPacketsCount > 0 {
+		barIEs = append(barIEs, protocol.NewSuggestedBufferingPacketsCountIE(bar.SuggestedBufferingPacketsCount))
+	}
+
+	barIE, err := protocol.NewGroupedIE(ieType, barIEs)
+	if err != nil {
+		return nil, err
+	}
+	return []*protocol.IE{barIE}, nil
+}
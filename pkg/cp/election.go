@@ -0,0 +1,194 @@
+package cp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Elector decides which of several CPFunctions sharing a store is active.
+// Run campaigns until ctx is cancelled and reports every leadership change
+// on the returned channel, which is closed when Run stops.
+type Elector interface {
+	Run(ctx context.Context) <-chan bool
+	IsLeader() bool
+}
+
+// KVElector implements Elector with a TTL lease stored under a single key.
+// The holder renews the lease with CompareAndSwap; any other candidate takes
+// over once the lease has expired or been released. A holder that cannot
+// renew stops leading when its last lease expires, before anyone else can
+// take over. Candidates time a lease on their own monotonic clock, from when
+// they first saw its revision, so the CPs' wall clocks need not agree.
+type KVElector struct {
+	backend  KVBackend
+	key      string
+	id       string
+	ttl      time.Duration
+	leader   bool
+	revision uint64
+	// renewed is when the lease last written was created; it expires ttl
+	// later.
+	renewed time.Time
+	// observed is the revision of another candidate's lease last read, and
+	// observedAt when it was first read. The lease has expired once it is
+	// still unchanged ttl later.
+	observed   uint64
+	observedAt time.Time
+	mu         sync.RWMutex
+}
+
+// leaderLease is the value of the key. ExpiresAt is on the holder's clock
+// and only informs operators.
+type leaderLease struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func NewKVElector(backend KVBackend, key, id string, ttl time.Duration) *KVElector {
+	return &KVElector{
+		backend: backend,
+		key:     key,
+		id:      id,
+		ttl:     ttl,
+	}
+}
+
+func (e *KVElector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader && time.Since(e.renewed) < e.ttl
+}
+
+func (e *KVElector) Run(ctx context.Context) <-chan bool {
+	changes := make(chan bool, 1)
+
+	go func() {
+		defer close(changes)
+
+		events := e.backend.Watch(ctx, e.key)
+
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+
+		e.campaign(changes)
+
+		for {
+			select {
+			case <-ctx.Done():
+				e.resign(changes)
+				return
+			case <-ticker.C:
+				// A watch the backend ended is set up again on the
+				// next tick, so that a failing backend is not hammered.
+				if events == nil {
+					events = e.backend.Watch(ctx, e.key)
+				}
+				e.campaign(changes)
+			case event, ok := <-events:
+				if !ok {
+					// A release may have been missed.
+					events = nil
+					e.campaign(changes)
+					continue
+				}
+				if event.Type == KVEventDelete {
+					e.campaign(changes)
+				}
+			}
+		}
+	}()
+
+	return changes
+}
+
+func (e *KVElector) campaign(changes chan bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	lease, err := json.Marshal(&leaderLease{
+		ID:        e.id,
+		ExpiresAt: now.Add(e.ttl),
+	})
+	if err != nil {
+		return
+	}
+
+	if e.leader {
+		revision, ok, err := e.backend.CompareAndSwap(e.key, e.revision, lease)
+		if err != nil {
+			// Keep the lease until it would have expired anyway; a
+			// transient backend error should not cause a failover.
+			fmt.Printf("Election: Failed to renew lease: %v\n", err)
+			if time.Since(e.renewed) >= e.ttl {
+				fmt.Printf("Election: Lease expired\n")
+				e.setLeader(false, 0, changes)
+			}
+			return
+		}
+		if !ok {
+			e.setLeader(false, 0, changes)
+			return
+		}
+		e.revision = revision
+		e.renewed = now
+		return
+	}
+
+	current, revision, err := e.backend.Get(e.key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		fmt.Printf("Election: Failed to read lease: %v\n", err)
+		return
+	}
+
+	if err == nil {
+		var holder leaderLease
+		if json.Unmarshal(current, &holder) == nil && holder.ID != e.id {
+			if revision != e.observed {
+				e.observed, e.observedAt = revision, now
+			}
+			if time.Since(e.observedAt) < e.ttl {
+				return
+			}
+		}
+	}
+
+	newRevision, ok, err := e.backend.CompareAndSwap(e.key, revision, lease)
+	if err != nil || !ok {
+		return
+	}
+
+	e.renewed = now
+	e.setLeader(true, newRevision, changes)
+}
+
+func (e *KVElector) resign(changes chan bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.leader {
+		return
+	}
+
+	if _, err := e.backend.CompareAndDelete(e.key, e.revision); err != nil {
+		fmt.Printf("Election: Failed to release lease: %v\n", err)
+	}
+
+	e.setLeader(false, 0, changes)
+}
+
+func (e *KVElector) setLeader(leader bool, revision uint64, changes chan bool) {
+	e.leader = leader
+	e.revision = revision
+
+	// Only the latest state matters to the consumer.
+	select {
+	case <-changes:
+	default:
+	}
+	changes <- leader
+}
@@ -0,0 +1,165 @@
+package cp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+const defaultEtcdRequestTimeout = 2 * time.Second
+
+// EtcdKV is a KVBackend on an etcd v3 cluster, which CPs on different hosts
+// share for the KVStore and KVElector. Revisions are etcd's: a key's
+// revision is its mod revision, and CompareAndSwap and CompareAndDelete are
+// transactions on it.
+type EtcdKV struct {
+	client  *clientv3.Client
+	timeout time.Duration
+}
+
+// NewEtcdKV connects to the etcd cluster at endpoints. Requests that take
+// longer than requestTimeout, 2s if zero, fail.
+func NewEtcdKV(endpoints []string, dialTimeout, requestTimeout time.Duration) (*EtcdKV, error) {
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   endpoints,
+		DialTimeout: dialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect to etcd: %w", err)
+	}
+
+	if requestTimeout == 0 {
+		requestTimeout = defaultEtcdRequestTimeout
+	}
+	return &EtcdKV{client: client, timeout: requestTimeout}, nil
+}
+
+func (e *EtcdKV) Close() error {
+	return e.client.Close()
+}
+
+func (e *EtcdKV) Get(key string) ([]byte, uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, ErrKeyNotFound
+	}
+	return resp.Kvs[0].Value, uint64(resp.Kvs[0].ModRevision), nil
+}
+
+func (e *EtcdKV) Put(key string, value []byte) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Put(ctx, key, string(value))
+	if err != nil {
+		return 0, err
+	}
+	return uint64(resp.Header.Revision), nil
+}
+
+func (e *EtcdKV) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, key)
+	return err
+}
+
+func (e *EtcdKV) List(prefix string) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		result[string(kv.Key)] = kv.Value
+	}
+	return result, nil
+}
+
+// revisionIs compares the key's mod revision; revision 0 requires the key
+// not to exist.
+func revisionIs(key string, revision uint64) clientv3.Cmp {
+	if revision == 0 {
+		return clientv3.Compare(clientv3.CreateRevision(key), "=", 0)
+	}
+	return clientv3.Compare(clientv3.ModRevision(key), "=", int64(revision))
+}
+
+func (e *EtcdKV) CompareAndSwap(key string, revision uint64, value []byte) (uint64, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Txn(ctx).
+		If(revisionIs(key, revision)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return 0, false, err
+	}
+	if !resp.Succeeded {
+		return 0, false, nil
+	}
+	return uint64(resp.Header.Revision), true, nil
+}
+
+func (e *EtcdKV) CompareAndDelete(key string, revision uint64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	resp, err := e.client.Txn(ctx).
+		If(revisionIs(key, revision)).
+		Then(clientv3.OpDelete(key)).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+// Watch streams the changes under prefix until ctx is cancelled. A watch
+// etcd cancels, e.g. after compaction, closes the channel like ctx does.
+func (e *EtcdKV) Watch(ctx context.Context, prefix string) <-chan KVEvent {
+	ch := make(chan KVEvent, 64)
+	watch := e.client.Watch(clientv3.WithRequireLeader(ctx), prefix, clientv3.WithPrefix())
+
+	go func() {
+		defer close(ch)
+		for resp := range watch {
+			if err := resp.Err(); err != nil {
+				fmt.Printf("Etcd: Watch on %s failed: %v\n", prefix, err)
+				return
+			}
+			for _, ev := range resp.Events {
+				event := KVEvent{
+					Type:     KVEventPut,
+					Key:      string(ev.Kv.Key),
+					Value:    ev.Kv.Value,
+					Revision: uint64(ev.Kv.ModRevision),
+				}
+				if ev.Type == clientv3.EventTypeDelete {
+					event.Type = KVEventDelete
+				}
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
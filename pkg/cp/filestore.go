@@ -0,0 +1,483 @@
+package cp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	fileStoreSnapshotFile = "snapshot.json"
+	fileStoreJournalFile  = "journal.log"
+
+	defaultCompactAfter = 1024
+)
+
+const (
+	journalOpStore    = "store"
+	journalOpDelete   = "delete"
+	journalOpNextSEID = "next-seid"
+	journalOpAssoc    = "store-association"
+	journalOpDelAssoc = "delete-association"
+	journalOpIPPool   = "store-ip-pool"
+	journalOpDelPool  = "delete-ip-pool"
+	journalOpBinding  = "store-ip-binding"
+)
+
+// FileStore is a NorthboundStore backed by an append-only journal in a
+// directory. Every change is appended and fsynced before it is applied, and
+// the journal is periodically compacted into a snapshot. Sessions are kept
+// marshalled, so the store never reads a Session after StoreSession returns.
+type FileStore struct {
+	dir          string
+	journal      *os.File
+	sessions     map[uint64]json.RawMessage
+	associations map[string]*Association
+	ipPools      map[string]*IPPool
+	ipBindings   map[string]*IPBinding
+	nextSEID     uint64
+	entries      int
+	compactAfter int
+	mu           sync.RWMutex
+}
+
+type journalEntry struct {
+	Op          string          `json:"op"`
+	SEID        uint64          `json:"seid,omitempty"`
+	Session     json.RawMessage `json:"session,omitempty"`
+	NodeID      string          `json:"node_id,omitempty"`
+	Association *Association    `json:"association,omitempty"`
+	Pool        string          `json:"pool,omitempty"`
+	IPPool      *IPPool         `json:"ip_pool,omitempty"`
+	IPBinding   *IPBinding      `json:"ip_binding,omitempty"`
+}
+
+type fileStoreSnapshot struct {
+	NextSEID     uint64                     `json:"next_seid"`
+	Sessions     map[uint64]json.RawMessage `json:"sessions"`
+	Associations map[string]*Association    `json:"associations"`
+	IPPools      map[string]*IPPool         `json:"ip_pools,omitempty"`
+	IPBindings   []*IPBinding               `json:"ip_bindings,omitempty"`
+}
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create store directory: %w", err)
+	}
+
+	f := &FileStore{
+		dir:          dir,
+		sessions:     make(map[uint64]json.RawMessage),
+		associations: make(map[string]*Association),
+		ipPools:      make(map[string]*IPPool),
+		ipBindings:   make(map[string]*IPBinding),
+		nextSEID:     1,
+		compactAfter: defaultCompactAfter,
+	}
+
+	if err := f.loadSnapshot(); err != nil {
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+
+	valid, err := f.replayJournal()
+	if err != nil {
+		return nil, fmt.Errorf("replay journal: %w", err)
+	}
+
+	journal, err := os.OpenFile(filepath.Join(dir, fileStoreJournalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	f.journal = journal
+
+	// Cut off a torn or corrupt tail, or every entry appended after it
+	// would be lost at the next replay.
+	if info, err := journal.Stat(); err == nil && info.Size() > valid {
+		fmt.Printf("FileStore: Truncating journal from %d to %d bytes\n", info.Size(), valid)
+		if err := journal.Truncate(valid); err != nil {
+			journal.Close()
+			return nil, fmt.Errorf("truncate journal: %w", err)
+		}
+	}
+
+	return f, nil
+}
+
+func (f *FileStore) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.journal.Close()
+}
+
+func (f *FileStore) StoreSession(seid uint64, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(&journalEntry{Op: journalOpStore, SEID: seid, Session: data}); err != nil {
+		return err
+	}
+	f.sessions[seid] = data
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) GetSession(seid uint64) (*Session, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	data, ok := f.sessions[seid]
+	if !ok {
+		return nil, fmt.Errorf("session %d not found", seid)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("unmarshal session %d: %w", seid, err)
+	}
+	return &session, nil
+}
+
+func (f *FileStore) DeleteSession(seid uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.sessions[seid]; !ok {
+		return nil
+	}
+
+	if err := f.append(&journalEntry{Op: journalOpDelete, SEID: seid}); err != nil {
+		return err
+	}
+	delete(f.sessions, seid)
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) ListSessions() ([]uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	seids := make([]uint64, 0, len(f.sessions))
+	for seid := range f.sessions {
+		seids = append(seids, seid)
+	}
+	return seids, nil
+}
+
+func (f *FileStore) StoreNextSEID(seid uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(&journalEntry{Op: journalOpNextSEID, SEID: seid}); err != nil {
+		return err
+	}
+	f.nextSEID = seid
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) GetNextSEID() (uint64, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.nextSEID, nil
+}
+
+func (f *FileStore) StoreAssociation(nodeID string, assoc *Association) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(&journalEntry{Op: journalOpAssoc, NodeID: nodeID, Association: assoc}); err != nil {
+		return err
+	}
+	f.associations[nodeID] = assoc
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) DeleteAssociation(nodeID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.associations[nodeID]; !ok {
+		return nil
+	}
+
+	if err := f.append(&journalEntry{Op: journalOpDelAssoc, NodeID: nodeID}); err != nil {
+		return err
+	}
+	delete(f.associations, nodeID)
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) ListAssociations() ([]*Association, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	associations := make([]*Association, 0, len(f.associations))
+	for _, assoc := range f.associations {
+		associations = append(associations, assoc)
+	}
+	return associations, nil
+}
+
+func (f *FileStore) StoreIPPool(pool *IPPool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(&journalEntry{Op: journalOpIPPool, IPPool: pool}); err != nil {
+		return err
+	}
+	f.ipPools[pool.Name] = pool
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) DeleteIPPool(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.ipPools[name]; !ok {
+		return nil
+	}
+
+	if err := f.append(&journalEntry{Op: journalOpDelPool, Pool: name}); err != nil {
+		return err
+	}
+	f.deleteIPPool(name)
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) deleteIPPool(name string) {
+	delete(f.ipPools, name)
+	for key, binding := range f.ipBindings {
+		if binding.Pool == name {
+			delete(f.ipBindings, key)
+		}
+	}
+}
+
+func (f *FileStore) ListIPPools() ([]*IPPool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	pools := make([]*IPPool, 0, len(f.ipPools))
+	for _, pool := range f.ipPools {
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func (f *FileStore) StoreIPBinding(binding *IPBinding) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.append(&journalEntry{Op: journalOpBinding, IPBinding: binding}); err != nil {
+		return err
+	}
+	f.setIPBinding(binding)
+
+	return f.maybeCompact()
+}
+
+func (f *FileStore) setIPBinding(binding *IPBinding) {
+	if binding.Subscriber == "" {
+		delete(f.ipBindings, ipBindingKey(binding))
+		return
+	}
+	f.ipBindings[ipBindingKey(binding)] = binding
+}
+
+func (f *FileStore) ListIPBindings() ([]*IPBinding, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	bindings := make([]*IPBinding, 0, len(f.ipBindings))
+	for _, binding := range f.ipBindings {
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+func (f *FileStore) append(entry *journalEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal journal entry: %w", err)
+	}
+
+	if _, err := f.journal.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+
+	if err := f.journal.Sync(); err != nil {
+		return fmt.Errorf("sync journal: %w", err)
+	}
+
+	f.entries++
+	return nil
+}
+
+func (f *FileStore) apply(entry *journalEntry) {
+	switch entry.Op {
+	case journalOpStore:
+		f.sessions[entry.SEID] = entry.Session
+	case journalOpDelete:
+		delete(f.sessions, entry.SEID)
+	case journalOpNextSEID:
+		f.nextSEID = entry.SEID
+	case journalOpAssoc:
+		f.associations[entry.NodeID] = entry.Association
+	case journalOpDelAssoc:
+		delete(f.associations, entry.NodeID)
+	case journalOpIPPool:
+		f.ipPools[entry.IPPool.Name] = entry.IPPool
+	case journalOpDelPool:
+		f.deleteIPPool(entry.Pool)
+	case journalOpBinding:
+		f.setIPBinding(entry.IPBinding)
+	}
+}
+
+func (f *FileStore) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(f.dir, fileStoreSnapshotFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap fileStoreSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	if snap.NextSEID > 0 {
+		f.nextSEID = snap.NextSEID
+	}
+	for seid, session := range snap.Sessions {
+		f.sessions[seid] = session
+	}
+	for nodeID, assoc := range snap.Associations {
+		f.associations[nodeID] = assoc
+	}
+	for name, pool := range snap.IPPools {
+		f.ipPools[name] = pool
+	}
+	for _, binding := range snap.IPBindings {
+		f.ipBindings[ipBindingKey(binding)] = binding
+	}
+
+	return nil
+}
+
+// replayJournal applies the journal and returns the length of its valid
+// part, which ends before the first entry that is torn or corrupt.
+func (f *FileStore) replayJournal() (int64, error) {
+	file, err := os.Open(filepath.Join(f.dir, fileStoreJournalFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// A torn final write from a crash is expected;
+				// everything before it has already been applied.
+				fmt.Printf("FileStore: Ignoring torn journal entry %d\n", f.entries+1)
+			}
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var entry journalEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			fmt.Printf("FileStore: Ignoring corrupt journal entry %d and everything after it: %v\n", f.entries+1, err)
+			return valid, nil
+		}
+		f.apply(&entry)
+		f.entries++
+		valid += int64(len(line))
+	}
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (f *FileStore) maybeCompact() error {
+	if f.entries < f.compactAfter {
+		return nil
+	}
+	return f.compact()
+}
+
+func (f *FileStore) compact() error {
+	bindings := make([]*IPBinding, 0, len(f.ipBindings))
+	for _, binding := range f.ipBindings {
+		bindings = append(bindings, binding)
+	}
+
+	data, err := json.Marshal(&fileStoreSnapshot{
+		NextSEID:     f.nextSEID,
+		Sessions:     f.sessions,
+		Associations: f.associations,
+		IPPools:      f.ipPools,
+		IPBindings:   bindings,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal snapshot: %w", err)
+	}
+
+	tmpPath := filepath.Join(f.dir, fileStoreSnapshotFile+".tmp")
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, filepath.Join(f.dir, fileStoreSnapshotFile)); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+
+	// The rename must be durable before the journal it replaces is gone.
+	if err := syncDir(f.dir); err != nil {
+		return fmt.Errorf("sync snapshot directory: %w", err)
+	}
+
+	if err := f.journal.Truncate(0); err != nil {
+		return fmt.Errorf("truncate journal: %w", err)
+	}
+
+	f.entries = 0
+	return nil
+}
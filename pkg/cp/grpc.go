@@ -3,9 +3,14 @@ package cp
 import (
 	"context"
 	"fmt"
+	"math"
 	"net"
+	"net/netip"
+	"strings"
+	"time"
 
 	pb "github.com/veesix-networks/pfcp-go/api/pfcp/v1"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
 )
 
 type GRPCServer struct {
@@ -18,75 +23,580 @@ func NewGRPCServer(cp *CPFunction) *GRPCServer {
 }
 
 func (s *GRPCServer) CreateSession(ctx context.Context, req *pb.CreateSessionRequest) (*pb.CreateSessionResponse, error) {
-	pdrs := make([]*PDR, len(req.Pdrs))
-	for i, pdr := range req.Pdrs {
-		var ueIP net.IP
-		if pdr.Pdi.UeIpAddress != "" {
-			ueIP = net.ParseIP(pdr.Pdi.UeIpAddress)
-		}
+	pdrs, err := pdrsFromProto(req.Pdrs)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+	fars, err := farsFromProto(req.Fars)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	bar, err := barFromProto(req.Bar)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	seid, err := s.cp.CreateSession(req.NodeId, pdrs, fars, qersFromProto(req.Qers), urrsFromProto(req.Urrs), bar, req.SubscriberId)
+	if err != nil {
+		return nil, fmt.Errorf("create session: %w", err)
+	}
+
+	fmt.Printf("gRPC: Session created SEID=%d for node %s\n", seid, req.NodeId)
+
+	return &pb.CreateSessionResponse{Seid: seid, CreatedPdrs: s.createdPDRsToProto(pdrs)}, nil
+}
+
+func (s *GRPCServer) ModifySession(ctx context.Context, req *pb.ModifySessionRequest) (*pb.ModifySessionResponse, error) {
+	pdrs, err := pdrsFromProto(req.Pdrs)
+	if err != nil {
+		return nil, fmt.Errorf("modify session: %w", err)
+	}
+	fars, err := farsFromProto(req.Fars)
+	if err != nil {
+		return nil, fmt.Errorf("modify session: %w", err)
+	}
+
+	bar, err := barFromProto(req.Bar)
+	if err != nil {
+		return nil, fmt.Errorf("modify session: %w", err)
+	}
 
+	mod := &SessionModification{
+		PDRs:       pdrs,
+		FARs:       fars,
+		QERs:       qersFromProto(req.Qers),
+		URRs:       urrsFromProto(req.Urrs),
+		RemoveFARs: req.RemoveFarIds,
+		RemoveQERs: req.RemoveQerIds,
+		RemoveURRs: req.RemoveUrrIds,
+		BAR:        bar,
+		RemoveBAR:  req.RemoveBar,
+	}
+	for _, id := range req.RemovePdrIds {
+		mod.RemovePDRs = append(mod.RemovePDRs, uint16(id))
+	}
+
+	if err := s.cp.ModifySession(req.Seid, mod); err != nil {
+		return nil, fmt.Errorf("modify session: %w", err)
+	}
+
+	fmt.Printf("gRPC: Session modified SEID=%d\n", req.Seid)
+
+	return &pb.ModifySessionResponse{Success: true, CreatedPdrs: s.createdPDRsToProto(pdrs)}, nil
+}
+
+func pdrsFromProto(in []*pb.PDR) ([]*PDR, error) {
+	pdrs := make([]*PDR, len(in))
+	for i, pdr := range in {
 		pdrs[i] = &PDR{
 			ID:         uint16(pdr.Id),
 			Precedence: pdr.Precedence,
-			PDI: &PacketDetectionInfo{
-				SourceInterface: uint8(pdr.Pdi.SourceInterface),
-				SDFFilter:       pdr.Pdi.SdfFilter,
-				UE_IPAddress:    ueIP,
-				NetworkInstance: pdr.Pdi.NetworkInstance,
-				ApplicationID:   pdr.Pdi.ApplicationId,
-			},
-			FAR_ID: pdr.FarId,
+			FAR_ID:     pdr.FarId,
+			QER_IDs:    pdr.QerIds,
+			URR_IDs:    pdr.UrrIds,
+		}
+
+		if pdr.OuterHeaderRemoval != nil {
+			description := uint8(pdr.OuterHeaderRemoval.Description)
+			pdrs[i].OuterHeaderRemoval = &description
+		}
+
+		if pdr.BbfOuterHeaderRemoval != nil {
+			if pdr.BbfOuterHeaderRemoval.Description > math.MaxUint8 {
+				return nil, fmt.Errorf("PDR %d: invalid BBF outer header removal %d", pdr.Id, pdr.BbfOuterHeaderRemoval.Description)
+			}
+			description := uint8(pdr.BbfOuterHeaderRemoval.Description)
+			pdrs[i].BBFOuterHeaderRemoval = &description
+		}
+
+		if pdr.Pdi != nil {
+			if pdr.Pdi.UeIpPool != "" && (pdr.Pdi.UeIpAddress != "" || pdr.Pdi.ChooseUeIp) {
+				return nil, fmt.Errorf("PDR %d: ue_ip_pool is exclusive with ue_ip_address and choose_ue_ip", pdr.Id)
+			}
+			ueIP, prefixLength, err := ueIPFromProto(pdr.Pdi.UeIpAddress, pdr.Pdi.ChooseUeIp)
+			if err != nil {
+				return nil, fmt.Errorf("PDR %d: %w", pdr.Id, err)
+			}
+
+			pdrs[i].PDI = &PacketDetectionInfo{
+				SourceInterface:     uint8(pdr.Pdi.SourceInterface),
+				SDFFilter:           pdr.Pdi.SdfFilter,
+				UE_IPAddress:        ueIP,
+				UE_IPv6PrefixLength: prefixLength,
+				ChooseUE_IP:         pdr.Pdi.ChooseUeIp,
+				UE_IPPool:           pdr.Pdi.UeIpPool,
+				NetworkInstance:     pdr.Pdi.NetworkInstance,
+				ApplicationID:       pdr.Pdi.ApplicationId,
+			}
+
+			if pdr.Pdi.LocalFteid != nil {
+				fteid, err := fteidFromProto(pdr.Pdi.LocalFteid)
+				if err != nil {
+					return nil, fmt.Errorf("PDR %d: %w", pdr.Id, err)
+				}
+				pdrs[i].PDI.LocalFTEID = fteid
+			}
+
+			for _, filter := range pdr.Pdi.EthernetPacketFilters {
+				f, err := ethernetPacketFilterFromProto(filter)
+				if err != nil {
+					return nil, fmt.Errorf("PDR %d: %w", pdr.Id, err)
+				}
+				pdrs[i].PDI.EthernetPacketFilters = append(pdrs[i].PDI.EthernetPacketFilters, f)
+			}
+
+			routes, err := framedRoutesFromProto(pdr.Pdi.FramedRoutes, pdr.Pdi.FramedIpv6Routes)
+			if err != nil {
+				return nil, fmt.Errorf("PDR %d: %w", pdr.Id, err)
+			}
+			if pdr.Pdi.FramedRouting > protocol.FramedRoutingSendAndListen {
+				return nil, fmt.Errorf("PDR %d: invalid framed routing %d", pdr.Id, pdr.Pdi.FramedRouting)
+			}
+			pdrs[i].PDI.FramedRoutes = routes
+			pdrs[i].PDI.FramedRouting = pdr.Pdi.FramedRouting
+
+			if pdr.Pdi.Pppoe != nil {
+				pppoe, err := pppoeMatchFromProto(pdr.Pdi.Pppoe)
+				if err != nil {
+					return nil, fmt.Errorf("PDR %d: %w", pdr.Id, err)
+				}
+				pdrs[i].PDI.PPPoE = pppoe
+			}
+		}
+	}
+	return pdrs, nil
+}
+
+// framedRoutesFromProto parses the Framed-Routes, which must be IPv4, and
+// Framed-IPv6-Routes.
+func framedRoutesFromProto(v4, v6 []string) ([]*protocol.FramedRoute, error) {
+	var routes []*protocol.FramedRoute
+	for _, in := range []struct {
+		routes []string
+		isV6   bool
+	}{{v4, false}, {v6, true}} {
+		for _, s := range in.routes {
+			route, err := protocol.ParseFramedRoute(s)
+			if err != nil {
+				return nil, err
+			}
+			if route.Prefix.Addr().Is6() != in.isV6 {
+				return nil, fmt.Errorf("framed route %q is of the other address family", s)
+			}
+			routes = append(routes, route)
+		}
+	}
+	return routes, nil
+}
+
+func pppoeMatchFromProto(in *pb.PPPoEMatch) (*protocol.PPPoEMatch, error) {
+	m := &protocol.PPPoEMatch{}
+	if in.SessionId != nil {
+		if *in.SessionId > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid PPPoE session ID %d", *in.SessionId)
+		}
+		m.SessionID, m.HasSessionID = uint16(*in.SessionId), true
+	}
+
+	if p := in.PppProtocol; p != nil {
+		m.Protocol = &protocol.PPPProtocol{Control: p.Control, Data: p.Data}
+		if p.Protocol != nil {
+			if *p.Protocol > math.MaxUint16 {
+				return nil, fmt.Errorf("invalid PPP protocol %d", *p.Protocol)
+			}
+			m.Protocol.Protocol, m.Protocol.HasProtocol = uint16(*p.Protocol), true
+		}
+	}
+	return m, nil
+}
+
+func ethernetPacketFilterFromProto(in *pb.EthernetPacketFilter) (*protocol.EthernetPacketFilter, error) {
+	if in.Ethertype > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid EtherType %d", in.Ethertype)
+	}
+
+	f := &protocol.EthernetPacketFilter{
+		Bidirectional: in.Bidirectional,
+		EtherType:     uint16(in.Ethertype),
+	}
+	if in.FilterId != nil {
+		f.FilterID, f.HasFilterID = *in.FilterId, true
+	}
+
+	for _, mac := range in.MacAddresses {
+		m := &protocol.MACAddress{}
+		for _, a := range []struct {
+			in  string
+			out *net.HardwareAddr
+		}{
+			{mac.Source, &m.Source},
+			{mac.Destination, &m.Destination},
+			{mac.UpperSource, &m.UpperSource},
+			{mac.UpperDestination, &m.UpperDestination},
+		} {
+			if a.in == "" {
+				continue
+			}
+			addr, err := net.ParseMAC(a.in)
+			if err != nil || len(addr) != 6 {
+				return nil, fmt.Errorf("invalid MAC address %q", a.in)
+			}
+			*a.out = addr
 		}
+		f.MACAddresses = append(f.MACAddresses, m)
 	}
 
-	fars := make([]*FAR, len(req.Fars))
-	for i, far := range req.Fars {
+	var err error
+	if f.CTag, err = vlanTagFromProto(in.CTag); err != nil {
+		return nil, fmt.Errorf("C-TAG: %w", err)
+	}
+	if f.STag, err = vlanTagFromProto(in.STag); err != nil {
+		return nil, fmt.Errorf("S-TAG: %w", err)
+	}
+
+	// Encoding the filter checks the VLAN fields and MAC address count.
+	if _, err := protocol.NewEthernetPacketFilterIE(f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func vlanTagFromProto(in *pb.VLANTag) (*protocol.VLANTag, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	t := &protocol.VLANTag{}
+	if in.Vid != nil {
+		if *in.Vid > 0x0fff {
+			return nil, fmt.Errorf("invalid VLAN ID %d", *in.Vid)
+		}
+		t.VID, t.HasVID = uint16(*in.Vid), true
+	}
+	if in.Pcp != nil {
+		if *in.Pcp > 7 {
+			return nil, fmt.Errorf("invalid PCP %d", *in.Pcp)
+		}
+		t.PCP, t.HasPCP = uint8(*in.Pcp), true
+	}
+	if in.Dei != nil {
+		t.DEI, t.HasDEI = *in.Dei, true
+	}
+	return t, nil
+}
+
+func fteidFromProto(in *pb.FTEID) (*protocol.FTEID, error) {
+	fteid := &protocol.FTEID{
+		TEID:        in.Teid,
+		Choose:      in.Choose,
+		ChooseID:    uint8(in.ChooseId),
+		HasChooseID: in.ChooseId != 0,
+	}
+
+	var err error
+	if fteid.IPv4, err = parseOptionalIP(in.Ipv4, false); err != nil {
+		return nil, err
+	}
+	if fteid.IPv6, err = parseOptionalIP(in.Ipv6, true); err != nil {
+		return nil, err
+	}
+
+	if fteid.Choose {
+		if fteid.IPv4 != nil {
+			fteid.IPv4 = net.IPv4zero
+		}
+		if fteid.IPv6 != nil {
+			fteid.IPv6 = net.IPv6zero
+		}
+		if fteid.IPv4 == nil && fteid.IPv6 == nil {
+			fteid.IPv4 = net.IPv4zero
+		}
+		return fteid, nil
+	}
+
+	if fteid.IPv4 == nil && fteid.IPv6 == nil {
+		return nil, fmt.Errorf("F-TEID needs an address or choose")
+	}
+	return fteid, nil
+}
+
+func fteidToProto(in *protocol.FTEID) *pb.FTEID {
+	out := &pb.FTEID{
+		Teid:   in.TEID,
+		Choose: in.Choose,
+	}
+	if in.HasChooseID {
+		out.ChooseId = uint32(in.ChooseID)
+	}
+	if in.IPv4 != nil {
+		out.Ipv4 = in.IPv4.String()
+	}
+	if in.IPv6 != nil {
+		out.Ipv6 = in.IPv6.String()
+	}
+	return out
+}
+
+// ueIPFromProto parses a UE IP address, or IPv6 prefix. A CHOOSE request
+// gets the unspecified address of the family asked for, IPv4 by default.
+func ueIPFromProto(s string, choose bool) (net.IP, uint8, error) {
+	if s == "" {
+		if choose {
+			return net.IPv4zero, 0, nil
+		}
+		return nil, 0, nil
+	}
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil || !prefix.Addr().Is6() {
+			return nil, 0, fmt.Errorf("invalid UE IPv6 prefix %q", s)
+		}
+		if choose {
+			return net.IPv6zero, 0, nil
+		}
+		return net.IP(prefix.Addr().AsSlice()), uint8(prefix.Bits()), nil
+	}
+
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil:
+		return nil, 0, fmt.Errorf("invalid UE IP address %q", s)
+	case choose && ip.To4() != nil:
+		return net.IPv4zero, 0, nil
+	case choose:
+		return net.IPv6zero, 0, nil
+	}
+	return ip, 0, nil
+}
+
+// parseOptionalIP parses an address of the given family, or returns nil for
+// an empty string.
+func parseOptionalIP(s string, isV6 bool) (net.IP, error) {
+	if s == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil || (ip.To4() == nil) != isV6 {
+		return nil, fmt.Errorf("invalid address %q", s)
+	}
+	if !isV6 {
+		ip = ip.To4()
+	}
+	return ip, nil
+}
+
+// createdPDRsToProto returns the local F-TEIDs and UE IP addresses of the
+// PDRs, which hold those the UP chose once the request succeeded.
+func (s *GRPCServer) createdPDRsToProto(pdrs []*PDR) []*pb.CreatedPDR {
+	s.cp.mu.RLock()
+	defer s.cp.mu.RUnlock()
+
+	var created []*pb.CreatedPDR
+	for _, pdr := range pdrs {
+		if pdr.PDI == nil || (pdr.PDI.LocalFTEID == nil && pdr.PDI.UE_IPAddress == nil) {
+			continue
+		}
+		c := &pb.CreatedPDR{PdrId: uint32(pdr.ID)}
+		if pdr.PDI.LocalFTEID != nil {
+			c.LocalFteid = fteidToProto(pdr.PDI.LocalFTEID)
+		}
+		if ue := pdr.PDI.UE_IPAddress; ue != nil {
+			c.UeIpAddress = ue.String()
+			if pdr.PDI.UE_IPv6PrefixLength != 0 {
+				c.UeIpAddress = fmt.Sprintf("%s/%d", ue, pdr.PDI.UE_IPv6PrefixLength)
+			}
+		}
+		created = append(created, c)
+	}
+	return created
+}
+
+func farsFromProto(in []*pb.FAR) ([]*FAR, error) {
+	fars := make([]*FAR, len(in))
+	for i, far := range in {
 		fars[i] = &FAR{
 			ID:          far.Id,
 			ApplyAction: uint8(far.ApplyAction),
-			ForwardingParameters: &ForwardingParams{
+		}
+
+		if far.BarId != nil {
+			if *far.BarId > math.MaxUint8 {
+				return nil, fmt.Errorf("FAR %d: invalid BAR ID %d", far.Id, *far.BarId)
+			}
+			barID := uint8(*far.BarId)
+			fars[i].BAR_ID = &barID
+		}
+
+		if far.ForwardingParams != nil {
+			fars[i].ForwardingParameters = &ForwardingParams{
 				DestinationInterface: uint8(far.ForwardingParams.DestinationInterface),
 				NetworkInstance:      far.ForwardingParams.NetworkInstance,
-			},
-		}
-	}
+			}
+
+			ohc, err := ohcFromProto(far.ForwardingParams.OuterHeaderCreation)
+			if err != nil {
+				return nil, fmt.Errorf("FAR %d: %w", far.Id, err)
+			}
+			fars[i].ForwardingParameters.OuterHeaderCreation = ohc
+
+			if far.ForwardingParams.Pppoe != nil {
+				if ohc != nil {
+					return nil, fmt.Errorf("FAR %d: PPPoE and outer header creation are exclusive", far.Id)
+				}
+				session, err := pppoeSessionFromProto(far.ForwardingParams.Pppoe)
+				if err != nil {
+					return nil, fmt.Errorf("FAR %d: %w", far.Id, err)
+				}
+				fars[i].ForwardingParameters.PPPoE = session
+			}
 
-	var qers []*QER
-	if len(req.Qers) > 0 {
-		qers = make([]*QER, len(req.Qers))
-		for i, qer := range req.Qers {
-			qers[i] = &QER{
-				ID:         qer.Id,
-				GateStatus: uint8(qer.GateStatus),
-				MBR_UL:     qer.MbrUplink,
-				MBR_DL:     qer.MbrDownlink,
+			if far.ForwardingParams.L2Tp != nil {
+				if ohc != nil || far.ForwardingParams.Pppoe != nil {
+					return nil, fmt.Errorf("FAR %d: L2TP, PPPoE and outer header creation are exclusive", far.Id)
+				}
+				session, err := l2tpSessionFromProto(far.ForwardingParams.L2Tp)
+				if err != nil {
+					return nil, fmt.Errorf("FAR %d: %w", far.Id, err)
+				}
+				fars[i].ForwardingParameters.L2TP = session
 			}
 		}
-	}
 
-	var urrs []*URR
-	if len(req.Urrs) > 0 {
-		urrs = make([]*URR, len(req.Urrs))
-		for i, urr := range req.Urrs {
-			urrs[i] = &URR{
-				ID:                urr.Id,
-				MeasurementMethod: uint8(urr.MeasurementMethod),
+		for _, dp := range far.DuplicatingParams {
+			ohc, err := ohcFromProto(dp.OuterHeaderCreation)
+			if err != nil {
+				return nil, fmt.Errorf("FAR %d duplicating parameters: %w", far.Id, err)
+			}
+			if len(dp.ForwardingPolicy) > math.MaxUint8 {
+				return nil, fmt.Errorf("FAR %d: forwarding policy longer than %d octets", far.Id, math.MaxUint8)
 			}
+			fars[i].DuplicatingParameters = append(fars[i].DuplicatingParameters, &DuplicatingParams{
+				DestinationInterface: uint8(dp.DestinationInterface),
+				OuterHeaderCreation:  ohc,
+				ForwardingPolicy:     dp.ForwardingPolicy,
+			})
 		}
 	}
+	return fars, nil
+}
 
-	seid, err := s.cp.CreateSession(req.NodeId, pdrs, fars, qers, urrs)
+func pppoeSessionFromProto(in *pb.PPPoESession) (*protocol.PPPoESession, error) {
+	if in.SessionId == 0 || in.SessionId >= math.MaxUint16 {
+		return nil, fmt.Errorf("invalid PPPoE session ID %d", in.SessionId)
+	}
+	mac, err := net.ParseMAC(in.PeerMac)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("invalid PPPoE peer MAC address %q", in.PeerMac)
+	}
+
+	session := &protocol.PPPoESession{SessionID: uint16(in.SessionId), PeerMAC: mac}
+	if session.CTag, err = vlanTagFromProto(in.CTag); err != nil {
+		return nil, fmt.Errorf("C-TAG: %w", err)
+	}
+	if session.STag, err = vlanTagFromProto(in.STag); err != nil {
+		return nil, fmt.Errorf("S-TAG: %w", err)
+	}
+
+	// Check the tags have VIDs.
+	if _, err := protocol.NewPPPoESessionIEs(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func l2tpSessionFromProto(in *pb.L2TPSession) (*protocol.L2TPSession, error) {
+	if in.TunnelId == 0 || in.TunnelId > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid L2TP tunnel ID %d", in.TunnelId)
+	}
+	if in.SessionId == 0 || in.SessionId > math.MaxUint16 {
+		return nil, fmt.Errorf("invalid L2TP session ID %d", in.SessionId)
+	}
+	lns := net.ParseIP(in.LnsAddress)
+	if lns == nil {
+		return nil, fmt.Errorf("invalid LNS address %q", in.LnsAddress)
+	}
+
+	return &protocol.L2TPSession{TunnelID: uint16(in.TunnelId), SessionID: uint16(in.SessionId), LNS: lns}, nil
+}
+
+func ohcFromProto(in *pb.OuterHeaderCreation) (*protocol.OuterHeaderCreation, error) {
+	if in == nil {
+		return nil, nil
+	}
+
+	ipv4, err := parseOptionalIP(in.Ipv4, false)
 	if err != nil {
-		return nil, fmt.Errorf("create session: %w", err)
+		return nil, err
+	}
+	ipv6, err := parseOptionalIP(in.Ipv6, true)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("gRPC: Session created SEID=%d for node %s\n", seid, req.NodeId)
+	return &protocol.OuterHeaderCreation{
+		Description: uint16(in.Description),
+		TEID:        in.Teid,
+		IPv4:        ipv4,
+		IPv6:        ipv6,
+		Port:        uint16(in.Port),
+	}, nil
+}
 
-	return &pb.CreateSessionResponse{Seid: seid}, nil
+func barFromProto(in *pb.BAR) (*BAR, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if in.Id > math.MaxUint8 {
+		return nil, fmt.Errorf("invalid BAR ID %d", in.Id)
+	}
+	if in.SuggestedBufferingPacketsCount > math.MaxUint8 {
+		return nil, fmt.Errorf("BAR %d: suggested buffering packets count %d above %d", in.Id, in.SuggestedBufferingPacketsCount, math.MaxUint8)
+	}
+
+	return &BAR{
+		ID:                             uint8(in.Id),
+		DownlinkDataNotificationDelay:  time.Duration(in.DownlinkDataNotificationDelayMs) * time.Millisecond,
+		SuggestedBufferingPacketsCount: uint8(in.SuggestedBufferingPacketsCount),
+	}, nil
 }
 
-func (s *GRPCServer) ModifySession(ctx context.Context, req *pb.ModifySessionRequest) (*pb.ModifySessionResponse, error) {
-	return nil, fmt.Errorf("ModifySession not implemented yet")
+func qersFromProto(in []*pb.QER) []*QER {
+	if len(in) == 0 {
+		return nil
+	}
+
+	qers := make([]*QER, len(in))
+	for i, qer := range in {
+		qers[i] = &QER{
+			ID:         qer.Id,
+			GateStatus: uint8(qer.GateStatus),
+			MBR_UL:     qer.MbrUplink,
+			MBR_DL:     qer.MbrDownlink,
+			GBR_UL:     qer.GbrUplink,
+			GBR_DL:     qer.GbrDownlink,
+		}
+	}
+	return qers
+}
+
+func urrsFromProto(in []*pb.URR) []*URR {
+	if len(in) == 0 {
+		return nil
+	}
+
+	urrs := make([]*URR, len(in))
+	for i, urr := range in {
+		urrs[i] = &URR{
+			ID:                urr.Id,
+			MeasurementMethod: uint8(urr.MeasurementMethod),
+			ReportingTriggers: urr.ReportingTriggers,
+			VolumeThreshold:   urr.VolumeThreshold,
+			TimeThreshold:     urr.TimeThreshold,
+		}
+	}
+	return urrs
 }
 
 func (s *GRPCServer) DeleteSession(ctx context.Context, req *pb.DeleteSessionRequest) (*pb.DeleteSessionResponse, error) {
@@ -115,3 +625,76 @@ func (s *GRPCServer) ListAssociations(ctx context.Context, req *pb.ListAssociati
 
 	return &pb.ListAssociationsResponse{Associations: associations}, nil
 }
+
+func (s *GRPCServer) AuditSessions(ctx context.Context, req *pb.AuditSessionsRequest) (*pb.AuditSessionsResponse, error) {
+	reports, err := s.cp.AuditSessions(ctx, req.NodeId, req.Repair)
+	if err != nil {
+		return nil, fmt.Errorf("audit sessions: %w", err)
+	}
+
+	resp := &pb.AuditSessionsResponse{}
+	for _, report := range reports {
+		resp.Reports = append(resp.Reports, &pb.AuditReport{
+			NodeId:              report.NodeID,
+			MissingSeids:        report.Missing,
+			OrphanedRemoteSeids: report.Orphaned,
+			MismatchedSeids:     report.Mismatched,
+			ReestablishedSeids:  report.Reestablished,
+			DeletedRemoteSeids:  report.Deleted,
+			Errors:              report.Errors,
+		})
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCServer) CreateIPPool(ctx context.Context, req *pb.CreateIPPoolRequest) (*pb.CreateIPPoolResponse, error) {
+	if req.Pool == nil {
+		return nil, fmt.Errorf("create IP pool: no pool")
+	}
+
+	status, err := s.cp.CreateIPPool(&IPPool{
+		Name:            req.Pool.Name,
+		Prefix:          req.Pool.Prefix,
+		PrefixLength:    int(req.Pool.PrefixLength),
+		NodeID:          req.Pool.NodeId,
+		NetworkInstance: req.Pool.NetworkInstance,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create IP pool: %w", err)
+	}
+
+	fmt.Printf("gRPC: IP pool %s created (%s)\n", status.Name, status.Prefix)
+
+	return &pb.CreateIPPoolResponse{Pool: ipPoolToProto(status)}, nil
+}
+
+func (s *GRPCServer) DeleteIPPool(ctx context.Context, req *pb.DeleteIPPoolRequest) (*pb.DeleteIPPoolResponse, error) {
+	if err := s.cp.DeleteIPPool(req.Name); err != nil {
+		return nil, fmt.Errorf("delete IP pool: %w", err)
+	}
+
+	fmt.Printf("gRPC: IP pool %s deleted\n", req.Name)
+
+	return &pb.DeleteIPPoolResponse{Success: true}, nil
+}
+
+func (s *GRPCServer) ListIPPools(ctx context.Context, req *pb.ListIPPoolsRequest) (*pb.ListIPPoolsResponse, error) {
+	resp := &pb.ListIPPoolsResponse{}
+	for _, status := range s.cp.ListIPPools() {
+		resp.Pools = append(resp.Pools, ipPoolToProto(status))
+	}
+	return resp, nil
+}
+
+func ipPoolToProto(in *IPPoolStatus) *pb.IPPool {
+	return &pb.IPPool{
+		Name:            in.Name,
+		Prefix:          in.Prefix,
+		PrefixLength:    uint32(in.PrefixLength),
+		NodeId:          in.NodeID,
+		NetworkInstance: in.NetworkInstance,
+		Size:            in.Size,
+		Used:            in.Used,
+	}
+}
@@ -8,7 +8,16 @@ import (
 	"github.com/veesix-networks/pfcp-go/pkg/protocol"
 )
 
+// handleAssociationSetupRequest is rejected by a standby CP, like every
+// request that would change the state it shares with the active CP, so that
+// only the leader writes to the store.
 func (cp *CPFunction) handleAssociationSetupRequest(msg *protocol.Message, addr *net.UDPAddr) error {
+	if !cp.IsActive() {
+		fmt.Printf("Rejecting association setup from %s: control plane is standby\n", addr)
+		resp := protocol.NewAssociationSetupResponse(msg.Header.SequenceNumber, cp.nodeID, protocol.CauseRequestRejected, cp.recoveryTS)
+		return cp.transport.SendResponse(resp, addr)
+	}
+
 	nodeIDIE := msg.FindIE(protocol.IETypeNodeID)
 	if nodeIDIE == nil {
 		return fmt.Errorf("no node ID in association setup request")
@@ -32,6 +41,12 @@ func (cp *CPFunction) handleAssociationSetupRequest(msg *protocol.Message, addr
 	cp.associations[nodeID] = assoc
 	cp.mu.Unlock()
 
+	if cp.store != nil {
+		if err := cp.store.StoreAssociation(nodeID, assoc); err != nil {
+			fmt.Printf("Failed to persist association with %s: %v\n", nodeID, err)
+		}
+	}
+
 	fmt.Printf("Association established from UP node: %s (%s)\n", nodeID, addr)
 
 	resp := protocol.NewAssociationSetupResponse(
@@ -45,6 +60,11 @@ func (cp *CPFunction) handleAssociationSetupRequest(msg *protocol.Message, addr
 }
 
 func (cp *CPFunction) handleAssociationReleaseRequest(msg *protocol.Message, addr *net.UDPAddr) error {
+	if !cp.IsActive() {
+		resp := protocol.NewAssociationReleaseResponse(msg.Header.SequenceNumber, cp.nodeID, protocol.CauseRequestRejected)
+		return cp.transport.SendResponse(resp, addr)
+	}
+
 	nodeIDIE := msg.FindIE(protocol.IETypeNodeID)
 	if nodeIDIE == nil {
 		return fmt.Errorf("no node ID in association release request")
@@ -56,6 +76,12 @@ func (cp *CPFunction) handleAssociationReleaseRequest(msg *protocol.Message, add
 	delete(cp.associations, nodeID)
 	cp.mu.Unlock()
 
+	if cp.store != nil {
+		if err := cp.store.DeleteAssociation(nodeID); err != nil {
+			fmt.Printf("Failed to remove association with %s from store: %v\n", nodeID, err)
+		}
+	}
+
 	resp := protocol.NewAssociationReleaseResponse(
 		msg.Header.SequenceNumber,
 		cp.nodeID,
@@ -71,5 +97,81 @@ func (cp *CPFunction) handleHeartbeatRequest(msg *protocol.Message, addr *net.UD
 }
 
 func (cp *CPFunction) handleSessionReportRequest(msg *protocol.Message, addr *net.UDPAddr) error {
+	seid := msg.Header.SEID
+
+	cp.mu.RLock()
+	session, ok := cp.sessions[seid]
+	cp.mu.RUnlock()
+
+	// Not "context not found", which would make the UP drop a session
+	// the active CP still has.
+	if !cp.IsActive() {
+		var remoteSEID uint64
+		if ok {
+			remoteSEID = session.RemoteSEID
+		}
+		resp := protocol.NewSessionReportResponse(msg.Header.SequenceNumber, remoteSEID, protocol.CauseRequestRejected)
+		return cp.transport.SendResponse(resp, addr)
+	}
+
+	if !ok {
+		resp := protocol.NewSessionReportResponse(
+			msg.Header.SequenceNumber,
+			0,
+			protocol.CauseSessionContextNotFound,
+		)
+		return cp.transport.SendResponse(resp, addr)
+	}
+
+	reportTypeIE := msg.FindIE(protocol.IETypeReportType)
+	if reportTypeIE == nil {
+		resp := protocol.NewSessionReportResponse(
+			msg.Header.SequenceNumber,
+			session.RemoteSEID,
+			protocol.CauseMandatoryIEMissing,
+		)
+		return cp.transport.SendResponse(resp, addr)
+	}
+
+	reportType, _ := reportTypeIE.GetReportType()
+
+	var dlPDRIDs []uint16
+	if reportType&protocol.ReportTypeDownlinkData != 0 {
+		dlReportIE := msg.FindIE(protocol.IETypeDownlinkDataReport)
+		if dlReportIE == nil {
+			resp := protocol.NewSessionReportResponse(
+				msg.Header.SequenceNumber,
+				session.RemoteSEID,
+				protocol.CauseConditionalIEMissing,
+			)
+			return cp.transport.SendResponse(resp, addr)
+		}
+
+		var err error
+		if dlPDRIDs, err = protocol.ParseDownlinkDataReport(dlReportIE); err != nil {
+			resp := protocol.NewSessionReportResponse(
+				msg.Header.SequenceNumber,
+				session.RemoteSEID,
+				protocol.CauseMandatoryIEIncorrect,
+			)
+			return cp.transport.SendResponse(resp, addr)
+		}
+	}
+
+	reports := parseUsageReports(msg.FindAllIEs(protocol.IETypeUsageReportSessionReport))
+
+	resp := protocol.NewSessionReportResponse(
+		msg.Header.SequenceNumber,
+		session.RemoteSEID,
+		protocol.CauseRequestAccepted,
+	)
+	if err := cp.transport.SendResponse(resp, addr); err != nil {
+		return err
+	}
+
+	if dlPDRIDs != nil {
+		cp.reportDownlinkData(seid, dlPDRIDs)
+	}
+	cp.reportUsage(seid, reports)
 	return nil
 }
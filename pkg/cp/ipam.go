@@ -0,0 +1,603 @@
+package cp
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrIPPoolExhausted is returned when a pool has no free address.
+var ErrIPPoolExhausted = errors.New("IP pool exhausted")
+
+// IPPool is a named pool the CP allocates UE IP addresses from, for PDRs
+// that name it instead of giving an address. IPv4 pools hand out single
+// addresses, IPv6 pools prefixes of PrefixLength, 64 by default. A pool with
+// a NodeID only serves sessions on that UP, and one with a NetworkInstance
+// only PDRs in that Network Instance.
+type IPPool struct {
+	Name            string
+	Prefix          string
+	PrefixLength    int
+	NodeID          string
+	NetworkInstance string
+}
+
+// IPPoolStatus is a pool with its number of addresses and how many are in
+// use by sessions.
+type IPPoolStatus struct {
+	IPPool
+	Size uint64
+	Used uint64
+}
+
+// IPBinding records the subscriber an address of a pool was last allocated
+// to. The subscriber gets the address back while no other session holds it,
+// and other subscribers are only given it once the pool has no address left
+// that is not bound. A binding expires Config.IPBindingLifetime after the
+// address was released; ReleasedAt is zero while a session holds it.
+type IPBinding struct {
+	Pool       string
+	Prefix     string
+	Subscriber string
+	ReleasedAt time.Time
+}
+
+// ipPool is a parsed IPPool. Its addresses or prefixes are numbered from the
+// start of the pool; those from first up to first+size are handed out.
+type ipPool struct {
+	cfg         IPPool
+	prefix      netip.Prefix
+	length      int
+	first, size uint64
+	next        uint64
+	// inUse maps the addresses sessions hold to the session's SEID.
+	inUse map[netip.Prefix]uint64
+	// bound and subscribers are the pool's bindings, both ways.
+	bound       map[netip.Prefix]string
+	subscribers map[string]netip.Prefix
+	// held counts the addresses that are in use or bound, so that the
+	// addresses that are neither are size-held.
+	held uint64
+	// releasedAt holds when the bound addresses no session holds were
+	// released, and released queues them in that order. Entries of the
+	// queue are stale once their address is allocated or released again.
+	releasedAt map[netip.Prefix]time.Time
+	released   []releasedAddress
+}
+
+type releasedAddress struct {
+	prefix netip.Prefix
+	at     time.Time
+}
+
+func newIPPool(cfg IPPool) (*ipPool, error) {
+	if cfg.Name == "" || strings.ContainsAny(cfg.Name, "/ ") {
+		return nil, fmt.Errorf("invalid pool name %q", cfg.Name)
+	}
+	prefix, err := netip.ParsePrefix(cfg.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("pool %s: %w", cfg.Name, err)
+	}
+
+	p := &ipPool{
+		cfg:         cfg,
+		prefix:      prefix.Masked(),
+		length:      32,
+		inUse:       make(map[netip.Prefix]uint64),
+		bound:       make(map[netip.Prefix]string),
+		subscribers: make(map[string]netip.Prefix),
+		releasedAt:  make(map[netip.Prefix]time.Time),
+	}
+	p.cfg.Prefix = p.prefix.String()
+
+	if p.prefix.Addr().Is6() {
+		p.length = cfg.PrefixLength
+		if p.length == 0 {
+			p.length = 64
+		}
+	} else if cfg.PrefixLength != 0 && cfg.PrefixLength != 32 {
+		return nil, fmt.Errorf("IPv4 pool %s hands out single addresses", cfg.Name)
+	}
+	p.cfg.PrefixLength = p.length
+
+	bits := p.length - p.prefix.Bits()
+	if bits < 0 || p.length > p.prefix.Addr().BitLen() || bits > 32 {
+		return nil, fmt.Errorf("pool %s cannot hand out /%d prefixes of %s", cfg.Name, p.length, p.prefix)
+	}
+	p.size = 1 << bits
+
+	// IPv4 pools do not hand out their network and broadcast addresses.
+	if p.prefix.Addr().Is4() && bits > 1 {
+		p.first, p.size = 1, p.size-2
+	}
+	return p, nil
+}
+
+// at returns the pool's i-th address or prefix.
+func (p *ipPool) at(i uint64) netip.Prefix {
+	addr := p.prefix.Addr().AsSlice()
+	n := new(big.Int).SetBytes(addr)
+	n.Add(n, new(big.Int).Lsh(new(big.Int).SetUint64(i), uint(len(addr)*8-p.length)))
+	a, _ := netip.AddrFromSlice(n.FillBytes(addr))
+	return netip.PrefixFrom(a, p.length)
+}
+
+// key maps an address to the pool's prefix that contains it.
+func (p *ipPool) key(addr netip.Addr) (netip.Prefix, bool) {
+	if !p.prefix.Contains(addr) {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, p.length).Masked(), true
+}
+
+// overlaps reports whether two pools could hand out the same address to one
+// session: their prefixes overlap and their scopes do not exclude each other.
+func (p *ipPool) overlaps(other *ipPool) bool {
+	scoped := func(a, b string) bool { return a == "" || b == "" || a == b }
+	return p.prefix.Overlaps(other.prefix) &&
+		scoped(p.cfg.NodeID, other.cfg.NodeID) && scoped(p.cfg.NetworkInstance, other.cfg.NetworkInstance)
+}
+
+func (p *ipPool) bind(prefix netip.Prefix, subscriber string) {
+	if old, ok := p.bound[prefix]; ok {
+		delete(p.subscribers, old)
+	} else if _, used := p.inUse[prefix]; !used {
+		p.held++
+	}
+	if old, ok := p.subscribers[subscriber]; ok && old != prefix {
+		p.unbind(old)
+	}
+	p.bound[prefix] = subscriber
+	p.subscribers[subscriber] = prefix
+}
+
+func (p *ipPool) unbind(prefix netip.Prefix) {
+	subscriber, ok := p.bound[prefix]
+	if !ok {
+		return
+	}
+	delete(p.bound, prefix)
+	delete(p.subscribers, subscriber)
+	delete(p.releasedAt, prefix)
+	if _, used := p.inUse[prefix]; !used {
+		p.held--
+	}
+}
+
+// use marks an address as held by a session.
+func (p *ipPool) use(prefix netip.Prefix, seid uint64) {
+	if _, used := p.inUse[prefix]; !used {
+		if _, bound := p.bound[prefix]; !bound {
+			p.held++
+		}
+	}
+	p.inUse[prefix] = seid
+	delete(p.releasedAt, prefix)
+}
+
+// free returns an address to the pool. It returns the address's binding,
+// to store with its new release time, if it is bound.
+func (p *ipPool) free(prefix netip.Prefix, now time.Time) *IPBinding {
+	if _, used := p.inUse[prefix]; !used {
+		return nil
+	}
+	delete(p.inUse, prefix)
+
+	subscriber, bound := p.bound[prefix]
+	if !bound {
+		p.held--
+		return nil
+	}
+	p.markReleased(prefix, now)
+	return &IPBinding{Pool: p.cfg.Name, Prefix: prefix.String(), Subscriber: subscriber, ReleasedAt: now}
+}
+
+func (p *ipPool) markReleased(prefix netip.Prefix, at time.Time) {
+	p.releasedAt[prefix] = at
+	p.released = append(p.released, releasedAddress{prefix: prefix, at: at})
+
+	// Drop the stale entries once they make up most of the queue.
+	if len(p.released) > 2*len(p.releasedAt)+64 {
+		released := make([]releasedAddress, 0, len(p.releasedAt))
+		for _, r := range p.released {
+			if at, ok := p.releasedAt[r.prefix]; ok && at.Equal(r.at) {
+				released = append(released, r)
+			}
+		}
+		p.released = released
+	}
+}
+
+// oldestReleased drops the stale entries at the head of the release queue,
+// and returns the bound address released first, if any.
+func (p *ipPool) oldestReleased() (releasedAddress, bool) {
+	for len(p.released) > 0 {
+		head := p.released[0]
+		if at, ok := p.releasedAt[head.prefix]; ok && at.Equal(head.at) {
+			return head, true
+		}
+		p.released = p.released[1:]
+	}
+	return releasedAddress{}, false
+}
+
+// expire removes the bindings of addresses released at least lifetime ago.
+func (p *ipPool) expire(now time.Time, lifetime time.Duration) {
+	if lifetime <= 0 {
+		return
+	}
+	for {
+		head, ok := p.oldestReleased()
+		if !ok || now.Sub(head.at) < lifetime {
+			return
+		}
+		p.unbind(head.prefix)
+	}
+}
+
+// ipam holds the CP's pools. Which addresses are in use is not stored: it
+// is rebuilt from the sessions' PDRs when the state is restored. Bindings
+// expire bindingLifetime after their address was released, or never if it
+// is zero.
+type ipam struct {
+	mu              sync.Mutex
+	pools           map[string]*ipPool
+	bindingLifetime time.Duration
+}
+
+func newIPAM(bindingLifetime time.Duration) *ipam {
+	return &ipam{pools: make(map[string]*ipPool), bindingLifetime: bindingLifetime}
+}
+
+func (m *ipam) addPool(cfg IPPool) (*IPPoolStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, err := newIPPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := m.pools[p.cfg.Name]; ok {
+		return nil, fmt.Errorf("pool %s already exists", p.cfg.Name)
+	}
+	for _, other := range m.pools {
+		if p.overlaps(other) {
+			return nil, fmt.Errorf("pool %s overlaps pool %s", p.cfg.Name, other.cfg.Name)
+		}
+	}
+
+	m.pools[p.cfg.Name] = p
+	return p.status(), nil
+}
+
+func (m *ipam) removePool(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pools[name]
+	if !ok {
+		return fmt.Errorf("pool %s not found", name)
+	}
+	if len(p.inUse) > 0 {
+		return fmt.Errorf("pool %s has %d addresses in use", name, len(p.inUse))
+	}
+	delete(m.pools, name)
+	return nil
+}
+
+func (p *ipPool) status() *IPPoolStatus {
+	return &IPPoolStatus{IPPool: p.cfg, Size: p.size, Used: uint64(len(p.inUse))}
+}
+
+func (m *ipam) list() []*IPPoolStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pools := make([]*IPPoolStatus, 0, len(m.pools))
+	for _, p := range m.pools {
+		pools = append(pools, p.status())
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
+
+// allocate hands a session an address of the pool, preferring the one bound
+// to the subscriber, if any, then addresses not bound to another subscriber,
+// and then the bound address released first. It returns the binding to store
+// when it changed, without a Subscriber if it was removed.
+func (m *ipam) allocate(name, nodeID, networkInstance, subscriber string, seid uint64) (netip.Prefix, *IPBinding, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.pools[name]
+	if !ok {
+		return netip.Prefix{}, nil, fmt.Errorf("pool %s not found", name)
+	}
+	if p.cfg.NodeID != "" && p.cfg.NodeID != nodeID {
+		return netip.Prefix{}, nil, fmt.Errorf("pool %s is scoped to node %s", name, p.cfg.NodeID)
+	}
+	if p.cfg.NetworkInstance != "" && p.cfg.NetworkInstance != networkInstance {
+		return netip.Prefix{}, nil, fmt.Errorf("pool %s is scoped to network instance %q", name, p.cfg.NetworkInstance)
+	}
+
+	if uint64(len(p.inUse)) >= p.size {
+		return netip.Prefix{}, nil, fmt.Errorf("pool %s: %w", name, ErrIPPoolExhausted)
+	}
+	p.expire(time.Now(), m.bindingLifetime)
+
+	if prefix, ok := p.subscribers[subscriber]; ok && subscriber != "" {
+		if _, used := p.inUse[prefix]; !used {
+			p.use(prefix, seid)
+			return prefix, nil, nil
+		}
+	}
+
+	// Every address is in use or bound, so the pool has a bound one free.
+	prefix, ok := netip.Prefix{}, false
+	if p.held >= p.size {
+		var oldest releasedAddress
+		oldest, ok = p.oldestReleased()
+		prefix = oldest.prefix
+	}
+	for n := uint64(0); !ok && n < p.size; n++ {
+		i := (p.next + n) % p.size
+		prefix = p.at(p.first + i)
+		_, used := p.inUse[prefix]
+		_, bound := p.bound[prefix]
+		if !used && !bound {
+			p.next = (i + 1) % p.size
+			ok = true
+		}
+	}
+	if !ok {
+		return netip.Prefix{}, nil, fmt.Errorf("pool %s: no free address found", name)
+	}
+
+	p.use(prefix, seid)
+	if subscriber == "" {
+		if _, bound := p.bound[prefix]; !bound {
+			return prefix, nil, nil
+		}
+		// The old subscriber must not get the address back once this
+		// session releases it.
+		p.unbind(prefix)
+		return prefix, &IPBinding{Pool: name, Prefix: prefix.String()}, nil
+	}
+	p.bind(prefix, subscriber)
+	return prefix, &IPBinding{Pool: name, Prefix: prefix.String(), Subscriber: subscriber}, nil
+}
+
+// release returns an address to its pool. It returns the address's binding
+// to store, if it is bound.
+func (m *ipam) release(name string, prefix netip.Prefix) *IPBinding {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, ok := m.pools[name]; ok {
+		return p.free(prefix, time.Now())
+	}
+	return nil
+}
+
+// restore replaces the pools with those stored, and marks the addresses the
+// sessions hold as in use. Bindings of addresses no session holds, and that
+// have no release time, are treated as released now.
+func (m *ipam) restore(pools []*IPPool, bindings []*IPBinding, sessions map[uint64]*Session) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+
+	m.pools = make(map[string]*ipPool, len(pools))
+	for _, cfg := range pools {
+		p, err := newIPPool(*cfg)
+		if err != nil {
+			fmt.Printf("Ignoring stored IP pool %s: %v\n", cfg.Name, err)
+			continue
+		}
+		m.pools[p.cfg.Name] = p
+	}
+
+	for seid, session := range sessions {
+		for pool, prefix := range session.poolAddresses() {
+			if p, ok := m.pools[pool]; ok {
+				p.use(prefix, seid)
+			}
+		}
+	}
+
+	releasedAt := func(b *IPBinding) time.Time {
+		if b.ReleasedAt.IsZero() {
+			return now
+		}
+		return b.ReleasedAt
+	}
+	// Bindings are bound in release order, for the release queues.
+	sort.Slice(bindings, func(i, j int) bool { return releasedAt(bindings[i]).Before(releasedAt(bindings[j])) })
+	for _, b := range bindings {
+		p, ok := m.pools[b.Pool]
+		if !ok {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(b.Prefix)
+		if err != nil {
+			continue
+		}
+		key, ok := p.key(prefix.Addr())
+		if !ok {
+			continue
+		}
+		if _, used := p.inUse[key]; used {
+			p.bind(key, b.Subscriber)
+			continue
+		}
+		// The subscriber keeps the address it holds.
+		if old, ok := p.subscribers[b.Subscriber]; ok {
+			if _, used := p.inUse[old]; used {
+				continue
+			}
+		}
+
+		if m.bindingLifetime > 0 && now.Sub(releasedAt(b)) >= m.bindingLifetime {
+			continue
+		}
+		p.bind(key, b.Subscriber)
+		p.markReleased(key, releasedAt(b))
+	}
+}
+
+// poolAddress returns the address a PDI holds from its pool, if any.
+func (pdi *PacketDetectionInfo) poolAddress() (netip.Prefix, bool) {
+	if pdi == nil || pdi.UE_IPPool == "" || pdi.UE_IPAddress == nil {
+		return netip.Prefix{}, false
+	}
+	addr, ok := netip.AddrFromSlice(pdi.UE_IPAddress)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := addr.BitLen()
+	if addr.Is6() && pdi.UE_IPv6PrefixLength != 0 {
+		bits = int(pdi.UE_IPv6PrefixLength)
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), true
+}
+
+// poolAddresses returns the address the session holds from each pool its
+// PDRs allocate from.
+func (s *Session) poolAddresses() map[string]netip.Prefix {
+	addrs := make(map[string]netip.Prefix)
+	for _, pdr := range s.PDRs {
+		if prefix, ok := pdr.PDI.poolAddress(); ok {
+			addrs[pdr.PDI.UE_IPPool] = prefix
+		}
+	}
+	return addrs
+}
+
+// allocateUEIPs gives the PDRs that name a pool and have no address yet the
+// session's address from that pool, allocating it if the session has none.
+// It returns the addresses it allocated, to be released if the request
+// fails.
+func (cp *CPFunction) allocateUEIPs(session *Session, pdrs []*PDR) (map[string]netip.Prefix, error) {
+	cp.mu.RLock()
+	held := session.poolAddresses()
+	cp.mu.RUnlock()
+
+	allocated := make(map[string]netip.Prefix)
+	for _, pdr := range pdrs {
+		pdi := pdr.PDI
+		if pdi == nil || pdi.UE_IPPool == "" || pdi.UE_IPAddress != nil {
+			continue
+		}
+
+		prefix, ok := held[pdi.UE_IPPool]
+		if !ok {
+			var binding *IPBinding
+			var err error
+			prefix, binding, err = cp.ipam.allocate(pdi.UE_IPPool, session.NodeID, pdi.NetworkInstance, session.SubscriberID, session.LocalSEID)
+			if err != nil {
+				cp.storeIPBindings(cp.releaseUEIPs(allocated))
+				return nil, fmt.Errorf("PDR %d: %w", pdr.ID, err)
+			}
+			cp.storeIPBindings([]*IPBinding{binding})
+			held[pdi.UE_IPPool] = prefix
+			allocated[pdi.UE_IPPool] = prefix
+		}
+
+		pdi.UE_IPAddress = net.IP(prefix.Addr().AsSlice())
+		if prefix.Addr().Is6() && prefix.Bits() < 128 {
+			pdi.UE_IPv6PrefixLength = uint8(prefix.Bits())
+		}
+	}
+	return allocated, nil
+}
+
+// releaseUEIPs releases the addresses and returns the bindings to store,
+// which the caller does once it no longer holds cp.mu.
+func (cp *CPFunction) releaseUEIPs(addrs map[string]netip.Prefix) []*IPBinding {
+	var bindings []*IPBinding
+	for pool, prefix := range addrs {
+		bindings = append(bindings, cp.ipam.release(pool, prefix))
+	}
+	return bindings
+}
+
+// releaseUnusedUEIPs releases the pool addresses in held that no PDR of the
+// session uses any more, like releaseUEIPs.
+func (cp *CPFunction) releaseUnusedUEIPs(session *Session, held map[string]netip.Prefix) []*IPBinding {
+	current := session.poolAddresses()
+	var bindings []*IPBinding
+	for pool, prefix := range held {
+		if current[pool] != prefix {
+			bindings = append(bindings, cp.ipam.release(pool, prefix))
+		}
+	}
+	return bindings
+}
+
+// storeIPBindings persists the bindings that changed, skipping nil ones. It
+// must not be called with cp.mu held.
+func (cp *CPFunction) storeIPBindings(bindings []*IPBinding) {
+	if cp.store == nil {
+		return
+	}
+	for _, binding := range bindings {
+		if binding == nil {
+			continue
+		}
+		if err := cp.store.StoreIPBinding(binding); err != nil {
+			fmt.Printf("Failed to persist IP binding of %s in pool %s: %v\n", binding.Prefix, binding.Pool, err)
+		}
+	}
+}
+
+// CreateIPPool adds a pool UE IP addresses are allocated from.
+func (cp *CPFunction) CreateIPPool(pool *IPPool) (*IPPoolStatus, error) {
+	if !cp.IsActive() {
+		return nil, fmt.Errorf("control plane is standby")
+	}
+
+	status, err := cp.ipam.addPool(*pool)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp.store != nil {
+		if err := cp.store.StoreIPPool(&status.IPPool); err != nil {
+			cp.ipam.removePool(status.Name)
+			return nil, fmt.Errorf("persist pool: %w", err)
+		}
+	}
+	return status, nil
+}
+
+// DeleteIPPool removes a pool, and its bindings, once no session holds an
+// address from it.
+func (cp *CPFunction) DeleteIPPool(name string) error {
+	if !cp.IsActive() {
+		return fmt.Errorf("control plane is standby")
+	}
+
+	if err := cp.ipam.removePool(name); err != nil {
+		return err
+	}
+
+	if cp.store != nil {
+		if err := cp.store.DeleteIPPool(name); err != nil {
+			fmt.Printf("Failed to remove pool %s from store: %v\n", name, err)
+		}
+	}
+	return nil
+}
+
+// ListIPPools returns the pools, sorted by name.
+func (cp *CPFunction) ListIPPools() []*IPPoolStatus {
+	return cp.ipam.list()
+}
@@ -0,0 +1,194 @@
+package cp
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrKeyNotFound = errors.New("key not found")
+
+// KVBackend is the minimal etcd/Redis-style key-value API the HA store and
+// leader election are built on. Every write bumps a store-wide revision,
+// which CompareAndSwap uses for optimistic concurrency. A revision of 0 means
+// "key must not exist".
+type KVBackend interface {
+	Get(key string) ([]byte, uint64, error)
+	Put(key string, value []byte) (uint64, error)
+	Delete(key string) error
+	List(prefix string) (map[string][]byte, error)
+	CompareAndSwap(key string, revision uint64, value []byte) (uint64, bool, error)
+	CompareAndDelete(key string, revision uint64) (bool, error)
+	Watch(ctx context.Context, prefix string) <-chan KVEvent
+}
+
+type KVEventType uint8
+
+const (
+	KVEventPut KVEventType = iota
+	KVEventDelete
+)
+
+type KVEvent struct {
+	Type     KVEventType
+	Key      string
+	Value    []byte
+	Revision uint64
+}
+
+// MemoryKV is an in-process KVBackend. It is meant for tests and for running
+// several CPFunctions inside one process; it does not provide HA on its own.
+type MemoryKV struct {
+	entries  map[string]*memoryKVEntry
+	revision uint64
+	watchers map[*memoryKVWatcher]struct{}
+	mu       sync.Mutex
+}
+
+type memoryKVEntry struct {
+	value    []byte
+	revision uint64
+}
+
+type memoryKVWatcher struct {
+	prefix string
+	ch     chan KVEvent
+}
+
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{
+		entries:  make(map[string]*memoryKVEntry),
+		watchers: make(map[*memoryKVWatcher]struct{}),
+	}
+}
+
+func (m *MemoryKV) Get(key string) ([]byte, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, 0, ErrKeyNotFound
+	}
+	return append([]byte(nil), entry.value...), entry.revision, nil
+}
+
+func (m *MemoryKV) Put(key string, value []byte) (uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.put(key, value), nil
+}
+
+func (m *MemoryKV) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.delete(key)
+	return nil
+}
+
+func (m *MemoryKV) List(prefix string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make(map[string][]byte)
+	for key, entry := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			result[key] = append([]byte(nil), entry.value...)
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryKV) CompareAndSwap(key string, revision uint64, value []byte) (uint64, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.currentRevision(key) != revision {
+		return 0, false, nil
+	}
+	return m.put(key, value), true, nil
+}
+
+func (m *MemoryKV) CompareAndDelete(key string, revision uint64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.currentRevision(key) != revision {
+		return false, nil
+	}
+	m.delete(key)
+	return true, nil
+}
+
+func (m *MemoryKV) Watch(ctx context.Context, prefix string) <-chan KVEvent {
+	w := &memoryKVWatcher{
+		prefix: prefix,
+		ch:     make(chan KVEvent, 64),
+	}
+
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.watchers, w)
+		close(w.ch)
+		m.mu.Unlock()
+	}()
+
+	return w.ch
+}
+
+func (m *MemoryKV) currentRevision(key string) uint64 {
+	if entry, ok := m.entries[key]; ok {
+		return entry.revision
+	}
+	return 0
+}
+
+func (m *MemoryKV) put(key string, value []byte) uint64 {
+	m.revision++
+	m.entries[key] = &memoryKVEntry{
+		value:    append([]byte(nil), value...),
+		revision: m.revision,
+	}
+	m.notify(KVEvent{Type: KVEventPut, Key: key, Value: value, Revision: m.revision})
+	return m.revision
+}
+
+func (m *MemoryKV) delete(key string) {
+	if _, ok := m.entries[key]; !ok {
+		return
+	}
+	m.revision++
+	delete(m.entries, key)
+	m.notify(KVEvent{Type: KVEventDelete, Key: key, Revision: m.revision})
+}
+
+func (m *MemoryKV) notify(event KVEvent) {
+	for w := range m.watchers {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.ch <- event:
+		default:
+			// Slow watchers drop events, the same as a compacted etcd
+			// watch. Consumers must re-read state rather than rely on
+			// seeing every change.
+		}
+	}
+}
+
+func sortedKeys(entries map[string][]byte) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
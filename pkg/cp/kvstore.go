@@ -0,0 +1,204 @@
+package cp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KVStore is a NorthboundStore over a shared KVBackend, so that an
+// active/standby pair of CPs sees the same sessions and associations.
+type KVStore struct {
+	backend KVBackend
+	prefix  string
+}
+
+func NewKVStore(backend KVBackend, prefix string) *KVStore {
+	return &KVStore{
+		backend: backend,
+		prefix:  strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+func (k *KVStore) sessionKey(seid uint64) string {
+	return fmt.Sprintf("%s/sessions/%d", k.prefix, seid)
+}
+
+func (k *KVStore) associationKey(nodeID string) string {
+	return fmt.Sprintf("%s/associations/%s", k.prefix, nodeID)
+}
+
+func (k *KVStore) ipPoolKey(name string) string {
+	return fmt.Sprintf("%s/ip-pools/%s", k.prefix, name)
+}
+
+func (k *KVStore) ipBindingsPrefix(pool string) string {
+	return fmt.Sprintf("%s/ip-bindings/%s/", k.prefix, pool)
+}
+
+func (k *KVStore) nextSEIDKey() string {
+	return k.prefix + "/next-seid"
+}
+
+func (k *KVStore) StoreSession(seid uint64, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("marshal session: %w", err)
+	}
+	_, err = k.backend.Put(k.sessionKey(seid), data)
+	return err
+}
+
+func (k *KVStore) GetSession(seid uint64) (*Session, error) {
+	data, _, err := k.backend.Get(k.sessionKey(seid))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, fmt.Errorf("session %d not found", seid)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("unmarshal session %d: %w", seid, err)
+	}
+	return &session, nil
+}
+
+func (k *KVStore) DeleteSession(seid uint64) error {
+	return k.backend.Delete(k.sessionKey(seid))
+}
+
+func (k *KVStore) ListSessions() ([]uint64, error) {
+	prefix := k.prefix + "/sessions/"
+	entries, err := k.backend.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	seids := make([]uint64, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		seid, err := strconv.ParseUint(strings.TrimPrefix(key, prefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		seids = append(seids, seid)
+	}
+	return seids, nil
+}
+
+func (k *KVStore) StoreNextSEID(seid uint64) error {
+	_, err := k.backend.Put(k.nextSEIDKey(), []byte(strconv.FormatUint(seid, 10)))
+	return err
+}
+
+func (k *KVStore) GetNextSEID() (uint64, error) {
+	data, _, err := k.backend.Get(k.nextSEIDKey())
+	if errors.Is(err, ErrKeyNotFound) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(data), 10, 64)
+}
+
+func (k *KVStore) StoreAssociation(nodeID string, assoc *Association) error {
+	data, err := json.Marshal(assoc)
+	if err != nil {
+		return fmt.Errorf("marshal association: %w", err)
+	}
+	_, err = k.backend.Put(k.associationKey(nodeID), data)
+	return err
+}
+
+func (k *KVStore) DeleteAssociation(nodeID string) error {
+	return k.backend.Delete(k.associationKey(nodeID))
+}
+
+func (k *KVStore) ListAssociations() ([]*Association, error) {
+	entries, err := k.backend.List(k.prefix + "/associations/")
+	if err != nil {
+		return nil, err
+	}
+
+	associations := make([]*Association, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		var assoc Association
+		if err := json.Unmarshal(entries[key], &assoc); err != nil {
+			return nil, fmt.Errorf("unmarshal association %s: %w", key, err)
+		}
+		associations = append(associations, &assoc)
+	}
+	return associations, nil
+}
+
+func (k *KVStore) StoreIPPool(pool *IPPool) error {
+	data, err := json.Marshal(pool)
+	if err != nil {
+		return fmt.Errorf("marshal IP pool: %w", err)
+	}
+	_, err = k.backend.Put(k.ipPoolKey(pool.Name), data)
+	return err
+}
+
+func (k *KVStore) DeleteIPPool(name string) error {
+	entries, err := k.backend.List(k.ipBindingsPrefix(name))
+	if err != nil {
+		return err
+	}
+	for key := range entries {
+		if err := k.backend.Delete(key); err != nil {
+			return err
+		}
+	}
+	return k.backend.Delete(k.ipPoolKey(name))
+}
+
+func (k *KVStore) ListIPPools() ([]*IPPool, error) {
+	entries, err := k.backend.List(k.prefix + "/ip-pools/")
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]*IPPool, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		var pool IPPool
+		if err := json.Unmarshal(entries[key], &pool); err != nil {
+			return nil, fmt.Errorf("unmarshal IP pool %s: %w", key, err)
+		}
+		pools = append(pools, &pool)
+	}
+	return pools, nil
+}
+
+func (k *KVStore) StoreIPBinding(binding *IPBinding) error {
+	if binding.Subscriber == "" {
+		return k.backend.Delete(k.ipBindingsPrefix(binding.Pool) + binding.Prefix)
+	}
+	data, err := json.Marshal(binding)
+	if err != nil {
+		return fmt.Errorf("marshal IP binding: %w", err)
+	}
+	_, err = k.backend.Put(k.ipBindingsPrefix(binding.Pool)+binding.Prefix, data)
+	return err
+}
+
+func (k *KVStore) ListIPBindings() ([]*IPBinding, error) {
+	entries, err := k.backend.List(k.prefix + "/ip-bindings/")
+	if err != nil {
+		return nil, err
+	}
+
+	bindings := make([]*IPBinding, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		var binding IPBinding
+		if err := json.Unmarshal(entries[key], &binding); err != nil {
+			return nil, fmt.Errorf("unmarshal IP binding %s: %w", key, err)
+		}
+		bindings = append(bindings, &binding)
+	}
+	return bindings, nil
+}
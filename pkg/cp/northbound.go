@@ -1,44 +1,171 @@
 package cp
 
+import (
+	"fmt"
+	"sync"
+)
+
 type NorthboundStore interface {
 	StoreSession(seid uint64, session *Session) error
 	GetSession(seid uint64) (*Session, error)
 	DeleteSession(seid uint64) error
 	ListSessions() ([]uint64, error)
+	StoreNextSEID(seid uint64) error
+	GetNextSEID() (uint64, error)
+	StoreAssociation(nodeID string, assoc *Association) error
+	DeleteAssociation(nodeID string) error
+	ListAssociations() ([]*Association, error)
+	StoreIPPool(pool *IPPool) error
+	// DeleteIPPool also deletes the pool's bindings.
+	DeleteIPPool(name string) error
+	ListIPPools() ([]*IPPool, error)
+	// StoreIPBinding replaces the binding of the same pool and prefix, or
+	// deletes it if the binding has no Subscriber.
+	StoreIPBinding(binding *IPBinding) error
+	ListIPBindings() ([]*IPBinding, error)
+}
+
+// ipBindingKey identifies a binding by its pool and prefix.
+func ipBindingKey(binding *IPBinding) string {
+	return binding.Pool + " " + binding.Prefix
 }
 
 type MemoryStore struct {
-	sessions map[uint64]*Session
+	sessions     map[uint64]*Session
+	associations map[string]*Association
+	ipPools      map[string]*IPPool
+	ipBindings   map[string]*IPBinding
+	nextSEID     uint64
+	mu           sync.RWMutex
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		sessions: make(map[uint64]*Session),
+		sessions:     make(map[uint64]*Session),
+		associations: make(map[string]*Association),
+		ipPools:      make(map[string]*IPPool),
+		ipBindings:   make(map[string]*IPBinding),
+		nextSEID:     1,
 	}
 }
 
 func (m *MemoryStore) StoreSession(seid uint64, session *Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.sessions[seid] = session
 	return nil
 }
 
 func (m *MemoryStore) GetSession(seid uint64) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	session, ok := m.sessions[seid]
 	if !ok {
-		return nil, nil
+		return nil, fmt.Errorf("session %d not found", seid)
 	}
 	return session, nil
 }
 
 func (m *MemoryStore) DeleteSession(seid uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	delete(m.sessions, seid)
 	return nil
 }
 
 func (m *MemoryStore) ListSessions() ([]uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	seids := make([]uint64, 0, len(m.sessions))
 	for seid := range m.sessions {
 		seids = append(seids, seid)
 	}
 	return seids, nil
 }
+
+func (m *MemoryStore) StoreNextSEID(seid uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextSEID = seid
+	return nil
+}
+
+func (m *MemoryStore) GetNextSEID() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nextSEID, nil
+}
+
+func (m *MemoryStore) StoreAssociation(nodeID string, assoc *Association) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.associations[nodeID] = assoc
+	return nil
+}
+
+func (m *MemoryStore) DeleteAssociation(nodeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.associations, nodeID)
+	return nil
+}
+
+func (m *MemoryStore) ListAssociations() ([]*Association, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	associations := make([]*Association, 0, len(m.associations))
+	for _, assoc := range m.associations {
+		associations = append(associations, assoc)
+	}
+	return associations, nil
+}
+
+func (m *MemoryStore) StoreIPPool(pool *IPPool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ipPools[pool.Name] = pool
+	return nil
+}
+
+func (m *MemoryStore) DeleteIPPool(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.ipPools, name)
+	for key, binding := range m.ipBindings {
+		if binding.Pool == name {
+			delete(m.ipBindings, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) ListIPPools() ([]*IPPool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	pools := make([]*IPPool, 0, len(m.ipPools))
+	for _, pool := range m.ipPools {
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func (m *MemoryStore) StoreIPBinding(binding *IPBinding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if binding.Subscriber == "" {
+		delete(m.ipBindings, ipBindingKey(binding))
+		return nil
+	}
+	m.ipBindings[ipBindingKey(binding)] = binding
+	return nil
+}
+
+func (m *MemoryStore) ListIPBindings() ([]*IPBinding, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	bindings := make([]*IPBinding, 0, len(m.ipBindings))
+	for _, binding := range m.ipBindings {
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
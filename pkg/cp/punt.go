@@ -0,0 +1,205 @@
+package cp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/veesix-networks/pfcp-go/api/pfcp/v1"
+)
+
+// puntRetryInterval is how often punt streams are opened to associated UPs
+// that lack one.
+const puntRetryInterval = 5 * time.Second
+
+// PuntedPacket is a packet a UP punted, tagged with the session and PDR that
+// punted it.
+type PuntedPacket struct {
+	NodeID string
+	// SEID is the CP's SEID for the session, zero if no PDR matched.
+	SEID  uint64
+	PDRID uint16
+	// SourceInterface is the PFCP Source Interface the packet arrived
+	// from, and Interface the UP's dataplane interface.
+	SourceInterface uint8
+	Interface       string
+	// Data starts at the Ethernet header, or at the IP header if IP is set.
+	Data       []byte
+	IP         bool
+	ReceivedAt time.Time
+}
+
+type InjectedPacket struct {
+	// Interface is the dataplane interface to send the packet out of, or
+	// for IP packets, whose routing table to send it through.
+	Interface string
+	// Data starts at the Ethernet header, or at the IP header if IP is set.
+	Data []byte
+	IP   bool
+	// VLANs are pushed onto an Ethernet frame by the UP, outermost first.
+	VLANs []uint16
+	// SEID is the CP's SEID of the session the packet belongs to, or zero.
+	SEID uint64
+}
+
+// PuntHandler receives the packets punted by the UPs, in order for each UP.
+type PuntHandler func(pkt *PuntedPacket)
+
+type UPPuntClient interface {
+	// StreamPunts calls handler with every packet the UP punts until ctx
+	// is done or the stream fails.
+	StreamPunts(ctx context.Context, nodeID string, handler PuntHandler) error
+	// InjectPacket takes the packet's SEID to be the UP's.
+	InjectPacket(ctx context.Context, nodeID string, pkt *InjectedPacket) error
+}
+
+func (c *GRPCAuditClient) StreamPunts(ctx context.Context, nodeID string, handler PuntHandler) error {
+	conn, err := c.conn(nodeID)
+	if err != nil {
+		return err
+	}
+
+	stream, err := pb.NewUserPlaneClient(conn).StreamPunts(ctx, &pb.StreamPuntsRequest{})
+	if err != nil {
+		return err
+	}
+
+	for {
+		pkt, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		handler(&PuntedPacket{
+			NodeID:          nodeID,
+			SEID:            pkt.RemoteSeid,
+			PDRID:           uint16(pkt.PdrId),
+			SourceInterface: uint8(pkt.SourceInterface),
+			Interface:       pkt.Interface,
+			Data:            pkt.Packet,
+			IP:              pkt.Ip,
+			ReceivedAt:      time.Unix(0, pkt.ReceivedAt),
+		})
+	}
+}
+
+func (c *GRPCAuditClient) InjectPacket(ctx context.Context, nodeID string, pkt *InjectedPacket) error {
+	conn, err := c.conn(nodeID)
+	if err != nil {
+		return err
+	}
+
+	req := &pb.InjectPacketRequest{
+		Interface: pkt.Interface,
+		Packet:    pkt.Data,
+		Ip:        pkt.IP,
+		LocalSeid: pkt.SEID,
+	}
+	for _, vlan := range pkt.VLANs {
+		req.Vlans = append(req.Vlans, uint32(vlan))
+	}
+
+	_, err = pb.NewUserPlaneClient(conn).InjectPacket(ctx, req)
+	return err
+}
+
+// SetPuntHandler subscribes to the packets punted by every associated UP,
+// which handler receives while the CP is active. It must be called before
+// Start.
+func (cp *CPFunction) SetPuntHandler(client UPPuntClient, handler PuntHandler) {
+	cp.puntClient = client
+	cp.puntHandler = handler
+}
+
+// InjectPacket sends a packet out through a UP's dataplane, such as the
+// reply to a punted DHCP or PPPoE packet, with the egress interface, VLANs
+// and session it belongs to.
+func (cp *CPFunction) InjectPacket(ctx context.Context, nodeID string, pkt *InjectedPacket) error {
+	if cp.puntClient == nil {
+		return fmt.Errorf("punt delivery not configured")
+	}
+	if !cp.IsActive() {
+		return fmt.Errorf("control plane is standby")
+	}
+
+	if pkt.SEID != 0 {
+		cp.mu.RLock()
+		session, ok := cp.sessions[pkt.SEID]
+		var remoteSEID uint64
+		if ok {
+			remoteSEID = session.RemoteSEID
+			ok = session.NodeID == nodeID
+		}
+		cp.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("no session %d on node %s", pkt.SEID, nodeID)
+		}
+
+		upPkt := *pkt
+		upPkt.SEID = remoteSEID
+		pkt = &upPkt
+	}
+
+	return cp.puntClient.InjectPacket(ctx, nodeID, pkt)
+}
+
+// puntLoop keeps a punt stream open to every associated UP. Streams are only
+// opened while the CP is active; a CP that loses leadership keeps them, but
+// stops handing their packets on.
+func (cp *CPFunction) puntLoop() {
+	defer cp.wg.Done()
+
+	ticker := time.NewTicker(puntRetryInterval)
+	defer ticker.Stop()
+
+	streams := make(map[string]bool)
+	ended := make(chan string)
+
+	openStreams := func() {
+		if !cp.IsActive() {
+			return
+		}
+		cp.mu.RLock()
+		defer cp.mu.RUnlock()
+		for nodeID := range cp.associations {
+			if streams[nodeID] {
+				continue
+			}
+			streams[nodeID] = true
+			cp.wg.Add(1)
+			go cp.streamPunts(nodeID, ended)
+		}
+	}
+
+	openStreams()
+	for {
+		select {
+		case <-cp.ctx.Done():
+			return
+		case nodeID := <-ended:
+			// Reopened on the next tick.
+			delete(streams, nodeID)
+		case <-ticker.C:
+			openStreams()
+		}
+	}
+}
+
+func (cp *CPFunction) streamPunts(nodeID string, ended chan<- string) {
+	defer cp.wg.Done()
+
+	err := cp.puntClient.StreamPunts(cp.ctx, nodeID, func(pkt *PuntedPacket) {
+		if cp.IsActive() {
+			cp.puntHandler(pkt)
+		}
+	})
+	if cp.ctx.Err() != nil {
+		return
+	}
+	fmt.Printf("Punt stream from node %s ended: %v\n", nodeID, err)
+
+	select {
+	case ended <- nodeID:
+	case <-cp.ctx.Done():
+	}
+}
@@ -0,0 +1,72 @@
+// Package dptest holds the session rules the dataplane tests install.
+package dptest
+
+import (
+	"testing"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+)
+
+const (
+	UEAddr     = "10.0.0.1"
+	ServerAddr = "192.0.2.10"
+)
+
+// Rules is a session's rules, installed PDRs last so that they see their
+// FARs and QERs.
+type Rules struct {
+	PDRs []*up.PDR
+	FARs []*up.FAR
+	QERs []*up.QER
+}
+
+// Install installs the rules on the dataplane and fails the test on the
+// first error, or skips it on a PDR error that skip reports true for.
+func (r Rules) Install(t testing.TB, dp up.Dataplane, seid uint64, skip func(error) bool) {
+	t.Helper()
+
+	for _, far := range r.FARs {
+		if err := dp.InstallFAR(seid, far); err != nil {
+			t.Fatalf("InstallFAR %d: %v", far.ID, err)
+		}
+	}
+	for _, qer := range r.QERs {
+		if err := dp.InstallQER(seid, qer); err != nil {
+			t.Fatalf("InstallQER %d: %v", qer.ID, err)
+		}
+	}
+	for _, pdr := range r.PDRs {
+		err := dp.InstallPDR(seid, pdr)
+		if err != nil && skip != nil && skip(err) {
+			t.Skipf("InstallPDR %d: %v", pdr.ID, err)
+		}
+		if err != nil {
+			t.Fatalf("InstallPDR %d: %v", pdr.ID, err)
+		}
+	}
+}
+
+// PDR matches the UE address on a source interface and uses the FAR with
+// the same ID. An empty flow description matches all traffic.
+func PDR(id uint16, sourceInterface uint8, precedence uint32, flowDescription string) *up.PDR {
+	pdr := &up.PDR{
+		ID:         id,
+		Precedence: precedence,
+		FAR_ID:     uint32(id),
+		PDI:        &up.PDI{SourceInterface: sourceInterface, UE_IPAddress: UEAddr},
+	}
+	if flowDescription != "" {
+		pdr.PDI.SDFFilter = protocol.NewSDFFilterIE(flowDescription).Value
+	}
+	return pdr
+}
+
+// FAR applies action to traffic for the core.
+func FAR(id uint32, action uint8) *up.FAR {
+	return &up.FAR{
+		ID:                   id,
+		ApplyAction:          action,
+		ForwardingParameters: &up.ForwardingParameters{DestinationInterface: protocol.DestinationInterfaceCore},
+	}
+}
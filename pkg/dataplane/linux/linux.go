@@ -0,0 +1,532 @@
+// Package linux programs the Linux kernel as the dataplane: nftables rules
+// match, count, drop and punt the traffic of each PDR, and tc policers
+// enforce QERs. It needs CAP_NET_ADMIN in its network namespace only, so it
+// can run inside an unprivileged user and network namespace.
+package linux
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"sync"
+
+	"github.com/google/nftables"
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"github.com/vishvananda/netlink"
+)
+
+type LinuxDataplane struct {
+	nft              *nftables.Conn
+	inet             *nftables.Table
+	bridge           *nftables.Table
+	chains           [2]*nftables.Chain
+	l2Chain          *nftables.Chain
+	accessInterfaces []netlink.Link
+	coreInterfaces   []netlink.Link
+	vrfs             map[string]netlink.Link
+	puntQueue        uint16
+	punts            *queueReader
+	sessions         map[uint64]*sessionState
+	filterPrios      map[uint16]bool
+	nextPolicer      uint32
+	mu               sync.Mutex
+}
+
+type Config struct {
+	// AccessInterfaces receive uplink traffic. Application ID PDRs match
+	// frames bridged between them, so they must be bridge ports for those.
+	AccessInterfaces []string
+	// CoreInterfaces receive downlink traffic.
+	CoreInterfaces []string
+	// PuntQueue is the NFQUEUE that punted packets are queued to.
+	PuntQueue uint16
+	// PuntDelivery binds PuntQueue, and PuntSource then delivers the
+	// packets queued to it. Without it, PDRs and FARs that punt are
+	// rejected, as nothing would take their packets off the queue.
+	PuntDelivery bool
+	// Table names the inet and bridge nftables tables the dataplane owns.
+	// Defaults to pfcp.
+	Table string
+	// NetworkInstances maps the Network Instances PDRs and FARs may name to
+	// VRF devices. A PDR's Network Instance limits it to the interfaces of
+	// its direction enslaved to the VRF. A FAR's is only checked: the kernel
+	// routes a packet in the VRF it arrived in.
+	NetworkInstances map[string]string
+}
+
+type sessionState struct {
+	pdrs map[uint16]*pdrState
+	fars map[uint32]*up.FAR
+	qers map[uint32]*up.QER
+	urrs map[uint32]*up.URR
+	// policers holds the tc police action index of each QER and
+	// direction, and filters the tc filters binding them.
+	policers map[string]uint32
+	filters  []*tcFilter
+}
+
+func newSessionState() *sessionState {
+	return &sessionState{
+		pdrs:     make(map[uint16]*pdrState),
+		fars:     make(map[uint32]*up.FAR),
+		qers:     make(map[uint32]*up.QER),
+		urrs:     make(map[uint32]*up.URR),
+		policers: make(map[string]uint32),
+	}
+}
+
+// pdrState is an installed PDR with its PDI compiled to nftables matches.
+// ue holds the UE's address or prefix followed by its framed routes.
+type pdrState struct {
+	pdr     *up.PDR
+	ue      []netip.Prefix
+	sdf     *protocol.SDFFilter
+	l2      *protocol.L2Filter
+	matches []match
+	counter *nftables.CounterObj
+}
+
+func NewLinuxDataplane(cfg *Config) (*LinuxDataplane, error) {
+	table := cfg.Table
+	if table == "" {
+		table = "pfcp"
+	}
+
+	nft, err := nftables.New(nftables.AsLasting())
+	if err != nil {
+		return nil, fmt.Errorf("open nftables connection: %w", err)
+	}
+
+	d := &LinuxDataplane{
+		nft:         nft,
+		inet:        &nftables.Table{Name: table, Family: nftables.TableFamilyINet},
+		bridge:      &nftables.Table{Name: table, Family: nftables.TableFamilyBridge},
+		puntQueue:   cfg.PuntQueue,
+		sessions:    make(map[uint64]*sessionState),
+		filterPrios: make(map[uint16]bool),
+	}
+
+	if d.accessInterfaces, err = lookupLinks(cfg.AccessInterfaces); err != nil {
+		nft.CloseLasting()
+		return nil, err
+	}
+	if d.coreInterfaces, err = lookupLinks(cfg.CoreInterfaces); err != nil {
+		nft.CloseLasting()
+		return nil, err
+	}
+
+	if d.vrfs, err = lookupVRFs(cfg.NetworkInstances); err != nil {
+		nft.CloseLasting()
+		return nil, err
+	}
+
+	if err := d.setupTables(); err != nil {
+		nft.CloseLasting()
+		return nil, err
+	}
+
+	for _, link := range append(d.accessInterfaces, d.coreInterfaces...) {
+		if err := resetClsact(link); err != nil {
+			nft.CloseLasting()
+			return nil, err
+		}
+	}
+
+	if cfg.PuntDelivery {
+		indexes := make(map[string]int)
+		for _, link := range append(d.accessInterfaces, d.coreInterfaces...) {
+			indexes[link.Attrs().Name] = link.Attrs().Index
+		}
+		d.punts, err = openQueueReader(d.puntQueue, cfg.AccessInterfaces, cfg.CoreInterfaces, indexes)
+		if err != nil {
+			nft.CloseLasting()
+			return nil, err
+		}
+	}
+
+	log.Printf("[Linux] Programming nftables tables %q and tc on %d access and %d core interfaces",
+		table, len(d.accessInterfaces), len(d.coreInterfaces))
+
+	return d, nil
+}
+
+func lookupLinks(names []string) ([]netlink.Link, error) {
+	links := make([]netlink.Link, 0, len(names))
+	for _, name := range names {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("interface %q: %w", name, err)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// Close removes the nftables tables and tc filters the dataplane owns.
+func (d *LinuxDataplane) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, session := range d.sessions {
+		d.deleteFilters(session)
+	}
+	d.sessions = make(map[uint64]*sessionState)
+
+	d.nft.DelTable(d.inet)
+	d.nft.DelTable(d.bridge)
+	err := d.nft.Flush()
+	d.nft.CloseLasting()
+	if d.punts != nil {
+		d.punts.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("delete nftables tables: %w", err)
+	}
+	return nil
+}
+
+// PuntSource returns the reader of the punt NFQUEUE, or nil if
+// Config.PuntDelivery is not set.
+func (d *LinuxDataplane) PuntSource() up.PuntSource {
+	if d.punts == nil {
+		return nil
+	}
+	return d.punts
+}
+
+func (d *LinuxDataplane) session(seid uint64) *sessionState {
+	session, exists := d.sessions[seid]
+	if !exists {
+		session = newSessionState()
+		d.sessions[seid] = session
+	}
+	return session
+}
+
+func (d *LinuxDataplane) InstallPDR(seid uint64, pdr *up.PDR) error {
+	state, err := d.newPDRState(seid, pdr)
+	if err != nil {
+		return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+	}
+	if state.punts() && d.punts == nil {
+		return fmt.Errorf("PDR %d: SDF filters and Application IDs are punted, and NFQUEUE %d has no reader", pdr.ID, d.puntQueue)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session := d.session(seid)
+	old, exists := session.pdrs[pdr.ID]
+
+	// An update keeps its counter, unless the PDR moves between the inet
+	// and bridge tables.
+	var added, removed []*nftables.CounterObj
+	switch {
+	case !exists:
+		added = append(added, state.counter)
+	case old.counter.Table != state.counter.Table:
+		added, removed = append(added, state.counter), append(removed, old.counter)
+	default:
+		state.counter = old.counter
+	}
+
+	session.pdrs[pdr.ID] = state
+	if err := d.syncRules(added, removed); err != nil {
+		if exists {
+			session.pdrs[pdr.ID] = old
+		} else {
+			delete(session.pdrs, pdr.ID)
+		}
+		return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+	}
+
+	if len(d.interfaces(pdrDirection(pdr))) == 0 {
+		log.Printf("[Linux] Warning: no %s interfaces configured, PDR %d of session %d matches no traffic",
+			pdrDirection(pdr), pdr.ID, seid)
+	}
+
+	if err := d.syncFilters(seid, session); err != nil {
+		return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+	}
+
+	log.Printf("[Linux] Installed PDR %d for session %d (precedence=%d, FAR_ID=%d, %d rules)",
+		pdr.ID, seid, pdr.Precedence, pdr.FAR_ID, len(state.matches))
+
+	return nil
+}
+
+// punts reports whether the PDR's traffic is queued when its FAR forwards
+// it, see verdict.
+func (state *pdrState) punts() bool {
+	if state.sdf != nil && state.sdf.FlowDescription != nil && state.sdf.FlowDescription.Action == ipfilter.ActionDeny {
+		return false
+	}
+	return state.sdf != nil || state.l2 != nil
+}
+
+// newPDRState decodes the PDR's PDI and compiles it. GTP-U is left to the
+// VPP dataplane: the kernel's gtp driver is not programmed here.
+func (d *LinuxDataplane) newPDRState(seid uint64, pdr *up.PDR) (*pdrState, error) {
+	state := &pdrState{
+		pdr:     pdr,
+		counter: &nftables.CounterObj{Table: d.inet, Name: fmt.Sprintf("pdr-%d-%d", seid, pdr.ID)},
+	}
+
+	pdi := pdr.PDI
+	if pdi == nil {
+		return nil, fmt.Errorf("no PDI")
+	}
+	if pdi.LocalFTEID != nil || pdr.OuterHeaderRemoval != nil {
+		return nil, fmt.Errorf("GTP-U is not supported by the linux dataplane")
+	}
+	if pdi.MatchesEthernet() || pdr.BBFOuterHeaderRemoval != nil {
+		return nil, fmt.Errorf("Ethernet packet filters and PPPoE sessions are not supported by the linux dataplane")
+	}
+
+	if pdi.UE_IPAddress != "" {
+		ue, err := netip.ParseAddr(pdi.UE_IPAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid UE IP address %q", pdi.UE_IPAddress)
+		}
+		ue = ue.Unmap()
+		bits := ue.BitLen()
+		if pdi.UE_IPPrefixLength != 0 && ue.Is6() {
+			bits = int(pdi.UE_IPPrefixLength)
+		}
+		state.ue = append(state.ue, netip.PrefixFrom(ue, bits).Masked())
+	}
+	for _, prefix := range pdi.FramedPrefixes() {
+		state.ue = append(state.ue, prefix.Masked())
+	}
+
+	if pdi.ApplicationID != "" {
+		filter, ok := protocol.GetL2Filter(pdi.ApplicationID)
+		if !ok {
+			return nil, fmt.Errorf("unknown Application ID %q", pdi.ApplicationID)
+		}
+		state.l2 = filter
+		state.matches = []match{l2Match(filter)}
+		state.counter.Table = d.bridge
+		if pdi.NetworkInstance != "" {
+			matches, err := d.vrfMatches(state.matches, pdi.NetworkInstance, pdrDirection(pdr), true)
+			if err != nil {
+				return nil, err
+			}
+			state.matches = matches
+		}
+		return state, nil
+	}
+
+	if len(pdi.SDFFilter) > 0 {
+		sdf, err := protocol.ParseSDFFilter(pdi.SDFFilter)
+		if err != nil {
+			return nil, fmt.Errorf("parse SDF filter: %w", err)
+		}
+		state.sdf = sdf
+	}
+
+	matches, err := pdrMatches(state.sdf, pdrDirection(pdr), state.ue)
+	if err != nil {
+		return nil, fmt.Errorf("compile SDF filter: %w", err)
+	}
+	if pdi.NetworkInstance != "" {
+		if matches, err = d.vrfMatches(matches, pdi.NetworkInstance, pdrDirection(pdr), false); err != nil {
+			return nil, err
+		}
+	}
+	state.matches = matches
+
+	return state, nil
+}
+
+func (d *LinuxDataplane) RemovePDR(seid uint64, pdrID uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return nil
+	}
+	state, ok := session.pdrs[pdrID]
+	if !ok {
+		return nil
+	}
+
+	delete(session.pdrs, pdrID)
+	if err := d.syncRules(nil, []*nftables.CounterObj{state.counter}); err != nil {
+		return fmt.Errorf("PDR %d: %w", pdrID, err)
+	}
+	if err := d.syncFilters(seid, session); err != nil {
+		return fmt.Errorf("PDR %d: %w", pdrID, err)
+	}
+
+	log.Printf("[Linux] Removed PDR %d from session %d", pdrID, seid)
+	return nil
+}
+
+func (d *LinuxDataplane) InstallFAR(seid uint64, far *up.FAR) error {
+	if fp := far.ForwardingParameters; fp != nil && fp.OuterHeaderCreation != nil {
+		return fmt.Errorf("FAR %d: outer header creation is not supported by the linux dataplane", far.ID)
+	}
+	if fp := far.ForwardingParameters; fp != nil && (fp.PPPoE != nil || fp.L2TP != nil) {
+		return fmt.Errorf("FAR %d: PPPoE and L2TP sessions are not supported by the linux dataplane", far.ID)
+	}
+	if far.ApplyAction&protocol.ApplyActionDuplicate != 0 {
+		return fmt.Errorf("FAR %d: duplication is not supported by the linux dataplane", far.ID)
+	}
+	if fp := far.ForwardingParameters; fp != nil && fp.DestinationInterface == protocol.DestinationInterfaceCPFunction &&
+		far.ApplyAction&protocol.ApplyActionForward != 0 && d.punts == nil {
+		return fmt.Errorf("FAR %d: forwarding to the CP function punts, and NFQUEUE %d has no reader", far.ID, d.puntQueue)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session := d.session(seid)
+	old, exists := session.fars[far.ID]
+
+	session.fars[far.ID] = far
+	if err := d.syncRules(nil, nil); err != nil {
+		if exists {
+			session.fars[far.ID] = old
+		} else {
+			delete(session.fars, far.ID)
+		}
+		return fmt.Errorf("FAR %d: %w", far.ID, err)
+	}
+
+	log.Printf("[Linux] Installed FAR %d for session %d (action=0x%02x)", far.ID, seid, far.ApplyAction)
+	return nil
+}
+
+func (d *LinuxDataplane) RemoveFAR(seid uint64, farID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return nil
+	}
+	if _, ok := session.fars[farID]; !ok {
+		return nil
+	}
+
+	delete(session.fars, farID)
+	if err := d.syncRules(nil, nil); err != nil {
+		return fmt.Errorf("FAR %d: %w", farID, err)
+	}
+
+	log.Printf("[Linux] Removed FAR %d from session %d", farID, seid)
+	return nil
+}
+
+func (d *LinuxDataplane) InstallQER(seid uint64, qer *up.QER) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session := d.session(seid)
+	session.qers[qer.ID] = qer
+	if err := d.syncFilters(seid, session); err != nil {
+		return fmt.Errorf("QER %d: %w", qer.ID, err)
+	}
+
+	log.Printf("[Linux] Installed QER %d for session %d (gate=0x%02x, MBR UL/DL=%d/%d kbps)",
+		qer.ID, seid, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
+	return nil
+}
+
+func (d *LinuxDataplane) RemoveQER(seid uint64, qerID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return nil
+	}
+
+	delete(session.qers, qerID)
+	for _, dir := range directions {
+		delete(session.policers, policerKey(qerID, dir))
+	}
+	if err := d.syncFilters(seid, session); err != nil {
+		return fmt.Errorf("QER %d: %w", qerID, err)
+	}
+
+	log.Printf("[Linux] Removed QER %d from session %d", qerID, seid)
+	return nil
+}
+
+// InstallURR only records the URR: traffic is counted per PDR by its
+// nftables counter and the UP evaluates the thresholds through PDRUsage.
+func (d *LinuxDataplane) InstallURR(seid uint64, urr *up.URR) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.session(seid).urrs[urr.ID] = urr
+	log.Printf("[Linux] Installed URR %d for session %d (triggers=0x%04x)", urr.ID, seid, urr.ReportingTriggers)
+
+	return nil
+}
+
+func (d *LinuxDataplane) RemoveURR(seid uint64, urrID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, ok := d.sessions[seid]; ok {
+		delete(session.urrs, urrID)
+		log.Printf("[Linux] Removed URR %d from session %d", urrID, seid)
+	}
+
+	return nil
+}
+
+func (d *LinuxDataplane) DeleteSession(seid uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return nil
+	}
+
+	d.deleteFilters(session)
+
+	var removed []*nftables.CounterObj
+	for _, state := range session.pdrs {
+		removed = append(removed, state.counter)
+	}
+	delete(d.sessions, seid)
+	if err := d.syncRules(nil, removed); err != nil {
+		return fmt.Errorf("session %d: %w", seid, err)
+	}
+
+	log.Printf("[Linux] Deleted session %d", seid)
+	return nil
+}
+
+func (d *LinuxDataplane) PDRUsage(seid uint64, pdrID uint16) (uint64, uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return 0, 0, fmt.Errorf("session %d not found", seid)
+	}
+	state, ok := session.pdrs[pdrID]
+	if !ok {
+		return 0, 0, fmt.Errorf("PDR %d not found in session %d", pdrID, seid)
+	}
+
+	obj, err := d.nft.GetObject(state.counter)
+	if err != nil {
+		return 0, 0, fmt.Errorf("read counter %s: %w", state.counter.Name, err)
+	}
+	counter, ok := obj.(*nftables.CounterObj)
+	if !ok {
+		return 0, 0, fmt.Errorf("counter %s has unexpected type %T", state.counter.Name, obj)
+	}
+
+	return counter.Packets, counter.Bytes, nil
+}
@@ -0,0 +1,445 @@
+package linux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/nftables/expr"
+	"github.com/veesix-networks/pfcp-go/pkg/dataplane/internal/dptest"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// The tests run in a user and network namespace of their own, where the
+// dataplane has CAP_NET_ADMIN without touching the host. TestMain re-runs
+// the test binary in one; if the kernel does not allow that, the tests
+// skip.
+const namespaceEnv = "PFCP_LINUX_TEST_NAMESPACE"
+
+var namespaceErr error
+
+func TestMain(m *testing.M) {
+	if os.Getenv(namespaceEnv) == "" {
+		cmd := exec.Command(os.Args[0], os.Args[1:]...)
+		cmd.Env = append(os.Environ(), namespaceEnv+"=1")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		}
+
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			os.Exit(0)
+		case errors.As(err, &exitErr):
+			os.Exit(exitErr.ExitCode())
+		}
+		namespaceErr = err
+	}
+
+	os.Exit(m.Run())
+}
+
+const puntQueue = 7
+
+// newDataplane creates an access and a core veth pair and a dataplane on
+// one end of each.
+func newDataplane(t *testing.T) (*LinuxDataplane, netlink.Link, netlink.Link) {
+	t.Helper()
+
+	if namespaceErr != nil {
+		t.Skipf("cannot create a user and network namespace: %v", namespaceErr)
+	}
+
+	var links []netlink.Link
+	for _, name := range []string{"access0", "core0"} {
+		veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name}, PeerName: name + "-peer"}
+		if err := netlink.LinkAdd(veth); err != nil {
+			t.Fatalf("add veth %s: %v", name, err)
+		}
+		t.Cleanup(func() { netlink.LinkDel(veth) })
+
+		for _, n := range []string{name, name + "-peer"} {
+			link, err := netlink.LinkByName(n)
+			if err != nil {
+				t.Fatalf("find %s: %v", n, err)
+			}
+			if err := netlink.LinkSetUp(link); err != nil {
+				t.Fatalf("set %s up: %v", n, err)
+			}
+		}
+
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			t.Fatalf("find %s: %v", name, err)
+		}
+		links = append(links, link)
+	}
+
+	d, err := NewLinuxDataplane(&Config{
+		AccessInterfaces: []string{"access0"},
+		CoreInterfaces:   []string{"core0"},
+		PuntQueue:        puntQueue,
+		PuntDelivery:     true,
+	})
+	if err != nil {
+		t.Fatalf("NewLinuxDataplane: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	return d, links[0], links[1]
+}
+
+// missingFeature reports whether a PDR failed for a kernel built without
+// nft_queue, cls_flower or act_police, which answers ENOENT. Modules
+// cannot be loaded from a user namespace.
+func missingFeature(err error) bool {
+	return errors.Is(err, unix.ENOENT) || errors.Is(err, unix.EOPNOTSUPP)
+}
+
+// programmedRule is what a rule of a PDR chain comes down to: the PDR
+// counter it updates, its verdict and the L4 ports it matches.
+type programmedRule struct {
+	counter string
+	verdict string
+	// ports holds "src=N" and "dst=N" for each port compared.
+	ports []string
+}
+
+func programmedRules(t *testing.T, d *LinuxDataplane, dir direction) []programmedRule {
+	t.Helper()
+
+	nftRules, err := d.nft.GetRules(d.inet, d.chains[dir])
+	if err != nil {
+		t.Fatalf("list %s rules: %v", dir.chain(), err)
+	}
+
+	var programmed []programmedRule
+	for _, rule := range nftRules {
+		var p programmedRule
+		var load *expr.Payload
+		for _, e := range rule.Exprs {
+			switch e := e.(type) {
+			case *expr.Objref:
+				p.counter = e.Name
+			case *expr.Verdict:
+				p.verdict = map[expr.VerdictKind]string{expr.VerdictAccept: "accept", expr.VerdictDrop: "drop"}[e.Kind]
+			case *expr.Queue:
+				p.verdict = fmt.Sprintf("queue %d", e.Num)
+				if e.Flag&expr.QueueFlagBypass != 0 {
+					p.verdict += " bypass"
+				}
+			case *expr.Payload:
+				load = e
+				continue
+			case *expr.Cmp:
+				if load != nil && load.Base == expr.PayloadBaseTransportHeader && load.Len == 2 {
+					side := map[uint32]string{0: "src", 2: "dst"}[load.Offset]
+					p.ports = append(p.ports, fmt.Sprintf("%s=%d", side, binary.BigEndian.Uint16(e.Data)))
+				}
+			}
+			load = nil
+		}
+		programmed = append(programmed, p)
+	}
+	return programmed
+}
+
+func TestNftablesRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     dptest.Rules
+		dir       direction
+		wantRules []programmedRule
+	}{
+		{
+			name: "forwarding PDR is accepted",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceAccess, 100, "")},
+				FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionForward)},
+			},
+			dir:       uplink,
+			wantRules: []programmedRule{{counter: "pdr-1-1", verdict: "accept"}},
+		},
+		{
+			name: "dropping FAR is dropped",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceCore, 100, "")},
+				FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionDrop)},
+			},
+			dir:       downlink,
+			wantRules: []programmedRule{{counter: "pdr-1-1", verdict: "drop"}},
+		},
+		{
+			name: "SDF filter PDR is punted",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceAccess, 100, "permit in 17 from assigned to any 53")},
+				FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionForward)},
+			},
+			dir:       uplink,
+			wantRules: []programmedRule{{counter: "pdr-1-1", verdict: "queue 7 bypass", ports: []string{"dst=53"}}},
+		},
+		{
+			name: "out flow description is swapped for uplink",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceAccess, 100, "deny out 17 from any 53 to assigned")},
+				FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionForward)},
+			},
+			dir:       uplink,
+			wantRules: []programmedRule{{counter: "pdr-1-1", verdict: "drop", ports: []string{"dst=53"}}},
+		},
+		{
+			name: "deny flow description is dropped",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceCore, 100, "deny out 17 from any 53 to assigned")},
+				FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionForward)},
+			},
+			dir:       downlink,
+			wantRules: []programmedRule{{counter: "pdr-1-1", verdict: "drop", ports: []string{"src=53"}}},
+		},
+		{
+			name: "PDRs are ordered by precedence",
+			rules: dptest.Rules{
+				PDRs: []*up.PDR{
+					dptest.PDR(1, protocol.SourceInterfaceAccess, 300, ""),
+					dptest.PDR(2, protocol.SourceInterfaceAccess, 100, ""),
+					dptest.PDR(3, protocol.SourceInterfaceAccess, 200, ""),
+				},
+				FARs: []*up.FAR{
+					dptest.FAR(1, protocol.ApplyActionForward),
+					dptest.FAR(2, protocol.ApplyActionDrop),
+					dptest.FAR(3, protocol.ApplyActionForward),
+				},
+			},
+			dir: uplink,
+			wantRules: []programmedRule{
+				{counter: "pdr-1-2", verdict: "drop"},
+				{counter: "pdr-1-3", verdict: "accept"},
+				{counter: "pdr-1-1", verdict: "accept"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, _, _ := newDataplane(t)
+			tt.rules.Install(t, d, 1, missingFeature)
+
+			got := programmedRules(t, d, tt.dir)
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantRules) {
+				t.Errorf("%s chain has rules %v, want %v", tt.dir.chain(), got, tt.wantRules)
+			}
+		})
+	}
+}
+
+func TestPuntsNeedQueueReader(t *testing.T) {
+	newDataplane(t)
+	d, err := NewLinuxDataplane(&Config{AccessInterfaces: []string{"access0"}, PuntQueue: puntQueue, Table: "unread"})
+	if err != nil {
+		t.Fatalf("NewLinuxDataplane: %v", err)
+	}
+	defer d.Close()
+
+	if src := d.PuntSource(); src != nil {
+		t.Errorf("PuntSource is %v without PuntDelivery, want nil", src)
+	}
+	if err := d.InstallPDR(1, dptest.PDR(1, protocol.SourceInterfaceAccess, 100, "permit out 17 from any to assigned 67")); err == nil {
+		t.Errorf("InstallPDR of an SDF filter PDR succeeded without a queue reader")
+	}
+	if err := d.InstallPDR(1, dptest.PDR(2, protocol.SourceInterfaceAccess, 100, "deny out 17 from any to assigned 67")); err != nil {
+		t.Errorf("InstallPDR of a denying SDF filter PDR: %v", err)
+	}
+
+	cp := dptest.FAR(1, protocol.ApplyActionForward)
+	cp.ForwardingParameters.DestinationInterface = protocol.DestinationInterfaceCPFunction
+	if err := d.InstallFAR(1, cp); err == nil {
+		t.Errorf("InstallFAR forwarding to the CP function succeeded without a queue reader")
+	}
+}
+
+func TestTCPolicers(t *testing.T) {
+	tests := []struct {
+		name  string
+		pdr   *up.PDR
+		qers  []*up.QER
+		dir   direction
+		want  string
+		ueKey func(*netlink.Flower) net.IP
+	}{
+		{
+			name: "uplink MBR polices the UE source",
+			pdr:  dptest.PDR(1, protocol.SourceInterfaceAccess, 100, ""),
+			qers: []*up.QER{{ID: 1, MBR_UL: 8000, MBR_DL: 16000}},
+			dir:  uplink,
+			// 8000 kbps is 1,000,000 bytes per second, with a burst of
+			// 100ms.
+			want:  "police rate=1000000 burst=100000",
+			ueKey: func(f *netlink.Flower) net.IP { return f.SrcIP },
+		},
+		{
+			name:  "downlink MBR polices the UE destination",
+			pdr:   dptest.PDR(1, protocol.SourceInterfaceCore, 100, ""),
+			qers:  []*up.QER{{ID: 1, MBR_UL: 8000, MBR_DL: 16000}},
+			dir:   downlink,
+			want:  "police rate=2000000 burst=200000",
+			ueKey: func(f *netlink.Flower) net.IP { return f.DestIP },
+		},
+		{
+			name:  "low MBR keeps the minimum burst",
+			pdr:   dptest.PDR(1, protocol.SourceInterfaceAccess, 100, ""),
+			qers:  []*up.QER{{ID: 1, MBR_UL: 64}},
+			dir:   uplink,
+			want:  "police rate=8000 burst=15000",
+			ueKey: func(f *netlink.Flower) net.IP { return f.SrcIP },
+		},
+		{
+			name:  "closed gate drops",
+			pdr:   dptest.PDR(1, protocol.SourceInterfaceAccess, 100, ""),
+			qers:  []*up.QER{{ID: 1, GateStatus: protocol.GateStatusClosed << 2}},
+			dir:   uplink,
+			want:  "drop",
+			ueKey: func(f *netlink.Flower) net.IP { return f.SrcIP },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, access, core := newDataplane(t)
+			tt.pdr.QER_IDs = []uint32{1}
+			dptest.Rules{PDRs: []*up.PDR{tt.pdr}, FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionForward)}, QERs: tt.qers}.Install(t, d, 1, missingFeature)
+
+			link, other := access, core
+			if tt.dir == downlink {
+				link, other = core, access
+			}
+
+			if filters, err := netlink.FilterList(other, netlink.HANDLE_MIN_INGRESS); err != nil || len(filters) != 0 {
+				t.Errorf("%s has %d ingress filters (%v), want none", other.Attrs().Name, len(filters), err)
+			}
+
+			filters, err := netlink.FilterList(link, netlink.HANDLE_MIN_INGRESS)
+			if err != nil {
+				t.Fatalf("list filters of %s: %v", link.Attrs().Name, err)
+			}
+			if len(filters) != 1 {
+				t.Fatalf("%s has %d ingress filters, want 1", link.Attrs().Name, len(filters))
+			}
+			flower, ok := filters[0].(*netlink.Flower)
+			if !ok {
+				t.Fatalf("filter is a %T, want a flower filter", filters[0])
+			}
+			if ue := tt.ueKey(flower); !ue.Equal(net.ParseIP(dptest.UEAddr)) {
+				t.Errorf("filter matches UE %v, want %s", ue, dptest.UEAddr)
+			}
+
+			var got []string
+			for _, action := range flower.Actions {
+				switch a := action.(type) {
+				case *netlink.PoliceAction:
+					got = append(got, fmt.Sprintf("police rate=%d burst=%d", a.Rate, a.Burst))
+				case *netlink.GenericAction:
+					if a.Action == netlink.TC_ACT_SHOT {
+						got = append(got, "drop")
+					}
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint([]string{tt.want}) {
+				t.Errorf("filter actions %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPDRCounters(t *testing.T) {
+	d, access, _ := newDataplane(t)
+	dptest.Rules{
+		PDRs: []*up.PDR{dptest.PDR(1, protocol.SourceInterfaceAccess, 100, "")},
+		FARs: []*up.FAR{dptest.FAR(1, protocol.ApplyActionDrop)},
+	}.Install(t, d, 1, missingFeature)
+
+	peer, err := netlink.LinkByName("access0-peer")
+	if err != nil {
+		t.Fatalf("find access0-peer: %v", err)
+	}
+
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW, 0)
+	if err != nil {
+		t.Skipf("open packet socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	var wantBytes uint64
+	sizes := []int{10, 100, 1000}
+	for _, size := range sizes {
+		ip := ipv4UDP(dptest.UEAddr, dptest.ServerAddr, size)
+		wantBytes += uint64(len(ip))
+
+		frame := append(bytes.Clone(access.Attrs().HardwareAddr), peer.Attrs().HardwareAddr...)
+		frame = binary.BigEndian.AppendUint16(frame, unix.ETH_P_IP)
+		frame = append(frame, ip...)
+		addr := &unix.SockaddrLinklayer{Ifindex: peer.Attrs().Index, Protocol: htons(unix.ETH_P_IP)}
+		if err := unix.Sendto(fd, frame, 0, addr); err != nil {
+			t.Fatalf("send frame: %v", err)
+		}
+	}
+
+	// The frames cross the veth pair asynchronously.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		packets, bytes, err := d.PDRUsage(1, 1)
+		if err != nil {
+			t.Fatalf("PDRUsage: %v", err)
+		}
+		if packets == uint64(len(sizes)) && bytes == wantBytes {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("PDR 1 counted %d packets and %d bytes, want %d and %d", packets, bytes, len(sizes), wantBytes)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// ipv4UDP builds an IPv4 UDP packet with size bytes of payload. The UDP
+// checksum is left out, which IPv4 allows.
+func ipv4UDP(src, dst string, size int) []byte {
+	b := make([]byte, 28+size)
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[8] = 64
+	b[9] = unix.IPPROTO_UDP
+	copy(b[12:16], net.ParseIP(src).To4())
+	copy(b[16:20], net.ParseIP(dst).To4())
+
+	var sum uint32
+	for i := 0; i < 20; i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(b[i:]))
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	binary.BigEndian.PutUint16(b[10:12], ^uint16(sum))
+
+	binary.BigEndian.PutUint16(b[20:22], 40000)
+	binary.BigEndian.PutUint16(b[22:24], 53)
+	binary.BigEndian.PutUint16(b[24:26], uint16(8+size))
+	return b
+}
@@ -0,0 +1,360 @@
+package linux
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/netlink"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"golang.org/x/sys/unix"
+)
+
+// nfnetlink_queue messages and attributes, from
+// linux/netfilter/nfnetlink_queue.h.
+const (
+	nfnlSubsysQueue = 3
+
+	nfqnlMsgPacket  = 0
+	nfqnlMsgVerdict = 1
+	nfqnlMsgConfig  = 2
+
+	nfqaPacketHdr   = 1
+	nfqaVerdictHdr  = 2
+	nfqaIfindexIn   = 5
+	nfqaPhysIndexIn = 7
+	nfqaPayload     = 10
+	nfqaL2Hdr       = 20
+
+	nfqaCfgCmd    = 1
+	nfqaCfgParams = 2
+	nfqaCfgMask   = 4
+	nfqaCfgFlags  = 5
+
+	nfqnlCfgCmdBind   = 1
+	nfqnlCopyPacket   = 2
+	nfqaCfgFFailOpen  = 1
+	nfVerdictDrop     = 0
+	nfVerdictAccept   = 1
+	nfqueueCopyRange  = 0xffff
+	nfqueueBufferSize = 4 << 20
+)
+
+// queueReader binds the punt NFQUEUE and holds each packet in the kernel
+// until the UP has classified it. The queue fails open, so packets are
+// accepted rather than dropped while the reader falls behind.
+type queueReader struct {
+	conn    *netlink.Conn
+	queue   uint16
+	links   map[int]queueLink
+	packets chan *up.PuntedPacket
+	done    chan struct{}
+	once    sync.Once
+}
+
+// queueLink is an access or core interface packets are queued from.
+type queueLink struct {
+	name            string
+	sourceInterface uint8
+}
+
+func openQueueReader(queue uint16, access, core []string, indexes map[string]int) (*queueReader, error) {
+	conn, err := netlink.Dial(unix.NETLINK_NETFILTER, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open nfnetlink socket: %w", err)
+	}
+	// Lost packets are accepted by the kernel, so ENOBUFS is not worth
+	// failing over.
+	if err := conn.SetOption(netlink.NoENOBUFS, true); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("set NETLINK_NO_ENOBUFS: %w", err)
+	}
+	if err := conn.SetReadBuffer(nfqueueBufferSize); err != nil {
+		log.Printf("[Linux] Warning: cannot grow the NFQUEUE socket buffer: %v", err)
+	}
+
+	r := &queueReader{
+		conn:    conn,
+		queue:   queue,
+		links:   make(map[int]queueLink),
+		packets: make(chan *up.PuntedPacket, 256),
+		done:    make(chan struct{}),
+	}
+	for _, ifaces := range []struct {
+		names           []string
+		sourceInterface uint8
+	}{
+		{access, protocol.SourceInterfaceAccess},
+		{core, protocol.SourceInterfaceCore},
+	} {
+		for _, name := range ifaces.names {
+			r.links[indexes[name]] = queueLink{name: name, sourceInterface: ifaces.sourceInterface}
+		}
+	}
+
+	cmd := make([]byte, 4)
+	cmd[0] = nfqnlCfgCmdBind
+	if err := r.configure(func(ae *netlink.AttributeEncoder) { ae.Bytes(nfqaCfgCmd, cmd) }); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bind NFQUEUE %d: %w", queue, err)
+	}
+
+	params := make([]byte, 5)
+	binary.BigEndian.PutUint32(params, nfqueueCopyRange)
+	params[4] = nfqnlCopyPacket
+	err = r.configure(func(ae *netlink.AttributeEncoder) {
+		ae.Bytes(nfqaCfgParams, params)
+		ae.Uint32(nfqaCfgFlags, nfqaCfgFFailOpen)
+		ae.Uint32(nfqaCfgMask, nfqaCfgFFailOpen)
+	})
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("configure NFQUEUE %d: %w", queue, err)
+	}
+
+	go r.read()
+
+	return r, nil
+}
+
+// header is the nfgenmsg every nfnetlink_queue message starts with.
+func (r *queueReader) header() []byte {
+	h := make([]byte, 4)
+	h[0] = unix.AF_UNSPEC
+	binary.BigEndian.PutUint16(h[2:], r.queue)
+	return h
+}
+
+func (r *queueReader) configure(attrs func(ae *netlink.AttributeEncoder)) error {
+	ae := netlink.NewAttributeEncoder()
+	ae.ByteOrder = binary.BigEndian
+	attrs(ae)
+	data, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(nfnlSubsysQueue<<8 | nfqnlMsgConfig),
+			Flags: netlink.Request | netlink.Acknowledge,
+		},
+		Data: append(r.header(), data...),
+	})
+	return err
+}
+
+func (r *queueReader) read() {
+	for {
+		msgs, err := r.conn.Receive()
+		if err != nil {
+			select {
+			case <-r.done:
+			default:
+				log.Printf("[Linux] Reading NFQUEUE %d stopped: %v", r.queue, err)
+				r.Close()
+			}
+			return
+		}
+
+		for _, msg := range msgs {
+			if msg.Header.Type != netlink.HeaderType(nfnlSubsysQueue<<8|nfqnlMsgPacket) {
+				continue
+			}
+			pkt, err := r.parse(msg.Data)
+			if err != nil {
+				log.Printf("[Linux] Skipping NFQUEUE %d message: %v", r.queue, err)
+				continue
+			}
+			if pkt == nil {
+				continue
+			}
+
+			select {
+			case r.packets <- pkt:
+			case <-r.done:
+				return
+			}
+		}
+	}
+}
+
+// parse turns a queued packet into a punted one whose Verdict releases it.
+// Packets from interfaces the dataplane does not own are accepted straight
+// away, and parse returns nil for them.
+func (r *queueReader) parse(data []byte) (*up.PuntedPacket, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("short message (%d bytes)", len(data))
+	}
+	ad, err := netlink.NewAttributeDecoder(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	ad.ByteOrder = binary.BigEndian
+
+	var id uint32
+	var hasID bool
+	var indev, physindev int
+	var l2, payload []byte
+	for ad.Next() {
+		switch ad.Type() {
+		case nfqaPacketHdr:
+			if b := ad.Bytes(); len(b) >= 4 {
+				id, hasID = binary.BigEndian.Uint32(b), true
+			}
+		case nfqaIfindexIn:
+			indev = int(ad.Uint32())
+		case nfqaPhysIndexIn:
+			physindev = int(ad.Uint32())
+		case nfqaL2Hdr:
+			l2 = ad.Bytes()
+		case nfqaPayload:
+			payload = ad.Bytes()
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+	if !hasID {
+		return nil, fmt.Errorf("no packet header")
+	}
+
+	// Bridged frames arrive on the bridge port.
+	if physindev != 0 {
+		indev = physindev
+	}
+	link, ok := r.links[indev]
+	if !ok {
+		r.verdict(id, true)
+		return nil, nil
+	}
+
+	pkt := &up.PuntedPacket{
+		Interface:       link.name,
+		SourceInterface: link.sourceInterface,
+		Data:            payload,
+		IP:              l2 == nil,
+		ReceivedAt:      time.Now(),
+		Verdict:         func(accept bool) { r.verdict(id, accept) },
+	}
+	if l2 != nil {
+		pkt.Data = append(append([]byte(nil), l2...), payload...)
+	}
+	return pkt, nil
+}
+
+func (r *queueReader) verdict(id uint32, accept bool) {
+	hdr := make([]byte, 8)
+	binary.BigEndian.PutUint32(hdr, nfVerdictDrop)
+	if accept {
+		binary.BigEndian.PutUint32(hdr, nfVerdictAccept)
+	}
+	binary.BigEndian.PutUint32(hdr[4:], id)
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(nfqaVerdictHdr, hdr)
+	data, err := ae.Encode()
+	if err == nil {
+		_, err = r.conn.Send(netlink.Message{
+			Header: netlink.Header{
+				Type:  netlink.HeaderType(nfnlSubsysQueue<<8 | nfqnlMsgVerdict),
+				Flags: netlink.Request,
+			},
+			Data: append(r.header(), data...),
+		})
+	}
+	if err != nil {
+		select {
+		case <-r.done:
+		default:
+			log.Printf("[Linux] Failed to set the verdict of NFQUEUE %d packet %d: %v", r.queue, id, err)
+		}
+	}
+}
+
+func (r *queueReader) ReadPunt() (*up.PuntedPacket, error) {
+	select {
+	case pkt := <-r.packets:
+		return pkt, nil
+	case <-r.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// InjectPacket sends an Ethernet frame out of an access or core interface,
+// or routes an IP packet, through the routing table of the interface it
+// names if any.
+func (r *queueReader) InjectPacket(pkt *up.InjectedPacket) error {
+	if pkt.IP {
+		return injectIP(pkt)
+	}
+
+	for index, link := range r.links {
+		if link.name != pkt.Interface {
+			continue
+		}
+		fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("open packet socket: %w", err)
+		}
+		defer unix.Close(fd)
+		if err := unix.Sendto(fd, pkt.Data, 0, &unix.SockaddrLinklayer{Ifindex: index}); err != nil {
+			return fmt.Errorf("send on %s: %w", link.name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown interface %q", pkt.Interface)
+}
+
+func injectIP(pkt *up.InjectedPacket) error {
+	if len(pkt.Data) == 0 {
+		return fmt.Errorf("empty IP packet")
+	}
+
+	var family int
+	var to unix.Sockaddr
+	switch pkt.Data[0] >> 4 {
+	case 4:
+		if len(pkt.Data) < 20 {
+			return fmt.Errorf("short IPv4 packet (%d bytes)", len(pkt.Data))
+		}
+		family, to = unix.AF_INET, &unix.SockaddrInet4{Addr: [4]byte(pkt.Data[16:20])}
+	case 6:
+		if len(pkt.Data) < 40 {
+			return fmt.Errorf("short IPv6 packet (%d bytes)", len(pkt.Data))
+		}
+		family, to = unix.AF_INET6, &unix.SockaddrInet6{Addr: [16]byte(pkt.Data[24:40])}
+	default:
+		return fmt.Errorf("not an IP packet")
+	}
+
+	// IPPROTO_RAW sockets send the packet with its own header.
+	fd, err := unix.Socket(family, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.IPPROTO_RAW)
+	if err != nil {
+		return fmt.Errorf("open raw socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	if pkt.Interface != "" {
+		if err := unix.BindToDevice(fd, pkt.Interface); err != nil {
+			return fmt.Errorf("bind raw socket to %s: %w", pkt.Interface, err)
+		}
+	}
+	if err := unix.Sendto(fd, pkt.Data, 0, to); err != nil {
+		return fmt.Errorf("send IP packet: %w", err)
+	}
+	return nil
+}
+
+// Close unbinds the queue. The kernel drops the packets still held.
+func (r *queueReader) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+		r.conn.Close()
+	})
+	return nil
+}
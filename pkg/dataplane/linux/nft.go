@@ -0,0 +1,236 @@
+package linux
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"github.com/vishvananda/netlink"
+)
+
+// Traffic enters the inet table's prerouting chain, which jumps to the
+// uplink chain for packets received on access interfaces and to the
+// downlink chain for those received on core interfaces. These chains hold a
+// rule per PDR match, ordered by precedence, so the first PDR to match
+// decides the verdict. Application ID PDRs match bridged frames in the
+// bridge table's prerouting chain instead.
+type direction int
+
+const (
+	uplink direction = iota
+	downlink
+)
+
+var directions = []direction{uplink, downlink}
+
+func (d direction) String() string {
+	if d == uplink {
+		return "access"
+	}
+	return "core"
+}
+
+func (d direction) chain() string {
+	if d == uplink {
+		return "uplink"
+	}
+	return "downlink"
+}
+
+// filterDirection returns the IPFilterRule direction of the traffic.
+func (d direction) filterDirection() ipfilter.Direction {
+	if d == uplink {
+		return ipfilter.DirectionIn
+	}
+	return ipfilter.DirectionOut
+}
+
+// pdrDirection takes Access PDRs as uplink and all others as downlink.
+func pdrDirection(pdr *up.PDR) direction {
+	if pdr.PDI != nil && pdr.PDI.SourceInterface == protocol.SourceInterfaceAccess {
+		return uplink
+	}
+	return downlink
+}
+
+func (d *LinuxDataplane) interfaces(dir direction) []netlink.Link {
+	if dir == uplink {
+		return d.accessInterfaces
+	}
+	return d.coreInterfaces
+}
+
+// NFT_OBJECT_COUNTER, the type of named counters.
+const objectCounter = 1
+
+// setupTables replaces the tables left by a previous run, whose sessions
+// the CP re-establishes, with empty ones.
+func (d *LinuxDataplane) setupTables() error {
+	for _, table := range []*nftables.Table{d.inet, d.bridge} {
+		if _, err := d.nft.ListTableOfFamily(table.Name, table.Family); err == nil {
+			d.nft.DelTable(table)
+		}
+	}
+
+	d.nft.AddTable(d.inet)
+	prerouting := d.nft.AddChain(&nftables.Chain{
+		Name:     "prerouting",
+		Table:    d.inet,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityMangle,
+	})
+	for _, dir := range directions {
+		d.chains[dir] = d.nft.AddChain(&nftables.Chain{Name: dir.chain(), Table: d.inet})
+		names, err := ipIngressNames(d.interfaces(dir))
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			d.nft.AddRule(&nftables.Rule{
+				Table: d.inet,
+				Chain: prerouting,
+				Exprs: append(meta(expr.MetaKeyIIFNAME, ifname(name)),
+					&expr.Verdict{Kind: expr.VerdictJump, Chain: dir.chain()}),
+			})
+		}
+	}
+
+	d.nft.AddTable(d.bridge)
+	d.l2Chain = d.nft.AddChain(&nftables.Chain{
+		Name:     "prerouting",
+		Table:    d.bridge,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookPrerouting,
+		Priority: nftables.ChainPriorityRef(-200), // NF_BR_PRI_FILTER_BRIDGED
+	})
+
+	if err := d.nft.Flush(); err != nil {
+		return fmt.Errorf("create nftables tables: %w", err)
+	}
+	return nil
+}
+
+// ipIngressNames returns the interfaces the inet table sees the traffic of
+// the links arrive on. IP traffic from a bridge port enters through its
+// bridge.
+func ipIngressNames(links []netlink.Link) ([]string, error) {
+	var names []string
+	for _, link := range links {
+		name := link.Attrs().Name
+		if master := link.Attrs().MasterIndex; master != 0 {
+			bridge, err := netlink.LinkByIndex(master)
+			if err != nil {
+				return nil, fmt.Errorf("master of %s: %w", name, err)
+			}
+			if bridge.Type() == "bridge" {
+				name = bridge.Attrs().Name
+			}
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// sessionPDR is an installed PDR and the session it belongs to.
+type sessionPDR struct {
+	seid    uint64
+	session *sessionState
+	state   *pdrState
+}
+
+// syncRules rewrites the PDR chains from the installed sessions, adding and
+// removing the given counters, in a single transaction: if the kernel
+// rejects any of it, nothing changes.
+func (d *LinuxDataplane) syncRules(added, removed []*nftables.CounterObj) error {
+	var pdrs []sessionPDR
+	for seid, session := range d.sessions {
+		for _, state := range session.pdrs {
+			pdrs = append(pdrs, sessionPDR{seid, session, state})
+		}
+	}
+	sort.Slice(pdrs, func(i, j int) bool {
+		a, b := pdrs[i], pdrs[j]
+		if a.state.pdr.Precedence != b.state.pdr.Precedence {
+			return a.state.pdr.Precedence < b.state.pdr.Precedence
+		}
+		if a.seid != b.seid {
+			return a.seid < b.seid
+		}
+		return a.state.pdr.ID < b.state.pdr.ID
+	})
+
+	for _, counter := range added {
+		d.nft.AddObj(counter)
+	}
+	for _, chain := range append(d.chains[:], d.l2Chain) {
+		d.nft.FlushChain(chain)
+	}
+
+	for _, p := range pdrs {
+		dir := pdrDirection(p.state.pdr)
+		verdict := append([]expr.Any{&expr.Objref{Type: objectCounter, Name: p.state.counter.Name}},
+			d.verdict(p.session, p.state)...)
+
+		if p.state.l2 != nil {
+			for _, link := range d.interfaces(dir) {
+				exprs := append(meta(expr.MetaKeyIIFNAME, ifname(link.Attrs().Name)), p.state.matches[0]...)
+				d.nft.AddRule(&nftables.Rule{Table: d.bridge, Chain: d.l2Chain, Exprs: append(exprs, verdict...)})
+			}
+			continue
+		}
+
+		for _, m := range p.state.matches {
+			exprs := append(m[:len(m):len(m)], verdict...)
+			d.nft.AddRule(&nftables.Rule{Table: d.inet, Chain: d.chains[dir], Exprs: exprs})
+		}
+	}
+
+	for _, counter := range removed {
+		d.nft.DeleteObject(counter)
+	}
+
+	if err := d.nft.Flush(); err != nil {
+		return fmt.Errorf("program nftables: %w", err)
+	}
+	return nil
+}
+
+// verdict follows the VPP dataplane: traffic is dropped if the flow
+// description denies it or the FAR drops without forwarding, punted to the
+// CP function's NFQUEUE if the FAR forwards it there or the PDR has an SDF
+// filter or Application ID, and accepted otherwise, including while the FAR
+// is not installed.
+func (d *LinuxDataplane) verdict(session *sessionState, state *pdrState) []expr.Any {
+	drop := []expr.Any{&expr.Verdict{Kind: expr.VerdictDrop}}
+	accept := []expr.Any{&expr.Verdict{Kind: expr.VerdictAccept}}
+
+	if state.sdf != nil && state.sdf.FlowDescription != nil && state.sdf.FlowDescription.Action == ipfilter.ActionDeny {
+		return drop
+	}
+
+	far, ok := session.fars[state.pdr.FAR_ID]
+	switch {
+	case !ok:
+		return accept
+	case far.ApplyAction&protocol.ApplyActionDrop != 0 && far.ApplyAction&protocol.ApplyActionForward == 0:
+		return drop
+	case far.ApplyAction&protocol.ApplyActionForward == 0:
+		return accept
+	}
+
+	fp := far.ForwardingParameters
+	if fp != nil && fp.DestinationInterface == protocol.DestinationInterfaceCPFunction || state.punts() {
+		// Without a reader bound, the kernel accepts the packets rather
+		// than dropping them.
+		return []expr.Any{&expr.Queue{Num: d.puntQueue, Flag: expr.QueueFlagBypass}}
+	}
+	return accept
+}
@@ -0,0 +1,409 @@
+package linux
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/google/nftables/expr"
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"golang.org/x/sys/unix"
+)
+
+// match is a conjunction of nftables expressions. A PDR is compiled into a
+// list of matches, one nftables rule each, and matches a packet if any of
+// them does.
+type match []expr.Any
+
+// exthdrOpIPv4 is NFT_EXTHDR_OP_IPV4, which x/sys does not define.
+const exthdrOpIPv4 expr.ExthdrOp = 2
+
+var (
+	// IP options the kernel's "ip option" expression can find; it does not
+	// parse timestamps.
+	ipOptionKinds = map[string]uint8{
+		"ssrr": 137,
+		"lsrr": 131,
+		"rr":   7,
+	}
+	// sack matches both SACK-permitted and SACK blocks.
+	tcpOptionKinds = map[string][]uint8{
+		"mss":    {2},
+		"window": {3},
+		"sack":   {4, 5},
+		"ts":     {8},
+		"cc":     {11},
+	}
+	tcpFlagBits = map[string]uint8{
+		"fin": 0x01,
+		"syn": 0x02,
+		"rst": 0x04,
+		"psh": 0x08,
+		"ack": 0x10,
+		"urg": 0x20,
+	}
+)
+
+func load(base expr.PayloadBase, offset, length uint32) expr.Any {
+	return &expr.Payload{DestRegister: 1, Base: base, Offset: offset, Len: length}
+}
+
+func cmp(op expr.CmpOp, data []byte) expr.Any {
+	return &expr.Cmp{Op: op, Register: 1, Data: data}
+}
+
+func bitwise(mask []byte) expr.Any {
+	return &expr.Bitwise{SourceRegister: 1, DestRegister: 1, Len: uint32(len(mask)), Mask: mask, Xor: make([]byte, len(mask))}
+}
+
+func meta(key expr.MetaKey, value []byte) []expr.Any {
+	return []expr.Any{&expr.Meta{Key: key, Register: 1}, cmp(expr.CmpOpEq, value)}
+}
+
+func be16(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+func be32(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// ifname is an interface name as the kernel compares it, NUL padded.
+func ifname(name string) []byte {
+	b := make([]byte, unix.IFNAMSIZ)
+	copy(b, name)
+	return b
+}
+
+// and appends the expressions to every match.
+func and(matches []match, exprs ...expr.Any) []match {
+	for i, m := range matches {
+		matches[i] = append(m[:len(m):len(m)], exprs...)
+	}
+	return matches
+}
+
+// or returns every combination of a match and an alternative. No
+// alternatives leaves the matches as they are.
+func or(matches []match, alternatives []match) []match {
+	if len(alternatives) == 0 {
+		return matches
+	}
+	var out []match
+	for _, m := range matches {
+		for _, alt := range alternatives {
+			out = append(out, append(m[:len(m):len(m)], alt...))
+		}
+	}
+	return out
+}
+
+// l2Match matches the outermost EtherType of a bridged frame, as the L2
+// filters of Application IDs do.
+func l2Match(filter *protocol.L2Filter) match {
+	return match{load(expr.PayloadBaseLLHeader, 12, 2), cmp(expr.CmpOpEq, be16(filter.EtherType))}
+}
+
+// addrOffsets are the source and destination address offsets in the IP
+// header.
+func addrOffsets(isV6 bool) (src, dst uint32) {
+	if isV6 {
+		return 8, 24
+	}
+	return 12, 16
+}
+
+func addrMatch(prefix netip.Prefix, offset uint32, negate bool) []expr.Any {
+	addr := prefix.Masked().Addr().AsSlice()
+	exprs := []expr.Any{load(expr.PayloadBaseNetworkHeader, offset, uint32(len(addr)))}
+	if prefix.Bits() < prefix.Addr().BitLen() {
+		exprs = append(exprs, bitwise(net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen())))
+	}
+
+	op := expr.CmpOpEq
+	if negate {
+		op = expr.CmpOpNeq
+	}
+	return append(exprs, cmp(op, addr))
+}
+
+// pdrMatches compiles a PDR's UE prefixes and SDF filter. The UE address and
+// framed routes are the source of uplink traffic and the destination of
+// downlink traffic, and a flow description written for the other direction
+// is matched with its source and destination swapped, like the VPP ACLs. A
+// PDR with neither matches all traffic of its direction.
+func pdrMatches(sdf *protocol.SDFFilter, dir direction, ue []netip.Prefix) ([]match, error) {
+	families := []bool{false, true}
+	if len(ue) > 0 {
+		families = prefixFamilies(ue)
+	}
+
+	if sdf != nil && sdf.FlowDescription != nil {
+		oriented := *sdf
+		oriented.FlowDescription = sdf.FlowDescription.Oriented(dir.filterDirection())
+		sdf = &oriented
+	}
+
+	if sdf != nil {
+		if fd := sdf.FlowDescription; fd != nil && fd.Family() != ipfilter.FamilyAny {
+			isV6 := fd.Family() == ipfilter.FamilyIPv6
+			if len(ue) > 0 && len(familyPrefixes(ue, isV6)) == 0 {
+				return nil, fmt.Errorf("flow description and UE address families differ")
+			}
+			families = []bool{isV6}
+		}
+		if sdf.FlowLabel != 0 {
+			if len(families) == 1 && !families[0] {
+				return nil, fmt.Errorf("flow label needs IPv6")
+			}
+			families = []bool{true}
+		}
+	}
+
+	var matches []match
+	for _, isV6 := range families {
+		proto := byte(unix.NFPROTO_IPV4)
+		if isV6 {
+			proto = unix.NFPROTO_IPV6
+		}
+		base := []match{meta(expr.MetaKeyNFPROTO, []byte{proto})}
+
+		prefixes := familyPrefixes(ue, isV6)
+		if len(prefixes) > 0 {
+			src, dst := addrOffsets(isV6)
+			offset := dst
+			if dir == uplink {
+				offset = src
+			}
+			var alternatives []match
+			for _, prefix := range prefixes {
+				alternatives = append(alternatives, addrMatch(prefix, offset, false))
+			}
+			base = or(base, alternatives)
+		}
+
+		if sdf == nil {
+			matches = append(matches, base...)
+			continue
+		}
+
+		for _, b := range base {
+			m, err := sdfMatches(sdf, isV6, prefixes, b)
+			if err != nil {
+				return nil, err
+			}
+			matches = append(matches, m...)
+		}
+	}
+
+	return matches, nil
+}
+
+// prefixFamilies returns the address families of prefixes, IPv4 first.
+func prefixFamilies(prefixes []netip.Prefix) []bool {
+	var families []bool
+	for _, isV6 := range []bool{false, true} {
+		if len(familyPrefixes(prefixes, isV6)) > 0 {
+			families = append(families, isV6)
+		}
+	}
+	return families
+}
+
+func familyPrefixes(prefixes []netip.Prefix, isV6 bool) []netip.Prefix {
+	var family []netip.Prefix
+	for _, prefix := range prefixes {
+		if prefix.Addr().Is6() == isV6 {
+			family = append(family, prefix)
+		}
+	}
+	return family
+}
+
+// sdfMatches adds an SDF filter's conditions for one address family. ue
+// holds the UE prefixes of that family.
+func sdfMatches(sdf *protocol.SDFFilter, isV6 bool, ue []netip.Prefix, base match) ([]match, error) {
+	matches := []match{base}
+
+	if tos, mask := uint8(sdf.ToS>>8), uint8(sdf.ToS); mask != 0 {
+		// The IPv6 traffic class straddles the first two bytes.
+		if isV6 {
+			matches = and(matches, load(expr.PayloadBaseNetworkHeader, 0, 2),
+				bitwise(be16(uint16(mask)<<4)), cmp(expr.CmpOpEq, be16(uint16(tos&mask)<<4)))
+		} else {
+			matches = and(matches, load(expr.PayloadBaseNetworkHeader, 1, 1),
+				bitwise([]byte{mask}), cmp(expr.CmpOpEq, []byte{tos & mask}))
+		}
+	}
+
+	if sdf.FlowLabel != 0 {
+		matches = and(matches, load(expr.PayloadBaseNetworkHeader, 1, 3),
+			bitwise([]byte{0x0f, 0xff, 0xff}), cmp(expr.CmpOpEq, be32(sdf.FlowLabel)[1:]))
+	}
+
+	fd := sdf.FlowDescription
+
+	if sdf.SPI != 0 {
+		// The SPI is the first word of ESP and the second of AH.
+		var offset uint32
+		switch {
+		case fd != nil && fd.Protocol == unix.IPPROTO_AH:
+			offset = 4
+		case fd == nil || fd.Protocol == ipfilter.ProtocolAny:
+			matches = and(matches, meta(expr.MetaKeyL4PROTO, []byte{unix.IPPROTO_ESP})...)
+		case fd.Protocol != unix.IPPROTO_ESP:
+			return nil, fmt.Errorf("SPI needs esp or ah")
+		}
+		matches = and(matches, load(expr.PayloadBaseTransportHeader, offset, 4), cmp(expr.CmpOpEq, be32(sdf.SPI)))
+	}
+
+	if fd == nil {
+		return matches, nil
+	}
+
+	if fd.Protocol != ipfilter.ProtocolAny {
+		matches = and(matches, meta(expr.MetaKeyL4PROTO, []byte{fd.Protocol})...)
+	}
+
+	src, dst := addrOffsets(isV6)
+	for _, ep := range []struct {
+		endpoint ipfilter.Endpoint
+		offset   uint32
+	}{{fd.Src, src}, {fd.Dst, dst}} {
+		switch ep.endpoint.Kind {
+		case ipfilter.AddressAssigned:
+			if len(ue) == 0 {
+				return nil, fmt.Errorf("\"assigned\" needs a UE address")
+			}
+			if ep.endpoint.Negate {
+				for _, prefix := range ue {
+					matches = and(matches, addrMatch(prefix, ep.offset, true)...)
+				}
+				break
+			}
+			var alternatives []match
+			for _, prefix := range ue {
+				alternatives = append(alternatives, addrMatch(prefix, ep.offset, false))
+			}
+			matches = or(matches, alternatives)
+		case ipfilter.AddressPrefix:
+			matches = and(matches, addrMatch(ep.endpoint.Prefix, ep.offset, ep.endpoint.Negate)...)
+		}
+	}
+
+	matches = or(matches, portMatches(0, fd.Src.Ports))
+	matches = or(matches, portMatches(2, fd.Dst.Ports))
+
+	return optionMatches(fd.Options, isV6, matches)
+}
+
+// portMatches returns one alternative per port range, at the given offset
+// in the L4 header.
+func portMatches(offset uint32, ports []ipfilter.PortRange) []match {
+	var alternatives []match
+	for _, r := range ports {
+		m := match{load(expr.PayloadBaseTransportHeader, offset, 2)}
+		if r.First == r.Last {
+			m = append(m, cmp(expr.CmpOpEq, be16(r.First)))
+		} else {
+			m = append(m, &expr.Range{Op: expr.CmpOpEq, Register: 1, FromData: be16(r.First), ToData: be16(r.Last)})
+		}
+		alternatives = append(alternatives, m)
+	}
+	return alternatives
+}
+
+func optionMatches(o ipfilter.Options, isV6 bool, matches []match) ([]match, error) {
+	if o.Frag {
+		if isV6 {
+			matches = and(matches, &expr.Exthdr{DestRegister: 1, Op: expr.ExthdrOpIpv6, Type: unix.IPPROTO_FRAGMENT, Offset: 2, Len: 2},
+				bitwise(be16(0xfff8)), cmp(expr.CmpOpNeq, be16(0)))
+		} else {
+			matches = and(matches, load(expr.PayloadBaseNetworkHeader, 6, 2),
+				bitwise(be16(0x1fff)), cmp(expr.CmpOpNeq, be16(0)))
+		}
+	}
+
+	for _, flag := range o.IPOptions {
+		kind, ok := ipOptionKinds[flag.Name]
+		if !ok {
+			return nil, fmt.Errorf("ipoptions %s cannot be matched with nftables", flag.Name)
+		}
+		// IPv6 packets have no IP options.
+		if isV6 {
+			if !flag.Negate {
+				return nil, nil
+			}
+			continue
+		}
+		matches = and(matches, optionPresent(exthdrOpIPv4, kind, !flag.Negate)...)
+	}
+
+	for _, flag := range o.TCPOptions {
+		kinds := tcpOptionKinds[flag.Name]
+		if flag.Negate {
+			for _, kind := range kinds {
+				matches = and(matches, optionPresent(expr.ExthdrOpTcpopt, kind, false)...)
+			}
+			continue
+		}
+		var alternatives []match
+		for _, kind := range kinds {
+			alternatives = append(alternatives, optionPresent(expr.ExthdrOpTcpopt, kind, true))
+		}
+		matches = or(matches, alternatives)
+	}
+
+	mask, value, err := tcpFlags(o)
+	if err != nil {
+		return nil, err
+	}
+	if mask != 0 {
+		matches = and(matches, load(expr.PayloadBaseTransportHeader, 13, 1),
+			bitwise([]byte{mask}), cmp(expr.CmpOpEq, []byte{value}))
+	}
+	if o.Established {
+		matches = and(matches, load(expr.PayloadBaseTransportHeader, 13, 1),
+			bitwise([]byte{tcpFlagBits["rst"] | tcpFlagBits["ack"]}), cmp(expr.CmpOpNeq, []byte{0}))
+	}
+
+	var alternatives []match
+	for _, t := range o.ICMPTypes {
+		alternatives = append(alternatives, match{load(expr.PayloadBaseTransportHeader, 0, 1), cmp(expr.CmpOpEq, []byte{t})})
+	}
+
+	return or(matches, alternatives), nil
+}
+
+func optionPresent(op expr.ExthdrOp, kind uint8, present bool) match {
+	value := byte(0)
+	if present {
+		value = 1
+	}
+	return match{
+		&expr.Exthdr{DestRegister: 1, Op: op, Type: kind, Len: 1, Flags: unix.NFT_EXTHDR_F_PRESENT},
+		cmp(expr.CmpOpEq, []byte{value}),
+	}
+}
+
+// tcpFlags returns the TCP flags mask and value the filter's options
+// require. "setup" is SYN without ACK.
+func tcpFlags(o ipfilter.Options) (mask, value uint8, err error) {
+	flags := o.TCPFlags
+	if o.Setup {
+		flags = append(flags[:len(flags):len(flags)], ipfilter.Flag{Name: "syn"}, ipfilter.Flag{Name: "ack", Negate: true})
+	}
+	for _, f := range flags {
+		bit := tcpFlagBits[f.Name]
+		if mask&bit != 0 && (value&bit != 0) == f.Negate {
+			return 0, 0, fmt.Errorf("conflicting TCP flag %q", f.Name)
+		}
+		mask |= bit
+		if !f.Negate {
+			value |= bit
+		}
+	}
+	return mask, value, nil
+}
@@ -0,0 +1,203 @@
+package linux
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/netip"
+	"sort"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// QERs are enforced at tc ingress, before netfilter, with one police action
+// per QER and direction. A PDR binds its UE address to the actions of its
+// QERs, in order, through a flower filter on each interface of its
+// direction: uplink PDRs match the UE as source on the access interfaces,
+// downlink PDRs match it as destination on the core interfaces. Like the
+// VPP policer classify sessions, a filter matches the UE address alone, so
+// the first PDR of a UE by precedence decides its QERs in each direction.
+
+// minPolicerBurst keeps low rates from dropping single full-size frames.
+const minPolicerBurst = 15000
+
+// tcFilter is an installed flower filter, identified by its priority.
+type tcFilter struct {
+	link     netlink.Link
+	priority uint16
+	protocol uint16
+}
+
+func (f *tcFilter) attrs() netlink.FilterAttrs {
+	return netlink.FilterAttrs{
+		LinkIndex: f.link.Attrs().Index,
+		Parent:    netlink.HANDLE_MIN_INGRESS,
+		Priority:  f.priority,
+		Protocol:  f.protocol,
+	}
+}
+
+func policerKey(qerID uint32, dir direction) string {
+	return fmt.Sprintf("qer%d-%s", qerID, dir.chain())
+}
+
+// resetClsact gives the interface an empty clsact qdisc, removing the
+// filters of a previous run along with any the qdisc had.
+func resetClsact(link netlink.Link) error {
+	qdisc := &netlink.Clsact{QdiscAttrs: netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    netlink.MakeHandle(0xffff, 0),
+		Parent:    netlink.HANDLE_CLSACT,
+	}}
+
+	// The qdisc may not exist yet.
+	_ = netlink.QdiscDel(qdisc)
+	if err := netlink.QdiscAdd(qdisc); err != nil {
+		return fmt.Errorf("add clsact qdisc to %s: %w", link.Attrs().Name, err)
+	}
+	return nil
+}
+
+// qerActions returns the tc actions enforcing the PDR's QERs in its
+// direction: a drop for a closed gate, a police action for an MBR.
+func (d *LinuxDataplane) qerActions(session *sessionState, pdr *up.PDR, dir direction) []netlink.Action {
+	var actions []netlink.Action
+	for _, id := range pdr.QER_IDs {
+		qer, ok := session.qers[id]
+		if !ok {
+			continue
+		}
+
+		gate, mbr := protocol.DownlinkGate(qer.GateStatus), qer.MBR_DL
+		if dir == uplink {
+			gate, mbr = protocol.UplinkGate(qer.GateStatus), qer.MBR_UL
+		}
+
+		switch {
+		case gate == protocol.GateStatusClosed:
+			drop := &netlink.GenericAction{ActionAttrs: netlink.ActionAttrs{Action: netlink.TC_ACT_SHOT}}
+			return append(actions, drop)
+		case mbr == 0:
+			continue
+		}
+
+		index, ok := session.policers[policerKey(id, dir)]
+		if !ok {
+			d.nextPolicer++
+			index = d.nextPolicer
+			session.policers[policerKey(id, dir)] = index
+		}
+
+		// Allow bursts of 100ms at the MBR. Conforming packets go on to
+		// the next QER's action.
+		rate := min(mbr*1000/8, math.MaxUint32)
+		police := netlink.NewPoliceAction()
+		police.Index = int(index)
+		police.Rate = uint32(rate)
+		police.Burst = uint32(max(rate/10, minPolicerBurst))
+		police.ExceedAction = netlink.TC_POLICE_SHOT
+		police.NotExceedAction = netlink.TC_POLICE_PIPE
+		actions = append(actions, police)
+	}
+	return actions
+}
+
+// syncFilters replaces the session's tc filters with the ones its PDRs and
+// QERs call for. Police actions live as long as a filter binds them, so
+// removing every filter first lets changed QERs take their new rates.
+func (d *LinuxDataplane) syncFilters(seid uint64, session *sessionState) error {
+	d.deleteFilters(session)
+
+	pdrs := make([]*pdrState, 0, len(session.pdrs))
+	for _, state := range session.pdrs {
+		pdrs = append(pdrs, state)
+	}
+	sort.Slice(pdrs, func(i, j int) bool {
+		if pdrs[i].pdr.Precedence != pdrs[j].pdr.Precedence {
+			return pdrs[i].pdr.Precedence < pdrs[j].pdr.Precedence
+		}
+		return pdrs[i].pdr.ID < pdrs[j].pdr.ID
+	})
+
+	bound := make(map[string]bool)
+	for _, state := range pdrs {
+		dir := pdrDirection(state.pdr)
+		actions := d.qerActions(session, state.pdr, dir)
+		if len(actions) == 0 {
+			continue
+		}
+		if len(state.ue) == 0 {
+			log.Printf("[Linux] PDR %d of session %d has no UE address, its QERs are not enforced", state.pdr.ID, seid)
+			continue
+		}
+
+		for _, link := range d.interfaces(dir) {
+			for _, ue := range state.ue {
+				key := fmt.Sprintf("%d/%s", link.Attrs().Index, ue)
+				if bound[key] {
+					continue
+				}
+				bound[key] = true
+
+				if err := d.addFilter(session, link, ue, dir, actions); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *LinuxDataplane) addFilter(session *sessionState, link netlink.Link, ue netip.Prefix, dir direction, actions []netlink.Action) error {
+	priority, err := d.allocFilterPriority()
+	if err != nil {
+		return err
+	}
+
+	f := &tcFilter{link: link, priority: priority, protocol: unix.ETH_P_IP}
+	if ue.Addr().Is6() {
+		f.protocol = unix.ETH_P_IPV6
+	}
+	ip := net.IP(ue.Addr().AsSlice())
+	mask := net.CIDRMask(ue.Bits(), ue.Addr().BitLen())
+
+	flower := &netlink.Flower{FilterAttrs: f.attrs(), EthType: f.protocol, Actions: actions}
+	if dir == uplink {
+		flower.SrcIP, flower.SrcIPMask = ip, mask
+	} else {
+		flower.DestIP, flower.DestIPMask = ip, mask
+	}
+
+	if err := netlink.FilterAdd(flower); err != nil {
+		delete(d.filterPrios, priority)
+		return fmt.Errorf("add tc filter for %s on %s: %w", ue, link.Attrs().Name, err)
+	}
+
+	session.filters = append(session.filters, f)
+	return nil
+}
+
+func (d *LinuxDataplane) deleteFilters(session *sessionState) {
+	for _, f := range session.filters {
+		if err := netlink.FilterDel(&netlink.Flower{FilterAttrs: f.attrs()}); err != nil {
+			log.Printf("[Linux] Failed to delete tc filter %d on %s: %v", f.priority, f.link.Attrs().Name, err)
+		}
+		delete(d.filterPrios, f.priority)
+	}
+	session.filters = nil
+}
+
+func (d *LinuxDataplane) allocFilterPriority() (uint16, error) {
+	for priority := uint16(1); priority != 0; priority++ {
+		if !d.filterPrios[priority] {
+			d.filterPrios[priority] = true
+			return priority, nil
+		}
+	}
+	return 0, fmt.Errorf("no free tc filter priority")
+}
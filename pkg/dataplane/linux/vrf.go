@@ -0,0 +1,75 @@
+package linux
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/google/nftables/expr"
+	"github.com/vishvananda/netlink"
+)
+
+// lookupVRFs resolves the VRF device of each Network Instance.
+func lookupVRFs(networkInstances map[string]string) (map[string]netlink.Link, error) {
+	vrfs := make(map[string]netlink.Link, len(networkInstances))
+	for name, dev := range networkInstances {
+		link, err := netlink.LinkByName(dev)
+		if err != nil {
+			return nil, fmt.Errorf("network instance %s: VRF %q: %w", name, dev, err)
+		}
+		if link.Type() != "vrf" {
+			return nil, fmt.Errorf("network instance %s: %s is a %s, not a VRF", name, dev, link.Type())
+		}
+		vrfs[name] = link
+	}
+	return vrfs, nil
+}
+
+// HasNetworkInstance reports whether name is mapped to a VRF.
+func (d *LinuxDataplane) HasNetworkInstance(name string) bool {
+	_, ok := d.vrfs[name]
+	return ok
+}
+
+// vrfMatches restricts a PDR's matches to the interfaces of its direction
+// that are in the Network Instance's VRF, by the name the table sees them
+// arrive on: the bridge of a bridge port for the inet table, and the port
+// itself for the bridge table.
+func (d *LinuxDataplane) vrfMatches(matches []match, name string, dir direction, bridged bool) ([]match, error) {
+	vrf := d.vrfs[name]
+	if vrf == nil {
+		return nil, fmt.Errorf("unknown network instance %q", name)
+	}
+
+	var names []string
+	for _, link := range d.interfaces(dir) {
+		ingress := link
+		if master := link.Attrs().MasterIndex; master != 0 {
+			bridge, err := netlink.LinkByIndex(master)
+			if err != nil {
+				return nil, fmt.Errorf("master of %s: %w", link.Attrs().Name, err)
+			}
+			if bridge.Type() == "bridge" {
+				ingress = bridge
+			}
+		}
+		if ingress.Attrs().MasterIndex != vrf.Attrs().Index {
+			continue
+		}
+		name := ingress.Attrs().Name
+		if bridged {
+			name = link.Attrs().Name
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no %s interfaces in VRF %s of network instance %s", dir, vrf.Attrs().Name, name)
+	}
+
+	alternatives := make([]match, 0, len(names))
+	for _, n := range names {
+		alternatives = append(alternatives, meta(expr.MetaKeyIIFNAME, ifname(n)))
+	}
+	return or(matches, alternatives), nil
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"sync"
 
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
 	"github.com/veesix-networks/pfcp-go/pkg/up"
 )
 
@@ -13,15 +14,46 @@ type MockDataplane struct {
 	fars map[uint64]map[uint32]*up.FAR
 	qers map[uint64]map[uint32]*up.QER
 	urrs map[uint64]map[uint32]*up.URR
-	mu   sync.RWMutex
+	// usage holds simulated PDR counters, set with SetPDRUsage.
+	usage map[uint64]map[uint16][2]uint64
+	// decaps and encaps record the GTP-U tunnel endpoints of each session:
+	// the local F-TEIDs of PDRs and the Outer Header Creation of FARs.
+	decaps map[uint64]map[uint16]protocol.FTEID
+	encaps map[uint64]map[uint32]protocol.OuterHeaderCreation
+	// injected records the packets the CP injects.
+	injected *up.InjectionRecorder
+	// duplicates records the copies FARs with the DUPL action made of the
+	// packets passed to SimulatePacket.
+	duplicates []*Duplicate
+	mu         sync.RWMutex
 }
 
+// Duplicate is a copy of a packet a FAR sent to one of its Duplicating
+// Parameters. The mock does not build the outer header, it records it.
+type Duplicate struct {
+	SEID                 uint64
+	PDRID                uint16
+	FARID                uint32
+	DestinationInterface uint8
+	OuterHeaderCreation  *protocol.OuterHeaderCreation
+	ForwardingPolicy     string
+	Frame                []byte
+}
+
+// injectionHistory is how many injected and duplicated packets the mock
+// keeps.
+const injectionHistory = 1000
+
 func NewMockDataplane() *MockDataplane {
 	return &MockDataplane{
-		pdrs: make(map[uint64]map[uint16]*up.PDR),
-		fars: make(map[uint64]map[uint32]*up.FAR),
-		qers: make(map[uint64]map[uint32]*up.QER),
-		urrs: make(map[uint64]map[uint32]*up.URR),
+		pdrs:     make(map[uint64]map[uint16]*up.PDR),
+		fars:     make(map[uint64]map[uint32]*up.FAR),
+		qers:     make(map[uint64]map[uint32]*up.QER),
+		urrs:     make(map[uint64]map[uint32]*up.URR),
+		usage:    make(map[uint64]map[uint16][2]uint64),
+		decaps:   make(map[uint64]map[uint16]protocol.FTEID),
+		encaps:   make(map[uint64]map[uint32]protocol.OuterHeaderCreation),
+		injected: up.NewInjectionRecorder(injectionHistory),
 	}
 }
 
@@ -29,13 +61,51 @@ func (m *MockDataplane) InstallPDR(seid uint64, pdr *up.PDR) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	var flow string
+	if pdr.PDI != nil && len(pdr.PDI.SDFFilter) > 0 {
+		sdf, err := protocol.ParseSDFFilter(pdr.PDI.SDFFilter)
+		if err != nil {
+			return fmt.Errorf("PDR %d: parse SDF filter: %w", pdr.ID, err)
+		}
+		if sdf.FlowDescription != nil {
+			flow = sdf.FlowDescription.String()
+		}
+	}
+
 	if m.pdrs[seid] == nil {
 		m.pdrs[seid] = make(map[uint16]*up.PDR)
 	}
 
 	m.pdrs[seid][pdr.ID] = pdr
-	log.Printf("[Mock] Installed PDR %d for session %d (precedence=%d, FAR_ID=%d)",
-		pdr.ID, seid, pdr.Precedence, pdr.FAR_ID)
+	log.Printf("[Mock] Installed PDR %d for session %d (precedence=%d, FAR_ID=%d, flow=%q)",
+		pdr.ID, seid, pdr.Precedence, pdr.FAR_ID, flow)
+
+	if pdr.PDI != nil {
+		for _, filter := range pdr.PDI.EthernetPacketFilters {
+			log.Printf("[Mock] Ethernet packet filter for PDR %d in session %d: %s", pdr.ID, seid, filter)
+		}
+		if pdr.PDI.PPPoE != nil {
+			log.Printf("[Mock] PPPoE match for PDR %d in session %d: %s", pdr.ID, seid, pdr.PDI.PPPoE)
+		}
+		for _, route := range pdr.PDI.FramedRoutes {
+			log.Printf("[Mock] Framed route for PDR %d in session %d: %s (routing=%d)",
+				pdr.ID, seid, route, pdr.PDI.FramedRouting)
+		}
+	}
+	if pdr.BBFOuterHeaderRemoval != nil {
+		log.Printf("[Mock] BBF outer header removal for PDR %d in session %d (description=%d)",
+			pdr.ID, seid, *pdr.BBFOuterHeaderRemoval)
+	}
+
+	delete(m.decaps[seid], pdr.ID)
+	if pdr.PDI != nil && pdr.PDI.LocalFTEID != nil {
+		if m.decaps[seid] == nil {
+			m.decaps[seid] = make(map[uint16]protocol.FTEID)
+		}
+		m.decaps[seid][pdr.ID] = *pdr.PDI.LocalFTEID
+		log.Printf("[Mock] GTP-U decap for PDR %d in session %d on F-TEID %s (outer header removal=%v)",
+			pdr.ID, seid, pdr.PDI.LocalFTEID, pdr.OuterHeaderRemoval != nil)
+	}
 
 	return nil
 }
@@ -46,6 +116,8 @@ func (m *MockDataplane) RemovePDR(seid uint64, pdrID uint16) error {
 
 	if m.pdrs[seid] != nil {
 		delete(m.pdrs[seid], pdrID)
+		delete(m.usage[seid], pdrID)
+		delete(m.decaps[seid], pdrID)
 		log.Printf("[Mock] Removed PDR %d from session %d", pdrID, seid)
 	}
 
@@ -64,6 +136,31 @@ func (m *MockDataplane) InstallFAR(seid uint64, far *up.FAR) error {
 	log.Printf("[Mock] Installed FAR %d for session %d (action=0x%02x)",
 		far.ID, seid, far.ApplyAction)
 
+	delete(m.encaps[seid], far.ID)
+	if fp := far.ForwardingParameters; fp != nil && fp.OuterHeaderCreation != nil {
+		if m.encaps[seid] == nil {
+			m.encaps[seid] = make(map[uint32]protocol.OuterHeaderCreation)
+		}
+		ohc := fp.OuterHeaderCreation
+		m.encaps[seid][far.ID] = *ohc
+		log.Printf("[Mock] GTP-U encap for FAR %d in session %d (description=0x%04x, TEID=0x%08x, IPv4=%s, IPv6=%s)",
+			far.ID, seid, ohc.Description, ohc.TEID, ohc.IPv4, ohc.IPv6)
+	}
+
+	if fp := far.ForwardingParameters; fp != nil && fp.PPPoE != nil {
+		log.Printf("[Mock] PPPoE encap for FAR %d in session %d: %s", far.ID, seid, fp.PPPoE)
+	}
+	if fp := far.ForwardingParameters; fp != nil && fp.L2TP != nil {
+		log.Printf("[Mock] L2TP encap for FAR %d in session %d: %s", far.ID, seid, fp.L2TP)
+	}
+
+	if far.ApplyAction&protocol.ApplyActionDuplicate != 0 {
+		for _, dp := range far.DuplicatingParameters {
+			log.Printf("[Mock] Duplicating FAR %d in session %d to interface %d (encap=%v, policy=%q)",
+				far.ID, seid, dp.DestinationInterface, dp.OuterHeaderCreation != nil, dp.ForwardingPolicy)
+		}
+	}
+
 	return nil
 }
 
@@ -73,6 +170,7 @@ func (m *MockDataplane) RemoveFAR(seid uint64, farID uint32) error {
 
 	if m.fars[seid] != nil {
 		delete(m.fars[seid], farID)
+		delete(m.encaps[seid], farID)
 		log.Printf("[Mock] Removed FAR %d from session %d", farID, seid)
 	}
 
@@ -88,7 +186,8 @@ func (m *MockDataplane) InstallQER(seid uint64, qer *up.QER) error {
 	}
 
 	m.qers[seid][qer.ID] = qer
-	log.Printf("[Mock] Installed QER %d for session %d", qer.ID, seid)
+	log.Printf("[Mock] Installed QER %d for session %d (gate=0x%02x, MBR UL/DL=%d/%d kbps)",
+		qer.ID, seid, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
 
 	return nil
 }
@@ -114,7 +213,8 @@ func (m *MockDataplane) InstallURR(seid uint64, urr *up.URR) error {
 	}
 
 	m.urrs[seid][urr.ID] = urr
-	log.Printf("[Mock] Installed URR %d for session %d", urr.ID, seid)
+	log.Printf("[Mock] Installed URR %d for session %d (triggers=0x%04x, volume threshold=%d, time threshold=%ds)",
+		urr.ID, seid, urr.ReportingTriggers, urr.VolumeThreshold, urr.TimeThreshold)
 
 	return nil
 }
@@ -139,6 +239,9 @@ func (m *MockDataplane) DeleteSession(seid uint64) error {
 	delete(m.fars, seid)
 	delete(m.qers, seid)
 	delete(m.urrs, seid)
+	delete(m.usage, seid)
+	delete(m.decaps, seid)
+	delete(m.encaps, seid)
 
 	log.Printf("[Mock] Deleted session %d", seid)
 
@@ -155,3 +258,109 @@ func (m *MockDataplane) GetSessionRules(seid uint64) (int, int, int, int, error)
 
 	return len(m.pdrs[seid]), len(m.fars[seid]), len(m.qers[seid]), len(m.urrs[seid]), nil
 }
+
+// SetPDRUsage sets the cumulative counters PDRUsage reports for a PDR,
+// standing in for the traffic a real dataplane would count.
+func (m *MockDataplane) SetPDRUsage(seid uint64, pdrID uint16, packets, bytes uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.usage[seid] == nil {
+		m.usage[seid] = make(map[uint16][2]uint64)
+	}
+
+	m.usage[seid][pdrID] = [2]uint64{packets, bytes}
+}
+
+func (m *MockDataplane) PDRUsage(seid uint64, pdrID uint16) (uint64, uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, exists := m.pdrs[seid][pdrID]; !exists {
+		return 0, 0, fmt.Errorf("PDR %d not found in session %d", pdrID, seid)
+	}
+
+	counters := m.usage[seid][pdrID]
+	return counters[0], counters[1], nil
+}
+
+// GTPUTunnels returns the GTP-U endpoints programmed for a session: the local
+// F-TEID of each PDR that has one and the Outer Header Creation of each FAR
+// that has one.
+func (m *MockDataplane) GTPUTunnels(seid uint64) (map[uint16]protocol.FTEID, map[uint32]protocol.OuterHeaderCreation) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	decaps := make(map[uint16]protocol.FTEID, len(m.decaps[seid]))
+	for id, fteid := range m.decaps[seid] {
+		decaps[id] = fteid
+	}
+
+	encaps := make(map[uint32]protocol.OuterHeaderCreation, len(m.encaps[seid]))
+	for id, ohc := range m.encaps[seid] {
+		encaps[id] = ohc
+	}
+
+	return decaps, encaps
+}
+
+// SimulatePacket stands in for a packet matching a PDR, recording a copy for
+// each Duplicating Parameters of its FAR if the FAR duplicates. It returns
+// how many copies were made.
+func (m *MockDataplane) SimulatePacket(seid uint64, pdrID uint16, frame []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pdr, ok := m.pdrs[seid][pdrID]
+	if !ok {
+		return 0, fmt.Errorf("PDR %d not found in session %d", pdrID, seid)
+	}
+	far, ok := m.fars[seid][pdr.FAR_ID]
+	if !ok || far.ApplyAction&protocol.ApplyActionDuplicate == 0 {
+		return 0, nil
+	}
+
+	for _, dp := range far.DuplicatingParameters {
+		m.duplicates = append(m.duplicates, &Duplicate{
+			SEID:                 seid,
+			PDRID:                pdrID,
+			FARID:                far.ID,
+			DestinationInterface: dp.DestinationInterface,
+			OuterHeaderCreation:  dp.OuterHeaderCreation,
+			ForwardingPolicy:     dp.ForwardingPolicy,
+			Frame:                append([]byte(nil), frame...),
+		})
+	}
+	if n := len(m.duplicates) - injectionHistory; n > 0 {
+		m.duplicates = append([]*Duplicate(nil), m.duplicates[n:]...)
+	}
+
+	log.Printf("[Mock] FAR %d in session %d duplicated %d bytes %d times", far.ID, seid, len(frame), len(far.DuplicatingParameters))
+	return len(far.DuplicatingParameters), nil
+}
+
+// Duplicates returns the most recent duplicated packets, oldest first.
+func (m *MockDataplane) Duplicates() []*Duplicate {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]*Duplicate(nil), m.duplicates...)
+}
+
+// InjectPacket records a packet the CP sends out through the dataplane.
+func (m *MockDataplane) InjectPacket(pkt *up.InjectedPacket) error {
+	log.Printf("[Mock] Injected %d bytes on %s (session %d, ip=%v)", len(pkt.Data), pkt.Interface, pkt.SEID, pkt.IP)
+	return m.injected.InjectPacket(pkt)
+}
+
+// InjectedPackets returns the most recent injected packets, oldest first.
+func (m *MockDataplane) InjectedPackets() []*up.InjectedPacket {
+	return m.injected.Packets()
+}
+
+// ReplayPacket accepts a buffered packet the UP releases. The mock has no
+// traffic to forward it with, so it is only logged.
+func (m *MockDataplane) ReplayPacket(sourceInterface uint8, frame []byte) error {
+	log.Printf("[Mock] Replayed %d bytes received on source interface %d", len(frame), sourceInterface)
+	return nil
+}
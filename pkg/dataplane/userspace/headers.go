@@ -0,0 +1,296 @@
+package userspace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+const defaultTTL = 64
+
+func isGTPUEncap(ohc *protocol.OuterHeaderCreation) bool {
+	return ohc.Description&(protocol.OuterHeaderCreationGTPUUDPIPv4|protocol.OuterHeaderCreationGTPUUDPIPv6) != 0
+}
+
+// removeOuterHeader returns the packet inside the frame's outer header.
+func removeOuterHeader(description uint8, f *frame) (*ipPacket, error) {
+	outer := f.ip
+
+	switch description {
+	case protocol.OuterHeaderRemovalGTPUUDPIPv4, protocol.OuterHeaderRemovalUDPIPv4, protocol.OuterHeaderRemovalIPv4:
+		if outer.isV6() {
+			return nil, fmt.Errorf("description %d needs an IPv4 outer header", description)
+		}
+	case protocol.OuterHeaderRemovalGTPUUDPIPv6, protocol.OuterHeaderRemovalUDPIPv6, protocol.OuterHeaderRemovalIPv6:
+		if !outer.isV6() {
+			return nil, fmt.Errorf("description %d needs an IPv6 outer header", description)
+		}
+	}
+
+	switch description {
+	case protocol.OuterHeaderRemovalGTPUUDPIPv4, protocol.OuterHeaderRemovalGTPUUDPIPv6, protocol.OuterHeaderRemovalGTPUUDPIP:
+		if f.inner == nil {
+			return nil, fmt.Errorf("not a GTP-U G-PDU")
+		}
+		return f.inner, nil
+	case protocol.OuterHeaderRemovalUDPIPv4, protocol.OuterHeaderRemovalUDPIPv6:
+		if outer.protocol != protoUDP || !outer.hasPorts {
+			return nil, fmt.Errorf("not a UDP packet")
+		}
+		return parseIP(outer.payload[8:])
+	case protocol.OuterHeaderRemovalIPv4, protocol.OuterHeaderRemovalIPv6:
+		if outer.protocol != protoIPIP && outer.protocol != protoIPv6 || outer.payload == nil {
+			return nil, fmt.Errorf("not an IP-in-IP packet")
+		}
+		return parseIP(outer.payload)
+	default:
+		return nil, fmt.Errorf("unsupported description %d", description)
+	}
+}
+
+// createOuterHeader encapsulates the packet as the FAR's Outer Header
+// Creation describes, from the dataplane's GTP-U address.
+func (d *UserspaceDataplane) createOuterHeader(ohc *protocol.OuterHeaderCreation, payload *ipPacket) ([]byte, error) {
+	var dstIP []byte
+	switch {
+	case ohc.Description&(protocol.OuterHeaderCreationGTPUUDPIPv4|protocol.OuterHeaderCreationUDPIPv4|protocol.OuterHeaderCreationIPv4) != 0:
+		dstIP = ohc.IPv4.To4()
+	case ohc.Description&(protocol.OuterHeaderCreationGTPUUDPIPv6|protocol.OuterHeaderCreationUDPIPv6|protocol.OuterHeaderCreationIPv6) != 0:
+		dstIP = ohc.IPv6.To16()
+	default:
+		return nil, fmt.Errorf("unsupported description 0x%04x", ohc.Description)
+	}
+
+	dst, ok := netip.AddrFromSlice(dstIP)
+	if !ok {
+		return nil, fmt.Errorf("no peer address")
+	}
+	src := d.gtpuAddr
+	if !src.IsValid() || src.Is6() != dst.Is6() {
+		return nil, fmt.Errorf("no local address of the peer's family")
+	}
+
+	switch {
+	case isGTPUEncap(ohc):
+		return ipHeader(src, dst, protoUDP, udpHeader(src, dst, gtpuPort, gtpuPort, gtpuHeader(ohc.TEID, payload.data))), nil
+	case ohc.Description&(protocol.OuterHeaderCreationUDPIPv4|protocol.OuterHeaderCreationUDPIPv6) != 0:
+		return ipHeader(src, dst, protoUDP, udpHeader(src, dst, ohc.Port, ohc.Port, payload.data)), nil
+	case payload.isV6():
+		return ipHeader(src, dst, protoIPv6, payload.data), nil
+	default:
+		return ipHeader(src, dst, protoIPIP, payload.data), nil
+	}
+}
+
+func gtpuHeader(teid uint32, payload []byte) []byte {
+	b := make([]byte, gtpuHeaderLen, gtpuHeaderLen+len(payload))
+	b[0] = 0x30 // version 1, GTP
+	b[1] = gtpuMsgGPDU
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], teid)
+	return append(b, payload...)
+}
+
+func udpHeader(src, dst netip.Addr, srcPort, dstPort uint16, payload []byte) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(b[0:2], srcPort)
+	binary.BigEndian.PutUint16(b[2:4], dstPort)
+	binary.BigEndian.PutUint16(b[4:6], uint16(8+len(payload)))
+	b = append(b, payload...)
+
+	sum := checksum(pseudoHeaderSum(src, dst, protoUDP, len(b)), b)
+	if sum == 0 {
+		sum = 0xffff
+	}
+	binary.BigEndian.PutUint16(b[6:8], sum)
+	return b
+}
+
+func ipHeader(src, dst netip.Addr, proto uint8, payload []byte) []byte {
+	if src.Is6() {
+		b := make([]byte, 40, 40+len(payload))
+		b[0] = 0x60
+		binary.BigEndian.PutUint16(b[4:6], uint16(len(payload)))
+		b[6] = proto
+		b[7] = defaultTTL
+		src16, dst16 := src.As16(), dst.As16()
+		copy(b[8:24], src16[:])
+		copy(b[24:40], dst16[:])
+		return append(b, payload...)
+	}
+
+	b := make([]byte, 20, 20+len(payload))
+	b[0] = 0x45
+	binary.BigEndian.PutUint16(b[2:4], uint16(20+len(payload)))
+	b[8] = defaultTTL
+	b[9] = proto
+	src4, dst4 := src.As4(), dst.As4()
+	copy(b[12:16], src4[:])
+	copy(b[16:20], dst4[:])
+	binary.BigEndian.PutUint16(b[10:12], checksum(0, b))
+	return append(b, payload...)
+}
+
+func pseudoHeaderSum(src, dst netip.Addr, proto uint8, length int) uint32 {
+	var sum uint32
+	for _, addr := range [][]byte{src.AsSlice(), dst.AsSlice()} {
+		for i := 0; i < len(addr); i += 2 {
+			sum += uint32(addr[i])<<8 | uint32(addr[i+1])
+		}
+	}
+	return sum + uint32(proto) + uint32(length)
+}
+
+// checksum is the Internet checksum of data, starting from a partial sum.
+func checksum(sum uint32, data []byte) uint16 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
+
+// removeBBFOuterHeader decapsulates the PPP frame of a PPPoE Session frame
+// or L2TP data message, returning it as an untagged frame between the same
+// MAC addresses. The PPP descriptions leave its IP packet, the others the PPP
+// frame itself, with no EtherType.
+func removeBBFOuterHeader(description uint8, f *frame) (*frame, error) {
+	var ppp []byte
+	switch description {
+	case protocol.BBFOuterHeaderRemovalPPPoEEthernet, protocol.BBFOuterHeaderRemovalPPPPPPoE:
+		if f.pppFrame == nil {
+			return nil, fmt.Errorf("not a PPPoE session frame")
+		}
+		ppp = f.pppFrame
+	case protocol.BBFOuterHeaderRemovalL2TP, protocol.BBFOuterHeaderRemovalPPPL2TP:
+		if f.ip == nil || f.ip.protocol != protoUDP || !f.ip.hasPorts || f.ip.dstPort != l2tpPort {
+			return nil, fmt.Errorf("not an L2TP packet")
+		}
+		var err error
+		if ppp, err = parseL2TP(f.ip.payload[8:]); err != nil {
+			return nil, fmt.Errorf("L2TP: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported description %d", description)
+	}
+
+	out := &frame{l3Offset: ethernetHeaderLen}
+	if keepsPPPFrame(description) {
+		out.data = append(bytes.Clone(f.data[:12]), 0, 0)
+		out.pppFrame = ppp
+		return out, nil
+	}
+
+	ip, err := parsePPP(ppp)
+	if err != nil {
+		return nil, fmt.Errorf("PPP: %w", err)
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("not an IP packet in the PPP session")
+	}
+
+	out.etherType = etherTypeIPv4
+	if ip.isV6() {
+		out.etherType = etherTypeIPv6
+	}
+	out.data = make([]byte, ethernetHeaderLen, ethernetHeaderLen+len(ip.data))
+	copy(out.data, f.data[:12])
+	binary.BigEndian.PutUint16(out.data[12:], out.etherType)
+	out.data = append(out.data, ip.data...)
+	out.ip = ip
+	return out, nil
+}
+
+// keepsPPPFrame reports whether a BBF Outer Header Removal leaves the PPP
+// frame, for a PPPoE or L2TP session to carry on, rather than its IP packet.
+func keepsPPPFrame(description uint8) bool {
+	return description == protocol.BBFOuterHeaderRemovalPPPoEEthernet || description == protocol.BBFOuterHeaderRemovalL2TP
+}
+
+// pppFrame puts an IP packet into a PPP frame.
+func pppFrame(ip *ipPacket) []byte {
+	proto := uint16(pppProtocolIPv4)
+	if ip.isV6() {
+		proto = pppProtocolIPv6
+	}
+	return append(binary.BigEndian.AppendUint16(nil, proto), ip.data...)
+}
+
+// pppoeFrame encapsulates a PPP frame into a PPPoE session, behind the
+// session's VLAN tags.
+func pppoeFrame(s *protocol.PPPoESession, src net.HardwareAddr, ppp []byte) []byte {
+	b := append(bytes.Clone(s.PeerMAC), src...)
+	for _, tag := range []struct {
+		tpid uint16
+		tag  *protocol.VLANTag
+	}{
+		{etherTypeQinQ, s.STag},
+		{etherTypeVLAN, s.CTag},
+	} {
+		if tag.tag == nil {
+			continue
+		}
+		tci := tag.tag.VID&0x0fff | uint16(tag.tag.PCP)<<13
+		if tag.tag.DEI {
+			tci |= 0x1000
+		}
+		b = binary.BigEndian.AppendUint16(b, tag.tpid)
+		b = binary.BigEndian.AppendUint16(b, tci)
+	}
+
+	b = binary.BigEndian.AppendUint16(b, etherTypePPPoESession)
+	b = append(b, 0x11, 0x00)
+	b = binary.BigEndian.AppendUint16(b, s.SessionID)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(ppp)))
+	return append(b, ppp...)
+}
+
+// l2tpPacket carries a PPP frame to the LNS in an L2TPv2 data message, from
+// the dataplane's L2TP address.
+func (d *UserspaceDataplane) l2tpPacket(s *protocol.L2TPSession, ppp []byte) ([]byte, error) {
+	lns := s.LNS.To4()
+	if lns == nil {
+		lns = s.LNS.To16()
+	}
+	dst, ok := netip.AddrFromSlice(lns)
+	if !ok {
+		return nil, fmt.Errorf("no LNS address")
+	}
+	src := d.l2tpAddr
+	if !src.IsValid() || src.Is6() != dst.Is6() {
+		return nil, fmt.Errorf("no local address of the LNS's family")
+	}
+
+	b := make([]byte, 8, 10+len(ppp))
+	binary.BigEndian.PutUint16(b[0:2], 0x4002) // length present, version 2
+	binary.BigEndian.PutUint16(b[2:4], uint16(10+len(ppp)))
+	binary.BigEndian.PutUint16(b[4:6], s.TunnelID)
+	binary.BigEndian.PutUint16(b[6:8], s.SessionID)
+	b = append(b, 0xff, 0x03) // HDLC address and control
+	b = append(b, ppp...)
+
+	return ipHeader(src, dst, protoUDP, udpHeader(src, dst, l2tpPort, l2tpPort, b)), nil
+}
+
+// rebuildFrame puts a new IP packet behind the frame's L2 header.
+func rebuildFrame(f *frame, ip []byte) []byte {
+	out := make([]byte, f.l3Offset, f.l3Offset+len(ip))
+	copy(out, f.data[:f.l3Offset])
+
+	etherType := uint16(etherTypeIPv4)
+	if ip[0]>>4 == 6 {
+		etherType = etherTypeIPv6
+	}
+	binary.BigEndian.PutUint16(out[f.l3Offset-2:], etherType)
+
+	return append(out, ip...)
+}
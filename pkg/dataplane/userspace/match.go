@@ -0,0 +1,214 @@
+package userspace
+
+import (
+	"net/netip"
+	"slices"
+
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+var (
+	ipOptionKinds = map[string][]uint8{
+		"ssrr": {137},
+		"lsrr": {131},
+		"rr":   {7},
+		"ts":   {68},
+	}
+	// sack matches both SACK-permitted and SACK blocks.
+	tcpOptionKinds = map[string][]uint8{
+		"mss":    {2},
+		"window": {3},
+		"sack":   {4, 5},
+		"ts":     {8},
+		"cc":     {11},
+	}
+	tcpFlagBits = map[string]uint8{
+		"fin": 0x01,
+		"syn": 0x02,
+		"rst": 0x04,
+		"psh": 0x08,
+		"ack": 0x10,
+		"urg": 0x20,
+	}
+)
+
+// match reports whether the PDR's PDI matches a frame received on
+// sourceInterface, returning the IP packet it matched: the T-PDU of a PDR
+// with a local F-TEID, otherwise the frame's own packet. L2 filter PDRs match
+// on the EtherType alone, and Ethernet packet filter PDRs on the frame's
+// header, and return nil.
+func (s *pdrState) match(sourceInterface uint8, f *frame) (*ipPacket, bool) {
+	pdi := s.pdr.PDI
+	if pdi == nil || pdi.SourceInterface != sourceInterface {
+		return nil, false
+	}
+
+	if s.l2 != nil {
+		return nil, f.etherType == s.l2.EtherType
+	}
+	if s.eth != nil {
+		return nil, slices.ContainsFunc(s.eth, func(m *protocol.EthernetHeaderMatch) bool {
+			return m.Matches(f.data)
+		})
+	}
+
+	ip := f.ip
+	if fteid := pdi.LocalFTEID; fteid != nil {
+		if f.inner == nil || f.teid != fteid.TEID || !fteidHasAddr(fteid.IPv4, f.ip.dst) && !fteidHasAddr(fteid.IPv6, f.ip.dst) {
+			return nil, false
+		}
+		ip = f.inner
+	}
+	if ip == nil {
+		return nil, false
+	}
+
+	if len(s.ue) > 0 {
+		ue := ip.dst
+		if sourceInterface == protocol.SourceInterfaceAccess {
+			ue = ip.src
+		}
+		if !prefixesContain(s.ue, ue) {
+			return nil, false
+		}
+	}
+
+	dir := ipfilter.DirectionOut
+	if sourceInterface == protocol.SourceInterfaceAccess {
+		dir = ipfilter.DirectionIn
+	}
+	if s.sdf != nil && !matchSDF(s.sdf, dir, s.ue, ip) {
+		return nil, false
+	}
+
+	return ip, true
+}
+
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func fteidHasAddr(ip []byte, addr netip.Addr) bool {
+	fteidAddr, ok := netip.AddrFromSlice(ip)
+	return ok && fteidAddr.Unmap() == addr
+}
+
+// matchSDF matches the SDF filter's ToS, SPI and flow label, when set, and
+// its flow description, swapping source and destination when it is written
+// for the other direction than dir.
+func matchSDF(sdf *protocol.SDFFilter, dir ipfilter.Direction, ue []netip.Prefix, ip *ipPacket) bool {
+	if tos, mask := uint8(sdf.ToS>>8), uint8(sdf.ToS); mask != 0 && ip.tos&mask != tos&mask {
+		return false
+	}
+	if sdf.SPI != 0 && ip.spi != sdf.SPI {
+		return false
+	}
+	if sdf.FlowLabel != 0 && (!ip.isV6() || ip.flowLabel != sdf.FlowLabel) {
+		return false
+	}
+
+	if fd := sdf.FlowDescription; fd != nil {
+		fd = fd.Oriented(dir)
+		if fd.Protocol != ipfilter.ProtocolAny && fd.Protocol != ip.protocol {
+			return false
+		}
+		if !matchEndpoint(fd.Src, ue, ip.src, ip.srcPort, ip) || !matchEndpoint(fd.Dst, ue, ip.dst, ip.dstPort, ip) {
+			return false
+		}
+		if !matchOptions(fd.Options, ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchEndpoint(e ipfilter.Endpoint, ue []netip.Prefix, addr netip.Addr, port uint16, ip *ipPacket) bool {
+	var in bool
+	switch e.Kind {
+	case ipfilter.AddressAny:
+		in = true
+	case ipfilter.AddressAssigned:
+		in = prefixesContain(ue, addr)
+	default:
+		in = e.Masked().Contains(addr)
+	}
+	if e.Negate {
+		in = !in
+	}
+	if !in {
+		return false
+	}
+
+	if len(e.Ports) == 0 {
+		return true
+	}
+	if !ip.hasPorts {
+		return false
+	}
+	for _, r := range e.Ports {
+		if port >= r.First && port <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOptions(o ipfilter.Options, ip *ipPacket) bool {
+	if o.Frag && !ip.fragment {
+		return false
+	}
+
+	for _, flag := range o.IPOptions {
+		if hasAnyOption(ip.ipOptions, ipOptionKinds[flag.Name]) == flag.Negate {
+			return false
+		}
+	}
+
+	isTCP := ip.protocol == protoTCP && ip.hasPorts
+	if (len(o.TCPOptions) > 0 || len(o.TCPFlags) > 0 || o.Established || o.Setup) && !isTCP {
+		return false
+	}
+	for _, flag := range o.TCPOptions {
+		if hasAnyOption(ip.tcpOptions, tcpOptionKinds[flag.Name]) == flag.Negate {
+			return false
+		}
+	}
+	for _, flag := range o.TCPFlags {
+		if (ip.tcpFlags&tcpFlagBits[flag.Name] != 0) == flag.Negate {
+			return false
+		}
+	}
+	if o.Established && ip.tcpFlags&(tcpFlagBits["rst"]|tcpFlagBits["ack"]) == 0 {
+		return false
+	}
+	if o.Setup && ip.tcpFlags&(tcpFlagBits["syn"]|tcpFlagBits["ack"]) != tcpFlagBits["syn"] {
+		return false
+	}
+
+	if len(o.ICMPTypes) > 0 {
+		if ip.protocol != protoICMP && ip.protocol != protoICMPv6 || ip.payload == nil {
+			return false
+		}
+		if !slices.Contains(o.ICMPTypes, ip.icmpType) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasAnyOption(options []byte, kinds []uint8) bool {
+	for _, kind := range kinds {
+		if hasOption(options, kind) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,404 @@
+package userspace
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+const (
+	etherTypeIPv4     = 0x0800
+	etherTypeIPv6     = 0x86dd
+	etherTypeVLAN     = 0x8100
+	etherTypeQinQ     = 0x88a8
+	ethernetHeaderLen = 14
+	vlanTagLen        = 4
+
+	etherTypePPPoESession = 0x8864
+	pppoeHeaderLen        = 6
+	pppProtocolIPv4       = 0x0021
+	pppProtocolIPv6       = 0x0057
+
+	l2tpPort = 1701
+
+	protoIPIP    = 4
+	protoIPv6    = 41
+	protoESP     = 50
+	protoAH      = 51
+	protoICMP    = 1
+	protoTCP     = 6
+	protoUDP     = 17
+	protoICMPv6  = 58
+	protoSCTP    = 132
+	protoHopOpts = 0
+	protoRouting = 43
+	protoFrag    = 44
+	protoDstOpts = 60
+
+	gtpuPort      = 2152
+	gtpuHeaderLen = 8
+	gtpuMsgGPDU   = 0xff
+)
+
+// frame is a parsed Ethernet frame. The EtherType is the outermost one, as
+// the L2 filters of Application IDs match it; VLAN tags are skipped to find
+// the IP packet.
+type frame struct {
+	data      []byte
+	etherType uint16
+	// l3Offset is where the IP packet starts, zero for non-IP frames.
+	l3Offset int
+	ip       *ipPacket
+	// teid and inner are set for GTP-U G-PDUs.
+	teid  uint32
+	inner *ipPacket
+	// pppFrame is the PPP frame, protocol and information, of a PPPoE
+	// Session frame, and ppp its IP packet.
+	pppFrame []byte
+	ppp      *ipPacket
+}
+
+// ipPacket holds the header fields SDF filters and QERs look at.
+type ipPacket struct {
+	data      []byte
+	src, dst  netip.Addr
+	protocol  uint8
+	tos       uint8
+	flowLabel uint32
+	// fragment is set for all but the first fragment, which alone has the
+	// L4 header.
+	fragment  bool
+	ipOptions []byte
+	// payload is the L4 header and data, nil for non-first fragments.
+	payload    []byte
+	hasPorts   bool
+	srcPort    uint16
+	dstPort    uint16
+	tcpFlags   uint8
+	tcpOptions []byte
+	icmpType   uint8
+	spi        uint32
+}
+
+func (p *ipPacket) isV6() bool {
+	return p.src.Is6()
+}
+
+func parseFrame(data []byte) (*frame, error) {
+	if len(data) < ethernetHeaderLen {
+		return nil, fmt.Errorf("short Ethernet frame (%d bytes)", len(data))
+	}
+
+	f := &frame{data: data, etherType: binary.BigEndian.Uint16(data[12:14])}
+
+	etherType, offset := f.etherType, ethernetHeaderLen
+	for etherType == etherTypeVLAN || etherType == etherTypeQinQ {
+		if len(data) < offset+vlanTagLen {
+			return nil, fmt.Errorf("short VLAN tag")
+		}
+		etherType = binary.BigEndian.Uint16(data[offset+2 : offset+4])
+		offset += vlanTagLen
+	}
+
+	if etherType == etherTypePPPoESession {
+		ppp, err := parsePPPoE(data[offset:])
+		if err == nil {
+			f.ppp, err = parsePPP(ppp)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("PPPoE: %w", err)
+		}
+		f.pppFrame = ppp
+		return f, nil
+	}
+
+	if etherType != etherTypeIPv4 && etherType != etherTypeIPv6 {
+		return f, nil
+	}
+
+	ip, err := parseIP(data[offset:])
+	if err != nil {
+		return nil, err
+	}
+	f.ip, f.l3Offset = ip, offset
+
+	if ip.protocol == protoUDP && ip.hasPorts && ip.dstPort == gtpuPort {
+		if teid, inner, ok := parseGTPU(ip.payload[8:]); ok {
+			if f.inner, err = parseIP(inner); err != nil {
+				return nil, fmt.Errorf("GTP-U payload: %w", err)
+			}
+			f.teid = teid
+		}
+	}
+
+	return f, nil
+}
+
+// parsePPPoE returns the PPP frame of a PPPoE Session stage frame, or nil
+// if it is not one.
+func parsePPPoE(data []byte) ([]byte, error) {
+	if len(data) < pppoeHeaderLen+2 {
+		return nil, fmt.Errorf("short header")
+	}
+	if data[0] != 0x11 || data[1] != 0 {
+		return nil, nil
+	}
+
+	length := int(binary.BigEndian.Uint16(data[4:6]))
+	if len(data) < pppoeHeaderLen+length || length < 2 {
+		return nil, fmt.Errorf("length %d exceeds frame", length)
+	}
+
+	return data[pppoeHeaderLen : pppoeHeaderLen+length], nil
+}
+
+// parsePPP returns the IP packet of a PPP frame, or nil if it carries LCP,
+// an NCP or another protocol.
+func parsePPP(ppp []byte) (*ipPacket, error) {
+	if len(ppp) < 2 {
+		return nil, nil
+	}
+
+	switch binary.BigEndian.Uint16(ppp) {
+	case pppProtocolIPv4, pppProtocolIPv6:
+		return parseIP(ppp[2:])
+	default:
+		return nil, nil
+	}
+}
+
+// parseL2TP returns the PPP frame of an L2TPv2 data message, without the
+// HDLC address and control field.
+func parseL2TP(data []byte) ([]byte, error) {
+	if len(data) < 6 {
+		return nil, fmt.Errorf("short header")
+	}
+
+	flags := binary.BigEndian.Uint16(data)
+	switch {
+	case flags&0x000f != 2:
+		return nil, fmt.Errorf("version %d", flags&0x000f)
+	case flags&0x8000 != 0:
+		return nil, fmt.Errorf("control message")
+	}
+
+	offset := 6
+	if flags&0x4000 != 0 { // length
+		offset += 2
+	}
+	if flags&0x0800 != 0 { // Ns and Nr
+		offset += 4
+	}
+	if flags&0x0200 != 0 { // offset size and pad
+		if len(data) < offset+2 {
+			return nil, fmt.Errorf("short header")
+		}
+		offset += 2 + int(binary.BigEndian.Uint16(data[offset:]))
+	}
+	if len(data) < offset {
+		return nil, fmt.Errorf("short header")
+	}
+
+	ppp := data[offset:]
+	if len(ppp) >= 2 && ppp[0] == 0xff && ppp[1] == 0x03 {
+		ppp = ppp[2:]
+	}
+	return ppp, nil
+}
+
+func parseIP(data []byte) (*ipPacket, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("empty IP packet")
+	}
+
+	switch data[0] >> 4 {
+	case 4:
+		return parseIPv4(data)
+	case 6:
+		return parseIPv6(data)
+	default:
+		return nil, fmt.Errorf("unknown IP version %d", data[0]>>4)
+	}
+}
+
+func parseIPv4(data []byte) (*ipPacket, error) {
+	if len(data) < 20 {
+		return nil, fmt.Errorf("short IPv4 header")
+	}
+
+	headerLen := int(data[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+	if headerLen < 20 || totalLen < headerLen || totalLen > len(data) {
+		return nil, fmt.Errorf("invalid IPv4 header or total length")
+	}
+	// Drop Ethernet padding.
+	data = data[:totalLen]
+
+	p := &ipPacket{
+		data:      data,
+		src:       netip.AddrFrom4([4]byte(data[12:16])),
+		dst:       netip.AddrFrom4([4]byte(data[16:20])),
+		protocol:  data[9],
+		tos:       data[1],
+		ipOptions: data[20:headerLen],
+		fragment:  binary.BigEndian.Uint16(data[6:8])&0x1fff != 0,
+	}
+
+	if !p.fragment {
+		p.payload = data[headerLen:]
+		p.parseL4()
+	}
+
+	return p, nil
+}
+
+func parseIPv6(data []byte) (*ipPacket, error) {
+	if len(data) < 40 {
+		return nil, fmt.Errorf("short IPv6 header")
+	}
+
+	payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+	if 40+payloadLen > len(data) {
+		return nil, fmt.Errorf("invalid IPv6 payload length")
+	}
+	data = data[:40+payloadLen]
+
+	p := &ipPacket{
+		data:      data,
+		src:       netip.AddrFrom16([16]byte(data[8:24])),
+		dst:       netip.AddrFrom16([16]byte(data[24:40])),
+		tos:       uint8(binary.BigEndian.Uint16(data[0:2]) >> 4),
+		flowLabel: binary.BigEndian.Uint32(data[0:4]) & 0xfffff,
+	}
+
+	next, payload := data[6], data[40:]
+	for {
+		switch next {
+		case protoHopOpts, protoRouting, protoDstOpts:
+			if len(payload) < 2 || len(payload) < (int(payload[1])+1)*8 {
+				return nil, fmt.Errorf("short IPv6 extension header")
+			}
+			next, payload = payload[0], payload[(int(payload[1])+1)*8:]
+			continue
+		case protoFrag:
+			if len(payload) < 8 {
+				return nil, fmt.Errorf("short IPv6 fragment header")
+			}
+			if binary.BigEndian.Uint16(payload[2:4])>>3 != 0 {
+				p.fragment = true
+			}
+			next, payload = payload[0], payload[8:]
+			continue
+		}
+		break
+	}
+
+	p.protocol = next
+	if !p.fragment {
+		p.payload = payload
+		p.parseL4()
+	}
+
+	return p, nil
+}
+
+// parseL4 reads the ports, TCP flags and options, ICMP type or IPsec SPI.
+// A truncated L4 header leaves them unset.
+func (p *ipPacket) parseL4() {
+	l4 := p.payload
+
+	switch p.protocol {
+	case protoTCP:
+		if len(l4) < 20 {
+			return
+		}
+		p.hasPorts = true
+		p.srcPort = binary.BigEndian.Uint16(l4[0:2])
+		p.dstPort = binary.BigEndian.Uint16(l4[2:4])
+		p.tcpFlags = l4[13] & 0x3f
+		if dataOffset := int(l4[12]>>4) * 4; dataOffset > 20 && dataOffset <= len(l4) {
+			p.tcpOptions = l4[20:dataOffset]
+		}
+	case protoUDP, protoSCTP:
+		if len(l4) < 8 {
+			return
+		}
+		p.hasPorts = true
+		p.srcPort = binary.BigEndian.Uint16(l4[0:2])
+		p.dstPort = binary.BigEndian.Uint16(l4[2:4])
+	case protoICMP, protoICMPv6:
+		if len(l4) >= 1 {
+			p.icmpType = l4[0]
+		}
+	case protoESP:
+		if len(l4) >= 4 {
+			p.spi = binary.BigEndian.Uint32(l4[0:4])
+		}
+	case protoAH:
+		if len(l4) >= 8 {
+			p.spi = binary.BigEndian.Uint32(l4[4:8])
+		}
+	}
+}
+
+// parseGTPU returns the TEID and T-PDU of a GTP-U G-PDU. Other GTP-U
+// messages, such as Echo Requests, are not tunnelled traffic.
+func parseGTPU(data []byte) (uint32, []byte, bool) {
+	if len(data) < gtpuHeaderLen {
+		return 0, nil, false
+	}
+
+	flags := data[0]
+	if flags>>5 != 1 || flags&0x10 == 0 || data[1] != gtpuMsgGPDU {
+		return 0, nil, false
+	}
+
+	end := gtpuHeaderLen + int(binary.BigEndian.Uint16(data[2:4]))
+	if end > len(data) {
+		return 0, nil, false
+	}
+	teid := binary.BigEndian.Uint32(data[4:8])
+
+	offset := gtpuHeaderLen
+	if flags&0x07 != 0 {
+		// Sequence number, N-PDU number and the first extension header
+		// type, followed by the extension header chain.
+		if end < offset+4 {
+			return 0, nil, false
+		}
+		next := data[offset+3]
+		offset += 4
+		for next != 0 {
+			if end < offset+1 || data[offset] == 0 || end < offset+int(data[offset])*4 {
+				return 0, nil, false
+			}
+			extLen := int(data[offset]) * 4
+			next = data[offset+extLen-1]
+			offset += extLen
+		}
+	}
+
+	return teid, data[offset:end], true
+}
+
+// hasOption reports whether a list of IPv4 or TCP options, which share
+// their encoding, includes the option kind.
+func hasOption(options []byte, kind uint8) bool {
+	for i := 0; i < len(options); {
+		switch options[i] {
+		case 0:
+			return false
+		case 1:
+			i++
+			continue
+		}
+		if options[i] == kind {
+			return true
+		}
+		if i+1 >= len(options) || options[i+1] < 2 {
+			return false
+		}
+		i += int(options[i+1])
+	}
+	return false
+}
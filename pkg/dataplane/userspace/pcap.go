@@ -0,0 +1,140 @@
+package userspace
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Classic libpcap file format, as written by tcpdump -w.
+const (
+	pcapMagicMicro   = 0xa1b2c3d4
+	pcapMagicNano    = 0xa1b23c4d
+	pcapHeaderLen    = 24
+	pcapRecordLen    = 16
+	pcapSnapLen      = 65535
+	pcapMaxRecord    = 256 << 10
+	linkTypeEthernet = 1
+)
+
+// ReplayPcap injects every frame of a pcap capture of Ethernet frames as
+// received on sourceInterface at its capture time, and returns the results
+// in order.
+func (d *UserspaceDataplane) ReplayPcap(r io.Reader, sourceInterface uint8) ([]*Packet, error) {
+	pr, err := newPcapReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var packets []*Packet
+	for {
+		ts, data, err := pr.next()
+		if err == io.EOF {
+			return packets, nil
+		}
+		if err != nil {
+			return packets, err
+		}
+		packets = append(packets, d.InjectAt(ts, sourceInterface, data))
+	}
+}
+
+type pcapReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	nano  bool
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	header := make([]byte, pcapHeaderLen)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("read pcap header: %w", err)
+	}
+
+	pr := &pcapReader{r: r}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		switch order.Uint32(header[0:4]) {
+		case pcapMagicMicro:
+			pr.order = order
+		case pcapMagicNano:
+			pr.order, pr.nano = order, true
+		}
+	}
+	if pr.order == nil {
+		return nil, fmt.Errorf("not a pcap file")
+	}
+
+	if linkType := pr.order.Uint32(header[20:24]) & 0x0fffffff; linkType != linkTypeEthernet {
+		return nil, fmt.Errorf("unsupported pcap link type %d, need Ethernet", linkType)
+	}
+
+	return pr, nil
+}
+
+func (pr *pcapReader) next() (time.Time, []byte, error) {
+	record := make([]byte, pcapRecordLen)
+	if _, err := io.ReadFull(pr.r, record); err != nil {
+		if err == io.EOF {
+			return time.Time{}, nil, io.EOF
+		}
+		return time.Time{}, nil, fmt.Errorf("read pcap record: %w", err)
+	}
+
+	sec := int64(pr.order.Uint32(record[0:4]))
+	frac := int64(pr.order.Uint32(record[4:8]))
+	if !pr.nano {
+		frac *= int64(time.Microsecond)
+	}
+
+	capLen := pr.order.Uint32(record[8:12])
+	if capLen > pcapMaxRecord {
+		return time.Time{}, nil, fmt.Errorf("pcap record of %d bytes", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(pr.r, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return time.Time{}, nil, fmt.Errorf("read pcap record: %w", err)
+	}
+
+	return time.Unix(sec, frac), data, nil
+}
+
+// PcapWriter writes frames, such as the Frame of forwarded or punted
+// packets, to a pcap capture with microsecond timestamps.
+type PcapWriter struct {
+	w io.Writer
+}
+
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	header := make([]byte, pcapHeaderLen)
+	binary.LittleEndian.PutUint32(header[0:4], pcapMagicMicro)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:24], linkTypeEthernet)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("write pcap header: %w", err)
+	}
+
+	return &PcapWriter{w: w}, nil
+}
+
+func (pw *PcapWriter) WriteFrame(ts time.Time, data []byte) error {
+	record := make([]byte, pcapRecordLen, pcapRecordLen+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(ts.Nanosecond()/int(time.Microsecond)))
+	binary.LittleEndian.PutUint32(record[8:12], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[12:16], uint32(len(data)))
+
+	if _, err := pw.w.Write(append(record, data...)); err != nil {
+		return fmt.Errorf("write pcap record: %w", err)
+	}
+
+	return nil
+}
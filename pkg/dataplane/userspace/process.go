@@ -0,0 +1,456 @@
+package userspace
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+)
+
+type Verdict uint8
+
+const (
+	VerdictDropped Verdict = iota
+	VerdictForwarded
+	VerdictPunted
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case VerdictForwarded:
+		return "forwarded"
+	case VerdictPunted:
+		return "punted"
+	default:
+		return "dropped"
+	}
+}
+
+// Packet is what became of an injected frame.
+type Packet struct {
+	Timestamp       time.Time
+	SourceInterface uint8
+	Verdict         Verdict
+	// SEID, PDRID and FARID identify the rules applied. They are zero if
+	// no PDR matched.
+	SEID  uint64
+	PDRID uint16
+	FARID uint32
+	// DestinationInterface is the FAR's destination for forwarded and
+	// punted packets.
+	DestinationInterface uint8
+	// Frame is the frame as it leaves the dataplane, after outer header
+	// removal and creation.
+	Frame []byte
+	// Reason says why a packet was dropped.
+	Reason string
+	// Duplicates are the copies a FAR with the DUPL action made, one per
+	// Duplicating Parameters.
+	Duplicates []*Duplicate
+}
+
+// Duplicate is a copy of a packet on its way to a duplication destination.
+type Duplicate struct {
+	DestinationInterface uint8
+	ForwardingPolicy     string
+	// Frame is the copy after outer header removal and the creation of the
+	// Duplicating Parameters' outer header.
+	Frame []byte
+}
+
+// Inject processes a frame received on sourceInterface now.
+func (d *UserspaceDataplane) Inject(sourceInterface uint8, data []byte) *Packet {
+	return d.InjectAt(time.Now(), sourceInterface, data)
+}
+
+// InjectAt processes a frame received on sourceInterface at ts, which drives
+// the QER rate limits. Timestamps should not go backwards.
+func (d *UserspaceDataplane) InjectAt(ts time.Time, sourceInterface uint8, data []byte) *Packet {
+	d.mu.Lock()
+	pkt := d.process(ts, sourceInterface, data)
+	d.mu.Unlock()
+
+	if d.output != nil {
+		d.output(pkt)
+	}
+
+	return pkt
+}
+
+// InjectPacket records a packet the CP sends out through the dataplane.
+// Unlike Inject, which processes a frame the dataplane receives, it applies
+// no rules.
+func (d *UserspaceDataplane) InjectPacket(pkt *up.InjectedPacket) error {
+	return d.injected.InjectPacket(pkt)
+}
+
+// InjectedPackets returns the packets the CP has injected, oldest first.
+func (d *UserspaceDataplane) InjectedPackets() []*up.InjectedPacket {
+	return d.injected.Packets()
+}
+
+// ReplayPacket processes a buffered frame the UP releases as if it had just
+// been received.
+func (d *UserspaceDataplane) ReplayPacket(sourceInterface uint8, frame []byte) error {
+	d.Inject(sourceInterface, frame)
+	return nil
+}
+
+// ForwardPacket processes a frame another dataplane diverted for the UP to
+// forward, returning the frame as it leaves. Duplicates are not made.
+func (d *UserspaceDataplane) ForwardPacket(sourceInterface uint8, frame []byte) ([]byte, error) {
+	pkt := d.Inject(sourceInterface, frame)
+	switch pkt.Verdict {
+	case VerdictForwarded:
+		return pkt.Frame, nil
+	case VerdictPunted:
+		return nil, fmt.Errorf("PDR %d punts", pkt.PDRID)
+	default:
+		return nil, fmt.Errorf("dropped: %s", pkt.Reason)
+	}
+}
+
+// ClassifyPunt finds the PDR a frame received on sourceInterface matches, as
+// Inject would, and whether its FAR punts the frame, without counting the
+// frame or applying QERs. It lets the UP tag the packets another dataplane
+// punts.
+func (d *UserspaceDataplane) ClassifyPunt(sourceInterface uint8, data []byte) (uint64, uint16, bool) {
+	f, err := parseFrame(data)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.classify(sourceInterface, f)
+	if c == nil {
+		return 0, 0, false
+	}
+
+	seid, pdrID := c.seid, c.pdr.pdr.ID
+	far, ok := c.session.fars[c.pdr.pdr.FAR_ID]
+	if !ok || far.ApplyAction&protocol.ApplyActionDrop != 0 || far.ApplyAction&protocol.ApplyActionForward == 0 {
+		return seid, pdrID, false
+	}
+	if c.pdr.sdf != nil && c.pdr.sdf.FlowDescription != nil && c.pdr.sdf.FlowDescription.Action == ipfilter.ActionDeny {
+		return seid, pdrID, false
+	}
+	// PPP frames a BBF Outer Header Removal keeps are forwarded whatever
+	// they carry.
+	if ohr := c.pdr.pdr.BBFOuterHeaderRemoval; ohr != nil && keepsPPPFrame(*ohr) {
+		return seid, pdrID, false
+	}
+	return seid, pdrID, c.ip == nil || isPunt(c.pdr, far)
+}
+
+// classification is the PDR a frame matched.
+type classification struct {
+	seid    uint64
+	session *sessionState
+	pdr     *pdrState
+	ip      *ipPacket
+}
+
+func (d *UserspaceDataplane) process(ts time.Time, sourceInterface uint8, data []byte) *Packet {
+	pkt := &Packet{
+		Timestamp:       ts,
+		SourceInterface: sourceInterface,
+		Frame:           data,
+	}
+
+	f, err := parseFrame(data)
+	if err != nil {
+		return pkt.drop("malformed frame: %v", err)
+	}
+
+	c := d.classify(sourceInterface, f)
+	if c == nil {
+		return pkt.drop("no matching PDR")
+	}
+
+	pdr := c.pdr.pdr
+	pkt.SEID, pkt.PDRID, pkt.FARID = c.seid, pdr.ID, pdr.FAR_ID
+
+	// Like the VPP dataplane, count every packet the PDR matches, whatever
+	// its FAR and QERs then do with it.
+	size := len(data) - f.l3Offset
+	if c.ip != nil {
+		size = len(c.ip.data)
+	}
+	c.pdr.packets++
+	c.pdr.bytes += uint64(size)
+
+	far, ok := c.session.fars[pdr.FAR_ID]
+	switch {
+	case !ok:
+		return pkt.drop("FAR %d not installed", pdr.FAR_ID)
+	case c.pdr.sdf != nil && c.pdr.sdf.FlowDescription != nil && c.pdr.sdf.FlowDescription.Action == ipfilter.ActionDeny:
+		return pkt.drop("flow description denies")
+	case far.ApplyAction&protocol.ApplyActionDrop != 0:
+		return pkt.drop("FAR %d drops", far.ID)
+	case far.ApplyAction&protocol.ApplyActionBuffer != 0:
+		return pkt.drop("FAR %d buffers, left to the UP", far.ID)
+	case far.ApplyAction&protocol.ApplyActionForward == 0:
+		return pkt.drop("FAR %d action 0x%02x does not forward", far.ID, far.ApplyAction)
+	}
+
+	if reason := enforceQERs(c.session, pdr, size, ts); reason != "" {
+		return pkt.drop("%s", reason)
+	}
+
+	if far.ForwardingParameters != nil {
+		pkt.DestinationInterface = far.ForwardingParameters.DestinationInterface
+	}
+
+	payload := c.ip
+	if pdr.BBFOuterHeaderRemoval != nil {
+		if f, err = removeBBFOuterHeader(*pdr.BBFOuterHeaderRemoval, f); err != nil {
+			return pkt.drop("BBF outer header removal: %v", err)
+		}
+		if f.pppFrame != nil {
+			return d.forwardPPP(pkt, f, far, data)
+		}
+		payload = f.ip
+	}
+
+	if payload == nil {
+		pkt.Duplicates = duplicateFrame(far, data)
+		pkt.Verdict = VerdictPunted
+		return pkt
+	}
+
+	if pdr.OuterHeaderRemoval != nil {
+		if payload, err = removeOuterHeader(*pdr.OuterHeaderRemoval, f); err != nil {
+			return pkt.drop("outer header removal: %v", err)
+		}
+	}
+
+	if pkt.Duplicates, err = d.duplicate(far, f, payload); err != nil {
+		return pkt.drop("duplication: %v", err)
+	}
+
+	if isPunt(c.pdr, far) {
+		pkt.Verdict = VerdictPunted
+		pkt.Frame = rebuildFrame(f, payload.data)
+		return pkt
+	}
+
+	if fp := far.ForwardingParameters; fp != nil && fp.PPPoE != nil {
+		pkt.Verdict = VerdictForwarded
+		pkt.Frame = pppoeFrame(fp.PPPoE, d.sourceMAC(f), pppFrame(payload))
+		return pkt
+	}
+
+	out := payload.data
+	if fp := far.ForwardingParameters; fp != nil && fp.OuterHeaderCreation != nil {
+		if out, err = d.createOuterHeader(fp.OuterHeaderCreation, payload); err != nil {
+			return pkt.drop("outer header creation: %v", err)
+		}
+	}
+	if fp := far.ForwardingParameters; fp != nil && fp.L2TP != nil {
+		if out, err = d.l2tpPacket(fp.L2TP, pppFrame(payload)); err != nil {
+			return pkt.drop("L2TP outer header creation: %v", err)
+		}
+	}
+
+	pkt.Verdict = VerdictForwarded
+	pkt.Frame = rebuildFrame(f, out)
+	return pkt
+}
+
+// forwardPPP sends a PPP frame a BBF Outer Header Removal left into the
+// FAR's PPPoE or L2TP session, as a LAC does between the subscriber and the
+// LNS.
+func (d *UserspaceDataplane) forwardPPP(pkt *Packet, f *frame, far *up.FAR, data []byte) *Packet {
+	fp := far.ForwardingParameters
+	switch {
+	case fp != nil && fp.PPPoE != nil:
+		pkt.Frame = pppoeFrame(fp.PPPoE, d.sourceMAC(f), f.pppFrame)
+	case fp != nil && fp.L2TP != nil:
+		out, err := d.l2tpPacket(fp.L2TP, f.pppFrame)
+		if err != nil {
+			return pkt.drop("L2TP outer header creation: %v", err)
+		}
+		pkt.Frame = rebuildFrame(f, out)
+	default:
+		return pkt.drop("FAR %d has no PPPoE or L2TP session for PPP frames", far.ID)
+	}
+
+	pkt.Duplicates = duplicateFrame(far, data)
+	pkt.Verdict = VerdictForwarded
+	return pkt
+}
+
+// sourceMAC is the source of the frames the dataplane builds.
+func (d *UserspaceDataplane) sourceMAC(f *frame) net.HardwareAddr {
+	if d.mac != nil {
+		return d.mac
+	}
+	return net.HardwareAddr(bytes.Clone(f.data[:6]))
+}
+
+func (p *Packet) drop(format string, args ...any) *Packet {
+	p.Verdict = VerdictDropped
+	p.Reason = fmt.Sprintf(format, args...)
+	p.Duplicates = nil
+	return p
+}
+
+// duplicate copies an IP packet for each Duplicating Parameters of a FAR
+// with the DUPL action, adding the outer header each asks for.
+func (d *UserspaceDataplane) duplicate(far *up.FAR, f *frame, payload *ipPacket) ([]*Duplicate, error) {
+	if far.ApplyAction&protocol.ApplyActionDuplicate == 0 {
+		return nil, nil
+	}
+
+	var dups []*Duplicate
+	for _, dp := range far.DuplicatingParameters {
+		out := payload.data
+		if dp.OuterHeaderCreation != nil {
+			var err error
+			if out, err = d.createOuterHeader(dp.OuterHeaderCreation, payload); err != nil {
+				return nil, err
+			}
+		}
+		dups = append(dups, &Duplicate{
+			DestinationInterface: dp.DestinationInterface,
+			ForwardingPolicy:     dp.ForwardingPolicy,
+			Frame:                rebuildFrame(f, out),
+		})
+	}
+	return dups, nil
+}
+
+// duplicateFrame copies a non-IP frame as it is, since there is no IP packet
+// to put an outer header on.
+func duplicateFrame(far *up.FAR, data []byte) []*Duplicate {
+	if far.ApplyAction&protocol.ApplyActionDuplicate == 0 {
+		return nil
+	}
+
+	var dups []*Duplicate
+	for _, dp := range far.DuplicatingParameters {
+		dups = append(dups, &Duplicate{
+			DestinationInterface: dp.DestinationInterface,
+			ForwardingPolicy:     dp.ForwardingPolicy,
+			Frame:                append([]byte(nil), data...),
+		})
+	}
+	return dups
+}
+
+// classify finds the matching PDR with the lowest precedence value across
+// all sessions. Ties go to the lowest SEID, then the lowest PDR ID, so that
+// the outcome does not depend on map order.
+func (d *UserspaceDataplane) classify(sourceInterface uint8, f *frame) *classification {
+	var best *classification
+	for seid, session := range d.sessions {
+		for _, state := range session.pdrs {
+			ip, ok := state.match(sourceInterface, f)
+			if !ok {
+				continue
+			}
+			if best == nil || better(seid, state.pdr, best.seid, best.pdr.pdr) {
+				best = &classification{seid: seid, session: session, pdr: state, ip: ip}
+			}
+		}
+	}
+	return best
+}
+
+func better(seid uint64, pdr *up.PDR, bestSEID uint64, best *up.PDR) bool {
+	if pdr.Precedence != best.Precedence {
+		return pdr.Precedence < best.Precedence
+	}
+	if seid != bestSEID {
+		return seid < bestSEID
+	}
+	return pdr.ID < best.ID
+}
+
+// isPunt applies the VPP dataplane's rule: a forwarding FAR sends traffic to
+// the CP function if that is its destination, and for PDRs with an SDF filter
+// or Application ID that are not carried in GTP-U or a PPPoE or L2TP session.
+func isPunt(state *pdrState, far *up.FAR) bool {
+	fp := far.ForwardingParameters
+	if fp != nil && fp.DestinationInterface == protocol.DestinationInterfaceCPFunction {
+		return true
+	}
+	if state.pdr.BBFOuterHeaderRemoval != nil || fp != nil && (fp.PPPoE != nil || fp.L2TP != nil) {
+		return false
+	}
+
+	pdi := state.pdr.PDI
+	if state.sdf == nil && state.l2 == nil && state.eth == nil {
+		return false
+	}
+	if pdi.LocalFTEID != nil {
+		return false
+	}
+	return fp == nil || fp.OuterHeaderCreation == nil || !isGTPUEncap(fp.OuterHeaderCreation)
+}
+
+// enforceQERs applies the PDR's QERs in order, returning why the packet is
+// dropped or "" if every gate is open and every MBR conforms. Access PDRs
+// are uplink, all others downlink.
+func enforceQERs(session *sessionState, pdr *up.PDR, size int, ts time.Time) string {
+	uplink := pdr.PDI != nil && pdr.PDI.SourceInterface == protocol.SourceInterfaceAccess
+
+	for _, id := range pdr.QER_IDs {
+		state, ok := session.qers[id]
+		if !ok {
+			continue
+		}
+
+		qer, direction := state.qer, 1
+		gate, mbr := protocol.DownlinkGate(qer.GateStatus), qer.MBR_DL
+		if uplink {
+			gate, mbr, direction = protocol.UplinkGate(qer.GateStatus), qer.MBR_UL, 0
+		}
+
+		if gate == protocol.GateStatusClosed {
+			return fmt.Sprintf("QER %d gate closed", id)
+		}
+		if mbr != 0 && !state.buckets[direction].conform(mbr, size, ts) {
+			return fmt.Sprintf("QER %d MBR exceeded", id)
+		}
+	}
+
+	return ""
+}
+
+// minBurst matches the VPP policers, keeping low rates from dropping single
+// full-size frames.
+const minBurst = 15000
+
+// tokenBucket is a single rate, two colour policer with a burst of 100ms at
+// the MBR, as the VPP dataplane configures.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) conform(mbr uint64, size int, now time.Time) bool {
+	rate := float64(mbr) * 1000 / 8
+	burst := max(rate/10, minBurst)
+
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if now.After(b.last) {
+		b.tokens = min(burst, b.tokens+rate*now.Sub(b.last).Seconds())
+	}
+	if now.After(b.last) {
+		b.last = now
+	}
+
+	if b.tokens < float64(size) {
+		return false
+	}
+	b.tokens -= float64(size)
+	return true
+}
@@ -0,0 +1,310 @@
+// Package userspace is a pure-Go reference dataplane. It classifies frames
+// injected from memory or replayed from a pcap capture against the installed
+// rules, so that session semantics can be exercised without VPP.
+package userspace
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+)
+
+type UserspaceDataplane struct {
+	sessions map[uint64]*sessionState
+	gtpuAddr netip.Addr
+	l2tpAddr netip.Addr
+	mac      net.HardwareAddr
+	output   func(*Packet)
+	quiet    bool
+	injected *up.InjectionRecorder
+	mu       sync.Mutex
+}
+
+type Config struct {
+	// GTPUAddress is the source address of the outer headers FARs create.
+	GTPUAddress string
+	// L2TPAddress is the source address of the L2TP packets FARs send to
+	// an LNS. Defaults to GTPUAddress.
+	L2TPAddress string
+	// MACAddress is the source address of the PPPoE frames FARs create. By
+	// default they come from the address the packet was sent to.
+	MACAddress string
+	// Output, if set, is called with every processed packet, in order.
+	Output func(*Packet)
+	// Quiet turns off the logging of installed and removed rules, for a
+	// dataplane that only classifies punts alongside another.
+	Quiet bool
+}
+
+type sessionState struct {
+	pdrs map[uint16]*pdrState
+	fars map[uint32]*up.FAR
+	qers map[uint32]*qerState
+	urrs map[uint32]*up.URR
+}
+
+func newSessionState() *sessionState {
+	return &sessionState{
+		pdrs: make(map[uint16]*pdrState),
+		fars: make(map[uint32]*up.FAR),
+		qers: make(map[uint32]*qerState),
+		urrs: make(map[uint32]*up.URR),
+	}
+}
+
+// pdrState is an installed PDR with its PDI decoded, and the traffic it has
+// matched. ue holds the UE's address or prefix followed by its framed
+// routes.
+type pdrState struct {
+	pdr     *up.PDR
+	ue      []netip.Prefix
+	sdf     *protocol.SDFFilter
+	l2      *protocol.L2Filter
+	eth     []*protocol.EthernetHeaderMatch
+	packets uint64
+	bytes   uint64
+}
+
+// qerState is an installed QER with a token bucket per direction.
+type qerState struct {
+	qer     *up.QER
+	buckets [2]tokenBucket
+}
+
+func NewUserspaceDataplane(cfg *Config) (*UserspaceDataplane, error) {
+	d := &UserspaceDataplane{
+		sessions: make(map[uint64]*sessionState),
+		output:   cfg.Output,
+		quiet:    cfg.Quiet,
+		injected: up.NewInjectionRecorder(0),
+	}
+
+	if cfg.GTPUAddress != "" {
+		addr, err := netip.ParseAddr(cfg.GTPUAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GTP-U address %q", cfg.GTPUAddress)
+		}
+		d.gtpuAddr = addr.Unmap()
+	}
+
+	d.l2tpAddr = d.gtpuAddr
+	if cfg.L2TPAddress != "" {
+		addr, err := netip.ParseAddr(cfg.L2TPAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid L2TP address %q", cfg.L2TPAddress)
+		}
+		d.l2tpAddr = addr.Unmap()
+	}
+
+	if cfg.MACAddress != "" {
+		mac, err := net.ParseMAC(cfg.MACAddress)
+		if err != nil || len(mac) != 6 {
+			return nil, fmt.Errorf("invalid MAC address %q", cfg.MACAddress)
+		}
+		d.mac = mac
+	}
+
+	return d, nil
+}
+
+func (d *UserspaceDataplane) logf(format string, args ...any) {
+	if !d.quiet {
+		log.Printf(format, args...)
+	}
+}
+
+func (d *UserspaceDataplane) session(seid uint64) *sessionState {
+	session, exists := d.sessions[seid]
+	if !exists {
+		session = newSessionState()
+		d.sessions[seid] = session
+	}
+	return session
+}
+
+func (d *UserspaceDataplane) InstallPDR(seid uint64, pdr *up.PDR) error {
+	state := &pdrState{pdr: pdr}
+
+	if pdi := pdr.PDI; pdi != nil {
+		if pdi.UE_IPAddress != "" {
+			ip := net.ParseIP(pdi.UE_IPAddress)
+			if ip == nil {
+				return fmt.Errorf("PDR %d: invalid UE IP address %q", pdr.ID, pdi.UE_IPAddress)
+			}
+			addr, _ := netip.AddrFromSlice(ip)
+			addr = addr.Unmap()
+			bits := addr.BitLen()
+			if pdi.UE_IPPrefixLength != 0 && addr.Is6() {
+				bits = int(pdi.UE_IPPrefixLength)
+			}
+			state.ue = append(state.ue, netip.PrefixFrom(addr, bits).Masked())
+		}
+		for _, prefix := range pdi.FramedPrefixes() {
+			state.ue = append(state.ue, prefix.Masked())
+		}
+
+		if len(pdi.SDFFilter) > 0 {
+			sdf, err := protocol.ParseSDFFilter(pdi.SDFFilter)
+			if err != nil {
+				return fmt.Errorf("PDR %d: parse SDF filter: %w", pdr.ID, err)
+			}
+			state.sdf = sdf
+		}
+
+		if pdi.ApplicationID != "" {
+			filter, ok := protocol.GetL2Filter(pdi.ApplicationID)
+			if !ok {
+				return fmt.Errorf("PDR %d: unknown Application ID %q", pdr.ID, pdi.ApplicationID)
+			}
+			state.l2 = filter
+		}
+
+		if pdi.MatchesEthernet() {
+			if pdi.UE_IPAddress != "" || len(pdi.FramedRoutes) > 0 || len(pdi.SDFFilter) > 0 || pdi.ApplicationID != "" || pdi.LocalFTEID != nil {
+				return fmt.Errorf("PDR %d: Ethernet packet filters and PPPoE sessions cannot be combined with a UE IP address, framed route, SDF filter, Application ID or local F-TEID", pdr.ID)
+			}
+			matches, err := pdi.EthernetHeaderMatches()
+			if err != nil {
+				return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+			}
+			state.eth = matches
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session := d.session(seid)
+
+	// An update keeps counting where the previous rule left off.
+	if old, ok := session.pdrs[pdr.ID]; ok {
+		state.packets, state.bytes = old.packets, old.bytes
+	}
+	session.pdrs[pdr.ID] = state
+
+	d.logf("[Userspace] Installed PDR %d for session %d (precedence=%d, FAR_ID=%d)",
+		pdr.ID, seid, pdr.Precedence, pdr.FAR_ID)
+
+	return nil
+}
+
+func (d *UserspaceDataplane) RemovePDR(seid uint64, pdrID uint16) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, ok := d.sessions[seid]; ok {
+		delete(session.pdrs, pdrID)
+		d.logf("[Userspace] Removed PDR %d from session %d", pdrID, seid)
+	}
+
+	return nil
+}
+
+func (d *UserspaceDataplane) InstallFAR(seid uint64, far *up.FAR) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.session(seid).fars[far.ID] = far
+	d.logf("[Userspace] Installed FAR %d for session %d (action=0x%02x)", far.ID, seid, far.ApplyAction)
+
+	return nil
+}
+
+func (d *UserspaceDataplane) RemoveFAR(seid uint64, farID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, ok := d.sessions[seid]; ok {
+		delete(session.fars, farID)
+		d.logf("[Userspace] Removed FAR %d from session %d", farID, seid)
+	}
+
+	return nil
+}
+
+// InstallQER replaces the QER's rates and gates but keeps its buckets, as
+// the VPP dataplane updates its policers in place.
+func (d *UserspaceDataplane) InstallQER(seid uint64, qer *up.QER) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session := d.session(seid)
+	if state, ok := session.qers[qer.ID]; ok {
+		state.qer = qer
+	} else {
+		session.qers[qer.ID] = &qerState{qer: qer}
+	}
+
+	d.logf("[Userspace] Installed QER %d for session %d (gate=0x%02x, MBR UL/DL=%d/%d kbps)",
+		qer.ID, seid, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
+
+	return nil
+}
+
+func (d *UserspaceDataplane) RemoveQER(seid uint64, qerID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, ok := d.sessions[seid]; ok {
+		delete(session.qers, qerID)
+		d.logf("[Userspace] Removed QER %d from session %d", qerID, seid)
+	}
+
+	return nil
+}
+
+// InstallURR only records the URR: traffic is counted per PDR and the UP
+// evaluates the thresholds through PDRUsage.
+func (d *UserspaceDataplane) InstallURR(seid uint64, urr *up.URR) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.session(seid).urrs[urr.ID] = urr
+	d.logf("[Userspace] Installed URR %d for session %d (triggers=0x%04x)", urr.ID, seid, urr.ReportingTriggers)
+
+	return nil
+}
+
+func (d *UserspaceDataplane) RemoveURR(seid uint64, urrID uint32) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if session, ok := d.sessions[seid]; ok {
+		delete(session.urrs, urrID)
+		d.logf("[Userspace] Removed URR %d from session %d", urrID, seid)
+	}
+
+	return nil
+}
+
+func (d *UserspaceDataplane) DeleteSession(seid uint64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.sessions, seid)
+	d.logf("[Userspace] Deleted session %d", seid)
+
+	return nil
+}
+
+func (d *UserspaceDataplane) PDRUsage(seid uint64, pdrID uint16) (uint64, uint64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, ok := d.sessions[seid]
+	if !ok {
+		return 0, 0, fmt.Errorf("session %d not found", seid)
+	}
+
+	state, ok := session.pdrs[pdrID]
+	if !ok {
+		return 0, 0, fmt.Errorf("PDR %d not found in session %d", pdrID, seid)
+	}
+
+	return state.packets, state.bytes, nil
+}
@@ -0,0 +1,556 @@
+package userspace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/dataplane/internal/dptest"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+)
+
+const (
+	localGTPU = "198.51.100.1"
+	peerGTPU  = "198.51.100.2"
+)
+
+func newDataplane(t *testing.T, sessions map[uint64]dptest.Rules) *UserspaceDataplane {
+	t.Helper()
+
+	d, err := NewUserspaceDataplane(&Config{GTPUAddress: localGTPU, Quiet: true})
+	if err != nil {
+		t.Fatalf("NewUserspaceDataplane: %v", err)
+	}
+
+	for seid, r := range sessions {
+		r.Install(t, d, seid, nil)
+	}
+
+	return d
+}
+
+// udpPacket builds an IPv4 UDP packet carrying size bytes of payload.
+func udpPacket(src, dst string, srcPort, dstPort uint16, size int) []byte {
+	s, d := netip.MustParseAddr(src), netip.MustParseAddr(dst)
+	return ipHeader(s, d, protoUDP, udpHeader(s, d, srcPort, dstPort, make([]byte, size)))
+}
+
+// gtpuPacket encapsulates an IP packet in GTP-U from the peer to the UP.
+func gtpuPacket(teid uint32, inner []byte) []byte {
+	s, d := netip.MustParseAddr(peerGTPU), netip.MustParseAddr(localGTPU)
+	return ipHeader(s, d, protoUDP, udpHeader(s, d, gtpuPort, gtpuPort, gtpuHeader(teid, inner)))
+}
+
+// ethernet puts an IP packet in an Ethernet frame.
+func ethernet(ip []byte) []byte {
+	frame := make([]byte, ethernetHeaderLen, ethernetHeaderLen+len(ip))
+	copy(frame[0:6], []byte{0x02, 0, 0, 0, 0, 0x01})
+	copy(frame[6:12], []byte{0x02, 0, 0, 0, 0, 0x02})
+	binary.BigEndian.PutUint16(frame[12:14], etherTypeIPv4)
+	return append(frame, ip...)
+}
+
+func sdf(flowDescription string) []byte {
+	return protocol.NewSDFFilterIE(flowDescription).Value
+}
+
+func uplinkPDR(id uint16, precedence uint32, flowDescription string) *up.PDR {
+	return dptest.PDR(id, protocol.SourceInterfaceAccess, precedence, flowDescription)
+}
+
+func forward(id uint32) *up.FAR {
+	return dptest.FAR(id, protocol.ApplyActionForward)
+}
+
+func TestPDRPrecedence(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions map[uint64]dptest.Rules
+		packet   []byte
+		wantSEID uint64
+		wantPDR  uint16
+	}{
+		{
+			name: "lowest precedence value wins",
+			sessions: map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{uplinkPDR(1, 200, ""), uplinkPDR(2, 100, "")},
+				FARs: []*up.FAR{forward(1), forward(2)},
+			}},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 1,
+			wantPDR:  2,
+		},
+		{
+			name: "equal precedence goes to the lowest PDR ID",
+			sessions: map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{uplinkPDR(2, 100, ""), uplinkPDR(1, 100, "")},
+				FARs: []*up.FAR{forward(1), forward(2)},
+			}},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 1,
+			wantPDR:  1,
+		},
+		{
+			name: "equal precedence across sessions goes to the lowest SEID",
+			sessions: map[uint64]dptest.Rules{
+				7: {PDRs: []*up.PDR{uplinkPDR(1, 100, "")}, FARs: []*up.FAR{forward(1)}},
+				3: {PDRs: []*up.PDR{uplinkPDR(1, 100, "")}, FARs: []*up.FAR{forward(1)}},
+			},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 3,
+			wantPDR:  1,
+		},
+		{
+			name: "an SDF filter that does not match falls through",
+			sessions: map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{uplinkPDR(1, 100, "permit in 17 from assigned to any 443"), uplinkPDR(2, 200, "")},
+				FARs: []*up.FAR{forward(1), forward(2)},
+			}},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 1,
+			wantPDR:  2,
+		},
+		{
+			name: "an out flow description is swapped for uplink",
+			sessions: map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{uplinkPDR(1, 100, "permit out 17 from any 53 to assigned"), uplinkPDR(2, 200, "")},
+				FARs: []*up.FAR{forward(1), forward(2)},
+			}},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 1,
+			wantPDR:  1,
+		},
+		{
+			name: "an in flow description is matched as written for uplink",
+			sessions: map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{uplinkPDR(1, 100, "permit in 17 from any 53 to assigned"), uplinkPDR(2, 200, "")},
+				FARs: []*up.FAR{forward(1), forward(2)},
+			}},
+			packet:   udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantSEID: 1,
+			wantPDR:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDataplane(t, tt.sessions)
+			pkt := d.Inject(protocol.SourceInterfaceAccess, ethernet(tt.packet))
+			if pkt.SEID != tt.wantSEID || pkt.PDRID != tt.wantPDR {
+				t.Errorf("matched session %d PDR %d, want session %d PDR %d (%s: %s)",
+					pkt.SEID, pkt.PDRID, tt.wantSEID, tt.wantPDR, pkt.Verdict, pkt.Reason)
+			}
+		})
+	}
+}
+
+func TestFARActions(t *testing.T) {
+	tests := []struct {
+		name        string
+		pdr         *up.PDR
+		far         *up.FAR
+		packet      []byte
+		wantVerdict Verdict
+		wantReason  string
+		// wantOuter, if set, is the destination of the outer header of
+		// the forwarded packet.
+		wantOuter string
+	}{
+		{
+			name:        "forward",
+			pdr:         uplinkPDR(1, 100, ""),
+			far:         forward(1),
+			packet:      udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantVerdict: VerdictForwarded,
+		},
+		{
+			name:        "drop",
+			pdr:         uplinkPDR(1, 100, ""),
+			far:         &up.FAR{ID: 1, ApplyAction: protocol.ApplyActionDrop},
+			packet:      udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantVerdict: VerdictDropped,
+			wantReason:  "FAR 1 drops",
+		},
+		{
+			name:        "buffer is left to the UP",
+			pdr:         uplinkPDR(1, 100, ""),
+			far:         &up.FAR{ID: 1, ApplyAction: protocol.ApplyActionBuffer},
+			packet:      udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantVerdict: VerdictDropped,
+			wantReason:  "FAR 1 buffers",
+		},
+		{
+			name:        "deny flow description",
+			pdr:         uplinkPDR(1, 100, "deny in 17 from assigned to any"),
+			far:         forward(1),
+			packet:      udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantVerdict: VerdictDropped,
+			wantReason:  "flow description denies",
+		},
+		{
+			name: "punt to the CP function",
+			pdr:  uplinkPDR(1, 100, ""),
+			far: &up.FAR{
+				ID:                   1,
+				ApplyAction:          protocol.ApplyActionForward,
+				ForwardingParameters: &up.ForwardingParameters{DestinationInterface: protocol.DestinationInterfaceCPFunction},
+			},
+			packet:      udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10),
+			wantVerdict: VerdictPunted,
+		},
+		{
+			name:        "punt an SDF filter PDR outside GTP-U",
+			pdr:         uplinkPDR(1, 100, "permit in 17 from any 68 to any 67"),
+			far:         forward(1),
+			packet:      udpPacket(dptest.UEAddr, "255.255.255.255", 68, 67, 10),
+			wantVerdict: VerdictPunted,
+		},
+		{
+			name: "forward into GTP-U",
+			pdr: &up.PDR{ID: 1, Precedence: 100, FAR_ID: 1, PDI: &up.PDI{
+				SourceInterface: protocol.SourceInterfaceCore,
+				UE_IPAddress:    dptest.UEAddr,
+			}},
+			far: &up.FAR{ID: 1, ApplyAction: protocol.ApplyActionForward, ForwardingParameters: &up.ForwardingParameters{
+				DestinationInterface: protocol.DestinationInterfaceAccess,
+				OuterHeaderCreation: &protocol.OuterHeaderCreation{
+					Description: protocol.OuterHeaderCreationGTPUUDPIPv4,
+					TEID:        0x55,
+					IPv4:        net.ParseIP(peerGTPU),
+				},
+			}},
+			packet:      udpPacket(dptest.ServerAddr, dptest.UEAddr, 53, 40000, 10),
+			wantVerdict: VerdictForwarded,
+			wantOuter:   peerGTPU,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDataplane(t, map[uint64]dptest.Rules{1: {PDRs: []*up.PDR{tt.pdr}, FARs: []*up.FAR{tt.far}}})
+			pkt := d.Inject(tt.pdr.PDI.SourceInterface, ethernet(tt.packet))
+
+			if pkt.Verdict != tt.wantVerdict {
+				t.Fatalf("verdict %s (%s), want %s", pkt.Verdict, pkt.Reason, tt.wantVerdict)
+			}
+			if !strings.HasPrefix(pkt.Reason, tt.wantReason) {
+				t.Errorf("reason %q, want %q", pkt.Reason, tt.wantReason)
+			}
+			if tt.wantOuter == "" {
+				return
+			}
+
+			f, err := parseFrame(pkt.Frame)
+			if err != nil {
+				t.Fatalf("parse forwarded frame: %v", err)
+			}
+			if f.ip.dst != netip.MustParseAddr(tt.wantOuter) || f.inner == nil || f.teid != 0x55 {
+				t.Errorf("forwarded to %s TEID 0x%x (inner %v), want %s TEID 0x55", f.ip.dst, f.teid, f.inner != nil, tt.wantOuter)
+			}
+		})
+	}
+}
+
+func TestGTPUDecapsulation(t *testing.T) {
+	removal := protocol.OuterHeaderRemovalGTPUUDPIPv4
+	pdr := uplinkPDR(1, 100, "")
+	pdr.OuterHeaderRemoval = &removal
+	pdr.PDI.LocalFTEID = &protocol.FTEID{TEID: 0x11, IPv4: net.ParseIP(localGTPU)}
+
+	d := newDataplane(t, map[uint64]dptest.Rules{1: {PDRs: []*up.PDR{pdr}, FARs: []*up.FAR{forward(1)}}})
+
+	inner := udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10)
+	for _, tt := range []struct {
+		name        string
+		teid        uint32
+		wantVerdict Verdict
+	}{
+		{"matching TEID", 0x11, VerdictForwarded},
+		{"other TEID", 0x12, VerdictDropped},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt := d.Inject(protocol.SourceInterfaceAccess, ethernet(gtpuPacket(tt.teid, inner)))
+			if pkt.Verdict != tt.wantVerdict {
+				t.Fatalf("verdict %s (%s), want %s", pkt.Verdict, pkt.Reason, tt.wantVerdict)
+			}
+			if tt.wantVerdict == VerdictForwarded && !bytes.Equal(pkt.Frame[ethernetHeaderLen:], inner) {
+				t.Errorf("forwarded frame is not the inner packet")
+			}
+		})
+	}
+}
+
+func TestQERTokenBuckets(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	// 8000 kbps is 1,000,000 bytes per second with a 100,000 byte burst,
+	// i.e. 100 packets of 1000 bytes.
+	packet := udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 1000-28)
+
+	tests := []struct {
+		name string
+		qer  *up.QER
+		// counts holds the number of packets sent at each of the sends,
+		// offsets from start.
+		sends         []time.Duration
+		counts        []int
+		wantForwarded int
+		wantReason    string
+	}{
+		{
+			name:          "burst conforms",
+			qer:           &up.QER{ID: 1, MBR_UL: 8000},
+			sends:         []time.Duration{0},
+			counts:        []int{100},
+			wantForwarded: 100,
+		},
+		{
+			name:          "beyond the burst is dropped",
+			qer:           &up.QER{ID: 1, MBR_UL: 8000},
+			sends:         []time.Duration{0},
+			counts:        []int{150},
+			wantForwarded: 100,
+			wantReason:    "QER 1 MBR exceeded",
+		},
+		{
+			name:          "the bucket refills at the MBR",
+			qer:           &up.QER{ID: 1, MBR_UL: 8000},
+			sends:         []time.Duration{0, 20 * time.Millisecond},
+			counts:        []int{120, 30},
+			wantForwarded: 120,
+			wantReason:    "QER 1 MBR exceeded",
+		},
+		{
+			name:          "downlink MBR does not police uplink",
+			qer:           &up.QER{ID: 1, MBR_DL: 8},
+			sends:         []time.Duration{0},
+			counts:        []int{150},
+			wantForwarded: 150,
+		},
+		{
+			name:          "closed uplink gate",
+			qer:           &up.QER{ID: 1, GateStatus: protocol.GateStatusClosed << 2},
+			sends:         []time.Duration{0},
+			counts:        []int{5},
+			wantForwarded: 0,
+			wantReason:    "QER 1 gate closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdr := uplinkPDR(1, 100, "")
+			pdr.QER_IDs = []uint32{tt.qer.ID}
+			d := newDataplane(t, map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{pdr},
+				FARs: []*up.FAR{forward(1)},
+				QERs: []*up.QER{tt.qer},
+			}})
+
+			forwarded, reason := 0, ""
+			for i, offset := range tt.sends {
+				for range tt.counts[i] {
+					pkt := d.InjectAt(start.Add(offset), protocol.SourceInterfaceAccess, ethernet(packet))
+					if pkt.Verdict == VerdictForwarded {
+						forwarded++
+					} else {
+						reason = pkt.Reason
+					}
+				}
+			}
+
+			if forwarded != tt.wantForwarded {
+				t.Errorf("forwarded %d packets, want %d", forwarded, tt.wantForwarded)
+			}
+			if reason != tt.wantReason {
+				t.Errorf("drop reason %q, want %q", reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestURRCountsFromPcap(t *testing.T) {
+	tests := []struct {
+		name        string
+		far         *up.FAR
+		sizes       []int
+		wantPackets uint64
+	}{
+		{
+			name:        "forwarded packets",
+			far:         forward(1),
+			sizes:       []int{10, 100, 1000},
+			wantPackets: 3,
+		},
+		{
+			name:        "dropped packets count too",
+			far:         &up.FAR{ID: 1, ApplyAction: protocol.ApplyActionDrop},
+			sizes:       []int{10, 20},
+			wantPackets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metered := uplinkPDR(1, 100, "permit out 17 from any 53 to assigned")
+			metered.URR_IDs = []uint32{1}
+			other := uplinkPDR(2, 200, "")
+			d := newDataplane(t, map[uint64]dptest.Rules{1: {
+				PDRs: []*up.PDR{metered, other},
+				FARs: []*up.FAR{tt.far, forward(2)},
+			}})
+
+			var capture bytes.Buffer
+			w, err := NewPcapWriter(&capture)
+			if err != nil {
+				t.Fatalf("NewPcapWriter: %v", err)
+			}
+			ts := time.Unix(1700000000, 0)
+			var wantBytes uint64
+			for i, size := range tt.sizes {
+				ip := udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, size)
+				wantBytes += uint64(len(ip))
+				if err := w.WriteFrame(ts.Add(time.Duration(i)*time.Millisecond), ethernet(ip)); err != nil {
+					t.Fatalf("WriteFrame: %v", err)
+				}
+			}
+			// Traffic of the other PDR is not counted against the URR.
+			if err := w.WriteFrame(ts.Add(time.Second), ethernet(udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 443, 50))); err != nil {
+				t.Fatalf("WriteFrame: %v", err)
+			}
+
+			packets, err := d.ReplayPcap(&capture, protocol.SourceInterfaceAccess)
+			if err != nil {
+				t.Fatalf("ReplayPcap: %v", err)
+			}
+			if len(packets) != len(tt.sizes)+1 {
+				t.Fatalf("replayed %d packets, want %d", len(packets), len(tt.sizes)+1)
+			}
+
+			gotPackets, gotBytes, err := d.PDRUsage(1, 1)
+			if err != nil {
+				t.Fatalf("PDRUsage: %v", err)
+			}
+			if gotPackets != tt.wantPackets || gotBytes != wantBytes {
+				t.Errorf("PDR 1 counted %d packets and %d bytes, want %d and %d", gotPackets, gotBytes, tt.wantPackets, wantBytes)
+			}
+		})
+	}
+}
+
+// TestLACForwarding forwards PPP frames between a PPPoE session and an L2TP
+// tunnel both ways, as the UP does for the packets the VPP dataplane diverts
+// to it.
+func TestLACForwarding(t *testing.T) {
+	subscriberMAC := net.HardwareAddr{0x02, 0, 0, 0, 0, 0x02}
+	pppoeEthernet := uint8(protocol.BBFOuterHeaderRemovalPPPoEEthernet)
+	l2tp := uint8(protocol.BBFOuterHeaderRemovalL2TP)
+
+	uplink := &up.PDR{
+		ID:                    1,
+		Precedence:            100,
+		FAR_ID:                1,
+		BBFOuterHeaderRemoval: &pppoeEthernet,
+		PDI: &up.PDI{
+			SourceInterface: protocol.SourceInterfaceAccess,
+			PPPoE:           &protocol.PPPoEMatch{SessionID: 7, HasSessionID: true},
+		},
+	}
+	downlink := &up.PDR{
+		ID:                    2,
+		Precedence:            100,
+		FAR_ID:                2,
+		BBFOuterHeaderRemoval: &l2tp,
+		PDI: &up.PDI{
+			SourceInterface: protocol.SourceInterfaceCore,
+			SDFFilter:       sdf("permit out 17 from " + peerGTPU + " 1701 to " + localGTPU + " 1701"),
+		},
+	}
+	toLNS := forward(1)
+	toLNS.ForwardingParameters.L2TP = &protocol.L2TPSession{TunnelID: 10, SessionID: 20, LNS: net.ParseIP(peerGTPU)}
+	toSubscriber := forward(2)
+	toSubscriber.ForwardingParameters.DestinationInterface = protocol.DestinationInterfaceAccess
+	toSubscriber.ForwardingParameters.PPPoE = &protocol.PPPoESession{SessionID: 7, PeerMAC: subscriberMAC}
+
+	d := newDataplane(t, map[uint64]dptest.Rules{1: {PDRs: []*up.PDR{uplink, downlink}, FARs: []*up.FAR{toLNS, toSubscriber}}})
+
+	lcp := []byte{0xc0, 0x21, 0x09, 0x01, 0x00, 0x08, 0, 0, 0, 1} // LCP Echo-Request
+	ipv4 := append([]byte{0x00, 0x21}, udpPacket(dptest.UEAddr, dptest.ServerAddr, 40000, 53, 10)...)
+	lns, local := netip.MustParseAddr(peerGTPU), netip.MustParseAddr(localGTPU)
+
+	for _, tt := range []struct {
+		name  string
+		ppp   []byte
+		iface uint8
+		in    []byte
+	}{
+		{
+			name:  "uplink LCP",
+			ppp:   lcp,
+			iface: protocol.SourceInterfaceAccess,
+			in:    pppoeFrame(&protocol.PPPoESession{SessionID: 7, PeerMAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}}, subscriberMAC, lcp),
+		},
+		{
+			name:  "uplink IPv4",
+			ppp:   ipv4,
+			iface: protocol.SourceInterfaceAccess,
+			in:    pppoeFrame(&protocol.PPPoESession{SessionID: 7, PeerMAC: net.HardwareAddr{0x02, 0, 0, 0, 0, 0x01}}, subscriberMAC, ipv4),
+		},
+		{
+			name:  "downlink LCP",
+			ppp:   lcp,
+			iface: protocol.SourceInterfaceCore,
+			in:    ethernet(ipHeader(lns, local, protoUDP, udpHeader(lns, local, l2tpPort, l2tpPort, append([]byte{0x00, 0x02, 0, 10, 0, 20, 0xff, 0x03}, lcp...)))),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, punted := d.ClassifyPunt(tt.iface, tt.in); punted {
+				t.Fatalf("PPP frame classified as punted")
+			}
+
+			out, err := d.ForwardPacket(tt.iface, tt.in)
+			if err != nil {
+				t.Fatalf("ForwardPacket: %v", err)
+			}
+
+			if tt.iface == protocol.SourceInterfaceAccess {
+				f, err := parseFrame(out)
+				if err != nil {
+					t.Fatalf("parseFrame: %v", err)
+				}
+				if f.ip == nil || f.ip.protocol != protoUDP || f.ip.dstPort != l2tpPort {
+					t.Fatalf("forwarded frame is not an L2TP packet")
+				}
+				l2tp := f.ip.payload[8:]
+				if tunnel, session := binary.BigEndian.Uint16(l2tp[4:]), binary.BigEndian.Uint16(l2tp[6:]); tunnel != 10 || session != 20 {
+					t.Errorf("L2TP tunnel %d session %d, want tunnel 10 session 20", tunnel, session)
+				}
+				ppp, err := parseL2TP(l2tp)
+				if err != nil {
+					t.Fatalf("parseL2TP: %v", err)
+				}
+				if !bytes.Equal(ppp, tt.ppp) {
+					t.Errorf("L2TP carries %x, want %x", ppp, tt.ppp)
+				}
+				return
+			}
+
+			if !bytes.Equal(out[:6], subscriberMAC) {
+				t.Errorf("destination MAC %s, want %s", net.HardwareAddr(out[:6]), subscriberMAC)
+			}
+			if etherType := binary.BigEndian.Uint16(out[12:]); etherType != etherTypePPPoESession {
+				t.Fatalf("EtherType %#04x, want PPPoE session", etherType)
+			}
+			if session := binary.BigEndian.Uint16(out[16:]); session != 7 {
+				t.Errorf("PPPoE session %d, want 7", session)
+			}
+			if !bytes.Equal(out[20:], tt.ppp) {
+				t.Errorf("PPPoE carries %x, want %x", out[20:], tt.ppp)
+			}
+		})
+	}
+}
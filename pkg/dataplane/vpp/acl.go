@@ -0,0 +1,933 @@
+package vpp
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/acl"
+	"go.fd.io/govpp/binapi/acl_types"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/ip_types"
+	"go.fd.io/govpp/binapi/punt"
+)
+
+// PDRs are matched with VPP ACLs. Every PDR with an SDF filter or a URR gets
+// rules compiled from its filter and UE address, permitting the traffic if
+// its FAR forwards and denying it if the FAR drops. A session has one ACL per
+// direction holding the rules of its PDRs in precedence order, so the first
+// PDR to match a packet decides. A session with its own GTP-U tunnel or PPPoE
+// session interface gets the uplink ACL as the interface's input ACL and the
+// downlink ACL as its output ACL. The ACLs of other sessions go on the input
+// ACL lists of the access (uplink) or core (downlink) interfaces, after any
+// ACLs the operator configured there. Either way they are followed by a punt
+// guard ACL, which denies punted traffic no PDR permitted, and a permit-any
+// ACL for everything else. The ACLs the UP created are kept in aclIndexes;
+// any other ACL is the operator's. VPP also counts the packets and bytes
+// each ACL rule matches, which is how URR usage is measured.
+const (
+	aclTagPrefix    = "pfcp-"
+	permitACLTag    = aclTagPrefix + "permit-any"
+	puntGuardACLTag = aclTagPrefix + "punt-guard"
+	// VPP takes at most this many ACLs per interface.
+	maxInterfaceACLs = 255
+)
+
+// sessionACL is the ACL matching a session's PDRs in one direction.
+type sessionACL struct {
+	ACLIndex uint32
+	Rules    []acl_types.ACLRule
+	// PDRRules holds the range of rules compiled from each PDR, which is
+	// where the PDR's usage is counted.
+	PDRRules map[uint16]ruleRange
+	// Precedence is that of the session's first PDR, which orders the
+	// session ACLs sharing an interface.
+	Precedence uint32
+}
+
+type ruleRange struct {
+	First, Count int
+}
+
+func sessionACLTag(seid uint64, d qerDirection) string {
+	return fmt.Sprintf("%s%d-%s", aclTagPrefix, seid, d)
+}
+
+// anyPrefix returns the default route of the address family of ip.
+func anyPrefix(ip net.IP) ip_types.Prefix {
+	if ip.To4() != nil {
+		return ip_types.NewPrefix(net.IPNet{IP: net.IPv4zero, Mask: net.CIDRMask(0, 32)})
+	}
+	return ip_types.NewPrefix(net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)})
+}
+
+// ueNet parses a UE address, or IPv6 prefix when prefixLength is set, into
+// the network it matches. It returns nil for an invalid address.
+func ueNet(addr string, prefixLength uint8) *net.IPNet {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	bits := 128
+	if prefixLength != 0 {
+		bits = int(prefixLength)
+	}
+	mask := net.CIDRMask(bits, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// pdrUENets returns the networks a PDR's UE side matches: the UE address or
+// prefix, if any, followed by its framed routes.
+func pdrUENets(pdi *up.PDI) []*net.IPNet {
+	var nets []*net.IPNet
+	if ue := ueNet(pdi.UE_IPAddress, pdi.UE_IPPrefixLength); ue != nil {
+		nets = append(nets, ue)
+	}
+	for _, prefix := range pdi.FramedPrefixes() {
+		prefix = prefix.Masked()
+		nets = append(nets, &net.IPNet{
+			IP:   net.IP(prefix.Addr().AsSlice()),
+			Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+		})
+	}
+	return nets
+}
+
+func familyZero(isV6 bool) net.IP {
+	if isV6 {
+		return net.IPv6zero
+	}
+	return net.IPv4zero
+}
+
+// sdfIsV6 reports whether an SDF filter matches IPv6. Filters whose
+// addresses are all "any" or "assigned" take the family of the UE address,
+// or IPv4 without one.
+func sdfIsV6(rule *ipfilter.Rule, ue *net.IPNet) bool {
+	switch rule.Family() {
+	case ipfilter.FamilyIPv6:
+		return true
+	case ipfilter.FamilyIPv4:
+		return false
+	}
+	return ue != nil && ue.IP.To4() == nil
+}
+
+// sdfPrefix converts an SDF filter endpoint to a prefix of the given family.
+// "assigned" is the PDR's UE address or prefix.
+func sdfPrefix(e ipfilter.Endpoint, isV6 bool, ue *net.IPNet) (ip_types.Prefix, error) {
+	if e.Negate {
+		return ip_types.Prefix{}, fmt.Errorf("negated address %q cannot be matched with VPP ACLs", e.String())
+	}
+
+	switch e.Kind {
+	case ipfilter.AddressAny:
+		return anyPrefix(familyZero(isV6)), nil
+	case ipfilter.AddressAssigned:
+		if ue == nil || (ue.IP.To4() == nil) != isV6 {
+			return ip_types.Prefix{}, fmt.Errorf("\"assigned\" needs a UE address of the filter's address family")
+		}
+		return ip_types.NewPrefix(*ue), nil
+	}
+
+	masked := e.Masked()
+	return ip_types.NewPrefix(net.IPNet{
+		IP:   net.IP(masked.Addr().AsSlice()),
+		Mask: net.CIDRMask(masked.Bits(), masked.Addr().BitLen()),
+	}), nil
+}
+
+// TCP flag bits, as matched by ACL rules.
+var tcpFlagBits = map[string]uint8{
+	"fin": 0x01,
+	"syn": 0x02,
+	"rst": 0x04,
+	"psh": 0x08,
+	"ack": 0x10,
+	"urg": 0x20,
+}
+
+// sdfTCPFlags returns the TCP flags mask and value the filter's options
+// require. "setup" is SYN without ACK.
+func sdfTCPFlags(o ipfilter.Options) (mask, value uint8, err error) {
+	if o.Frag || o.Established || len(o.IPOptions) > 0 || len(o.TCPOptions) > 0 {
+		return 0, 0, fmt.Errorf("options %q cannot be matched with VPP ACLs", o.String())
+	}
+
+	flags := o.TCPFlags
+	if o.Setup {
+		flags = append(flags, ipfilter.Flag{Name: "syn"}, ipfilter.Flag{Name: "ack", Negate: true})
+	}
+	for _, f := range flags {
+		bit := tcpFlagBits[f.Name]
+		if mask&bit != 0 && (value&bit != 0) == f.Negate {
+			return 0, 0, fmt.Errorf("conflicting TCP flag %q", f.Name)
+		}
+		mask |= bit
+		if !f.Negate {
+			value |= bit
+		}
+	}
+	return mask, value, nil
+}
+
+// portRanges returns the ranges an endpoint matches; no ports is any port.
+func portRanges(ports []ipfilter.PortRange) []ipfilter.PortRange {
+	if len(ports) == 0 {
+		return []ipfilter.PortRange{{First: 0, Last: 65535}}
+	}
+	return ports
+}
+
+// sdfACLRules compiles an SDF filter into ACL rules, one for each
+// combination of source and destination port ranges or ICMP type. A flow
+// description written for the other direction than the PDR's is matched
+// with its source and destination swapped, the same way the L4 punt picks
+// its ports. When the UE side of the filter is "any", the PDR's UE address
+// is used instead. A deny filter denies the traffic whatever the FAR does.
+//
+// VPP ACLs match neither the ToS or traffic class, the IPsec SPI nor the
+// IPv6 flow label, so filters using them are rejected; the linux and
+// userspace dataplanes match them.
+func sdfACLRules(sdf *protocol.SDFFilter, d qerDirection, ue *net.IPNet, action acl_types.ACLAction) ([]acl_types.ACLRule, error) {
+	if sdf.ToS != 0 || sdf.SPI != 0 || sdf.FlowLabel != 0 {
+		return nil, fmt.Errorf("ToS, SPI and flow label are not supported by the VPP dataplane")
+	}
+
+	fd := sdf.FlowDescription
+	if fd == nil {
+		return nil, fmt.Errorf("SDF filter has no flow description")
+	}
+	fd = fd.Oriented(d.filterDirection())
+	if fd.Action == ipfilter.ActionDeny {
+		action = acl_types.ACL_ACTION_API_DENY
+	}
+
+	isV6 := sdfIsV6(fd, ue)
+	src, err := sdfPrefix(fd.Src, isV6, ue)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := sdfPrefix(fd.Dst, isV6, ue)
+	if err != nil {
+		return nil, err
+	}
+
+	if ue != nil && (ue.IP.To4() == nil) == isV6 {
+		if d == qerUplink && src.Len == 0 {
+			src = ip_types.NewPrefix(*ue)
+		}
+		if d == qerDownlink && dst.Len == 0 {
+			dst = ip_types.NewPrefix(*ue)
+		}
+	}
+
+	flagsMask, flagsValue, err := sdfTCPFlags(fd.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	base := acl_types.ACLRule{
+		IsPermit:      action,
+		SrcPrefix:     src,
+		DstPrefix:     dst,
+		Proto:         ip_types.IPProto(fd.Protocol),
+		TCPFlagsMask:  flagsMask,
+		TCPFlagsValue: flagsValue,
+	}
+
+	var rules []acl_types.ACLRule
+
+	// For ICMP the source port fields hold the type and the destination
+	// port fields the code.
+	if len(fd.Options.ICMPTypes) > 0 {
+		for _, t := range fd.Options.ICMPTypes {
+			rule := base
+			rule.SrcportOrIcmptypeFirst = uint16(t)
+			rule.SrcportOrIcmptypeLast = uint16(t)
+			rule.DstportOrIcmpcodeLast = 255
+			rules = append(rules, rule)
+		}
+		return rules, nil
+	}
+
+	for _, sp := range portRanges(fd.Src.Ports) {
+		for _, dp := range portRanges(fd.Dst.Ports) {
+			rule := base
+			rule.SrcportOrIcmptypeFirst = sp.First
+			rule.SrcportOrIcmptypeLast = sp.Last
+			rule.DstportOrIcmpcodeFirst = dp.First
+			rule.DstportOrIcmpcodeLast = dp.Last
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// sdfUENets returns the UE networks an SDF filter is compiled for: those of
+// the flow description's address family, or the first one if none is. A
+// PDR without a UE address compiles the filter once, without one.
+func sdfUENets(fd *ipfilter.Rule, nets []*net.IPNet) []*net.IPNet {
+	if len(nets) == 0 {
+		return []*net.IPNet{nil}
+	}
+	var family []*net.IPNet
+	for _, ue := range nets {
+		if fd == nil || fd.Family() == ipfilter.FamilyAny || (ue.IP.To4() == nil) == (fd.Family() == ipfilter.FamilyIPv6) {
+			family = append(family, ue)
+		}
+	}
+	if len(family) == 0 {
+		return nets[:1]
+	}
+	return family
+}
+
+func ueACLRule(d qerDirection, ue *net.IPNet, action acl_types.ACLAction) acl_types.ACLRule {
+	rule := acl_types.ACLRule{
+		IsPermit:  action,
+		SrcPrefix: anyPrefix(ue.IP),
+		DstPrefix: anyPrefix(ue.IP),
+	}
+	if d == qerUplink {
+		rule.SrcPrefix = ip_types.NewPrefix(*ue)
+	} else {
+		rule.DstPrefix = ip_types.NewPrefix(*ue)
+	}
+	return rule
+}
+
+func permitAnyRules(action acl_types.ACLAction) []acl_types.ACLRule {
+	return []acl_types.ACLRule{
+		{IsPermit: action, SrcPrefix: anyPrefix(net.IPv4zero), DstPrefix: anyPrefix(net.IPv4zero)},
+		{IsPermit: action, SrcPrefix: anyPrefix(net.IPv6zero), DstPrefix: anyPrefix(net.IPv6zero)},
+	}
+}
+
+// farACLAction denies the traffic of PDRs whose FAR drops it. Other PDRs,
+// including those whose FAR is not installed yet, are permitted.
+func farACLAction(session *sessionState, pdr *up.PDR) acl_types.ACLAction {
+	far, ok := session.fars[pdr.FAR_ID]
+	if ok && far.ApplyAction&0x01 != 0 && far.ApplyAction&0x02 == 0 {
+		return acl_types.ACL_ACTION_API_DENY
+	}
+	return acl_types.ACL_ACTION_API_PERMIT
+}
+
+// pdrACLRules returns the rules matching the PDR, or nil if it needs no ACL.
+// A PDR with framed routes gets the rules of its UE address for each of
+// them too.
+func pdrACLRules(session *sessionState, pdr *up.PDR, d qerDirection) ([]acl_types.ACLRule, error) {
+	nets := pdrUENets(pdr.PDI)
+	action := farACLAction(session, pdr)
+
+	switch {
+	case len(pdr.PDI.SDFFilter) > 0:
+		sdf, err := protocol.ParseSDFFilter(pdr.PDI.SDFFilter)
+		if err != nil {
+			return nil, fmt.Errorf("parse SDF filter: %w", err)
+		}
+		var rules []acl_types.ACLRule
+		for _, ue := range sdfUENets(sdf.FlowDescription, nets) {
+			ueRules, err := sdfACLRules(sdf, d, ue, action)
+			if err != nil {
+				return nil, fmt.Errorf("compile SDF filter %q: %w", sdf.FlowDescription, err)
+			}
+			rules = append(rules, ueRules...)
+		}
+		return rules, nil
+	case len(pdr.URR_IDs) == 0:
+		return nil, nil
+	case len(nets) > 0:
+		rules := make([]acl_types.ACLRule, 0, len(nets))
+		for _, ue := range nets {
+			rules = append(rules, ueACLRule(d, ue, action))
+		}
+		return rules, nil
+	default:
+		return permitAnyRules(action), nil
+	}
+}
+
+// pdrHasACL reports whether the PDR is matched by the session ACLs. Its
+// direction must be known, and Application ID and Ethernet PDRs are
+// classified on L2 instead.
+func pdrHasACL(pdr *up.PDR) (qerDirection, bool) {
+	d, ok := pdrDirection(pdr)
+	if !ok || pdr.PDI.ApplicationID != "" || pdr.PDI.MatchesEthernet() {
+		return 0, false
+	}
+	return d, true
+}
+
+// syncSessionACLs brings the session's ACLs in line with its PDRs and FARs
+// after either changed, and binds them where its traffic passes. An ACL
+// whose rules are unchanged is left alone; otherwise the counts of its rules
+// are carried over before it is replaced, because replacing it resets them.
+func (v *VPPDataplane) syncSessionACLs(session *sessionState) error {
+	var dropped []uint32
+	changed := false
+
+	for _, d := range qerDirections {
+		var pdrs []*up.PDR
+		for _, pdr := range session.pdrs {
+			if pd, ok := pdrHasACL(pdr); ok && pd == d {
+				pdrs = append(pdrs, pdr)
+			}
+		}
+
+		// A lower precedence value takes priority.
+		sort.Slice(pdrs, func(i, j int) bool {
+			if pdrs[i].Precedence != pdrs[j].Precedence {
+				return pdrs[i].Precedence < pdrs[j].Precedence
+			}
+			return pdrs[i].ID < pdrs[j].ID
+		})
+
+		var rules []acl_types.ACLRule
+		ranges := make(map[uint16]ruleRange)
+		var precedence uint32
+		for _, pdr := range pdrs {
+			pdrRules, err := pdrACLRules(session, pdr, d)
+			if err != nil {
+				return fmt.Errorf("PDR %d: %w", pdr.ID, err)
+			}
+			if pdrRules == nil {
+				continue
+			}
+			if len(ranges) == 0 {
+				precedence = pdr.Precedence
+			}
+			ranges[pdr.ID] = ruleRange{First: len(rules), Count: len(pdrRules)}
+			rules = append(rules, pdrRules...)
+		}
+
+		old := session.acls[d]
+		switch {
+		case len(rules) == 0:
+			if old == nil {
+				continue
+			}
+			v.carryACLUsage(session, old)
+			session.acls[d] = nil
+			dropped = append(dropped, old.ACLIndex)
+			changed = true
+			continue
+		case old != nil && reflect.DeepEqual(old.Rules, rules) && maps.Equal(old.PDRRules, ranges):
+			changed = changed || old.Precedence != precedence
+			old.Precedence = precedence
+			continue
+		}
+
+		if err := v.ensurePermitACL(); err != nil {
+			return err
+		}
+
+		index := ^uint32(0)
+		if old != nil {
+			v.carryACLUsage(session, old)
+			index = old.ACLIndex
+		}
+
+		index, err := v.addReplaceACL(index, sessionACLTag(session.SEID, d), rules)
+		if err != nil {
+			return fmt.Errorf("add %s ACL: %w", d, err)
+		}
+
+		session.acls[d] = &sessionACL{
+			ACLIndex:   index,
+			Rules:      rules,
+			PDRRules:   ranges,
+			Precedence: precedence,
+		}
+		// An ACL replaced in place keeps its bindings, and only needs them
+		// reordered if its precedence changed.
+		changed = changed || old == nil || old.Precedence != precedence
+
+		fmt.Printf("VPP: Session %d %s PDRs matched by ACL %d (%d rules)\n", session.SEID, d, index, len(rules))
+	}
+
+	for pdrID := range session.aclUsage {
+		if _, ok := session.pdrs[pdrID]; !ok {
+			delete(session.aclUsage, pdrID)
+		}
+	}
+
+	// An ACL cannot be deleted while an interface still uses it.
+	if err := v.bindSessionACLs(session, changed); err != nil {
+		return err
+	}
+
+	for _, index := range dropped {
+		if err := v.deleteACL(index); err != nil {
+			fmt.Printf("VPP: ERROR deleting ACL %d: %v\n", index, err)
+		}
+	}
+	if len(dropped) > 0 {
+		v.teardownPermitACL()
+	}
+
+	return nil
+}
+
+// releaseSessionACLs unbinds and deletes the session's ACLs.
+func (v *VPPDataplane) releaseSessionACLs(session *sessionState) {
+	var dropped []uint32
+	for _, d := range qerDirections {
+		if a := session.acls[d]; a != nil {
+			dropped = append(dropped, a.ACLIndex)
+			session.acls[d] = nil
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	if err := v.bindSessionACLs(session, true); err != nil {
+		fmt.Printf("VPP: ERROR detaching ACLs of session %d: %v\n", session.SEID, err)
+	}
+
+	for _, index := range dropped {
+		if err := v.deleteACL(index); err != nil {
+			fmt.Printf("VPP: ERROR deleting ACL %d: %v\n", index, err)
+		}
+	}
+
+	v.teardownPermitACL()
+}
+
+// sessionInterfaces returns the interfaces carrying only the session's
+// traffic: its GTP-U tunnel and PPPoE session, once they exist.
+func (v *VPPDataplane) sessionInterfaces(session *sessionState) []uint32 {
+	var ifaces []uint32
+	if session.gtpu != nil {
+		ifaces = append(ifaces, session.gtpu.SwIfIndex)
+	}
+	if session.pppoe != nil {
+		ifaces = append(ifaces, session.pppoe.SwIfIndex)
+	}
+	return ifaces
+}
+
+// bindSessionACLs applies the session's ACLs to its own interfaces, or to
+// the shared access and core interfaces if it has none. Nothing is done
+// unless the ACLs changed or the session's interfaces did. Interfaces the
+// session no longer has were deleted, taking their ACL lists with them.
+func (v *VPPDataplane) bindSessionACLs(session *sessionState, changed bool) error {
+	ifaces := v.sessionInterfaces(session)
+	if !changed && slices.Equal(ifaces, session.aclInterfaces) {
+		return nil
+	}
+
+	for _, swIfIndex := range ifaces {
+		if err := v.applySessionInterfaceACLs(session, swIfIndex); err != nil {
+			return err
+		}
+	}
+
+	shared := len(ifaces) == 0
+	if shared || len(session.aclInterfaces) == 0 {
+		for _, d := range qerDirections {
+			if err := v.applyACLs(d); err != nil {
+				return err
+			}
+		}
+	}
+
+	session.aclInterfaces = ifaces
+	return nil
+}
+
+// applySessionInterfaceACLs sets the ACL lists of one of the session's own
+// interfaces: the uplink ACL and punt guard on input, the downlink ACL on
+// output, each followed by permit-any.
+func (v *VPPDataplane) applySessionInterfaceACLs(session *sessionState, swIfIndex uint32) error {
+	var input, output []uint32
+	if a := session.acls[qerUplink]; a != nil {
+		input = append(input, a.ACLIndex)
+	}
+	if v.puntGuardACL != ^uint32(0) {
+		input = append(input, v.puntGuardACL)
+	}
+	if len(input) > 0 {
+		input = append(input, v.permitACL)
+	}
+	if a := session.acls[qerDownlink]; a != nil {
+		output = append(output, a.ACLIndex, v.permitACL)
+	}
+	return v.setInterfaceACLList(interface_types.InterfaceIndex(swIfIndex), input, output)
+}
+
+// carryACLUsage adds what the rules of the session's PDRs in the ACL counted
+// so far to their carried usage, before the ACL is replaced or deleted.
+func (v *VPPDataplane) carryACLUsage(session *sessionState, a *sessionACL) {
+	metered := false
+	for pdrID := range a.PDRRules {
+		if pdr, ok := session.pdrs[pdrID]; ok && len(pdr.URR_IDs) > 0 {
+			metered = true
+		}
+	}
+	if !metered {
+		return
+	}
+
+	counts, err := v.aclCounters(a.ACLIndex)
+	if err != nil {
+		fmt.Printf("VPP: ERROR reading ACL %d counters: %v\n", a.ACLIndex, err)
+		return
+	}
+	for pdrID, r := range a.PDRRules {
+		usage := session.aclUsage[pdrID]
+		usage.add(counts, r)
+		session.aclUsage[pdrID] = usage
+	}
+}
+
+// ensurePermitACL enables ACL counters and creates the permit-any ACL on
+// first use.
+func (v *VPPDataplane) ensurePermitACL() error {
+	if v.permitACL != ^uint32(0) {
+		return nil
+	}
+
+	reply := &acl.ACLStatsIntfCountersEnableReply{}
+	if err := v.ch.SendRequest(&acl.ACLStatsIntfCountersEnable{Enable: true}).ReceiveReply(reply); err != nil {
+		return fmt.Errorf("enable ACL counters: %w", err)
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("enable ACL counters: VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	index, err := v.addReplaceACL(^uint32(0), permitACLTag, permitAnyRules(acl_types.ACL_ACTION_API_PERMIT))
+	if err != nil {
+		return fmt.Errorf("add permit ACL: %w", err)
+	}
+
+	v.permitACL = index
+	return nil
+}
+
+// teardownPermitACL deletes the permit-any ACL once no PDR and no punt
+// guard needs it.
+func (v *VPPDataplane) teardownPermitACL() {
+	if v.permitACL == ^uint32(0) || v.puntGuardACL != ^uint32(0) {
+		return
+	}
+
+	for _, session := range v.sessions {
+		if session.acls[qerUplink] != nil || session.acls[qerDownlink] != nil {
+			return
+		}
+	}
+
+	if err := v.deleteACL(v.permitACL); err != nil {
+		fmt.Printf("VPP: ERROR deleting ACL %d: %v\n", v.permitACL, err)
+	}
+	v.permitACL = ^uint32(0)
+}
+
+// puntGuardRules denies every registered punt, merging L4 punts on adjacent
+// ports into one rule.
+func (v *VPPDataplane) puntGuardRules() []acl_types.ACLRule {
+	type l4Key struct {
+		isV6  bool
+		proto uint8
+	}
+
+	ports := make(map[l4Key][]uint16)
+	var protos []l4Key
+
+	regs := make([]*puntRegistration, 0, len(v.punts)+len(v.inheritedPunts))
+	for _, reg := range v.punts {
+		regs = append(regs, reg)
+	}
+	for _, reg := range v.inheritedPunts {
+		regs = append(regs, reg)
+	}
+
+	for _, reg := range regs {
+		key := l4Key{isV6: reg.AF == ip_types.ADDRESS_IP6, proto: reg.Protocol}
+		switch reg.Type {
+		case punt.PUNT_API_TYPE_L4:
+			ports[key] = append(ports[key], reg.Port)
+		case punt.PUNT_API_TYPE_IP_PROTO:
+			protos = append(protos, key)
+		}
+	}
+
+	var rules []acl_types.ACLRule
+	newRule := func(key l4Key) acl_types.ACLRule {
+		anyNet := anyPrefix(familyZero(key.isV6))
+		return acl_types.ACLRule{
+			IsPermit:              acl_types.ACL_ACTION_API_DENY,
+			SrcPrefix:             anyNet,
+			DstPrefix:             anyNet,
+			Proto:                 ip_types.IPProto(key.proto),
+			SrcportOrIcmptypeLast: 65535,
+			DstportOrIcmpcodeLast: 65535,
+		}
+	}
+
+	for _, key := range protos {
+		rules = append(rules, newRule(key))
+	}
+
+	for key, list := range ports {
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+		for i := 0; i < len(list); {
+			j := i
+			for j+1 < len(list) && list[j+1] == list[j]+1 {
+				j++
+			}
+			rule := newRule(key)
+			rule.DstportOrIcmpcodeFirst = list[i]
+			rule.DstportOrIcmpcodeLast = list[j]
+			rules = append(rules, rule)
+			i = j + 1
+		}
+	}
+
+	return rules
+}
+
+// syncPuntGuard brings the punt guard ACL in line with the punt
+// registrations after they changed.
+func (v *VPPDataplane) syncPuntGuard() error {
+	if !v.puntGuardDirty {
+		return nil
+	}
+
+	rules := v.puntGuardRules()
+
+	switch {
+	case len(rules) == 0 && v.puntGuardACL == ^uint32(0):
+	case len(rules) == 0:
+		index := v.puntGuardACL
+		v.puntGuardACL = ^uint32(0)
+		if err := v.applyAllACLs(); err != nil {
+			return err
+		}
+		if err := v.deleteACL(index); err != nil {
+			return fmt.Errorf("delete punt guard ACL %d: %w", index, err)
+		}
+		v.teardownPermitACL()
+	case v.puntGuardACL != ^uint32(0):
+		if _, err := v.addReplaceACL(v.puntGuardACL, puntGuardACLTag, rules); err != nil {
+			return fmt.Errorf("update punt guard ACL: %w", err)
+		}
+	default:
+		if err := v.ensurePermitACL(); err != nil {
+			return err
+		}
+		index, err := v.addReplaceACL(^uint32(0), puntGuardACLTag, rules)
+		if err != nil {
+			return fmt.Errorf("add punt guard ACL: %w", err)
+		}
+		v.puntGuardACL = index
+		if err := v.applyAllACLs(); err != nil {
+			return err
+		}
+	}
+
+	v.puntGuardDirty = false
+	return nil
+}
+
+// applyAllACLs sets the ACL lists of every session interface and of the
+// access and core interfaces.
+func (v *VPPDataplane) applyAllACLs() error {
+	for _, session := range v.sessions {
+		for _, swIfIndex := range v.sessionInterfaces(session) {
+			if err := v.applySessionInterfaceACLs(session, swIfIndex); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, d := range qerDirections {
+		if err := v.applyACLs(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyACLs sets the input ACL list of the direction's interfaces to the
+// ACLs the operator configured there, followed by the ACLs of the sessions
+// without interfaces of their own, ordered by precedence, and the punt guard
+// and permit-any ACLs.
+func (v *VPPDataplane) applyACLs(d qerDirection) error {
+	var all []*sessionState
+	for _, session := range v.sessions {
+		if session.acls[d] != nil && len(v.sessionInterfaces(session)) == 0 {
+			all = append(all, session)
+		}
+	}
+
+	// A lower precedence value takes priority.
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].acls[d].Precedence != all[j].acls[d].Precedence {
+			return all[i].acls[d].Precedence < all[j].acls[d].Precedence
+		}
+		return all[i].SEID < all[j].SEID
+	})
+
+	acls := make([]uint32, 0, len(all)+2)
+	for _, session := range all {
+		acls = append(acls, session.acls[d].ACLIndex)
+	}
+	if v.puntGuardACL != ^uint32(0) {
+		acls = append(acls, v.puntGuardACL)
+	}
+	if len(acls) > 0 {
+		acls = append(acls, v.permitACL)
+	}
+
+	if len(all) > 0 && len(v.directionInterfaces(d)) == 0 {
+		fmt.Printf("VPP: No %s interfaces configured, %s session ACLs not applied\n", d.interfaceRole(), d)
+	}
+
+	for _, swIfIndex := range v.directionInterfaces(d) {
+		input, output, err := v.interfaceACLList(swIfIndex)
+		if err != nil {
+			return err
+		}
+
+		operator := slices.DeleteFunc(slices.Clone(input), func(index uint32) bool { return v.aclIndexes[index] })
+		want := append(operator, acls...)
+		if slices.Equal(input, want) {
+			continue
+		}
+		if len(want)+len(output) > maxInterfaceACLs {
+			return fmt.Errorf("%d %s session ACLs and %d operator ACLs exceed the %d ACLs VPP allows per interface",
+				len(all), d, len(operator)+len(output), maxInterfaceACLs)
+		}
+
+		if err := v.setInterfaceACLList(swIfIndex, want, output); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// interfaceACLList returns the input and output ACLs of an interface.
+func (v *VPPDataplane) interfaceACLList(swIfIndex interface_types.InterfaceIndex) (input, output []uint32, err error) {
+	reqCtx := v.ch.SendMultiRequest(&acl.ACLInterfaceListDump{SwIfIndex: swIfIndex})
+	for {
+		details := &acl.ACLInterfaceListDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dump ACLs of interface %d: %w", swIfIndex, err)
+		}
+		if stop {
+			return input, output, nil
+		}
+		if details.SwIfIndex != swIfIndex {
+			continue
+		}
+		n := min(int(details.NInput), len(details.Acls))
+		input = append(input, details.Acls[:n]...)
+		output = append(output, details.Acls[n:]...)
+	}
+}
+
+func (v *VPPDataplane) setInterfaceACLList(swIfIndex interface_types.InterfaceIndex, input, output []uint32) error {
+	acls := append(slices.Clone(input), output...)
+	req := &acl.ACLInterfaceSetACLList{
+		SwIfIndex: swIfIndex,
+		Count:     uint8(len(acls)),
+		NInput:    uint8(len(input)),
+		Acls:      acls,
+	}
+
+	reply := &acl.ACLInterfaceSetACLListReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return fmt.Errorf("set ACLs on interface %d: %w", swIfIndex, err)
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("set ACLs on interface %d: VPPApiError: %s (%d)", swIfIndex, vppErrorString(reply.Retval), reply.Retval)
+	}
+	return nil
+}
+
+func (v *VPPDataplane) addReplaceACL(index uint32, tag string, rules []acl_types.ACLRule) (uint32, error) {
+	req := &acl.ACLAddReplace{
+		ACLIndex: index,
+		Tag:      tag,
+		Count:    uint32(len(rules)),
+		R:        rules,
+	}
+
+	reply := &acl.ACLAddReplaceReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return 0, err
+	}
+
+	if reply.Retval != 0 {
+		return 0, fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	v.aclIndexes[reply.ACLIndex] = true
+	return reply.ACLIndex, nil
+}
+
+func (v *VPPDataplane) deleteACL(index uint32) error {
+	reply := &acl.ACLDelReply{}
+	if err := v.ch.SendRequest(&acl.ACLDel{ACLIndex: index}).ReceiveReply(reply); err != nil {
+		return err
+	}
+
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	delete(v.aclIndexes, index)
+	return nil
+}
+
+// removeStaleACLs deletes the ACLs a previous run left behind. They are
+// taken off the access and core interfaces first, as an ACL cannot be
+// deleted while in use, leaving the operator's ACLs in place.
+func (v *VPPDataplane) removeStaleACLs() error {
+	var stale []uint32
+	reqCtx := v.ch.SendMultiRequest(&acl.ACLDump{ACLIndex: ^uint32(0)})
+	for {
+		details := &acl.ACLDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return fmt.Errorf("dump ACLs: %w", err)
+		}
+		if stop {
+			break
+		}
+		if strings.HasPrefix(details.Tag, aclTagPrefix) && !v.aclIndexes[details.ACLIndex] {
+			stale = append(stale, details.ACLIndex)
+			v.aclIndexes[details.ACLIndex] = true
+		}
+	}
+
+	if err := v.applyAllACLs(); err != nil {
+		return err
+	}
+
+	for _, index := range stale {
+		fmt.Printf("VPP: Removing stale ACL %d\n", index)
+		if err := v.deleteACL(index); err != nil {
+			return fmt.Errorf("delete ACL %d: %w", index, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,437 @@
+package vpp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/classify"
+	interfaces "go.fd.io/govpp/binapi/interface"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/tapv2"
+	"go.fd.io/govpp/binapi/vlib"
+)
+
+// L2 punts share one classify table that matches the EtherType of untagged
+// frames. It is attached as the L2 input table of every access interface for
+// IPv4, IPv6 and other traffic alike, so any EtherType can be diverted;
+// frames that miss carry on through the normal L2 input features. Ethernet
+// packet filters and PPPoE sessions get a table per mask, chained in front
+// of it. Diverted frames go to the L2 punt node, or out of the L2 punt tap,
+// whose host side the UP captures: l2-input-classify cannot hand frames to
+// the punt socket.
+const (
+	l2PuntClassifyNode = "l2-input-classify"
+	defaultL2PuntNode  = "error-punt"
+	etherTypeOffset    = 12
+	classifyVectorSize = 16
+)
+
+func etherTypeMask() []byte {
+	mask := make([]byte, classifyVectorSize)
+	mask[etherTypeOffset] = 0xff
+	mask[etherTypeOffset+1] = 0xff
+	return mask
+}
+
+func etherTypeMatch(etherType uint16) []byte {
+	match := make([]byte, classifyVectorSize)
+	match[etherTypeOffset] = byte(etherType >> 8)
+	match[etherTypeOffset+1] = byte(etherType)
+	return match
+}
+
+// l2PuntTap is the tap L2 punts are sent out of.
+type l2PuntTap struct {
+	name string
+	// host is the tap's host interface, and iface the interface the frames
+	// it carries are reported to have arrived on.
+	host  string
+	iface string
+}
+
+// setL2PuntTap sends diverted frames to the output node of the tap. VPP
+// does not tell which access interface they came in on, so they are
+// reported on the access interface if there is only one, and on the tap
+// otherwise.
+func (v *VPPDataplane) setL2PuntTap(name string, accessInterfaces []string) error {
+	indexes, err := v.resolveInterfaces([]string{name})
+	if err != nil {
+		return err
+	}
+
+	reqCtx := v.ch.SendMultiRequest(&tapv2.SwInterfaceTapV2Dump{SwIfIndex: indexes[0]})
+	var host string
+	for {
+		details := &tapv2.SwInterfaceTapV2Details{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return fmt.Errorf("dump tap %s: %w", name, err)
+		}
+		if stop {
+			break
+		}
+		host = details.HostIfName
+	}
+	if host == "" {
+		return fmt.Errorf("%s is not a tap interface", name)
+	}
+
+	flags := &interfaces.SwInterfaceSetFlags{
+		SwIfIndex: indexes[0],
+		Flags:     interface_types.IF_STATUS_API_FLAG_ADMIN_UP,
+	}
+	flagsReply := &interfaces.SwInterfaceSetFlagsReply{}
+	if err := v.ch.SendRequest(flags).ReceiveReply(flagsReply); err != nil {
+		return fmt.Errorf("set %s up: %w", name, err)
+	}
+	if flagsReply.Retval != 0 {
+		return fmt.Errorf("set %s up: VPPApiError: %s (%d)", name, vppErrorString(flagsReply.Retval), flagsReply.Retval)
+	}
+
+	tap := &l2PuntTap{name: name, host: host, iface: name}
+	if len(accessInterfaces) == 1 {
+		tap.iface = accessInterfaces[0]
+	}
+	v.l2PuntTap = tap
+	v.l2PuntNode = name + "-output"
+
+	fmt.Printf("VPP: L2 punts go out of %s (host interface %s)\n", name, host)
+	return nil
+}
+
+// L2PuntCapture returns the host interface of the L2 punt tap, to be read
+// for the frames L2 punts divert, and the interface to report them on. ok
+// is false without an L2 punt tap.
+func (v *VPPDataplane) L2PuntCapture() (host, iface string, ok bool) {
+	if v.l2PuntTap == nil {
+		return "", "", false
+	}
+	return v.l2PuntTap.host, v.l2PuntTap.iface, true
+}
+
+func (v *VPPDataplane) resolveInterfaces(names []string) ([]interface_types.InterfaceIndex, error) {
+	byName, err := dumpInterfaces(v.ch)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]interface_types.InterfaceIndex, 0, len(names))
+	for _, name := range names {
+		idx, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown interface %q", name)
+		}
+		indexes = append(indexes, idx)
+	}
+
+	return indexes, nil
+}
+
+// ensureL2PuntTable creates the L2 punt table and attaches it to the access
+// interfaces on first use.
+func (v *VPPDataplane) ensureL2PuntTable() error {
+	if v.l2PuntTable != ^uint32(0) {
+		return nil
+	}
+
+	if len(v.accessInterfaces) == 0 {
+		return fmt.Errorf("no access interfaces configured for L2 punt")
+	}
+
+	nextIndex, err := v.addNodeNext(l2PuntClassifyNode, v.l2PuntNode)
+	if err != nil {
+		return fmt.Errorf("resolve next node %s: %w", v.l2PuntNode, err)
+	}
+
+	tableIdx, err := v.createClassifyTable(0, etherTypeMask())
+	if err != nil {
+		return fmt.Errorf("create classify table: %w", err)
+	}
+
+	v.l2PuntTable = tableIdx
+	v.l2PuntNextIndex = nextIndex
+
+	if err := v.attachL2PuntTable(); err != nil {
+		v.teardownL2PuntTable()
+		return err
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	return nil
+}
+
+// l2TableChain returns the L2 input tables in lookup order: the Ethernet
+// filter tables by mask, then the L2 punt table.
+func (v *VPPDataplane) l2TableChain() []uint32 {
+	var chain []uint32
+	for _, key := range slices.Sorted(maps.Keys(v.ethernetTables)) {
+		chain = append(chain, v.ethernetTables[key])
+	}
+	if v.l2PuntTable != ^uint32(0) {
+		chain = append(chain, v.l2PuntTable)
+	}
+	return chain
+}
+
+// attachL2PuntTable links the L2 input tables into one chain and attaches
+// its head to the access interfaces, or detaches them if there are no
+// tables left.
+func (v *VPPDataplane) attachL2PuntTable() error {
+	chain := v.l2TableChain()
+	for i, tableIdx := range chain {
+		next := ^uint32(0)
+		if i+1 < len(chain) {
+			next = chain[i+1]
+		}
+		if err := v.setClassifyTableNext(tableIdx, next); err != nil {
+			return fmt.Errorf("chain classify table %d to %d: %w", tableIdx, next, err)
+		}
+	}
+
+	head := ^uint32(0)
+	if len(chain) > 0 {
+		head = chain[0]
+	}
+	for _, swIfIndex := range v.accessInterfaces {
+		if err := v.setInterfaceL2Table(swIfIndex, head); err != nil {
+			return fmt.Errorf("attach classify table %d to interface %d: %w", head, swIfIndex, err)
+		}
+	}
+
+	fmt.Printf("VPP: %d L2 punt tables attached to %d access interfaces\n", len(chain), len(v.accessInterfaces))
+	return nil
+}
+
+// teardownL2PuntTable unlinks and deletes the L2 punt table once it holds
+// no sessions.
+func (v *VPPDataplane) teardownL2PuntTable() {
+	if v.l2PuntTable == ^uint32(0) {
+		return
+	}
+
+	tableIdx := v.l2PuntTable
+	v.l2PuntTable = ^uint32(0)
+	v.removeL2Table(tableIdx)
+}
+
+// removeL2Table takes a table that has left the chain out of VPP.
+func (v *VPPDataplane) removeL2Table(tableIdx uint32) {
+	if err := v.attachL2PuntTable(); err != nil {
+		fmt.Printf("VPP: ERROR relinking L2 punt tables: %v\n", err)
+	}
+
+	if err := v.deleteClassifyTable(tableIdx); err != nil {
+		fmt.Printf("VPP: ERROR deleting classify table %d: %v\n", tableIdx, err)
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
+
+// ensureEthernetTable returns the L2 input table for an Ethernet packet
+// filter mask, creating it and adding it to the chain on first use.
+func (v *VPPDataplane) ensureEthernetTable(mask []byte) (uint32, error) {
+	key := hex.EncodeToString(mask)
+	if tableIdx, ok := v.ethernetTables[key]; ok {
+		return tableIdx, nil
+	}
+
+	if len(v.accessInterfaces) == 0 {
+		return 0, fmt.Errorf("no access interfaces configured for L2 punt")
+	}
+
+	nextIndex, err := v.addNodeNext(l2PuntClassifyNode, v.l2PuntNode)
+	if err != nil {
+		return 0, fmt.Errorf("resolve next node %s: %w", v.l2PuntNode, err)
+	}
+	v.l2PuntNextIndex = nextIndex
+
+	tableIdx, err := v.createClassifyTable(0, mask)
+	if err != nil {
+		return 0, fmt.Errorf("create classify table: %w", err)
+	}
+	v.ethernetTables[key] = tableIdx
+
+	if err := v.attachL2PuntTable(); err != nil {
+		delete(v.ethernetTables, key)
+		v.removeL2Table(tableIdx)
+		return 0, err
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	return tableIdx, nil
+}
+
+// teardownEthernetTables deletes the Ethernet filter tables no classify
+// session uses.
+func (v *VPPDataplane) teardownEthernetTables() {
+	for key, tableIdx := range v.ethernetTables {
+		if v.tableInUse(tableIdx) {
+			continue
+		}
+		delete(v.ethernetTables, key)
+		v.removeL2Table(tableIdx)
+	}
+}
+
+// ethernetFilterEntries compiles the Ethernet header matches of a PDI into
+// classify sessions, each in the table for its mask. Masks are padded to
+// whole classify vectors.
+func (v *VPPDataplane) ethernetFilterEntries(pdi *up.PDI) ([]*classifyEntry, error) {
+	matches, err := pdi.EthernetHeaderMatches()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*classifyEntry
+	for _, m := range matches {
+		if !slices.ContainsFunc(m.Mask, func(b byte) bool { return b != 0 }) {
+			return nil, fmt.Errorf("Ethernet packet filter matches every frame")
+		}
+
+		size := (len(m.Mask) + classifyVectorSize - 1) / classifyVectorSize * classifyVectorSize
+		mask := append(bytes.Clone(m.Mask), make([]byte, size-len(m.Mask))...)
+		match := append(bytes.Clone(m.Value), make([]byte, size-len(m.Value))...)
+
+		tableIdx, err := v.ensureEthernetTable(mask)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &classifyEntry{
+			TableIndex:   tableIdx,
+			Match:        match,
+			HitNextIndex: v.l2PuntNextIndex,
+			OpaqueIndex:  ^uint32(0),
+		})
+	}
+	return entries, nil
+}
+
+// setClassifyTableNext points an existing table's misses at next.
+func (v *VPPDataplane) setClassifyTableNext(tableIdx, next uint32) error {
+	req := &classify.ClassifyAddDelTable{
+		IsAdd:          true,
+		TableIndex:     tableIdx,
+		Nbuckets:       2,
+		MemorySize:     2 << 20,
+		MatchNVectors:  1,
+		NextTableIndex: next,
+		MissNextIndex:  ^uint32(0),
+	}
+
+	reply := &classify.ClassifyAddDelTableReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) setInterfaceL2Table(swIfIndex interface_types.InterfaceIndex, tableIdx uint32) error {
+	req := &classify.ClassifySetInterfaceL2Tables{
+		SwIfIndex:       swIfIndex,
+		IP4TableIndex:   tableIdx,
+		IP6TableIndex:   tableIdx,
+		OtherTableIndex: tableIdx,
+		IsInput:         true,
+	}
+
+	reply := &classify.ClassifySetInterfaceL2TablesReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+// addNodeNext returns the index of next as a next node of node, adding the
+// arc if it does not exist yet.
+func (v *VPPDataplane) addNodeNext(node, next string) (uint32, error) {
+	reply := &vlib.AddNodeNextReply{}
+	if err := v.ch.SendRequest(&vlib.AddNodeNext{NodeName: node, NextName: next}).ReceiveReply(reply); err != nil {
+		return 0, err
+	}
+
+	if reply.Retval != 0 {
+		return 0, fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return reply.NextIndex, nil
+}
+
+// registerClassifySession takes a reference on a classify session, adding it
+// on first use or claiming the one a previous run left behind. A table holds
+// one session per match, so a session with the same match but a different
+// action is rejected rather than shared.
+func (v *VPPDataplane) registerClassifySession(entry *classifyEntry) error {
+	key := entry.key()
+	if existing, ok := v.classifySessions[key]; ok {
+		if !sameClassifyAction(existing, entry) {
+			return fmt.Errorf("classify session %s is in use with hit next %d", key, existing.HitNextIndex)
+		}
+		v.classifyRefs[key]++
+		return nil
+	}
+
+	if !v.adoptClassifySession(entry) {
+		if err := v.setClassifySession(entry, true); err != nil {
+			return err
+		}
+	}
+
+	v.classifySessions[key] = entry
+	v.classifyRefs[key] = 1
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) releaseClassifySession(entry *classifyEntry) {
+	key := entry.key()
+	if v.classifyRefs[key] == 0 {
+		return
+	}
+
+	v.classifyRefs[key]--
+	if v.classifyRefs[key] > 0 {
+		return
+	}
+
+	if err := v.setClassifySession(entry, false); err != nil {
+		fmt.Printf("VPP: ERROR removing classify session %s: %v\n", key, err)
+		v.inheritedClassify[key] = entry
+	} else {
+		fmt.Printf("VPP: Classify session %s removed\n", key)
+	}
+
+	delete(v.classifySessions, key)
+	delete(v.classifyRefs, key)
+
+	v.teardownUnusedTables()
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
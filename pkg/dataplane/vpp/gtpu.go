@@ -0,0 +1,473 @@
+package vpp
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/gtpu"
+	interfaces "go.fd.io/govpp/binapi/interface"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/ip_types"
+)
+
+// gtpuTunnel is the VPP GTP-U tunnel of a session. VPP decapsulates packets
+// from Dst with TEID and encapsulates towards Dst with TTEID, so one tunnel
+// carries both directions. Traffic to the UE is routed into it.
+//
+// EncapVRF is the FIB table the encapsulated packets are routed in, and VRF
+// the one the tunnel interface is in: decapsulated packets are routed in it,
+// and it holds the routes to the UE and its FramedRoutes.
+type gtpuTunnel struct {
+	SwIfIndex uint32 `json:"sw_if_index"`
+	Src       string `json:"src"`
+	Dst       string `json:"dst"`
+	TEID      uint32 `json:"teid"`
+	TTEID     uint32 `json:"tteid"`
+	UE        string `json:"ue,omitempty"`
+	EncapVRF  uint32 `json:"encap_vrf,omitempty"`
+	VRF       uint32 `json:"vrf,omitempty"`
+
+	// UEPrefixLength makes UE an IPv6 prefix of that length.
+	UEPrefixLength uint8    `json:"ue_prefix_length,omitempty"`
+	FramedRoutes   []string `json:"framed_routes,omitempty"`
+}
+
+func (t *gtpuTunnel) key() string {
+	return fmt.Sprintf("%s/%s/%d", t.Src, t.Dst, t.TEID)
+}
+
+func isGTPUEncap(ohc *protocol.OuterHeaderCreation) bool {
+	return ohc.Description&(protocol.OuterHeaderCreationGTPUUDPIPv4|protocol.OuterHeaderCreationGTPUUDPIPv6) != 0
+}
+
+// sameGTPUPeer reports whether two outer header creations encapsulate
+// towards the same peer and TEID.
+func sameGTPUPeer(a, b *protocol.OuterHeaderCreation) bool {
+	return a.Description == b.Description && a.TEID == b.TEID && a.IPv4.Equal(b.IPv4) && a.IPv6.Equal(b.IPv6)
+}
+
+// isGTPUPDR reports whether the PDR's traffic is carried by the session's
+// GTP-U tunnel rather than punted.
+func isGTPUPDR(session *sessionState, pdr *up.PDR) bool {
+	if pdr.PDI != nil && pdr.PDI.LocalFTEID != nil {
+		return true
+	}
+	far, ok := session.fars[pdr.FAR_ID]
+	return ok && far.ForwardingParameters != nil && far.ForwardingParameters.OuterHeaderCreation != nil &&
+		isGTPUEncap(far.ForwardingParameters.OuterHeaderCreation)
+}
+
+// desiredGTPUTunnel derives the session's tunnel from the lowest numbered
+// PDR with a local F-TEID and the lowest numbered forwarding FAR that creates
+// a GTP-U header. It returns nil until the session has both.
+//
+// Traffic to the UE is routed into the tunnel, so a session has one: PDRs
+// with different local F-TEIDs, or forwarding FARs encapsulating towards
+// different peers or TEIDs, are rejected.
+//
+// The tunnel is encapsulated in the Network Instance of that FAR, or else of
+// the PDR, and decapsulates into the one of the PDR's FAR, or else of the
+// PDR matching the UE's downlink traffic.
+func (v *VPPDataplane) desiredGTPUTunnel(session *sessionState) (*gtpuTunnel, error) {
+	var decap *up.PDR
+	for _, pdr := range session.pdrs {
+		if pdr.PDI == nil || pdr.PDI.LocalFTEID == nil {
+			continue
+		}
+		if decap != nil && pdr.PDI.LocalFTEID.String() != decap.PDI.LocalFTEID.String() {
+			return nil, fmt.Errorf("PDRs %d and %d have different local F-TEIDs, but the VPP dataplane carries a session in one GTP-U tunnel",
+				min(pdr.ID, decap.ID), max(pdr.ID, decap.ID))
+		}
+		if decap == nil || pdr.ID < decap.ID {
+			decap = pdr
+		}
+	}
+
+	var encap *up.FAR
+	for _, far := range session.fars {
+		fp := far.ForwardingParameters
+		if far.ApplyAction&0x02 == 0 || fp == nil || fp.OuterHeaderCreation == nil || !isGTPUEncap(fp.OuterHeaderCreation) {
+			continue
+		}
+		if encap != nil && !sameGTPUPeer(fp.OuterHeaderCreation, encap.ForwardingParameters.OuterHeaderCreation) {
+			return nil, fmt.Errorf("FARs %d and %d create different GTP-U headers, but the VPP dataplane carries a session in one GTP-U tunnel",
+				min(far.ID, encap.ID), max(far.ID, encap.ID))
+		}
+		if encap == nil || far.ID < encap.ID {
+			encap = far
+		}
+	}
+
+	if decap == nil || encap == nil {
+		return nil, nil
+	}
+
+	fteid := decap.PDI.LocalFTEID
+	ohc := encap.ForwardingParameters.OuterHeaderCreation
+
+	t := &gtpuTunnel{TEID: fteid.TEID, TTEID: ohc.TEID}
+
+	t.EncapVRF = v.fibTable(encap.ForwardingParameters.NetworkInstance)
+	if encap.ForwardingParameters.NetworkInstance == "" {
+		t.EncapVRF = v.fibTable(decap.PDI.NetworkInstance)
+	}
+
+	var downlink *up.PDR
+	for _, pdr := range session.pdrs {
+		if pdr.PDI != nil && pdr.PDI.LocalFTEID == nil && pdr.PDI.UE_IPAddress != "" && (downlink == nil || pdr.ID < downlink.ID) {
+			downlink = pdr
+		}
+	}
+	if far, ok := session.fars[decap.FAR_ID]; ok && far.ForwardingParameters != nil && far.ForwardingParameters.NetworkInstance != "" {
+		t.VRF = v.fibTable(far.ForwardingParameters.NetworkInstance)
+	} else if downlink != nil {
+		t.VRF = v.fibTable(downlink.PDI.NetworkInstance)
+	}
+
+	switch {
+	case ohc.Description&protocol.OuterHeaderCreationGTPUUDPIPv4 != 0 && fteid.IPv4 != nil:
+		t.Src, t.Dst = fteid.IPv4.String(), ohc.IPv4.String()
+	case ohc.Description&protocol.OuterHeaderCreationGTPUUDPIPv6 != 0 && fteid.IPv6 != nil:
+		t.Src, t.Dst = fteid.IPv6.String(), ohc.IPv6.String()
+	default:
+		return nil, fmt.Errorf("F-TEID of PDR %d and outer header creation of FAR %d have no address family in common",
+			decap.ID, encap.ID)
+	}
+
+	ue := decap.PDI
+	if ue.UE_IPAddress == "" {
+		var ueID uint16
+		for _, pdr := range session.pdrs {
+			if pdr.PDI != nil && pdr.PDI.UE_IPAddress != "" && (ue.UE_IPAddress == "" || pdr.ID < ueID) {
+				ue, ueID = pdr.PDI, pdr.ID
+			}
+		}
+	}
+	if ue.UE_IPAddress != "" {
+		ip := net.ParseIP(ue.UE_IPAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid UE IP address %q", ue.UE_IPAddress)
+		}
+		t.UE = ue.UE_IPAddress
+		if ip.To4() == nil {
+			t.UEPrefixLength = ue.UE_IPPrefixLength
+		}
+	}
+	t.FramedRoutes = framedRoutes(session)
+
+	return t, nil
+}
+
+// syncGTPUTunnel brings the session's GTP-U tunnel in line with its PDRs and
+// FARs. A new peer TEID and framed routes are updated in place; any other
+// change replaces the tunnel.
+func (v *VPPDataplane) syncGTPUTunnel(session *sessionState) error {
+	want, err := v.desiredGTPUTunnel(session)
+	if err != nil {
+		return err
+	}
+
+	have := session.gtpu
+	if have != nil && want != nil && have.key() == want.key() && have.UE == want.UE && have.UEPrefixLength == want.UEPrefixLength && sameVRFs(have, want) {
+		if have.TTEID == want.TTEID && slices.Equal(have.FramedRoutes, want.FramedRoutes) {
+			return nil
+		}
+		defer v.saveTunnelState()
+		if !slices.Equal(have.FramedRoutes, want.FramedRoutes) {
+			have.FramedRoutes, err = v.updateFramedRoutes(have.FramedRoutes, want.FramedRoutes, have.VRF, have.SwIfIndex)
+			if err != nil {
+				return err
+			}
+		}
+		if have.TTEID != want.TTEID {
+			if err := v.updateGTPUTunnelTTEID(have, want.TTEID); err != nil {
+				return fmt.Errorf("update GTP-U tunnel TEID: %w", err)
+			}
+			have.TTEID = want.TTEID
+		}
+		return nil
+	}
+
+	if have != nil {
+		v.deleteGTPUTunnel(have)
+		session.gtpu = nil
+	}
+
+	if want != nil {
+		if err := v.createGTPUTunnel(want); err != nil {
+			v.saveTunnelState()
+			return fmt.Errorf("create GTP-U tunnel: %w", err)
+		}
+		session.gtpu = want
+	}
+
+	v.saveTunnelState()
+	return nil
+}
+
+func (v *VPPDataplane) saveTunnelState() {
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
+
+// createGTPUTunnel programs a tunnel, or claims the identical one a previous
+// run left behind.
+func (v *VPPDataplane) createGTPUTunnel(t *gtpuTunnel) error {
+	if inherited, ok := v.inheritedTunnels[t.key()]; ok && !sameVRFs(inherited, t) {
+		delete(v.inheritedTunnels, t.key())
+		v.deleteGTPUTunnel(inherited)
+	}
+
+	if inherited, ok := v.inheritedTunnels[t.key()]; ok {
+		delete(v.inheritedTunnels, t.key())
+		t.SwIfIndex = inherited.SwIfIndex
+
+		if inherited.TTEID != t.TTEID {
+			if err := v.updateGTPUTunnelTTEID(inherited, t.TTEID); err != nil {
+				v.deleteGTPUTunnel(inherited)
+				return err
+			}
+		}
+		if inherited.UE != t.UE || inherited.UEPrefixLength != t.UEPrefixLength {
+			if inherited.UE != "" {
+				if err := v.setGTPURoute(inherited, false); err != nil {
+					fmt.Printf("VPP: ERROR removing route to %s: %v\n", inherited.UE, err)
+				}
+			}
+			if t.UE != "" {
+				if err := v.setGTPURoute(t, true); err != nil {
+					v.removeGTPUTunnel(t)
+					return err
+				}
+			}
+		}
+		if !slices.Equal(inherited.FramedRoutes, t.FramedRoutes) {
+			routes, err := v.updateFramedRoutes(inherited.FramedRoutes, t.FramedRoutes, t.VRF, t.SwIfIndex)
+			if err != nil {
+				t.FramedRoutes = routes
+				v.deleteGTPUTunnel(t)
+				return err
+			}
+		}
+
+		fmt.Printf("VPP: Adopted GTP-U tunnel %s (sw_if_index %d)\n", t.key(), t.SwIfIndex)
+		return nil
+	}
+
+	isV6 := net.ParseIP(t.UE).To4() == nil && t.UE != ""
+	decapNext := gtpu.GTPU_API_DECAP_NEXT_IP4
+	if isV6 {
+		decapNext = gtpu.GTPU_API_DECAP_NEXT_IP6
+	}
+
+	req := &gtpu.GtpuAddDelTunnelV2{
+		IsAdd:          true,
+		SrcAddress:     toVPPAddress(t.Src),
+		DstAddress:     toVPPAddress(t.Dst),
+		McastSwIfIndex: ^interface_types.InterfaceIndex(0),
+		EncapVrfID:     t.EncapVRF,
+		DecapNextIndex: decapNext,
+		Teid:           t.TEID,
+		Tteid:          t.TTEID,
+	}
+
+	reply := &gtpu.GtpuAddDelTunnelV2Reply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+	t.SwIfIndex = uint32(reply.SwIfIndex)
+
+	if err := v.enableTunnelInterface(t.SwIfIndex, t.VRF); err != nil {
+		v.removeGTPUTunnel(t)
+		return err
+	}
+
+	if t.UE != "" {
+		if err := v.setGTPURoute(t, true); err != nil {
+			v.removeGTPUTunnel(t)
+			return err
+		}
+	}
+
+	if routes, err := v.updateFramedRoutes(nil, t.FramedRoutes, t.VRF, t.SwIfIndex); err != nil {
+		t.FramedRoutes = routes
+		v.deleteGTPUTunnel(t)
+		return err
+	}
+
+	fmt.Printf("VPP: Created GTP-U tunnel %s (TTEID 0x%08x, sw_if_index %d, UE %s)\n",
+		t.key(), t.TTEID, t.SwIfIndex, t.UE)
+	return nil
+}
+
+// enableTunnelInterface brings a tunnel interface up in FIB table vrf and
+// borrows the address of the first core interface so that decapsulated
+// packets are IP routed.
+func (v *VPPDataplane) enableTunnelInterface(index, vrf uint32) error {
+	swIfIndex := interface_types.InterfaceIndex(index)
+
+	// The table has to be set while the interface has no address.
+	if vrf != 0 {
+		for _, isIPv6 := range []bool{false, true} {
+			table := &interfaces.SwInterfaceSetTable{SwIfIndex: swIfIndex, IsIPv6: isIPv6, VrfID: vrf}
+			tableReply := &interfaces.SwInterfaceSetTableReply{}
+			if err := v.ch.SendRequest(table).ReceiveReply(tableReply); err != nil {
+				return fmt.Errorf("set interface %d table %d: %w", swIfIndex, vrf, err)
+			}
+			if tableReply.Retval != 0 {
+				return fmt.Errorf("set interface %d table %d: VPPApiError: %s (%d)",
+					swIfIndex, vrf, vppErrorString(tableReply.Retval), tableReply.Retval)
+			}
+		}
+	}
+
+	flags := &interfaces.SwInterfaceSetFlags{
+		SwIfIndex: swIfIndex,
+		Flags:     interface_types.IF_STATUS_API_FLAG_ADMIN_UP,
+	}
+	flagsReply := &interfaces.SwInterfaceSetFlagsReply{}
+	if err := v.ch.SendRequest(flags).ReceiveReply(flagsReply); err != nil {
+		return fmt.Errorf("set interface %d up: %w", swIfIndex, err)
+	}
+	if flagsReply.Retval != 0 {
+		return fmt.Errorf("set interface %d up: VPPApiError: %s (%d)",
+			swIfIndex, vppErrorString(flagsReply.Retval), flagsReply.Retval)
+	}
+
+	if len(v.coreInterfaces) == 0 {
+		return nil
+	}
+
+	unnumbered := &interfaces.SwInterfaceSetUnnumbered{
+		SwIfIndex:           v.coreInterfaces[0],
+		UnnumberedSwIfIndex: swIfIndex,
+		IsAdd:               true,
+	}
+	unnumberedReply := &interfaces.SwInterfaceSetUnnumberedReply{}
+	if err := v.ch.SendRequest(unnumbered).ReceiveReply(unnumberedReply); err != nil {
+		return fmt.Errorf("set interface %d unnumbered: %w", swIfIndex, err)
+	}
+	if unnumberedReply.Retval != 0 {
+		return fmt.Errorf("set interface %d unnumbered: VPPApiError: %s (%d)",
+			swIfIndex, vppErrorString(unnumberedReply.Retval), unnumberedReply.Retval)
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) setGTPURoute(t *gtpuTunnel, isAdd bool) error {
+	ue := ueNet(t.UE, t.UEPrefixLength)
+	if ue == nil {
+		return fmt.Errorf("invalid UE IP address %q", t.UE)
+	}
+	return v.setInterfaceRoute(ue, t.VRF, t.SwIfIndex, isAdd)
+}
+
+// sameVRFs reports whether two tunnels are in the same FIB tables.
+func sameVRFs(a, b *gtpuTunnel) bool {
+	return a.EncapVRF == b.EncapVRF && a.VRF == b.VRF
+}
+
+func (v *VPPDataplane) updateGTPUTunnelTTEID(t *gtpuTunnel, tteid uint32) error {
+	req := &gtpu.GtpuTunnelUpdateTteid{
+		DstAddress: toVPPAddress(t.Dst),
+		Teid:       t.TEID,
+		Tteid:      tteid,
+	}
+
+	reply := &gtpu.GtpuTunnelUpdateTteidReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	fmt.Printf("VPP: GTP-U tunnel %s TTEID 0x%08x -> 0x%08x\n", t.key(), t.TTEID, tteid)
+	return nil
+}
+
+// deleteGTPUTunnel removes a tunnel and its routes. One that cannot be
+// removed is left for Reconcile.
+func (v *VPPDataplane) deleteGTPUTunnel(t *gtpuTunnel) {
+	v.removeFramedRoutes(t.FramedRoutes, t.VRF, t.SwIfIndex)
+	t.FramedRoutes = nil
+	if t.UE != "" {
+		if err := v.setGTPURoute(t, false); err != nil {
+			fmt.Printf("VPP: ERROR removing route to %s: %v\n", t.UE, err)
+		}
+	}
+
+	if err := v.setGTPUTunnel(t, false); err != nil {
+		fmt.Printf("VPP: ERROR removing GTP-U tunnel %s: %v\n", t.key(), err)
+		v.inheritedTunnels[t.key()] = t
+		return
+	}
+
+	fmt.Printf("VPP: GTP-U tunnel %s removed\n", t.key())
+}
+
+// removeGTPUTunnel rolls back a tunnel that could not be fully set up.
+func (v *VPPDataplane) removeGTPUTunnel(t *gtpuTunnel) {
+	if err := v.setGTPUTunnel(t, false); err != nil {
+		fmt.Printf("VPP: ERROR removing GTP-U tunnel %s: %v\n", t.key(), err)
+		v.inheritedTunnels[t.key()] = t
+	}
+}
+
+func (v *VPPDataplane) setGTPUTunnel(t *gtpuTunnel, isAdd bool) error {
+	req := &gtpu.GtpuAddDelTunnelV2{
+		IsAdd:          isAdd,
+		SrcAddress:     toVPPAddress(t.Src),
+		DstAddress:     toVPPAddress(t.Dst),
+		McastSwIfIndex: ^interface_types.InterfaceIndex(0),
+		EncapVrfID:     t.EncapVRF,
+		Teid:           t.TEID,
+		Tteid:          t.TTEID,
+	}
+
+	reply := &gtpu.GtpuAddDelTunnelV2Reply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+// gtpuTunnelKeys returns the interface index of each GTP-U tunnel in VPP by
+// key.
+func (v *VPPDataplane) gtpuTunnelKeys() (map[string]uint32, error) {
+	keys := make(map[string]uint32)
+
+	reqCtx := v.ch.SendMultiRequest(&gtpu.GtpuTunnelV2Dump{SwIfIndex: ^interface_types.InterfaceIndex(0)})
+	for {
+		details := &gtpu.GtpuTunnelV2Details{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			return keys, nil
+		}
+		t := &gtpuTunnel{
+			Src:  details.SrcAddress.ToIP().String(),
+			Dst:  details.DstAddress.ToIP().String(),
+			TEID: details.Teid,
+		}
+		keys[t.key()] = uint32(details.SwIfIndex)
+	}
+}
+
+func toVPPAddress(s string) ip_types.Address {
+	return ip_types.NewAddress(net.ParseIP(s))
+}
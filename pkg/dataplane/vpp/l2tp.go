@@ -0,0 +1,67 @@
+package vpp
+
+import (
+	"fmt"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+)
+
+// VPP has no L2TPv2: its l2tp plugin only speaks L2TPv3 over IP, and the
+// pppoe plugin terminates PPP rather than handing PPP frames on. So when the
+// UP acts as a LAC, the VPP dataplane diverts the traffic of the L2TP
+// session for the UP to forward: the subscriber's PPPoE frames through the
+// L2 punt tap, and the LNS's L2TP packets through the punt socket. The UP
+// removes and creates the PPPoE and L2TP headers and injects the result
+// back through the punt socket.
+
+// isUPForwardedPDR reports whether the PDR's traffic is diverted for the UP
+// to forward: PDRs that keep the PPP frame or remove L2TP, and those whose
+// FAR sends into an L2TP session.
+func isUPForwardedPDR(session *sessionState, pdr *up.PDR) bool {
+	if ohr := pdr.BBFOuterHeaderRemoval; ohr != nil && removedByUP(*ohr) {
+		return true
+	}
+	far, ok := session.fars[pdr.FAR_ID]
+	return ok && far.ForwardingParameters != nil && far.ForwardingParameters.L2TP != nil
+}
+
+// removedByUP reports whether the UP rather than the pppoe plugin applies a
+// BBF Outer Header Removal.
+func removedByUP(description uint8) bool {
+	switch description {
+	case protocol.BBFOuterHeaderRemovalPPPoEEthernet, protocol.BBFOuterHeaderRemovalL2TP, protocol.BBFOuterHeaderRemovalPPPL2TP:
+		return true
+	}
+	return false
+}
+
+// checkUPForwarding returns an error unless diverted traffic reaches the
+// UP and can be injected back.
+func (v *VPPDataplane) checkUPForwarding() error {
+	if v.l2PuntTap == nil || v.puntSocket == nil {
+		return fmt.Errorf("forwarded by the UP, which needs an L2 punt tap and a punt socket")
+	}
+	return nil
+}
+
+// checkBBFOuterHeaderRemoval accepts the removals the pppoe plugin applies
+// itself, and those the UP does.
+func (v *VPPDataplane) checkBBFOuterHeaderRemoval(pdr *up.PDR) error {
+	ohr := pdr.BBFOuterHeaderRemoval
+	if ohr == nil {
+		return nil
+	}
+
+	switch {
+	case *ohr == protocol.BBFOuterHeaderRemovalPPPPPPoE:
+		return nil
+	case removedByUP(*ohr):
+		if err := v.checkUPForwarding(); err != nil {
+			return fmt.Errorf("PDR %d: BBF outer header removal %d is %w", pdr.ID, *ohr, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("PDR %d: BBF outer header removal %d is not supported by the VPP dataplane", pdr.ID, *ohr)
+	}
+}
@@ -0,0 +1,214 @@
+package vpp
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/span"
+)
+
+// mirror is a SPAN session copying the traffic of a session's GTP-U tunnel
+// to a duplication destination: an interface named by a Forwarding Policy,
+// or a GTP-U tunnel to a collector. VPP mirrors per interface, so every
+// packet the tunnel carries is copied, not only those of the duplicating
+// FAR's PDRs.
+type mirror struct {
+	From uint32
+	To   uint32
+	// Tunnel is the key of the collector tunnel in mirrorTunnels, or empty
+	// for a Forwarding Policy interface.
+	Tunnel string
+}
+
+// mirrorTarget is a destination a FAR duplicates to.
+type mirrorTarget struct {
+	policy string
+	tunnel *gtpuTunnel
+}
+
+func (t *mirrorTarget) key() string {
+	if t.tunnel != nil {
+		return "gtpu:" + t.tunnel.key()
+	}
+	return "policy:" + t.policy
+}
+
+// mirrorTargets returns the destinations of the session's duplicating FARs,
+// by key. Collector tunnels are sourced from the session's tunnel address.
+func mirrorTargets(session *sessionState) (map[string]*mirrorTarget, error) {
+	targets := make(map[string]*mirrorTarget)
+	for _, farID := range slices.Sorted(maps.Keys(session.fars)) {
+		far := session.fars[farID]
+		if far.ApplyAction&protocol.ApplyActionDuplicate == 0 {
+			continue
+		}
+
+		for _, dp := range far.DuplicatingParameters {
+			target, err := mirrorTargetFor(session, dp)
+			if err != nil {
+				return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+			}
+			if target != nil {
+				targets[target.key()] = target
+			}
+		}
+	}
+	return targets, nil
+}
+
+func mirrorTargetFor(session *sessionState, dp *up.DuplicatingParameters) (*mirrorTarget, error) {
+	if dp.ForwardingPolicy != "" {
+		return &mirrorTarget{policy: dp.ForwardingPolicy}, nil
+	}
+
+	ohc := dp.OuterHeaderCreation
+	if ohc == nil || !isGTPUEncap(ohc) {
+		return nil, fmt.Errorf("VPP duplicates to a forwarding policy interface or over GTP-U only")
+	}
+	if session.gtpu == nil {
+		return nil, nil
+	}
+
+	t := &gtpuTunnel{Src: session.gtpu.Src, TEID: ohc.TEID, TTEID: ohc.TEID, EncapVRF: session.gtpu.EncapVRF}
+	isV4 := net.ParseIP(t.Src).To4() != nil
+	switch {
+	case isV4 && ohc.Description&protocol.OuterHeaderCreationGTPUUDPIPv4 != 0 && ohc.IPv4 != nil:
+		t.Dst = ohc.IPv4.String()
+	case !isV4 && ohc.Description&protocol.OuterHeaderCreationGTPUUDPIPv6 != 0 && ohc.IPv6 != nil:
+		t.Dst = ohc.IPv6.String()
+	default:
+		return nil, fmt.Errorf("collector has no address in the family of GTP-U tunnel %s", session.gtpu.key())
+	}
+	return &mirrorTarget{tunnel: t}, nil
+}
+
+// syncMirrors brings the session's SPAN sessions in line with its
+// duplicating FARs and GTP-U tunnel. A session without a tunnel has nothing
+// to mirror from.
+func (v *VPPDataplane) syncMirrors(session *sessionState) error {
+	targets, err := mirrorTargets(session)
+	if err != nil {
+		return err
+	}
+	if session.gtpu == nil && len(targets) > 0 {
+		fmt.Printf("VPP: Session %d duplicates, but has no GTP-U tunnel to mirror\n", session.SEID)
+		targets = nil
+	}
+
+	for key, m := range session.mirrors {
+		if _, ok := targets[key]; ok && m.From == session.gtpu.SwIfIndex {
+			delete(targets, key)
+			continue
+		}
+		v.removeMirror(m)
+		delete(session.mirrors, key)
+	}
+
+	if len(targets) == 0 {
+		return nil
+	}
+	defer v.saveTunnelState()
+
+	for _, key := range slices.Sorted(maps.Keys(targets)) {
+		m, err := v.addMirror(session.gtpu, targets[key])
+		if err != nil {
+			return fmt.Errorf("mirror to %s: %w", key, err)
+		}
+		if session.mirrors == nil {
+			session.mirrors = make(map[string]*mirror)
+		}
+		session.mirrors[key] = m
+	}
+	return nil
+}
+
+func (v *VPPDataplane) addMirror(from *gtpuTunnel, target *mirrorTarget) (*mirror, error) {
+	m := &mirror{From: from.SwIfIndex}
+
+	if target.tunnel != nil {
+		t, err := v.acquireMirrorTunnel(target.tunnel)
+		if err != nil {
+			return nil, err
+		}
+		m.To, m.Tunnel = t.SwIfIndex, t.key()
+	} else {
+		indexes, err := v.resolveInterfaces([]string{target.policy})
+		if err != nil {
+			return nil, fmt.Errorf("forwarding policy: %w", err)
+		}
+		m.To = uint32(indexes[0])
+	}
+
+	if err := v.setSpan(m.From, m.To, span.SPAN_STATE_API_RX_TX); err != nil {
+		if m.Tunnel != "" {
+			v.releaseMirrorTunnel(m.Tunnel)
+		}
+		return nil, err
+	}
+
+	fmt.Printf("VPP: Mirroring GTP-U tunnel %s to %s (sw_if_index %d)\n", from.key(), target.key(), m.To)
+	return m, nil
+}
+
+// removeMirror stops a SPAN session. Its source may already be gone with
+// the session's old tunnel, so failures are only logged.
+func (v *VPPDataplane) removeMirror(m *mirror) {
+	if err := v.setSpan(m.From, m.To, span.SPAN_STATE_API_DISABLED); err != nil {
+		fmt.Printf("VPP: ERROR disabling mirror from %d to %d: %v\n", m.From, m.To, err)
+	}
+	if m.Tunnel != "" {
+		v.releaseMirrorTunnel(m.Tunnel)
+	}
+}
+
+// acquireMirrorTunnel references the tunnel to a collector, creating it for
+// its first session.
+func (v *VPPDataplane) acquireMirrorTunnel(t *gtpuTunnel) (*gtpuTunnel, error) {
+	key := t.key()
+	if existing, ok := v.mirrorTunnels[key]; ok {
+		v.mirrorRefs[key]++
+		return existing, nil
+	}
+
+	if err := v.createGTPUTunnel(t); err != nil {
+		return nil, fmt.Errorf("create collector tunnel: %w", err)
+	}
+	v.mirrorTunnels[key] = t
+	v.mirrorRefs[key] = 1
+	return t, nil
+}
+
+func (v *VPPDataplane) releaseMirrorTunnel(key string) {
+	v.mirrorRefs[key]--
+	if v.mirrorRefs[key] > 0 {
+		return
+	}
+
+	t := v.mirrorTunnels[key]
+	delete(v.mirrorTunnels, key)
+	delete(v.mirrorRefs, key)
+	v.deleteGTPUTunnel(t)
+}
+
+func (v *VPPDataplane) setSpan(from, to uint32, state span.SpanState) error {
+	req := &span.SwInterfaceSpanEnableDisable{
+		SwIfIndexFrom: interface_types.InterfaceIndex(from),
+		SwIfIndexTo:   interface_types.InterfaceIndex(to),
+		State:         state,
+	}
+
+	reply := &span.SwInterfaceSpanEnableDisableReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
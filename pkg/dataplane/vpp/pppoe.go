@@ -0,0 +1,249 @@
+package vpp
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/ethernet_types"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/pppoe"
+)
+
+// pppoeSession is the VPP PPPoE session of a PFCP session. The pppoe plugin
+// decapsulates the subscriber's frames by session ID and MAC address, and
+// routes traffic to ClientIP into the session, encapsulating it towards the
+// interface it learned the MAC address on. VLAN tags are therefore those of
+// that (sub-)interface, not the FAR's. DecapVRF is the FIB table decapsulated
+// packets are routed in, which holds the route to ClientIP and the
+// FramedRoutes through the session.
+type pppoeSession struct {
+	SwIfIndex    uint32   `json:"sw_if_index"`
+	SessionID    uint16   `json:"session_id"`
+	ClientIP     string   `json:"client_ip"`
+	ClientMAC    string   `json:"client_mac"`
+	DecapVRF     uint32   `json:"decap_vrf,omitempty"`
+	FramedRoutes []string `json:"framed_routes,omitempty"`
+}
+
+func (s *pppoeSession) key() string {
+	return fmt.Sprintf("%s/%d/%d/%s", s.ClientMAC, s.SessionID, s.DecapVRF, s.ClientIP)
+}
+
+// isPPPoEPDR reports whether the PDR's traffic is carried by the session's
+// PPPoE session rather than punted.
+func isPPPoEPDR(session *sessionState, pdr *up.PDR) bool {
+	if pdr.BBFOuterHeaderRemoval != nil {
+		return true
+	}
+	far, ok := session.fars[pdr.FAR_ID]
+	return ok && far.ForwardingParameters != nil && far.ForwardingParameters.PPPoE != nil
+}
+
+// desiredPPPoESession derives the session's PPPoE session from the lowest
+// numbered forwarding FAR that encapsulates into one and the lowest numbered
+// PDR with a UE IP address, in that PDR's Network Instance. It returns nil
+// until the session has both, e.g. while IPCP is still punted to the CP.
+func (v *VPPDataplane) desiredPPPoESession(session *sessionState) (*pppoeSession, error) {
+	var encap *up.FAR
+	for _, far := range session.fars {
+		fp := far.ForwardingParameters
+		if far.ApplyAction&0x02 == 0 || fp == nil || fp.PPPoE == nil {
+			continue
+		}
+		if encap == nil || far.ID < encap.ID {
+			encap = far
+		}
+	}
+
+	var ue *up.PDR
+	for _, pdr := range session.pdrs {
+		if pdr.PDI != nil && pdr.PDI.UE_IPAddress != "" && (ue == nil || pdr.ID < ue.ID) {
+			ue = pdr
+		}
+	}
+
+	if encap == nil || ue == nil {
+		return nil, nil
+	}
+
+	if net.ParseIP(ue.PDI.UE_IPAddress) == nil {
+		return nil, fmt.Errorf("invalid UE IP address %q", ue.PDI.UE_IPAddress)
+	}
+
+	s := encap.ForwardingParameters.PPPoE
+	return &pppoeSession{
+		SessionID:    s.SessionID,
+		ClientIP:     ue.PDI.UE_IPAddress,
+		ClientMAC:    s.PeerMAC.String(),
+		DecapVRF:     v.fibTable(ue.PDI.NetworkInstance),
+		FramedRoutes: framedRoutes(session),
+	}, nil
+}
+
+// syncPPPoESession brings the session's PPPoE session in line with its PDRs
+// and FARs. VPP cannot update a session, so any change other than to the
+// framed routes replaces it.
+func (v *VPPDataplane) syncPPPoESession(session *sessionState) error {
+	want, err := v.desiredPPPoESession(session)
+	if err != nil {
+		return err
+	}
+
+	have := session.pppoe
+	if have != nil && want != nil && have.key() == want.key() {
+		if slices.Equal(have.FramedRoutes, want.FramedRoutes) {
+			return nil
+		}
+		defer v.saveTunnelState()
+		have.FramedRoutes, err = v.updateFramedRoutes(have.FramedRoutes, want.FramedRoutes, have.DecapVRF, have.SwIfIndex)
+		return err
+	}
+
+	if have != nil {
+		v.deletePPPoESession(have)
+		session.pppoe = nil
+	}
+
+	if want != nil {
+		if err := v.createPPPoESession(want); err != nil {
+			v.saveTunnelState()
+			return fmt.Errorf("create PPPoE session: %w", err)
+		}
+		session.pppoe = want
+	}
+
+	v.saveTunnelState()
+	return nil
+}
+
+// createPPPoESession programs a session, or claims the identical one a
+// previous run left behind.
+func (v *VPPDataplane) createPPPoESession(s *pppoeSession) error {
+	if inherited, ok := v.inheritedPPPoE[s.key()]; ok {
+		delete(v.inheritedPPPoE, s.key())
+		s.SwIfIndex = inherited.SwIfIndex
+		if !slices.Equal(inherited.FramedRoutes, s.FramedRoutes) {
+			routes, err := v.updateFramedRoutes(inherited.FramedRoutes, s.FramedRoutes, s.DecapVRF, s.SwIfIndex)
+			if err != nil {
+				s.FramedRoutes = routes
+				v.deletePPPoESession(s)
+				return err
+			}
+		}
+		fmt.Printf("VPP: Adopted PPPoE session %s (sw_if_index %d)\n", s.key(), s.SwIfIndex)
+		return nil
+	}
+
+	swIfIndex, err := v.setPPPoESession(s, true)
+	if err != nil {
+		return err
+	}
+	s.SwIfIndex = swIfIndex
+
+	if err := v.enableTunnelInterface(s.SwIfIndex, s.DecapVRF); err != nil {
+		v.removePPPoESession(s)
+		return err
+	}
+
+	if routes, err := v.updateFramedRoutes(nil, s.FramedRoutes, s.DecapVRF, s.SwIfIndex); err != nil {
+		s.FramedRoutes = routes
+		v.deletePPPoESession(s)
+		return err
+	}
+
+	fmt.Printf("VPP: Created PPPoE session %s (sw_if_index %d)\n", s.key(), s.SwIfIndex)
+	return nil
+}
+
+// deletePPPoESession removes a session's framed routes and the session, and
+// with it the plugin's route to the client. One that cannot be removed is
+// left for Reconcile.
+func (v *VPPDataplane) deletePPPoESession(s *pppoeSession) {
+	v.removeFramedRoutes(s.FramedRoutes, s.DecapVRF, s.SwIfIndex)
+	s.FramedRoutes = nil
+	if _, err := v.setPPPoESession(s, false); err != nil {
+		fmt.Printf("VPP: ERROR removing PPPoE session %s: %v\n", s.key(), err)
+		v.inheritedPPPoE[s.key()] = s
+		return
+	}
+
+	fmt.Printf("VPP: PPPoE session %s removed\n", s.key())
+}
+
+// removePPPoESession rolls back a session that could not be fully set up.
+func (v *VPPDataplane) removePPPoESession(s *pppoeSession) {
+	if _, err := v.setPPPoESession(s, false); err != nil {
+		fmt.Printf("VPP: ERROR removing PPPoE session %s: %v\n", s.key(), err)
+		v.inheritedPPPoE[s.key()] = s
+	}
+}
+
+func (v *VPPDataplane) setPPPoESession(s *pppoeSession, isAdd bool) (uint32, error) {
+	mac, err := ethernet_types.ParseMacAddress(s.ClientMAC)
+	if err != nil {
+		return 0, err
+	}
+
+	req := &pppoe.PppoeAddDelSession{
+		IsAdd:      isAdd,
+		SessionID:  s.SessionID,
+		ClientIP:   toVPPAddress(s.ClientIP),
+		DecapVrfID: s.DecapVRF,
+		ClientMac:  mac,
+	}
+
+	reply := &pppoe.PppoeAddDelSessionReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return 0, err
+	}
+	if reply.Retval != 0 {
+		return 0, fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return uint32(reply.SwIfIndex), nil
+}
+
+// setPPPoEControlInterface makes the pppoe plugin hand PPPoE discovery and
+// PPP control frames to an interface, e.g. a tap towards the BNG control
+// plane. The plugin learns the subscriber MAC addresses sessions are created
+// for from these frames.
+func (v *VPPDataplane) setPPPoEControlInterface(swIfIndex interface_types.InterfaceIndex) error {
+	req := &pppoe.PppoeAddDelCp{SwIfIndex: swIfIndex, IsAdd: 1}
+
+	reply := &pppoe.PppoeAddDelCpReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+// pppoeSessionKeys returns the interface index of each PPPoE session in VPP
+// by key.
+func (v *VPPDataplane) pppoeSessionKeys() (map[string]uint32, error) {
+	keys := make(map[string]uint32)
+
+	reqCtx := v.ch.SendMultiRequest(&pppoe.PppoeSessionDump{SwIfIndex: ^interface_types.InterfaceIndex(0)})
+	for {
+		details := &pppoe.PppoeSessionDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			return keys, nil
+		}
+		s := &pppoeSession{
+			SessionID: details.SessionID,
+			ClientIP:  details.ClientIP.ToIP().String(),
+			ClientMAC: details.ClientMac.String(),
+			DecapVRF:  details.DecapVrfID,
+		}
+		keys[s.key()] = uint32(details.SwIfIndex)
+	}
+}
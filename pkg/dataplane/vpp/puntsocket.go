@@ -0,0 +1,289 @@
+package vpp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/api"
+	interfaces "go.fd.io/govpp/binapi/interface"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/punt"
+)
+
+// With a punt socket, L4 and IP protocol punts are registered with
+// PuntSocketRegister rather than SetPunt, so VPP sends the punted packets to
+// a datagram socket of ours instead of the host stack. Each datagram starts
+// with a punt_packetdesc_t, in host byte order, giving the receiving
+// interface; the packet follows from its Ethernet header. Packets sent to
+// VPP's own punt socket with the same header are injected: Ethernet frames
+// out of the interface, IP packets routed in its FIB. L2 punts go through
+// the classifier, which cannot reach the socket; they are delivered by
+// capturing the L2 punt tap instead.
+const (
+	puntDescLen          = 8
+	puntHeaderVersion    = 1
+	puntActionL2         = 0
+	puntActionIP4Routed  = 1
+	puntActionIP6Routed  = 2
+	interfaceRefreshRate = time.Second
+)
+
+// puntSocket is the VPP dataplane's up.PuntSource.
+type puntSocket struct {
+	conn *net.UnixConn
+	path string
+	// server is VPP's punt socket, learnt from PuntSocketRegister replies.
+	server string
+	ch     api.Channel
+	core   []interface_types.InterfaceIndex
+	// names and indexes cache the VPP interface names, refreshed when an
+	// unknown interface turns up.
+	names     map[interface_types.InterfaceIndex]string
+	indexes   map[string]interface_types.InterfaceIndex
+	refreshed time.Time
+	once      sync.Once
+	mu        sync.Mutex
+}
+
+// openPuntSocket binds the socket VPP sends punted packets to. It has a
+// channel of its own, since it is used concurrently with the dataplane.
+func (v *VPPDataplane) openPuntSocket(path string) (*puntSocket, error) {
+	// Remove the socket of a previous run.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", path, err)
+	}
+
+	ch, err := v.conn.NewAPIChannel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("create API channel: %w", err)
+	}
+
+	return &puntSocket{
+		conn: conn,
+		path: path,
+		ch:   ch,
+		core: v.coreInterfaces,
+	}, nil
+}
+
+// PuntSource returns the punt socket, or nil if none is configured.
+func (v *VPPDataplane) PuntSource() up.PuntSource {
+	if v.puntSocket == nil {
+		return nil
+	}
+	return v.puntSocket
+}
+
+func (p *puntSocket) setServer(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if path != p.server {
+		fmt.Printf("VPP: Punt socket server is %s\n", path)
+		p.server = path
+	}
+}
+
+func (p *puntSocket) ReadPunt() (*up.PuntedPacket, error) {
+	buf := make([]byte, 65536)
+	for {
+		n, err := p.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n < puntDescLen {
+			continue
+		}
+
+		swIfIndex := interface_types.InterfaceIndex(binary.NativeEndian.Uint32(buf[0:4]))
+		pkt := &up.PuntedPacket{
+			Interface:       p.interfaceName(swIfIndex),
+			SourceInterface: protocol.SourceInterfaceAccess,
+			Data:            append([]byte(nil), buf[puntDescLen:n]...),
+			ReceivedAt:      time.Now(),
+		}
+		// Interfaces not configured as core face subscribers.
+		if slices.Contains(p.core, swIfIndex) {
+			pkt.SourceInterface = protocol.SourceInterfaceCore
+		}
+
+		return pkt, nil
+	}
+}
+
+func (p *puntSocket) InjectPacket(pkt *up.InjectedPacket) error {
+	swIfIndex, err := p.interfaceIndex(pkt.Interface)
+	if err != nil {
+		return err
+	}
+
+	action := uint32(puntActionL2)
+	if pkt.IP {
+		if len(pkt.Data) == 0 {
+			return fmt.Errorf("empty IP packet")
+		}
+		switch pkt.Data[0] >> 4 {
+		case 4:
+			action = puntActionIP4Routed
+		case 6:
+			action = puntActionIP6Routed
+		default:
+			return fmt.Errorf("unknown IP version %d", pkt.Data[0]>>4)
+		}
+	}
+
+	p.mu.Lock()
+	server := p.server
+	p.mu.Unlock()
+	if server == "" {
+		return fmt.Errorf("VPP punt socket unknown until a punt is registered")
+	}
+
+	msg := make([]byte, puntDescLen+len(pkt.Data))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(swIfIndex))
+	binary.NativeEndian.PutUint32(msg[4:8], action)
+	copy(msg[puntDescLen:], pkt.Data)
+
+	if _, err := p.conn.WriteToUnix(msg, &net.UnixAddr{Name: server, Net: "unixgram"}); err != nil {
+		return fmt.Errorf("send to %s: %w", server, err)
+	}
+	return nil
+}
+
+func (p *puntSocket) Close() error {
+	p.once.Do(func() {
+		p.conn.Close()
+		p.ch.Close()
+		os.Remove(p.path)
+	})
+	return nil
+}
+
+func (p *puntSocket) interfaceName(swIfIndex interface_types.InterfaceIndex) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if name, ok := p.names[swIfIndex]; ok {
+		return name
+	}
+	if err := p.refreshInterfaces(); err != nil {
+		fmt.Printf("VPP: Failed to refresh interface names: %v\n", err)
+	}
+	if name, ok := p.names[swIfIndex]; ok {
+		return name
+	}
+	return fmt.Sprintf("sw_if_index %d", swIfIndex)
+}
+
+func (p *puntSocket) interfaceIndex(name string) (interface_types.InterfaceIndex, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if idx, ok := p.indexes[name]; ok {
+		return idx, nil
+	}
+	if err := p.refreshInterfaces(); err != nil {
+		return 0, fmt.Errorf("resolve interface %q: %w", name, err)
+	}
+	if idx, ok := p.indexes[name]; ok {
+		return idx, nil
+	}
+	return 0, fmt.Errorf("unknown interface %q", name)
+}
+
+// refreshInterfaces reloads the interface names, at most once per
+// interfaceRefreshRate so that traffic from unknown interfaces cannot flood
+// the API.
+func (p *puntSocket) refreshInterfaces() error {
+	if time.Since(p.refreshed) < interfaceRefreshRate {
+		return nil
+	}
+	p.refreshed = time.Now()
+
+	indexes, err := dumpInterfaces(p.ch)
+	if err != nil {
+		return err
+	}
+
+	p.indexes = indexes
+	p.names = make(map[interface_types.InterfaceIndex]string, len(indexes))
+	for name, idx := range indexes {
+		p.names[idx] = name
+	}
+	return nil
+}
+
+func dumpInterfaces(ch api.Channel) (map[string]interface_types.InterfaceIndex, error) {
+	byName := make(map[string]interface_types.InterfaceIndex)
+
+	reqCtx := ch.SendMultiRequest(&interfaces.SwInterfaceDump{
+		SwIfIndex: ^interface_types.InterfaceIndex(0),
+	})
+	for {
+		details := &interfaces.SwInterfaceDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return nil, fmt.Errorf("dump interfaces: %w", err)
+		}
+		if stop {
+			break
+		}
+		byName[details.InterfaceName] = details.SwIfIndex
+	}
+
+	return byName, nil
+}
+
+// setPuntSocket registers or deregisters a punt with the punt socket.
+func (v *VPPDataplane) setPuntSocket(reg *puntRegistration, p punt.Punt, isAdd bool) error {
+	if !isAdd {
+		reply := &punt.PuntSocketDeregisterReply{}
+		if err := v.ch.SendRequest(&punt.PuntSocketDeregister{Punt: p}).ReceiveReply(reply); err != nil {
+			return err
+		}
+		if reply.Retval != 0 {
+			return fmt.Errorf("VPPApiError: %s (%d) for %s", vppErrorString(reply.Retval), reply.Retval, reg.key())
+		}
+		return nil
+	}
+
+	req := &punt.PuntSocketRegister{
+		HeaderVersion: puntHeaderVersion,
+		Punt:          p,
+		Pathname:      reg.Socket,
+	}
+	reply := &punt.PuntSocketRegisterReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d) for %s", vppErrorString(reply.Retval), reply.Retval, reg.key())
+	}
+
+	if v.puntSocket != nil {
+		v.puntSocket.setServer(reply.Pathname)
+	}
+	return nil
+}
+
+// InjectPacket sends a packet the CP generates through the punt socket, the
+// only way VPP takes packets from us.
+func (v *VPPDataplane) InjectPacket(pkt *up.InjectedPacket) error {
+	if v.puntSocket == nil {
+		return fmt.Errorf("packet injection needs a VPP punt socket")
+	}
+	return v.puntSocket.InjectPacket(pkt)
+}
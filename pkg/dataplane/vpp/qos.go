@@ -0,0 +1,619 @@
+package vpp
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+	"github.com/veesix-networks/pfcp-go/pkg/up"
+	"go.fd.io/govpp/binapi/classify"
+	"go.fd.io/govpp/binapi/interface_types"
+	"go.fd.io/govpp/binapi/policer"
+	"go.fd.io/govpp/binapi/policer_types"
+)
+
+// QERs are enforced with one VPP policer per QER and direction. A PDR binds
+// its UE address to the policer through a policer-classify session: uplink
+// PDRs match the UE as source on the access interfaces, downlink PDRs match
+// it as destination on the core interfaces. IPv4 UEs share a table per
+// direction; IPv6 UEs share one per direction and prefix length, so that a
+// UE with a delegated prefix is policed on every address in it.
+type qerDirection int
+
+const (
+	qerUplink qerDirection = iota
+	qerDownlink
+)
+
+var qerDirections = []qerDirection{qerUplink, qerDownlink}
+
+func (d qerDirection) String() string {
+	if d == qerUplink {
+		return "ul"
+	}
+	return "dl"
+}
+
+// UE addresses are matched from the start of the Ethernet header, like VPP's
+// "l3 ip4 src" and "l3 ip6 dst" masks, skipping the first vector.
+const (
+	ip4SrcOffset         = 14 + 12
+	ip4DstOffset         = 14 + 16
+	ip6SrcOffset         = 14 + 8
+	ip6DstOffset         = 14 + 24
+	policerSkipVectors   = 1
+	policerMatchVectors  = 2
+	policerMatchVectors6 = 3
+	policerNamePrefix    = "pfcp-"
+	// minPolicerBurst keeps low rates from dropping single full-size frames.
+	minPolicerBurst = 15000
+)
+
+// policerTable identifies a policer classify table: the direction, and for
+// IPv6 the length of the UE prefixes it matches, 0 for IPv4.
+type policerTable struct {
+	dir          qerDirection
+	ip6PrefixLen int
+}
+
+// String names the table in the state file; IPv4 tables keep the
+// direction's name.
+func (t policerTable) String() string {
+	if t.ip6PrefixLen == 0 {
+		return t.dir.String()
+	}
+	return fmt.Sprintf("%s-ip6/%d", t.dir, t.ip6PrefixLen)
+}
+
+func parsePolicerTable(s string) (policerTable, bool) {
+	dir, prefix, isV6 := strings.Cut(s, "-ip6/")
+	for _, d := range qerDirections {
+		if dir != d.String() {
+			continue
+		}
+		if !isV6 {
+			return policerTable{dir: d}, true
+		}
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n < 1 || n > 128 {
+			return policerTable{}, false
+		}
+		return policerTable{dir: d, ip6PrefixLen: n}, true
+	}
+	return policerTable{}, false
+}
+
+func (t policerTable) ueOffset() int {
+	switch {
+	case t.ip6PrefixLen != 0 && t.dir == qerUplink:
+		return ip6SrcOffset
+	case t.ip6PrefixLen != 0:
+		return ip6DstOffset
+	case t.dir == qerUplink:
+		return ip4SrcOffset
+	}
+	return ip4DstOffset
+}
+
+func (t policerTable) matchVectors() int {
+	if t.ip6PrefixLen != 0 {
+		return policerMatchVectors6
+	}
+	return policerMatchVectors
+}
+
+func (t policerTable) ueMask() net.IPMask {
+	if t.ip6PrefixLen != 0 {
+		return net.CIDRMask(t.ip6PrefixLen, 128)
+	}
+	return net.CIDRMask(32, 32)
+}
+
+func ueAddressMask(t policerTable) []byte {
+	mask := make([]byte, t.matchVectors()*classifyVectorSize)
+	offset := t.ueOffset() - policerSkipVectors*classifyVectorSize
+	copy(mask[offset:], t.ueMask())
+	return mask
+}
+
+func ueAddressMatch(t policerTable, ip net.IP) []byte {
+	match := make([]byte, (policerSkipVectors+t.matchVectors())*classifyVectorSize)
+	copy(match[t.ueOffset():], ip.Mask(t.ueMask()))
+	return match
+}
+
+// uePolicerTable returns the policer table and address that match the PDR's
+// UE in direction d.
+func uePolicerTable(pdr *up.PDR, d qerDirection) (policerTable, net.IP, error) {
+	ip := net.ParseIP(pdr.PDI.UE_IPAddress)
+	switch {
+	case ip == nil:
+		return policerTable{}, nil, fmt.Errorf("PDR %d has no UE IP address to police", pdr.ID)
+	case ip.To4() != nil:
+		return policerTable{dir: d}, ip.To4(), nil
+	}
+
+	prefixLen := int(pdr.PDI.UE_IPPrefixLength)
+	if prefixLen == 0 {
+		prefixLen = 128
+	}
+	return policerTable{dir: d, ip6PrefixLen: prefixLen}, ip, nil
+}
+
+func pdrDirection(pdr *up.PDR) (qerDirection, bool) {
+	if pdr.PDI == nil {
+		return 0, false
+	}
+
+	switch pdr.PDI.SourceInterface {
+	case protocol.SourceInterfaceAccess:
+		return qerUplink, true
+	case protocol.SourceInterfaceCore:
+		return qerDownlink, true
+	}
+	return 0, false
+}
+
+func policerName(seid uint64, qerID uint32, d qerDirection) string {
+	return fmt.Sprintf("%s%d-qer%d-%s", policerNamePrefix, seid, qerID, d)
+}
+
+// policerConfig maps a QER gate and MBR (kbps) to a policer. It returns false
+// when the direction is open and unlimited, so no policer is needed.
+func policerConfig(gate uint8, mbr uint64) (policer_types.PolicerConfig, bool) {
+	drop := policer_types.Sse2QosAction{Type: policer_types.SSE2_QOS_ACTION_API_DROP}
+	transmit := policer_types.Sse2QosAction{Type: policer_types.SSE2_QOS_ACTION_API_TRANSMIT}
+
+	cfg := policer_types.PolicerConfig{
+		RateType:      policer_types.SSE2_QOS_RATE_API_KBPS,
+		RoundType:     policer_types.SSE2_QOS_ROUND_API_TO_CLOSEST,
+		Type:          policer_types.SSE2_QOS_POLICER_TYPE_API_1R2C,
+		Cb:            minPolicerBurst,
+		ConformAction: transmit,
+		ExceedAction:  drop,
+		ViolateAction: drop,
+	}
+
+	switch {
+	case gate == protocol.GateStatusClosed:
+		cfg.ConformAction = drop
+	case mbr == 0:
+		return cfg, false
+	default:
+		cfg.Cir = uint32(min(mbr, math.MaxUint32))
+		// Allow bursts of 100ms at the committed rate.
+		cfg.Cb = max(uint64(cfg.Cir)*1000/8/10, minPolicerBurst)
+	}
+
+	return cfg, true
+}
+
+func (v *VPPDataplane) directionInterfaces(d qerDirection) []interface_types.InterfaceIndex {
+	if d == qerUplink {
+		return v.accessInterfaces
+	}
+	return v.coreInterfaces
+}
+
+func (v *VPPDataplane) ensurePolicerTable(t policerTable) (uint32, error) {
+	if tableIdx, ok := v.policerTables[t]; ok {
+		return tableIdx, nil
+	}
+
+	if len(v.directionInterfaces(t.dir)) == 0 {
+		return 0, fmt.Errorf("no %s interfaces configured for QER enforcement", t.dir.interfaceRole())
+	}
+
+	tableIdx, err := v.createClassifyTable(policerSkipVectors, ueAddressMask(t))
+	if err != nil {
+		return 0, fmt.Errorf("create classify table: %w", err)
+	}
+
+	v.policerTables[t] = tableIdx
+
+	if err := v.attachPolicerTable(t); err != nil {
+		v.teardownPolicerTable(t)
+		return 0, err
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	return tableIdx, nil
+}
+
+func (d qerDirection) interfaceRole() string {
+	if d == qerUplink {
+		return "access"
+	}
+	return "core"
+}
+
+// filterDirection returns the IPFilterRule direction of the traffic.
+func (d qerDirection) filterDirection() ipfilter.Direction {
+	if d == qerUplink {
+		return ipfilter.DirectionIn
+	}
+	return ipfilter.DirectionOut
+}
+
+// attachPolicerTable attaches the table to the direction's interfaces. An
+// interface takes one policer table per address family, so the IPv6 tables
+// of a direction are chained behind each other, longest prefix first, and
+// only the head is attached.
+func (v *VPPDataplane) attachPolicerTable(t policerTable) error {
+	tableIdx := v.policerTables[t]
+	if t.ip6PrefixLen != 0 {
+		var err error
+		if tableIdx, err = v.chainPolicerTables6(t.dir); err != nil {
+			return err
+		}
+	}
+
+	for _, swIfIndex := range v.directionInterfaces(t.dir) {
+		if err := v.setInterfacePolicerTable(swIfIndex, t, tableIdx, true); err != nil {
+			return fmt.Errorf("attach policer table %d to interface %d: %w", tableIdx, swIfIndex, err)
+		}
+	}
+
+	fmt.Printf("VPP: %s policer table %d attached to %d %s interfaces\n",
+		t, tableIdx, len(v.directionInterfaces(t.dir)), t.dir.interfaceRole())
+	return nil
+}
+
+// policerTables6 returns the direction's IPv6 policer tables, longest
+// prefix first.
+func (v *VPPDataplane) policerTables6(d qerDirection) []policerTable {
+	var tables []policerTable
+	for t := range v.policerTables {
+		if t.dir == d && t.ip6PrefixLen != 0 {
+			tables = append(tables, t)
+		}
+	}
+	slices.SortFunc(tables, func(a, b policerTable) int { return b.ip6PrefixLen - a.ip6PrefixLen })
+	return tables
+}
+
+// chainPolicerTables6 links the direction's IPv6 policer tables so that a
+// miss in one goes on to the next, and returns the head, or ^0 if there are
+// none.
+func (v *VPPDataplane) chainPolicerTables6(d qerDirection) (uint32, error) {
+	tables := v.policerTables6(d)
+	for i, t := range tables {
+		next := ^uint32(0)
+		if i+1 < len(tables) {
+			next = v.policerTables[tables[i+1]]
+		}
+		if err := v.setClassifyTableNext(v.policerTables[t], next); err != nil {
+			return 0, fmt.Errorf("chain classify table %d to %d: %w", v.policerTables[t], next, err)
+		}
+	}
+
+	if len(tables) == 0 {
+		return ^uint32(0), nil
+	}
+	return v.policerTables[tables[0]], nil
+}
+
+func (v *VPPDataplane) teardownPolicerTable(t policerTable) {
+	tableIdx, ok := v.policerTables[t]
+	if !ok {
+		return
+	}
+
+	// An IPv6 table leaves the chain, and the interfaces are only detached
+	// if it was the last one.
+	var head uint32 = ^uint32(0)
+	if t.ip6PrefixLen != 0 {
+		delete(v.policerTables, t)
+		var err error
+		if head, err = v.chainPolicerTables6(t.dir); err != nil {
+			fmt.Printf("VPP: ERROR relinking %s IPv6 policer tables: %v\n", t.dir, err)
+		}
+	}
+
+	for _, swIfIndex := range v.directionInterfaces(t.dir) {
+		var err error
+		if head != ^uint32(0) {
+			err = v.setInterfacePolicerTable(swIfIndex, t, head, true)
+		} else {
+			err = v.setInterfacePolicerTable(swIfIndex, t, tableIdx, false)
+		}
+		if err != nil {
+			fmt.Printf("VPP: ERROR detaching policer table from interface %d: %v\n", swIfIndex, err)
+		}
+	}
+
+	delete(v.policerTables, t)
+
+	if err := v.deleteClassifyTable(tableIdx); err != nil {
+		fmt.Printf("VPP: ERROR deleting classify table %d: %v\n", tableIdx, err)
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
+
+func (v *VPPDataplane) setInterfacePolicerTable(swIfIndex interface_types.InterfaceIndex, t policerTable, tableIdx uint32, isAdd bool) error {
+	req := &classify.PolicerClassifySetInterface{
+		SwIfIndex:     swIfIndex,
+		IP4TableIndex: tableIdx,
+		IP6TableIndex: ^uint32(0),
+		L2TableIndex:  ^uint32(0),
+		IsAdd:         isAdd,
+	}
+	if t.ip6PrefixLen != 0 {
+		req.IP4TableIndex, req.IP6TableIndex = ^uint32(0), tableIdx
+	}
+
+	reply := &classify.PolicerClassifySetInterfaceReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d)", vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+// installPolicer creates the named policer or updates it in place, so that
+// classify sessions pointing at it stay valid across QER modifications.
+func (v *VPPDataplane) installPolicer(session *sessionState, name string, cfg policer_types.PolicerConfig) error {
+	index, exists := session.policers[name]
+	if !exists {
+		index, exists = v.inheritedPolicers[name]
+		delete(v.inheritedPolicers, name)
+	}
+
+	if exists {
+		reply := &policer.PolicerUpdateReply{}
+		if err := v.ch.SendRequest(&policer.PolicerUpdate{PolicerIndex: index, Infos: cfg}).ReceiveReply(reply); err != nil {
+			return err
+		}
+		if reply.Retval != 0 {
+			return fmt.Errorf("VPPApiError: %s (%d) updating policer %s", vppErrorString(reply.Retval), reply.Retval, name)
+		}
+	} else {
+		reply := &policer.PolicerAddReply{}
+		if err := v.ch.SendRequest(&policer.PolicerAdd{Name: name, Infos: cfg}).ReceiveReply(reply); err != nil {
+			return err
+		}
+		if reply.Retval != 0 {
+			return fmt.Errorf("VPPApiError: %s (%d) adding policer %s", vppErrorString(reply.Retval), reply.Retval, name)
+		}
+		index = reply.PolicerIndex
+	}
+
+	session.policers[name] = index
+	v.policers[name] = index
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	fmt.Printf("VPP: Policer %s (index %d) cir=%dkbps conform=%d\n", name, index, cfg.Cir, cfg.ConformAction.Type)
+	return nil
+}
+
+func (v *VPPDataplane) deletePolicer(name string, index uint32) {
+	reply := &policer.PolicerDelReply{}
+	if err := v.ch.SendRequest(&policer.PolicerDel{PolicerIndex: index}).ReceiveReply(reply); err != nil {
+		fmt.Printf("VPP: ERROR deleting policer %s: %v\n", name, err)
+	} else if reply.Retval != 0 {
+		fmt.Printf("VPP: ERROR deleting policer %s: %s (%d)\n", name, vppErrorString(reply.Retval), reply.Retval)
+	} else {
+		fmt.Printf("VPP: Policer %s deleted\n", name)
+	}
+
+	delete(v.policers, name)
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
+
+// applyQER brings the policers of a QER in line with its gate status and
+// MBR, then rebinds the PDRs that reference it. Policers that are no longer
+// needed are deleted only after no classify session points at them.
+func (v *VPPDataplane) applyQER(session *sessionState, qer *up.QER) error {
+	stale := make(map[string]uint32)
+
+	for _, d := range qerDirections {
+		gate, mbr := protocol.UplinkGate(qer.GateStatus), qer.MBR_UL
+		if d == qerDownlink {
+			gate, mbr = protocol.DownlinkGate(qer.GateStatus), qer.MBR_DL
+		}
+
+		name := policerName(session.SEID, qer.ID, d)
+		cfg, needed := policerConfig(gate, mbr)
+		if needed {
+			if err := v.installPolicer(session, name, cfg); err != nil {
+				return fmt.Errorf("install policer %s: %w", name, err)
+			}
+			continue
+		}
+
+		if index, ok := session.policers[name]; ok {
+			stale[name] = index
+			delete(session.policers, name)
+		}
+	}
+
+	var errs []error
+	for _, pdr := range session.pdrs {
+		if containsID(pdr.QER_IDs, qer.ID) {
+			if err := v.bindPDRPolicer(session, pdr); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for name, index := range stale {
+		v.deletePolicer(name, index)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("bind PDRs to QER %d: %v", qer.ID, errs)
+	}
+	return nil
+}
+
+// removeQERPolicers unbinds the QER's PDRs and deletes its policers.
+func (v *VPPDataplane) removeQERPolicers(session *sessionState, qerID uint32) {
+	for _, pdr := range session.pdrs {
+		if containsID(pdr.QER_IDs, qerID) {
+			if err := v.bindPDRPolicer(session, pdr); err != nil {
+				fmt.Printf("VPP: ERROR rebinding PDR %d: %v\n", pdr.ID, err)
+			}
+		}
+	}
+
+	for _, d := range qerDirections {
+		name := policerName(session.SEID, qerID, d)
+		if index, ok := session.policers[name]; ok {
+			delete(session.policers, name)
+			v.deletePolicer(name, index)
+		}
+	}
+}
+
+// bindPDRPolicer points the PDR's UE address at the policer of the first
+// of its QERs that has one in the PDR's direction, replacing any previous
+// binding.
+func (v *VPPDataplane) bindPDRPolicer(session *sessionState, pdr *up.PDR) error {
+	old := session.pdrPolicing[pdr.ID]
+
+	var entry *classifyEntry
+	if d, ok := pdrDirection(pdr); ok {
+		for _, qerID := range pdr.QER_IDs {
+			if _, installed := session.qers[qerID]; !installed {
+				continue
+			}
+
+			index, ok := session.policers[policerName(session.SEID, qerID, d)]
+			if !ok {
+				continue
+			}
+
+			table, ip, err := uePolicerTable(pdr, d)
+			if err != nil {
+				return fmt.Errorf("enforce QER %d: %w", qerID, err)
+			}
+
+			tableIdx, err := v.ensurePolicerTable(table)
+			if err != nil {
+				return err
+			}
+
+			// The opaque index carries the pre-color; 0 is conform.
+			entry = &classifyEntry{
+				TableIndex:   tableIdx,
+				Match:        ueAddressMatch(table, ip),
+				HitNextIndex: index,
+				OpaqueIndex:  0,
+			}
+			break
+		}
+	}
+
+	switch {
+	case entry == nil:
+		if old != nil {
+			delete(session.pdrPolicing, pdr.ID)
+			v.releaseClassifySession(old)
+		}
+		return nil
+	case old != nil && old.key() == entry.key():
+		if old.HitNextIndex == entry.HitNextIndex {
+			return nil
+		}
+		if v.classifyRefs[entry.key()] > 1 {
+			return fmt.Errorf("UE address of PDR %d is policed by another PDR with a different QER", pdr.ID)
+		}
+		// Same UE address, different policer: overwrite the session in
+		// place rather than removing it and letting traffic through.
+		if err := v.setClassifySession(entry, true); err != nil {
+			return fmt.Errorf("update policer classify session: %w", err)
+		}
+		v.classifySessions[entry.key()] = entry
+		session.pdrPolicing[pdr.ID] = entry
+		if err := v.saveState(); err != nil {
+			fmt.Printf("VPP: Failed to save state: %v\n", err)
+		}
+		return nil
+	}
+
+	if err := v.registerClassifySession(entry); err != nil {
+		return fmt.Errorf("add policer classify session: %w", err)
+	}
+	session.pdrPolicing[pdr.ID] = entry
+
+	if old != nil {
+		v.releaseClassifySession(old)
+	}
+
+	fmt.Printf("VPP: PDR %d bound to policer %d\n", pdr.ID, entry.HitNextIndex)
+	return nil
+}
+
+func (v *VPPDataplane) releasePDRPolicer(session *sessionState, pdrID uint16) {
+	if entry, ok := session.pdrPolicing[pdrID]; ok {
+		delete(session.pdrPolicing, pdrID)
+		v.releaseClassifySession(entry)
+	}
+}
+
+// tableInUse reports whether any live or inherited classify session belongs
+// to the table.
+func (v *VPPDataplane) tableInUse(tableIdx uint32) bool {
+	for _, entry := range v.classifySessions {
+		if entry.TableIndex == tableIdx {
+			return true
+		}
+	}
+	for _, entry := range v.inheritedClassify {
+		if entry.TableIndex == tableIdx {
+			return true
+		}
+	}
+	return false
+}
+
+// teardownUnusedTables deletes the L2 punt, Ethernet filter and policer
+// tables once no classify session uses them.
+func (v *VPPDataplane) teardownUnusedTables() {
+	v.teardownEthernetTables()
+
+	if v.l2PuntTable != ^uint32(0) && !v.tableInUse(v.l2PuntTable) {
+		v.teardownL2PuntTable()
+	}
+
+	for t, tableIdx := range v.policerTables {
+		if !v.tableInUse(tableIdx) {
+			v.teardownPolicerTable(t)
+		}
+	}
+}
+
+func containsID(ids []uint32, id uint32) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameClassifyAction(a, b *classifyEntry) bool {
+	return a.HitNextIndex == b.HitNextIndex && a.OpaqueIndex == b.OpaqueIndex && bytes.Equal(a.Match, b.Match)
+}
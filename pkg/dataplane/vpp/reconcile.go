@@ -0,0 +1,449 @@
+package vpp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.fd.io/govpp/binapi/classify"
+	"go.fd.io/govpp/binapi/ip_types"
+	"go.fd.io/govpp/binapi/policer"
+	"go.fd.io/govpp/binapi/punt"
+	"go.fd.io/govpp/binapi/vpe"
+)
+
+// A VPP restart resets everything we programmed, so state recorded against a
+// different boot time is discarded. The tolerance absorbs clock drift between
+// the two readings.
+const bootTimeTolerance = 5 * time.Second
+
+type puntRegistration struct {
+	Type     punt.PuntType          `json:"type"`
+	AF       ip_types.AddressFamily `json:"af"`
+	Protocol uint8                  `json:"protocol"`
+	Port     uint16                 `json:"port,omitempty"`
+	// Socket is the punt socket the packets are sent to, empty for a
+	// SetPunt registration.
+	Socket string `json:"socket,omitempty"`
+}
+
+func (r *puntRegistration) key() string {
+	if r.Socket != "" {
+		return fmt.Sprintf("%d/%d/%d/%d/%s", r.Type, r.AF, r.Protocol, r.Port, r.Socket)
+	}
+	return fmt.Sprintf("%d/%d/%d/%d", r.Type, r.AF, r.Protocol, r.Port)
+}
+
+type classifyEntry struct {
+	TableIndex   uint32 `json:"table_index"`
+	Match        []byte `json:"match"`
+	HitNextIndex uint32 `json:"hit_next_index"`
+	OpaqueIndex  uint32 `json:"opaque_index"`
+}
+
+func (e *classifyEntry) key() string {
+	return fmt.Sprintf("%d/%s", e.TableIndex, hex.EncodeToString(e.Match))
+}
+
+// dataplaneState is everything the backend has programmed into VPP, persisted
+// so that a restarted pfcp-up can find and clean up what it left behind.
+type dataplaneState struct {
+	BootTime         int64               `json:"boot_time"`
+	Punts            []*puntRegistration `json:"punts"`
+	ClassifySessions []*classifyEntry    `json:"classify_sessions"`
+	L2PuntTable      *uint32             `json:"l2_punt_table,omitempty"`
+	EthernetTables   map[string]uint32   `json:"ethernet_tables,omitempty"`
+	PolicerTables    map[string]uint32   `json:"policer_tables,omitempty"`
+	Policers         map[string]uint32   `json:"policers,omitempty"`
+	GTPUTunnels      []*gtpuTunnel       `json:"gtpu_tunnels,omitempty"`
+	PPPoESessions    []*pppoeSession     `json:"pppoe_sessions,omitempty"`
+}
+
+func (v *VPPDataplane) vppBootTime() (time.Time, error) {
+	reply := &vpe.ShowVpeSystemTimeReply{}
+	if err := v.ch.SendRequest(&vpe.ShowVpeSystemTime{}).ReceiveReply(reply); err != nil {
+		return time.Time{}, err
+	}
+
+	uptime := time.Duration(float64(reply.VpeSystemTime) * float64(time.Second))
+	return time.Now().Add(-uptime), nil
+}
+
+// loadInheritedState collects what a previous pfcp-up left in VPP. VPP has no
+// dump for SetPunt registrations, so punts come from the state file, which is
+// only trusted if VPP has not restarted since it was written; classify
+// sessions are checked against a dump of their tables. Entries stay inherited
+// until a restored session claims them or Reconcile removes them.
+func (v *VPPDataplane) loadInheritedState() error {
+	bootTime, err := v.vppBootTime()
+	if err != nil {
+		return fmt.Errorf("get VPP system time: %w", err)
+	}
+	v.bootTime = bootTime
+
+	if v.stateFile != "" {
+		if err := v.loadStateFile(); err != nil {
+			return err
+		}
+	}
+
+	if len(v.inheritedPunts) > 0 || len(v.inheritedClassify) > 0 {
+		fmt.Printf("VPP: Inherited %d punt registrations and %d classify sessions from a previous run\n",
+			len(v.inheritedPunts), len(v.inheritedClassify))
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) loadStateFile() error {
+	data, err := os.ReadFile(v.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read state file: %w", err)
+	}
+
+	var state dataplaneState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("parse state file: %w", err)
+	}
+
+	drift := time.Duration(math.Abs(float64(v.bootTime.Unix()-state.BootTime))) * time.Second
+	if drift > bootTimeTolerance {
+		fmt.Printf("VPP: Restarted since the state file was written, discarding it\n")
+		return nil
+	}
+
+	for _, reg := range state.Punts {
+		v.inheritedPunts[reg.key()] = reg
+	}
+
+	tables, err := v.classifyTableIDs()
+	if err != nil {
+		return fmt.Errorf("list classify tables: %w", err)
+	}
+
+	// Keep using the L2 punt and Ethernet filter tables so that inherited
+	// sessions can be adopted, and make sure they cover the configured
+	// access interfaces.
+	for key, tableIdx := range state.EthernetTables {
+		if tables[tableIdx] {
+			v.ethernetTables[key] = tableIdx
+		}
+	}
+	if state.L2PuntTable != nil && tables[*state.L2PuntTable] {
+		v.l2PuntTable = *state.L2PuntTable
+	}
+	if v.l2PuntTable != ^uint32(0) || len(v.ethernetTables) > 0 {
+		v.l2PuntNextIndex, err = v.addNodeNext(l2PuntClassifyNode, v.l2PuntNode)
+		if err != nil {
+			return fmt.Errorf("resolve next node %s: %w", v.l2PuntNode, err)
+		}
+		if err := v.attachL2PuntTable(); err != nil {
+			return err
+		}
+	}
+
+	for name, tableIdx := range state.PolicerTables {
+		t, ok := parsePolicerTable(name)
+		if !ok || !tables[tableIdx] {
+			continue
+		}
+		v.policerTables[t] = tableIdx
+	}
+	for t := range v.policerTables {
+		if err := v.attachPolicerTable(t); err != nil {
+			return err
+		}
+	}
+
+	if len(state.Policers) > 0 {
+		names, err := v.policerNames()
+		if err != nil {
+			return fmt.Errorf("list policers: %w", err)
+		}
+		for name, index := range state.Policers {
+			if names[name] {
+				v.inheritedPolicers[name] = index
+			}
+		}
+	}
+
+	if len(state.GTPUTunnels) > 0 {
+		tunnels, err := v.gtpuTunnelKeys()
+		if err != nil {
+			return fmt.Errorf("dump GTP-U tunnels: %w", err)
+		}
+		for _, t := range state.GTPUTunnels {
+			if swIfIndex, ok := tunnels[t.key()]; ok && swIfIndex == t.SwIfIndex {
+				v.inheritedTunnels[t.key()] = t
+			}
+		}
+	}
+
+	if len(state.PPPoESessions) > 0 {
+		sessions, err := v.pppoeSessionKeys()
+		if err != nil {
+			return fmt.Errorf("dump PPPoE sessions: %w", err)
+		}
+		for _, s := range state.PPPoESessions {
+			if swIfIndex, ok := sessions[s.key()]; ok && swIfIndex == s.SwIfIndex {
+				v.inheritedPPPoE[s.key()] = s
+			}
+		}
+	}
+
+	for _, entry := range state.ClassifySessions {
+		if !tables[entry.TableIndex] {
+			continue
+		}
+
+		exists, err := v.classifySessionExists(entry)
+		if err != nil {
+			return fmt.Errorf("dump classify table %d: %w", entry.TableIndex, err)
+		}
+		if exists {
+			v.inheritedClassify[entry.key()] = entry
+		}
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) classifyTableIDs() (map[uint32]bool, error) {
+	reply := &classify.ClassifyTableIdsReply{}
+	if err := v.ch.SendRequest(&classify.ClassifyTableIds{}).ReceiveReply(reply); err != nil {
+		return nil, err
+	}
+
+	tables := make(map[uint32]bool, len(reply.Ids))
+	for _, id := range reply.Ids {
+		tables[id] = true
+	}
+	return tables, nil
+}
+
+func (v *VPPDataplane) classifySessionExists(entry *classifyEntry) (bool, error) {
+	reqCtx := v.ch.SendMultiRequest(&classify.ClassifySessionDump{TableID: entry.TableIndex})
+	found := false
+	for {
+		details := &classify.ClassifySessionDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return false, err
+		}
+		if stop {
+			return found, nil
+		}
+		if bytes.HasPrefix(details.Match, entry.Match) || bytes.HasPrefix(entry.Match, details.Match) {
+			found = true
+		}
+	}
+}
+
+// adoptPunt claims an inherited punt registration for a restored session,
+// returning false if it still has to be programmed.
+func (v *VPPDataplane) adoptPunt(reg *puntRegistration) bool {
+	if _, ok := v.inheritedPunts[reg.key()]; !ok {
+		return false
+	}
+	delete(v.inheritedPunts, reg.key())
+	return true
+}
+
+// adoptClassifySession claims an inherited classify session. One with the
+// same match but a different action is dropped from the inherited set, since
+// adding the new session overwrites it in VPP.
+func (v *VPPDataplane) adoptClassifySession(entry *classifyEntry) bool {
+	inherited, ok := v.inheritedClassify[entry.key()]
+	if !ok {
+		return false
+	}
+	delete(v.inheritedClassify, entry.key())
+	return sameClassifyAction(inherited, entry)
+}
+
+func (v *VPPDataplane) policerNames() (map[string]bool, error) {
+	names := make(map[string]bool)
+
+	reqCtx := v.ch.SendMultiRequest(&policer.PolicerDump{})
+	for {
+		details := &policer.PolicerDetails{}
+		stop, err := reqCtx.ReceiveReply(details)
+		if err != nil {
+			return nil, err
+		}
+		if stop {
+			return names, nil
+		}
+		names[details.Name] = true
+	}
+}
+
+// Reconcile removes every inherited punt registration, classify session,
+// policer, counting ACL and GTP-U tunnel that no restored or re-established
+// session has claimed.
+func (v *VPPDataplane) Reconcile() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var errs []error
+
+	for key, reg := range v.inheritedPunts {
+		fmt.Printf("VPP: Removing stale punt registration %s\n", key)
+		if err := v.setPunt(reg, false); err != nil {
+			errs = append(errs, fmt.Errorf("remove punt %s: %w", key, err))
+			continue
+		}
+		delete(v.inheritedPunts, key)
+		v.puntGuardDirty = true
+	}
+
+	for key, entry := range v.inheritedClassify {
+		fmt.Printf("VPP: Removing stale classify session %s\n", key)
+		if err := v.setClassifySession(entry, false); err != nil {
+			errs = append(errs, fmt.Errorf("remove classify session %s: %w", key, err))
+			continue
+		}
+		delete(v.inheritedClassify, key)
+	}
+
+	// Policers go after the classify sessions that may point at them.
+	for name, index := range v.inheritedPolicers {
+		fmt.Printf("VPP: Removing stale policer %s\n", name)
+		delete(v.inheritedPolicers, name)
+		v.deletePolicer(name, index)
+	}
+
+	// A tunnel that fails to go is put back into inheritedTunnels, so
+	// iterate over a snapshot.
+	stale := make([]*gtpuTunnel, 0, len(v.inheritedTunnels))
+	for key, t := range v.inheritedTunnels {
+		stale = append(stale, t)
+		delete(v.inheritedTunnels, key)
+	}
+	for _, t := range stale {
+		fmt.Printf("VPP: Removing stale GTP-U tunnel %s\n", t.key())
+		v.deleteGTPUTunnel(t)
+	}
+
+	stalePPPoE := make([]*pppoeSession, 0, len(v.inheritedPPPoE))
+	for key, s := range v.inheritedPPPoE {
+		stalePPPoE = append(stalePPPoE, s)
+		delete(v.inheritedPPPoE, key)
+	}
+	for _, s := range stalePPPoE {
+		fmt.Printf("VPP: Removing stale PPPoE session %s\n", s.key())
+		v.deletePPPoESession(s)
+	}
+
+	v.teardownUnusedTables()
+
+	if err := v.syncPuntGuard(); err != nil {
+		errs = append(errs, fmt.Errorf("update punt guard: %w", err))
+	}
+
+	if err := v.removeStaleACLs(); err != nil {
+		errs = append(errs, fmt.Errorf("remove stale ACLs: %w", err))
+	}
+
+	if err := v.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("reconcile: %v", errs)
+	}
+
+	fmt.Printf("VPP: Reconciliation complete\n")
+	return nil
+}
+
+func (v *VPPDataplane) saveState() error {
+	if v.stateFile == "" {
+		return nil
+	}
+
+	state := &dataplaneState{BootTime: v.bootTime.Unix()}
+
+	if v.l2PuntTable != ^uint32(0) {
+		table := v.l2PuntTable
+		state.L2PuntTable = &table
+	}
+	if len(v.ethernetTables) > 0 {
+		state.EthernetTables = maps.Clone(v.ethernetTables)
+	}
+
+	for t, tableIdx := range v.policerTables {
+		if state.PolicerTables == nil {
+			state.PolicerTables = make(map[string]uint32)
+		}
+		state.PolicerTables[t.String()] = tableIdx
+	}
+
+	if len(v.policers)+len(v.inheritedPolicers) > 0 {
+		state.Policers = make(map[string]uint32)
+		for name, index := range v.policers {
+			state.Policers[name] = index
+		}
+		for name, index := range v.inheritedPolicers {
+			state.Policers[name] = index
+		}
+	}
+
+	// Inherited entries are still in VPP until Reconcile removes them, so
+	// they must survive another restart in the meantime.
+	for _, reg := range v.punts {
+		state.Punts = append(state.Punts, reg)
+	}
+	for _, reg := range v.inheritedPunts {
+		state.Punts = append(state.Punts, reg)
+	}
+	for _, entry := range v.classifySessions {
+		state.ClassifySessions = append(state.ClassifySessions, entry)
+	}
+	for _, entry := range v.inheritedClassify {
+		state.ClassifySessions = append(state.ClassifySessions, entry)
+	}
+
+	for _, session := range v.sessions {
+		if session.gtpu != nil {
+			state.GTPUTunnels = append(state.GTPUTunnels, session.gtpu)
+		}
+		if session.pppoe != nil {
+			state.PPPoESessions = append(state.PPPoESessions, session.pppoe)
+		}
+	}
+	for _, t := range v.mirrorTunnels {
+		state.GTPUTunnels = append(state.GTPUTunnels, t)
+	}
+	for _, t := range v.inheritedTunnels {
+		state.GTPUTunnels = append(state.GTPUTunnels, t)
+	}
+	for _, s := range v.inheritedPPPoE {
+		state.PPPoESessions = append(state.PPPoESessions, s)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(v.stateFile), 0o750); err != nil {
+		return fmt.Errorf("create state directory: %w", err)
+	}
+
+	tmpPath := v.stateFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o640); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+
+	return os.Rename(tmpPath, v.stateFile)
+}
@@ -0,0 +1,114 @@
+package vpp
+
+import (
+	"fmt"
+	"net"
+	"slices"
+
+	"go.fd.io/govpp/binapi/fib_types"
+	"go.fd.io/govpp/binapi/ip"
+	"go.fd.io/govpp/binapi/ip_types"
+)
+
+// Framed routes are the subnets a session's PDRs route behind the UE, e.g. a
+// business subscriber's LAN or a delegated IPv6 prefix. They are routed into
+// the session's GTP-U tunnel or PPPoE session, in the FIB table that holds
+// the route to the UE, and kept with it in the state file so that a restart
+// can adopt or remove them.
+
+// framedRoutes returns the framed route prefixes of the session's PDRs,
+// sorted and without duplicates.
+func framedRoutes(session *sessionState) []string {
+	var routes []string
+	for _, pdr := range session.pdrs {
+		if pdr.PDI == nil {
+			continue
+		}
+		for _, prefix := range pdr.PDI.FramedPrefixes() {
+			routes = append(routes, prefix.Masked().String())
+		}
+	}
+	slices.Sort(routes)
+	return slices.Compact(routes)
+}
+
+// setInterfaceRoute adds or removes a route to prefix through an interface
+// in FIB table vrf.
+func (v *VPPDataplane) setInterfaceRoute(prefix *net.IPNet, vrf, swIfIndex uint32, isAdd bool) error {
+	proto := fib_types.FIB_API_PATH_NH_PROTO_IP4
+	if prefix.IP.To4() == nil {
+		proto = fib_types.FIB_API_PATH_NH_PROTO_IP6
+	}
+
+	req := &ip.IPRouteAddDel{
+		IsAdd: isAdd,
+		Route: ip.IPRoute{
+			TableID: vrf,
+			Prefix:  ip_types.NewPrefix(*prefix),
+			NPaths:  1,
+			Paths: []fib_types.FibPath{{
+				SwIfIndex: swIfIndex,
+				Proto:     proto,
+			}},
+		},
+	}
+
+	reply := &ip.IPRouteAddDelReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return fmt.Errorf("route %s via interface %d: %w", prefix, swIfIndex, err)
+	}
+	if reply.Retval != 0 {
+		return fmt.Errorf("route %s via interface %d: VPPApiError: %s (%d)",
+			prefix, swIfIndex, vppErrorString(reply.Retval), reply.Retval)
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) setFramedRoute(route string, vrf, swIfIndex uint32, isAdd bool) error {
+	_, prefix, err := net.ParseCIDR(route)
+	if err != nil {
+		return fmt.Errorf("invalid framed route %q", route)
+	}
+	return v.setInterfaceRoute(prefix, vrf, swIfIndex, isAdd)
+}
+
+// updateFramedRoutes replaces the framed routes have through an interface
+// with want. It returns the routes that are in place afterwards, which on
+// error are those that could be added before it.
+func (v *VPPDataplane) updateFramedRoutes(have, want []string, vrf, swIfIndex uint32) ([]string, error) {
+	var kept []string
+	for _, route := range have {
+		if slices.Contains(want, route) {
+			kept = append(kept, route)
+			continue
+		}
+		if err := v.setFramedRoute(route, vrf, swIfIndex, false); err != nil {
+			fmt.Printf("VPP: ERROR removing framed route %s: %v\n", route, err)
+		}
+	}
+
+	for _, route := range want {
+		if slices.Contains(kept, route) {
+			continue
+		}
+		if err := v.setFramedRoute(route, vrf, swIfIndex, true); err != nil {
+			slices.Sort(kept)
+			return kept, fmt.Errorf("add framed route: %w", err)
+		}
+		kept = append(kept, route)
+	}
+
+	slices.Sort(kept)
+	return kept, nil
+}
+
+// removeFramedRoutes removes the framed routes through an interface before
+// it goes.
+func (v *VPPDataplane) removeFramedRoutes(routes []string, vrf, swIfIndex uint32) {
+	for _, route := range routes {
+		if err := v.setFramedRoute(route, vrf, swIfIndex, false); err != nil {
+			fmt.Printf("VPP: ERROR removing framed route %s: %v\n", route, err)
+		}
+	}
+}
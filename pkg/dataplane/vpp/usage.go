@@ -0,0 +1,94 @@
+package vpp
+
+import (
+	"fmt"
+
+	"go.fd.io/govpp/adapter"
+	"go.fd.io/govpp/adapter/statsclient"
+)
+
+// URR usage is read from the rule counters of the session ACLs, which VPP
+// keeps in the stats segment.
+const defaultStatsSocket = "/run/vpp/stats.sock"
+
+// ruleCount is the packets and bytes ACL rules matched.
+type ruleCount struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// add adds the counts of the rules in r.
+func (c *ruleCount) add(counts []ruleCount, r ruleRange) {
+	for i := r.First; i < r.First+r.Count && i < len(counts); i++ {
+		c.Packets += counts[i].Packets
+		c.Bytes += counts[i].Bytes
+	}
+}
+
+// PDRUsage returns the packets and bytes matched by the PDR's rules in its
+// session's ACL, including what they counted in the ACLs it replaced. PDRs
+// without ACL rules, such as L2 punt PDRs, are not counted and report 0.
+func (v *VPPDataplane) PDRUsage(seid uint64, pdrID uint16) (uint64, uint64, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	session, exists := v.sessions[seid]
+	if !exists {
+		return 0, 0, fmt.Errorf("session %d not found", seid)
+	}
+
+	usage := session.aclUsage[pdrID]
+	for _, a := range session.acls {
+		if a == nil {
+			continue
+		}
+		r, ok := a.PDRRules[pdrID]
+		if !ok {
+			continue
+		}
+		counts, err := v.aclCounters(a.ACLIndex)
+		if err != nil {
+			return 0, 0, err
+		}
+		usage.add(counts, r)
+	}
+
+	return usage.Packets, usage.Bytes, nil
+}
+
+// aclCounters returns the counts of each rule of the ACL, summed over the
+// worker threads.
+func (v *VPPDataplane) aclCounters(aclIndex uint32) ([]ruleCount, error) {
+	if v.stats == nil {
+		stats := statsclient.NewStatsClient(v.statsSocket)
+		if err := stats.Connect(); err != nil {
+			return nil, fmt.Errorf("connect to VPP stats: %w", err)
+		}
+		v.stats = stats
+	}
+
+	entries, err := v.stats.DumpStats(fmt.Sprintf("^/acl/%d/matches$", aclIndex))
+	if err != nil {
+		return nil, fmt.Errorf("read ACL %d counters: %w", aclIndex, err)
+	}
+
+	var counts []ruleCount
+	for _, entry := range entries {
+		stat, ok := entry.Data.(adapter.CombinedCounterStat)
+		if !ok {
+			continue
+		}
+		// One vector per worker thread, indexed by rule.
+		for _, thread := range stat {
+			for i, rule := range thread {
+				if i >= len(counts) {
+					counts = append(counts, make([]ruleCount, i+1-len(counts))...)
+				}
+				counts[i].Packets += rule.Packets()
+				counts[i].Bytes += rule.Bytes()
+			}
+		}
+	}
+
+	return counts, nil
+}
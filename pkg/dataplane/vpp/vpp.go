@@ -3,31 +3,138 @@ package vpp
 import (
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
 	"github.com/veesix-networks/pfcp-go/pkg/up"
 	"go.fd.io/govpp/adapter/socketclient"
+	"go.fd.io/govpp/adapter/statsclient"
 	"go.fd.io/govpp/api"
 	"go.fd.io/govpp/binapi/classify"
+	"go.fd.io/govpp/binapi/interface_types"
 	"go.fd.io/govpp/binapi/ip_types"
 	"go.fd.io/govpp/binapi/punt"
 	"go.fd.io/govpp/core"
 )
 
 type VPPDataplane struct {
-	conn     *core.Connection
-	ch       api.Channel
-	sessions map[uint64]*sessionState
-	mu       sync.RWMutex
+	conn              *core.Connection
+	ch                api.Channel
+	sessions          map[uint64]*sessionState
+	stateFile         string
+	bootTime          time.Time
+	punts             map[string]*puntRegistration
+	puntRefs          map[string]int
+	classifySessions  map[string]*classifyEntry
+	classifyRefs      map[string]int
+	inheritedPunts    map[string]*puntRegistration
+	inheritedClassify map[string]*classifyEntry
+	accessInterfaces  []interface_types.InterfaceIndex
+	coreInterfaces    []interface_types.InterfaceIndex
+	l2PuntNode        string
+	l2PuntTap         *l2PuntTap
+	l2PuntTable       uint32
+	l2PuntNextIndex   uint32
+	ethernetTables    map[string]uint32
+	policerTables     map[policerTable]uint32
+	policers          map[string]uint32
+	inheritedPolicers map[string]uint32
+	inheritedTunnels  map[string]*gtpuTunnel
+	inheritedPPPoE    map[string]*pppoeSession
+	networkInstances  map[string]uint32
+	mirrorTunnels     map[string]*gtpuTunnel
+	mirrorRefs        map[string]int
+	permitACL         uint32
+	puntGuardACL      uint32
+	aclIndexes        map[uint32]bool
+	puntGuardDirty    bool
+	statsSocket       string
+	stats             *statsclient.StatsClient
+	puntSocket        *puntSocket
+	mu                sync.RWMutex
+}
+
+type Config struct {
+	SocketPath string
+	StateFile  string
+	// AccessInterfaces are the VPP interfaces, in L2 mode, on which L2
+	// punt PDRs divert matching frames.
+	AccessInterfaces []string
+	// L2PuntNode is the graph node that receives diverted frames.
+	// Defaults to error-punt.
+	L2PuntNode string
+	// L2PuntTap, if set, is a VPP tap interface diverted frames are sent
+	// out of instead of L2PuntNode, so that the UP can read them on its
+	// host side. See L2PuntCapture.
+	L2PuntTap string
+	// CoreInterfaces are the VPP interfaces on which downlink QERs are
+	// enforced. Uplink QERs are enforced on the AccessInterfaces.
+	CoreInterfaces []string
+	// StatsSocketPath is the VPP stats socket URR usage counters are read
+	// from. Defaults to /run/vpp/stats.sock.
+	StatsSocketPath string
+	// PuntSocketPath, if set, is where the socket VPP sends L4 and IP
+	// protocol punts to is created. PuntSource then delivers them.
+	PuntSocketPath string
+	// PPPoEControlInterface, if set, is the VPP interface the pppoe plugin
+	// hands PPPoE discovery and PPP control frames to.
+	PPPoEControlInterface string
+	// NetworkInstances maps the Network Instances PDRs and FARs may name to
+	// VPP FIB table IDs. The tables are created if they do not exist. Any
+	// other name is rejected; without any, only the default table is used.
+	NetworkInstances map[string]uint32
 }
 
 type sessionState struct {
-	SEID    uint64
-	pdrs    map[uint16]*up.PDR
-	fars    map[uint32]*up.FAR
-	puntReg map[string]bool
+	SEID uint64
+	pdrs map[uint16]*up.PDR
+	fars map[uint32]*up.FAR
+	// pdrPunts holds the punt registrations each PDR references. A PDR is
+	// present once its punt is configured, even if it needed no SetPunt.
+	pdrPunts map[uint16][]*puntRegistration
+	// pdrClassify holds the classify sessions of each L2 punt PDR.
+	pdrClassify map[uint16][]*classifyEntry
+	qers        map[uint32]*up.QER
+	// policers maps the policer names of this session's QERs to their
+	// VPP indexes, and pdrPolicing the classify session binding each PDR
+	// to one of them.
+	policers    map[string]uint32
+	pdrPolicing map[uint16]*classifyEntry
+	// acls holds the session's ACL of each direction, matching its PDRs
+	// with an SDF filter or URRs, and aclInterfaces the interfaces of its
+	// own they were last bound to.
+	acls          [2]*sessionACL
+	aclInterfaces []uint32
+	// aclUsage holds the usage each PDR's rules counted in ACLs since
+	// replaced or deleted.
+	aclUsage map[uint16]ruleCount
+	// gtpu is the session's GTP-U tunnel, once it has both a local F-TEID
+	// and a peer to encapsulate towards.
+	gtpu *gtpuTunnel
+	// pppoe is the session's PPPoE session, once it has a FAR encapsulating
+	// into one and a UE IP address.
+	pppoe *pppoeSession
+	// mirrors holds the SPAN sessions of the duplicating FARs by target.
+	mirrors map[string]*mirror
+}
+
+func newSessionState(seid uint64) *sessionState {
+	return &sessionState{
+		SEID:        seid,
+		pdrs:        make(map[uint16]*up.PDR),
+		fars:        make(map[uint32]*up.FAR),
+		pdrPunts:    make(map[uint16][]*puntRegistration),
+		pdrClassify: make(map[uint16][]*classifyEntry),
+		qers:        make(map[uint32]*up.QER),
+		policers:    make(map[string]uint32),
+		pdrPolicing: make(map[uint16]*classifyEntry),
+		aclUsage:    make(map[uint16]ruleCount),
+	}
 }
 
-func NewVPPDataplane(socketPath string) (*VPPDataplane, error) {
+func NewVPPDataplane(cfg *Config) (*VPPDataplane, error) {
+	socketPath := cfg.SocketPath
 	if socketPath == "" {
 		socketPath = "/run/vpp/api.sock"
 	}
@@ -43,16 +150,106 @@ func NewVPPDataplane(socketPath string) (*VPPDataplane, error) {
 		return nil, fmt.Errorf("create API channel: %w", err)
 	}
 
+	statsSocket := cfg.StatsSocketPath
+	if statsSocket == "" {
+		statsSocket = defaultStatsSocket
+	}
+
+	l2PuntNode := cfg.L2PuntNode
+	if l2PuntNode == "" {
+		l2PuntNode = defaultL2PuntNode
+	}
+
 	vpp := &VPPDataplane{
-		conn:     conn,
-		ch:       ch,
-		sessions: make(map[uint64]*sessionState),
+		conn:              conn,
+		ch:                ch,
+		sessions:          make(map[uint64]*sessionState),
+		stateFile:         cfg.StateFile,
+		punts:             make(map[string]*puntRegistration),
+		puntRefs:          make(map[string]int),
+		classifySessions:  make(map[string]*classifyEntry),
+		classifyRefs:      make(map[string]int),
+		inheritedPunts:    make(map[string]*puntRegistration),
+		inheritedClassify: make(map[string]*classifyEntry),
+		l2PuntNode:        l2PuntNode,
+		l2PuntTable:       ^uint32(0),
+		ethernetTables:    make(map[string]uint32),
+		policerTables:     make(map[policerTable]uint32),
+		policers:          make(map[string]uint32),
+		inheritedPolicers: make(map[string]uint32),
+		inheritedTunnels:  make(map[string]*gtpuTunnel),
+		inheritedPPPoE:    make(map[string]*pppoeSession),
+		networkInstances:  cfg.NetworkInstances,
+		mirrorTunnels:     make(map[string]*gtpuTunnel),
+		mirrorRefs:        make(map[string]int),
+		permitACL:         ^uint32(0),
+		puntGuardACL:      ^uint32(0),
+		aclIndexes:        make(map[uint32]bool),
+		statsSocket:       statsSocket,
+	}
+
+	if len(cfg.AccessInterfaces) > 0 {
+		vpp.accessInterfaces, err = vpp.resolveInterfaces(cfg.AccessInterfaces)
+		if err != nil {
+			vpp.Close()
+			return nil, fmt.Errorf("resolve access interfaces: %w", err)
+		}
+	}
+
+	if len(cfg.CoreInterfaces) > 0 {
+		vpp.coreInterfaces, err = vpp.resolveInterfaces(cfg.CoreInterfaces)
+		if err != nil {
+			vpp.Close()
+			return nil, fmt.Errorf("resolve core interfaces: %w", err)
+		}
+	}
+
+	if err := vpp.addFIBTables(); err != nil {
+		vpp.Close()
+		return nil, fmt.Errorf("add network instance tables: %w", err)
+	}
+
+	if cfg.PPPoEControlInterface != "" {
+		cp, err := vpp.resolveInterfaces([]string{cfg.PPPoEControlInterface})
+		if err == nil {
+			err = vpp.setPPPoEControlInterface(cp[0])
+		}
+		if err != nil {
+			vpp.Close()
+			return nil, fmt.Errorf("set PPPoE control interface: %w", err)
+		}
+	}
+
+	if cfg.L2PuntTap != "" {
+		if err := vpp.setL2PuntTap(cfg.L2PuntTap, cfg.AccessInterfaces); err != nil {
+			vpp.Close()
+			return nil, fmt.Errorf("set L2 punt tap: %w", err)
+		}
+	}
+
+	if cfg.PuntSocketPath != "" {
+		vpp.puntSocket, err = vpp.openPuntSocket(cfg.PuntSocketPath)
+		if err != nil {
+			vpp.Close()
+			return nil, fmt.Errorf("open punt socket: %w", err)
+		}
+	}
+
+	if err := vpp.loadInheritedState(); err != nil {
+		vpp.Close()
+		return nil, fmt.Errorf("load inherited state: %w", err)
 	}
 
 	return vpp, nil
 }
 
 func (v *VPPDataplane) Close() error {
+	if v.puntSocket != nil {
+		v.puntSocket.Close()
+	}
+	if v.stats != nil {
+		v.stats.Disconnect()
+	}
 	if v.ch != nil {
 		v.ch.Close()
 	}
@@ -66,20 +263,66 @@ func (v *VPPDataplane) InstallPDR(seid uint64, pdr *up.PDR) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 
-	if _, exists := v.sessions[seid]; !exists {
-		v.sessions[seid] = &sessionState{
-			SEID:    seid,
-			pdrs:    make(map[uint16]*up.PDR),
-			fars:    make(map[uint32]*up.FAR),
-			puntReg: make(map[string]bool),
-		}
+	session, exists := v.sessions[seid]
+	if !exists {
+		session = newSessionState(seid)
+		v.sessions[seid] = session
 	}
 
 	fmt.Printf("VPP: Installing PDR %d for session %d (precedence: %d, FAR_ID: %d)\n", pdr.ID, seid, pdr.Precedence, pdr.FAR_ID)
 
-	v.sessions[seid].pdrs[pdr.ID] = pdr
+	// Ethernet packet filters and PPPoE sessions are matched on the access
+	// interfaces' L2 input, ahead of any IP or GTP-U classification.
+	if pdi := pdr.PDI; pdi != nil && pdi.MatchesEthernet() &&
+		(pdi.UE_IPAddress != "" || len(pdi.FramedRoutes) > 0 || len(pdi.SDFFilter) > 0 || pdi.ApplicationID != "" || pdi.LocalFTEID != nil) {
+		return fmt.Errorf("PDR %d: Ethernet packet filters and PPPoE sessions cannot be combined with a UE IP address, framed route, SDF filter, Application ID or local F-TEID", pdr.ID)
+	}
+
+	if err := v.checkBBFOuterHeaderRemoval(pdr); err != nil {
+		return err
+	}
 
-	return nil
+	// An update may change the filter or the FAR, so drop the old punt
+	// before configuring the new one.
+	if _, exists := session.pdrs[pdr.ID]; exists {
+		v.releasePDRPunts(session, pdr.ID)
+	}
+
+	session.pdrs[pdr.ID] = pdr
+
+	// The ACLs go in first so the punt never sees traffic the SDF filter
+	// does not match.
+	if err := v.syncSessionACLs(session); err != nil {
+		return fmt.Errorf("update ACLs: %w", err)
+	}
+
+	if far, ok := session.fars[pdr.FAR_ID]; ok && far.ApplyAction&0x02 != 0 {
+		if err := v.configurePuntForPDR(seid, pdr); err != nil {
+			return fmt.Errorf("configure punt: %w", err)
+		}
+	}
+
+	if err := v.syncPuntGuard(); err != nil {
+		return fmt.Errorf("update punt guard: %w", err)
+	}
+
+	if err := v.bindPDRPolicer(session, pdr); err != nil {
+		return fmt.Errorf("bind policer: %w", err)
+	}
+
+	if err := v.syncGTPUTunnel(session); err != nil {
+		return err
+	}
+
+	if err := v.syncPPPoESession(session); err != nil {
+		return err
+	}
+
+	if err := v.bindSessionACLs(session, false); err != nil {
+		return err
+	}
+
+	return v.syncMirrors(session)
 }
 
 func (v *VPPDataplane) RemovePDR(seid uint64, pdrID uint16) error {
@@ -98,9 +341,31 @@ func (v *VPPDataplane) RemovePDR(seid uint64, pdrID uint16) error {
 
 	fmt.Printf("VPP: Removing PDR %d from session %d\n", pdrID, seid)
 
+	v.releasePDRPunts(session, pdrID)
+	v.releasePDRPolicer(session, pdrID)
 	delete(session.pdrs, pdrID)
 
-	return nil
+	if err := v.syncSessionACLs(session); err != nil {
+		return fmt.Errorf("update ACLs: %w", err)
+	}
+
+	if err := v.syncGTPUTunnel(session); err != nil {
+		return err
+	}
+
+	if err := v.syncPPPoESession(session); err != nil {
+		return err
+	}
+
+	if err := v.bindSessionACLs(session, false); err != nil {
+		return err
+	}
+
+	if err := v.syncMirrors(session); err != nil {
+		return err
+	}
+
+	return v.syncPuntGuard()
 }
 
 func (v *VPPDataplane) InstallFAR(seid uint64, far *up.FAR) error {
@@ -109,13 +374,28 @@ func (v *VPPDataplane) InstallFAR(seid uint64, far *up.FAR) error {
 
 	session, exists := v.sessions[seid]
 	if !exists {
-		return fmt.Errorf("session %d not found", seid)
+		session = newSessionState(seid)
+		v.sessions[seid] = session
 	}
 
 	fmt.Printf("VPP: Installing FAR %d for session %d (action: 0x%02x)\n", far.ID, seid, far.ApplyAction)
 
+	if fp := far.ForwardingParameters; fp != nil && fp.L2TP != nil {
+		if err := v.checkUPForwarding(); err != nil {
+			return fmt.Errorf("FAR %d: L2TP sessions are %w", far.ID, err)
+		}
+	}
+
+	if old, ok := session.fars[far.ID]; ok && old.ApplyAction&0x02 != 0 && far.ApplyAction&0x02 == 0 {
+		v.deregisterPunt(session, far.ID)
+	}
+
 	session.fars[far.ID] = far
 
+	if err := v.syncSessionACLs(session); err != nil {
+		return fmt.Errorf("update ACLs: %w", err)
+	}
+
 	if far.ApplyAction&0x02 != 0 {
 		for _, pdr := range session.pdrs {
 			if pdr.FAR_ID == far.ID {
@@ -127,7 +407,23 @@ func (v *VPPDataplane) InstallFAR(seid uint64, far *up.FAR) error {
 		}
 	}
 
-	return nil
+	if err := v.syncGTPUTunnel(session); err != nil {
+		return err
+	}
+
+	if err := v.syncPPPoESession(session); err != nil {
+		return err
+	}
+
+	if err := v.bindSessionACLs(session, false); err != nil {
+		return err
+	}
+
+	if err := v.syncMirrors(session); err != nil {
+		return err
+	}
+
+	return v.syncPuntGuard()
 }
 
 func (v *VPPDataplane) RemoveFAR(seid uint64, farID uint32) error {
@@ -141,28 +437,76 @@ func (v *VPPDataplane) RemoveFAR(seid uint64, farID uint32) error {
 
 	fmt.Printf("VPP: Removing FAR %d from session %d\n", farID, seid)
 
+	v.deregisterPunt(session, farID)
 	delete(session.fars, farID)
 
-	return nil
+	if err := v.syncSessionACLs(session); err != nil {
+		return fmt.Errorf("update ACLs: %w", err)
+	}
+
+	if err := v.syncGTPUTunnel(session); err != nil {
+		return err
+	}
+
+	if err := v.syncPPPoESession(session); err != nil {
+		return err
+	}
+
+	if err := v.bindSessionACLs(session, false); err != nil {
+		return err
+	}
+
+	if err := v.syncMirrors(session); err != nil {
+		return err
+	}
+
+	return v.syncPuntGuard()
 }
 
 func (v *VPPDataplane) InstallQER(seid uint64, qer *up.QER) error {
-	fmt.Printf("VPP: Installing QER %d for session %d (not implemented)\n", qer.ID, seid)
-	return nil
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	session, exists := v.sessions[seid]
+	if !exists {
+		session = newSessionState(seid)
+		v.sessions[seid] = session
+	}
+
+	fmt.Printf("VPP: Installing QER %d for session %d (gate: 0x%02x, MBR UL/DL: %d/%d kbps)\n",
+		qer.ID, seid, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
+
+	session.qers[qer.ID] = qer
+
+	return v.applyQER(session, qer)
 }
 
 func (v *VPPDataplane) RemoveQER(seid uint64, qerID uint32) error {
-	fmt.Printf("VPP: Removing QER %d from session %d (not implemented)\n", qerID, seid)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	session, exists := v.sessions[seid]
+	if !exists {
+		return fmt.Errorf("session %d not found", seid)
+	}
+
+	fmt.Printf("VPP: Removing QER %d from session %d\n", qerID, seid)
+
+	delete(session.qers, qerID)
+	v.removeQERPolicers(session, qerID)
+
 	return nil
 }
 
+// InstallURR needs no VPP state of its own: usage is counted per PDR, for
+// every PDR that references a URR, and the UP evaluates the thresholds.
 func (v *VPPDataplane) InstallURR(seid uint64, urr *up.URR) error {
-	fmt.Printf("VPP: Installing URR %d for session %d (not implemented)\n", urr.ID, seid)
+	fmt.Printf("VPP: Installing URR %d for session %d (triggers: 0x%04x)\n", urr.ID, seid, urr.ReportingTriggers)
 	return nil
 }
 
 func (v *VPPDataplane) RemoveURR(seid uint64, urrID uint32) error {
-	fmt.Printf("VPP: Removing URR %d from session %d (not implemented)\n", urrID, seid)
+	fmt.Printf("VPP: Removing URR %d from session %d\n", urrID, seid)
 	return nil
 }
 
@@ -179,30 +523,45 @@ func (v *VPPDataplane) DeleteSession(seid uint64) error {
 
 	for pdrID := range session.pdrs {
 		fmt.Printf("VPP: Cleaning up PDR %d\n", pdrID)
+		v.releasePDRPunts(session, pdrID)
+		v.releasePDRPolicer(session, pdrID)
+	}
+
+	v.releaseSessionACLs(session)
+
+	for name, index := range session.policers {
+		v.deletePolicer(name, index)
 	}
 
 	for farID := range session.fars {
 		fmt.Printf("VPP: Cleaning up FAR %d\n", farID)
 	}
 
-	delete(v.sessions, seid)
+	for _, m := range session.mirrors {
+		v.removeMirror(m)
+	}
 
-	return nil
-}
+	if session.gtpu != nil {
+		v.deleteGTPUTunnel(session.gtpu)
+	}
 
-func (v *VPPDataplane) createClassifyTable() (uint32, error) {
-	mask := make([]byte, 48)
-	for i := range mask {
-		mask[i] = 0xff
+	if session.pppoe != nil {
+		v.deletePPPoESession(session.pppoe)
 	}
 
+	delete(v.sessions, seid)
+
+	return v.syncPuntGuard()
+}
+
+func (v *VPPDataplane) createClassifyTable(skipVectors uint32, mask []byte) (uint32, error) {
 	req := &classify.ClassifyAddDelTable{
 		IsAdd:             true,
 		TableIndex:        ^uint32(0),
 		Nbuckets:          2,
 		MemorySize:        2 << 20,
-		SkipNVectors:      0,
-		MatchNVectors:     3,
+		SkipNVectors:      skipVectors,
+		MatchNVectors:     uint32(len(mask) / classifyVectorSize),
 		NextTableIndex:    ^uint32(0),
 		MissNextIndex:     ^uint32(0),
 		MaskLen:           uint32(len(mask)),
@@ -275,12 +634,21 @@ func (v *VPPDataplane) deleteClassifyTable(tableIdx uint32) error {
 func (v *VPPDataplane) configurePuntForPDR(seid uint64, pdr *up.PDR) error {
 	session := v.sessions[seid]
 
-	puntKey := fmt.Sprintf("pdr-%d", pdr.ID)
-	if session.puntReg[puntKey] {
+	if _, ok := session.pdrPunts[pdr.ID]; ok {
 		fmt.Printf("VPP: Punt already configured for PDR %d\n", pdr.ID)
 		return nil
 	}
 
+	// GTP-U and PPPoE traffic is forwarded through the session's tunnel,
+	// unless the UP forwards it.
+	if !isUPForwardedPDR(session, pdr) && (isGTPUPDR(session, pdr) || isPPPoEPDR(session, pdr)) {
+		return nil
+	}
+
+	if pdr.PDI.MatchesEthernet() {
+		return v.configureEthernetPuntForPDR(seid, pdr)
+	}
+
 	// Check for Application ID first (L2 filters)
 	if pdr.PDI.ApplicationID != "" {
 		return v.configureL2PuntForPDR(seid, pdr)
@@ -291,89 +659,244 @@ func (v *VPPDataplane) configurePuntForPDR(seid uint64, pdr *up.PDR) error {
 		return fmt.Errorf("PDR %d has no SDF filter or Application ID", pdr.ID)
 	}
 
-	sdfFilter, err := parseSDFFilter(pdr.PDI.SDFFilter)
+	sdfFilter, err := protocol.ParseSDFFilter(pdr.PDI.SDFFilter)
 	if err != nil {
 		return fmt.Errorf("parse SDF filter: %w", err)
 	}
+	fd := sdfFilter.FlowDescription
+	if fd == nil {
+		return fmt.Errorf("PDR %d SDF filter has no flow description", pdr.ID)
+	}
+	// The punted packets' destination ports are those of the filter as it
+	// applies to the PDR's direction.
+	if d, ok := pdrDirection(pdr); ok {
+		fd = fd.Oriented(d.filterDirection())
+	}
 
 	af := ip_types.ADDRESS_IP4
-	if sdfFilter.AddressFamily == 1 {
+	if sdfIsV6(fd, ueNet(pdr.PDI.UE_IPAddress, pdr.PDI.UE_IPPrefixLength)) {
 		af = ip_types.ADDRESS_IP6
 	}
 
-	isL4Protocol := sdfFilter.Protocol == 6 || sdfFilter.Protocol == 17 || sdfFilter.Protocol == 132
-	hasPorts := sdfFilter.PortStart > 0 || sdfFilter.PortEnd > 0
+	isL4Protocol := fd.Protocol == ipfilter.ProtocolTCP || fd.Protocol == ipfilter.ProtocolUDP || fd.Protocol == ipfilter.ProtocolSCTP
+	hasPorts := len(fd.Dst.Ports) > 0
 
-	if isL4Protocol && hasPorts {
-		fmt.Printf("VPP: Configuring L4 punt for PDR %d (flow: %s, protocol=%d, ports=%d-%d, af=%d)\n",
-			pdr.ID, sdfFilter.FlowDescription, sdfFilter.Protocol, sdfFilter.PortStart, sdfFilter.PortEnd, sdfFilter.AddressFamily)
-
-		for port := sdfFilter.PortStart; port <= sdfFilter.PortEnd; port++ {
-			req := &punt.SetPunt{
-				IsAdd: true,
-				Punt: punt.Punt{
-					Type: punt.PUNT_API_TYPE_L4,
-					Punt: punt.PuntUnionL4(punt.PuntL4{
-						Af:       af,
-						Protocol: ip_types.IPProto(sdfFilter.Protocol),
-						Port:     port,
-					}),
-				},
-			}
+	var regs []*puntRegistration
 
-			reply := &punt.SetPuntReply{}
-			if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
-				return fmt.Errorf("set L4 punt port %d: %w", port, err)
-			}
+	if isL4Protocol && hasPorts {
+		fmt.Printf("VPP: Configuring L4 punt for PDR %d (flow: %s, protocol=%d, ports=%v, af=%d)\n",
+			pdr.ID, fd, fd.Protocol, fd.Dst.Ports, af)
+
+		for _, ports := range fd.Dst.Ports {
+			for port := uint32(ports.First); port <= uint32(ports.Last); port++ {
+				reg := &puntRegistration{
+					Type:     punt.PUNT_API_TYPE_L4,
+					AF:       af,
+					Protocol: fd.Protocol,
+					Port:     uint16(port),
+				}
 
-			if reply.Retval != 0 {
-				return fmt.Errorf("VPPApiError: %s (%d) for port %d", vppErrorString(reply.Retval), reply.Retval, port)
+				if err := v.registerPunt(reg); err != nil {
+					v.releasePunts(regs)
+					return fmt.Errorf("set L4 punt port %d: %w", port, err)
+				}
+				regs = append(regs, reg)
 			}
-
-			fmt.Printf("VPP: L4 punt registered for protocol=%d port=%d\n", sdfFilter.Protocol, port)
 		}
 	} else {
 		fmt.Printf("VPP: Configuring IP proto punt for PDR %d (flow: %s, protocol=%d, af=%d)\n",
-			pdr.ID, sdfFilter.FlowDescription, sdfFilter.Protocol, sdfFilter.AddressFamily)
-
-		req := &punt.SetPunt{
-			IsAdd: true,
-			Punt: punt.Punt{
-				Type: punt.PUNT_API_TYPE_IP_PROTO,
-				Punt: punt.PuntUnionIPProto(punt.PuntIPProto{
-					Af:       af,
-					Protocol: ip_types.IPProto(sdfFilter.Protocol),
-				}),
-			},
+			pdr.ID, fd, fd.Protocol, af)
+
+		reg := &puntRegistration{
+			Type:     punt.PUNT_API_TYPE_IP_PROTO,
+			AF:       af,
+			Protocol: fd.Protocol,
 		}
 
-		reply := &punt.SetPuntReply{}
-		if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		if err := v.registerPunt(reg); err != nil {
 			return fmt.Errorf("set IP proto punt: %w", err)
 		}
+		regs = append(regs, reg)
+	}
+
+	// A port range can take many registrations, so the state is saved
+	// once for all of them.
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+
+	session.pdrPunts[pdr.ID] = regs
+	fmt.Printf("VPP: Punt configured for PDR %d (%d registrations)\n", pdr.ID, len(regs))
+
+	return nil
+}
+
+// registerPunt takes a reference on a punt registration, programming it on
+// first use or claiming the identical one a previous run left behind. Several
+// PDRs, possibly in different sessions, can share one registration. The
+// caller saves the state.
+func (v *VPPDataplane) registerPunt(reg *puntRegistration) error {
+	if v.puntSocket != nil {
+		reg.Socket = v.puntSocket.path
+	}
+
+	key := reg.key()
+	if _, ok := v.punts[key]; ok {
+		v.puntRefs[key]++
+		return nil
+	}
 
-		if reply.Retval != 0 {
-			return fmt.Errorf("VPPApiError: %s (%d) for proto %d", vppErrorString(reply.Retval), reply.Retval, sdfFilter.Protocol)
+	switch {
+	case !v.adoptPunt(reg):
+		if err := v.setPunt(reg, true); err != nil {
+			return err
+		}
+	case reg.Socket != "":
+		// Renew an adopted punt socket registration, since the reply names
+		// VPP's socket for injection.
+		if err := v.setPunt(reg, true); err != nil {
+			fmt.Printf("VPP: Failed to renew punt %s: %v\n", key, err)
 		}
+	}
+
+	v.punts[key] = reg
+	v.puntRefs[key] = 1
+	v.puntGuardDirty = true
+
+	return nil
+}
+
+func (v *VPPDataplane) setPunt(reg *puntRegistration, isAdd bool) error {
+	req := &punt.SetPunt{
+		IsAdd: isAdd,
+		Punt: punt.Punt{
+			Type: reg.Type,
+		},
+	}
+
+	switch reg.Type {
+	case punt.PUNT_API_TYPE_L4:
+		req.Punt.Punt = punt.PuntUnionL4(punt.PuntL4{
+			Af:       reg.AF,
+			Protocol: ip_types.IPProto(reg.Protocol),
+			Port:     reg.Port,
+		})
+	case punt.PUNT_API_TYPE_IP_PROTO:
+		req.Punt.Punt = punt.PuntUnionIPProto(punt.PuntIPProto{
+			Af:       reg.AF,
+			Protocol: ip_types.IPProto(reg.Protocol),
+		})
+	default:
+		return fmt.Errorf("unsupported punt type %d", reg.Type)
+	}
+
+	if reg.Socket != "" {
+		return v.setPuntSocket(reg, req.Punt, isAdd)
+	}
+
+	reply := &punt.SetPuntReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
+	}
+
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d) for %s", vppErrorString(reply.Retval), reply.Retval, reg.key())
+	}
+
+	return nil
+}
+
+func (v *VPPDataplane) setClassifySession(entry *classifyEntry, isAdd bool) error {
+	req := &classify.ClassifyAddDelSession{
+		IsAdd:        isAdd,
+		TableIndex:   entry.TableIndex,
+		HitNextIndex: entry.HitNextIndex,
+		OpaqueIndex:  entry.OpaqueIndex,
+		MatchLen:     uint32(len(entry.Match)),
+		Match:        entry.Match,
+	}
 
-		fmt.Printf("VPP: IP proto punt registered for protocol=%d\n", sdfFilter.Protocol)
+	reply := &classify.ClassifyAddDelSessionReply{}
+	if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+		return err
 	}
 
-	session.puntReg[puntKey] = true
-	fmt.Printf("VPP: Punt configured for PDR %d\n", pdr.ID)
+	if reply.Retval != 0 {
+		return fmt.Errorf("VPPApiError: %s (%d) for classify session %s", vppErrorString(reply.Retval), reply.Retval, entry.key())
+	}
 
 	return nil
 }
 
-func (v *VPPDataplane) deregisterPunt(farID uint32) error {
+// releasePunt drops a reference taken by registerPunt and removes the
+// registration from VPP once nothing uses it. If VPP refuses, the
+// registration is kept so that it is still saved and Reconcile can retry
+// after a restart. The caller saves the state.
+func (v *VPPDataplane) releasePunt(reg *puntRegistration) {
+	key := reg.key()
+	if v.puntRefs[key] == 0 {
+		return
+	}
+
+	v.puntRefs[key]--
+	if v.puntRefs[key] > 0 {
+		return
+	}
+
+	if err := v.setPunt(reg, false); err != nil {
+		fmt.Printf("VPP: ERROR removing punt %s: %v\n", key, err)
+		v.inheritedPunts[key] = reg
+	}
+
+	delete(v.punts, key)
+	delete(v.puntRefs, key)
+	v.puntGuardDirty = true
+}
+
+func (v *VPPDataplane) releasePunts(regs []*puntRegistration) {
+	if len(regs) == 0 {
+		return
+	}
+	for _, reg := range regs {
+		v.releasePunt(reg)
+	}
+
+	if err := v.saveState(); err != nil {
+		fmt.Printf("VPP: Failed to save state: %v\n", err)
+	}
+}
+
+func (v *VPPDataplane) releasePDRPunts(session *sessionState, pdrID uint16) {
+	regs, ok := session.pdrPunts[pdrID]
+	if !ok {
+		return
+	}
+
+	v.releasePunts(regs)
+	delete(session.pdrPunts, pdrID)
+
+	for _, entry := range session.pdrClassify[pdrID] {
+		v.releaseClassifySession(entry)
+	}
+	delete(session.pdrClassify, pdrID)
+}
+
+// deregisterPunt releases the punts of every PDR forwarding through the FAR,
+// for when the FAR is removed or stops forwarding.
+func (v *VPPDataplane) deregisterPunt(session *sessionState, farID uint32) {
 	fmt.Printf("VPP: Punt deregistration for FAR %d\n", farID)
-	return nil
+
+	for _, pdr := range session.pdrs {
+		if pdr.FAR_ID == farID {
+			v.releasePDRPunts(session, pdr.ID)
+		}
+	}
 }
 
 func (v *VPPDataplane) configureL2PuntForPDR(seid uint64, pdr *up.PDR) error {
 	session := v.sessions[seid]
 
-	l2Filter, ok := GetL2Filter(pdr.PDI.ApplicationID)
+	l2Filter, ok := protocol.GetL2Filter(pdr.PDI.ApplicationID)
 	if !ok {
 		return fmt.Errorf("unknown Application ID: %s", pdr.PDI.ApplicationID)
 	}
@@ -381,17 +904,55 @@ func (v *VPPDataplane) configureL2PuntForPDR(seid uint64, pdr *up.PDR) error {
 	fmt.Printf("VPP: Configuring L2 punt for PDR %d (Application ID: %s, EtherType: 0x%04x)\n",
 		pdr.ID, l2Filter.Name, l2Filter.EtherType)
 
-	// For L2 punt, we use VPP classify tables with punt action
-	// This allows matching on EtherType and punting to control plane
-	// The classify table creates a mask for the EtherType field (offset 12-13 in Ethernet frame)
+	if err := v.ensureL2PuntTable(); err != nil {
+		return err
+	}
+
+	entry := &classifyEntry{
+		TableIndex:   v.l2PuntTable,
+		Match:        etherTypeMatch(l2Filter.EtherType),
+		HitNextIndex: v.l2PuntNextIndex,
+		OpaqueIndex:  ^uint32(0),
+	}
+
+	if err := v.registerClassifySession(entry); err != nil {
+		return fmt.Errorf("add classify session: %w", err)
+	}
+
+	fmt.Printf("VPP: L2 punt configured for Application ID %s (EtherType 0x%04x, table %d)\n",
+		pdr.PDI.ApplicationID, l2Filter.EtherType, v.l2PuntTable)
+
+	session.pdrPunts[pdr.ID] = nil
+	session.pdrClassify[pdr.ID] = []*classifyEntry{entry}
+
+	return nil
+}
+
+// configureEthernetPuntForPDR diverts the frames matching any of the PDR's
+// Ethernet packet filters, or its PPPoE session, with a classify session per
+// header match.
+func (v *VPPDataplane) configureEthernetPuntForPDR(seid uint64, pdr *up.PDR) error {
+	session := v.sessions[seid]
+
+	entries, err := v.ethernetFilterEntries(pdr.PDI)
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		if err := v.registerClassifySession(entry); err != nil {
+			for _, added := range entries[:i] {
+				v.releaseClassifySession(added)
+			}
+			return fmt.Errorf("add classify session: %w", err)
+		}
+	}
 
-	// TODO: Implement classify table-based L2 punt
-	// For now, log that L2 punt is configured
-	fmt.Printf("VPP: L2 punt configured for Application ID %s (EtherType 0x%04x)\n",
-		pdr.PDI.ApplicationID, l2Filter.EtherType)
+	fmt.Printf("VPP: L2 punt configured for PDR %d (%d Ethernet packet filters, PPPoE %v, %d classify sessions)\n",
+		pdr.ID, len(pdr.PDI.EthernetPacketFilters), pdr.PDI.PPPoE != nil, len(entries))
 
-	puntKey := fmt.Sprintf("pdr-%d", pdr.ID)
-	session.puntReg[puntKey] = true
+	session.pdrPunts[pdr.ID] = nil
+	session.pdrClassify[pdr.ID] = entries
 
 	return nil
 }
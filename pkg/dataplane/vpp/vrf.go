@@ -0,0 +1,46 @@
+package vpp
+
+import (
+	"fmt"
+
+	"go.fd.io/govpp/binapi/ip"
+)
+
+// HasNetworkInstance reports whether name is mapped to a FIB table.
+func (v *VPPDataplane) HasNetworkInstance(name string) bool {
+	_, ok := v.networkInstances[name]
+	return ok
+}
+
+// fibTable is the FIB table of a Network Instance. The UP rejects rules
+// naming unknown ones, so anything else is the default table.
+func (v *VPPDataplane) fibTable(name string) uint32 {
+	return v.networkInstances[name]
+}
+
+// addFIBTables creates the IPv4 and IPv6 tables of each Network Instance,
+// named after it. Adding a table that exists only takes another lock on it.
+func (v *VPPDataplane) addFIBTables() error {
+	for name, tableID := range v.networkInstances {
+		if tableID == 0 {
+			continue
+		}
+		for _, isIP6 := range []bool{false, true} {
+			req := &ip.IPTableAddDel{
+				IsAdd: true,
+				Table: ip.IPTable{TableID: tableID, IsIP6: isIP6, Name: name},
+			}
+
+			reply := &ip.IPTableAddDelReply{}
+			if err := v.ch.SendRequest(req).ReceiveReply(reply); err != nil {
+				return fmt.Errorf("table %d (%s): %w", tableID, name, err)
+			}
+			if reply.Retval != 0 {
+				return fmt.Errorf("table %d (%s): VPPApiError: %s (%d)",
+					tableID, name, vppErrorString(reply.Retval), reply.Retval)
+			}
+		}
+		fmt.Printf("VPP: Network instance %s is FIB table %d\n", name, tableID)
+	}
+	return nil
+}
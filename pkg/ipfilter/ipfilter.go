@@ -0,0 +1,594 @@
+// Package ipfilter parses and serializes the IPFilterRule flow descriptions
+// of RFC 6733 section 4.3.1, as carried in PFCP SDF filters:
+//
+//	action dir proto from src [ports] to dst [ports] [options]
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+type Action uint8
+
+const (
+	ActionPermit Action = iota
+	ActionDeny
+)
+
+func (a Action) String() string {
+	if a == ActionDeny {
+		return "deny"
+	}
+	return "permit"
+}
+
+type Direction uint8
+
+const (
+	DirectionIn Direction = iota
+	DirectionOut
+)
+
+func (d Direction) String() string {
+	if d == DirectionOut {
+		return "out"
+	}
+	return "in"
+}
+
+// ProtocolAny is the "ip" keyword, matching every IP protocol.
+const ProtocolAny uint8 = 0
+
+const (
+	ProtocolICMP   uint8 = 1
+	ProtocolTCP    uint8 = 6
+	ProtocolUDP    uint8 = 17
+	ProtocolICMPv6 uint8 = 58
+	ProtocolSCTP   uint8 = 132
+)
+
+var protocolNames = map[string]uint8{
+	"ip":     ProtocolAny,
+	"icmp":   ProtocolICMP,
+	"tcp":    ProtocolTCP,
+	"udp":    ProtocolUDP,
+	"icmpv6": ProtocolICMPv6,
+	"sctp":   ProtocolSCTP,
+}
+
+type AddressKind uint8
+
+const (
+	// AddressAny matches every address.
+	AddressAny AddressKind = iota
+	// AddressAssigned is the address, or addresses, assigned to the UE.
+	AddressAssigned
+	// AddressPrefix is a single address or an address with a mask width.
+	AddressPrefix
+)
+
+type Family uint8
+
+const (
+	// FamilyAny is a rule whose addresses are all "any" or "assigned".
+	FamilyAny Family = iota
+	FamilyIPv4
+	FamilyIPv6
+)
+
+type PortRange struct {
+	First uint16
+	Last  uint16
+}
+
+func (p PortRange) String() string {
+	if p.First == p.Last {
+		return strconv.Itoa(int(p.First))
+	}
+	return fmt.Sprintf("%d-%d", p.First, p.Last)
+}
+
+// Endpoint is the source or destination of a rule. No ports means any port.
+type Endpoint struct {
+	Kind AddressKind
+	// Negate matches everything except the address.
+	Negate bool
+	// Prefix keeps the address as written, so "192.0.2.10/24" serializes
+	// unchanged; Masked gives the network.
+	Prefix netip.Prefix
+	Ports  []PortRange
+}
+
+func (e Endpoint) String() string {
+	var b strings.Builder
+	if e.Negate {
+		b.WriteByte('!')
+	}
+	switch e.Kind {
+	case AddressAny:
+		b.WriteString("any")
+	case AddressAssigned:
+		b.WriteString("assigned")
+	default:
+		if e.Prefix.IsSingleIP() {
+			b.WriteString(e.Prefix.Addr().String())
+		} else {
+			b.WriteString(e.Prefix.String())
+		}
+	}
+	if len(e.Ports) > 0 {
+		b.WriteByte(' ')
+		b.WriteString(joinPorts(e.Ports))
+	}
+	return b.String()
+}
+
+// Masked returns the network the endpoint matches. It is only meaningful for
+// AddressPrefix endpoints.
+func (e Endpoint) Masked() netip.Prefix {
+	return e.Prefix.Masked()
+}
+
+func (e Endpoint) family() Family {
+	if e.Kind != AddressPrefix {
+		return FamilyAny
+	}
+	if e.Prefix.Addr().Is4() {
+		return FamilyIPv4
+	}
+	return FamilyIPv6
+}
+
+// Flag is an item of an ipoptions, tcpoptions or tcpflags list. A negated
+// flag must be absent.
+type Flag struct {
+	Name   string
+	Negate bool
+}
+
+func (f Flag) String() string {
+	if f.Negate {
+		return "!" + f.Name
+	}
+	return f.Name
+}
+
+var (
+	ipOptionNames  = []string{"ssrr", "lsrr", "rr", "ts"}
+	tcpOptionNames = []string{"mss", "window", "sack", "ts", "cc"}
+	tcpFlagNames   = []string{"fin", "syn", "rst", "psh", "ack", "urg"}
+)
+
+// Options are the matches that may follow the destination.
+type Options struct {
+	Frag        bool
+	IPOptions   []Flag
+	TCPOptions  []Flag
+	Established bool
+	Setup       bool
+	TCPFlags    []Flag
+	ICMPTypes   []uint8
+}
+
+// IsZero reports whether no option is set.
+func (o Options) IsZero() bool {
+	return !o.Frag && !o.Established && !o.Setup && len(o.IPOptions) == 0 &&
+		len(o.TCPOptions) == 0 && len(o.TCPFlags) == 0 && len(o.ICMPTypes) == 0
+}
+
+func (o Options) String() string {
+	var parts []string
+	if o.Frag {
+		parts = append(parts, "frag")
+	}
+	if len(o.IPOptions) > 0 {
+		parts = append(parts, "ipoptions", joinFlags(o.IPOptions))
+	}
+	if len(o.TCPOptions) > 0 {
+		parts = append(parts, "tcpoptions", joinFlags(o.TCPOptions))
+	}
+	if o.Established {
+		parts = append(parts, "established")
+	}
+	if o.Setup {
+		parts = append(parts, "setup")
+	}
+	if len(o.TCPFlags) > 0 {
+		parts = append(parts, "tcpflags", joinFlags(o.TCPFlags))
+	}
+	if len(o.ICMPTypes) > 0 {
+		types := make([]string, len(o.ICMPTypes))
+		for i, t := range o.ICMPTypes {
+			types[i] = strconv.Itoa(int(t))
+		}
+		parts = append(parts, "icmptypes", strings.Join(types, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Rule is a parsed IPFilterRule.
+type Rule struct {
+	Action    Action
+	Direction Direction
+	Protocol  uint8
+	Src       Endpoint
+	Dst       Endpoint
+	Options   Options
+}
+
+// Family returns the address family of the rule's addresses.
+func (r *Rule) Family() Family {
+	if f := r.Src.family(); f != FamilyAny {
+		return f
+	}
+	return r.Dst.family()
+}
+
+// Oriented returns the rule as it applies to packets travelling in dir, out
+// for downlink and in for uplink. A rule is written for the direction it
+// names and matches the other direction with its source and destination,
+// addresses and ports alike, swapped, as TS 29.212 and TS 29.244 specify.
+func (r *Rule) Oriented(dir Direction) *Rule {
+	if r.Direction == dir {
+		return r
+	}
+	swapped := *r
+	swapped.Direction = dir
+	swapped.Src, swapped.Dst = r.Dst, r.Src
+	return &swapped
+}
+
+// String serializes the rule in canonical form, with the protocol as a
+// number and the options in RFC order. Parsing the result gives the same
+// rule.
+func (r *Rule) String() string {
+	proto := "ip"
+	if r.Protocol != ProtocolAny {
+		proto = strconv.Itoa(int(r.Protocol))
+	}
+
+	s := fmt.Sprintf("%s %s %s from %s to %s", r.Action, r.Direction, proto, r.Src, r.Dst)
+	if !r.Options.IsZero() {
+		s += " " + r.Options.String()
+	}
+	return s
+}
+
+// Parse parses an IPFilterRule. Besides protocol numbers it accepts the
+// names ip, icmp, tcp, udp, icmpv6 and sctp.
+func Parse(s string) (*Rule, error) {
+	rule, err := parse(strings.Fields(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IPFilterRule %q: %w", s, err)
+	}
+	return rule, nil
+}
+
+type tokens struct {
+	fields []string
+	pos    int
+}
+
+func (t *tokens) next() (string, bool) {
+	if t.pos >= len(t.fields) {
+		return "", false
+	}
+	tok := t.fields[t.pos]
+	t.pos++
+	return tok, true
+}
+
+func (t *tokens) peek() (string, bool) {
+	if t.pos >= len(t.fields) {
+		return "", false
+	}
+	return t.fields[t.pos], true
+}
+
+func (t *tokens) expect(what string) (string, error) {
+	tok, ok := t.next()
+	if !ok {
+		return "", fmt.Errorf("missing %s", what)
+	}
+	return tok, nil
+}
+
+func parse(fields []string) (*Rule, error) {
+	t := &tokens{fields: fields}
+	rule := &Rule{}
+
+	tok, err := t.expect("action")
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case "permit":
+		rule.Action = ActionPermit
+	case "deny":
+		rule.Action = ActionDeny
+	default:
+		return nil, fmt.Errorf("invalid action %q", tok)
+	}
+
+	if tok, err = t.expect("direction"); err != nil {
+		return nil, err
+	}
+	switch tok {
+	case "in":
+		rule.Direction = DirectionIn
+	case "out":
+		rule.Direction = DirectionOut
+	default:
+		return nil, fmt.Errorf("invalid direction %q", tok)
+	}
+
+	if tok, err = t.expect("protocol"); err != nil {
+		return nil, err
+	}
+	if rule.Protocol, err = parseProtocol(tok); err != nil {
+		return nil, err
+	}
+
+	if tok, err = t.expect("\"from\""); err != nil {
+		return nil, err
+	}
+	if tok != "from" {
+		return nil, fmt.Errorf("expected \"from\", got %q", tok)
+	}
+	if rule.Src, err = parseEndpoint(t); err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+
+	if tok, err = t.expect("\"to\""); err != nil {
+		return nil, err
+	}
+	if tok != "to" {
+		return nil, fmt.Errorf("expected \"to\", got %q", tok)
+	}
+	if rule.Dst, err = parseEndpoint(t); err != nil {
+		return nil, fmt.Errorf("destination: %w", err)
+	}
+
+	if rule.Options, err = parseOptions(t); err != nil {
+		return nil, err
+	}
+
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func parseProtocol(tok string) (uint8, error) {
+	if proto, ok := protocolNames[strings.ToLower(tok)]; ok {
+		return proto, nil
+	}
+	proto, err := strconv.ParseUint(tok, 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid protocol %q", tok)
+	}
+	return uint8(proto), nil
+}
+
+func parseEndpoint(t *tokens) (Endpoint, error) {
+	var e Endpoint
+
+	tok, err := t.expect("address")
+	if err != nil {
+		return e, err
+	}
+	if tok == "!" {
+		e.Negate = true
+		if tok, err = t.expect("address"); err != nil {
+			return e, err
+		}
+	} else if strings.HasPrefix(tok, "!") {
+		e.Negate = true
+		tok = tok[1:]
+	}
+
+	switch tok {
+	case "any":
+		if e.Negate {
+			return e, fmt.Errorf("\"!any\" matches nothing")
+		}
+		e.Kind = AddressAny
+	case "assigned":
+		e.Kind = AddressAssigned
+	default:
+		e.Kind = AddressPrefix
+		if e.Prefix, err = parsePrefix(tok); err != nil {
+			return e, err
+		}
+	}
+
+	if tok, ok := t.peek(); ok && tok != "" && tok[0] >= '0' && tok[0] <= '9' {
+		t.next()
+		if e.Ports, err = parsePorts(tok); err != nil {
+			return e, err
+		}
+	}
+
+	return e, nil
+}
+
+func parsePrefix(tok string) (netip.Prefix, error) {
+	if strings.Contains(tok, "/") {
+		prefix, err := netip.ParsePrefix(tok)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid address %q", tok)
+		}
+		return prefix, nil
+	}
+
+	addr, err := netip.ParseAddr(tok)
+	if err != nil || addr.Zone() != "" {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", tok)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func parsePorts(tok string) ([]PortRange, error) {
+	var ports []PortRange
+	for _, item := range strings.Split(tok, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		if !isRange {
+			last = first
+		}
+
+		start, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		end, err := strconv.ParseUint(last, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", item)
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+
+		ports = append(ports, PortRange{First: uint16(start), Last: uint16(end)})
+	}
+	return ports, nil
+}
+
+func parseOptions(t *tokens) (Options, error) {
+	var o Options
+	seen := make(map[string]bool)
+
+	for {
+		tok, ok := t.next()
+		if !ok {
+			return o, nil
+		}
+		if seen[tok] {
+			return o, fmt.Errorf("duplicate option %q", tok)
+		}
+		seen[tok] = true
+
+		var err error
+		switch tok {
+		case "frag":
+			o.Frag = true
+		case "established":
+			o.Established = true
+		case "setup":
+			o.Setup = true
+		case "ipoptions":
+			o.IPOptions, err = parseFlags(t, tok, ipOptionNames)
+		case "tcpoptions":
+			o.TCPOptions, err = parseFlags(t, tok, tcpOptionNames)
+		case "tcpflags":
+			o.TCPFlags, err = parseFlags(t, tok, tcpFlagNames)
+		case "icmptypes":
+			o.ICMPTypes, err = parseICMPTypes(t)
+		default:
+			return o, fmt.Errorf("unknown option %q", tok)
+		}
+		if err != nil {
+			return o, err
+		}
+	}
+}
+
+func parseFlags(t *tokens, option string, names []string) ([]Flag, error) {
+	spec, err := t.expect(option + " list")
+	if err != nil {
+		return nil, err
+	}
+
+	var flags []Flag
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(spec, ",") {
+		flag := Flag{Name: item}
+		if strings.HasPrefix(item, "!") {
+			flag = Flag{Name: item[1:], Negate: true}
+		}
+		if !contains(names, flag.Name) {
+			return nil, fmt.Errorf("invalid %s %q", option, item)
+		}
+		if seen[flag.Name] {
+			return nil, fmt.Errorf("duplicate %s %q", option, flag.Name)
+		}
+		seen[flag.Name] = true
+		flags = append(flags, flag)
+	}
+	return flags, nil
+}
+
+func parseICMPTypes(t *tokens) ([]uint8, error) {
+	spec, err := t.expect("icmptypes list")
+	if err != nil {
+		return nil, err
+	}
+
+	var types []uint8
+	for _, item := range strings.Split(spec, ",") {
+		v, err := strconv.ParseUint(item, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid icmptype %q", item)
+		}
+		types = append(types, uint8(v))
+	}
+	return types, nil
+}
+
+// validate rejects combinations the RFC does not allow.
+func (r *Rule) validate() error {
+	src, dst := r.Src.family(), r.Dst.family()
+	if src != FamilyAny && dst != FamilyAny && src != dst {
+		return fmt.Errorf("source and destination address families differ")
+	}
+
+	hasPorts := len(r.Src.Ports) > 0 || len(r.Dst.Ports) > 0
+	switch r.Protocol {
+	case ProtocolTCP, ProtocolUDP, ProtocolSCTP:
+	default:
+		if hasPorts {
+			return fmt.Errorf("ports require tcp, udp or sctp")
+		}
+	}
+
+	o := r.Options
+	if o.Frag && (hasPorts || len(o.TCPFlags) > 0) {
+		return fmt.Errorf("frag cannot be combined with ports or tcpflags")
+	}
+	if (o.Established || o.Setup || len(o.TCPFlags) > 0 || len(o.TCPOptions) > 0) && r.Protocol != ProtocolTCP {
+		return fmt.Errorf("TCP options require tcp")
+	}
+	if len(o.ICMPTypes) > 0 && r.Protocol != ProtocolICMP && r.Protocol != ProtocolICMPv6 {
+		return fmt.Errorf("icmptypes requires icmp or icmpv6")
+	}
+
+	return nil
+}
+
+func joinPorts(ports []PortRange) string {
+	items := make([]string, len(ports))
+	for i, p := range ports {
+		items[i] = p.String()
+	}
+	return strings.Join(items, ",")
+}
+
+func joinFlags(flags []Flag) string {
+	items := make([]string, len(flags))
+	for i, f := range flags {
+		items[i] = f.String()
+	}
+	return strings.Join(items, ",")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package ipfilter
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		want Rule
+	}{
+		{
+			name: "protocol name and single ports",
+			rule: "permit out udp from any 67 to assigned 68",
+			want: Rule{
+				Direction: DirectionOut,
+				Protocol:  ProtocolUDP,
+				Src:       Endpoint{Kind: AddressAny, Ports: []PortRange{{67, 67}}},
+				Dst:       Endpoint{Kind: AddressAssigned, Ports: []PortRange{{68, 68}}},
+			},
+		},
+		{
+			name: "port ranges and lists",
+			rule: "permit in 6 from assigned to 192.0.2.0/24 80,443,8000-8080",
+			want: Rule{
+				Protocol: ProtocolTCP,
+				Src:      Endpoint{Kind: AddressAssigned},
+				Dst: Endpoint{
+					Kind:   AddressPrefix,
+					Prefix: netip.MustParsePrefix("192.0.2.0/24"),
+					Ports:  []PortRange{{80, 80}, {443, 443}, {8000, 8080}},
+				},
+			},
+		},
+		{
+			name: "negated addresses",
+			rule: "deny in ip from !2001:db8::/32 to ! 2001:db8::1",
+			want: Rule{
+				Action: ActionDeny,
+				Src:    Endpoint{Kind: AddressPrefix, Negate: true, Prefix: netip.MustParsePrefix("2001:db8::/32")},
+				Dst:    Endpoint{Kind: AddressPrefix, Negate: true, Prefix: netip.MustParsePrefix("2001:db8::1/128")},
+			},
+		},
+		{
+			name: "host bits are kept",
+			rule: "permit out ip from 192.0.2.10/24 to assigned",
+			want: Rule{
+				Direction: DirectionOut,
+				Src:       Endpoint{Kind: AddressPrefix, Prefix: netip.MustParsePrefix("192.0.2.10/24")},
+				Dst:       Endpoint{Kind: AddressAssigned},
+			},
+		},
+		{
+			name: "options",
+			rule: "permit in tcp from any to any established tcpflags syn,!ack",
+			want: Rule{
+				Protocol: ProtocolTCP,
+				Src:      Endpoint{Kind: AddressAny},
+				Dst:      Endpoint{Kind: AddressAny},
+				Options: Options{
+					Established: true,
+					TCPFlags:    []Flag{{Name: "syn"}, {Name: "ack", Negate: true}},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if fmt.Sprintf("%#v", *got) != fmt.Sprintf("%#v", tt.want) {
+				t.Errorf("Parse(%q) = %#v, want %#v", tt.rule, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		wantErr string
+	}{
+		{"mixed families", "permit out ip from 192.0.2.1 to 2001:db8::1", "address families differ"},
+		{"mixed families with prefixes", "permit in ip from 2001:db8::/32 to 10.0.0.0/8", "address families differ"},
+		{"over-long IPv4 prefix", "permit out ip from 192.0.2.0/33 to assigned", "invalid address"},
+		{"over-long IPv6 prefix", "permit out ip from 2001:db8::/129 to assigned", "invalid address"},
+		{"zoned address", "permit out ip from fe80::1%eth0 to assigned", "invalid address"},
+		{"reversed port range", "permit out udp from any 68-67 to assigned", "invalid port range"},
+		{"port out of range", "permit out udp from any 65536 to assigned", "invalid port"},
+		{"ports without transport", "permit out icmp from any 1 to assigned", "ports require"},
+		{"negated any", "permit out ip from !any to assigned", "matches nothing"},
+		{"unknown action", "allow out ip from any to assigned", "invalid action"},
+		{"unknown direction", "permit both ip from any to assigned", "invalid direction"},
+		{"missing destination", "permit out ip from any", "missing"},
+		{"TCP option on UDP", "permit out udp from any to assigned setup", "require tcp"},
+		{"icmptypes on TCP", "permit out tcp from any to assigned icmptypes 8", "requires icmp"},
+		{"frag with ports", "permit out udp from any 53 to assigned frag", "frag cannot"},
+		{"duplicate option", "permit out tcp from any to assigned setup setup", "duplicate option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Parse(%q) error = %v, want one containing %q", tt.rule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOriented(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		dir  Direction
+		want string
+	}{
+		{
+			name: "same direction is unchanged",
+			rule: "permit out 17 from any 53 to assigned 1024-65535",
+			dir:  DirectionOut,
+			want: "permit out 17 from any 53 to assigned 1024-65535",
+		},
+		{
+			name: "out rule for uplink swaps addresses and ports",
+			rule: "permit out 17 from any 53 to assigned 1024-65535",
+			dir:  DirectionIn,
+			want: "permit in 17 from assigned 1024-65535 to any 53",
+		},
+		{
+			name: "in rule for downlink keeps negation with its address",
+			rule: "deny in 6 from assigned to !192.0.2.0/24 443",
+			dir:  DirectionOut,
+			want: "deny out 6 from !192.0.2.0/24 443 to assigned",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := rule.Oriented(tt.dir).String(); got != tt.want {
+				t.Errorf("Oriented(%s) = %q, want %q", tt.dir, got, tt.want)
+			}
+			if got := rule.String(); got != tt.rule {
+				t.Errorf("Oriented changed the rule to %q", got)
+			}
+		})
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string
+	}{
+		{"permit out udp from any 67 to assigned 68", "permit out 17 from any 67 to assigned 68"},
+		{"permit in ip from assigned to any", "permit in ip from assigned to any"},
+		{"deny out tcp from ! 10.0.0.0/8 to 192.0.2.10/24 80,8000-8080", "deny out 6 from !10.0.0.0/8 to 192.0.2.10/24 80,8000-8080"},
+		{"permit out 58 from 2001:db8::/64 to assigned icmptypes 128,129", "permit out 58 from 2001:db8::/64 to assigned icmptypes 128,129"},
+		{"permit in tcp from any to any tcpflags !ack,syn setup tcpoptions mss", "permit in 6 from any to any tcpoptions mss setup tcpflags !ack,syn"},
+		{"permit out ip from any to assigned frag ipoptions !ts", "permit out ip from any to assigned frag ipoptions !ts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got := rule.String()
+			if got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+
+			again, err := Parse(got)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", got, err)
+			}
+			if fmt.Sprintf("%#v", *again) != fmt.Sprintf("%#v", *rule) {
+				t.Errorf("Parse(%q) = %#v, want %#v", got, *again, *rule)
+			}
+		})
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tests := []struct {
+		rule string
+		want Family
+	}{
+		{"permit out ip from any to assigned", FamilyAny},
+		{"permit out ip from 192.0.2.1 to assigned", FamilyIPv4},
+		{"permit out ip from assigned to 2001:db8::/32", FamilyIPv6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			rule, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := rule.Family(); got != tt.want {
+				t.Errorf("Family() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package protocol
+
+import (
+	"fmt"
+	"time"
+)
+
+// The Downlink Data Notification Delay is encoded in steps of 50ms.
+const dlDataNotificationDelayUnit = 50 * time.Millisecond
+
+func NewBAR_ID_IE(id uint8) *IE {
+	return &IE{
+		Type:  IETypeBAR_ID,
+		Value: []byte{id},
+	}
+}
+
+func (ie *IE) GetBAR_ID() (uint8, error) {
+	if ie.Type != IETypeBAR_ID || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid BAR ID IE")
+	}
+	return ie.Value[0], nil
+}
+
+// NewDownlinkDataNotificationDelayIE rounds the delay down to 50ms and caps
+// it at 12.75s, the longest the IE can carry.
+func NewDownlinkDataNotificationDelayIE(delay time.Duration) *IE {
+	steps := min(delay/dlDataNotificationDelayUnit, 255)
+	return &IE{
+		Type:  IETypeDownlinkDataNotificationDelay,
+		Value: []byte{byte(max(steps, 0))},
+	}
+}
+
+func (ie *IE) GetDownlinkDataNotificationDelay() (time.Duration, error) {
+	if ie.Type != IETypeDownlinkDataNotificationDelay || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Downlink Data Notification Delay IE")
+	}
+	return time.Duration(ie.Value[0]) * dlDataNotificationDelayUnit, nil
+}
+
+func NewSuggestedBufferingPacketsCountIE(count uint8) *IE {
+	return &IE{
+		Type:  IETypeSuggestedBufferingPacketsCount,
+		Value: []byte{count},
+	}
+}
+
+func (ie *IE) GetSuggestedBufferingPacketsCount() (uint8, error) {
+	if ie.Type != IETypeSuggestedBufferingPacketsCount || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Suggested Buffering Packets Count IE")
+	}
+	return ie.Value[0], nil
+}
+
+// NewDownlinkDataReportIE reports the PDRs whose downlink packets the UP
+// started buffering.
+func NewDownlinkDataReportIE(pdrIDs []uint16) (*IE, error) {
+	children := make([]*IE, 0, len(pdrIDs))
+	for _, id := range pdrIDs {
+		children = append(children, NewPDR_ID_IE(id))
+	}
+	return NewGroupedIE(IETypeDownlinkDataReport, children)
+}
+
+// ParseDownlinkDataReport returns the PDR IDs of a Downlink Data Report IE.
+func ParseDownlinkDataReport(ie *IE) ([]uint16, error) {
+	if ie.Type != IETypeDownlinkDataReport {
+		return nil, fmt.Errorf("invalid Downlink Data Report IE")
+	}
+
+	children, err := ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse Downlink Data Report: %w", err)
+	}
+
+	var pdrIDs []uint16
+	for _, child := range children {
+		if child.Type != IETypePDR_ID {
+			continue
+		}
+		id, err := child.GetPDR_ID()
+		if err != nil {
+			return nil, err
+		}
+		pdrIDs = append(pdrIDs, id)
+	}
+
+	if len(pdrIDs) == 0 {
+		return nil, fmt.Errorf("Downlink Data Report missing PDR ID")
+	}
+	return pdrIDs, nil
+}
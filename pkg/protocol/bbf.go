@@ -0,0 +1,518 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// EnterpriseIDBBF is the IANA enterprise number of the Broadband Forum,
+// whose TR-459 defines the vendor-specific IEs of BNG CUPS.
+const EnterpriseIDBBF uint16 = 3561
+
+// BBF IE types (TR-459)
+const (
+	IETypeBBFLogicalPort         uint16 = 32769
+	IETypeBBFOuterHeaderCreation uint16 = 32770
+	IETypeBBFOuterHeaderRemoval  uint16 = 32771
+	IETypeBBFPPPoESessionID      uint16 = 32772
+	IETypeBBFPPPProtocol         uint16 = 32773
+	IETypeBBFMTU                 uint16 = 32776
+	IETypeBBFL2TPTunnelEndpoint  uint16 = 32777
+	IETypeBBFL2TPSessionID       uint16 = 32778
+	IETypeBBFL2TPType            uint16 = 32779
+)
+
+// BBF Outer Header Creation description flags, octet 5 and 6 as one value
+const (
+	BBFOuterHeaderCreationTrafficEndpoint uint16 = 0x0001
+	BBFOuterHeaderCreationL2TP            uint16 = 0x0002
+	BBFOuterHeaderCreationPPP             uint16 = 0x0004
+	BBFOuterHeaderCreationCPRNSH          uint16 = 0x0100
+)
+
+// BBF Outer Header Removal descriptions
+const (
+	BBFOuterHeaderRemovalEthernet      uint8 = 1
+	BBFOuterHeaderRemovalPPPoEEthernet uint8 = 2
+	BBFOuterHeaderRemovalPPPPPPoE      uint8 = 3
+	BBFOuterHeaderRemovalL2TP          uint8 = 4
+	BBFOuterHeaderRemovalPPPL2TP       uint8 = 5
+)
+
+const (
+	pppProtocolFlagProtocol = 0x01
+	pppProtocolFlagData     = 0x02
+	pppProtocolFlagControl  = 0x04
+
+	l2tpTunnelEndpointFlagV4     = 0x01
+	l2tpTunnelEndpointFlagV6     = 0x02
+	l2tpTunnelEndpointFlagChoose = 0x04
+
+	// EtherTypePPPoESession is the EtherType of PPPoE Session stage frames.
+	EtherTypePPPoESession uint16 = 0x8864
+)
+
+// The BBF IEs decode to these types through the enterprise IE registry.
+type (
+	BBFLogicalPort        string
+	BBFOuterHeaderRemoval uint8
+	BBFPPPoESessionID     uint16
+	BBFMTU                uint16
+	BBFL2TPSessionID      uint16
+	// BBFL2TPType is true for L2TP control and false for data messages.
+	BBFL2TPType bool
+)
+
+// BBFOuterHeaderCreation is the outer header a FAR creates for a PPPoE or
+// L2TP session, or the traffic endpoint it forwards to.
+type BBFOuterHeaderCreation struct {
+	Description uint16
+	// TunnelID and SessionID identify the L2TP session, with the L2TP flag.
+	TunnelID  uint16
+	SessionID uint16
+}
+
+// PPPProtocol selects PPP frames by protocol: control (LCP, NCPs and
+// authentication), data, or one protocol number.
+type PPPProtocol struct {
+	Control     bool
+	Data        bool
+	Protocol    uint16
+	HasProtocol bool
+}
+
+func (p *PPPProtocol) String() string {
+	var parts []string
+	if p.Control {
+		parts = append(parts, "control")
+	}
+	if p.Data {
+		parts = append(parts, "data")
+	}
+	if p.HasProtocol {
+		parts = append(parts, fmt.Sprintf("0x%04x", p.Protocol))
+	}
+	if len(parts) == 0 {
+		return "any"
+	}
+	return strings.Join(parts, ",")
+}
+
+// L2TPTunnelEndpoint is the local or LNS end of an L2TP tunnel. With Choose
+// set, the UP allocates the tunnel ID and address.
+type L2TPTunnelEndpoint struct {
+	TunnelID uint16
+	IPv4     net.IP
+	IPv6     net.IP
+	Choose   bool
+}
+
+func init() {
+	for _, codec := range []*EnterpriseIE{
+		{Type: IETypeBBFLogicalPort, Name: "Logical Port", Decode: func(ie *IE) (any, error) {
+			port, err := ie.GetBBFLogicalPort()
+			return BBFLogicalPort(port), err
+		}},
+		{Type: IETypeBBFOuterHeaderCreation, Name: "BBF Outer Header Creation", Decode: func(ie *IE) (any, error) {
+			return ie.GetBBFOuterHeaderCreation()
+		}},
+		{Type: IETypeBBFOuterHeaderRemoval, Name: "BBF Outer Header Removal", Decode: func(ie *IE) (any, error) {
+			description, err := ie.getBBFUint8(IETypeBBFOuterHeaderRemoval)
+			return BBFOuterHeaderRemoval(description), err
+		}},
+		{Type: IETypeBBFPPPoESessionID, Name: "PPPoE Session ID", Decode: func(ie *IE) (any, error) {
+			id, err := ie.getBBFUint16(IETypeBBFPPPoESessionID)
+			return BBFPPPoESessionID(id), err
+		}},
+		{Type: IETypeBBFPPPProtocol, Name: "PPP Protocol", Decode: func(ie *IE) (any, error) {
+			return ie.GetPPPProtocol()
+		}},
+		{Type: IETypeBBFMTU, Name: "MTU", Decode: func(ie *IE) (any, error) {
+			mtu, err := ie.getBBFUint16(IETypeBBFMTU)
+			return BBFMTU(mtu), err
+		}},
+		{Type: IETypeBBFL2TPTunnelEndpoint, Name: "L2TP Tunnel Endpoint", Decode: func(ie *IE) (any, error) {
+			return ie.GetL2TPTunnelEndpoint()
+		}},
+		{Type: IETypeBBFL2TPSessionID, Name: "L2TP Session ID", Decode: func(ie *IE) (any, error) {
+			id, err := ie.getBBFUint16(IETypeBBFL2TPSessionID)
+			return BBFL2TPSessionID(id), err
+		}},
+		{Type: IETypeBBFL2TPType, Name: "L2TP Type", Decode: func(ie *IE) (any, error) {
+			flags, err := ie.getBBFUint8(IETypeBBFL2TPType)
+			return BBFL2TPType(flags&0x01 != 0), err
+		}},
+	} {
+		codec.EnterpriseID = EnterpriseIDBBF
+		RegisterEnterpriseIE(codec)
+	}
+}
+
+func newBBFIE(ieType uint16, value []byte) *IE {
+	return &IE{
+		Type:         ieType,
+		EnterpriseID: EnterpriseIDBBF,
+		Value:        value,
+	}
+}
+
+func (ie *IE) isBBF(ieType uint16) bool {
+	return ie.Type == ieType && ie.EnterpriseID == EnterpriseIDBBF
+}
+
+func (ie *IE) getBBFUint8(ieType uint16) (uint8, error) {
+	if !ie.isBBF(ieType) || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid BBF IE %d", ieType)
+	}
+	return ie.Value[0], nil
+}
+
+func (ie *IE) getBBFUint16(ieType uint16) (uint16, error) {
+	if !ie.isBBF(ieType) || len(ie.Value) < 2 {
+		return 0, fmt.Errorf("invalid BBF IE %d", ieType)
+	}
+	return binary.BigEndian.Uint16(ie.Value), nil
+}
+
+func NewBBFLogicalPortIE(port string) *IE {
+	return newBBFIE(IETypeBBFLogicalPort, []byte(port))
+}
+
+func (ie *IE) GetBBFLogicalPort() (string, error) {
+	if !ie.isBBF(IETypeBBFLogicalPort) {
+		return "", fmt.Errorf("invalid Logical Port IE")
+	}
+	return string(ie.Value), nil
+}
+
+func NewBBFOuterHeaderCreationIE(ohc *BBFOuterHeaderCreation) *IE {
+	value := binary.BigEndian.AppendUint16(nil, ohc.Description)
+	if ohc.Description&BBFOuterHeaderCreationL2TP != 0 {
+		value = binary.BigEndian.AppendUint16(value, ohc.TunnelID)
+		value = binary.BigEndian.AppendUint16(value, ohc.SessionID)
+	}
+	return newBBFIE(IETypeBBFOuterHeaderCreation, value)
+}
+
+func (ie *IE) GetBBFOuterHeaderCreation() (*BBFOuterHeaderCreation, error) {
+	if !ie.isBBF(IETypeBBFOuterHeaderCreation) || len(ie.Value) < 2 {
+		return nil, fmt.Errorf("invalid BBF Outer Header Creation IE")
+	}
+
+	ohc := &BBFOuterHeaderCreation{Description: binary.BigEndian.Uint16(ie.Value)}
+	if ohc.Description&BBFOuterHeaderCreationL2TP != 0 {
+		if len(ie.Value) < 6 {
+			return nil, fmt.Errorf("invalid BBF Outer Header Creation IE: L2TP without tunnel and session ID")
+		}
+		ohc.TunnelID = binary.BigEndian.Uint16(ie.Value[2:4])
+		ohc.SessionID = binary.BigEndian.Uint16(ie.Value[4:6])
+	}
+	return ohc, nil
+}
+
+func NewBBFOuterHeaderRemovalIE(description uint8) *IE {
+	return newBBFIE(IETypeBBFOuterHeaderRemoval, []byte{description})
+}
+
+func (ie *IE) GetBBFOuterHeaderRemoval() (uint8, error) {
+	return ie.getBBFUint8(IETypeBBFOuterHeaderRemoval)
+}
+
+func NewBBFPPPoESessionIDIE(id uint16) *IE {
+	return newBBFIE(IETypeBBFPPPoESessionID, binary.BigEndian.AppendUint16(nil, id))
+}
+
+func (ie *IE) GetBBFPPPoESessionID() (uint16, error) {
+	return ie.getBBFUint16(IETypeBBFPPPoESessionID)
+}
+
+func NewPPPProtocolIE(p *PPPProtocol) *IE {
+	var flags byte
+	if p.Control {
+		flags |= pppProtocolFlagControl
+	}
+	if p.Data {
+		flags |= pppProtocolFlagData
+	}
+
+	value := []byte{flags}
+	if p.HasProtocol {
+		value[0] |= pppProtocolFlagProtocol
+		value = binary.BigEndian.AppendUint16(value, p.Protocol)
+	}
+	return newBBFIE(IETypeBBFPPPProtocol, value)
+}
+
+func (ie *IE) GetPPPProtocol() (*PPPProtocol, error) {
+	if !ie.isBBF(IETypeBBFPPPProtocol) || len(ie.Value) < 1 {
+		return nil, fmt.Errorf("invalid PPP Protocol IE")
+	}
+
+	flags := ie.Value[0]
+	p := &PPPProtocol{
+		Control: flags&pppProtocolFlagControl != 0,
+		Data:    flags&pppProtocolFlagData != 0,
+	}
+	if flags&pppProtocolFlagProtocol != 0 {
+		if len(ie.Value) < 3 {
+			return nil, fmt.Errorf("invalid PPP Protocol IE: protocol flag without protocol")
+		}
+		p.Protocol = binary.BigEndian.Uint16(ie.Value[1:3])
+		p.HasProtocol = true
+	}
+	return p, nil
+}
+
+func NewBBFMTUIE(mtu uint16) *IE {
+	return newBBFIE(IETypeBBFMTU, binary.BigEndian.AppendUint16(nil, mtu))
+}
+
+func (ie *IE) GetBBFMTU() (uint16, error) {
+	return ie.getBBFUint16(IETypeBBFMTU)
+}
+
+func NewL2TPTunnelEndpointIE(e *L2TPTunnelEndpoint) (*IE, error) {
+	value := []byte{0, 0, 0}
+	binary.BigEndian.PutUint16(value[1:], e.TunnelID)
+
+	if e.Choose {
+		value[0] |= l2tpTunnelEndpointFlagChoose
+	}
+	if e.IPv4 != nil {
+		v4 := e.IPv4.To4()
+		if v4 == nil {
+			return nil, fmt.Errorf("L2TP tunnel endpoint IPv4 address %s is not IPv4", e.IPv4)
+		}
+		value[0] |= l2tpTunnelEndpointFlagV4
+		value = append(value, v4...)
+	}
+	if e.IPv6 != nil {
+		if e.IPv6.To4() != nil || len(e.IPv6) != net.IPv6len {
+			return nil, fmt.Errorf("L2TP tunnel endpoint IPv6 address %s is not IPv6", e.IPv6)
+		}
+		value[0] |= l2tpTunnelEndpointFlagV6
+		value = append(value, e.IPv6...)
+	}
+	if value[0] == 0 {
+		return nil, fmt.Errorf("L2TP tunnel endpoint needs an address or CHOOSE")
+	}
+
+	return newBBFIE(IETypeBBFL2TPTunnelEndpoint, value), nil
+}
+
+func (ie *IE) GetL2TPTunnelEndpoint() (*L2TPTunnelEndpoint, error) {
+	if !ie.isBBF(IETypeBBFL2TPTunnelEndpoint) || len(ie.Value) < 3 {
+		return nil, fmt.Errorf("invalid L2TP Tunnel Endpoint IE")
+	}
+
+	flags := ie.Value[0]
+	e := &L2TPTunnelEndpoint{
+		TunnelID: binary.BigEndian.Uint16(ie.Value[1:3]),
+		Choose:   flags&l2tpTunnelEndpointFlagChoose != 0,
+	}
+
+	rest := ie.Value[3:]
+	if flags&l2tpTunnelEndpointFlagV4 != 0 {
+		if len(rest) < net.IPv4len {
+			return nil, fmt.Errorf("invalid L2TP Tunnel Endpoint IE: truncated IPv4 address")
+		}
+		e.IPv4 = net.IP(append([]byte(nil), rest[:net.IPv4len]...))
+		rest = rest[net.IPv4len:]
+	}
+	if flags&l2tpTunnelEndpointFlagV6 != 0 {
+		if len(rest) < net.IPv6len {
+			return nil, fmt.Errorf("invalid L2TP Tunnel Endpoint IE: truncated IPv6 address")
+		}
+		e.IPv6 = net.IP(append([]byte(nil), rest[:net.IPv6len]...))
+	}
+	return e, nil
+}
+
+func NewBBFL2TPSessionIDIE(id uint16) *IE {
+	return newBBFIE(IETypeBBFL2TPSessionID, binary.BigEndian.AppendUint16(nil, id))
+}
+
+func (ie *IE) GetBBFL2TPSessionID() (uint16, error) {
+	return ie.getBBFUint16(IETypeBBFL2TPSessionID)
+}
+
+// NewBBFL2TPTypeIE encodes the L2TP Type, with control true for control
+// messages.
+func NewBBFL2TPTypeIE(control bool) *IE {
+	var flags byte
+	if control {
+		flags = 0x01
+	}
+	return newBBFIE(IETypeBBFL2TPType, []byte{flags})
+}
+
+// PPPoEMatch is the PPPoE session a PDI matches with the PPPoE Session ID
+// and PPP Protocol IEs.
+type PPPoEMatch struct {
+	SessionID    uint16
+	HasSessionID bool
+	// Protocol selects the PPP frames of the session, or nil for all.
+	Protocol *PPPProtocol
+}
+
+func (p *PPPoEMatch) String() string {
+	session := "any"
+	if p.HasSessionID {
+		session = fmt.Sprintf("%d", p.SessionID)
+	}
+	protocol := "any"
+	if p.Protocol != nil {
+		protocol = p.Protocol.String()
+	}
+	return fmt.Sprintf("session %s ppp %s", session, protocol)
+}
+
+// HeaderMatches narrows Ethernet header matches down to the PPPoE Session
+// frames of the match, behind whatever tags they name. Without Ethernet
+// matches it matches untagged frames.
+func (p *PPPoEMatch) HeaderMatches(eth []*EthernetHeaderMatch) ([]*EthernetHeaderMatch, error) {
+	if len(eth) == 0 {
+		eth = []*EthernetHeaderMatch{{etherType: 12}}
+	}
+
+	var matches []*EthernetHeaderMatch
+	for _, base := range eth {
+		o := base.etherType
+		if len(base.Mask) >= o+2 && base.Mask[o] != 0 {
+			if et := binary.BigEndian.Uint16(base.Value[o:]); et != EtherTypePPPoESession {
+				return nil, fmt.Errorf("EtherType 0x%04x is not PPPoE Session", et)
+			}
+		}
+
+		m := base.clone()
+		m.set(o, []byte{0xff, 0xff}, binary.BigEndian.AppendUint16(nil, EtherTypePPPoESession))
+		// Version and type 1, code 0 for session data.
+		m.set(o+2, []byte{0xff, 0xff}, []byte{0x11, 0x00})
+		if p.HasSessionID {
+			m.set(o+4, []byte{0xff, 0xff}, binary.BigEndian.AppendUint16(nil, p.SessionID))
+		}
+
+		// Protocol numbers with the top bit set are LCP, the NCPs and
+		// authentication; the others carry data.
+		if pp := p.Protocol; pp != nil {
+			switch {
+			case pp.HasProtocol:
+				m.set(o+8, []byte{0xff, 0xff}, binary.BigEndian.AppendUint16(nil, pp.Protocol))
+			case pp.Control && !pp.Data:
+				m.set(o+8, []byte{0x80}, []byte{0x80})
+			case pp.Data && !pp.Control:
+				m.set(o+8, []byte{0x80}, []byte{0x00})
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// PPPoESession is the PPPoE session a FAR encapsulates downlink packets
+// into. Its Forwarding Parameters carry a BBF Outer Header Creation with the
+// PPP flag, the PPPoE Session ID, the subscriber's MAC address as the
+// destination of a MAC Address IE, and the C-TAG and S-TAG of the
+// subscriber's VLANs.
+type PPPoESession struct {
+	SessionID uint16
+	PeerMAC   net.HardwareAddr
+	// CTag and STag are pushed onto the frames, or nil for none.
+	CTag *VLANTag
+	STag *VLANTag
+}
+
+func (s *PPPoESession) String() string {
+	str := fmt.Sprintf("session %d peer %s", s.SessionID, s.PeerMAC)
+	if s.STag != nil {
+		str += fmt.Sprintf(" s-tag %s", s.STag)
+	}
+	if s.CTag != nil {
+		str += fmt.Sprintf(" c-tag %s", s.CTag)
+	}
+	return str
+}
+
+// NewPPPoESessionIEs encodes the session as IEs of Forwarding Parameters.
+func NewPPPoESessionIEs(s *PPPoESession) ([]*IE, error) {
+	if len(s.PeerMAC) != 6 {
+		return nil, fmt.Errorf("invalid PPPoE peer MAC address %s", s.PeerMAC)
+	}
+
+	mac, err := NewMACAddressIE(&MACAddress{Destination: s.PeerMAC})
+	if err != nil {
+		return nil, err
+	}
+	ies := []*IE{
+		NewBBFOuterHeaderCreationIE(&BBFOuterHeaderCreation{Description: BBFOuterHeaderCreationPPP}),
+		NewBBFPPPoESessionIDIE(s.SessionID),
+		mac,
+	}
+
+	for _, tag := range []struct {
+		ieType uint16
+		tag    *VLANTag
+	}{
+		{IETypeCTAG, s.CTag},
+		{IETypeSTAG, s.STag},
+	} {
+		if tag.tag == nil {
+			continue
+		}
+		if !tag.tag.HasVID {
+			return nil, fmt.Errorf("PPPoE session VLAN tag needs a VID")
+		}
+		ie, err := NewVLANTagIE(tag.ieType, tag.tag)
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+	return ies, nil
+}
+
+// L2TPSession is the L2TP session a FAR forwards a subscriber's PPP frames
+// into, as a LAC does towards the LNS. Its Forwarding Parameters carry a BBF
+// Outer Header Creation with the L2TP flag, holding the tunnel and session ID
+// the LNS assigned, and the LNS as the L2TP Tunnel Endpoint.
+type L2TPSession struct {
+	TunnelID  uint16
+	SessionID uint16
+	LNS       net.IP
+}
+
+func (s *L2TPSession) String() string {
+	return fmt.Sprintf("tunnel %d session %d LNS %s", s.TunnelID, s.SessionID, s.LNS)
+}
+
+// NewL2TPSessionIEs encodes the session as IEs of Forwarding Parameters.
+func NewL2TPSessionIEs(s *L2TPSession) ([]*IE, error) {
+	if s.TunnelID == 0 || s.SessionID == 0 {
+		return nil, fmt.Errorf("L2TP tunnel and session ID must not be 0")
+	}
+
+	lns := &L2TPTunnelEndpoint{TunnelID: s.TunnelID}
+	switch {
+	case s.LNS.To4() != nil:
+		lns.IPv4 = s.LNS
+	case len(s.LNS) == net.IPv6len:
+		lns.IPv6 = s.LNS
+	default:
+		return nil, fmt.Errorf("invalid LNS address %s", s.LNS)
+	}
+	endpoint, err := NewL2TPTunnelEndpointIE(lns)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*IE{
+		NewBBFOuterHeaderCreationIE(&BBFOuterHeaderCreation{
+			Description: BBFOuterHeaderCreationL2TP,
+			TunnelID:    s.TunnelID,
+			SessionID:   s.SessionID,
+		}),
+		endpoint,
+	}, nil
+}
@@ -35,26 +35,28 @@ const (
 )
 
 const (
-	IETypeCause                 uint16 = 19
-	IETypeSourceInterface       uint16 = 20
-	IETypeNetworkInstance       uint16 = 22
-	IETypeSDFFilter             uint16 = 23
-	IETypeApplicationID         uint16 = 24
-	IETypeGateStatus            uint16 = 25
-	IETypeMBR                   uint16 = 26
-	IETypeGBR                   uint16 = 27
-	IETypePrecedence            uint16 = 29
-	IETypeReportingTriggers     uint16 = 37
-	IETypeDestinationInterface  uint16 = 42
-	IETypeApplyAction           uint16 = 44
-	IETypeNodeID                uint16 = 60
-	IETypeMeasurementMethod     uint16 = 62
-	IETypeURR_ID                uint16 = 81
-	IETypeUE_IPAddress          uint16 = 93
-	IETypeOuterHeaderRemoval    uint16 = 95
-	IETypeRecoveryTimeStamp     uint16 = 96
-	IETypeFAR_ID                uint16 = 108
-	IETypeQER_ID                uint16 = 109
+	IETypeCause                uint16 = 19
+	IETypeSourceInterface      uint16 = 20
+	IETypeFTEID                uint16 = 21
+	IETypeNetworkInstance      uint16 = 22
+	IETypeSDFFilter            uint16 = 23
+	IETypeApplicationID        uint16 = 24
+	IETypeGateStatus           uint16 = 25
+	IETypeMBR                  uint16 = 26
+	IETypeGBR                  uint16 = 27
+	IETypePrecedence           uint16 = 29
+	IETypeReportingTriggers    uint16 = 37
+	IETypeDestinationInterface uint16 = 42
+	IETypeApplyAction          uint16 = 44
+	IETypeNodeID               uint16 = 60
+	IETypeMeasurementMethod    uint16 = 62
+	IETypeURR_ID               uint16 = 81
+	IETypeOuterHeaderCreation  uint16 = 84
+	IETypeUE_IPAddress         uint16 = 93
+	IETypeOuterHeaderRemoval   uint16 = 95
+	IETypeRecoveryTimeStamp    uint16 = 96
+	IETypeFAR_ID               uint16 = 108
+	IETypeQER_ID               uint16 = 109
 
 	IETypePDI                  uint16 = 2
 	IETypeForwardingParameters uint16 = 4
@@ -62,8 +64,20 @@ const (
 	IETypeCreateFAR            uint16 = 3
 	IETypeCreateQER            uint16 = 7
 	IETypeCreateURR            uint16 = 6
+	IETypeCreatedPDR           uint16 = 8
 	IETypePDR_ID               uint16 = 56
+	IETypeFSEID                uint16 = 57
 	IETypeFlowDescription      uint16 = 106
+
+	IETypeUpdatePDR                  uint16 = 9
+	IETypeUpdateFAR                  uint16 = 10
+	IETypeUpdateForwardingParameters uint16 = 11
+	IETypeUpdateURR                  uint16 = 13
+	IETypeUpdateQER                  uint16 = 14
+	IETypeRemovePDR                  uint16 = 15
+	IETypeRemoveFAR                  uint16 = 16
+	IETypeRemoveURR                  uint16 = 17
+	IETypeRemoveQER                  uint16 = 18
 )
 
 const (
@@ -89,21 +103,22 @@ const (
 )
 
 const (
-	CauseRequestAccepted                uint8 = 1
-	CauseRequestRejected                uint8 = 64
-	CauseSessionContextNotFound         uint8 = 65
-	CauseMandatoryIEMissing             uint8 = 66
-	CauseConditionalIEMissing           uint8 = 67
-	CauseInvalidLength                  uint8 = 68
-	CauseMandatoryIEIncorrect           uint8 = 69
-	CauseInvalidForwardingPolicy        uint8 = 70
-	CauseInvalidFTEID                   uint8 = 71
-	CauseNoEstablishedPFCPAssociation   uint8 = 72
+	CauseRequestAccepted                 uint8 = 1
+	CauseRequestRejected                 uint8 = 64
+	CauseSessionContextNotFound          uint8 = 65
+	CauseMandatoryIEMissing              uint8 = 66
+	CauseConditionalIEMissing            uint8 = 67
+	CauseInvalidLength                   uint8 = 68
+	CauseMandatoryIEIncorrect            uint8 = 69
+	CauseInvalidForwardingPolicy         uint8 = 70
+	CauseInvalidFTEID                    uint8 = 71
+	CauseNoEstablishedPFCPAssociation    uint8 = 72
 	CauseRuleCreationModificationFailure uint8 = 73
-	CausePFCPEntityInCongestion         uint8 = 74
-	CauseNoResourcesAvailable           uint8 = 75
-	CauseServiceNotSupported            uint8 = 76
-	CauseSystemFailure                  uint8 = 77
+	CausePFCPEntityInCongestion          uint8 = 74
+	CauseNoResourcesAvailable            uint8 = 75
+	CauseServiceNotSupported             uint8 = 76
+	CauseSystemFailure                   uint8 = 77
+	CauseAllDynamicAddressesOccupied     uint8 = 79
 )
 
 const (
@@ -125,3 +140,111 @@ const (
 	ReportingTriggerTimeQuota                 uint32 = 0x00000200
 	ReportingTriggerEnvelopeClosure           uint32 = 0x00000400
 )
+
+// Gate Status IE values, carried in bits 3-4 (UL) and 1-2 (DL) of the IE.
+const (
+	GateStatusOpen   uint8 = 0
+	GateStatusClosed uint8 = 1
+)
+
+const (
+	IETypeVolumeThreshold          uint16 = 31
+	IETypeTimeThreshold            uint16 = 32
+	IETypeReportType               uint16 = 39
+	IETypeUsageReportTrigger       uint16 = 63
+	IETypeVolumeMeasurement        uint16 = 66
+	IETypeDurationMeasurement      uint16 = 67
+	IETypeStartTime                uint16 = 75
+	IETypeEndTime                  uint16 = 76
+	IETypeUsageReportModification  uint16 = 78
+	IETypeUsageReportDeletion      uint16 = 79
+	IETypeUsageReportSessionReport uint16 = 80
+	IETypeUR_SEQN                  uint16 = 104
+)
+
+// Usage Report Trigger IE flags, laid out like the Reporting Triggers with
+// octet 5 in the low byte.
+const (
+	UsageReportTriggerPeriodic        uint32 = 0x00000001
+	UsageReportTriggerVolumeThreshold uint32 = 0x00000002
+	UsageReportTriggerTimeThreshold   uint32 = 0x00000004
+	UsageReportTriggerImmediate       uint32 = 0x00000080
+	UsageReportTriggerTermination     uint32 = 0x00000800
+)
+
+// Buffering Action Rule IEs. Update BAR is the one carried in Session
+// Modification Requests.
+const (
+	IETypeDownlinkDataNotificationDelay  uint16 = 46
+	IETypeDownlinkDataReport             uint16 = 83
+	IETypeCreateBAR                      uint16 = 85
+	IETypeUpdateBAR                      uint16 = 86
+	IETypeRemoveBAR                      uint16 = 87
+	IETypeBAR_ID                         uint16 = 88
+	IETypeSuggestedBufferingPacketsCount uint16 = 140
+)
+
+// Duplicating Parameters IEs. Update Duplicating Parameters is the one
+// carried in Update FAR.
+const (
+	IETypeDuplicatingParameters       uint16 = 5
+	IETypeForwardingPolicy            uint16 = 41
+	IETypeUpdateDuplicatingParameters uint16 = 105
+)
+
+// Ethernet Packet Filter IEs, which PDIs of Ethernet traffic carry.
+const (
+	IETypeEthernetPacketFilter     uint16 = 132
+	IETypeMACAddress               uint16 = 133
+	IETypeCTAG                     uint16 = 134
+	IETypeSTAG                     uint16 = 135
+	IETypeEthertype                uint16 = 136
+	IETypeEthernetFilterID         uint16 = 138
+	IETypeEthernetFilterProperties uint16 = 139
+)
+
+const (
+	ReportTypeDownlinkData uint8 = 0x01
+	ReportTypeUsage        uint8 = 0x02
+)
+
+// Outer Header Creation descriptions, octet 5 in the low byte.
+const (
+	OuterHeaderCreationGTPUUDPIPv4 uint16 = 0x0001
+	OuterHeaderCreationGTPUUDPIPv6 uint16 = 0x0002
+	OuterHeaderCreationUDPIPv4     uint16 = 0x0004
+	OuterHeaderCreationUDPIPv6     uint16 = 0x0008
+	OuterHeaderCreationIPv4        uint16 = 0x0010
+	OuterHeaderCreationIPv6        uint16 = 0x0020
+)
+
+// Outer Header Removal descriptions.
+const (
+	OuterHeaderRemovalGTPUUDPIPv4 uint8 = 0
+	OuterHeaderRemovalGTPUUDPIPv6 uint8 = 1
+	OuterHeaderRemovalUDPIPv4     uint8 = 2
+	OuterHeaderRemovalUDPIPv6     uint8 = 3
+	OuterHeaderRemovalIPv4        uint8 = 4
+	OuterHeaderRemovalIPv6        uint8 = 5
+	OuterHeaderRemovalGTPUUDPIP   uint8 = 6
+)
+
+// Offending IE, which a rejection carries to name the IE that caused it.
+const (
+	IETypeOffendingIE uint16 = 40
+)
+
+// Framed routing IEs, which PDIs carry for the subnets routed behind a UE.
+const (
+	IETypeFramedRoute     uint16 = 153
+	IETypeFramedRouting   uint16 = 154
+	IETypeFramedIPv6Route uint16 = 155
+)
+
+// Framed-Routing values (RFC 2865 5.10).
+const (
+	FramedRoutingNone          uint32 = 0
+	FramedRoutingSend          uint32 = 1
+	FramedRoutingListen        uint32 = 2
+	FramedRoutingSendAndListen uint32 = 3
+)
@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RuleDigest hashes the rules of a session so that the CP and UP can compare
+// their views without exchanging every rule. Both sides must add the same
+// fields for the same rule, independently of the order rules are added in.
+type RuleDigest struct {
+	lines []string
+}
+
+func (d *RuleDigest) AddPDR(id uint16, precedence uint32, farID uint32, qerIDs, urrIDs []uint32) {
+	d.lines = append(d.lines, fmt.Sprintf("pdr %d %d %d %v %v", id, precedence, farID, sortedIDs(qerIDs), sortedIDs(urrIDs)))
+}
+
+// DigestPDI is the PDI of a PDR as RuleDigest covers it. UEIPAddress is the
+// UE IP Address IE's content and SDFFilter the SDF Filter IE's value, so
+// that the CP and UP hash them in the same form.
+type DigestPDI struct {
+	SourceInterface       uint8
+	NetworkInstance       string
+	UEIPAddress           *UEIPAddress
+	SDFFilter             []byte
+	ApplicationID         string
+	LocalFTEID            *FTEID
+	EthernetPacketFilters []*EthernetPacketFilter
+	PPPoE                 *PPPoEMatch
+	FramedRoutes          []*FramedRoute
+	FramedRouting         uint32
+}
+
+func (d *RuleDigest) AddPDI(pdrID uint16, pdi DigestPDI) {
+	line := fmt.Sprintf("pdi %d %d %q %s %x %q %s", pdrID, pdi.SourceInterface, pdi.NetworkInstance,
+		digestString(pdi.UEIPAddress), pdi.SDFFilter, pdi.ApplicationID, digestString(pdi.LocalFTEID))
+
+	// A PPPoE match without a session ID or PPP protocol sends no IEs.
+	if pppoe := pdi.PPPoE; pppoe != nil && (pppoe.HasSessionID || pppoe.Protocol != nil) {
+		line += " pppoe " + pppoe.String()
+	}
+
+	var filters []string
+	for _, filter := range pdi.EthernetPacketFilters {
+		filters = append(filters, filter.String())
+	}
+	sort.Strings(filters)
+
+	var routes []string
+	for _, route := range pdi.FramedRoutes {
+		routes = append(routes, route.String())
+	}
+	sort.Strings(routes)
+
+	line += fmt.Sprintf(" eth [%s] routes [%s] %d", strings.Join(filters, "; "), strings.Join(routes, "; "), pdi.FramedRouting)
+	d.lines = append(d.lines, line)
+}
+
+func (d *RuleDigest) AddFAR(id uint32, applyAction uint8) {
+	d.lines = append(d.lines, fmt.Sprintf("far %d %d", id, applyAction))
+}
+
+// AddForwarding adds the Forwarding Parameters of a FAR.
+func (d *RuleDigest) AddForwarding(farID uint32, destinationInterface uint8, networkInstance string, ohc *OuterHeaderCreation, pppoe *PPPoESession, l2tp *L2TPSession) {
+	d.lines = append(d.lines, fmt.Sprintf("fwd %d %d %q %s %s %s", farID, destinationInterface, networkInstance,
+		digestOuterHeaderCreation(ohc), digestString(pppoe), digestString(l2tp)))
+}
+
+// AddDuplicating adds one of the Duplicating Parameters of a FAR.
+func (d *RuleDigest) AddDuplicating(farID uint32, destinationInterface uint8, ohc *OuterHeaderCreation, forwardingPolicy string) {
+	d.lines = append(d.lines, fmt.Sprintf("dupl %d %d %s %q", farID, destinationInterface,
+		digestOuterHeaderCreation(ohc), forwardingPolicy))
+}
+
+func (d *RuleDigest) AddQER(id uint32, gateStatus uint8, mbrUL, mbrDL uint64) {
+	d.lines = append(d.lines, fmt.Sprintf("qer %d %d %d %d", id, gateStatus, mbrUL, mbrDL))
+}
+
+func (d *RuleDigest) AddURR(id uint32, measurementMethod uint8, reportingTriggers uint32, volumeThreshold uint64, timeThreshold uint32) {
+	d.lines = append(d.lines, fmt.Sprintf("urr %d %d %d %d %d", id, measurementMethod, reportingTriggers, volumeThreshold, timeThreshold))
+}
+
+func (d *RuleDigest) Sum() string {
+	lines := append([]string(nil), d.lines...)
+	sort.Strings(lines)
+
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func sortedIDs(ids []uint32) []uint32 {
+	sorted := append([]uint32(nil), ids...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// digestString formats an optional rule field, with "-" for none.
+func digestString[T any, P interface {
+	*T
+	fmt.Stringer
+}](v P) string {
+	if v == nil {
+		return "-"
+	}
+	return "(" + v.String() + ")"
+}
+
+func digestOuterHeaderCreation(ohc *OuterHeaderCreation) string {
+	if ohc == nil {
+		return "-"
+	}
+	return fmt.Sprintf("(%d %d %s %s %d)", ohc.Description, ohc.TEID, ohc.IPv4, ohc.IPv6, ohc.Port)
+}
@@ -0,0 +1,32 @@
+package protocol
+
+import "fmt"
+
+// NewForwardingPolicyIE encodes a Forwarding Policy Identifier, which is at
+// most 255 octets long.
+func NewForwardingPolicyIE(identifier string) (*IE, error) {
+	if len(identifier) == 0 || len(identifier) > 255 {
+		return nil, fmt.Errorf("forwarding policy identifier must be 1-255 octets, got %d", len(identifier))
+	}
+
+	value := make([]byte, 0, 1+len(identifier))
+	value = append(value, byte(len(identifier)))
+	value = append(value, identifier...)
+
+	return &IE{
+		Type:  IETypeForwardingPolicy,
+		Value: value,
+	}, nil
+}
+
+func (ie *IE) GetForwardingPolicy() (string, error) {
+	if ie.Type != IETypeForwardingPolicy || len(ie.Value) < 1 {
+		return "", fmt.Errorf("invalid Forwarding Policy IE")
+	}
+
+	n := int(ie.Value[0])
+	if len(ie.Value) < 1+n {
+		return "", fmt.Errorf("invalid Forwarding Policy IE: identifier length %d exceeds %d octets", n, len(ie.Value)-1)
+	}
+	return string(ie.Value[1 : 1+n]), nil
+}
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Vendor-specific IEs have a type of 32768 or above and carry the IANA
+// enterprise ID of the vendor that defines them. Their codecs register here,
+// by enterprise ID and type, so that a receiver decodes the IEs of the
+// vendors it knows and skips the others as TS 29.244 requires.
+
+// ErrUnknownEnterpriseIE is returned for a vendor-specific IE that no codec
+// is registered for.
+var ErrUnknownEnterpriseIE = errors.New("unknown enterprise IE")
+
+// EnterpriseIE is the codec of a vendor-specific IE. IEs are built by the
+// vendor's constructors; the registry only needs to decode them.
+type EnterpriseIE struct {
+	EnterpriseID uint16
+	Type         uint16
+	Name         string
+	// Decode returns the IE's value as the Go type the vendor defines for
+	// it, so that receivers can switch on it.
+	Decode func(*IE) (any, error)
+}
+
+type enterpriseIEKey struct {
+	enterpriseID uint16
+	ieType       uint16
+}
+
+var (
+	enterpriseIERegistry   = make(map[enterpriseIEKey]*EnterpriseIE)
+	enterpriseIERegistryMu sync.RWMutex
+)
+
+// RegisterEnterpriseIE adds a codec to the registry. Codecs register from
+// init, so a type outside the vendor-specific range or one registered twice
+// panics.
+func RegisterEnterpriseIE(codec *EnterpriseIE) {
+	if codec.Type < 32768 {
+		panic(fmt.Sprintf("enterprise IE %s has type %d, below the vendor-specific range", codec.Name, codec.Type))
+	}
+
+	enterpriseIERegistryMu.Lock()
+	defer enterpriseIERegistryMu.Unlock()
+
+	key := enterpriseIEKey{codec.EnterpriseID, codec.Type}
+	if existing, ok := enterpriseIERegistry[key]; ok {
+		panic(fmt.Sprintf("enterprise IE %d/%d registered twice, as %s and %s",
+			codec.EnterpriseID, codec.Type, existing.Name, codec.Name))
+	}
+	enterpriseIERegistry[key] = codec
+}
+
+func LookupEnterpriseIE(enterpriseID, ieType uint16) (*EnterpriseIE, bool) {
+	enterpriseIERegistryMu.RLock()
+	defer enterpriseIERegistryMu.RUnlock()
+
+	codec, ok := enterpriseIERegistry[enterpriseIEKey{enterpriseID, ieType}]
+	return codec, ok
+}
+
+// DecodeEnterpriseIE decodes a vendor-specific IE with its registered codec.
+// The error wraps ErrUnknownEnterpriseIE if there is none.
+func DecodeEnterpriseIE(ie *IE) (any, error) {
+	codec, ok := LookupEnterpriseIE(ie.EnterpriseID, ie.Type)
+	if !ok {
+		return nil, fmt.Errorf("%w %d/%d", ErrUnknownEnterpriseIE, ie.EnterpriseID, ie.Type)
+	}
+
+	value, err := codec.Decode(ie)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", codec.Name, err)
+	}
+	return value, nil
+}
+
+// IsEnterprise reports whether the IE is vendor-specific and so carries an
+// enterprise ID.
+func (ie *IE) IsEnterprise() bool {
+	return ie.Type >= 32768
+}
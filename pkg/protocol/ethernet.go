@@ -0,0 +1,439 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// MAC address IE flags.
+const (
+	macFlagSOUR uint8 = 0x01
+	macFlagDEST uint8 = 0x02
+	macFlagUSOU uint8 = 0x04
+	macFlagUDES uint8 = 0x08
+)
+
+// C-TAG and S-TAG IE flags.
+const (
+	vlanFlagPCP uint8 = 0x01
+	vlanFlagDEI uint8 = 0x02
+	vlanFlagVID uint8 = 0x04
+)
+
+// ethernetFilterBIDE is the Ethernet Filter Properties flag for filters
+// that apply in both directions.
+const ethernetFilterBIDE uint8 = 0x01
+
+// An Ethernet Packet Filter carries up to 16 MAC address IEs.
+const maxEthernetFilterMACs = 16
+
+// EtherTypes of the VLAN tags Ethernet Packet Filters match.
+const (
+	EtherTypeCTAG uint16 = 0x8100
+	EtherTypeSTAG uint16 = 0x88a8
+)
+
+// MACAddress is a MAC address IE. Each address is nil when absent; the upper
+// addresses turn Source and Destination into inclusive ranges.
+type MACAddress struct {
+	Source           net.HardwareAddr
+	Destination      net.HardwareAddr
+	UpperSource      net.HardwareAddr
+	UpperDestination net.HardwareAddr
+}
+
+// VLANTag is a C-TAG or S-TAG IE. Only the fields flagged as present are
+// matched.
+type VLANTag struct {
+	PCP    uint8
+	HasPCP bool
+	DEI    bool
+	HasDEI bool
+	VID    uint16
+	HasVID bool
+}
+
+func (t *VLANTag) String() string {
+	s := "any"
+	if t.HasVID {
+		s = fmt.Sprintf("%d", t.VID)
+	}
+	if t.HasPCP {
+		s += fmt.Sprintf(" pcp %d", t.PCP)
+	}
+	if t.HasDEI {
+		s += fmt.Sprintf(" dei %t", t.DEI)
+	}
+	return s
+}
+
+// EthernetPacketFilter matches Ethernet frames on their MAC addresses, VLAN
+// tags and EtherType. A frame matches if it matches any of MACAddresses, or
+// there are none, and every other field that is set.
+type EthernetPacketFilter struct {
+	FilterID    uint32
+	HasFilterID bool
+	// Bidirectional also matches frames with the source and destination
+	// MAC addresses swapped.
+	Bidirectional bool
+	MACAddresses  []*MACAddress
+	// EtherType is the EtherType after the VLAN tags, or 0 for any.
+	EtherType uint16
+	CTag      *VLANTag
+	STag      *VLANTag
+}
+
+func (f *EthernetPacketFilter) String() string {
+	var b bytes.Buffer
+	for _, mac := range f.MACAddresses {
+		if mac.Source != nil {
+			fmt.Fprintf(&b, "src %s ", mac.Source)
+		}
+		if mac.Destination != nil {
+			fmt.Fprintf(&b, "dst %s ", mac.Destination)
+		}
+	}
+	if f.STag != nil {
+		fmt.Fprintf(&b, "s-tag %s ", f.STag)
+	}
+	if f.CTag != nil {
+		fmt.Fprintf(&b, "c-tag %s ", f.CTag)
+	}
+	if f.EtherType != 0 {
+		fmt.Fprintf(&b, "ethertype 0x%04x ", f.EtherType)
+	}
+	if f.Bidirectional {
+		b.WriteString("bidirectional ")
+	}
+	if b.Len() == 0 {
+		return "any"
+	}
+	return string(bytes.TrimSuffix(b.Bytes(), []byte(" ")))
+}
+
+func NewMACAddressIE(m *MACAddress) (*IE, error) {
+	value := []byte{0}
+	for _, a := range []struct {
+		addr net.HardwareAddr
+		flag uint8
+	}{
+		{m.Source, macFlagSOUR},
+		{m.Destination, macFlagDEST},
+		{m.UpperSource, macFlagUSOU},
+		{m.UpperDestination, macFlagUDES},
+	} {
+		if a.addr == nil {
+			continue
+		}
+		if len(a.addr) != 6 {
+			return nil, fmt.Errorf("invalid MAC address %s", a.addr)
+		}
+		value[0] |= a.flag
+		value = append(value, a.addr...)
+	}
+
+	return &IE{
+		Type:  IETypeMACAddress,
+		Value: value,
+	}, nil
+}
+
+func (ie *IE) GetMACAddress() (*MACAddress, error) {
+	if ie.Type != IETypeMACAddress || len(ie.Value) < 1 {
+		return nil, fmt.Errorf("invalid MAC Address IE")
+	}
+
+	flags, rest := ie.Value[0], ie.Value[1:]
+	m := &MACAddress{}
+	for _, a := range []struct {
+		addr *net.HardwareAddr
+		flag uint8
+	}{
+		{&m.Source, macFlagSOUR},
+		{&m.Destination, macFlagDEST},
+		{&m.UpperSource, macFlagUSOU},
+		{&m.UpperDestination, macFlagUDES},
+	} {
+		if flags&a.flag == 0 {
+			continue
+		}
+		if len(rest) < 6 {
+			return nil, fmt.Errorf("invalid MAC Address IE: short address")
+		}
+		*a.addr = net.HardwareAddr(bytes.Clone(rest[:6]))
+		rest = rest[6:]
+	}
+
+	return m, nil
+}
+
+// NewVLANTagIE encodes a C-TAG or S-TAG IE, as ieType says.
+func NewVLANTagIE(ieType uint16, t *VLANTag) (*IE, error) {
+	if t.VID > 0x0fff {
+		return nil, fmt.Errorf("invalid VLAN ID %d", t.VID)
+	}
+	if t.PCP > 7 {
+		return nil, fmt.Errorf("invalid VLAN PCP %d", t.PCP)
+	}
+
+	value := make([]byte, 3)
+	if t.HasPCP {
+		value[0] |= vlanFlagPCP
+		value[1] |= t.PCP
+	}
+	if t.HasDEI {
+		value[0] |= vlanFlagDEI
+		if t.DEI {
+			value[1] |= 0x08
+		}
+	}
+	if t.HasVID {
+		value[0] |= vlanFlagVID
+		value[1] |= byte(t.VID>>8) << 4
+		value[2] = byte(t.VID)
+	}
+
+	return &IE{
+		Type:  ieType,
+		Value: value,
+	}, nil
+}
+
+func (ie *IE) GetVLANTag() (*VLANTag, error) {
+	if ie.Type != IETypeCTAG && ie.Type != IETypeSTAG || len(ie.Value) < 3 {
+		return nil, fmt.Errorf("invalid VLAN tag IE")
+	}
+
+	flags := ie.Value[0]
+	return &VLANTag{
+		PCP:    ie.Value[1] & 0x07,
+		HasPCP: flags&vlanFlagPCP != 0,
+		DEI:    ie.Value[1]&0x08 != 0,
+		HasDEI: flags&vlanFlagDEI != 0,
+		VID:    uint16(ie.Value[1]>>4)<<8 | uint16(ie.Value[2]),
+		HasVID: flags&vlanFlagVID != 0,
+	}, nil
+}
+
+func NewEthertypeIE(etherType uint16) *IE {
+	return &IE{
+		Type:  IETypeEthertype,
+		Value: binary.BigEndian.AppendUint16(nil, etherType),
+	}
+}
+
+func (ie *IE) GetEthertype() (uint16, error) {
+	if ie.Type != IETypeEthertype || len(ie.Value) < 2 {
+		return 0, fmt.Errorf("invalid Ethertype IE")
+	}
+	return binary.BigEndian.Uint16(ie.Value), nil
+}
+
+func NewEthernetFilterIDIE(id uint32) *IE {
+	return &IE{
+		Type:  IETypeEthernetFilterID,
+		Value: binary.BigEndian.AppendUint32(nil, id),
+	}
+}
+
+func (ie *IE) GetEthernetFilterID() (uint32, error) {
+	if ie.Type != IETypeEthernetFilterID || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid Ethernet Filter ID IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
+
+func NewEthernetPacketFilterIE(f *EthernetPacketFilter) (*IE, error) {
+	if len(f.MACAddresses) > maxEthernetFilterMACs {
+		return nil, fmt.Errorf("%d MAC addresses in Ethernet packet filter, at most %d allowed",
+			len(f.MACAddresses), maxEthernetFilterMACs)
+	}
+
+	var ies []*IE
+	if f.HasFilterID {
+		ies = append(ies, NewEthernetFilterIDIE(f.FilterID))
+	}
+	if f.Bidirectional {
+		ies = append(ies, &IE{Type: IETypeEthernetFilterProperties, Value: []byte{ethernetFilterBIDE}})
+	}
+	for _, mac := range f.MACAddresses {
+		ie, err := NewMACAddressIE(mac)
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+	if f.EtherType != 0 {
+		ies = append(ies, NewEthertypeIE(f.EtherType))
+	}
+	for _, tag := range []struct {
+		ieType uint16
+		tag    *VLANTag
+	}{
+		{IETypeCTAG, f.CTag},
+		{IETypeSTAG, f.STag},
+	} {
+		if tag.tag == nil {
+			continue
+		}
+		ie, err := NewVLANTagIE(tag.ieType, tag.tag)
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ie)
+	}
+
+	return NewGroupedIE(IETypeEthernetPacketFilter, ies)
+}
+
+func ParseEthernetPacketFilter(ie *IE) (*EthernetPacketFilter, error) {
+	if ie.Type != IETypeEthernetPacketFilter {
+		return nil, fmt.Errorf("invalid Ethernet Packet Filter IE")
+	}
+
+	ies, err := ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse Ethernet packet filter: %w", err)
+	}
+
+	f := &EthernetPacketFilter{}
+	for _, ie := range ies {
+		switch ie.Type {
+		case IETypeEthernetFilterID:
+			if f.FilterID, err = ie.GetEthernetFilterID(); err != nil {
+				return nil, err
+			}
+			f.HasFilterID = true
+		case IETypeEthernetFilterProperties:
+			f.Bidirectional = len(ie.Value) > 0 && ie.Value[0]&ethernetFilterBIDE != 0
+		case IETypeMACAddress:
+			mac, err := ie.GetMACAddress()
+			if err != nil {
+				return nil, err
+			}
+			f.MACAddresses = append(f.MACAddresses, mac)
+		case IETypeEthertype:
+			if f.EtherType, err = ie.GetEthertype(); err != nil {
+				return nil, err
+			}
+		case IETypeCTAG:
+			if f.CTag, err = ie.GetVLANTag(); err != nil {
+				return nil, err
+			}
+		case IETypeSTAG:
+			if f.STag, err = ie.GetVLANTag(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// EthernetHeaderMatch is a pattern on the leading bytes of an Ethernet
+// frame, which matches if every byte ANDed with Mask equals Value.
+type EthernetHeaderMatch struct {
+	Mask  []byte
+	Value []byte
+	// etherType is the offset of the EtherType behind the tags the
+	// pattern names.
+	etherType int
+}
+
+// Matches reports whether the frame starts with the pattern.
+func (m *EthernetHeaderMatch) Matches(frame []byte) bool {
+	if len(frame) < len(m.Mask) {
+		return false
+	}
+	for i, mask := range m.Mask {
+		if frame[i]&mask != m.Value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// HeaderMatches compiles the filter into header patterns, one per MAC
+// address IE and direction, as a classifier looking at fixed offsets would
+// match it. The tags the filter names fix the tag stack: none for an
+// untagged frame, a C-TAG, an S-TAG, or an S-TAG over a C-TAG, with the
+// EtherType after them. A filter naming no tag and no EtherType matches
+// tagged and untagged frames alike. MAC address ranges cannot be expressed
+// and are rejected.
+func (f *EthernetPacketFilter) HeaderMatches() ([]*EthernetHeaderMatch, error) {
+	base := &EthernetHeaderMatch{}
+	offset := 12
+
+	setTag := func(tpid uint16, t *VLANTag) {
+		base.set(offset, []byte{0xff, 0xff}, binary.BigEndian.AppendUint16(nil, tpid))
+
+		var mask, value uint16
+		if t.HasPCP {
+			mask |= 0xe000
+			value |= uint16(t.PCP) << 13
+		}
+		if t.HasDEI {
+			mask |= 0x1000
+			if t.DEI {
+				value |= 0x1000
+			}
+		}
+		if t.HasVID {
+			mask |= 0x0fff
+			value |= t.VID & 0x0fff
+		}
+		base.set(offset+2, binary.BigEndian.AppendUint16(nil, mask), binary.BigEndian.AppendUint16(nil, value))
+		offset += 4
+	}
+	if f.STag != nil {
+		setTag(EtherTypeSTAG, f.STag)
+	}
+	if f.CTag != nil {
+		setTag(EtherTypeCTAG, f.CTag)
+	}
+	base.etherType = offset
+	if f.EtherType != 0 {
+		base.set(offset, []byte{0xff, 0xff}, binary.BigEndian.AppendUint16(nil, f.EtherType))
+	}
+
+	if len(f.MACAddresses) == 0 {
+		return []*EthernetHeaderMatch{base}, nil
+	}
+
+	var matches []*EthernetHeaderMatch
+	for _, mac := range f.MACAddresses {
+		if mac.UpperSource != nil || mac.UpperDestination != nil {
+			return nil, fmt.Errorf("MAC address ranges are not supported")
+		}
+
+		directions := [][2]net.HardwareAddr{{mac.Destination, mac.Source}}
+		if f.Bidirectional {
+			directions = append(directions, [2]net.HardwareAddr{mac.Source, mac.Destination})
+		}
+		for _, d := range directions {
+			m := base.clone()
+			for i, addr := range d {
+				if addr != nil {
+					m.set(6*i, bytes.Repeat([]byte{0xff}, 6), addr)
+				}
+			}
+			matches = append(matches, m)
+		}
+	}
+	return matches, nil
+}
+
+func (m *EthernetHeaderMatch) set(offset int, mask, value []byte) {
+	if end := offset + len(mask); end > len(m.Mask) {
+		m.Mask = append(m.Mask, make([]byte, end-len(m.Mask))...)
+		m.Value = append(m.Value, make([]byte, end-len(m.Value))...)
+	}
+	copy(m.Mask[offset:], mask)
+	copy(m.Value[offset:], value)
+}
+
+func (m *EthernetHeaderMatch) clone() *EthernetHeaderMatch {
+	return &EthernetHeaderMatch{Mask: bytes.Clone(m.Mask), Value: bytes.Clone(m.Value), etherType: m.etherType}
+}
@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// FramedRoute is a subnet routed behind a UE, written as in RADIUS
+// Framed-Route (RFC 2865) and Framed-IPv6-Route (RFC 3162) attributes: the
+// prefix, the gateway and one or more metrics, e.g.
+// "192.0.2.0/24 0.0.0.0 1". An unspecified gateway is the UE itself.
+type FramedRoute struct {
+	Prefix  netip.Prefix
+	Gateway netip.Addr
+	Metrics []uint32
+}
+
+// ParseFramedRoute parses a Framed-Route or Framed-IPv6-Route. A prefix
+// without a length is a host route, and a missing gateway is the UE.
+func ParseFramedRoute(s string) (*FramedRoute, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty framed route")
+	}
+
+	r := &FramedRoute{}
+	if strings.Contains(fields[0], "/") {
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("framed route %q: %w", s, err)
+		}
+		r.Prefix = prefix.Masked()
+	} else {
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("framed route %q: %w", s, err)
+		}
+		r.Prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	r.Gateway = netip.IPv4Unspecified()
+	if r.Prefix.Addr().Is6() {
+		r.Gateway = netip.IPv6Unspecified()
+	}
+	if len(fields) > 1 {
+		gateway, err := netip.ParseAddr(fields[1])
+		if err != nil || gateway.Is6() != r.Prefix.Addr().Is6() {
+			return nil, fmt.Errorf("framed route %q: invalid gateway %q", s, fields[1])
+		}
+		r.Gateway = gateway
+	}
+
+	for _, field := range fields[min(len(fields), 2):] {
+		metric, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("framed route %q: invalid metric %q", s, field)
+		}
+		r.Metrics = append(r.Metrics, uint32(metric))
+	}
+	return r, nil
+}
+
+func (r *FramedRoute) String() string {
+	parts := []string{r.Prefix.String(), r.Gateway.String()}
+	for _, metric := range r.Metrics {
+		parts = append(parts, strconv.FormatUint(uint64(metric), 10))
+	}
+	return strings.Join(parts, " ")
+}
+
+// NewFramedRouteIE returns a Framed-Route IE, or a Framed-IPv6-Route IE for
+// an IPv6 route.
+func NewFramedRouteIE(r *FramedRoute) *IE {
+	ieType := IETypeFramedRoute
+	if r.Prefix.Addr().Is6() {
+		ieType = IETypeFramedIPv6Route
+	}
+	return &IE{
+		Type:  ieType,
+		Value: []byte(r.String()),
+	}
+}
+
+// GetFramedRoute parses a Framed-Route or Framed-IPv6-Route IE.
+func (ie *IE) GetFramedRoute() (*FramedRoute, error) {
+	if ie.Type != IETypeFramedRoute && ie.Type != IETypeFramedIPv6Route {
+		return nil, fmt.Errorf("invalid Framed-Route IE")
+	}
+	r, err := ParseFramedRoute(string(ie.Value))
+	if err != nil {
+		return nil, err
+	}
+	if r.Prefix.Addr().Is6() != (ie.Type == IETypeFramedIPv6Route) {
+		return nil, fmt.Errorf("framed route %s in IE of the other address family", r.Prefix)
+	}
+	return r, nil
+}
+
+func NewFramedRoutingIE(routing uint32) *IE {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, routing)
+	return &IE{
+		Type:  IETypeFramedRouting,
+		Value: value,
+	}
+}
+
+func (ie *IE) GetFramedRouting() (uint32, error) {
+	if ie.Type != IETypeFramedRouting || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid Framed-Routing IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
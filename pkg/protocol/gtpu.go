@@ -0,0 +1,301 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// F-TEID IE flags.
+const (
+	fteidFlagV4   uint8 = 0x01
+	fteidFlagV6   uint8 = 0x02
+	fteidFlagCH   uint8 = 0x04
+	fteidFlagCHID uint8 = 0x08
+)
+
+// FTEID is a Fully qualified TEID. With Choose set the UP allocates the TEID
+// and address; IPv4 and IPv6 are then unspecified addresses marking the
+// families asked for, and PDRs of a session with the same ChooseID share one
+// F-TEID.
+type FTEID struct {
+	TEID        uint32
+	IPv4        net.IP
+	IPv6        net.IP
+	Choose      bool
+	ChooseID    uint8
+	HasChooseID bool
+}
+
+func (f *FTEID) String() string {
+	if f.Choose {
+		if f.HasChooseID {
+			return fmt.Sprintf("CHOOSE(id=%d)", f.ChooseID)
+		}
+		return "CHOOSE"
+	}
+	switch {
+	case f.IPv4 != nil && f.IPv6 != nil:
+		return fmt.Sprintf("0x%08x@%s,%s", f.TEID, f.IPv4, f.IPv6)
+	case f.IPv6 != nil:
+		return fmt.Sprintf("0x%08x@%s", f.TEID, f.IPv6)
+	default:
+		return fmt.Sprintf("0x%08x@%s", f.TEID, f.IPv4)
+	}
+}
+
+func NewFTEIDIE(f *FTEID) *IE {
+	value := []byte{0}
+
+	if f.IPv4 != nil {
+		value[0] |= fteidFlagV4
+	}
+	if f.IPv6 != nil {
+		value[0] |= fteidFlagV6
+	}
+
+	if f.Choose {
+		value[0] |= fteidFlagCH
+		if f.HasChooseID {
+			value[0] |= fteidFlagCHID
+			value = append(value, f.ChooseID)
+		}
+	} else {
+		value = binary.BigEndian.AppendUint32(value, f.TEID)
+		if ip4 := f.IPv4.To4(); ip4 != nil {
+			value = append(value, ip4...)
+		}
+		if f.IPv6 != nil {
+			value = append(value, f.IPv6.To16()...)
+		}
+	}
+
+	return &IE{
+		Type:  IETypeFTEID,
+		Value: value,
+	}
+}
+
+func (ie *IE) GetFTEID() (*FTEID, error) {
+	if ie.Type != IETypeFTEID || len(ie.Value) < 1 {
+		return nil, fmt.Errorf("invalid F-TEID IE")
+	}
+
+	flags := ie.Value[0]
+	f := &FTEID{}
+	offset := 1
+
+	if flags&fteidFlagCH != 0 {
+		f.Choose = true
+		if flags&fteidFlagV4 != 0 {
+			f.IPv4 = net.IPv4zero
+		}
+		if flags&fteidFlagV6 != 0 {
+			f.IPv6 = net.IPv6zero
+		}
+		if flags&fteidFlagCHID != 0 {
+			if len(ie.Value) < offset+1 {
+				return nil, fmt.Errorf("F-TEID missing Choose ID")
+			}
+			f.ChooseID = ie.Value[offset]
+			f.HasChooseID = true
+		}
+		return f, nil
+	}
+
+	if len(ie.Value) < offset+4 {
+		return nil, fmt.Errorf("F-TEID missing TEID")
+	}
+	f.TEID = binary.BigEndian.Uint32(ie.Value[offset : offset+4])
+	offset += 4
+
+	if flags&fteidFlagV4 != 0 {
+		if len(ie.Value) < offset+4 {
+			return nil, fmt.Errorf("F-TEID missing IPv4 address")
+		}
+		f.IPv4 = net.IP(append([]byte(nil), ie.Value[offset:offset+4]...))
+		offset += 4
+	}
+	if flags&fteidFlagV6 != 0 {
+		if len(ie.Value) < offset+16 {
+			return nil, fmt.Errorf("F-TEID missing IPv6 address")
+		}
+		f.IPv6 = net.IP(append([]byte(nil), ie.Value[offset:offset+16]...))
+	}
+	if f.IPv4 == nil && f.IPv6 == nil {
+		return nil, fmt.Errorf("F-TEID has no address")
+	}
+
+	return f, nil
+}
+
+// OuterHeaderCreation is the Outer Header Creation IE of a FAR. TEID is set
+// for GTP-U descriptions and Port for UDP ones.
+type OuterHeaderCreation struct {
+	Description uint16
+	TEID        uint32
+	IPv4        net.IP
+	IPv6        net.IP
+	Port        uint16
+}
+
+func (o *OuterHeaderCreation) isGTPU() bool {
+	return o.Description&(OuterHeaderCreationGTPUUDPIPv4|OuterHeaderCreationGTPUUDPIPv6) != 0
+}
+
+func (o *OuterHeaderCreation) hasIPv4() bool {
+	return o.Description&(OuterHeaderCreationGTPUUDPIPv4|OuterHeaderCreationUDPIPv4|OuterHeaderCreationIPv4) != 0
+}
+
+func (o *OuterHeaderCreation) hasIPv6() bool {
+	return o.Description&(OuterHeaderCreationGTPUUDPIPv6|OuterHeaderCreationUDPIPv6|OuterHeaderCreationIPv6) != 0
+}
+
+func (o *OuterHeaderCreation) hasPort() bool {
+	return o.Description&(OuterHeaderCreationUDPIPv4|OuterHeaderCreationUDPIPv6) != 0
+}
+
+func NewOuterHeaderCreationIE(o *OuterHeaderCreation) (*IE, error) {
+	value := []byte{byte(o.Description), byte(o.Description >> 8)}
+
+	if o.isGTPU() {
+		value = binary.BigEndian.AppendUint32(value, o.TEID)
+	}
+	if o.hasIPv4() {
+		ip4 := o.IPv4.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("outer header creation 0x%04x needs an IPv4 address", o.Description)
+		}
+		value = append(value, ip4...)
+	}
+	if o.hasIPv6() {
+		if o.IPv6 == nil || o.IPv6.To4() != nil {
+			return nil, fmt.Errorf("outer header creation 0x%04x needs an IPv6 address", o.Description)
+		}
+		value = append(value, o.IPv6.To16()...)
+	}
+	if o.hasPort() {
+		value = binary.BigEndian.AppendUint16(value, o.Port)
+	}
+
+	return &IE{
+		Type:  IETypeOuterHeaderCreation,
+		Value: value,
+	}, nil
+}
+
+func (ie *IE) GetOuterHeaderCreation() (*OuterHeaderCreation, error) {
+	if ie.Type != IETypeOuterHeaderCreation || len(ie.Value) < 2 {
+		return nil, fmt.Errorf("invalid Outer Header Creation IE")
+	}
+
+	o := &OuterHeaderCreation{Description: uint16(ie.Value[0]) | uint16(ie.Value[1])<<8}
+	offset := 2
+
+	if o.isGTPU() {
+		if len(ie.Value) < offset+4 {
+			return nil, fmt.Errorf("Outer Header Creation missing TEID")
+		}
+		o.TEID = binary.BigEndian.Uint32(ie.Value[offset : offset+4])
+		offset += 4
+	}
+	if o.hasIPv4() {
+		if len(ie.Value) < offset+4 {
+			return nil, fmt.Errorf("Outer Header Creation missing IPv4 address")
+		}
+		o.IPv4 = net.IP(append([]byte(nil), ie.Value[offset:offset+4]...))
+		offset += 4
+	}
+	if o.hasIPv6() {
+		if len(ie.Value) < offset+16 {
+			return nil, fmt.Errorf("Outer Header Creation missing IPv6 address")
+		}
+		o.IPv6 = net.IP(append([]byte(nil), ie.Value[offset:offset+16]...))
+		offset += 16
+	}
+	if o.hasPort() {
+		if len(ie.Value) < offset+2 {
+			return nil, fmt.Errorf("Outer Header Creation missing port")
+		}
+		o.Port = binary.BigEndian.Uint16(ie.Value[offset : offset+2])
+	}
+
+	return o, nil
+}
+
+func NewOuterHeaderRemovalIE(description uint8) *IE {
+	return &IE{
+		Type:  IETypeOuterHeaderRemoval,
+		Value: []byte{description},
+	}
+}
+
+func (ie *IE) GetOuterHeaderRemoval() (uint8, error) {
+	if ie.Type != IETypeOuterHeaderRemoval || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Outer Header Removal IE")
+	}
+	return ie.Value[0], nil
+}
+
+// CreatedPDR is what the UP allocated for a PDR that asked it to CHOOSE: the
+// local F-TEID, the UE IP address, or both.
+type CreatedPDR struct {
+	PDRID       uint16
+	LocalFTEID  *FTEID
+	UEIPAddress *UEIPAddress
+}
+
+// NewCreatedPDRIE reports what the UP allocated for a PDR.
+func NewCreatedPDRIE(c *CreatedPDR) (*IE, error) {
+	ies := []*IE{NewPDR_ID_IE(c.PDRID)}
+	if c.LocalFTEID != nil {
+		ies = append(ies, NewFTEIDIE(c.LocalFTEID))
+	}
+	if c.UEIPAddress != nil {
+		ueIP, err := NewUEIPAddressIE(c.UEIPAddress)
+		if err != nil {
+			return nil, err
+		}
+		ies = append(ies, ueIP)
+	}
+	return NewGroupedIE(IETypeCreatedPDR, ies)
+}
+
+// ParseCreatedPDR returns what the UP allocated for a PDR from a Created PDR
+// IE.
+func ParseCreatedPDR(ie *IE) (*CreatedPDR, error) {
+	if ie.Type != IETypeCreatedPDR {
+		return nil, fmt.Errorf("invalid Created PDR IE")
+	}
+
+	children, err := ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse Created PDR: %w", err)
+	}
+
+	c := &CreatedPDR{}
+	hasPDRID := false
+	for _, child := range children {
+		switch child.Type {
+		case IETypePDR_ID:
+			if c.PDRID, err = child.GetPDR_ID(); err != nil {
+				return nil, err
+			}
+			hasPDRID = true
+		case IETypeFTEID:
+			if c.LocalFTEID, err = child.GetFTEID(); err != nil {
+				return nil, err
+			}
+		case IETypeUE_IPAddress:
+			if c.UEIPAddress, err = child.GetUEIPAddress(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if !hasPDRID {
+		return nil, fmt.Errorf("Created PDR missing PDR ID")
+	}
+
+	return c, nil
+}
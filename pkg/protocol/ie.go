@@ -70,6 +70,22 @@ func NewCauseIE(cause uint8) *IE {
 	}
 }
 
+// NewOffendingIEIE names the type of the IE a request was rejected for.
+func NewOffendingIEIE(ieType uint16) *IE {
+	return &IE{
+		Type:  IETypeOffendingIE,
+		Value: binary.BigEndian.AppendUint16(nil, ieType),
+	}
+}
+
+// NewNetworkInstanceIE encodes a Network Instance as its name's octets.
+func NewNetworkInstanceIE(name string) *IE {
+	return &IE{
+		Type:  IETypeNetworkInstance,
+		Value: []byte(name),
+	}
+}
+
 func NewNodeIDIE(nodeID []byte) *IE {
 	value := make([]byte, 1+len(nodeID))
 	value[0] = 0
@@ -177,6 +193,24 @@ func NewUE_IPAddressIE(ip net.IP, isV6 bool) *IE {
 	}
 }
 
+func NewFSEIDIE(seid uint64, ip net.IP) *IE {
+	value := make([]byte, 9)
+	binary.BigEndian.PutUint64(value[1:], seid)
+
+	if ip4 := ip.To4(); ip4 != nil {
+		value[0] |= 0x02
+		value = append(value, ip4...)
+	} else if ip != nil {
+		value[0] |= 0x01
+		value = append(value, ip.To16()...)
+	}
+
+	return &IE{
+		Type:  IETypeFSEID,
+		Value: value,
+	}
+}
+
 func NewSDFFilterIE(flowDescription string) *IE {
 	flags := uint8(0x01) // FD flag - Flow Description present
 	fdBytes := []byte(flowDescription)
@@ -241,6 +275,13 @@ func (ie *IE) GetCause() (uint8, error) {
 	return ie.Value[0], nil
 }
 
+func (ie *IE) GetOffendingIE() (uint16, error) {
+	if ie.Type != IETypeOffendingIE || len(ie.Value) < 2 {
+		return 0, fmt.Errorf("invalid Offending IE IE")
+	}
+	return binary.BigEndian.Uint16(ie.Value), nil
+}
+
 func (ie *IE) GetPDR_ID() (uint16, error) {
 	if ie.Type != IETypePDR_ID || len(ie.Value) < 2 {
 		return 0, fmt.Errorf("invalid PDR_ID IE")
@@ -254,3 +295,127 @@ func (ie *IE) GetFAR_ID() (uint32, error) {
 	}
 	return binary.BigEndian.Uint32(ie.Value), nil
 }
+
+func (ie *IE) GetFSEID() (uint64, net.IP, error) {
+	if ie.Type != IETypeFSEID || len(ie.Value) < 9 {
+		return 0, nil, fmt.Errorf("invalid F-SEID IE")
+	}
+
+	seid := binary.BigEndian.Uint64(ie.Value[1:9])
+	flags := ie.Value[0]
+
+	switch {
+	case flags&0x02 != 0 && len(ie.Value) >= 13:
+		return seid, net.IP(ie.Value[9:13]), nil
+	case flags&0x01 != 0 && len(ie.Value) >= 25:
+		return seid, net.IP(ie.Value[9:25]), nil
+	}
+
+	return seid, nil, nil
+}
+
+func (ie *IE) GetPrecedence() (uint32, error) {
+	if ie.Type != IETypePrecedence || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid Precedence IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
+
+func (ie *IE) GetQER_ID() (uint32, error) {
+	if ie.Type != IETypeQER_ID || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid QER_ID IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
+
+func (ie *IE) GetURR_ID() (uint32, error) {
+	if ie.Type != IETypeURR_ID || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid URR_ID IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
+
+func (ie *IE) GetUE_IPAddress() (net.IP, error) {
+	if ie.Type != IETypeUE_IPAddress || len(ie.Value) < 1 {
+		return nil, fmt.Errorf("invalid UE IP Address IE")
+	}
+
+	flags := ie.Value[0]
+	switch {
+	case flags&0x02 != 0 && len(ie.Value) >= 5:
+		return net.IP(ie.Value[1:5]), nil
+	case flags&0x01 != 0 && len(ie.Value) >= 17:
+		return net.IP(ie.Value[1:17]), nil
+	}
+
+	return nil, fmt.Errorf("invalid UE IP Address IE")
+}
+
+func NewGateStatusIE(status uint8) *IE {
+	return &IE{
+		Type:  IETypeGateStatus,
+		Value: []byte{status & 0x0f},
+	}
+}
+
+func (ie *IE) GetGateStatus() (uint8, error) {
+	if ie.Type != IETypeGateStatus || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Gate Status IE")
+	}
+	return ie.Value[0] & 0x0f, nil
+}
+
+func UplinkGate(status uint8) uint8 {
+	return (status >> 2) & 0x03
+}
+
+func DownlinkGate(status uint8) uint8 {
+	return status & 0x03
+}
+
+// NewMBRIE encodes uplink and downlink bit rates in kbps as 40-bit values.
+func NewMBRIE(ul, dl uint64) *IE {
+	return &IE{
+		Type:  IETypeMBR,
+		Value: encodeBitRates(ul, dl),
+	}
+}
+
+func NewGBRIE(ul, dl uint64) *IE {
+	return &IE{
+		Type:  IETypeGBR,
+		Value: encodeBitRates(ul, dl),
+	}
+}
+
+func (ie *IE) GetMBR() (ul, dl uint64, err error) {
+	if ie.Type != IETypeMBR || len(ie.Value) < 10 {
+		return 0, 0, fmt.Errorf("invalid MBR IE")
+	}
+	ul, dl = decodeBitRates(ie.Value)
+	return ul, dl, nil
+}
+
+func (ie *IE) GetGBR() (ul, dl uint64, err error) {
+	if ie.Type != IETypeGBR || len(ie.Value) < 10 {
+		return 0, 0, fmt.Errorf("invalid GBR IE")
+	}
+	ul, dl = decodeBitRates(ie.Value)
+	return ul, dl, nil
+}
+
+func encodeBitRates(ul, dl uint64) []byte {
+	value := make([]byte, 16)
+	binary.BigEndian.PutUint64(value[0:8], ul<<24)
+	binary.BigEndian.PutUint64(value[5:13], dl<<24)
+	return value[:10]
+}
+
+func decodeBitRates(value []byte) (ul, dl uint64) {
+	var buf [8]byte
+	copy(buf[3:], value[0:5])
+	ul = binary.BigEndian.Uint64(buf[:])
+	copy(buf[3:], value[5:10])
+	dl = binary.BigEndian.Uint64(buf[:])
+	return ul, dl
+}
@@ -1,4 +1,4 @@
-package vpp
+package protocol
 
 type L2Filter struct {
 	Name      string
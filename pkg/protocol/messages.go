@@ -1,7 +1,5 @@
 package protocol
 
-import "encoding/binary"
-
 type Message struct {
 	Header MessageHeader
 	IEs    []*IE
@@ -154,12 +152,15 @@ func NewAssociationReleaseResponse(seqNum uint32, nodeID []byte, cause uint8) *M
 	}
 }
 
-func NewSessionEstablishmentRequest(seqNum uint32, seid uint64, createPDRs, createFARs, createQERs, createURRs []*IE) *Message {
-	ies := make([]*IE, 0, len(createPDRs)+len(createFARs)+len(createQERs)+len(createURRs))
+func NewSessionEstablishmentRequest(seqNum uint32, seid uint64, nodeID []byte, cpSEID uint64, createPDRs, createFARs, createQERs, createURRs, createBAR []*IE) *Message {
+	ies := make([]*IE, 0, 2+len(createPDRs)+len(createFARs)+len(createQERs)+len(createURRs)+len(createBAR))
+	ies = append(ies, NewNodeIDIE(nodeID))
+	ies = append(ies, NewFSEIDIE(cpSEID, nil))
 	ies = append(ies, createPDRs...)
 	ies = append(ies, createFARs...)
 	ies = append(ies, createQERs...)
 	ies = append(ies, createURRs...)
+	ies = append(ies, createBAR...)
 
 	return &Message{
 		Header: MessageHeader{
@@ -173,28 +174,51 @@ func NewSessionEstablishmentRequest(seqNum uint32, seid uint64, createPDRs, crea
 	}
 }
 
-func NewSessionEstablishmentResponse(seqNum uint32, seid uint64, cause uint8, localSEID uint64) *Message {
-	fseidValue := make([]byte, 9)
-	fseidValue[0] = 0x02
-	binary.BigEndian.PutUint64(fseidValue[1:], localSEID)
+// NewSessionEstablishmentResponse carries the Created PDRs of an accepted
+// request, or the Offending IE of a rejected one, in ies.
+func NewSessionEstablishmentResponse(seqNum uint32, seid uint64, cause uint8, localSEID uint64, ies ...*IE) *Message {
+	return &Message{
+		Header: MessageHeader{
+			Version:        Version1,
+			MessageType:    MsgTypeSessionEstablishmentResponse,
+			SEIDPresent:    true,
+			SEID:           seid,
+			SequenceNumber: seqNum,
+		},
+		IEs: append([]*IE{
+			NewCauseIE(cause),
+			NewFSEIDIE(localSEID, nil),
+		}, ies...),
+	}
+}
 
-	fseidIE := &IE{
-		Type:  IETypePDI,
-		Value: fseidValue,
+func NewSessionModificationRequest(seqNum uint32, seid uint64, ies []*IE) *Message {
+	return &Message{
+		Header: MessageHeader{
+			Version:        Version1,
+			MessageType:    MsgTypeSessionModificationRequest,
+			SEIDPresent:    true,
+			SEID:           seid,
+			SequenceNumber: seqNum,
+		},
+		IEs: ies,
 	}
+}
 
+// NewSessionModificationResponse carries the Created PDRs of an accepted
+// request, or the Offending IE of a rejected one, in ies.
+func NewSessionModificationResponse(seqNum uint32, seid uint64, cause uint8, ies ...*IE) *Message {
 	return &Message{
 		Header: MessageHeader{
 			Version:        Version1,
-			MessageType:    MsgTypeSessionEstablishmentResponse,
+			MessageType:    MsgTypeSessionModificationResponse,
 			SEIDPresent:    true,
 			SEID:           seid,
 			SequenceNumber: seqNum,
 		},
-		IEs: []*IE{
+		IEs: append([]*IE{
 			NewCauseIE(cause),
-			fseidIE,
-		},
+		}, ies...),
 	}
 }
 
@@ -211,7 +235,7 @@ func NewSessionDeletionRequest(seqNum uint32, seid uint64) *Message {
 	}
 }
 
-func NewSessionDeletionResponse(seqNum uint32, seid uint64, cause uint8) *Message {
+func NewSessionDeletionResponse(seqNum uint32, seid uint64, cause uint8, usageReports ...*IE) *Message {
 	return &Message{
 		Header: MessageHeader{
 			Version:        Version1,
@@ -220,6 +244,36 @@ func NewSessionDeletionResponse(seqNum uint32, seid uint64, cause uint8) *Messag
 			SEID:           seid,
 			SequenceNumber: seqNum,
 		},
+		IEs: append([]*IE{
+			NewCauseIE(cause),
+		}, usageReports...),
+	}
+}
+
+func NewSessionReportRequest(seqNum uint32, seid uint64, reportType uint8, ies []*IE) *Message {
+	return &Message{
+		Header: MessageHeader{
+			Version:        Version1,
+			MessageType:    MsgTypeSessionReportRequest,
+			SEIDPresent:    true,
+			SEID:           seid,
+			SequenceNumber: seqNum,
+		},
+		IEs: append([]*IE{
+			NewReportTypeIE(reportType),
+		}, ies...),
+	}
+}
+
+func NewSessionReportResponse(seqNum uint32, seid uint64, cause uint8) *Message {
+	return &Message{
+		Header: MessageHeader{
+			Version:        Version1,
+			MessageType:    MsgTypeSessionReportResponse,
+			SEIDPresent:    true,
+			SEID:           seid,
+			SequenceNumber: seqNum,
+		},
 		IEs: []*IE{
 			NewCauseIE(cause),
 		},
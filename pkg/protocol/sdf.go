@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/veesix-networks/pfcp-go/pkg/ipfilter"
+)
+
+// SDF Filter IE flags.
+const (
+	sdfFlagFD   uint8 = 0x01
+	sdfFlagTTC  uint8 = 0x02
+	sdfFlagSPI  uint8 = 0x04
+	sdfFlagFL   uint8 = 0x08
+	sdfFlagBID  uint8 = 0x10
+	sdfFixedLen       = 2
+)
+
+// SDFFilter is a decoded SDF Filter IE. FlowDescription is nil when the IE
+// carries none.
+type SDFFilter struct {
+	FlowDescription *ipfilter.Rule
+	ToS             uint16
+	SPI             uint32
+	FlowLabel       uint32
+	FilterID        uint32
+}
+
+func (ie *IE) GetSDFFilter() (*SDFFilter, error) {
+	if ie.Type != IETypeSDFFilter {
+		return nil, fmt.Errorf("invalid SDF Filter IE")
+	}
+	return ParseSDFFilter(ie.Value)
+}
+
+// ParseSDFFilter decodes the value of an SDF Filter IE, parsing its Flow
+// Description as an IPFilterRule.
+func ParseSDFFilter(value []byte) (*SDFFilter, error) {
+	if len(value) < sdfFixedLen {
+		return nil, fmt.Errorf("SDF filter too short: %d bytes", len(value))
+	}
+
+	flags := value[0]
+	offset := sdfFixedLen
+	sdf := &SDFFilter{}
+
+	if flags&sdfFlagFD != 0 {
+		if len(value) < offset+2 {
+			return nil, fmt.Errorf("SDF filter missing Flow Description length")
+		}
+		fdLen := int(binary.BigEndian.Uint16(value[offset : offset+2]))
+		offset += 2
+
+		if len(value) < offset+fdLen {
+			return nil, fmt.Errorf("SDF filter Flow Description truncated")
+		}
+		rule, err := ipfilter.Parse(string(value[offset : offset+fdLen]))
+		if err != nil {
+			return nil, err
+		}
+		sdf.FlowDescription = rule
+		offset += fdLen
+	}
+
+	if flags&sdfFlagTTC != 0 {
+		if len(value) < offset+2 {
+			return nil, fmt.Errorf("SDF filter missing ToS/Traffic Class")
+		}
+		sdf.ToS = binary.BigEndian.Uint16(value[offset : offset+2])
+		offset += 2
+	}
+
+	if flags&sdfFlagSPI != 0 {
+		if len(value) < offset+4 {
+			return nil, fmt.Errorf("SDF filter missing SPI")
+		}
+		sdf.SPI = binary.BigEndian.Uint32(value[offset : offset+4])
+		offset += 4
+	}
+
+	if flags&sdfFlagFL != 0 {
+		if len(value) < offset+3 {
+			return nil, fmt.Errorf("SDF filter missing Flow Label")
+		}
+		sdf.FlowLabel = (uint32(value[offset])<<16 | uint32(value[offset+1])<<8 | uint32(value[offset+2])) & 0x000FFFFF
+		offset += 3
+	}
+
+	if flags&sdfFlagBID != 0 {
+		if len(value) < offset+4 {
+			return nil, fmt.Errorf("SDF filter missing Filter ID")
+		}
+		sdf.FilterID = binary.BigEndian.Uint32(value[offset : offset+4])
+	}
+
+	return sdf, nil
+}
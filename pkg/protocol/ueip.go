@@ -0,0 +1,132 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// UE IP Address flags (TS 29.244 8.2.62).
+const (
+	ueIPFlagV6    uint8 = 0x01
+	ueIPFlagV4    uint8 = 0x02
+	ueIPFlagIPv6D uint8 = 0x08
+	ueIPFlagCHV4  uint8 = 0x10
+	ueIPFlagCHV6  uint8 = 0x20
+	ueIPFlagIP6PL uint8 = 0x40
+)
+
+// UEIPAddress is a UE IP Address IE. IPv6PrefixLength, if set, makes IPv6
+// the UE's prefix of that length rather than a single address. ChooseV4 and
+// ChooseV6 ask the UP to allocate an address of the family (CHV4, CHV6)
+// instead of carrying one.
+type UEIPAddress struct {
+	IPv4             net.IP
+	IPv6             net.IP
+	IPv6PrefixLength uint8
+	ChooseV4         bool
+	ChooseV6         bool
+}
+
+func (u *UEIPAddress) String() string {
+	var parts []string
+	if u.ChooseV4 {
+		parts = append(parts, "CHOOSE(v4)")
+	}
+	if u.ChooseV6 {
+		parts = append(parts, "CHOOSE(v6)")
+	}
+	if u.IPv4 != nil {
+		parts = append(parts, u.IPv4.String())
+	}
+	if u.IPv6 != nil {
+		if u.IPv6PrefixLength != 0 {
+			parts = append(parts, fmt.Sprintf("%s/%d", u.IPv6, u.IPv6PrefixLength))
+		} else {
+			parts = append(parts, u.IPv6.String())
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func NewUEIPAddressIE(u *UEIPAddress) (*IE, error) {
+	var flags uint8
+	var addrs []byte
+
+	if u.ChooseV4 {
+		flags |= ueIPFlagCHV4
+	}
+	if u.ChooseV6 {
+		flags |= ueIPFlagCHV6
+	}
+	if u.IPv4 != nil {
+		ip4 := u.IPv4.To4()
+		if ip4 == nil {
+			return nil, fmt.Errorf("UE IPv4 address %s is not IPv4", u.IPv4)
+		}
+		flags |= ueIPFlagV4
+		addrs = append(addrs, ip4...)
+	}
+	if u.IPv6 != nil {
+		if u.IPv6.To4() != nil {
+			return nil, fmt.Errorf("UE IPv6 address %s is not IPv6", u.IPv6)
+		}
+		flags |= ueIPFlagV6
+		addrs = append(addrs, u.IPv6.To16()...)
+	}
+	if u.IPv6PrefixLength != 0 {
+		if u.IPv6 == nil || u.IPv6PrefixLength > 128 {
+			return nil, fmt.Errorf("invalid UE IPv6 prefix length %d", u.IPv6PrefixLength)
+		}
+		flags |= ueIPFlagIP6PL
+		addrs = append(addrs, u.IPv6PrefixLength)
+	}
+
+	return &IE{
+		Type:  IETypeUE_IPAddress,
+		Value: append([]byte{flags}, addrs...),
+	}, nil
+}
+
+func (ie *IE) GetUEIPAddress() (*UEIPAddress, error) {
+	if ie.Type != IETypeUE_IPAddress || len(ie.Value) < 1 {
+		return nil, fmt.Errorf("invalid UE IP Address IE")
+	}
+
+	flags := ie.Value[0]
+	u := &UEIPAddress{
+		ChooseV4: flags&ueIPFlagCHV4 != 0,
+		ChooseV6: flags&ueIPFlagCHV6 != 0,
+	}
+	offset := 1
+
+	if flags&ueIPFlagV4 != 0 {
+		if len(ie.Value) < offset+4 {
+			return nil, fmt.Errorf("UE IP Address missing IPv4 address")
+		}
+		u.IPv4 = net.IP(append([]byte(nil), ie.Value[offset:offset+4]...))
+		offset += 4
+	}
+	if flags&ueIPFlagV6 != 0 {
+		if len(ie.Value) < offset+16 {
+			return nil, fmt.Errorf("UE IP Address missing IPv6 address")
+		}
+		u.IPv6 = net.IP(append([]byte(nil), ie.Value[offset:offset+16]...))
+		offset += 16
+	}
+	// The IPv6 Prefix Delegation Bits are not used.
+	if flags&ueIPFlagIPv6D != 0 {
+		offset++
+	}
+	if flags&ueIPFlagIP6PL != 0 {
+		if len(ie.Value) < offset+1 {
+			return nil, fmt.Errorf("UE IP Address missing IPv6 prefix length")
+		}
+		u.IPv6PrefixLength = ie.Value[offset]
+		if u.IPv6PrefixLength > 128 {
+			return nil, fmt.Errorf("invalid UE IPv6 prefix length %d", u.IPv6PrefixLength)
+		}
+	}
+
+	return u, nil
+}
@@ -0,0 +1,239 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Seconds between the NTP epoch (1900) used by PFCP timestamps and the Unix
+// epoch.
+const ntpEpochOffset = 2208988800
+
+// Volume Threshold and Volume Measurement IE flags. The packet counts only
+// exist in Volume Measurement.
+const (
+	volumeFlagTotal           uint8 = 0x01
+	volumeFlagUplink          uint8 = 0x02
+	volumeFlagDownlink        uint8 = 0x04
+	volumeFlagTotalPackets    uint8 = 0x08
+	volumeFlagUplinkPackets   uint8 = 0x10
+	volumeFlagDownlinkPackets uint8 = 0x20
+)
+
+func NewMeasurementMethodIE(method uint8) *IE {
+	return &IE{
+		Type:  IETypeMeasurementMethod,
+		Value: []byte{method},
+	}
+}
+
+func (ie *IE) GetMeasurementMethod() (uint8, error) {
+	if ie.Type != IETypeMeasurementMethod || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Measurement Method IE")
+	}
+	return ie.Value[0], nil
+}
+
+func NewReportingTriggersIE(triggers uint32) *IE {
+	return &IE{
+		Type:  IETypeReportingTriggers,
+		Value: encodeTriggers(triggers),
+	}
+}
+
+func (ie *IE) GetReportingTriggers() (uint32, error) {
+	if ie.Type != IETypeReportingTriggers || len(ie.Value) < 2 {
+		return 0, fmt.Errorf("invalid Reporting Triggers IE")
+	}
+	return decodeTriggers(ie.Value), nil
+}
+
+// encodeTriggers lays out trigger flags as octets 5 and 6 of the Reporting
+// Triggers and Usage Report Trigger IEs.
+func encodeTriggers(triggers uint32) []byte {
+	return []byte{byte(triggers), byte(triggers >> 8)}
+}
+
+func decodeTriggers(value []byte) uint32 {
+	return uint32(value[0]) | uint32(value[1])<<8
+}
+
+// NewVolumeThresholdIE sets a threshold on the total volume in bytes.
+func NewVolumeThresholdIE(total uint64) *IE {
+	value := make([]byte, 9)
+	value[0] = volumeFlagTotal
+	binary.BigEndian.PutUint64(value[1:], total)
+	return &IE{
+		Type:  IETypeVolumeThreshold,
+		Value: value,
+	}
+}
+
+// GetVolumeThreshold returns the total volume threshold, or 0 if the IE only
+// sets uplink or downlink thresholds.
+func (ie *IE) GetVolumeThreshold() (uint64, error) {
+	if ie.Type != IETypeVolumeThreshold || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Volume Threshold IE")
+	}
+	if ie.Value[0]&volumeFlagTotal == 0 {
+		return 0, nil
+	}
+	if len(ie.Value) < 9 {
+		return 0, fmt.Errorf("invalid Volume Threshold IE")
+	}
+	return binary.BigEndian.Uint64(ie.Value[1:9]), nil
+}
+
+func NewTimeThresholdIE(seconds uint32) *IE {
+	return newUint32IE(IETypeTimeThreshold, seconds)
+}
+
+func (ie *IE) GetTimeThreshold() (uint32, error) {
+	if ie.Type != IETypeTimeThreshold || len(ie.Value) < 4 {
+		return 0, fmt.Errorf("invalid Time Threshold IE")
+	}
+	return binary.BigEndian.Uint32(ie.Value), nil
+}
+
+func NewReportTypeIE(reportType uint8) *IE {
+	return &IE{
+		Type:  IETypeReportType,
+		Value: []byte{reportType},
+	}
+}
+
+func (ie *IE) GetReportType() (uint8, error) {
+	if ie.Type != IETypeReportType || len(ie.Value) < 1 {
+		return 0, fmt.Errorf("invalid Report Type IE")
+	}
+	return ie.Value[0], nil
+}
+
+func newUint32IE(ieType uint16, v uint32) *IE {
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, v)
+	return &IE{
+		Type:  ieType,
+		Value: value,
+	}
+}
+
+func newTimeIE(ieType uint16, t time.Time) *IE {
+	return newUint32IE(ieType, uint32(t.Unix()+ntpEpochOffset))
+}
+
+func parseTime(value []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint32(value))-ntpEpochOffset, 0)
+}
+
+// UsageReport is the content of a Usage Report IE, which is carried in
+// Session Report Requests and in Session Modification and Deletion
+// Responses.
+type UsageReport struct {
+	URRID           uint32
+	SeqNum          uint32
+	Trigger         uint32
+	StartTime       time.Time
+	EndTime         time.Time
+	UplinkVolume    uint64
+	DownlinkVolume  uint64
+	UplinkPackets   uint64
+	DownlinkPackets uint64
+	Duration        uint32
+}
+
+func (r *UsageReport) TotalVolume() uint64 {
+	return r.UplinkVolume + r.DownlinkVolume
+}
+
+// NewUsageReportIE encodes the report as a grouped IE of ieType, one of the
+// IETypeUsageReport types.
+func NewUsageReportIE(ieType uint16, r *UsageReport) (*IE, error) {
+	volume := make([]byte, 1+3*8+3*8)
+	volume[0] = volumeFlagTotal | volumeFlagUplink | volumeFlagDownlink |
+		volumeFlagTotalPackets | volumeFlagUplinkPackets | volumeFlagDownlinkPackets
+	binary.BigEndian.PutUint64(volume[1:], r.TotalVolume())
+	binary.BigEndian.PutUint64(volume[9:], r.UplinkVolume)
+	binary.BigEndian.PutUint64(volume[17:], r.DownlinkVolume)
+	binary.BigEndian.PutUint64(volume[25:], r.UplinkPackets+r.DownlinkPackets)
+	binary.BigEndian.PutUint64(volume[33:], r.UplinkPackets)
+	binary.BigEndian.PutUint64(volume[41:], r.DownlinkPackets)
+
+	children := []*IE{
+		NewURR_ID_IE(r.URRID),
+		newUint32IE(IETypeUR_SEQN, r.SeqNum),
+		{Type: IETypeUsageReportTrigger, Value: encodeTriggers(r.Trigger)},
+		newTimeIE(IETypeStartTime, r.StartTime),
+		newTimeIE(IETypeEndTime, r.EndTime),
+		{Type: IETypeVolumeMeasurement, Value: volume},
+		newUint32IE(IETypeDurationMeasurement, r.Duration),
+	}
+
+	return NewGroupedIE(ieType, children)
+}
+
+func ParseUsageReport(ie *IE) (*UsageReport, error) {
+	children, err := ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse Usage Report: %w", err)
+	}
+
+	r := &UsageReport{}
+	for _, child := range children {
+		switch child.Type {
+		case IETypeURR_ID:
+			r.URRID, _ = child.GetURR_ID()
+		case IETypeUR_SEQN:
+			if len(child.Value) >= 4 {
+				r.SeqNum = binary.BigEndian.Uint32(child.Value)
+			}
+		case IETypeUsageReportTrigger:
+			if len(child.Value) >= 2 {
+				r.Trigger = decodeTriggers(child.Value)
+			}
+		case IETypeStartTime:
+			if len(child.Value) >= 4 {
+				r.StartTime = parseTime(child.Value)
+			}
+		case IETypeEndTime:
+			if len(child.Value) >= 4 {
+				r.EndTime = parseTime(child.Value)
+			}
+		case IETypeDurationMeasurement:
+			if len(child.Value) >= 4 {
+				r.Duration = binary.BigEndian.Uint32(child.Value)
+			}
+		case IETypeVolumeMeasurement:
+			parseVolumeMeasurement(child.Value, r)
+		}
+	}
+
+	return r, nil
+}
+
+// parseVolumeMeasurement reads the fields present according to the flags,
+// in the order TS 29.244 lays them out.
+func parseVolumeMeasurement(value []byte, r *UsageReport) {
+	if len(value) < 1 {
+		return
+	}
+
+	flags := value[0]
+	offset := 1
+	next := func(flag uint8) (uint64, bool) {
+		if flags&flag == 0 || len(value) < offset+8 {
+			return 0, false
+		}
+		v := binary.BigEndian.Uint64(value[offset:])
+		offset += 8
+		return v, true
+	}
+
+	next(volumeFlagTotal)
+	r.UplinkVolume, _ = next(volumeFlagUplink)
+	r.DownlinkVolume, _ = next(volumeFlagDownlink)
+	next(volumeFlagTotalPackets)
+	r.UplinkPackets, _ = next(volumeFlagUplinkPackets)
+	r.DownlinkPackets, _ = next(volumeFlagDownlinkPackets)
+}
@@ -0,0 +1,181 @@
+package up
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+// defaultBufferedPackets is how many packets a FAR buffers when no BAR
+// suggests a count.
+const defaultBufferedPackets = 64
+
+// farBuffer holds the packets of a FAR with the BUFF action until the CP
+// updates it to forward or drop them.
+type farBuffer struct {
+	packets []*PuntedPacket
+	dropped uint64
+	// notified is set once a Downlink Data Report is under way.
+	notified bool
+}
+
+// bar returns the BAR the FAR buffers with, or nil.
+func (s *Session) bar(far *FAR) *BAR {
+	if s.BAR == nil || far.BAR_ID == nil || *far.BAR_ID != s.BAR.ID {
+		return nil
+	}
+	return s.BAR
+}
+
+// bufferPacket keeps a packet whose PDR's FAR buffers, returning whether it
+// did. The first packet buffered for a FAR that also notifies the CP raises
+// a Downlink Data Report, after the BAR's notification delay.
+func (up *UPFunction) bufferPacket(pkt *PuntedPacket) bool {
+	if pkt.LocalSEID == 0 {
+		return false
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+
+	session, ok := up.sessions[pkt.LocalSEID]
+	if !ok {
+		return false
+	}
+	pdr, ok := session.PDRs[pkt.PDRID]
+	if !ok {
+		return false
+	}
+	far, ok := session.FARs[pdr.FAR_ID]
+	if !ok || far.ApplyAction&protocol.ApplyActionBuffer == 0 {
+		return false
+	}
+
+	if session.buffers == nil {
+		session.buffers = make(map[uint32]*farBuffer)
+	}
+	buf, ok := session.buffers[far.ID]
+	if !ok {
+		buf = &farBuffer{}
+		session.buffers[far.ID] = buf
+	}
+
+	limit := defaultBufferedPackets
+	var delay time.Duration
+	if bar := session.bar(far); bar != nil {
+		if bar.SuggestedBufferingPacketsCount > 0 {
+			limit = int(bar.SuggestedBufferingPacketsCount)
+		}
+		delay = bar.DownlinkDataNotificationDelay
+	}
+
+	if len(buf.packets) >= limit {
+		buf.dropped++
+		return true
+	}
+	buf.packets = append(buf.packets, pkt)
+
+	if !buf.notified && far.ApplyAction&protocol.ApplyActionNotify != 0 {
+		buf.notified = true
+		up.wg.Add(1)
+		go up.reportDownlinkData(session.LocalSEID, far.ID, buf, delay)
+	}
+
+	return true
+}
+
+// reportDownlinkData sends a Downlink Data Report for the PDRs whose packets
+// are in the buffer, unless it was released in the meantime.
+func (up *UPFunction) reportDownlinkData(seid uint64, farID uint32, buf *farBuffer, delay time.Duration) {
+	defer up.wg.Done()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-up.ctx.Done():
+			return
+		case <-timer.C:
+		}
+	}
+
+	var remoteSEID uint64
+	var pdrIDs []uint16
+
+	up.mu.RLock()
+	if session, ok := up.sessions[seid]; ok && session.buffers[farID] == buf {
+		remoteSEID = session.RemoteSEID
+		for _, pkt := range buf.packets {
+			if !slices.Contains(pdrIDs, pkt.PDRID) {
+				pdrIDs = append(pdrIDs, pkt.PDRID)
+			}
+		}
+	}
+	up.mu.RUnlock()
+
+	if len(pdrIDs) == 0 {
+		return
+	}
+
+	ie, err := protocol.NewDownlinkDataReportIE(pdrIDs)
+	if err == nil {
+		err = up.sendSessionReport(remoteSEID, protocol.ReportTypeDownlinkData, []*protocol.IE{ie})
+	}
+	if err != nil {
+		fmt.Printf("Failed to send downlink data report for CP session %d: %v\n", remoteSEID, err)
+	}
+}
+
+// releaseBuffers empties the buffers of the FARs that no longer buffer. It
+// returns the packets of those that now forward, in FAR ID order; the others
+// are discarded.
+func (up *UPFunction) releaseBuffers(session *Session) []*PuntedPacket {
+	var released []*PuntedPacket
+	for _, farID := range slices.Sorted(maps.Keys(session.buffers)) {
+		far, ok := session.FARs[farID]
+		if ok && far.ApplyAction&protocol.ApplyActionBuffer != 0 {
+			continue
+		}
+
+		buf := session.buffers[farID]
+		delete(session.buffers, farID)
+
+		if buf.dropped > 0 {
+			fmt.Printf("Session %d FAR %d buffer overflowed, %d packets dropped\n", session.LocalSEID, farID, buf.dropped)
+		}
+		if ok && far.ApplyAction&protocol.ApplyActionForward != 0 {
+			released = append(released, buf.packets...)
+		}
+	}
+	return released
+}
+
+// replayBuffered hands released packets back to the dataplane, which now
+// forwards them.
+func (up *UPFunction) replayBuffered(seid uint64, packets []*PuntedPacket) {
+	if len(packets) == 0 {
+		return
+	}
+
+	replayer, ok := up.backend().(PacketReplayer)
+	if !ok {
+		fmt.Printf("Session %d: dataplane cannot replay buffered packets, %d discarded\n", seid, len(packets))
+		return
+	}
+
+	for _, pkt := range packets {
+		frame := pkt.Data
+		if pkt.IP {
+			frame = ethernetFrame(pkt.Data)
+		}
+		if err := replayer.ReplayPacket(pkt.SourceInterface, frame); err != nil {
+			fmt.Printf("Session %d: failed to replay buffered packet: %v\n", seid, err)
+		}
+	}
+
+	fmt.Printf("Session %d: released %d buffered packets\n", seid, len(packets))
+}
@@ -11,3 +11,32 @@ type Dataplane interface {
 	RemoveURR(seid uint64, urrID uint32) error
 	DeleteSession(seid uint64) error
 }
+
+// Reconciler is implemented by dataplanes that can outlive pfcp-up and need
+// to remove rules left over from a previous run, and by UE IP allocators that
+// hold on to the addresses of the previous run's sessions. Reconcile is called
+// once the CP has had ReconcileDelay to restore or re-establish its sessions.
+type Reconciler interface {
+	Reconcile() error
+}
+
+// UsageCounter is implemented by dataplanes that count the traffic matched by
+// each PDR. Counters are cumulative since the PDR was installed; a counter
+// that goes backwards is taken to have been reset.
+type UsageCounter interface {
+	PDRUsage(seid uint64, pdrID uint16) (packets, bytes uint64, err error)
+}
+
+// PacketReplayer is implemented by dataplanes that can process a frame as if
+// it had just been received on sourceInterface. The UP releases buffered
+// packets through it once their FAR forwards.
+type PacketReplayer interface {
+	ReplayPacket(sourceInterface uint8, frame []byte) error
+}
+
+// NetworkInstanceResolver is implemented by dataplanes that map Network
+// Instances to routing tables. The UP rejects PDRs and FARs that name one the
+// dataplane does not know; without a resolver any name is accepted.
+type NetworkInstanceResolver interface {
+	HasNetworkInstance(name string) bool
+}
@@ -0,0 +1,181 @@
+package up
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+// teidAllocator hands out local TEIDs and records the session that owns each
+// one, whether the UP chose it or the CP did. TEID 0 is never used.
+type teidAllocator struct {
+	mu        sync.Mutex
+	owner     map[uint32]uint64
+	bySession map[uint64]map[uint32]bool
+	next      uint32
+}
+
+func newTEIDAllocator() *teidAllocator {
+	return &teidAllocator{
+		owner:     make(map[uint32]uint64),
+		bySession: make(map[uint64]map[uint32]bool),
+		next:      1,
+	}
+}
+
+func (a *teidAllocator) allocate(seid uint64) (uint32, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.owner) == 1<<32-1 {
+		return 0, fmt.Errorf("no free TEIDs")
+	}
+
+	for {
+		teid := a.next
+		a.next++
+		if a.next == 0 {
+			a.next = 1
+		}
+		if _, used := a.owner[teid]; !used {
+			a.claim(seid, teid)
+			return teid, nil
+		}
+	}
+}
+
+// reserve claims a TEID the CP allocated.
+func (a *teidAllocator) reserve(seid uint64, teid uint32) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if teid == 0 {
+		return fmt.Errorf("TEID 0 is reserved")
+	}
+	if owner, used := a.owner[teid]; used && owner != seid {
+		return fmt.Errorf("TEID 0x%08x is in use by session %d", teid, owner)
+	}
+
+	a.claim(seid, teid)
+	return nil
+}
+
+func (a *teidAllocator) claim(seid uint64, teid uint32) {
+	a.owner[teid] = seid
+	if a.bySession[seid] == nil {
+		a.bySession[seid] = make(map[uint32]bool)
+	}
+	a.bySession[seid][teid] = true
+}
+
+// releaseUnused frees the session's TEIDs that are not in used.
+func (a *teidAllocator) releaseUnused(seid uint64, used map[uint32]bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for teid := range a.bySession[seid] {
+		if !used[teid] {
+			delete(a.owner, teid)
+			delete(a.bySession[seid], teid)
+		}
+	}
+	if len(a.bySession[seid]) == 0 {
+		delete(a.bySession, seid)
+	}
+}
+
+func (a *teidAllocator) releaseSession(seid uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for teid := range a.bySession[seid] {
+		delete(a.owner, teid)
+	}
+	delete(a.bySession, seid)
+}
+
+// assignFTEID resolves the PDR's local F-TEID before it is installed. A
+// CHOOSE request gets the F-TEID of a PDR with the same Choose ID, the
+// F-TEID the PDR already had, or a newly allocated one, and returns it for
+// the Created PDR IE. An F-TEID allocated by the CP is reserved as it is.
+func (up *UPFunction) assignFTEID(session *Session, pdr *PDR) (*protocol.FTEID, error) {
+	if pdr.PDI == nil || pdr.PDI.LocalFTEID == nil {
+		return nil, nil
+	}
+
+	req := pdr.PDI.LocalFTEID
+	if !req.Choose {
+		if err := up.teids.reserve(session.LocalSEID, req.TEID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	if session.chosen == nil {
+		session.chosen = make(map[uint8]*protocol.FTEID)
+	}
+
+	var fteid *protocol.FTEID
+	if req.HasChooseID {
+		fteid = session.chosen[req.ChooseID]
+	}
+	if old, ok := session.PDRs[pdr.ID]; fteid == nil && ok && old.PDI != nil && old.PDI.LocalFTEID != nil {
+		fteid = old.PDI.LocalFTEID
+	}
+	if fteid == nil {
+		var err error
+		if fteid, err = up.allocateFTEID(session.LocalSEID, req); err != nil {
+			return nil, err
+		}
+	}
+	if req.HasChooseID {
+		session.chosen[req.ChooseID] = fteid
+	}
+
+	pdr.PDI.LocalFTEID = fteid
+	return fteid, nil
+}
+
+func (up *UPFunction) allocateFTEID(seid uint64, req *protocol.FTEID) (*protocol.FTEID, error) {
+	if up.gtpuAddr == nil {
+		return nil, fmt.Errorf("no GTP-U address configured to CHOOSE an F-TEID")
+	}
+
+	isV6 := up.gtpuAddr.To4() == nil
+	if (req.IPv4 != nil || req.IPv6 != nil) && ((isV6 && req.IPv6 == nil) || (!isV6 && req.IPv4 == nil)) {
+		return nil, fmt.Errorf("no GTP-U address of the requested family")
+	}
+
+	teid, err := up.teids.allocate(seid)
+	if err != nil {
+		return nil, err
+	}
+
+	fteid := &protocol.FTEID{TEID: teid}
+	if isV6 {
+		fteid.IPv6 = up.gtpuAddr
+	} else {
+		fteid.IPv4 = up.gtpuAddr.To4()
+	}
+	return fteid, nil
+}
+
+// releaseFTEIDs frees the TEIDs and Choose IDs no PDR of the session uses
+// any more.
+func (up *UPFunction) releaseFTEIDs(session *Session) {
+	used := make(map[uint32]bool)
+	for _, pdr := range session.PDRs {
+		if pdr.PDI != nil && pdr.PDI.LocalFTEID != nil {
+			used[pdr.PDI.LocalFTEID.TEID] = true
+		}
+	}
+
+	for id, fteid := range session.chosen {
+		if !used[fteid.TEID] {
+			delete(session.chosen, id)
+		}
+	}
+
+	up.teids.releaseUnused(session.LocalSEID, used)
+}
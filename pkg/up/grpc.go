@@ -0,0 +1,92 @@
+package up
+
+import (
+	"context"
+
+	pb "github.com/veesix-networks/pfcp-go/api/pfcp/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type GRPCServer struct {
+	pb.UnimplementedUserPlaneServer
+	up *UPFunction
+}
+
+func NewGRPCServer(up *UPFunction) *GRPCServer {
+	return &GRPCServer{up: up}
+}
+
+func (s *GRPCServer) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.ListSessionsResponse, error) {
+	s.up.mu.RLock()
+	defer s.up.mu.RUnlock()
+
+	sessions := make([]*pb.UserPlaneSession, 0, len(s.up.sessions))
+	for _, session := range s.up.sessions {
+		sessions = append(sessions, &pb.UserPlaneSession{
+			LocalSeid:  session.LocalSEID,
+			RemoteSeid: session.RemoteSEID,
+			RuleHash:   session.RuleHash(),
+			PdrCount:   uint32(len(session.PDRs)),
+			FarCount:   uint32(len(session.FARs)),
+			QerCount:   uint32(len(session.QERs)),
+			UrrCount:   uint32(len(session.URRs)),
+			CreatedAt:  session.CreatedAt.Unix(),
+		})
+	}
+
+	return &pb.ListSessionsResponse{Sessions: sessions}, nil
+}
+
+func (s *GRPCServer) StreamPunts(req *pb.StreamPuntsRequest, stream pb.UserPlane_StreamPuntsServer) error {
+	punts, unsubscribe, err := s.up.subscribePunts()
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case pkt, ok := <-punts:
+			if !ok {
+				return status.Error(codes.Unavailable, "UP function stopped")
+			}
+			err := stream.Send(&pb.PuntedPacket{
+				LocalSeid:       pkt.LocalSEID,
+				RemoteSeid:      pkt.RemoteSEID,
+				PdrId:           uint32(pkt.PDRID),
+				SourceInterface: uint32(pkt.SourceInterface),
+				Interface:       pkt.Interface,
+				Packet:          pkt.Data,
+				Ip:              pkt.IP,
+				ReceivedAt:      pkt.ReceivedAt.UnixNano(),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *GRPCServer) InjectPacket(ctx context.Context, req *pb.InjectPacketRequest) (*pb.InjectPacketResponse, error) {
+	pkt := &InjectedPacket{
+		Interface: req.Interface,
+		Data:      req.Packet,
+		IP:        req.Ip,
+		SEID:      req.LocalSeid,
+	}
+	for _, vlan := range req.Vlans {
+		if vlan > maxVLANID {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid VLAN ID %d", vlan)
+		}
+		pkt.VLANs = append(pkt.VLANs, uint16(vlan))
+	}
+
+	err := s.up.injectPacket(pkt)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.InjectPacketResponse{}, nil
+}
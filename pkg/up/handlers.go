@@ -1,6 +1,8 @@
 package up
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"time"
 
@@ -10,83 +12,118 @@ import (
 func (up *UPFunction) handleSessionEstablishmentRequest(msg *protocol.Message, addr *net.UDPAddr) error {
 	seid := up.allocSEID()
 
+	remoteSEID := msg.Header.SEID
+	if fseidIE := msg.FindIE(protocol.IETypeFSEID); fseidIE != nil {
+		if cpSEID, _, err := fseidIE.GetFSEID(); err == nil {
+			remoteSEID = cpSEID
+		}
+	}
+
+	// CPs that leave out the Node ID are the one the UP is associated with.
+	up.mu.RLock()
+	cpNodeID := up.cpNodeID
+	up.mu.RUnlock()
+	if nodeIDIE := msg.FindIE(protocol.IETypeNodeID); nodeIDIE != nil && len(nodeIDIE.Value) > 0 {
+		cpNodeID = string(nodeIDIE.Value[1:])
+	}
+
 	session := &Session{
 		LocalSEID:  seid,
-		RemoteSEID: msg.Header.SEID,
+		RemoteSEID: remoteSEID,
+		CPNodeID:   cpNodeID,
 		PDRs:       make(map[uint16]*PDR),
 		FARs:       make(map[uint32]*FAR),
 		QERs:       make(map[uint32]*QER),
 		URRs:       make(map[uint32]*URR),
 		CreatedAt:  time.Now(),
+		usage:      make(map[uint32]*urrUsage),
+		chosen:     make(map[uint8]*protocol.FTEID),
 	}
 
-	createPDRs := msg.FindAllIEs(protocol.IETypeCreatePDR)
-	for _, createPDR := range createPDRs {
-		pdrIEs, err := protocol.ParseGroupedIE(createPDR.Value)
+	var createdPDRs []*protocol.IE
+	for _, createPDR := range msg.FindAllIEs(protocol.IETypeCreatePDR) {
+		pdr, err := parsePDR(createPDR)
 		if err != nil {
 			continue
 		}
 
-		var pdr PDR
-		pdr.PDI = &PDI{}
-
-		for _, ie := range pdrIEs {
-			switch ie.Type {
-			case protocol.IETypePDR_ID:
-				id, _ := ie.GetPDR_ID()
-				pdr.ID = id
-			case protocol.IETypePrecedence:
-				pdr.Precedence = 0
-			case protocol.IETypeFAR_ID:
-				farID, _ := ie.GetFAR_ID()
-				pdr.FAR_ID = farID
-			case protocol.IETypePDI:
-				pdiIEs, _ := protocol.ParseGroupedIE(ie.Value)
-				for _, pdiIE := range pdiIEs {
-					switch pdiIE.Type {
-					case protocol.IETypeSourceInterface:
-						if len(pdiIE.Value) > 0 {
-							pdr.PDI.SourceInterface = pdiIE.Value[0]
-						}
-					case protocol.IETypeSDFFilter:
-						pdr.PDI.SDFFilter = pdiIE.Value
-					case protocol.IETypeUE_IPAddress:
-						pdr.PDI.UE_IPAddress = string(pdiIE.Value)
-					case protocol.IETypeNetworkInstance:
-						pdr.PDI.NetworkInstance = string(pdiIE.Value)
-					case protocol.IETypeApplicationID:
-						pdr.PDI.ApplicationID = string(pdiIE.Value)
-					}
-				}
-			}
+		if err := up.checkNetworkInstance(pdr.PDI.NetworkInstance); err != nil {
+			fmt.Printf("Session %d PDR %d: %v\n", seid, pdr.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure,
+				protocol.NewOffendingIEIE(protocol.IETypeNetworkInstance))
+		}
+
+		created, err := up.assignPDR(session, pdr)
+		if err != nil {
+			fmt.Printf("Session %d PDR %d %v\n", seid, pdr.ID, err)
+			return up.rejectEstablishment(msg, addr, session, rejectionCause(err))
+		}
+		if created != nil {
+			createdPDRs = append(createdPDRs, created)
 		}
 
-		session.PDRs[pdr.ID] = &pdr
-		up.dataplane.InstallPDR(seid, &pdr)
+		session.PDRs[pdr.ID] = pdr
+		if err := up.dataplane.InstallPDR(seid, pdr); err != nil {
+			fmt.Printf("Session %d PDR %d: install: %v\n", seid, pdr.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure)
+		}
 	}
 
-	createFARs := msg.FindAllIEs(protocol.IETypeCreateFAR)
-	for _, createFAR := range createFARs {
-		farIEs, err := protocol.ParseGroupedIE(createFAR.Value)
+	for _, createFAR := range msg.FindAllIEs(protocol.IETypeCreateFAR) {
+		far, err := parseFAR(createFAR)
 		if err != nil {
 			continue
 		}
 
-		var far FAR
-		for _, ie := range farIEs {
-			switch ie.Type {
-			case protocol.IETypeFAR_ID:
-				farID, _ := ie.GetFAR_ID()
-				far.ID = farID
-			case protocol.IETypeApplyAction:
-				if len(ie.Value) > 0 {
-					far.ApplyAction = ie.Value[0]
-				}
-			}
+		if err := up.checkNetworkInstance(farNetworkInstance(far)); err != nil {
+			fmt.Printf("Session %d FAR %d: %v\n", seid, far.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure,
+				protocol.NewOffendingIEIE(protocol.IETypeNetworkInstance))
 		}
 
-		session.FARs[far.ID] = &far
-		up.dataplane.InstallFAR(seid, &far)
+		session.FARs[far.ID] = far
+		if err := up.dataplane.InstallFAR(seid, far); err != nil {
+			fmt.Printf("Session %d FAR %d: install: %v\n", seid, far.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure)
+		}
+	}
+
+	for _, createQER := range msg.FindAllIEs(protocol.IETypeCreateQER) {
+		qer, err := parseQER(createQER)
+		if err != nil {
+			fmt.Printf("Session %d QER: %v\n", seid, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseMandatoryIEIncorrect,
+				protocol.NewOffendingIEIE(protocol.IETypeCreateQER))
+		}
+
+		session.QERs[qer.ID] = qer
+		if err := up.dataplane.InstallQER(seid, qer); err != nil {
+			fmt.Printf("Session %d QER %d: install: %v\n", seid, qer.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure)
+		}
+	}
+
+	for _, createURR := range msg.FindAllIEs(protocol.IETypeCreateURR) {
+		urr, err := parseURR(createURR)
+		if err != nil {
+			fmt.Printf("Session %d URR: %v\n", seid, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseMandatoryIEIncorrect,
+				protocol.NewOffendingIEIE(protocol.IETypeCreateURR))
+		}
+
+		session.URRs[urr.ID] = urr
+		if err := up.dataplane.InstallURR(seid, urr); err != nil {
+			fmt.Printf("Session %d URR %d: install: %v\n", seid, urr.ID, err)
+			return up.rejectEstablishment(msg, addr, session, protocol.CauseRuleCreationModificationFailure)
+		}
+		up.trackURR(session, urr, nil)
+	}
+
+	// A session has at most one BAR.
+	if createBAR := msg.FindIE(protocol.IETypeCreateBAR); createBAR != nil {
+		if bar, err := parseBAR(createBAR); err == nil {
+			session.BAR = bar
+		}
 	}
 
 	up.mu.Lock()
@@ -95,44 +132,302 @@ func (up *UPFunction) handleSessionEstablishmentRequest(msg *protocol.Message, a
 
 	resp := protocol.NewSessionEstablishmentResponse(
 		msg.Header.SequenceNumber,
-		msg.Header.SEID,
+		remoteSEID,
 		protocol.CauseRequestAccepted,
 		seid,
+		createdPDRs...,
 	)
 
 	return up.transport.SendResponse(resp, addr)
 }
 
+// rejectEstablishment undoes what the UP allocated and the dataplane
+// installed for a session that is not going to be established and rejects
+// the request.
+func (up *UPFunction) rejectEstablishment(msg *protocol.Message, addr *net.UDPAddr, session *Session, cause uint8, ies ...*protocol.IE) error {
+	up.dataplane.DeleteSession(session.LocalSEID)
+	up.teids.releaseSession(session.LocalSEID)
+	up.releaseSessionUEIPs(session)
+	resp := protocol.NewSessionEstablishmentResponse(
+		msg.Header.SequenceNumber,
+		session.RemoteSEID,
+		cause,
+		session.LocalSEID,
+		ies...,
+	)
+	return up.transport.SendResponse(resp, addr)
+}
+
+// rejectionCause is the cause a request is rejected with for an error
+// resolving its rules.
+func rejectionCause(err error) uint8 {
+	if errors.Is(err, ErrUEIPPoolExhausted) {
+		return protocol.CauseAllDynamicAddressesOccupied
+	}
+	return protocol.CauseRuleCreationModificationFailure
+}
+
+// assignPDR resolves what the UP allocates for a PDR, its local F-TEID and
+// UE IP address, and returns the Created PDR IE reporting them, if any.
+func (up *UPFunction) assignPDR(session *Session, pdr *PDR) (*protocol.IE, error) {
+	fteid, err := up.assignFTEID(session, pdr)
+	if err != nil {
+		return nil, fmt.Errorf("F-TEID: %w", err)
+	}
+	ueIP, err := up.assignUEIP(session, pdr)
+	if err != nil {
+		return nil, fmt.Errorf("UE IP address: %w", err)
+	}
+	if fteid == nil && ueIP == nil {
+		return nil, nil
+	}
+	return protocol.NewCreatedPDRIE(&protocol.CreatedPDR{PDRID: pdr.ID, LocalFTEID: fteid, UEIPAddress: ueIP})
+}
+
 func (up *UPFunction) handleSessionModificationRequest(msg *protocol.Message, addr *net.UDPAddr) error {
-	resp := &protocol.Message{
-		Header: protocol.MessageHeader{
-			Version:        protocol.Version1,
-			MessageType:    protocol.MsgTypeSessionModificationResponse,
-			SEIDPresent:    true,
-			SEID:           msg.Header.SEID,
-			SequenceNumber: msg.Header.SequenceNumber,
-		},
-		IEs: []*protocol.IE{
-			protocol.NewCauseIE(protocol.CauseRequestAccepted),
-		},
+	seid := msg.Header.SEID
+
+	up.mu.Lock()
+
+	session, ok := up.sessions[seid]
+	if !ok {
+		up.mu.Unlock()
+		resp := protocol.NewSessionModificationResponse(
+			msg.Header.SequenceNumber,
+			0,
+			protocol.CauseSessionContextNotFound,
+		)
+		return up.transport.SendResponse(resp, addr)
 	}
 
-	return up.transport.SendResponse(resp, addr)
+	cause := protocol.CauseRequestAccepted
+	respIEs, err := up.modifySession(session, msg)
+	if err != nil {
+		fmt.Printf("Session %d modification failed: %v\n", seid, err)
+		cause = rejectionCause(err)
+		respIEs = nil
+		if errors.Is(err, errUnknownNetworkInstance) {
+			respIEs = append(respIEs, protocol.NewOffendingIEIE(protocol.IETypeNetworkInstance))
+		}
+	}
+	up.releaseFTEIDs(session)
+	up.releaseUEIPs(session)
+	released := up.releaseBuffers(session)
+
+	resp := protocol.NewSessionModificationResponse(
+		msg.Header.SequenceNumber,
+		session.RemoteSEID,
+		cause,
+		respIEs...,
+	)
+
+	err = up.transport.SendResponse(resp, addr)
+	up.mu.Unlock()
+
+	// Replayed packets go through the dataplane, which must not hold up
+	// the other sessions.
+	up.replayBuffered(seid, released)
+	return err
+}
+
+// modifySession applies removals before creations and updates so that a
+// rule can be replaced by one with the same ID in a single request. The CP
+// always sends complete rules in Update IEs, so an update replaces the rule.
+// It returns a Created PDR IE for every PDR whose F-TEID the UP chose.
+func (up *UPFunction) modifySession(session *Session, msg *protocol.Message) ([]*protocol.IE, error) {
+	seid := session.LocalSEID
+
+	for _, ie := range msg.FindAllIEs(protocol.IETypeRemovePDR) {
+		children, err := protocol.ParseGroupedIE(ie.Value)
+		if err != nil || len(children) == 0 {
+			return nil, fmt.Errorf("invalid Remove PDR")
+		}
+		pdrID, err := children[0].GetPDR_ID()
+		if err != nil {
+			return nil, err
+		}
+		if err := up.dataplane.RemovePDR(seid, pdrID); err != nil {
+			return nil, fmt.Errorf("remove PDR %d: %w", pdrID, err)
+		}
+		delete(session.PDRs, pdrID)
+		for _, usage := range session.usage {
+			delete(usage.last, pdrID)
+		}
+	}
+
+	for _, ie := range msg.FindAllIEs(protocol.IETypeRemoveFAR) {
+		children, err := protocol.ParseGroupedIE(ie.Value)
+		if err != nil || len(children) == 0 {
+			return nil, fmt.Errorf("invalid Remove FAR")
+		}
+		farID, err := children[0].GetFAR_ID()
+		if err != nil {
+			return nil, err
+		}
+		if err := up.dataplane.RemoveFAR(seid, farID); err != nil {
+			return nil, fmt.Errorf("remove FAR %d: %w", farID, err)
+		}
+		delete(session.FARs, farID)
+	}
+
+	for _, ie := range msg.FindAllIEs(protocol.IETypeRemoveQER) {
+		children, err := protocol.ParseGroupedIE(ie.Value)
+		if err != nil || len(children) == 0 {
+			return nil, fmt.Errorf("invalid Remove QER")
+		}
+		qerID, err := children[0].GetQER_ID()
+		if err != nil {
+			return nil, err
+		}
+		if err := up.dataplane.RemoveQER(seid, qerID); err != nil {
+			return nil, fmt.Errorf("remove QER %d: %w", qerID, err)
+		}
+		delete(session.QERs, qerID)
+	}
+
+	for _, ie := range msg.FindAllIEs(protocol.IETypeRemoveURR) {
+		children, err := protocol.ParseGroupedIE(ie.Value)
+		if err != nil || len(children) == 0 {
+			return nil, fmt.Errorf("invalid Remove URR")
+		}
+		urrID, err := children[0].GetURR_ID()
+		if err != nil {
+			return nil, err
+		}
+		if err := up.dataplane.RemoveURR(seid, urrID); err != nil {
+			return nil, fmt.Errorf("remove URR %d: %w", urrID, err)
+		}
+		delete(session.URRs, urrID)
+		delete(session.usage, urrID)
+	}
+
+	if ie := msg.FindIE(protocol.IETypeRemoveBAR); ie != nil {
+		children, err := protocol.ParseGroupedIE(ie.Value)
+		if err != nil || len(children) == 0 {
+			return nil, fmt.Errorf("invalid Remove BAR")
+		}
+		barID, err := children[0].GetBAR_ID()
+		if err != nil {
+			return nil, err
+		}
+		if session.BAR != nil && session.BAR.ID == barID {
+			session.BAR = nil
+		}
+	}
+
+	var createdPDRs []*protocol.IE
+	for _, ieType := range []uint16{protocol.IETypeCreatePDR, protocol.IETypeUpdatePDR} {
+		for _, ie := range msg.FindAllIEs(ieType) {
+			pdr, err := parsePDR(ie)
+			if err != nil {
+				return nil, err
+			}
+			if err := up.checkNetworkInstance(pdr.PDI.NetworkInstance); err != nil {
+				return nil, fmt.Errorf("PDR %d: %w", pdr.ID, err)
+			}
+			created, err := up.assignPDR(session, pdr)
+			if err != nil {
+				return nil, fmt.Errorf("PDR %d %w", pdr.ID, err)
+			}
+			if created != nil {
+				createdPDRs = append(createdPDRs, created)
+			}
+			if err := up.dataplane.InstallPDR(seid, pdr); err != nil {
+				return nil, fmt.Errorf("install PDR %d: %w", pdr.ID, err)
+			}
+			session.PDRs[pdr.ID] = pdr
+		}
+	}
+
+	for _, ieType := range []uint16{protocol.IETypeCreateFAR, protocol.IETypeUpdateFAR} {
+		for _, ie := range msg.FindAllIEs(ieType) {
+			far, err := parseFAR(ie)
+			if err != nil {
+				return nil, err
+			}
+			if err := up.checkNetworkInstance(farNetworkInstance(far)); err != nil {
+				return nil, fmt.Errorf("FAR %d: %w", far.ID, err)
+			}
+			if err := up.dataplane.InstallFAR(seid, far); err != nil {
+				return nil, fmt.Errorf("install FAR %d: %w", far.ID, err)
+			}
+			session.FARs[far.ID] = far
+		}
+	}
+
+	for _, ieType := range []uint16{protocol.IETypeCreateQER, protocol.IETypeUpdateQER} {
+		for _, ie := range msg.FindAllIEs(ieType) {
+			qer, err := parseQER(ie)
+			if err != nil {
+				return nil, err
+			}
+			if err := up.dataplane.InstallQER(seid, qer); err != nil {
+				return nil, fmt.Errorf("install QER %d: %w", qer.ID, err)
+			}
+			session.QERs[qer.ID] = qer
+		}
+	}
+
+	for _, ieType := range []uint16{protocol.IETypeCreateURR, protocol.IETypeUpdateURR} {
+		for _, ie := range msg.FindAllIEs(ieType) {
+			urr, err := parseURR(ie)
+			if err != nil {
+				return nil, err
+			}
+			if err := up.dataplane.InstallURR(seid, urr); err != nil {
+				return nil, fmt.Errorf("install URR %d: %w", urr.ID, err)
+			}
+			session.URRs[urr.ID] = urr
+			up.trackURR(session, urr, nil)
+		}
+	}
+
+	for _, ieType := range []uint16{protocol.IETypeCreateBAR, protocol.IETypeUpdateBAR} {
+		if ie := msg.FindIE(ieType); ie != nil {
+			bar, err := parseBAR(ie)
+			if err != nil {
+				return nil, err
+			}
+			session.BAR = bar
+		}
+	}
+
+	return createdPDRs, nil
 }
 
 func (up *UPFunction) handleSessionDeletionRequest(msg *protocol.Message, addr *net.UDPAddr) error {
 	seid := msg.Header.SEID
 
 	up.mu.Lock()
+	session, ok := up.sessions[seid]
 	delete(up.sessions, seid)
 	up.mu.Unlock()
 
+	if !ok {
+		resp := protocol.NewSessionDeletionResponse(
+			msg.Header.SequenceNumber,
+			0,
+			protocol.CauseSessionContextNotFound,
+		)
+		return up.transport.SendResponse(resp, addr)
+	}
+
+	// Read the final counters before the dataplane forgets the PDRs.
+	usageReports, err := usageReportIEs(protocol.IETypeUsageReportDeletion,
+		up.usageReports(session, protocol.UsageReportTriggerTermination, nil))
+	if err != nil {
+		fmt.Printf("Session %d final usage reports dropped: %v\n", seid, err)
+	}
+
 	up.dataplane.DeleteSession(seid)
+	up.teids.releaseSession(seid)
+	up.releaseSessionUEIPs(session)
 
 	resp := protocol.NewSessionDeletionResponse(
 		msg.Header.SequenceNumber,
-		msg.Header.SEID,
+		session.RemoteSEID,
 		protocol.CauseRequestAccepted,
+		usageReports...,
 	)
 
 	return up.transport.SendResponse(resp, addr)
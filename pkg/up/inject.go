@@ -0,0 +1,136 @@
+package up
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+const (
+	etherTypeVLAN         = 0x8100
+	etherTypeQinQ         = 0x88a8
+	etherTypePPPoESession = 0x8864
+	maxVLANID             = 4094
+)
+
+// PacketInjector sends the packets the CP generates, such as DHCP and PPPoE
+// replies, out through the dataplane. The punt source injects if there is
+// one; otherwise the dataplane may implement it.
+type PacketInjector interface {
+	InjectPacket(pkt *InjectedPacket) error
+}
+
+type InjectedPacket struct {
+	// Interface is the dataplane interface to send the packet out of, or
+	// for IP packets, whose routing table to send it through.
+	Interface string
+	// Data starts at the Ethernet header, or at the IP header if IP is set.
+	Data []byte
+	IP   bool
+	// VLANs are the tags to push onto an Ethernet frame, outermost first.
+	// The UP pushes them before the packet reaches the PacketInjector.
+	VLANs []uint16
+	// SEID is the UP's SEID of the session the packet belongs to, or zero.
+	SEID uint64
+}
+
+// InjectionRecorder is a PacketInjector for dataplanes that cannot send
+// packets. It keeps the most recent ones for inspection.
+type InjectionRecorder struct {
+	packets []*InjectedPacket
+	limit   int
+	mu      sync.Mutex
+}
+
+// NewInjectionRecorder keeps up to limit packets, or every packet if limit
+// is zero.
+func NewInjectionRecorder(limit int) *InjectionRecorder {
+	return &InjectionRecorder{limit: limit}
+}
+
+func (r *InjectionRecorder) InjectPacket(pkt *InjectedPacket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.packets = append(r.packets, pkt)
+	if r.limit > 0 && len(r.packets) > r.limit {
+		r.packets = r.packets[len(r.packets)-r.limit:]
+	}
+	return nil
+}
+
+// Packets returns the recorded packets, oldest first.
+func (r *InjectionRecorder) Packets() []*InjectedPacket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*InjectedPacket(nil), r.packets...)
+}
+
+func (up *UPFunction) injector() PacketInjector {
+	if up.puntSource != nil {
+		return up.puntSource
+	}
+	if injector, ok := up.backend().(PacketInjector); ok {
+		return injector
+	}
+	return nil
+}
+
+// injectPacket checks the packet's egress context, pushes its VLAN tags and
+// hands it to the injector.
+func (up *UPFunction) injectPacket(pkt *InjectedPacket) error {
+	injector := up.injector()
+	if injector == nil {
+		return fmt.Errorf("packet injection not supported by the dataplane")
+	}
+
+	if pkt.Interface == "" {
+		return fmt.Errorf("no egress interface")
+	}
+
+	if pkt.SEID != 0 {
+		up.mu.RLock()
+		_, ok := up.sessions[pkt.SEID]
+		up.mu.RUnlock()
+		if !ok {
+			return fmt.Errorf("session %d not found", pkt.SEID)
+		}
+	}
+
+	if len(pkt.VLANs) > 0 {
+		if pkt.IP {
+			return fmt.Errorf("VLAN tags need an Ethernet frame")
+		}
+		data, err := pushVLANs(pkt.Data, pkt.VLANs)
+		if err != nil {
+			return err
+		}
+		pkt = &InjectedPacket{Interface: pkt.Interface, Data: data, SEID: pkt.SEID}
+	}
+
+	return injector.InjectPacket(pkt)
+}
+
+// pushVLANs inserts VLAN tags after the MAC addresses, outermost first. All
+// but the innermost tag of a stack are 802.1ad service tags.
+func pushVLANs(frame []byte, vlans []uint16) ([]byte, error) {
+	if len(frame) < 14 {
+		return nil, fmt.Errorf("short Ethernet frame (%d bytes)", len(frame))
+	}
+
+	out := make([]byte, 0, len(frame)+4*len(vlans))
+	out = append(out, frame[:12]...)
+	for i, id := range vlans {
+		if id == 0 || id > maxVLANID {
+			return nil, fmt.Errorf("invalid VLAN ID %d", id)
+		}
+		tpid := uint16(etherTypeVLAN)
+		if i < len(vlans)-1 {
+			tpid = etherTypeQinQ
+		}
+		out = binary.BigEndian.AppendUint16(out, tpid)
+		out = binary.BigEndian.AppendUint16(out, id)
+	}
+	return append(out, frame[12:]...), nil
+}
@@ -0,0 +1,29 @@
+package up
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errUnknownNetworkInstance = errors.New("unknown network instance")
+
+// checkNetworkInstance fails if the dataplane maps Network Instances and does
+// not know name. An empty name is the default table.
+func (up *UPFunction) checkNetworkInstance(name string) error {
+	if name == "" {
+		return nil
+	}
+	resolver, ok := up.backend().(NetworkInstanceResolver)
+	if !ok || resolver.HasNetworkInstance(name) {
+		return nil
+	}
+	return fmt.Errorf("%w %q", errUnknownNetworkInstance, name)
+}
+
+// farNetworkInstance is the Network Instance a FAR forwards into, if any.
+func farNetworkInstance(far *FAR) string {
+	if far.ForwardingParameters == nil {
+		return ""
+	}
+	return far.ForwardingParameters.NetworkInstance
+}
@@ -0,0 +1,439 @@
+package up
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+// PuntSource carries the packets a dataplane punts to the UP, and injects
+// the packets the CP sends back through the same channel.
+type PuntSource interface {
+	PacketInjector
+	// ReadPunt blocks until a packet is punted. It returns an error once
+	// the source is closed.
+	ReadPunt() (*PuntedPacket, error)
+	Close() error
+}
+
+// PuntClassifier tags punted packets with the session and PDR that punted
+// them. The UP installs its rules into the classifier as well as into the
+// dataplane, unless they are one and the same.
+type PuntClassifier interface {
+	Dataplane
+	// ClassifyPunt returns the session and PDR an Ethernet frame received
+	// on sourceInterface matches, and whether that PDR punts it. The SEID
+	// is zero if no PDR matches.
+	ClassifyPunt(sourceInterface uint8, frame []byte) (seid uint64, pdrID uint16, punted bool)
+}
+
+// PacketForwarder is implemented by punt classifiers that forward packets
+// as well. Dataplanes divert the traffic of rules they cannot apply, such as
+// L2TP sessions with VPP, to the punt source like punted packets, and the UP
+// forwards it and injects the result.
+type PacketForwarder interface {
+	// ForwardPacket applies the rules an Ethernet frame received on
+	// sourceInterface matches, returning the frame as it leaves. It fails
+	// if the rules do not forward the frame.
+	ForwardPacket(sourceInterface uint8, frame []byte) ([]byte, error)
+}
+
+type PuntedPacket struct {
+	// Interface is the dataplane interface the packet arrived on, and
+	// SourceInterface its PFCP Source Interface.
+	Interface       string
+	SourceInterface uint8
+	// Data starts at the Ethernet header, or at the IP header if IP is set.
+	Data       []byte
+	IP         bool
+	ReceivedAt time.Time
+	// Captured is set by sources that see all the traffic of their
+	// interfaces rather than only what the dataplane punted. The UP then
+	// delivers only the packets whose PDR punts them.
+	Captured bool
+	// Verdict is set by sources whose dataplane holds the packet until the
+	// UP has classified it. The UP calls it once, accepting the packet if
+	// no PDR punts or buffers it, so the dataplane forwards it, and
+	// dropping it otherwise.
+	Verdict func(accept bool)
+	// LocalSEID, RemoteSEID and PDRID are filled in by the UP, and are
+	// zero if no PDR matched.
+	LocalSEID  uint64
+	RemoteSEID uint64
+	PDRID      uint16
+}
+
+// mergedPuntSource reads the packets of several punt sources, and injects
+// through the first.
+type mergedPuntSource struct {
+	sources []PuntSource
+	packets chan *PuntedPacket
+	done    chan struct{}
+	err     error
+	once    sync.Once
+}
+
+// MergePuntSources combines punt sources, such as a dataplane's punt socket
+// and a capture of the interface it sends L2 punts out of. Packets the CP
+// sends back are injected through the first source. Once any source fails,
+// all of them are closed.
+func MergePuntSources(sources ...PuntSource) PuntSource {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+
+	m := &mergedPuntSource{
+		sources: sources,
+		packets: make(chan *PuntedPacket),
+		done:    make(chan struct{}),
+	}
+	for _, src := range sources {
+		go m.read(src)
+	}
+	return m
+}
+
+func (m *mergedPuntSource) read(src PuntSource) {
+	for {
+		pkt, err := src.ReadPunt()
+		if err != nil {
+			m.stop(err)
+			return
+		}
+		select {
+		case m.packets <- pkt:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+func (m *mergedPuntSource) stop(err error) {
+	m.once.Do(func() {
+		m.err = err
+		close(m.done)
+		for _, src := range m.sources {
+			src.Close()
+		}
+	})
+}
+
+func (m *mergedPuntSource) ReadPunt() (*PuntedPacket, error) {
+	select {
+	case pkt := <-m.packets:
+		return pkt, nil
+	case <-m.done:
+		return nil, m.err
+	}
+}
+
+func (m *mergedPuntSource) InjectPacket(pkt *InjectedPacket) error {
+	return m.sources[0].InjectPacket(pkt)
+}
+
+func (m *mergedPuntSource) Close() error {
+	m.stop(net.ErrClosed)
+	return nil
+}
+
+// puntQueueLen is how many packets a subscriber can fall behind by before
+// packets are dropped for it.
+const puntQueueLen = 256
+
+// puntMirror installs every rule into the punt classifier after the
+// dataplane has accepted it.
+type puntMirror struct {
+	Dataplane
+	classifier PuntClassifier
+}
+
+func (m *puntMirror) mirror(err error, what string, seid uint64, install func() error) error {
+	if err != nil {
+		return err
+	}
+	if err := install(); err != nil {
+		fmt.Printf("Punt classifier: failed to %s for session %d: %v\n", what, seid, err)
+	}
+	return nil
+}
+
+func (m *puntMirror) InstallPDR(seid uint64, pdr *PDR) error {
+	return m.mirror(m.Dataplane.InstallPDR(seid, pdr), fmt.Sprintf("install PDR %d", pdr.ID), seid,
+		func() error { return m.classifier.InstallPDR(seid, pdr) })
+}
+
+func (m *puntMirror) RemovePDR(seid uint64, pdrID uint16) error {
+	return m.mirror(m.Dataplane.RemovePDR(seid, pdrID), fmt.Sprintf("remove PDR %d", pdrID), seid,
+		func() error { return m.classifier.RemovePDR(seid, pdrID) })
+}
+
+func (m *puntMirror) InstallFAR(seid uint64, far *FAR) error {
+	return m.mirror(m.Dataplane.InstallFAR(seid, far), fmt.Sprintf("install FAR %d", far.ID), seid,
+		func() error { return m.classifier.InstallFAR(seid, far) })
+}
+
+func (m *puntMirror) RemoveFAR(seid uint64, farID uint32) error {
+	return m.mirror(m.Dataplane.RemoveFAR(seid, farID), fmt.Sprintf("remove FAR %d", farID), seid,
+		func() error { return m.classifier.RemoveFAR(seid, farID) })
+}
+
+func (m *puntMirror) InstallQER(seid uint64, qer *QER) error {
+	return m.mirror(m.Dataplane.InstallQER(seid, qer), fmt.Sprintf("install QER %d", qer.ID), seid,
+		func() error { return m.classifier.InstallQER(seid, qer) })
+}
+
+func (m *puntMirror) RemoveQER(seid uint64, qerID uint32) error {
+	return m.mirror(m.Dataplane.RemoveQER(seid, qerID), fmt.Sprintf("remove QER %d", qerID), seid,
+		func() error { return m.classifier.RemoveQER(seid, qerID) })
+}
+
+func (m *puntMirror) InstallURR(seid uint64, urr *URR) error {
+	return m.mirror(m.Dataplane.InstallURR(seid, urr), fmt.Sprintf("install URR %d", urr.ID), seid,
+		func() error { return m.classifier.InstallURR(seid, urr) })
+}
+
+func (m *puntMirror) RemoveURR(seid uint64, urrID uint32) error {
+	return m.mirror(m.Dataplane.RemoveURR(seid, urrID), fmt.Sprintf("remove URR %d", urrID), seid,
+		func() error { return m.classifier.RemoveURR(seid, urrID) })
+}
+
+// DeleteSession always reaches the classifier, since the UP forgets the
+// session even if the dataplane fails to delete it.
+func (m *puntMirror) DeleteSession(seid uint64) error {
+	err := m.Dataplane.DeleteSession(seid)
+	m.mirror(nil, "delete session", seid, func() error { return m.classifier.DeleteSession(seid) })
+	return err
+}
+
+// backend is the dataplane the UP was created with, beneath the punt
+// classifier's mirror.
+func (up *UPFunction) backend() Dataplane {
+	if m, ok := up.dataplane.(*puntMirror); ok {
+		return m.Dataplane
+	}
+	return up.dataplane
+}
+
+// SetPuntSource enables punt delivery: packets read from src are tagged by
+// classifier and streamed to the subscribers of the gRPC admin API. It must
+// be called before Start, and before any session is established.
+func (up *UPFunction) SetPuntSource(src PuntSource, classifier PuntClassifier) {
+	up.puntSource = src
+	up.puntClassifier = classifier
+	if Dataplane(classifier) != up.dataplane {
+		up.dataplane = &puntMirror{Dataplane: up.dataplane, classifier: classifier}
+	}
+}
+
+func (up *UPFunction) puntLoop() {
+	defer up.wg.Done()
+	defer up.closePuntSubscribers()
+
+	for {
+		pkt, err := up.puntSource.ReadPunt()
+		if err != nil {
+			if up.ctx.Err() == nil {
+				fmt.Printf("Punt source failed: %v\n", err)
+			}
+			return
+		}
+
+		punted := up.tagPunt(pkt)
+		buffered := up.bufferPacket(pkt)
+		if pkt.Verdict != nil {
+			pkt.Verdict(!punted && !buffered)
+			if punted && !buffered {
+				up.publishPunt(pkt)
+			}
+			continue
+		}
+		if buffered || (!punted && pkt.Captured) {
+			continue
+		}
+		if !punted && up.forwardPunt(pkt) {
+			continue
+		}
+		up.publishPunt(pkt)
+	}
+}
+
+// tagPunt fills in the session and PDR the packet matches, returning
+// whether that PDR punts it.
+func (up *UPFunction) tagPunt(pkt *PuntedPacket) bool {
+	frame := pkt.Data
+	if pkt.IP {
+		frame = ethernetFrame(pkt.Data)
+	}
+
+	seid, pdrID, punted := up.puntClassifier.ClassifyPunt(pkt.SourceInterface, frame)
+	if seid == 0 {
+		return false
+	}
+
+	up.mu.Lock()
+	session, ok := up.sessions[seid]
+	if ok {
+		pkt.LocalSEID, pkt.RemoteSEID, pkt.PDRID = seid, session.RemoteSEID, pdrID
+		// Unicast frames are sent to us.
+		if pkt.SourceInterface == protocol.SourceInterfaceAccess && !pkt.IP && frame[0]&1 == 0 {
+			session.subscriber = &subscriberAttachment{Interface: pkt.Interface, LocalMAC: net.HardwareAddr(bytes.Clone(frame[:6]))}
+		}
+	}
+	up.mu.Unlock()
+
+	return ok && punted
+}
+
+// subscriberAttachment is the access interface a session's subscriber is
+// attached to, and the MAC address of ours it sends to.
+type subscriberAttachment struct {
+	Interface string
+	LocalMAC  net.HardwareAddr
+}
+
+// forwardPunt forwards a packet the dataplane diverted rather than punted,
+// through the punt classifier, and injects the result: PPPoE frames out of
+// the interface the session's subscriber is attached to, from the address
+// it sends to, and IP packets routed by the dataplane. It returns false if
+// the classifier does not forward packets.
+func (up *UPFunction) forwardPunt(pkt *PuntedPacket) bool {
+	forwarder, ok := up.puntClassifier.(PacketForwarder)
+	if !ok || pkt.Captured || pkt.LocalSEID == 0 {
+		return false
+	}
+
+	frame := pkt.Data
+	if pkt.IP {
+		frame = ethernetFrame(pkt.Data)
+	}
+
+	out, err := forwarder.ForwardPacket(pkt.SourceInterface, frame)
+	if err == nil {
+		err = up.injectForwarded(pkt, out)
+	}
+	if err != nil {
+		up.puntMu.Lock()
+		up.forwardDrops++
+		if up.forwardDrops&(up.forwardDrops-1) == 0 {
+			fmt.Printf("Session %d: failed to forward diverted packet, %d dropped: %v\n", pkt.LocalSEID, up.forwardDrops, err)
+		}
+		up.puntMu.Unlock()
+	}
+	return true
+}
+
+// injectForwarded injects a frame the punt classifier forwarded.
+func (up *UPFunction) injectForwarded(pkt *PuntedPacket, frame []byte) error {
+	l3Offset := 12
+	for len(frame) >= l3Offset+4 {
+		etherType := binary.BigEndian.Uint16(frame[l3Offset:])
+		if etherType != etherTypeVLAN && etherType != etherTypeQinQ {
+			break
+		}
+		l3Offset += 4
+	}
+	if len(frame) < l3Offset+2 {
+		return fmt.Errorf("short Ethernet frame (%d bytes)", len(frame))
+	}
+
+	if binary.BigEndian.Uint16(frame[l3Offset:]) != etherTypePPPoESession {
+		return up.injectPacket(&InjectedPacket{
+			Interface: pkt.Interface,
+			Data:      frame[l3Offset+2:],
+			IP:        true,
+			SEID:      pkt.LocalSEID,
+		})
+	}
+
+	up.mu.RLock()
+	var subscriber *subscriberAttachment
+	if session, ok := up.sessions[pkt.LocalSEID]; ok {
+		subscriber = session.subscriber
+	}
+	up.mu.RUnlock()
+	if subscriber == nil {
+		return fmt.Errorf("subscriber not seen on the access side yet")
+	}
+
+	copy(frame[6:12], subscriber.LocalMAC)
+	return up.injectPacket(&InjectedPacket{
+		Interface: subscriber.Interface,
+		Data:      frame,
+		SEID:      pkt.LocalSEID,
+	})
+}
+
+// ethernetFrame gives an IP packet an Ethernet header with zero addresses,
+// for classification.
+func ethernetFrame(ip []byte) []byte {
+	frame := make([]byte, 14+len(ip))
+	frame[12], frame[13] = 0x08, 0x00
+	if len(ip) > 0 && ip[0]>>4 == 6 {
+		frame[12], frame[13] = 0x86, 0xdd
+	}
+	copy(frame[14:], ip)
+	return frame
+}
+
+func (up *UPFunction) publishPunt(pkt *PuntedPacket) {
+	up.puntMu.Lock()
+	defer up.puntMu.Unlock()
+
+	for sub := range up.puntSubs {
+		select {
+		case sub <- pkt:
+		default:
+			up.puntDrops++
+			if up.puntDrops&(up.puntDrops-1) == 0 {
+				fmt.Printf("Punt subscriber too slow, %d packets dropped\n", up.puntDrops)
+			}
+		}
+	}
+}
+
+// subscribePunts returns a channel receiving every packet delivered from
+// now on. It is closed when the UP stops or unsubscribe is called.
+func (up *UPFunction) subscribePunts() (<-chan *PuntedPacket, func(), error) {
+	if up.puntSource == nil {
+		return nil, nil, fmt.Errorf("punt delivery not configured")
+	}
+
+	up.puntMu.Lock()
+	defer up.puntMu.Unlock()
+
+	if up.puntSubs == nil {
+		return nil, nil, fmt.Errorf("UP function stopped")
+	}
+
+	sub := make(chan *PuntedPacket, puntQueueLen)
+	up.puntSubs[sub] = struct{}{}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			up.puntMu.Lock()
+			defer up.puntMu.Unlock()
+			if _, ok := up.puntSubs[sub]; ok {
+				delete(up.puntSubs, sub)
+				close(sub)
+			}
+		})
+	}
+
+	return sub, unsubscribe, nil
+}
+
+func (up *UPFunction) closePuntSubscribers() {
+	up.puntMu.Lock()
+	defer up.puntMu.Unlock()
+
+	for sub := range up.puntSubs {
+		close(sub)
+	}
+	up.puntSubs = nil
+}
@@ -0,0 +1,418 @@
+package up
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+func parsePDR(ie *protocol.IE) (*PDR, error) {
+	pdrIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse PDR: %w", err)
+	}
+
+	pdr := &PDR{PDI: &PDI{}}
+
+	for _, ie := range pdrIEs {
+		switch ie.Type {
+		case protocol.IETypePDR_ID:
+			id, _ := ie.GetPDR_ID()
+			pdr.ID = id
+		case protocol.IETypePrecedence:
+			precedence, _ := ie.GetPrecedence()
+			pdr.Precedence = precedence
+		case protocol.IETypeFAR_ID:
+			farID, _ := ie.GetFAR_ID()
+			pdr.FAR_ID = farID
+		case protocol.IETypeQER_ID:
+			qerID, _ := ie.GetQER_ID()
+			pdr.QER_IDs = append(pdr.QER_IDs, qerID)
+		case protocol.IETypeURR_ID:
+			urrID, _ := ie.GetURR_ID()
+			pdr.URR_IDs = append(pdr.URR_IDs, urrID)
+		case protocol.IETypeOuterHeaderRemoval:
+			if description, err := ie.GetOuterHeaderRemoval(); err == nil {
+				pdr.OuterHeaderRemoval = &description
+			}
+		case protocol.IETypeBBFOuterHeaderRemoval:
+			if description, err := ie.GetBBFOuterHeaderRemoval(); err == nil {
+				pdr.BBFOuterHeaderRemoval = &description
+			}
+		case protocol.IETypePDI:
+			pdiIEs, _ := protocol.ParseGroupedIE(ie.Value)
+			for _, pdiIE := range pdiIEs {
+				if pdiIE.IsEnterprise() {
+					if err := parseEnterprisePDI(pdr.PDI, pdiIE); err != nil {
+						return nil, fmt.Errorf("parse PDR %d: %w", pdr.ID, err)
+					}
+					continue
+				}
+				switch pdiIE.Type {
+				case protocol.IETypeSourceInterface:
+					if len(pdiIE.Value) > 0 {
+						pdr.PDI.SourceInterface = pdiIE.Value[0]
+					}
+				case protocol.IETypeSDFFilter:
+					pdr.PDI.SDFFilter = pdiIE.Value
+				case protocol.IETypeUE_IPAddress:
+					if ue, err := pdiIE.GetUEIPAddress(); err == nil {
+						switch {
+						case ue.IPv4 != nil:
+							pdr.PDI.UE_IPAddress = ue.IPv4.String()
+						case ue.IPv6 != nil:
+							pdr.PDI.UE_IPAddress = ue.IPv6.String()
+							pdr.PDI.UE_IPPrefixLength = ue.IPv6PrefixLength
+						}
+						pdr.PDI.ChooseUE_IPv4, pdr.PDI.ChooseUE_IPv6 = ue.ChooseV4, ue.ChooseV6
+					}
+				case protocol.IETypeNetworkInstance:
+					pdr.PDI.NetworkInstance = string(pdiIE.Value)
+				case protocol.IETypeApplicationID:
+					pdr.PDI.ApplicationID = string(pdiIE.Value)
+				case protocol.IETypeEthernetPacketFilter:
+					filter, err := protocol.ParseEthernetPacketFilter(pdiIE)
+					if err != nil {
+						return nil, fmt.Errorf("parse PDR %d: %w", pdr.ID, err)
+					}
+					pdr.PDI.EthernetPacketFilters = append(pdr.PDI.EthernetPacketFilters, filter)
+				case protocol.IETypeFramedRoute, protocol.IETypeFramedIPv6Route:
+					route, err := pdiIE.GetFramedRoute()
+					if err != nil {
+						return nil, fmt.Errorf("parse PDR %d: %w", pdr.ID, err)
+					}
+					pdr.PDI.FramedRoutes = append(pdr.PDI.FramedRoutes, route)
+				case protocol.IETypeFramedRouting:
+					if routing, err := pdiIE.GetFramedRouting(); err == nil {
+						pdr.PDI.FramedRouting = routing
+					}
+				case protocol.IETypeFTEID:
+					fteid, err := pdiIE.GetFTEID()
+					if err != nil {
+						return nil, fmt.Errorf("parse PDR %d: %w", pdr.ID, err)
+					}
+					pdr.PDI.LocalFTEID = fteid
+				}
+			}
+		}
+	}
+
+	return pdr, nil
+}
+
+// parseEnterprisePDI adds a vendor-specific PDI IE to the PDI. IEs without
+// a registered codec are skipped.
+func parseEnterprisePDI(pdi *PDI, ie *protocol.IE) error {
+	value, err := protocol.DecodeEnterpriseIE(ie)
+	if errors.Is(err, protocol.ErrUnknownEnterpriseIE) {
+		fmt.Printf("Skipping %v in PDI\n", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case protocol.BBFPPPoESessionID:
+		if pdi.PPPoE == nil {
+			pdi.PPPoE = &protocol.PPPoEMatch{}
+		}
+		pdi.PPPoE.SessionID, pdi.PPPoE.HasSessionID = uint16(v), true
+	case *protocol.PPPProtocol:
+		if pdi.PPPoE == nil {
+			pdi.PPPoE = &protocol.PPPoEMatch{}
+		}
+		pdi.PPPoE.Protocol = v
+	}
+	return nil
+}
+
+func parseFAR(ie *protocol.IE) (*FAR, error) {
+	farIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse FAR: %w", err)
+	}
+
+	far := &FAR{}
+
+	for _, ie := range farIEs {
+		switch ie.Type {
+		case protocol.IETypeFAR_ID:
+			farID, _ := ie.GetFAR_ID()
+			far.ID = farID
+		case protocol.IETypeApplyAction:
+			if len(ie.Value) > 0 {
+				far.ApplyAction = ie.Value[0]
+			}
+		case protocol.IETypeForwardingParameters, protocol.IETypeUpdateForwardingParameters:
+			fp, err := parseForwardingParameters(ie)
+			if err != nil {
+				return nil, fmt.Errorf("parse FAR %d: %w", far.ID, err)
+			}
+			far.ForwardingParameters = fp
+		case protocol.IETypeBAR_ID:
+			if barID, err := ie.GetBAR_ID(); err == nil {
+				far.BAR_ID = &barID
+			}
+		case protocol.IETypeDuplicatingParameters, protocol.IETypeUpdateDuplicatingParameters:
+			dp, err := parseDuplicatingParameters(ie)
+			if err != nil {
+				return nil, fmt.Errorf("parse FAR %d: %w", far.ID, err)
+			}
+			far.DuplicatingParameters = append(far.DuplicatingParameters, dp)
+		}
+	}
+
+	return far, nil
+}
+
+func parseDuplicatingParameters(ie *protocol.IE) (*DuplicatingParameters, error) {
+	dpIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse duplicating parameters: %w", err)
+	}
+
+	dp := &DuplicatingParameters{}
+
+	for _, ie := range dpIEs {
+		switch ie.Type {
+		case protocol.IETypeDestinationInterface:
+			if len(ie.Value) > 0 {
+				dp.DestinationInterface = ie.Value[0]
+			}
+		case protocol.IETypeOuterHeaderCreation:
+			ohc, err := ie.GetOuterHeaderCreation()
+			if err != nil {
+				return nil, err
+			}
+			dp.OuterHeaderCreation = ohc
+		case protocol.IETypeForwardingPolicy:
+			policy, err := ie.GetForwardingPolicy()
+			if err != nil {
+				return nil, err
+			}
+			dp.ForwardingPolicy = policy
+		}
+	}
+
+	return dp, nil
+}
+
+func parseForwardingParameters(ie *protocol.IE) (*ForwardingParameters, error) {
+	fpIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse forwarding parameters: %w", err)
+	}
+
+	fp := &ForwardingParameters{}
+
+	// PPPoE and L2TP sessions are spread over several IEs, and only apply
+	// if a BBF Outer Header Creation asks for them.
+	var (
+		bbfOHC  *protocol.BBFOuterHeaderCreation
+		session protocol.PPPoESession
+		hasID   bool
+		lns     *protocol.L2TPTunnelEndpoint
+	)
+
+	for _, ie := range fpIEs {
+		if ie.IsEnterprise() {
+			switch value, err := protocol.DecodeEnterpriseIE(ie); v := value.(type) {
+			case *protocol.BBFOuterHeaderCreation:
+				bbfOHC = v
+			case protocol.BBFPPPoESessionID:
+				session.SessionID, hasID = uint16(v), true
+			case *protocol.L2TPTunnelEndpoint:
+				lns = v
+			default:
+				if err != nil && !errors.Is(err, protocol.ErrUnknownEnterpriseIE) {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		switch ie.Type {
+		case protocol.IETypeDestinationInterface:
+			if len(ie.Value) > 0 {
+				fp.DestinationInterface = ie.Value[0]
+			}
+		case protocol.IETypeNetworkInstance:
+			fp.NetworkInstance = string(ie.Value)
+		case protocol.IETypeOuterHeaderCreation:
+			ohc, err := ie.GetOuterHeaderCreation()
+			if err != nil {
+				return nil, err
+			}
+			fp.OuterHeaderCreation = ohc
+		case protocol.IETypeMACAddress:
+			mac, err := ie.GetMACAddress()
+			if err != nil {
+				return nil, err
+			}
+			session.PeerMAC = mac.Destination
+		case protocol.IETypeCTAG:
+			if session.CTag, err = ie.GetVLANTag(); err != nil {
+				return nil, err
+			}
+		case protocol.IETypeSTAG:
+			if session.STag, err = ie.GetVLANTag(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch {
+	case bbfOHC == nil:
+	case bbfOHC.Description&protocol.BBFOuterHeaderCreationL2TP != 0:
+		if lns == nil || lns.IPv4 == nil && lns.IPv6 == nil {
+			return nil, fmt.Errorf("L2TP outer header creation without an LNS tunnel endpoint")
+		}
+		fp.L2TP = &protocol.L2TPSession{TunnelID: bbfOHC.TunnelID, SessionID: bbfOHC.SessionID, LNS: lns.IPv4}
+		if lns.IPv4 == nil {
+			fp.L2TP.LNS = lns.IPv6
+		}
+	case bbfOHC.Description&protocol.BBFOuterHeaderCreationPPP != 0:
+		if !hasID || session.PeerMAC == nil {
+			return nil, fmt.Errorf("PPP outer header creation without a PPPoE session ID and peer MAC address")
+		}
+		fp.PPPoE = &session
+	}
+
+	return fp, nil
+}
+
+func parseQER(ie *protocol.IE) (*QER, error) {
+	qerIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse QER: %w", err)
+	}
+
+	qer := &QER{}
+
+	for _, ie := range qerIEs {
+		switch ie.Type {
+		case protocol.IETypeQER_ID:
+			qerID, _ := ie.GetQER_ID()
+			qer.ID = qerID
+		case protocol.IETypeGateStatus:
+			gateStatus, _ := ie.GetGateStatus()
+			qer.GateStatus = gateStatus
+		case protocol.IETypeMBR:
+			qer.MBR_UL, qer.MBR_DL, _ = ie.GetMBR()
+		case protocol.IETypeGBR:
+			qer.GBR_UL, qer.GBR_DL, _ = ie.GetGBR()
+		}
+	}
+
+	return qer, nil
+}
+
+func parseURR(ie *protocol.IE) (*URR, error) {
+	urrIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse URR: %w", err)
+	}
+
+	urr := &URR{}
+
+	for _, ie := range urrIEs {
+		switch ie.Type {
+		case protocol.IETypeURR_ID:
+			urrID, _ := ie.GetURR_ID()
+			urr.ID = urrID
+		case protocol.IETypeMeasurementMethod:
+			urr.MeasurementMethod, _ = ie.GetMeasurementMethod()
+		case protocol.IETypeReportingTriggers:
+			urr.ReportingTriggers, _ = ie.GetReportingTriggers()
+		case protocol.IETypeVolumeThreshold:
+			urr.VolumeThreshold, _ = ie.GetVolumeThreshold()
+		case protocol.IETypeTimeThreshold:
+			urr.TimeThreshold, _ = ie.GetTimeThreshold()
+		}
+	}
+
+	return urr, nil
+}
+
+func parseBAR(ie *protocol.IE) (*BAR, error) {
+	barIEs, err := protocol.ParseGroupedIE(ie.Value)
+	if err != nil {
+		return nil, fmt.Errorf("parse BAR: %w", err)
+	}
+
+	bar := &BAR{}
+
+	for _, ie := range barIEs {
+		switch ie.Type {
+		case protocol.IETypeBAR_ID:
+			bar.ID, _ = ie.GetBAR_ID()
+		case protocol.IETypeDownlinkDataNotificationDelay:
+			bar.DownlinkDataNotificationDelay, _ = ie.GetDownlinkDataNotificationDelay()
+		case protocol.IETypeSuggestedBufferingPacketsCount:
+			bar.SuggestedBufferingPacketsCount, _ = ie.GetSuggestedBufferingPacketsCount()
+		}
+	}
+
+	return bar, nil
+}
+
+// RuleHash must stay in sync with the CP's Session.RuleHash so that audits
+// compare like with like.
+func (s *Session) RuleHash() string {
+	var d protocol.RuleDigest
+	for _, pdr := range s.PDRs {
+		d.AddPDR(pdr.ID, pdr.Precedence, pdr.FAR_ID, pdr.QER_IDs, pdr.URR_IDs)
+		d.AddPDI(pdr.ID, pdr.PDI.digest())
+	}
+	for _, far := range s.FARs {
+		d.AddFAR(far.ID, far.ApplyAction)
+		if fp := far.ForwardingParameters; fp != nil {
+			d.AddForwarding(far.ID, fp.DestinationInterface, fp.NetworkInstance, fp.OuterHeaderCreation, fp.PPPoE, fp.L2TP)
+		}
+		for _, dp := range far.DuplicatingParameters {
+			d.AddDuplicating(far.ID, dp.DestinationInterface, dp.OuterHeaderCreation, dp.ForwardingPolicy)
+		}
+	}
+	for _, qer := range s.QERs {
+		d.AddQER(qer.ID, qer.GateStatus, qer.MBR_UL, qer.MBR_DL)
+	}
+	for _, urr := range s.URRs {
+		d.AddURR(urr.ID, urr.MeasurementMethod, urr.ReportingTriggers, urr.VolumeThreshold, urr.TimeThreshold)
+	}
+	return d.Sum()
+}
+
+// digest returns the PDI in the form the CP's Session.RuleHash hashes it.
+func (pdi *PDI) digest() protocol.DigestPDI {
+	if pdi == nil {
+		return protocol.DigestPDI{}
+	}
+
+	d := protocol.DigestPDI{
+		SourceInterface:       pdi.SourceInterface,
+		NetworkInstance:       pdi.NetworkInstance,
+		SDFFilter:             pdi.SDFFilter,
+		ApplicationID:         pdi.ApplicationID,
+		LocalFTEID:            pdi.LocalFTEID,
+		EthernetPacketFilters: pdi.EthernetPacketFilters,
+		PPPoE:                 pdi.PPPoE,
+		FramedRoutes:          pdi.FramedRoutes,
+		FramedRouting:         pdi.FramedRouting,
+	}
+
+	ue := &protocol.UEIPAddress{ChooseV4: pdi.ChooseUE_IPv4, ChooseV6: pdi.ChooseUE_IPv6}
+	if ip := net.ParseIP(pdi.UE_IPAddress); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ue.IPv4 = ip4
+		} else {
+			ue.IPv6, ue.IPv6PrefixLength = ip, pdi.UE_IPPrefixLength
+		}
+	}
+	if ue.ChooseV4 || ue.ChooseV6 || ue.IPv4 != nil || ue.IPv6 != nil {
+		d.UEIPAddress = ue
+	}
+	return d
+}
@@ -0,0 +1,572 @@
+package up
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+// ErrUEIPPoolExhausted is returned by a UEIPAllocator that has no free
+// address of the family asked for. The request is then rejected with cause
+// All dynamic addresses are occupied.
+var ErrUEIPPoolExhausted = errors.New("all dynamic addresses are occupied")
+
+// UEIPAllocator hands out the UE IP addresses, or IPv6 prefixes, of PDRs that
+// ask the UP to CHOOSE one. Allocations are owned by the session, which keeps
+// its owner when the CP re-establishes it after the UP restarted. An allocator
+// that also implements Reconciler is reconciled like the dataplane.
+type UEIPAllocator interface {
+	// Allocate returns an IPv4 address, as a /32, or an IPv6 prefix from
+	// the pools of the Network Instance.
+	Allocate(networkInstance string, isV6 bool, owner UEIPOwner) (netip.Prefix, error)
+	// Reserve claims an address the CP gave, such as one the UP allocated
+	// before it restarted, if it is in a pool.
+	Reserve(prefix netip.Prefix, owner UEIPOwner) error
+	// Release returns an address to its pool.
+	Release(prefix netip.Prefix)
+}
+
+// UEIPOwner is the session a UE IP allocation belongs to: the CP's SEID of
+// it, which is only unique among the sessions of the CP with that Node ID.
+type UEIPOwner struct {
+	NodeID string
+	SEID   uint64
+}
+
+func (o UEIPOwner) String() string {
+	return fmt.Sprintf("%s/%d", o.NodeID, o.SEID)
+}
+
+// SetUEIPAllocator replaces the pools of Config.UEIPPools, e.g. with an
+// allocator backed by an external IPAM. It must be called before Start.
+func (up *UPFunction) SetUEIPAllocator(allocator UEIPAllocator) {
+	up.ueIPs = allocator
+}
+
+// UEIPPool is a prefix UE IP addresses are allocated from: single addresses
+// from an IPv4 prefix, and prefixes of PrefixLength, 64 by default, from an
+// IPv6 one. A pool without a NetworkInstance serves the Network Instances
+// that have no pool of the family.
+type UEIPPool struct {
+	NetworkInstance string
+	Prefix          string
+	PrefixLength    int
+}
+
+// ParseUEIPPool parses a pool written as [network-instance=]prefix, with the
+// length of the prefixes an IPv6 pool hands out as a second length, e.g.
+// internet=2001:db8::/48/64.
+func ParseUEIPPool(s string) (UEIPPool, error) {
+	var pool UEIPPool
+	if name, prefix, ok := strings.Cut(s, "="); ok {
+		pool.NetworkInstance, s = name, prefix
+	}
+	pool.Prefix = s
+	if strings.Count(s, "/") == 2 {
+		i := strings.LastIndex(s, "/")
+		length, err := strconv.Atoi(s[i+1:])
+		if err != nil {
+			return UEIPPool{}, fmt.Errorf("invalid prefix length in %q", s)
+		}
+		pool.Prefix, pool.PrefixLength = s[:i], length
+	}
+	return pool, nil
+}
+
+// uePool is a parsed UEIPPool. Its addresses or prefixes are numbered from
+// the start of the pool; those from first up to first+size are handed out.
+type uePool struct {
+	networkInstance string
+	prefix          netip.Prefix
+	length          int
+	first, size     uint64
+	used            uint64
+	next            uint64
+}
+
+func newUEPool(cfg UEIPPool) (*uePool, error) {
+	prefix, err := netip.ParsePrefix(cfg.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	p := &uePool{networkInstance: cfg.NetworkInstance, prefix: prefix.Masked(), length: 32}
+
+	if prefix.Addr().Is6() {
+		p.length = cfg.PrefixLength
+		if p.length == 0 {
+			p.length = 64
+		}
+	} else if cfg.PrefixLength != 0 && cfg.PrefixLength != 32 {
+		return nil, fmt.Errorf("IPv4 pool %s hands out single addresses", prefix)
+	}
+
+	bits := p.length - prefix.Bits()
+	if bits < 0 || p.length > prefix.Addr().BitLen() || bits > 32 {
+		return nil, fmt.Errorf("pool %s cannot hand out /%d prefixes", prefix, p.length)
+	}
+	p.size = 1 << bits
+
+	// IPv4 pools do not hand out their network and broadcast addresses.
+	if prefix.Addr().Is4() && bits > 1 {
+		p.first, p.size = 1, p.size-2
+	}
+	return p, nil
+}
+
+func (p *uePool) isV6() bool {
+	return p.prefix.Addr().Is6()
+}
+
+// at returns the pool's i-th address or prefix.
+func (p *uePool) at(i uint64) netip.Prefix {
+	addr := p.prefix.Addr().AsSlice()
+	n := new(big.Int).SetBytes(addr)
+	n.Add(n, new(big.Int).Lsh(new(big.Int).SetUint64(i), uint(len(addr)*8-p.length)))
+	a, _ := netip.AddrFromSlice(n.FillBytes(addr))
+	return netip.PrefixFrom(a, p.length)
+}
+
+// contains maps an address to the pool's prefix that contains it.
+func (p *uePool) contains(prefix netip.Prefix) (netip.Prefix, bool) {
+	if !p.prefix.Contains(prefix.Addr()) {
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(prefix.Addr(), p.length).Masked(), true
+}
+
+// uePools is the UEIPAllocator of Config.UEIPPools. Allocations and releases
+// are appended to the state file, which is rewritten with the allocations
+// alone once releases make up most of it; those of the previous run are held
+// for their owners until Reconcile.
+type uePools struct {
+	mu        sync.Mutex
+	pools     []*uePool
+	owner     map[netip.Prefix]UEIPOwner
+	inherited map[netip.Prefix]bool
+	stateFile string
+	// log is the state file opened for appending, and records the number
+	// of records in it.
+	log     *os.File
+	records int
+}
+
+// ueIPRecord is a line of the state file: an allocation, or the release of
+// one.
+type ueIPRecord struct {
+	Prefix   string `json:"prefix"`
+	NodeID   string `json:"node_id,omitempty"`
+	SEID     uint64 `json:"seid,omitempty"`
+	Released bool   `json:"released,omitempty"`
+}
+
+// minCompactRecords is the number of records below which the state file is
+// never rewritten.
+const minCompactRecords = 1024
+
+func newUEIPPools(cfgs []UEIPPool, stateFile string) (*uePools, error) {
+	a := &uePools{
+		owner:     make(map[netip.Prefix]UEIPOwner),
+		inherited: make(map[netip.Prefix]bool),
+		stateFile: stateFile,
+	}
+
+	for _, cfg := range cfgs {
+		p, err := newUEPool(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range a.pools {
+			if other.prefix.Overlaps(p.prefix) {
+				return nil, fmt.Errorf("pools %s and %s overlap", other.prefix, p.prefix)
+			}
+		}
+		a.pools = append(a.pools, p)
+	}
+
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	if err := a.compact(); err != nil {
+		return nil, fmt.Errorf("write state file: %w", err)
+	}
+	return a, nil
+}
+
+// load replays the state file. A torn last record, from a crash while it
+// was written, is ignored.
+func (a *uePools) load() error {
+	if a.stateFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(a.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read state file: %w", err)
+	}
+
+	allocations := make(map[netip.Prefix]UEIPOwner)
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var record ueIPRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			// Only the last line lacks its newline.
+			if i == len(lines)-1 {
+				fmt.Printf("Ignoring torn last record of the UE IP state file\n")
+				break
+			}
+			return fmt.Errorf("parse state file line %d: %w", i+1, err)
+		}
+		prefix, err := netip.ParsePrefix(record.Prefix)
+		if err != nil {
+			return fmt.Errorf("parse state file line %d: %w", i+1, err)
+		}
+		if record.Released {
+			delete(allocations, prefix)
+		} else {
+			allocations[prefix] = UEIPOwner{NodeID: record.NodeID, SEID: record.SEID}
+		}
+	}
+	for prefix, owner := range allocations {
+		p, key := a.poolOf(prefix)
+		if p == nil {
+			fmt.Printf("Dropping UE IP allocation %s outside the configured pools\n", prefix)
+			continue
+		}
+		a.owner[key] = owner
+		a.inherited[key] = true
+		p.used++
+	}
+
+	if len(a.inherited) > 0 {
+		fmt.Printf("Holding %d UE IP allocations of a previous run for their sessions\n", len(a.inherited))
+	}
+	return nil
+}
+
+// record appends an allocation, or a release if owner is nil, to the state
+// file, and rewrites the file once most of its records are stale.
+func (a *uePools) record(prefix netip.Prefix, owner *UEIPOwner) {
+	if a.log == nil {
+		return
+	}
+
+	r := ueIPRecord{Prefix: prefix.String(), Released: owner == nil}
+	if owner != nil {
+		r.NodeID, r.SEID = owner.NodeID, owner.SEID
+	}
+	data, err := json.Marshal(&r)
+	if err == nil {
+		_, err = a.log.Write(append(data, '\n'))
+	}
+	if err != nil {
+		fmt.Printf("Failed to record UE IP allocation: %v\n", err)
+		return
+	}
+
+	a.records++
+	if a.records >= minCompactRecords && a.records > 2*len(a.owner) {
+		if err := a.compact(); err != nil {
+			fmt.Printf("Failed to compact UE IP state file: %v\n", err)
+		}
+	}
+}
+
+// compact rewrites the state file with the current allocations, and opens
+// it for appending.
+func (a *uePools) compact() error {
+	if a.stateFile == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for prefix, owner := range a.owner {
+		data, err := json.Marshal(&ueIPRecord{Prefix: prefix.String(), NodeID: owner.NodeID, SEID: owner.SEID})
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(a.stateFile), 0o750); err != nil {
+		return err
+	}
+	tmpPath := a.stateFile + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0o640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, a.stateFile); err != nil {
+		return err
+	}
+
+	log, err := os.OpenFile(a.stateFile, os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	if a.log != nil {
+		a.log.Close()
+	}
+	a.log, a.records = log, len(a.owner)
+	return nil
+}
+
+// poolOf returns the pool an address is in, and the pool's prefix that
+// contains it.
+func (a *uePools) poolOf(prefix netip.Prefix) (*uePool, netip.Prefix) {
+	for _, p := range a.pools {
+		if key, ok := p.contains(prefix); ok {
+			return p, key
+		}
+	}
+	return nil, netip.Prefix{}
+}
+
+// poolsFor returns the pools of the family serving a Network Instance.
+func (a *uePools) poolsFor(networkInstance string, isV6 bool) []*uePool {
+	var named, unnamed []*uePool
+	for _, p := range a.pools {
+		switch {
+		case p.isV6() != isV6:
+		case p.networkInstance == networkInstance:
+			named = append(named, p)
+		case p.networkInstance == "":
+			unnamed = append(unnamed, p)
+		}
+	}
+	if len(named) > 0 {
+		return named
+	}
+	return unnamed
+}
+
+func (a *uePools) Allocate(networkInstance string, isV6 bool, owner UEIPOwner) (netip.Prefix, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	family := "IPv4"
+	if isV6 {
+		family = "IPv6"
+	}
+	pools := a.poolsFor(networkInstance, isV6)
+	if len(pools) == 0 {
+		return netip.Prefix{}, fmt.Errorf("no %s UE IP pool for network instance %q", family, networkInstance)
+	}
+
+	// A session the CP re-establishes with CHOOSE gets its address back.
+	for prefix := range a.inherited {
+		if p, _ := a.poolOf(prefix); a.owner[prefix] == owner && slices.Contains(pools, p) {
+			delete(a.inherited, prefix)
+			return prefix, nil
+		}
+	}
+
+	for _, p := range pools {
+		if p.used == p.size {
+			continue
+		}
+		for n := uint64(0); n < p.size; n++ {
+			i := (p.next + n) % p.size
+			prefix := p.at(p.first + i)
+			if _, used := a.owner[prefix]; used {
+				continue
+			}
+			p.next = (i + 1) % p.size
+			p.used++
+			a.owner[prefix] = owner
+			a.record(prefix, &owner)
+			return prefix, nil
+		}
+	}
+
+	return netip.Prefix{}, fmt.Errorf("%s pools of network instance %q: %w", family, networkInstance, ErrUEIPPoolExhausted)
+}
+
+func (a *uePools) Reserve(prefix netip.Prefix, owner UEIPOwner) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, key := a.poolOf(prefix)
+	if p == nil {
+		return nil
+	}
+
+	if current, used := a.owner[key]; used {
+		if current != owner {
+			return fmt.Errorf("UE IP address %s is in use by another session", key)
+		}
+		delete(a.inherited, key)
+		return nil
+	}
+
+	p.used++
+	a.owner[key] = owner
+	a.record(key, &owner)
+	return nil
+}
+
+func (a *uePools) Release(prefix netip.Prefix) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, key := a.poolOf(prefix)
+	if _, used := a.owner[key]; p == nil || !used {
+		return
+	}
+
+	p.used--
+	delete(a.owner, key)
+	delete(a.inherited, key)
+	a.record(key, nil)
+}
+
+// Reconcile releases the allocations of the previous run that no session
+// claimed again.
+func (a *uePools) Reconcile() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if len(a.inherited) == 0 {
+		return nil
+	}
+
+	for prefix := range a.inherited {
+		if p, _ := a.poolOf(prefix); p != nil {
+			p.used--
+		}
+		delete(a.owner, prefix)
+	}
+	fmt.Printf("Released %d UE IP allocations of sessions that were not restored\n", len(a.inherited))
+	a.inherited = make(map[netip.Prefix]bool)
+	if err := a.compact(); err != nil {
+		fmt.Printf("Failed to compact UE IP state file: %v\n", err)
+	}
+	return nil
+}
+
+// ueIPPrefix returns the PDI's UE address, or prefix, if it has one.
+func ueIPPrefix(pdi *PDI) (netip.Prefix, bool) {
+	addr, err := netip.ParseAddr(pdi.UE_IPAddress)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := addr.BitLen()
+	if addr.Is6() && pdi.UE_IPPrefixLength != 0 {
+		bits = int(pdi.UE_IPPrefixLength)
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), true
+}
+
+// assignUEIP resolves the PDR's UE IP address before it is installed. A
+// CHOOSE request gets the session's address of the family, or one newly
+// allocated from the pools of the PDR's Network Instance, and returns it for
+// the Created PDR IE. An address the CP gave is reserved, so that the pools
+// do not hand it out to another session.
+func (up *UPFunction) assignUEIP(session *Session, pdr *PDR) (*protocol.UEIPAddress, error) {
+	pdi := pdr.PDI
+	if session.ueIPs == nil {
+		session.ueIPs = make(map[netip.Prefix]bool)
+	}
+
+	if !pdi.ChooseUE_IPv4 && !pdi.ChooseUE_IPv6 {
+		prefix, ok := ueIPPrefix(pdi)
+		if !ok || up.ueIPs == nil {
+			return nil, nil
+		}
+		if err := up.ueIPs.Reserve(prefix, session.ueIPOwner()); err != nil {
+			return nil, err
+		}
+		session.ueIPs[prefix] = true
+		return nil, nil
+	}
+
+	if pdi.ChooseUE_IPv4 && pdi.ChooseUE_IPv6 {
+		return nil, fmt.Errorf("CHOOSE of an IPv4 and an IPv6 address needs a PDR for each")
+	}
+	if up.ueIPs == nil {
+		return nil, fmt.Errorf("no UE IP pools configured to CHOOSE a UE IP address")
+	}
+
+	isV6 := pdi.ChooseUE_IPv6
+	prefix, ok := session.ueIP(isV6)
+	if !ok {
+		var err error
+		if prefix, err = up.ueIPs.Allocate(pdi.NetworkInstance, isV6, session.ueIPOwner()); err != nil {
+			return nil, err
+		}
+		session.ueIPs[prefix] = true
+	}
+
+	pdi.ChooseUE_IPv4, pdi.ChooseUE_IPv6 = false, false
+	pdi.UE_IPAddress = prefix.Addr().String()
+
+	ue := &protocol.UEIPAddress{}
+	if isV6 {
+		ue.IPv6 = prefix.Addr().AsSlice()
+		if prefix.Bits() < 128 {
+			pdi.UE_IPPrefixLength = uint8(prefix.Bits())
+			ue.IPv6PrefixLength = pdi.UE_IPPrefixLength
+		}
+	} else {
+		ue.IPv4 = prefix.Addr().AsSlice()
+	}
+	return ue, nil
+}
+
+// ueIPOwner returns the owner of the session's UE IP allocations.
+func (s *Session) ueIPOwner() UEIPOwner {
+	return UEIPOwner{NodeID: s.CPNodeID, SEID: s.RemoteSEID}
+}
+
+// ueIP returns the lowest address of the family the session holds.
+func (s *Session) ueIP(isV6 bool) (netip.Prefix, bool) {
+	var found netip.Prefix
+	for prefix := range s.ueIPs {
+		if prefix.Addr().Is6() == isV6 && (!found.IsValid() || prefix.Addr().Less(found.Addr())) {
+			found = prefix
+		}
+	}
+	return found, found.IsValid()
+}
+
+// releaseUEIPs returns the addresses no PDR of the session uses any more to
+// their pools.
+func (up *UPFunction) releaseUEIPs(session *Session) {
+	used := make(map[netip.Prefix]bool)
+	for _, pdr := range session.PDRs {
+		if prefix, ok := ueIPPrefix(pdr.PDI); ok {
+			used[prefix] = true
+		}
+	}
+
+	for prefix := range session.ueIPs {
+		if !used[prefix] {
+			up.ueIPs.Release(prefix)
+			delete(session.ueIPs, prefix)
+		}
+	}
+}
+
+// releaseSessionUEIPs returns all of the session's addresses to their pools.
+func (up *UPFunction) releaseSessionUEIPs(session *Session) {
+	for prefix := range session.ueIPs {
+		up.ueIPs.Release(prefix)
+	}
+	session.ueIPs = nil
+}
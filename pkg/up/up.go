@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/netip"
 	"sync"
 	"time"
 
@@ -11,17 +12,27 @@ import (
 )
 
 type UPFunction struct {
-	config       *Config
-	nodeID       []byte
-	recoveryTS   uint32
-	transport    *protocol.Transport
-	cpAddr       *net.UDPAddr
-	sessions     map[uint64]*Session
-	dataplane    Dataplane
-	mu           sync.RWMutex
-	ctx          context.Context
-	cancel       context.CancelFunc
-	wg           sync.WaitGroup
+	config         *Config
+	nodeID         []byte
+	cpNodeID       string
+	recoveryTS     uint32
+	transport      *protocol.Transport
+	cpAddr         *net.UDPAddr
+	sessions       map[uint64]*Session
+	dataplane      Dataplane
+	gtpuAddr       net.IP
+	teids          *teidAllocator
+	ueIPs          UEIPAllocator
+	puntSource     PuntSource
+	puntClassifier PuntClassifier
+	puntSubs       map[chan *PuntedPacket]struct{}
+	puntDrops      uint64
+	forwardDrops   uint64
+	puntMu         sync.Mutex
+	mu             sync.RWMutex
+	ctx            context.Context
+	cancel         context.CancelFunc
+	wg             sync.WaitGroup
 }
 
 type Config struct {
@@ -29,23 +40,58 @@ type Config struct {
 	CPAddress         string
 	LocalAddr         string
 	HeartbeatInterval time.Duration
+	ReconcileDelay    time.Duration
+	// UsageInterval is how often URR thresholds are evaluated. Zero
+	// disables threshold-triggered Usage Reports.
+	UsageInterval time.Duration
+	// GTPUAddress is the local N3/S1-U address on which F-TEIDs are
+	// allocated for PDRs that ask the UP to CHOOSE one.
+	GTPUAddress string
+	// UEIPPools are the pools UE IP addresses are allocated from for PDRs
+	// that ask the UP to CHOOSE one, and UEIPStateFile where allocations
+	// are recorded so that they survive a restart.
+	UEIPPools     []UEIPPool
+	UEIPStateFile string
 }
 
 type Session struct {
 	LocalSEID  uint64
 	RemoteSEID uint64
+	CPNodeID   string
 	PDRs       map[uint16]*PDR
 	FARs       map[uint32]*FAR
 	QERs       map[uint32]*QER
 	URRs       map[uint32]*URR
-	CreatedAt  time.Time
+	// BAR is the session's Buffering Action Rule, or nil.
+	BAR       *BAR
+	CreatedAt time.Time
+	usage     map[uint32]*urrUsage
+	// chosen maps the Choose IDs of the session's PDRs to their F-TEIDs.
+	chosen map[uint8]*protocol.FTEID
+	// ueIPs holds the UE IP addresses and prefixes the session holds in the
+	// UE IP pools.
+	ueIPs map[netip.Prefix]bool
+	// buffers holds the downlink packets of the FARs that buffer.
+	buffers map[uint32]*farBuffer
+	// subscriber is where the session's Ethernet frames last came from on
+	// the access side, or nil.
+	subscriber *subscriberAttachment
 }
 
 type PDR struct {
 	ID         uint16
 	Precedence uint32
 	FAR_ID     uint32
+	QER_IDs    []uint32
+	URR_IDs    []uint32
 	PDI        *PDI
+	// OuterHeaderRemoval is the Outer Header Removal description, or nil
+	// if the PDR keeps the outer header.
+	OuterHeaderRemoval *uint8
+	// BBFOuterHeaderRemoval is the BBF (TR-459) Outer Header Removal
+	// description, e.g. PPP/PPPoE/Ethernet to decapsulate a PPPoE session,
+	// or nil.
+	BBFOuterHeaderRemoval *uint8
 }
 
 type PDI struct {
@@ -54,19 +100,127 @@ type PDI struct {
 	UE_IPAddress    string
 	NetworkInstance string
 	ApplicationID   string
+	// LocalFTEID is the F-TEID the PDR matches. The dataplane always sees
+	// an allocated one, never a CHOOSE request.
+	LocalFTEID *protocol.FTEID
+	// EthernetPacketFilters match frames on their MAC addresses, VLAN tags
+	// and EtherType; a frame matching any of them matches.
+	EthernetPacketFilters []*protocol.EthernetPacketFilter
+	// PPPoE narrows the PDI down to a PPPoE session, from the BBF PPPoE
+	// Session ID and PPP Protocol IEs.
+	PPPoE *protocol.PPPoEMatch
+	// UE_IPPrefixLength makes UE_IPAddress the UE's IPv6 prefix of that
+	// length rather than a single address.
+	UE_IPPrefixLength uint8
+	// ChooseUE_IPv4 and ChooseUE_IPv6 ask the UP to allocate UE_IPAddress
+	// from its pools. The dataplane always sees an allocated address.
+	ChooseUE_IPv4 bool
+	ChooseUE_IPv6 bool
+	// FramedRoutes are the subnets routed behind the UE, which the PDI
+	// matches like the UE's address. FramedRouting is the RADIUS
+	// Framed-Routing the CP gave; routing protocols are not spoken.
+	FramedRoutes  []*protocol.FramedRoute
+	FramedRouting uint32
+}
+
+// FramedPrefixes returns the prefixes of the PDI's framed routes.
+func (pdi *PDI) FramedPrefixes() []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(pdi.FramedRoutes))
+	for _, r := range pdi.FramedRoutes {
+		prefixes = append(prefixes, r.Prefix)
+	}
+	return prefixes
+}
+
+// MatchesEthernet reports whether the PDI matches on the Ethernet header
+// rather than on IP or GTP-U.
+func (pdi *PDI) MatchesEthernet() bool {
+	return len(pdi.EthernetPacketFilters) > 0 || pdi.PPPoE != nil
+}
+
+// EthernetHeaderMatches compiles the PDI's Ethernet packet filters and
+// PPPoE session into header patterns, any of which a frame must match.
+func (pdi *PDI) EthernetHeaderMatches() ([]*protocol.EthernetHeaderMatch, error) {
+	var matches []*protocol.EthernetHeaderMatch
+	for _, filter := range pdi.EthernetPacketFilters {
+		m, err := filter.HeaderMatches()
+		if err != nil {
+			return nil, fmt.Errorf("Ethernet packet filter %s: %w", filter, err)
+		}
+		matches = append(matches, m...)
+	}
+
+	if pdi.PPPoE != nil {
+		m, err := pdi.PPPoE.HeaderMatches(matches)
+		if err != nil {
+			return nil, fmt.Errorf("PPPoE %s: %w", pdi.PPPoE, err)
+		}
+		matches = m
+	}
+	return matches, nil
 }
 
 type FAR struct {
-	ID          uint32
-	ApplyAction uint8
+	ID                   uint32
+	ApplyAction          uint8
+	ForwardingParameters *ForwardingParameters
+	// BAR_ID is the BAR applied while the FAR buffers, or nil.
+	BAR_ID *uint8
+	// DuplicatingParameters are where copies of the FAR's packets go when
+	// it has the DUPL action.
+	DuplicatingParameters []*DuplicatingParameters
+}
+
+type ForwardingParameters struct {
+	DestinationInterface uint8
+	NetworkInstance      string
+	OuterHeaderCreation  *protocol.OuterHeaderCreation
+	// PPPoE is the session the FAR encapsulates packets into, from a BBF
+	// Outer Header Creation with the PPP flag, or nil.
+	PPPoE *protocol.PPPoESession
+	// L2TP is the session the FAR forwards PPP frames into towards the LNS,
+	// from a BBF Outer Header Creation with the L2TP flag, or nil.
+	L2TP *protocol.L2TPSession
+}
+
+// DuplicatingParameters describe one destination, e.g. a lawful intercept
+// collector, that receives copies of a FAR's packets.
+type DuplicatingParameters struct {
+	DestinationInterface uint8
+	// OuterHeaderCreation encapsulates the copies towards the collector, or
+	// nil to send them as they are.
+	OuterHeaderCreation *protocol.OuterHeaderCreation
+	// ForwardingPolicy names a policy pre-configured in the dataplane, or
+	// is empty.
+	ForwardingPolicy string
 }
 
 type QER struct {
-	ID uint32
+	ID         uint32
+	GateStatus uint8
+	MBR_UL     uint64
+	MBR_DL     uint64
+	GBR_UL     uint64
+	GBR_DL     uint64
+}
+
+// BAR is a Buffering Action Rule.
+type BAR struct {
+	ID uint8
+	// DownlinkDataNotificationDelay delays the Downlink Data Report for
+	// the first packet buffered.
+	DownlinkDataNotificationDelay time.Duration
+	// SuggestedBufferingPacketsCount caps the packets buffered per FAR.
+	// Zero leaves it to the UP.
+	SuggestedBufferingPacketsCount uint8
 }
 
 type URR struct {
-	ID uint32
+	ID                uint32
+	MeasurementMethod uint8
+	ReportingTriggers uint32
+	VolumeThreshold   uint64
+	TimeThreshold     uint32
 }
 
 func NewUPFunction(cfg *Config, dp Dataplane) (*UPFunction, error) {
@@ -86,6 +240,22 @@ func NewUPFunction(cfg *Config, dp Dataplane) (*UPFunction, error) {
 		return nil, fmt.Errorf("resolve CP address: %w", err)
 	}
 
+	var gtpuAddr net.IP
+	if cfg.GTPUAddress != "" {
+		if gtpuAddr = net.ParseIP(cfg.GTPUAddress); gtpuAddr == nil {
+			return nil, fmt.Errorf("invalid GTP-U address %q", cfg.GTPUAddress)
+		}
+	}
+
+	var ueIPs UEIPAllocator
+	if len(cfg.UEIPPools) > 0 {
+		pools, err := newUEIPPools(cfg.UEIPPools, cfg.UEIPStateFile)
+		if err != nil {
+			return nil, fmt.Errorf("UE IP pools: %w", err)
+		}
+		ueIPs = pools
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	up := &UPFunction{
@@ -96,6 +266,10 @@ func NewUPFunction(cfg *Config, dp Dataplane) (*UPFunction, error) {
 		cpAddr:     cpAddr,
 		sessions:   make(map[uint64]*Session),
 		dataplane:  dp,
+		gtpuAddr:   gtpuAddr,
+		teids:      newTEIDAllocator(),
+		ueIPs:      ueIPs,
+		puntSubs:   make(map[chan *PuntedPacket]struct{}),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
@@ -113,6 +287,26 @@ func (up *UPFunction) Start(ctx context.Context) error {
 	up.wg.Add(1)
 	go up.heartbeatLoop()
 
+	if reconciler, ok := up.backend().(Reconciler); ok {
+		up.wg.Add(1)
+		go up.reconcile("Dataplane", reconciler)
+	}
+
+	if reconciler, ok := up.ueIPs.(Reconciler); ok {
+		up.wg.Add(1)
+		go up.reconcile("UE IP pool", reconciler)
+	}
+
+	if up.config.UsageInterval > 0 {
+		up.wg.Add(1)
+		go up.usageLoop()
+	}
+
+	if up.puntSource != nil {
+		up.wg.Add(1)
+		go up.puntLoop()
+	}
+
 	<-ctx.Done()
 	return up.Stop()
 }
@@ -120,6 +314,9 @@ func (up *UPFunction) Start(ctx context.Context) error {
 func (up *UPFunction) Stop() error {
 	up.cancel()
 	up.transport.Close()
+	if up.puntSource != nil {
+		up.puntSource.Close()
+	}
 	up.wg.Wait()
 	return nil
 }
@@ -148,6 +345,12 @@ func (up *UPFunction) establishAssociation() error {
 		return fmt.Errorf("association setup rejected: cause=%d", cause)
 	}
 
+	if nodeIDIE := resp.FindIE(protocol.IETypeNodeID); nodeIDIE != nil && len(nodeIDIE.Value) > 0 {
+		up.mu.Lock()
+		up.cpNodeID = string(nodeIDIE.Value[1:])
+		up.mu.Unlock()
+	}
+
 	fmt.Printf("Association established with CP %s\n", up.cpAddr)
 	return nil
 }
@@ -169,6 +372,25 @@ func (up *UPFunction) heartbeatLoop() {
 	}
 }
 
+// reconcile lets the CP restore its sessions for ReconcileDelay before the
+// reconciler removes what none of them claimed.
+func (up *UPFunction) reconcile(name string, reconciler Reconciler) {
+	defer up.wg.Done()
+
+	timer := time.NewTimer(up.config.ReconcileDelay)
+	defer timer.Stop()
+
+	select {
+	case <-up.ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	if err := reconciler.Reconcile(); err != nil {
+		fmt.Printf("%s reconciliation failed: %v\n", name, err)
+	}
+}
+
 func (up *UPFunction) allocSEID() uint64 {
 	up.mu.Lock()
 	defer up.mu.Unlock()
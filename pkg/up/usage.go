@@ -0,0 +1,319 @@
+package up
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/veesix-networks/pfcp-go/pkg/protocol"
+)
+
+type usageCount struct {
+	packets uint64
+	bytes   uint64
+}
+
+// urrUsage is what a URR has measured since its last Usage Report. last holds
+// the dataplane counters of each PDR at the previous reading, taken at
+// lastRead.
+type urrUsage struct {
+	seqNum   uint32
+	start    time.Time
+	uplink   usageCount
+	downlink usageCount
+	last     map[uint16]usageCount
+	lastRead time.Time
+}
+
+type pendingReport struct {
+	remoteSEID uint64
+	reports    []*protocol.UsageReport
+}
+
+// pdrReadings are dataplane counters of a session's PDRs read beforehand, so
+// that pollUsage does not hold up.mu while the dataplane dumps them. With
+// nil readings the counters are read as the URRs are measured.
+type pdrReadings struct {
+	at     time.Time
+	counts map[uint16]usageCount
+}
+
+// usagePoll is one session's part of a usage poll.
+type usagePoll struct {
+	seid     uint64
+	pdrIDs   []uint16
+	readings *pdrReadings
+}
+
+// meteredPDRs returns the PDRs of the session that a URR measures.
+func meteredPDRs(session *Session) []uint16 {
+	var ids []uint16
+	for _, pdr := range session.PDRs {
+		for _, urrID := range pdr.URR_IDs {
+			if _, ok := session.URRs[urrID]; ok {
+				ids = append(ids, pdr.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// readPDRUsage reads the counters of the session's PDRs.
+func (up *UPFunction) readPDRUsage(seid uint64, pdrIDs []uint16) *pdrReadings {
+	readings := &pdrReadings{at: time.Now(), counts: make(map[uint16]usageCount, len(pdrIDs))}
+
+	counter, ok := up.backend().(UsageCounter)
+	if !ok {
+		return readings
+	}
+
+	for _, pdrID := range pdrIDs {
+		packets, bytes, err := counter.PDRUsage(seid, pdrID)
+		if err != nil {
+			fmt.Printf("Failed to read usage of PDR %d in session %d: %v\n", pdrID, seid, err)
+			continue
+		}
+		readings.counts[pdrID] = usageCount{packets: packets, bytes: bytes}
+	}
+	return readings
+}
+
+// trackURR starts measuring a new URR. Traffic its PDRs counted before the
+// URR existed is read as a baseline and discarded.
+func (up *UPFunction) trackURR(session *Session, urr *URR, readings *pdrReadings) {
+	if session.usage == nil {
+		session.usage = make(map[uint32]*urrUsage)
+	}
+	if _, exists := session.usage[urr.ID]; exists {
+		return
+	}
+
+	usage := &urrUsage{
+		start: time.Now(),
+		last:  make(map[uint16]usageCount),
+	}
+	up.measureURR(session, urr.ID, usage, readings)
+	usage.uplink = usageCount{}
+	usage.downlink = usageCount{}
+
+	session.usage[urr.ID] = usage
+}
+
+// measureURR adds the traffic the URR's PDRs matched since the last reading.
+// Access PDRs count as uplink, all others as downlink. PDRs missing from
+// readings, e.g. added since they were read, are measured next time.
+func (up *UPFunction) measureURR(session *Session, urrID uint32, usage *urrUsage, readings *pdrReadings) {
+	var pdrIDs []uint16
+	for _, pdr := range session.PDRs {
+		if containsURR(pdr.URR_IDs, urrID) {
+			pdrIDs = append(pdrIDs, pdr.ID)
+		}
+	}
+	if readings == nil {
+		readings = up.readPDRUsage(session.LocalSEID, pdrIDs)
+	}
+	// A session modification may have measured the URR since the readings
+	// were taken, and older counters would look like a counter reset.
+	if readings.at.Before(usage.lastRead) {
+		return
+	}
+	usage.lastRead = readings.at
+
+	for _, pdrID := range pdrIDs {
+		pdr := session.PDRs[pdrID]
+		current, ok := readings.counts[pdrID]
+		if !ok {
+			continue
+		}
+
+		delta := current
+		if prev, ok := usage.last[pdr.ID]; ok && current.packets >= prev.packets && current.bytes >= prev.bytes {
+			delta = usageCount{packets: current.packets - prev.packets, bytes: current.bytes - prev.bytes}
+		}
+		usage.last[pdr.ID] = current
+
+		total := &usage.downlink
+		if pdr.PDI != nil && pdr.PDI.SourceInterface == protocol.SourceInterfaceAccess {
+			total = &usage.uplink
+		}
+		total.packets += delta.packets
+		total.bytes += delta.bytes
+	}
+}
+
+// report closes the current measurement period and starts the next one.
+func (usage *urrUsage) report(urrID uint32, trigger uint32, now time.Time) *protocol.UsageReport {
+	r := &protocol.UsageReport{
+		URRID:           urrID,
+		SeqNum:          usage.seqNum,
+		Trigger:         trigger,
+		StartTime:       usage.start,
+		EndTime:         now,
+		UplinkVolume:    usage.uplink.bytes,
+		DownlinkVolume:  usage.downlink.bytes,
+		UplinkPackets:   usage.uplink.packets,
+		DownlinkPackets: usage.downlink.packets,
+		Duration:        uint32(now.Sub(usage.start) / time.Second),
+	}
+
+	usage.seqNum++
+	usage.start = now
+	usage.uplink = usageCount{}
+	usage.downlink = usageCount{}
+
+	return r
+}
+
+// thresholdTrigger returns the Usage Report Trigger flags for the thresholds
+// the URR has reached, or 0.
+func thresholdTrigger(urr *URR, usage *urrUsage, now time.Time) uint32 {
+	var trigger uint32
+
+	if urr.ReportingTriggers&protocol.ReportingTriggerVolumeThreshold != 0 && urr.VolumeThreshold > 0 &&
+		usage.uplink.bytes+usage.downlink.bytes >= urr.VolumeThreshold {
+		trigger |= protocol.UsageReportTriggerVolumeThreshold
+	}
+
+	if urr.ReportingTriggers&protocol.ReportingTriggerTimeThreshold != 0 && urr.TimeThreshold > 0 &&
+		now.Sub(usage.start) >= time.Duration(urr.TimeThreshold)*time.Second {
+		trigger |= protocol.UsageReportTriggerTimeThreshold
+	}
+
+	return trigger
+}
+
+// usageReports measures every URR of the session from readings, or from the
+// dataplane if they are nil. With a trigger, all URRs are reported;
+// otherwise only those that reached a threshold.
+func (up *UPFunction) usageReports(session *Session, trigger uint32, readings *pdrReadings) []*protocol.UsageReport {
+	urrIDs := make([]uint32, 0, len(session.URRs))
+	for id := range session.URRs {
+		urrIDs = append(urrIDs, id)
+	}
+	sort.Slice(urrIDs, func(i, j int) bool { return urrIDs[i] < urrIDs[j] })
+
+	now := time.Now()
+	var reports []*protocol.UsageReport
+	for _, id := range urrIDs {
+		urr := session.URRs[id]
+		up.trackURR(session, urr, readings)
+
+		usage := session.usage[id]
+		up.measureURR(session, id, usage, readings)
+
+		urrTrigger := trigger
+		if urrTrigger == 0 {
+			urrTrigger = thresholdTrigger(urr, usage, now)
+		}
+		if urrTrigger != 0 {
+			reports = append(reports, usage.report(id, urrTrigger, now))
+		}
+	}
+
+	return reports
+}
+
+func (up *UPFunction) usageLoop() {
+	defer up.wg.Done()
+
+	ticker := time.NewTicker(up.config.UsageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-up.ctx.Done():
+			return
+		case <-ticker.C:
+			up.pollUsage()
+		}
+	}
+}
+
+// pollUsage reads the counters of the metered PDRs without holding up.mu,
+// then measures the URRs of the sessions that are still there.
+func (up *UPFunction) pollUsage() {
+	var polls []*usagePoll
+
+	up.mu.Lock()
+	for seid, session := range up.sessions {
+		if len(session.URRs) > 0 {
+			polls = append(polls, &usagePoll{seid: seid, pdrIDs: meteredPDRs(session)})
+		}
+	}
+	up.mu.Unlock()
+
+	for _, poll := range polls {
+		poll.readings = up.readPDRUsage(poll.seid, poll.pdrIDs)
+	}
+
+	var pending []pendingReport
+
+	up.mu.Lock()
+	for _, poll := range polls {
+		session, ok := up.sessions[poll.seid]
+		if !ok {
+			continue
+		}
+		if reports := up.usageReports(session, 0, poll.readings); len(reports) > 0 {
+			pending = append(pending, pendingReport{remoteSEID: session.RemoteSEID, reports: reports})
+		}
+	}
+	up.mu.Unlock()
+
+	for _, p := range pending {
+		if err := up.sendUsageReports(p.remoteSEID, p.reports); err != nil {
+			fmt.Printf("Failed to send usage reports for CP session %d: %v\n", p.remoteSEID, err)
+		}
+	}
+}
+
+func (up *UPFunction) sendUsageReports(remoteSEID uint64, reports []*protocol.UsageReport) error {
+	ies, err := usageReportIEs(protocol.IETypeUsageReportSessionReport, reports)
+	if err != nil {
+		return err
+	}
+
+	return up.sendSessionReport(remoteSEID, protocol.ReportTypeUsage, ies)
+}
+
+func (up *UPFunction) sendSessionReport(remoteSEID uint64, reportType uint8, ies []*protocol.IE) error {
+	req := protocol.NewSessionReportRequest(0, remoteSEID, reportType, ies)
+	resp, err := up.transport.SendRequest(req, up.cpAddr, 3*time.Second, 3)
+	if err != nil {
+		return fmt.Errorf("send session report request: %w", err)
+	}
+
+	causeIE := resp.FindIE(protocol.IETypeCause)
+	if causeIE == nil {
+		return fmt.Errorf("no cause IE in response")
+	}
+
+	cause, _ := causeIE.GetCause()
+	if cause != protocol.CauseRequestAccepted {
+		return fmt.Errorf("session report rejected: cause=%d", cause)
+	}
+
+	return nil
+}
+
+func usageReportIEs(ieType uint16, reports []*protocol.UsageReport) ([]*protocol.IE, error) {
+	ies := make([]*protocol.IE, 0, len(reports))
+	for _, r := range reports {
+		ie, err := protocol.NewUsageReportIE(ieType, r)
+		if err != nil {
+			return nil, fmt.Errorf("encode usage report for URR %d: %w", r.URRID, err)
+		}
+		ies = append(ies, ie)
+	}
+	return ies, nil
+}
+
+func containsURR(ids []uint32, id uint32) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
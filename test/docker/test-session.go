@@ -62,7 +62,7 @@ func main() {
 		},
 	}
 
-	seid, err := cpFunc.CreateSession("up-node-1", pdrs, fars, nil, nil)
+	seid, err := cpFunc.CreateSession("up-node-1", pdrs, fars, nil, nil, nil, "")
 	if err != nil {
 		log.Fatalf("Failed to create session: %v", err)
 	}